@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	mainOpenRegex  = regexp.MustCompile(`<main[\s>]`)
+	skipLinkRegex  = regexp.MustCompile(`<a href="#([^"]+)" class="skip-link"`)
+	headingRegex   = regexp.MustCompile(`<h([1-6])[\s>]`)
+	imgAltRegex    = regexp.MustCompile(`\salt="`)
+	ariaHiddenAttr = regexp.MustCompile(`\saria-hidden="true"`)
+)
+
+// checkPageStructure returns the accessibility problems in a rendered page:
+// a missing or repeated main landmark, a skip link that leads nowhere, a
+// heading outline that doesn't start at one h1 or skips levels, and images
+// without alt text
+func checkPageStructure(body string) []string {
+	var problems []string
+	if n := len(mainOpenRegex.FindAllString(body, -1)); n != 1 {
+		problems = append(problems, fmt.Sprintf("%d <main> landmarks, want 1", n))
+	}
+	if m := skipLinkRegex.FindStringSubmatch(body); m == nil {
+		problems = append(problems, "no skip link")
+	} else if !strings.Contains(body, ` id="`+m[1]+`"`) {
+		problems = append(problems, "skip link target #"+m[1]+" is missing")
+	}
+
+	// The outline is the main content's; dialogs and the footer sit outside it
+	content := body
+	if start := mainOpenRegex.FindStringIndex(body); start != nil {
+		content = body[start[0]:]
+		if end := strings.Index(content, "</main>"); end >= 0 {
+			content = content[:end]
+		}
+	}
+	h1s, prev := 0, 0
+	for _, m := range headingRegex.FindAllStringSubmatch(content, -1) {
+		level, _ := strconv.Atoi(m[1])
+		if level == 1 {
+			h1s++
+		}
+		switch {
+		case prev == 0 && level != 1:
+			problems = append(problems, fmt.Sprintf("first heading is h%d, want h1", level))
+		case prev != 0 && level > prev+1:
+			problems = append(problems, fmt.Sprintf("h%d follows h%d, skipping a level", level, prev))
+		}
+		prev = level
+	}
+	switch {
+	case h1s == 0:
+		problems = append(problems, "no h1")
+	case h1s > 1:
+		problems = append(problems, fmt.Sprintf("%d h1 headings, want 1", h1s))
+	}
+
+	for _, img := range imgTagRegex.FindAllString(body, -1) {
+		if imgAltRegex.MatchString(img) || ariaHiddenAttr.MatchString(img) {
+			continue
+		}
+		src := ""
+		if m := imgSrcRegex.FindStringSubmatch(img); m != nil {
+			src = " " + m[1]
+		}
+		problems = append(problems, "image without alt text:"+src)
+	}
+	return problems
+}
+
+// checkSite crawls the pages a static export would contain and checks the
+// structure of each HTML page. It returns the problems found as "path: problem".
+func checkSite(posts *PostIndex) []string {
+	base, _ := url.Parse("http://localhost")
+	e := &siteExporter{handler: exportHandler(posts), base: base, seen: make(map[string]bool)}
+	for _, s := range exportSeeds {
+		e.add(s)
+	}
+
+	var problems []string
+	for len(e.queue) > 0 {
+		p := e.queue[0]
+		e.queue = e.queue[1:]
+		// Images and files have nothing to check and may be slow to produce
+		if strings.HasPrefix(p, "/images/") || strings.HasPrefix(p, "/static/") || path.Ext(p) == ".pdf" {
+			continue
+		}
+		w := e.fetch(p)
+		if w.status >= 300 && w.status < 400 {
+			e.add(w.header.Get("Location"))
+			continue
+		}
+		if w.status != http.StatusOK {
+			continue
+		}
+		ct := w.header.Get("Content-Type")
+		e.addLinks(w.body.Bytes(), ct)
+		if !strings.HasPrefix(ct, "text/html") {
+			continue
+		}
+		for _, problem := range checkPageStructure(w.body.String()) {
+			problems = append(problems, p+": "+problem)
+		}
+	}
+	return problems
+}
+
+// runCheckCommand checks the content and templates as the server does at
+// startup, then for slug collisions, broken [[slug]] links, and every page
+// for accessibility problems, printing each one
+func runCheckCommand() error {
+	if err := checkStartup("."); err != nil {
+		return fmt.Errorf("startup check failed:\n%w", err)
+	}
+	markdown = newMarkdown(cfg.Markdown)
+	playground = newPlaygroundProxy(cfg.Playground)
+	repoCards = newRepoCards(cfg.Repos)
+	store, err := openPostStore(context.Background(), cfg.PostStore)
+	if err != nil {
+		return err
+	}
+	posts, err := NewStoreIndex(store)
+	if err != nil {
+		return err
+	}
+	linkedPosts = posts
+	sectionClashes, err := sectionCollisions()
+	if err != nil {
+		return err
+	}
+	var problems []string
+	for _, c := range append(posts.Collisions(), sectionClashes...) {
+		problems = append(problems, c.String())
+	}
+	for _, l := range posts.BrokenLinks() {
+		problems = append(problems, l.String())
+	}
+	problems = append(problems, checkSite(posts)...)
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("check: %d problems", len(problems))
+	}
+	return nil
+}
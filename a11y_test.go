@@ -0,0 +1,55 @@
+package main
+
+import (
+	"html/template"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestCheckPageStructure(t *testing.T) {
+	const skip = `<a href="#main-content" class="skip-link">Skip</a>`
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"ok", skip + `<main id="main-content"><h1>A</h1><h2>B</h2><h3>C</h3><h2>D</h2><img src="/a.png" alt=""></main>`, nil},
+		{"no main", skip + `<div id="main-content"><h1>A</h1></div>`, []string{"0 <main> landmarks, want 1"}},
+		{"two mains", skip + `<main id="main-content"><h1>A</h1></main><main></main>`, []string{"2 <main> landmarks, want 1"}},
+		{"no skip link", `<main><h1>A</h1></main>`, []string{"no skip link"}},
+		{"skip target missing", skip + `<main><h1>A</h1></main>`, []string{"skip link target #main-content is missing"}},
+		{"starts at h2", skip + `<main id="main-content"><h2>A</h2></main>`, []string{"first heading is h2, want h1", "no h1"}},
+		{"skips a level", skip + `<main id="main-content"><h1>A</h1><h3>B</h3></main>`, []string{"h3 follows h1, skipping a level"}},
+		{"two h1s", skip + `<main id="main-content"><h1>A</h1><h1>B</h1></main>`, []string{"2 h1 headings, want 1"}},
+		{"footer heading ignored", skip + `<main id="main-content"><h1>A</h1></main><footer><h2 id="x">Dialog</h2></footer>`, nil},
+		{"image without alt", skip + `<main id="main-content"><h1>A</h1><img src="/a.png"><img src="/b.png" aria-hidden="true"></main>`, []string{"image without alt text: /a.png"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkPageStructure(tt.body); !slices.Equal(got, tt.want) {
+				t.Errorf("checkPageStructure() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderPage_Structure(t *testing.T) {
+	w := httptest.NewRecorder()
+	renderPage(w, "Hello", template.HTML("<h1>Hello</h1>\n<h2>More</h2>\n"))
+	if problems := checkPageStructure(w.Body.String()); len(problems) > 0 {
+		t.Errorf("base template problems: %q", problems)
+	}
+}
+
+func TestSectionEntryHandler_Structure(t *testing.T) {
+	for _, slug := range []string{"2026-01-01-old", "2026-02-01-new"} {
+		req := httptest.NewRequest("GET", "/notes/x", nil)
+		req.SetPathValue("slug", slug)
+		w := httptest.NewRecorder()
+		SectionEntryHandler(sections[0], testNotes())(w, req)
+		if problems := checkPageStructure(w.Body.String()); len(problems) > 0 {
+			t.Errorf("%s: %q", slug, problems)
+		}
+	}
+}
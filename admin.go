@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	adminCookieName = "admin_session"
+	adminSessionTTL = 7 * 24 * time.Hour
+)
+
+// AdminAuth guards the /admin area with a single username/password and a signed session cookie
+type AdminAuth struct {
+	User     string
+	Password string
+	secret   []byte
+}
+
+// NewAdminAuth creates the admin authenticator. When secret is empty a random one is
+// generated, which means sessions do not survive a restart.
+func NewAdminAuth(user, password, secret string) *AdminAuth {
+	key := []byte(secret)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			fatal("Failed to generate session secret", err)
+		}
+	}
+	return &AdminAuth{User: user, Password: password, secret: key}
+}
+
+// Enabled reports whether admin credentials are configured
+func (a *AdminAuth) Enabled() bool {
+	return a.User != "" && a.Password != ""
+}
+
+// sign returns the MAC for a session expiry timestamp
+func (a *AdminAuth) sign(expiry string) string {
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte(a.User + "|" + expiry))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// IsAdmin reports whether the request carries a valid, unexpired admin session
+func (a *AdminAuth) IsAdmin(r *http.Request) bool {
+	if !a.Enabled() {
+		return false
+	}
+	cookie, err := r.Cookie(adminCookieName)
+	if err != nil {
+		return false
+	}
+	expiry, mac, ok := strings.Cut(cookie.Value, ".")
+	if !ok || !hmac.Equal([]byte(mac), []byte(a.sign(expiry))) {
+		return false
+	}
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return false
+	}
+	return time.Now().Before(time.Unix(unix, 0))
+}
+
+// Require wraps an admin handler, redirecting anonymous visitors to the login page
+func (a *AdminAuth) Require(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setSecurityHeaders(w)
+		w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+
+		if !a.Enabled() {
+			http.NotFound(w, r)
+			return
+		}
+		if !a.IsAdmin(r) {
+			http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// LoginHandler shows the login form and starts a session on valid credentials
+func (a *AdminAuth) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	setSecurityHeaders(w)
+	w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+
+	if !a.Enabled() {
+		http.NotFound(w, r)
+		return
+	}
+
+	var errMsg string
+	if r.Method == http.MethodPost {
+		user := r.PostFormValue("username")
+		password := r.PostFormValue("password")
+		userOK := subtle.ConstantTimeCompare([]byte(user), []byte(a.User)) == 1
+		passOK := subtle.ConstantTimeCompare([]byte(password), []byte(a.Password)) == 1
+		if userOK && passOK {
+			expiry := strconv.FormatInt(time.Now().Add(adminSessionTTL).Unix(), 10)
+			cookie := newCookie(r, adminCookieName, expiry+"."+a.sign(expiry))
+			cookie.SameSite = http.SameSiteStrictMode
+			cookie.MaxAge = int(adminSessionTTL.Seconds())
+			cookie.HttpOnly = true
+			http.SetCookie(w, cookie)
+			http.Redirect(w, r, "/admin", http.StatusSeeOther)
+			return
+		}
+		slog.Warn("Failed admin login", "remote", clientIP(r))
+		errMsg = "Invalid username or password"
+	}
+
+	var content bytes.Buffer
+	content.WriteString("<div class=\"admin-page\">\n<h1>Admin Login</h1>\n")
+	if errMsg != "" {
+		content.WriteString("<p class=\"form-error\">" + template.HTMLEscapeString(errMsg) + "</p>\n")
+	}
+	content.WriteString(`<form method="post" action="/admin/login" class="admin-form">
+	<label>Username <input type="text" name="username" autocomplete="username" required></label>
+	<label>Password <input type="password" name="password" autocomplete="current-password" required></label>
+	<button type="submit" class="modal-btn">Log in</button>
+</form>
+</div>`)
+
+	renderPage(w, "Admin Login", template.HTML(content.String()))
+}
+
+// LogoutHandler clears the admin session
+func (a *AdminAuth) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	cookie := newCookie(r, adminCookieName, "")
+	cookie.SameSite = http.SameSiteStrictMode
+	cookie.MaxAge = -1
+	cookie.HttpOnly = true
+	http.SetCookie(w, cookie)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// adminNav renders the navigation shared by all admin pages
+func adminNav() string {
+	return `<nav class="admin-nav">
+	<a href="/admin">Dashboard</a>
+	<a href="/admin/posts">Posts</a>
+	<a href="/admin/images">Images</a>
+	<a href="/admin/inbox">Inbox</a>
+	<a href="/admin/comments">Comments</a>
+	<a href="/admin/crossposts">Cross-posts</a>
+	<a href="/admin/previews">Previews</a>
+	<a href="/admin/mentions">Mentions</a>
+	<a href="/admin/stats">Stats</a>
+	<a href="/admin/jobs">Jobs</a>
+	<form method="post" action="/admin/logout"><button type="submit">Log out</button></form>
+</nav>
+`
+}
+
+// AdminHandler renders the admin dashboard
+func AdminHandler(w http.ResponseWriter, r *http.Request) {
+	var content bytes.Buffer
+	content.WriteString("<div class=\"admin-page\">\n")
+	content.WriteString(adminNav())
+	content.WriteString("<h1>Dashboard</h1>\n")
+	content.WriteString("<p>Manage background work and site content.</p>\n")
+	content.WriteString("</div>")
+
+	renderPage(w, "Admin", template.HTML(content.String()))
+}
+
+// AdminJobsHandler shows the dead-letter list of jobs that exhausted their retries
+func AdminJobsHandler(q *JobQueue) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobs, err := q.DeadJobs()
+		if err != nil {
+			slog.Error("Error listing dead jobs", "err", err)
+			http.Error(w, "Could not list jobs", http.StatusInternalServerError)
+			return
+		}
+
+		var content bytes.Buffer
+		content.WriteString("<div class=\"admin-page\">\n")
+		content.WriteString(adminNav())
+		content.WriteString("<h1>Failed Jobs</h1>\n")
+		if len(jobs) == 0 {
+			content.WriteString("<p>No failed jobs. 🎉</p>\n")
+		} else {
+			content.WriteString("<table class=\"admin-table\">\n<tr><th>ID</th><th>Kind</th><th>Attempts</th><th>Error</th><th>Created</th><th></th></tr>\n")
+			for _, j := range jobs {
+				id := strconv.FormatInt(j.ID, 10)
+				content.WriteString("<tr>")
+				content.WriteString("<td>" + id + "</td>")
+				content.WriteString("<td>" + template.HTMLEscapeString(j.Kind) + "</td>")
+				content.WriteString("<td>" + strconv.Itoa(j.Attempts) + "</td>")
+				content.WriteString("<td><code>" + template.HTMLEscapeString(j.LastError) + "</code></td>")
+				content.WriteString("<td>" + j.CreatedAt.Format("Jan 2, 2006 15:04") + "</td>")
+				content.WriteString("<td class=\"admin-actions\">")
+				content.WriteString("<form method=\"post\" action=\"/admin/jobs/" + id + "/retry\"><button type=\"submit\">Retry</button></form>")
+				content.WriteString("<form method=\"post\" action=\"/admin/jobs/" + id + "/delete\"><button type=\"submit\">Delete</button></form>")
+				content.WriteString("</td></tr>\n")
+			}
+			content.WriteString("</table>\n")
+		}
+		content.WriteString("</div>")
+
+		renderPage(w, "Failed Jobs", template.HTML(content.String()))
+	}
+}
+
+// AdminJobActionHandler retries or deletes a dead job
+func AdminJobActionHandler(q *JobQueue) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid job ID", http.StatusBadRequest)
+			return
+		}
+
+		switch r.PathValue("action") {
+		case "retry":
+			err = q.Retry(id)
+		case "delete":
+			err = q.Delete(id)
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		if err == ErrJobNotFound {
+			http.Error(w, "Job not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.Error("Error updating job", "id", id, "err", err)
+			http.Error(w, "Could not update job", http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, "/admin/jobs", http.StatusSeeOther)
+	}
+}
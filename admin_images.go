@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"image"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxUploadPixels refuses images that would take too much memory to
+	// resize, whatever their file size; 100 megapixels is beyond any phone
+	maxUploadPixels = 100_000_000
+	// adminImagesListed is how many of the newest images the upload page lists
+	adminImagesListed = 30
+	// uploadWidth is the width upload snippets ask for, wide enough for the
+	// article column on high-density screens
+	uploadWidth = 800
+)
+
+// uploadNameRegex matches the runs of characters left out of uploaded file names
+var uploadNameRegex = regexp.MustCompile(`[^a-z0-9_-]+`)
+
+// uploadFormats maps the formats accepted for upload to their file extension
+var uploadFormats = map[string]string{"jpeg": ".jpg", "png": ".png", "gif": ".gif"}
+
+// ImageVariantsJobPayload is the payload of an "image-variants" job
+type ImageVariantsJobPayload struct {
+	Name string `json:"name"`
+}
+
+// ImageVariantsJob returns a JobHandler that makes the resized and WebP copies
+// of an uploaded image
+func ImageVariantsJob(dir, cacheDir string) JobHandler {
+	return func(ctx context.Context, payload []byte) error {
+		var p ImageVariantsJobPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return fmt.Errorf("decode image-variants job: %w", err)
+		}
+		if !fs.ValidPath(p.Name) || !isResizableImage(p.Name) {
+			return fmt.Errorf("invalid image %q", p.Name)
+		}
+		if err := generateImageVariants(dir, cacheDir, p.Name); err != nil {
+			return err
+		}
+		_, err := preparePlaceholder(filepath.Join(dir, filepath.FromSlash(p.Name)))
+		return err
+	}
+}
+
+// uploadFileName makes a file name for an upload from the name it was sent
+// with: lowercase letters, digits, dashes, and underscores, and the extension
+// of its actual format
+func uploadFileName(filename, format string) string {
+	base := strings.ToLower(strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename)))
+	base = strings.Trim(uploadNameRegex.ReplaceAllString(base, "-"), "-")
+	if base == "" {
+		base = "image"
+	}
+	return base + uploadFormats[format]
+}
+
+// saveUpload writes data to dir under name, or name-1, name-2, and so on if
+// it's taken: a file is never replaced, since its resized copies are cached
+// for good. It returns the name used.
+func saveUpload(dir, name string, data []byte) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for n := 0; ; n++ {
+		if n > 0 {
+			name = base + "-" + strconv.Itoa(n) + ext
+		}
+		f, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		_, err = f.Write(data)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(f.Name())
+			return "", err
+		}
+		return name, nil
+	}
+}
+
+// imageSnippet is the markdown that shows an uploaded image in a post
+func imageSnippet(name string) string {
+	src := "/images/" + name
+	if isResizableImage(name) {
+		src += "?w=" + strconv.Itoa(uploadWidth)
+	}
+	return "![Description](" + src + ")"
+}
+
+// AdminImageUploadHandler stores an image uploaded as the "image" form field
+// in dir, without its metadata, and queues the making of its resized copies. Requests that accept JSON
+// get the image's URL and markdown; forms go back to the images page.
+func AdminImageUploadHandler(dir string, queue *JobQueue) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, int64(cfg.MaxImageUploadMB)<<20)
+		file, header, err := r.FormFile("image")
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, "Image too large, the limit is "+strconv.Itoa(cfg.MaxImageUploadMB)+" MB", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "No image uploaded", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		data, err := io.ReadAll(file)
+		if err != nil {
+			http.Error(w, "Could not read image", http.StatusBadRequest)
+			return
+		}
+
+		ic, format, err := image.DecodeConfig(bytes.NewReader(data))
+		if _, ok := uploadFormats[format]; err != nil || !ok {
+			http.Error(w, "Not a JPEG, PNG, or GIF image", http.StatusBadRequest)
+			return
+		}
+		if ic.Width*ic.Height > maxUploadPixels {
+			http.Error(w, "Image has too many pixels", http.StatusBadRequest)
+			return
+		}
+
+		// Phone photos carry where they were taken; the stored original never does
+		fileName := uploadFileName(header.Filename, format)
+		if data, err = stripImageMetadata(data, fileName); err != nil {
+			http.Error(w, "Could not read the image's metadata", http.StatusBadRequest)
+			return
+		}
+
+		name, err := saveUpload(dir, fileName, data)
+		if err != nil {
+			slog.Error("Error saving image", "file", header.Filename, "err", err)
+			http.Error(w, "Could not save image", http.StatusInternalServerError)
+			return
+		}
+		slog.Info("Image uploaded", "image", name, "width", ic.Width, "height", ic.Height)
+		if queue != nil && isResizableImage(name) {
+			if err := queue.Enqueue("image-variants", ImageVariantsJobPayload{Name: name}); err != nil {
+				// The copies are still made when first asked for
+				slog.Error("Error queueing image variants", "image", name, "err", err)
+			}
+		}
+
+		if strings.Contains(r.Header.Get("Accept"), "application/json") {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]string{"name": name, "url": "/images/" + name, "markdown": imageSnippet(name)})
+			return
+		}
+		http.Redirect(w, r, "/admin/images?uploaded="+name, http.StatusSeeOther)
+	}
+}
+
+// recentImages returns the newest images in dir, newest first
+func recentImages(dir string, limit int) ([]string, error) {
+	type file struct {
+		name string
+		info fs.FileInfo
+	}
+	var files []file
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if !isResizableImage(path) && !strings.EqualFold(filepath.Ext(path), ".gif") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, file{filepath.ToSlash(rel), info})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if !files[i].info.ModTime().Equal(files[j].info.ModTime()) {
+			return files[i].info.ModTime().After(files[j].info.ModTime())
+		}
+		return files[i].name < files[j].name
+	})
+	var names []string
+	for _, f := range files[:min(limit, len(files))] {
+		names = append(names, f.name)
+	}
+	return names, nil
+}
+
+// AdminImagesHandler shows the upload form and the newest images in dir, each
+// with the markdown to put it in a post
+func AdminImagesHandler(dir string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		names, err := recentImages(dir, adminImagesListed)
+		if err != nil {
+			slog.Error("Error listing images", "dir", dir, "err", err)
+			http.Error(w, "Could not list images", http.StatusInternalServerError)
+			return
+		}
+
+		var content bytes.Buffer
+		content.WriteString("<div class=\"admin-page\">\n")
+		content.WriteString(adminNav())
+		content.WriteString("<h1>Images</h1>\n")
+		if uploaded := r.URL.Query().Get("uploaded"); uploaded != "" {
+			content.WriteString("<p class=\"form-success\">Uploaded <code>" + template.HTMLEscapeString(uploaded) + "</code>. Add it to a post with <code>" + template.HTMLEscapeString(imageSnippet(uploaded)) + "</code></p>\n")
+		}
+		content.WriteString("<form method=\"post\" action=\"/admin/images\" enctype=\"multipart/form-data\" class=\"admin-form\">\n")
+		content.WriteString("\t<label>Image <input type=\"file\" name=\"image\" accept=\"image/jpeg,image/png,image/gif\" required></label>\n")
+		content.WriteString("\t<p class=\"editor-hint\">JPEG, PNG, or GIF up to " + strconv.Itoa(cfg.MaxImageUploadMB) + " MB. Resized copies are made straight away.</p>\n")
+		content.WriteString("\t<button type=\"submit\" class=\"modal-btn\">Upload</button>\n")
+		content.WriteString("</form>\n")
+
+		if len(names) > 0 {
+			content.WriteString("<h2>Recent images</h2>\n<table class=\"admin-table\">\n<tr><th>Image</th><th>Markdown</th></tr>\n")
+			for _, name := range names {
+				thumb := "/images/" + name
+				if isResizableImage(name) {
+					thumb += "?w=160"
+				}
+				content.WriteString("<tr><td><img src=\"" + template.HTMLEscapeString(thumb) + "\" alt=\"" + template.HTMLEscapeString(name) + "\" width=\"160\" loading=\"lazy\"></td>")
+				content.WriteString("<td><code>" + template.HTMLEscapeString(imageSnippet(name)) + "</code></td></tr>\n")
+			}
+			content.WriteString("</table>\n")
+		}
+		content.WriteString("</div>")
+
+		renderPage(w, "Images", template.HTML(content.String()))
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/jpeg"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// uploadRequest builds a multipart upload of data as the image field
+func uploadRequest(t *testing.T, filename string, data []byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("image", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(data)
+	mw.Close()
+	r := httptest.NewRequest("POST", "/admin/images", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+func testJPEG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h)), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestUploadFileName(t *testing.T) {
+	tests := []struct {
+		filename, format, want string
+	}{
+		{"IMG_2041.JPG", "jpeg", "img_2041.jpg"},
+		{"My Holiday (1).jpeg", "jpeg", "my-holiday-1.jpg"},
+		{"screenshot.png", "png", "screenshot.png"},
+		{"disguised.png", "jpeg", "disguised.jpg"},
+		{"../../etc/passwd.gif", "gif", "passwd.gif"},
+		{"ภาพถ่าย.jpg", "jpeg", "image.jpg"},
+	}
+	for _, tt := range tests {
+		if got := uploadFileName(tt.filename, tt.format); got != tt.want {
+			t.Errorf("uploadFileName(%q, %q) = %q, want %q", tt.filename, tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestAdminImageUploadHandler(t *testing.T) {
+	dir := t.TempDir()
+	q := newTestQueue(t)
+	handler := AdminImageUploadHandler(dir, q)
+	photo := testJPEG(t, 400, 300)
+
+	w := httptest.NewRecorder()
+	handler(w, uploadRequest(t, "Beach Day.JPG", photo))
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/admin/images?uploaded=beach-day.jpg" {
+		t.Fatalf("status %d, Location %q", w.Code, w.Header().Get("Location"))
+	}
+	saved, err := os.ReadFile(filepath.Join(dir, "beach-day.jpg"))
+	if err != nil || !bytes.Equal(saved, photo) {
+		t.Fatalf("original not stored as uploaded: %v", err)
+	}
+
+	// Location data is stripped before the photo is stored
+	tagged := append(append(append([]byte{}, photo[:2]...), exifWithGPS(1)...), photo[2:]...)
+	w = httptest.NewRecorder()
+	handler(w, uploadRequest(t, "located.jpg", tagged))
+	saved, err = os.ReadFile(filepath.Join(dir, "located.jpg"))
+	if err != nil || bytes.Contains(saved, []byte("GPS")) {
+		t.Errorf("uploaded photo kept its location: %v", err)
+	}
+
+	// A second upload with the same name doesn't replace the first
+	r := uploadRequest(t, "beach-day.jpg", photo)
+	r.Header.Set("Accept", "application/json")
+	w = httptest.NewRecorder()
+	handler(w, r)
+	var got map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("response is not JSON: %v: %s", err, w.Body)
+	}
+	if got["name"] != "beach-day-1.jpg" || got["markdown"] != "![Description](/images/beach-day-1.jpg?w=800)" {
+		t.Errorf("response = %v", got)
+	}
+
+	// The queued jobs make the resized copies
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	q.Register("image-variants", ImageVariantsJob(dir, cacheDir))
+	for {
+		ran, err := q.RunOnce(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ran {
+			break
+		}
+	}
+	for _, name := range []string{"beach-day.jpg", "beach-day-1.jpg", "located.jpg"} {
+		if _, err := os.Stat(filepath.Join(cacheDir, "800", name+".webp")); err != nil {
+			t.Errorf("variants of %s not made: %v", name, err)
+		}
+	}
+}
+
+func TestAdminImageUploadHandler_Rejects(t *testing.T) {
+	defer func(old int) { cfg.MaxImageUploadMB = old }(cfg.MaxImageUploadMB)
+	cfg.MaxImageUploadMB = 1
+	dir := t.TempDir()
+	handler := AdminImageUploadHandler(dir, nil)
+
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"not an image", []byte("<?php echo 1; ?>"), http.StatusBadRequest},
+		{"too large", append(testJPEG(t, 10, 10), make([]byte, 2<<20)...), http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		handler(w, uploadRequest(t, "x.jpg", tt.data))
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("POST", "/admin/images", strings.NewReader("")))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("no file: status %d, want 400", w.Code)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("rejected uploads left %d files", len(entries))
+	}
+}
+
+func TestAdminImagesHandler(t *testing.T) {
+	dir := t.TempDir()
+	writeTestPNG(t, filepath.Join(dir, "diagram.png"), 10, 10)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0644)
+
+	r := httptest.NewRequest("GET", "/admin/images?uploaded=diagram.png", nil)
+	w := httptest.NewRecorder()
+	AdminImagesHandler(dir)(w, r)
+	body := w.Body.String()
+	for _, want := range []string{
+		`enctype="multipart/form-data"`,
+		`<p class="form-success">Uploaded <code>diagram.png</code>`,
+		`<img src="/images/diagram.png?w=160" alt="diagram.png"`,
+		`![Description](/images/diagram.png?w=800)`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page missing %s", want)
+		}
+	}
+	if strings.Contains(body, "notes.txt") {
+		t.Error("page lists a file that isn't an image")
+	}
+}
@@ -0,0 +1,476 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PostFiles is the post storage the admin editor works with
+type PostFiles interface {
+	SlugReader
+	SlugWriter
+	SlugLister
+}
+
+// adminPost is a row in the admin post list
+type adminPost struct {
+	Slug  string
+	Title string
+	Date  time.Time
+	// Scheduled posts have a date in the future and are hidden from readers until then
+	Scheduled bool
+	// Draft posts are hidden until draft: true is removed
+	Draft bool
+	// Private posts are only ever read through preview links
+	Private bool
+}
+
+// adminPostList reads the frontmatter of every post, newest first
+func adminPostList(posts PostFiles) ([]adminPost, error) {
+	slugs, err := posts.List()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var list []adminPost
+	for _, slug := range slugs {
+		md, err := posts.Read(slug)
+		if err != nil {
+			slog.Error("Error reading post", "slug", slug, "err", err)
+			continue
+		}
+		fm, _ := ParseFrontmatter(md)
+		p := adminPost{Slug: slug, Title: fm.Title, Scheduled: isScheduled(fm, now), Draft: fm.Draft, Private: fm.Private}
+		if p.Title == "" {
+			p.Title = slug
+		}
+		p.Date, _ = parsePostDate(fm.Date)
+		list = append(list, p)
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Date.After(list[j].Date) })
+	return list, nil
+}
+
+// AdminPostsHandler lists all posts, with the scheduled ones and when they go live on top
+func AdminPostsHandler(posts PostFiles) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list, err := adminPostList(posts)
+		if err != nil {
+			slog.Error("Error listing posts", "err", err)
+			http.Error(w, "Could not list posts", http.StatusInternalServerError)
+			return
+		}
+
+		var scheduled []adminPost
+		for _, p := range list {
+			if p.Scheduled {
+				scheduled = append(scheduled, p)
+			}
+		}
+		// Soonest first
+		sort.Slice(scheduled, func(i, j int) bool { return scheduled[i].Date.Before(scheduled[j].Date) })
+
+		loc := cfg.Location()
+		var content bytes.Buffer
+		content.WriteString("<div class=\"admin-page\">\n")
+		content.WriteString(adminNav())
+		content.WriteString("<h1>Posts</h1>\n")
+		content.WriteString("<p class=\"admin-actions\"><a href=\"/admin/posts/new\">New post</a>")
+		for _, name := range postTemplateNames() {
+			content.WriteString(" <a href=\"/admin/posts/new?template=" + template.HTMLEscapeString(name) + "\">New " + template.HTMLEscapeString(name) + "</a>")
+		}
+		content.WriteString("</p>\n")
+
+		if len(scheduled) > 0 {
+			content.WriteString("<h2>Scheduled</h2>\n<table class=\"admin-table\">\n<tr><th>Post</th><th>Goes live</th><th></th></tr>\n")
+			for _, p := range scheduled {
+				content.WriteString("<tr><td>" + template.HTMLEscapeString(p.Title) + "</td>")
+				content.WriteString("<td>" + p.Date.In(loc).Format("Mon Jan 2, 2006 15:04 MST") + " (in " + formatUptime(time.Until(p.Date)) + ")</td>")
+				content.WriteString("<td><a href=\"/admin/posts/" + template.HTMLEscapeString(p.Slug) + "/edit\">Edit</a></td></tr>\n")
+			}
+			content.WriteString("</table>\n")
+			content.WriteString("<h2>All Posts</h2>\n")
+		}
+
+		content.WriteString("<table class=\"admin-table\">\n<tr><th>Post</th><th>Date</th><th>Status</th><th></th></tr>\n")
+		for _, p := range list {
+			date, status := "", "Published"
+			if !p.Date.IsZero() {
+				date = p.Date.In(loc).Format("Jan 2, 2006")
+			}
+			switch {
+			case p.Private:
+				status = "Private"
+			case p.Draft:
+				status = "Draft"
+			case p.Scheduled:
+				status = "Scheduled"
+			}
+			content.WriteString("<tr><td>" + template.HTMLEscapeString(p.Title) + "<br><code>" + template.HTMLEscapeString(p.Slug) + "</code></td>")
+			content.WriteString("<td>" + date + "</td><td>" + status + "</td>")
+			content.WriteString("<td class=\"admin-actions\"><a href=\"/admin/posts/" + template.HTMLEscapeString(p.Slug) + "/edit\">Edit</a>")
+			content.WriteString("<form method=\"post\" action=\"/admin/posts/" + template.HTMLEscapeString(p.Slug) + "/duplicate\"><button type=\"submit\">Duplicate</button></form>")
+			content.WriteString(deletePostForm(p.Slug) + "</td></tr>\n")
+		}
+		content.WriteString("</table>\n</div>")
+
+		renderPage(w, "Posts", template.HTML(content.String()))
+	}
+}
+
+// AdminPostEditHandler shows the editor for an existing post, or an empty one at
+// /admin/posts/new. An autosaved draft newer than the post is offered for restoring.
+func AdminPostEditHandler(posts PostFiles, drafts *DraftStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		e := postEditor{Slug: r.PathValue("slug")}
+		if e.Slug == "" && r.URL.Query().Get("template") != "" {
+			var err error
+			if e.Source, err = postTemplate(r.URL.Query().Get("template")); err != nil {
+				http.Error(w, "Template not found", http.StatusNotFound)
+				return
+			}
+		}
+		if e.Slug != "" {
+			if !IsValidSlug(e.Slug) {
+				http.Error(w, "Invalid post slug", http.StatusBadRequest)
+				return
+			}
+			var err error
+			if e.Source, err = posts.Read(e.Slug); err != nil {
+				http.Error(w, "Post not found", http.StatusNotFound)
+				return
+			}
+			e.BaseHash = contentHash(e.Source)
+		}
+
+		draft, err := drafts.Get(e.Slug)
+		if err != nil && err != sql.ErrNoRows {
+			slog.Error("Error loading draft", "slug", e.Slug, "err", err)
+		}
+		if err == nil && draft.Content != e.Source {
+			e.Draft = &draft
+		}
+
+		title := "New Post"
+		if e.Slug != "" {
+			title = "Edit " + e.Slug
+		}
+		renderPage(w, title, template.HTML(e.HTML()))
+	}
+}
+
+// contentHash identifies a version of a post for edit-conflict detection
+func contentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// postEditor is the state of the markdown editor page
+type postEditor struct {
+	// Slug is empty for a new post; NewSlug keeps what was typed for one
+	Slug    string
+	NewSlug string
+	Source  string
+	Error   string
+	// BaseHash is the hash of the post as it was when the editor was opened
+	BaseHash string
+	// Draft is an autosaved version that differs from Source
+	Draft *PostDraft
+	// Current is the version on disk when saving hit an edit conflict
+	Current string
+}
+
+// HTML renders the editor with its scheduling controls and autosave script
+func (e postEditor) HTML() string {
+	fm, _ := ParseFrontmatter(e.Source)
+	loc := cfg.Location()
+
+	// datetime-local wants the wall-clock time in the site timezone
+	publishAt := ""
+	if t, ok := parsePostDate(fm.Date); ok {
+		publishAt = t.In(loc).Format("2006-01-02T15:04")
+	}
+
+	action, autosave := "/admin/posts", "/admin/drafts"
+	if e.Slug != "" {
+		action, autosave = "/admin/posts/"+e.Slug, "/admin/drafts/"+e.Slug
+	}
+
+	var b strings.Builder
+	b.WriteString("<div class=\"admin-page\">\n")
+	b.WriteString(adminNav())
+	if e.Slug == "" {
+		b.WriteString("<h1>New Post</h1>\n")
+	} else {
+		b.WriteString("<h1>Edit <code>" + template.HTMLEscapeString(e.Slug) + "</code></h1>\n")
+	}
+	if e.Error != "" {
+		b.WriteString("<p class=\"form-error\">" + template.HTMLEscapeString(e.Error) + "</p>\n")
+	}
+	if e.Current != "" {
+		b.WriteString("<details class=\"editor-conflict\" open><summary>Current version on the server</summary>\n")
+		b.WriteString("<textarea rows=\"12\" readonly>" + template.HTMLEscapeString(e.Current) + "</textarea></details>\n")
+	}
+	if e.Draft != nil {
+		b.WriteString("<div class=\"editor-draft\">You have an autosaved draft from " + e.Draft.UpdatedAt.In(loc).Format("Jan 2, 2006 15:04") + ". ")
+		b.WriteString("<button type=\"button\" id=\"restore-draft\">Restore draft</button>")
+		b.WriteString("<textarea id=\"draft-content\" hidden>" + template.HTMLEscapeString(e.Draft.Content) + "</textarea></div>\n")
+	}
+
+	b.WriteString("<form method=\"post\" action=\"" + template.HTMLEscapeString(action) + "\" class=\"admin-form editor-form\" data-autosave=\"" + template.HTMLEscapeString(autosave) + "\">\n")
+	b.WriteString("\t<input type=\"hidden\" name=\"base_hash\" value=\"" + e.BaseHash + "\">\n")
+	if e.Slug == "" {
+		b.WriteString("\t<label>Slug <input type=\"text\" name=\"slug\" value=\"" + template.HTMLEscapeString(e.NewSlug) + "\" pattern=\"[a-zA-Z0-9_\\-]+\" placeholder=\"en-my-new-post\" required></label>\n")
+	}
+	b.WriteString("\t<label>Publish at (" + template.HTMLEscapeString(loc.String()) + ") <input type=\"datetime-local\" name=\"publish_at\" value=\"" + publishAt + "\"></label>\n")
+	b.WriteString("\t<p class=\"editor-hint\">A time in the future schedules the post; it stays hidden until then.</p>\n")
+	b.WriteString("\t<label>Markdown <textarea name=\"content\" rows=\"25\" spellcheck=\"true\">" + template.HTMLEscapeString(e.Source) + "</textarea></label>\n")
+	b.WriteString("\t<div class=\"editor-actions\"><button type=\"submit\" class=\"modal-btn\">Save</button> <button type=\"button\" id=\"preview-post\">Preview</button> <span class=\"editor-status\" aria-live=\"polite\"></span></div>\n")
+	b.WriteString("</form>\n")
+	b.WriteString("<section class=\"editor-preview\" aria-label=\"Preview\" hidden></section>\n")
+	if e.Slug != "" {
+		b.WriteString(deletePostForm(e.Slug))
+	}
+	b.WriteString("</div>\n")
+	b.WriteString(editorScript)
+	return b.String()
+}
+
+// deletePostForm is the button that deletes a post, after asking
+func deletePostForm(slug string) string {
+	return "<form method=\"post\" action=\"/admin/posts/" + template.HTMLEscapeString(slug) + "/delete\" class=\"delete-post\" onsubmit=\"return confirm('Delete this post? Its file is removed.')\"><button type=\"submit\">Delete</button></form>"
+}
+
+// editorScript autosaves the editor every few seconds while it has unsaved changes,
+// restores an offered draft, and shows the rendered markdown on Preview
+const editorScript = `<script>
+(function () {
+    const form = document.querySelector('.editor-form');
+    const content = form.querySelector('textarea[name="content"]');
+    const status = form.querySelector('.editor-status');
+    const preview = document.querySelector('.editor-preview');
+    let saved = content.value;
+
+    document.getElementById('preview-post').addEventListener('click', () => {
+        fetch('/admin/preview', { method: 'POST', body: new URLSearchParams({ content: content.value }) })
+            .then(resp => {
+                if (!resp.ok) throw new Error(resp.statusText);
+                return resp.text();
+            })
+            .then(html => {
+                preview.innerHTML = html;
+                preview.hidden = false;
+                preview.scrollIntoView({ behavior: 'smooth' });
+            })
+            .catch(() => { status.textContent = 'Preview failed'; });
+    });
+
+    const restore = document.getElementById('restore-draft');
+    if (restore) {
+        restore.addEventListener('click', () => {
+            content.value = document.getElementById('draft-content').value;
+            restore.closest('.editor-draft').remove();
+        });
+    }
+
+    setInterval(() => {
+        if (content.value === saved) return;
+        const value = content.value;
+        fetch(form.dataset.autosave, { method: 'POST', body: new URLSearchParams({ content: value }) })
+            .then(resp => {
+                if (!resp.ok) throw new Error(resp.statusText);
+                saved = value;
+                status.textContent = 'Draft saved at ' + new Date().toLocaleTimeString();
+            })
+            .catch(() => { status.textContent = 'Autosave failed'; });
+    }, 10000);
+})();
+</script>`
+
+// AdminPostSaveHandler writes a post from the editor. The publish time is written
+// to the frontmatter date in the site timezone. If the post changed on disk since
+// the editor was opened (another tab, a git pull) nothing is written and both
+// versions are shown; saving again overwrites deliberately.
+func AdminPostSaveHandler(posts PostFiles, drafts *DraftStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		e := postEditor{
+			Slug:     r.PathValue("slug"),
+			Source:   strings.ReplaceAll(r.PostFormValue("content"), "\r\n", "\n"),
+			BaseHash: r.PostFormValue("base_hash"),
+		}
+		slug := e.Slug
+		if slug == "" {
+			e.NewSlug = strings.TrimSpace(r.PostFormValue("slug"))
+			slug = e.NewSlug
+		}
+
+		fail := func(status int, msg string) {
+			e.Error = msg
+			w.WriteHeader(status)
+			renderPage(w, "Edit Post", template.HTML(e.HTML()))
+		}
+
+		if !IsValidSlug(slug) {
+			fail(http.StatusBadRequest, "Slugs may only contain letters, digits, hyphens, and underscores.")
+			return
+		}
+		current, err := posts.Read(slug)
+		if e.Slug == "" && err == nil {
+			fail(http.StatusBadRequest, "A post with this slug already exists.")
+			return
+		}
+		if e.Slug != "" && err != nil {
+			http.Error(w, "Post not found", http.StatusNotFound)
+			return
+		}
+		if postTooLarge(int64(len(e.Source))) {
+			fail(http.StatusRequestEntityTooLarge, "The post is over the "+strconv.Itoa(cfg.MaxPostKB)+" KB limit (max_post_kb).")
+			return
+		}
+		if e.Slug != "" && contentHash(current) != e.BaseHash {
+			e.Current = current
+			e.BaseHash = contentHash(current)
+			fail(http.StatusConflict, "This post was changed somewhere else since you opened it. Compare with the current version below; saving again will overwrite it.")
+			return
+		}
+
+		if v := r.PostFormValue("publish_at"); v != "" {
+			t, err := time.ParseInLocation("2006-01-02T15:04", v, cfg.Location())
+			if err != nil {
+				fail(http.StatusBadRequest, "Invalid publish time.")
+				return
+			}
+			e.Source = setFrontmatterField(e.Source, "date", t.Format(time.RFC3339))
+		}
+
+		if err := posts.Write(slug, e.Source); err != nil {
+			slog.Error("Error saving post", "slug", slug, "err", err)
+			http.Error(w, "Could not save post", http.StatusInternalServerError)
+			return
+		}
+		if err := drafts.Delete(e.Slug); err != nil {
+			slog.Error("Error clearing draft", "slug", e.Slug, "err", err)
+		}
+
+		http.Redirect(w, r, "/admin/posts", http.StatusSeeOther)
+	}
+}
+
+// AdminPostDeleteHandler removes a post's file and any autosaved draft of it
+func AdminPostDeleteHandler(posts PostFiles, drafts *DraftStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slug := r.PathValue("slug")
+		if !IsValidSlug(slug) {
+			http.Error(w, "Invalid post slug", http.StatusBadRequest)
+			return
+		}
+		if _, err := posts.Read(slug); err != nil {
+			http.Error(w, "Post not found", http.StatusNotFound)
+			return
+		}
+		if err := posts.Delete(slug); err != nil {
+			slog.Error("Error deleting post", "slug", slug, "err", err)
+			http.Error(w, "Could not delete post", http.StatusInternalServerError)
+			return
+		}
+		if err := drafts.Delete(slug); err != nil {
+			slog.Error("Error clearing draft", "slug", slug, "err", err)
+		}
+		slog.Info("Deleted post", "slug", slug)
+		http.Redirect(w, r, "/admin/posts", http.StatusSeeOther)
+	}
+}
+
+// AdminPreviewHandler renders the editor's markdown as the post page would show
+// it, title included, without saving anything
+func AdminPreviewHandler(w http.ResponseWriter, r *http.Request) {
+	fm, body := ParseFrontmatter(strings.ReplaceAll(r.PostFormValue("content"), "\r\n", "\n"))
+	html, err := renderMarkdown(body, "preview")
+	if err != nil {
+		http.Error(w, "Could not render preview: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if fm.Title != "" {
+		w.Write([]byte("<h1>" + template.HTMLEscapeString(fm.Title) + "</h1>\n"))
+	}
+	w.Write([]byte(html))
+}
+
+// postTemplatesDir holds the markdown starting points offered by "New from template"
+const postTemplatesDir = "templates/posts"
+
+// postTemplateNames lists the available post templates
+func postTemplateNames() []string {
+	files, _ := filepath.Glob(filepath.Join(postTemplatesDir, "*.md"))
+	var names []string
+	for _, f := range files {
+		names = append(names, strings.TrimSuffix(filepath.Base(f), ".md"))
+	}
+	return names
+}
+
+// postTemplate returns the named template, dated today in the site timezone
+func postTemplate(name string) (string, error) {
+	if !IsValidSlug(name) {
+		return "", fs.ErrNotExist
+	}
+	b, err := os.ReadFile(filepath.Join(postTemplatesDir, name+".md"))
+	if err != nil {
+		return "", err
+	}
+	return setFrontmatterField(string(b), "date", time.Now().In(cfg.Location()).Format("2006-01-02")), nil
+}
+
+// AdminPostDuplicateHandler copies a post to a new, unused slug and opens it in the
+// editor. The copy is dated today so it does not inherit the original's schedule.
+func AdminPostDuplicateHandler(posts PostFiles) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slug := r.PathValue("slug")
+		if !IsValidSlug(slug) {
+			http.Error(w, "Invalid post slug", http.StatusBadRequest)
+			return
+		}
+		source, err := posts.Read(slug)
+		if err != nil {
+			http.Error(w, "Post not found", http.StatusNotFound)
+			return
+		}
+
+		copySlug := slug + "-copy"
+		for i := 2; ; i++ {
+			if _, err := posts.Read(copySlug); err != nil {
+				break
+			}
+			copySlug = slug + "-copy-" + strconv.Itoa(i)
+		}
+
+		fm, _ := ParseFrontmatter(source)
+		title := fm.Title
+		if title == "" {
+			title = slug
+		}
+		source = setFrontmatterField(source, "title", strconv.Quote("Copy of "+title))
+		source = setFrontmatterField(source, "date", time.Now().In(cfg.Location()).Format("2006-01-02"))
+
+		if err := posts.Write(copySlug, source); err != nil {
+			slog.Error("Error duplicating post", "slug", slug, "err", err)
+			http.Error(w, "Could not duplicate post", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/admin/posts/"+copySlug+"/edit", http.StatusSeeOther)
+	}
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func (m *MockSlugReader) Write(slug, content string) error {
+	m.content[slug] = content
+	return nil
+}
+
+func (m *MockSlugReader) Delete(slug string) error {
+	if _, ok := m.content[slug]; !ok {
+		return fs.ErrNotExist
+	}
+	delete(m.content, slug)
+	return nil
+}
+
+func (m *MockSlugReader) List() ([]string, error) {
+	var slugs []string
+	for slug := range m.content {
+		slugs = append(slugs, slug)
+	}
+	sort.Strings(slugs)
+	return slugs, nil
+}
+
+func newTestDraftStore(t *testing.T) *DraftStore {
+	t.Helper()
+	drafts, err := NewDraftStore(newTestDB(t))
+	if err != nil {
+		t.Fatalf("NewDraftStore: %v", err)
+	}
+	return drafts
+}
+
+func TestAdminPostSaveHandler_Schedules(t *testing.T) {
+	posts := &MockSlugReader{content: map[string]string{"existing": "---\ntitle: Existing\n---\n\nBody"}}
+	handler := AdminPostSaveHandler(posts, newTestDraftStore(t))
+
+	tests := []struct {
+		name       string
+		slug       string
+		form       url.Values
+		wantStatus int
+		wantDate   string
+	}{
+		{
+			name:       "schedule existing post in site timezone",
+			slug:       "existing",
+			form:       url.Values{"content": {"---\ntitle: Existing\n---\n\nBody"}, "publish_at": {"2099-03-01T09:00"}},
+			wantStatus: http.StatusSeeOther,
+			wantDate:   "2099-03-01T09:00:00+07:00",
+		},
+		{
+			name:       "create",
+			form:       url.Values{"slug": {"fresh"}, "content": {"Hello"}},
+			wantStatus: http.StatusSeeOther,
+		},
+		{
+			name:       "duplicate slug",
+			form:       url.Values{"slug": {"existing"}, "content": {"Hello"}},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid slug",
+			form:       url.Values{"slug": {"../x"}, "content": {"Hello"}},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "bad publish time",
+			slug:       "existing",
+			form:       url.Values{"content": {"Body"}, "publish_at": {"tomorrow"}},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "over max_post_kb",
+			form:       url.Values{"slug": {"huge"}, "content": {strings.Repeat("x", (cfg.MaxPostKB+1)<<10)}},
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.slug != "" {
+				tt.form.Set("base_hash", contentHash(posts.content[tt.slug]))
+			}
+			req := postForm("/admin/posts/"+tt.slug, tt.form)
+			req.SetPathValue("slug", tt.slug)
+			w := httptest.NewRecorder()
+			handler(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if tt.wantDate != "" {
+				fm, _ := ParseFrontmatter(posts.content[tt.slug])
+				if fm.Date != tt.wantDate {
+					t.Errorf("expected date %q, got %q", tt.wantDate, fm.Date)
+				}
+			}
+		})
+	}
+
+	if _, ok := posts.content["fresh"]; !ok {
+		t.Error("expected the new post to be written")
+	}
+}
+
+func TestAdminPostsHandler_ListsScheduled(t *testing.T) {
+	soon := time.Now().Add(2 * time.Hour).Format(time.RFC3339)
+	posts := &MockSlugReader{content: map[string]string{
+		"live":  "---\ntitle: Live Post\ndate: 2026-01-01\n---\n\nBody",
+		"later": "---\ntitle: Later Post\ndate: " + soon + "\n---\n\nBody",
+	}}
+
+	w := httptest.NewRecorder()
+	AdminPostsHandler(posts)(w, httptest.NewRequest("GET", "/admin/posts", nil))
+	body := w.Body.String()
+
+	scheduled, all, ok := strings.Cut(body, "<h2>All Posts</h2>")
+	if !ok {
+		t.Fatalf("expected a scheduled section, got %s", body)
+	}
+	if !strings.Contains(scheduled, "Later Post") || strings.Contains(scheduled, "Live Post") {
+		t.Errorf("expected only the future post under Scheduled, got %s", scheduled)
+	}
+	if !strings.Contains(scheduled, "(in 1h 59m)") && !strings.Contains(scheduled, "(in 2h 0m)") {
+		t.Errorf("expected time until publishing, got %s", scheduled)
+	}
+	if !strings.Contains(all, "Live Post") {
+		t.Errorf("expected all posts listed, got %s", all)
+	}
+
+	// Readers get a 404 until the date passes
+	req := httptest.NewRequest("GET", "/posts/later", nil)
+	req.SetPathValue("slug", "later")
+	w = httptest.NewRecorder()
+	PostHandler(posts, nil, nil)(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected scheduled post to 404, got %d", w.Code)
+	}
+}
+
+func TestAdminPostDuplicateHandler(t *testing.T) {
+	posts := &MockSlugReader{content: map[string]string{
+		"hello":      "---\ntitle: Hello\ndate: 2099-01-01\ntags: [go]\n---\n\nBody",
+		"hello-copy": "taken",
+	}}
+
+	req := httptest.NewRequest("POST", "/admin/posts/hello/duplicate", nil)
+	req.SetPathValue("slug", "hello")
+	w := httptest.NewRecorder()
+	AdminPostDuplicateHandler(posts)(w, req)
+
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/admin/posts/hello-copy-2/edit" {
+		t.Fatalf("expected redirect to the copy's editor, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	fm, body := ParseFrontmatter(posts.content["hello-copy-2"])
+	if fm.Title != "Copy of Hello" || len(fm.Tags) != 1 || body != "Body" {
+		t.Errorf("unexpected copy %+v %q", fm, body)
+	}
+	if isScheduled(fm, time.Now()) {
+		t.Errorf("expected the copy not to inherit the schedule, got date %q", fm.Date)
+	}
+}
+
+func TestAdminPostEditHandler_Template(t *testing.T) {
+	posts := &MockSlugReader{content: map[string]string{}}
+
+	tests := []struct {
+		template   string
+		wantStatus int
+		want       string
+	}{
+		{"til", http.StatusOK, "TIL: "},
+		{"tutorial", http.StatusOK, "## Prerequisites"},
+		{"link", http.StatusOK, "tags: [links]"},
+		{"../base", http.StatusNotFound, ""},
+		{"missing", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			AdminPostEditHandler(posts, newTestDraftStore(t))(w, httptest.NewRequest("GET", "/admin/posts/new?template="+url.QueryEscape(tt.template), nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if tt.want != "" && !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("expected %q in editor, got %s", tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAdminPostSaveHandler_Conflict(t *testing.T) {
+	posts := &MockSlugReader{content: map[string]string{"hello": "Original"}}
+	drafts := newTestDraftStore(t)
+	opened := contentHash("Original")
+
+	// Someone else saves in the meantime
+	posts.content["hello"] = "Changed elsewhere"
+
+	save := func(baseHash string) *httptest.ResponseRecorder {
+		req := postForm("/admin/posts/hello", url.Values{"content": {"My edit"}, "base_hash": {baseHash}})
+		req.SetPathValue("slug", "hello")
+		w := httptest.NewRecorder()
+		AdminPostSaveHandler(posts, drafts)(w, req)
+		return w
+	}
+
+	w := save(opened)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected status 409, got %d", w.Code)
+	}
+	if posts.content["hello"] != "Changed elsewhere" {
+		t.Fatalf("conflicting save must not overwrite, got %q", posts.content["hello"])
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "Changed elsewhere") || !strings.Contains(body, "My edit") {
+		t.Errorf("expected both versions in the editor, got %s", body)
+	}
+	if !strings.Contains(body, `name="base_hash" value="`+contentHash("Changed elsewhere")+`"`) {
+		t.Errorf("expected the editor to carry the current version's hash, got %s", body)
+	}
+
+	// Saving again from the conflict page overwrites deliberately and clears the draft
+	drafts.Save("hello", "My edit")
+	if w := save(contentHash("Changed elsewhere")); w.Code != http.StatusSeeOther {
+		t.Fatalf("expected status 303, got %d", w.Code)
+	}
+	if posts.content["hello"] != "My edit" {
+		t.Errorf("expected the edit to be saved, got %q", posts.content["hello"])
+	}
+	if _, err := drafts.Get("hello"); err == nil {
+		t.Error("expected the draft to be deleted after saving")
+	}
+}
+
+func TestAdminDraftSaveHandler_OfferedInEditor(t *testing.T) {
+	posts := &MockSlugReader{content: map[string]string{"hello": "Saved text"}}
+	drafts := newTestDraftStore(t)
+
+	req := postForm("/admin/drafts/hello", url.Values{"content": {"Unsaved <draft>"}})
+	req.SetPathValue("slug", "hello")
+	w := httptest.NewRecorder()
+	AdminDraftSaveHandler(drafts)(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/admin/posts/hello/edit", nil)
+	req.SetPathValue("slug", "hello")
+	w = httptest.NewRecorder()
+	AdminPostEditHandler(posts, drafts)(w, req)
+	body := w.Body.String()
+	if !strings.Contains(body, `id="restore-draft"`) || !strings.Contains(body, "Unsaved &lt;draft&gt;") {
+		t.Errorf("expected the draft to be offered, got %s", body)
+	}
+
+	// A draft identical to the saved post is not offered
+	drafts.Save("hello", "Saved text")
+	w = httptest.NewRecorder()
+	AdminPostEditHandler(posts, drafts)(w, req)
+	if strings.Contains(w.Body.String(), `id="restore-draft"`) {
+		t.Error("did not expect a draft identical to the post to be offered")
+	}
+}
+
+func TestAdminPostDeleteHandler(t *testing.T) {
+	posts := &MockSlugReader{content: map[string]string{"hello": "---\ntitle: Hello\n---\n\nBody"}}
+	drafts := newTestDraftStore(t)
+	drafts.Save("hello", "unsaved edits")
+	handler := AdminPostDeleteHandler(posts, drafts)
+
+	tests := []struct {
+		slug     string
+		wantCode int
+	}{
+		{"hello", http.StatusSeeOther},
+		{"hello", http.StatusNotFound},
+		{"../etc", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/admin/posts/x/delete", nil)
+		req.SetPathValue("slug", tt.slug)
+		w := httptest.NewRecorder()
+		handler(w, req)
+		if w.Code != tt.wantCode {
+			t.Errorf("delete %s: status %d, want %d", tt.slug, w.Code, tt.wantCode)
+		}
+	}
+	if _, ok := posts.content["hello"]; ok {
+		t.Error("post not deleted")
+	}
+	if _, err := drafts.Get("hello"); err == nil {
+		t.Error("draft of the deleted post kept")
+	}
+}
+
+func TestAdminPreviewHandler(t *testing.T) {
+	form := url.Values{"content": {"---\ntitle: Draft <Title>\n---\n\nSome **bold** text"}}
+	req := httptest.NewRequest("POST", "/admin/preview", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	AdminPreviewHandler(w, req)
+
+	body := w.Body.String()
+	if !strings.Contains(body, "<h1>Draft &lt;Title&gt;</h1>") || !strings.Contains(body, "<strong>bold</strong>") {
+		t.Errorf("preview = %s", body)
+	}
+	if strings.Contains(body, "<html") {
+		t.Error("preview should be a fragment, not a page")
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestAdminAuth_RequireRedirectsAnonymous(t *testing.T) {
+	auth := NewAdminAuth("admin", "secret", "test-secret")
+	handler := auth.Require(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest("GET", "/admin", nil)
+	w := httptest.NewRecorder()
+	handler(w, req)
+
+	if w.Code != http.StatusSeeOther {
+		t.Errorf("expected status 303, got %d", w.Code)
+	}
+	if loc := w.Header().Get("Location"); loc != "/admin/login" {
+		t.Errorf("expected redirect to /admin/login, got %q", loc)
+	}
+}
+
+func TestAdminAuth_DisabledWithoutCredentials(t *testing.T) {
+	auth := NewAdminAuth("", "", "")
+	handler := auth.Require(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest("GET", "/admin", nil)
+	w := httptest.NewRecorder()
+	handler(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 when admin is disabled, got %d", w.Code)
+	}
+}
+
+func TestAdminAuth_LoginStartsSession(t *testing.T) {
+	auth := NewAdminAuth("admin", "secret", "test-secret")
+
+	tests := []struct {
+		password   string
+		wantStatus int
+	}{
+		{"wrong", http.StatusOK},
+		{"secret", http.StatusSeeOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			form := url.Values{"username": {"admin"}, "password": {tt.password}}
+			req := httptest.NewRequest("POST", "/admin/login", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+
+			auth.LoginHandler(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if tt.wantStatus != http.StatusSeeOther {
+				return
+			}
+
+			// The issued cookie must authenticate follow-up requests
+			follow := httptest.NewRequest("GET", "/admin", nil)
+			for _, c := range w.Result().Cookies() {
+				follow.AddCookie(c)
+			}
+			if !auth.IsAdmin(follow) {
+				t.Error("expected session cookie to be accepted")
+			}
+		})
+	}
+}
+
+// loginCookie logs in through the login handler and returns the session cookie
+func loginCookie(t *testing.T, auth *AdminAuth) *http.Cookie {
+	t.Helper()
+	w := httptest.NewRecorder()
+	auth.LoginHandler(w, postForm("/admin/login", url.Values{"username": {auth.User}, "password": {auth.Password}}))
+	for _, c := range w.Result().Cookies() {
+		if c.Name == adminCookieName {
+			return c
+		}
+	}
+	t.Fatal("login did not set a session cookie")
+	return nil
+}
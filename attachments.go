@@ -0,0 +1,168 @@
+package main
+
+import (
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// attachmentsDir holds the files posts offer for download
+const attachmentsDir = "attachments"
+
+// attachmentTypes are the content types of common downloads, which the system
+// MIME tables don't always know
+var attachmentTypes = map[string]string{
+	".pdf":  "application/pdf",
+	".zip":  "application/zip",
+	".gz":   "application/gzip",
+	".tgz":  "application/gzip",
+	".tar":  "application/x-tar",
+	".epub": "application/epub+zip",
+	".csv":  "text/csv; charset=utf-8",
+	".txt":  "text/plain; charset=utf-8",
+	".md":   "text/markdown; charset=utf-8",
+}
+
+// attachmentType returns the content type to serve a download with
+func attachmentType(name string) string {
+	ext := strings.ToLower(path.Ext(name))
+	if t, ok := attachmentTypes[ext]; ok {
+		return t
+	}
+	if t := mime.TypeByExtension(ext); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// PostAttachment is a download a post offers. In frontmatter it is either a
+// file name in attachments/ or a map with a file and a title:
+//
+//	attachments:
+//	  - slides.pdf
+//	  - file: go-intro/code.zip
+//	    title: Example code
+type PostAttachment struct {
+	File  string `yaml:"file"`
+	Title string `yaml:"title"`
+}
+
+// UnmarshalYAML accepts a plain file name as well as a map
+func (a *PostAttachment) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*a = PostAttachment{File: node.Value}
+		return nil
+	}
+	type plain PostAttachment
+	return node.Decode((*plain)(a))
+}
+
+// attachmentFile is an attachment found on disk
+type attachmentFile struct {
+	Title string
+	// URL is the root-relative address of the file
+	URL  string
+	Type string
+	Size int64
+}
+
+// validAttachmentPath reports whether name is a file path inside the attachments
+// directory
+func validAttachmentPath(name string) bool {
+	return name != "" && fs.ValidPath(name) && !strings.Contains(name, "\\")
+}
+
+// postAttachments returns a post's attachments that exist in dir. Missing files
+// are left out rather than linked.
+func postAttachments(list []PostAttachment, dir string) []attachmentFile {
+	var files []attachmentFile
+	for _, a := range list {
+		if !validAttachmentPath(a.File) {
+			slog.Debug("Invalid attachment path", "file", a.File)
+			continue
+		}
+		info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(a.File)))
+		if err != nil || info.IsDir() {
+			slog.Debug("Attachment not found", "file", a.File)
+			continue
+		}
+		f := attachmentFile{Title: a.Title, URL: "/attachments/" + a.File, Type: attachmentType(a.File), Size: info.Size()}
+		if f.Title == "" {
+			f.Title = path.Base(a.File)
+		}
+		files = append(files, f)
+	}
+	return files
+}
+
+// formatSize writes a byte count the way file managers do, e.g. 1.2 MB
+func formatSize(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return strconv.FormatInt(n, 10) + " B"
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return strconv.FormatFloat(float64(n)/float64(div), 'f', 1, 64) + " " + string("kMGT"[exp]) + "B"
+}
+
+// attachmentsHTML renders the download box below a post, or "" when it has none
+func attachmentsHTML(files []attachmentFile) string {
+	if len(files) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("<aside class=\"post-attachments\">\n<h2>Downloads</h2>\n<ul>\n")
+	for _, f := range files {
+		ext := strings.ToUpper(strings.TrimPrefix(path.Ext(f.URL), "."))
+		b.WriteString("<li><a href=\"" + template.HTMLEscapeString(f.URL) + "\" download>" + template.HTMLEscapeString(f.Title) + "</a>")
+		b.WriteString(" <span class=\"attachment-meta\">")
+		if ext != "" {
+			b.WriteString(template.HTMLEscapeString(ext) + " · ")
+		}
+		b.WriteString(formatSize(f.Size) + "</span></li>\n")
+	}
+	b.WriteString("</ul>\n</aside>\n")
+	return b.String()
+}
+
+// AttachmentHandler serves files from dir at /attachments/{path...} as
+// downloads, with a content type from their extension
+func AttachmentHandler(dir string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("path")
+		if !validAttachmentPath(name) {
+			http.Error(w, "Invalid attachment path", http.StatusBadRequest)
+			return
+		}
+		f, err := os.Open(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil || info.IsDir() {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", attachmentType(name))
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": path.Base(name)}))
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+		http.ServeContent(w, r, name, info.ModTime(), f)
+	}
+}
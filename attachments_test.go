@@ -0,0 +1,129 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAttachmentType(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"slides.pdf", "application/pdf"},
+		{"code/EXAMPLE.ZIP", "application/zip"},
+		{"data.csv", "text/csv; charset=utf-8"},
+		{"archive.tgz", "application/gzip"},
+		{"binary.xyz123", "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		if got := attachmentType(tt.name); got != tt.want {
+			t.Errorf("attachmentType(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "0 B"},
+		{999, "999 B"},
+		{1500, "1.5 kB"},
+		{1234567, "1.2 MB"},
+		{3000000000, "3.0 GB"},
+	}
+	for _, tt := range tests {
+		if got := formatSize(tt.n); got != tt.want {
+			t.Errorf("formatSize(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestPostAttachments(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "slides.pdf"), []byte("%PDF-1.4"), 0644)
+	os.Mkdir(filepath.Join(dir, "go-intro"), 0755)
+	os.WriteFile(filepath.Join(dir, "go-intro", "code.zip"), []byte("PK"), 0644)
+
+	fm, _ := ParseFrontmatter("---\nattachments:\n  - slides.pdf\n  - file: go-intro/code.zip\n    title: Example code\n  - missing.pdf\n  - ../secret.txt\n  - go-intro\n---\nBody")
+	got := postAttachments(fm.Attachments, dir)
+	want := []attachmentFile{
+		{Title: "slides.pdf", URL: "/attachments/slides.pdf", Type: "application/pdf", Size: 8},
+		{Title: "Example code", URL: "/attachments/go-intro/code.zip", Type: "application/zip", Size: 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("postAttachments = %+v, want %+v", got, want)
+	}
+
+	html := attachmentsHTML(got)
+	for _, s := range []string{`<a href="/attachments/slides.pdf" download>slides.pdf</a>`, "PDF · 8 B", "Example code", "ZIP · 2 B"} {
+		if !strings.Contains(html, s) {
+			t.Errorf("attachmentsHTML missing %q: %s", s, html)
+		}
+	}
+	if attachmentsHTML(nil) != "" {
+		t.Error("download box rendered without attachments")
+	}
+}
+
+func TestAttachmentHandler(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "slides.pdf"), []byte("%PDF-1.4"), 0644)
+	os.Mkdir(filepath.Join(dir, "code"), 0755)
+	h := AttachmentHandler(dir)
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"slides.pdf", http.StatusOK},
+		{"missing.pdf", http.StatusNotFound},
+		{"code", http.StatusNotFound},
+		{"../slides.pdf", http.StatusBadRequest},
+		{"code\\..\\slides.pdf", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/attachments/x", nil)
+		r.SetPathValue("path", tt.path)
+		w := httptest.NewRecorder()
+		h(w, r)
+		if w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.path, w.Code, tt.status)
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/pdf" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		if cd := w.Header().Get("Content-Disposition"); cd != "attachment; filename=slides.pdf" {
+			t.Errorf("Content-Disposition = %q", cd)
+		}
+		if w.Body.String() != "%PDF-1.4" {
+			t.Errorf("body = %q", w.Body.String())
+		}
+	}
+}
+
+func TestPostHandler_Attachments(t *testing.T) {
+	t.Chdir(t.TempDir())
+	os.Mkdir(attachmentsDir, 0755)
+	os.WriteFile(filepath.Join(attachmentsDir, "slides.pdf"), []byte("%PDF-1.4"), 0644)
+	posts := &MockSlugReader{content: map[string]string{
+		"talk": "---\ntitle: My Talk\nattachments: [slides.pdf]\n---\n\nNotes.",
+	}}
+
+	r := httptest.NewRequest("GET", "/posts/talk", nil)
+	r.SetPathValue("slug", "talk")
+	w := httptest.NewRecorder()
+	PostHandler(posts, nil, nil)(w, r)
+	if body := w.Body.String(); !strings.Contains(body, `class="post-attachments"`) || !strings.Contains(body, `href="/attachments/slides.pdf"`) {
+		t.Errorf("download box missing: %s", body)
+	}
+}
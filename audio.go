@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// audioAuto in the audio frontmatter field requests a generated narration
+const audioAuto = "auto"
+
+// TTSJobPayload is the payload of a "tts" job
+type TTSJobPayload struct {
+	Slug string `json:"slug"`
+}
+
+// audioSrc resolves the audio frontmatter field to a URL for the player. Bare file
+// names refer to the audio/ directory; "auto" refers to the generated narration
+// and resolves to "" until it exists.
+func audioSrc(audio, slug, audioDir string) string {
+	switch {
+	case audio == "":
+		return ""
+	case audio == audioAuto:
+		if _, err := os.Stat(filepath.Join(audioDir, slug+".mp3")); err != nil {
+			return ""
+		}
+		return "/audio/" + slug + ".mp3"
+	case strings.Contains(audio, "://"), strings.HasPrefix(audio, "/"):
+		return audio
+	default:
+		return "/audio/" + audio
+	}
+}
+
+// audioPlayerHTML renders the inline player for a post's narration
+func audioPlayerHTML(src string) string {
+	return "<div class=\"post-audio\"><audio controls preload=\"none\" src=\"" + template.HTMLEscapeString(src) + "\"></audio></div>\n"
+}
+
+// publishedAudio returns the files in audioDir that published posts play:
+// narrations of posts with audio: auto and files named in their audio field.
+// Other files, like the narrations of posts since made private, stay unserved.
+func publishedAudio(posts *PostIndex) map[string]bool {
+	files := make(map[string]bool)
+	for _, post := range posts.Posts() {
+		p, ok := posts.Get(post.Slug)
+		if !ok {
+			continue
+		}
+		switch audio := p.FM.Audio; {
+		case audio == audioAuto:
+			files[post.Slug+".mp3"] = true
+		case audio != "" && !strings.Contains(audio, "://") && !strings.HasPrefix(audio, "/"):
+			files[audio] = true
+		}
+	}
+	return files
+}
+
+// postAudioFile describes a post's audio for a feed enclosure, with its size
+// when it's a file in audioDir. ok is false for posts without audio.
+func postAudioFile(audio, slug, audioDir string) (f attachmentFile, ok bool) {
+	src := audioSrc(audio, slug, audioDir)
+	if src == "" {
+		return f, false
+	}
+	f = attachmentFile{URL: src, Type: "audio/mpeg"}
+	if name, local := strings.CutPrefix(src, "/audio/"); local {
+		if info, err := os.Stat(filepath.Join(audioDir, name)); err == nil {
+			f.Size = info.Size()
+		}
+	}
+	return f, true
+}
+
+// AudioHandler serves the audio files of published posts from audioDir, one
+// file at a time; the directory itself isn't listed
+func AudioHandler(posts *PostIndex, audioDir string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("file")
+		if strings.HasPrefix(name, ".") || !publishedAudio(posts)[name] {
+			http.NotFound(w, r)
+			return
+		}
+		f, err := os.Open(filepath.Join(audioDir, name))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil || info.IsDir() {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, name, info.ModTime(), f)
+	}
+}
+
+// queueMissingNarrations enqueues a TTS job for every published post with
+// audio: auto whose narration has not been generated yet. Drafts, scheduled
+// and private posts aren't sent out to be narrated.
+func queueMissingNarrations(queue *JobQueue, posts *PostIndex, audioDir string) error {
+	for _, post := range posts.Posts() {
+		if p, ok := posts.Get(post.Slug); !ok || p.FM.Audio != audioAuto {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(audioDir, post.Slug+".mp3")); err == nil {
+			continue
+		}
+		if err := queue.EnqueueUnique("tts", TTSJobPayload{Slug: post.Slug}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunNarrationScanner queues the narrations of newly published posts, checking
+// every interval whether the posts have changed, until the context is cancelled
+func RunNarrationScanner(ctx context.Context, interval time.Duration, queue *JobQueue, posts *PostIndex, audioDir string) {
+	var seen uint64
+	for {
+		// Scheduled posts count as a change once they're published
+		if v := posts.Version(); v != seen {
+			if err := queueMissingNarrations(queue, posts, audioDir); err != nil {
+				slog.Error("Error queueing narrations", "err", err)
+			} else {
+				seen = v
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// TTSJob returns a JobHandler that narrates a post through an OpenAI-compatible
+// /audio/speech endpoint and writes audio/<slug>.mp3
+func TTSJob(tts TTSConfig, apiKey string, reader SlugReader, audioDir string, client *http.Client) JobHandler {
+	return func(ctx context.Context, payload []byte) error {
+		if tts.Endpoint == "" {
+			return errors.New("text-to-speech is not configured (set tts.endpoint)")
+		}
+
+		var p TTSJobPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return fmt.Errorf("decode tts job: %w", err)
+		}
+		if !IsValidSlug(p.Slug) {
+			return fmt.Errorf("invalid slug %q", p.Slug)
+		}
+
+		src, err := reader.Read(p.Slug)
+		if err != nil {
+			return fmt.Errorf("read post %s: %w", p.Slug, err)
+		}
+		fm, body := ParseFrontmatter(src)
+		if !postVisible(fm) {
+			// Unpublished since it was queued; it's queued again once published
+			slog.Info("Not narrating unpublished post", "slug", p.Slug)
+			return nil
+		}
+		rendered, err := renderMarkdown(body, p.Slug)
+		if err != nil {
+			return fmt.Errorf("render post %s: %w", p.Slug, err)
+		}
+		text := strings.TrimSpace(fm.Title + ".\n\n" + plainText(rendered))
+
+		// Speech APIs cap the input length; MP3 frames can simply be concatenated
+		var audio bytes.Buffer
+		for _, chunk := range splitText(text, 4000) {
+			if err := synthesizeSpeech(ctx, client, tts, apiKey, chunk, &audio); err != nil {
+				return err
+			}
+		}
+
+		if err := os.MkdirAll(audioDir, 0755); err != nil {
+			return err
+		}
+		tmp := filepath.Join(audioDir, "."+p.Slug+".mp3.tmp")
+		if err := os.WriteFile(tmp, audio.Bytes(), 0644); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, filepath.Join(audioDir, p.Slug+".mp3")); err != nil {
+			return err
+		}
+		slog.Info("Generated narration", "slug", p.Slug, "bytes", audio.Len())
+		return nil
+	}
+}
+
+// synthesizeSpeech sends one chunk of text to the speech endpoint and appends the MP3 to out
+func synthesizeSpeech(ctx context.Context, client *http.Client, tts TTSConfig, apiKey, text string, out *bytes.Buffer) error {
+	body, _ := json.Marshal(map[string]string{
+		"model":           tts.Model,
+		"voice":           tts.Voice,
+		"input":           text,
+		"response_format": "mp3",
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tts.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build tts request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("tts request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("tts request: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	_, err = io.Copy(out, resp.Body)
+	return err
+}
+
+// splitText breaks text into chunks of at most limit bytes, preferring paragraph
+// and sentence boundaries
+func splitText(text string, limit int) []string {
+	var chunks []string
+	for len(text) > limit {
+		cut := strings.LastIndex(text[:limit], "\n\n")
+		if cut <= 0 {
+			cut = strings.LastIndexAny(text[:limit], ".!?\n")
+		}
+		if cut <= 0 {
+			cut = strings.LastIndex(text[:limit], " ")
+		}
+		if cut <= 0 {
+			// Never split inside a UTF-8 sequence
+			cut = limit
+			for cut > 0 && text[cut]&0xC0 == 0x80 {
+				cut--
+			}
+		} else {
+			cut++
+		}
+		chunks = append(chunks, strings.TrimSpace(text[:cut]))
+		text = strings.TrimSpace(text[cut:])
+	}
+	if text != "" {
+		chunks = append(chunks, text)
+	}
+	return chunks
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAudioSrc(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "narrated.mp3"), []byte("mp3"), 0644)
+
+	tests := []struct {
+		audio, slug, want string
+	}{
+		{"", "post", ""},
+		{"episode-1.mp3", "post", "/audio/episode-1.mp3"},
+		{"/media/episode.mp3", "post", "/media/episode.mp3"},
+		{"https://cdn.example.com/a.mp3", "post", "https://cdn.example.com/a.mp3"},
+		{"auto", "narrated", "/audio/narrated.mp3"},
+		{"auto", "not-generated-yet", ""},
+	}
+	for _, tt := range tests {
+		if got := audioSrc(tt.audio, tt.slug, dir); got != tt.want {
+			t.Errorf("audioSrc(%q, %q) = %q, want %q", tt.audio, tt.slug, got, tt.want)
+		}
+	}
+}
+
+func TestPostHandler_AudioPlayer(t *testing.T) {
+	mockReader := &MockSlugReader{content: map[string]string{
+		"podcast": "---\ntitle: Episode\naudio: https://cdn.example.com/ep1.mp3\n---\n\nShow notes.",
+	}}
+
+	req := httptest.NewRequest("GET", "/posts/podcast", nil)
+	req.SetPathValue("slug", "podcast")
+	w := httptest.NewRecorder()
+	PostHandler(mockReader, nil, nil)(w, req)
+
+	if !strings.Contains(w.Body.String(), `<audio controls preload="none" src="https://cdn.example.com/ep1.mp3">`) {
+		t.Error("expected inline audio player")
+	}
+}
+
+func TestTTSJob(t *testing.T) {
+	var inputs []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer key" {
+			t.Errorf("missing API key")
+		}
+		var req map[string]string
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &req)
+		inputs = append(inputs, req["input"])
+		w.Write([]byte("ID3"))
+	}))
+	defer server.Close()
+
+	reader := &MockSlugReader{content: map[string]string{
+		"talk": "---\ntitle: My Talk\naudio: auto\n---\n\nHello **there**.\n\n```go\nfmt.Println()\n```\n",
+	}}
+	audioDir := t.TempDir()
+	job := TTSJob(TTSConfig{Endpoint: server.URL, Model: "tts-1", Voice: "alloy"}, "key", reader, audioDir, server.Client())
+
+	payload, _ := json.Marshal(TTSJobPayload{Slug: "talk"})
+	if err := job(context.Background(), payload); err != nil {
+		t.Fatalf("TTSJob: %v", err)
+	}
+
+	if len(inputs) != 1 || inputs[0] != "My Talk.\n\nHello there." {
+		t.Errorf("unexpected narration input %q", inputs)
+	}
+	data, err := os.ReadFile(filepath.Join(audioDir, "talk.mp3"))
+	if err != nil || string(data) != "ID3" {
+		t.Errorf("expected narration file to be written, got %q, %v", data, err)
+	}
+}
+
+func TestTTSJob_Unpublished(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("unpublished post sent to the speech endpoint")
+	}))
+	defer server.Close()
+
+	reader := &MockSlugReader{content: map[string]string{
+		"secret": "---\ntitle: Secret\naudio: auto\nprivate: true\n---\n\nNot for anyone.",
+	}}
+	audioDir := t.TempDir()
+	job := TTSJob(TTSConfig{Endpoint: server.URL}, "", reader, audioDir, server.Client())
+	payload, _ := json.Marshal(TTSJobPayload{Slug: "secret"})
+	if err := job(context.Background(), payload); err != nil {
+		t.Fatalf("TTSJob: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(audioDir, "secret.mp3")); err == nil {
+		t.Error("narration written for a private post")
+	}
+}
+
+func TestAudioHandler(t *testing.T) {
+	postsDir, audioDir := t.TempDir(), t.TempDir()
+	os.WriteFile(filepath.Join(postsDir, "talk.md"), []byte("---\naudio: auto\n---\nT"), 0644)
+	os.WriteFile(filepath.Join(postsDir, "episode.md"), []byte("---\naudio: ep1.mp3\n---\nE"), 0644)
+	os.WriteFile(filepath.Join(postsDir, "secret.md"), []byte("---\naudio: auto\nprivate: true\n---\nS"), 0644)
+	for _, name := range []string{"talk.mp3", "ep1.mp3", "secret.mp3", "stray.mp3"} {
+		os.WriteFile(filepath.Join(audioDir, name), []byte("ID3"), 0644)
+	}
+	posts := newTestPostIndex(t, postsDir)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /audio/{file}", AudioHandler(posts, audioDir))
+	for path, want := range map[string]int{
+		"/audio/talk.mp3":   http.StatusOK,
+		"/audio/ep1.mp3":    http.StatusOK,
+		"/audio/secret.mp3": http.StatusNotFound,
+		"/audio/stray.mp3":  http.StatusNotFound,
+		"/audio/":           http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != want {
+			t.Errorf("%s: status %d, want %d", path, w.Code, want)
+		}
+	}
+}
+
+func TestRunNarrationScanner(t *testing.T) {
+	postsDir, audioDir := t.TempDir(), t.TempDir()
+	posts := newTestPostIndex(t, postsDir)
+	q := newTestQueue(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		RunNarrationScanner(ctx, 10*time.Millisecond, q, posts, audioDir)
+		close(done)
+	}()
+
+	// A post published after startup is narrated without a restart
+	if err := posts.Write("new", "---\ntitle: New\naudio: auto\n---\nN"); err != nil {
+		t.Fatal(err)
+	}
+	var slugs []string
+	q.Register("tts", func(ctx context.Context, payload []byte) error {
+		var p TTSJobPayload
+		json.Unmarshal(payload, &p)
+		slugs = append(slugs, p.Slug)
+		return nil
+	})
+	deadline := time.Now().Add(2 * time.Second)
+	for len(slugs) == 0 && time.Now().Before(deadline) {
+		q.RunOnce(context.Background())
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+	if len(slugs) != 1 || slugs[0] != "new" {
+		t.Errorf("narrations queued = %v", slugs)
+	}
+}
+
+func TestSplitText(t *testing.T) {
+	text := strings.Repeat("สวัสดีครับ ", 50) + "\n\n" + strings.Repeat("Sentence one. ", 20)
+	chunks := splitText(text, 200)
+	if len(chunks) < 2 {
+		t.Fatalf("expected multiple chunks, got %d", len(chunks))
+	}
+	for _, c := range chunks {
+		if len(c) > 200 {
+			t.Errorf("chunk exceeds limit: %d bytes", len(c))
+		}
+		if !strings.HasPrefix(c, "ส") && !strings.HasPrefix(c, "ครับ") && !strings.HasPrefix(c, "Sentence") {
+			t.Errorf("chunk split mid-word: %q", c[:20])
+		}
+	}
+}
+
+func TestQueueMissingNarrations(t *testing.T) {
+	postsDir, audioDir := t.TempDir(), t.TempDir()
+	os.WriteFile(filepath.Join(postsDir, "a.md"), []byte("---\naudio: auto\n---\nA"), 0644)
+	os.WriteFile(filepath.Join(postsDir, "b.md"), []byte("---\naudio: auto\n---\nB"), 0644)
+	os.WriteFile(filepath.Join(postsDir, "c.md"), []byte("---\ntitle: C\n---\nC"), 0644)
+	os.WriteFile(filepath.Join(postsDir, "draft.md"), []byte("---\naudio: auto\ndraft: true\n---\nD"), 0644)
+	os.WriteFile(filepath.Join(postsDir, "secret.md"), []byte("---\naudio: auto\nprivate: true\n---\nS"), 0644)
+	os.WriteFile(filepath.Join(audioDir, "b.mp3"), []byte("done"), 0644)
+
+	q := newTestQueue(t)
+	var slugs []string
+	q.Register("tts", func(ctx context.Context, payload []byte) error {
+		var p TTSJobPayload
+		json.Unmarshal(payload, &p)
+		slugs = append(slugs, p.Slug)
+		return nil
+	})
+
+	// Queueing twice must not duplicate jobs
+	for i := 0; i < 2; i++ {
+		if err := queueMissingNarrations(q, newTestPostIndex(t, postsDir), audioDir); err != nil {
+			t.Fatalf("queueMissingNarrations: %v", err)
+		}
+	}
+	for processed := true; processed; {
+		processed, _ = q.RunOnce(context.Background())
+	}
+
+	if len(slugs) != 1 || slugs[0] != "a" {
+		t.Errorf("expected a single job for post a, got %v", slugs)
+	}
+}
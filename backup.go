@@ -0,0 +1,488 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backupDBEntry is the database's name inside a snapshot
+const backupDBEntry = "blog.db"
+
+// snapshotNameRegex matches the names snapshots are stored under, which sort
+// by the time they were taken
+var snapshotNameRegex = regexp.MustCompile(`^snapshot-\d{8}T\d{6}Z\.tar\.gz$`)
+
+// snapshotName names a snapshot taken at t
+func snapshotName(t time.Time) string {
+	return "snapshot-" + t.UTC().Format("20060102T150405Z") + ".tar.gz"
+}
+
+// snapshotTime returns when the snapshot with this name was taken
+func snapshotTime(name string) (time.Time, bool) {
+	if !snapshotNameRegex.MatchString(name) {
+		return time.Time{}, false
+	}
+	t, err := time.Parse("20060102T150405Z", strings.TrimSuffix(strings.TrimPrefix(name, "snapshot-"), ".tar.gz"))
+	return t, err == nil
+}
+
+// SnapshotStore keeps snapshot files somewhere other than the server's disk
+type SnapshotStore interface {
+	// Put uploads the local file at src as name
+	Put(ctx context.Context, name, src string) error
+	// Get downloads name to the local file at dst
+	Get(ctx context.Context, name, dst string) error
+	// List returns the names of the stored snapshots
+	List(ctx context.Context) ([]string, error)
+	Delete(ctx context.Context, name string) error
+}
+
+// DirSnapshotStore keeps snapshots in a local directory, e.g. a mounted volume
+type DirSnapshotStore struct {
+	Dir string
+}
+
+func (d DirSnapshotStore) Put(ctx context.Context, name, src string) error {
+	if err := os.MkdirAll(d.Dir, 0755); err != nil {
+		return err
+	}
+	// Copy under a temporary name so a half-written snapshot is never listed
+	tmp := filepath.Join(d.Dir, "."+name+".tmp")
+	if err := copyFile(src, tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(d.Dir, name))
+}
+
+func (d DirSnapshotStore) Get(ctx context.Context, name, dst string) error {
+	return copyFile(filepath.Join(d.Dir, name), dst)
+}
+
+func (d DirSnapshotStore) List(ctx context.Context) ([]string, error) {
+	entries, err := os.ReadDir(d.Dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names, nil
+}
+
+func (d DirSnapshotStore) Delete(ctx context.Context, name string) error {
+	return os.Remove(filepath.Join(d.Dir, name))
+}
+
+// copyFile copies the file at src to dst
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// snapshotStoreFromConfig returns the S3 bucket when one is configured, and
+// otherwise the local directory
+func snapshotStoreFromConfig(c BackupConfig) SnapshotStore {
+	if c.S3.Bucket != "" {
+		return &S3SnapshotStore{
+			Endpoint:  c.S3.Endpoint,
+			Region:    c.S3.Region,
+			Bucket:    c.S3.Bucket,
+			Prefix:    c.S3.Prefix,
+			AccessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			Client:    &http.Client{Timeout: 30 * time.Minute},
+		}
+	}
+	return DirSnapshotStore{Dir: c.Dir}
+}
+
+// listSnapshots returns the store's snapshots, oldest first, ignoring anything
+// else kept alongside them
+func listSnapshots(ctx context.Context, store SnapshotStore) ([]string, error) {
+	names, err := store.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list snapshots: %w", err)
+	}
+	var snapshots []string
+	for _, name := range names {
+		if snapshotNameRegex.MatchString(name) {
+			snapshots = append(snapshots, name)
+		}
+	}
+	sort.Strings(snapshots)
+	return snapshots, nil
+}
+
+// writeSnapshot writes a gzipped tar of the directories in paths, which may be
+// missing, and of a consistent copy of db, when there is one
+func writeSnapshot(w io.Writer, db *sql.DB, paths []string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, dir := range paths {
+		err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && !d.Type().IsRegular() {
+				return nil
+			}
+			return addTarFile(tw, p, filepath.ToSlash(p))
+		})
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("archive %s: %w", dir, err)
+		}
+	}
+
+	if db != nil {
+		// VACUUM INTO copies the database in one transaction, unlike copying the
+		// file while the server writes to it
+		tmp, err := os.MkdirTemp("", "blog-backup-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmp)
+		copyPath := filepath.Join(tmp, backupDBEntry)
+		if _, err := db.Exec(`VACUUM INTO ?`, copyPath); err != nil {
+			return fmt.Errorf("copy database: %w", err)
+		}
+		if err := addTarFile(tw, copyPath, backupDBEntry); err != nil {
+			return fmt.Errorf("archive database: %w", err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// addTarFile adds the file or directory at p to tw as name
+func addTarFile(tw *tar.Writer, p, name string) error {
+	info, err := os.Stat(p)
+	if err != nil {
+		return err
+	}
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	if info.IsDir() {
+		hdr.Name += "/"
+		return tw.WriteHeader(hdr)
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// takeSnapshot archives paths and db and uploads the result to store, returning
+// the snapshot's name
+func takeSnapshot(ctx context.Context, store SnapshotStore, db *sql.DB, paths []string, now time.Time) (string, error) {
+	f, err := os.CreateTemp("", "blog-snapshot-*.tar.gz")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	err = writeSnapshot(f, db, paths)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", fmt.Errorf("write snapshot: %w", err)
+	}
+	name := snapshotName(now)
+	if err := store.Put(ctx, name, f.Name()); err != nil {
+		return "", fmt.Errorf("upload snapshot %s: %w", name, err)
+	}
+	return name, nil
+}
+
+// pruneSnapshots deletes all but the newest keep snapshots
+func pruneSnapshots(ctx context.Context, store SnapshotStore, keep int) error {
+	snapshots, err := listSnapshots(ctx, store)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for len(snapshots) > keep {
+		if err := store.Delete(ctx, snapshots[0]); err != nil {
+			errs = append(errs, fmt.Errorf("delete snapshot %s: %w", snapshots[0], err))
+		}
+		snapshots = snapshots[1:]
+	}
+	return errors.Join(errs...)
+}
+
+// nextBackupIn returns how long to wait for the next snapshot, given the
+// newest one there is
+func nextBackupIn(newest string, interval time.Duration, now time.Time) time.Duration {
+	t, ok := snapshotTime(newest)
+	if !ok {
+		return 0
+	}
+	return max(t.Add(interval).Sub(now), 0)
+}
+
+// RunBackups takes a snapshot every interval, keeping the newest keep. The
+// schedule carries over restarts: a server started soon after the last snapshot
+// waits out the rest of the interval.
+func RunBackups(ctx context.Context, store SnapshotStore, db *sql.DB, paths []string, interval time.Duration, keep int) {
+	for {
+		var newest string
+		snapshots, err := listSnapshots(ctx, store)
+		if err != nil {
+			slog.Error("Error listing snapshots", "err", err)
+		} else if len(snapshots) > 0 {
+			newest = snapshots[len(snapshots)-1]
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(nextBackupIn(newest, interval, time.Now())):
+		}
+
+		name, err := takeSnapshot(ctx, store, db, paths, time.Now())
+		if err != nil {
+			slog.Error("Error taking snapshot", "err", err)
+			// Try again after a while rather than straight away
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(min(interval, time.Hour)):
+			}
+			continue
+		}
+		slog.Info("Saved snapshot", "name", name)
+		if err := pruneSnapshots(ctx, store, keep); err != nil {
+			slog.Error("Error pruning snapshots", "err", err)
+		}
+	}
+}
+
+// extractSnapshot unpacks the snapshot file at src into dir, refusing entries
+// that would land outside it
+func extractSnapshot(src, dir string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := path.Clean(hdr.Name)
+		if !fs.ValidPath(name) || name == "." {
+			return fmt.Errorf("snapshot entry %q: not a relative path", hdr.Name)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, hdr.FileInfo().Mode().Perm())
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(out, tr); err != nil {
+				out.Close()
+				return err
+			}
+			if err := out.Close(); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("snapshot entry %q: unsupported type", hdr.Name)
+		}
+	}
+}
+
+// restoreSnapshot replaces the content directories and the database at dbPath
+// with those in snapshot, a name in store or a local file. The current state is
+// saved as a snapshot first, so a restore can itself be undone. The server must
+// not be running.
+func restoreSnapshot(ctx context.Context, store SnapshotStore, snapshot, dbPath string, paths []string) error {
+	src := snapshot
+	if _, err := os.Stat(snapshot); err != nil {
+		if !snapshotNameRegex.MatchString(snapshot) {
+			return fmt.Errorf("%s: no such file and not a snapshot name", snapshot)
+		}
+		f, err := os.CreateTemp("", "blog-restore-*.tar.gz")
+		if err != nil {
+			return err
+		}
+		f.Close()
+		defer os.Remove(f.Name())
+		if err := store.Get(ctx, snapshot, f.Name()); err != nil {
+			return fmt.Errorf("download snapshot %s: %w", snapshot, err)
+		}
+		src = f.Name()
+	}
+
+	// Unpack next to the content so the directories can be moved into place
+	tmp, err := os.MkdirTemp(".", ".restore-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	if err := extractSnapshot(src, tmp); err != nil {
+		return fmt.Errorf("extract %s: %w", snapshot, err)
+	}
+
+	db, err := openDB(dbPath)
+	if err != nil {
+		return err
+	}
+	saved, err := takeSnapshot(ctx, store, db, paths, time.Now())
+	db.Close()
+	if err != nil {
+		return fmt.Errorf("save current state: %w", err)
+	}
+	slog.Info("Saved the current state", "name", saved)
+
+	// Only the configured paths are replaced, so a nested path like
+	// static/uploads leaves the rest of static/ alone, and a path the snapshot
+	// lacks was empty when it was taken
+	for _, dir := range restorePaths(paths) {
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("replace %s: %w", dir, err)
+		}
+		from := filepath.Join(tmp, dir)
+		if _, err := os.Stat(from); errors.Is(err, fs.ErrNotExist) {
+			slog.Info("Cleared, not in the snapshot", "path", dir)
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(from, dir); err != nil {
+			return fmt.Errorf("replace %s: %w", dir, err)
+		}
+		slog.Info("Restored", "path", dir)
+	}
+
+	from := filepath.Join(tmp, backupDBEntry)
+	if _, err := os.Stat(from); err != nil {
+		return nil
+	}
+	for _, suffix := range []string{"-wal", "-shm"} {
+		os.Remove(dbPath + suffix)
+	}
+	if err := os.Rename(from, dbPath); err != nil {
+		return fmt.Errorf("replace %s: %w", dbPath, err)
+	}
+	slog.Info("Restored", "path", dbPath)
+	return nil
+}
+
+// restorePaths cleans the configured paths and drops those inside another,
+// which is restored with everything under it
+func restorePaths(paths []string) []string {
+	var dirs []string
+	for _, p := range paths {
+		dirs = append(dirs, filepath.Clean(p))
+	}
+	var out []string
+	for _, d := range dirs {
+		nested := false
+		for _, other := range dirs {
+			if other != d && strings.HasPrefix(d, other+string(filepath.Separator)) {
+				nested = true
+			}
+		}
+		if !nested && !slices.Contains(out, d) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// runBackupCommand handles "backup" and "restore <snapshot>" on the command
+// line. Restore without a snapshot lists the ones there are.
+func runBackupCommand(args []string) error {
+	ctx := context.Background()
+	store := snapshotStoreFromConfig(cfg.Backup)
+	switch {
+	case args[0] == "backup" && len(args) == 1:
+		db, err := openDB(databasePath())
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		name, err := takeSnapshot(ctx, store, db, cfg.Backup.Paths, time.Now())
+		if err != nil {
+			return err
+		}
+		fmt.Println(name)
+		return pruneSnapshots(ctx, store, cfg.Backup.Keep)
+	case args[0] == "restore" && len(args) == 2:
+		return restoreSnapshot(ctx, store, args[1], databasePath(), cfg.Backup.Paths)
+	case args[0] == "restore" && len(args) == 1:
+		snapshots, err := listSnapshots(ctx, store)
+		if err != nil {
+			return err
+		}
+		for _, name := range snapshots {
+			fmt.Println(name)
+		}
+		return errors.New("usage: blog-web restore <snapshot>")
+	}
+	return fmt.Errorf("usage: blog-web backup | blog-web restore <snapshot>")
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	t.Chdir(t.TempDir())
+	os.MkdirAll("posts/th", 0755)
+	os.WriteFile("posts/hello.md", []byte("# Hello"), 0644)
+	os.WriteFile("posts/th/hello.md", []byte("# สวัสดี"), 0644)
+	db, err := openDB("blog.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`CREATE TABLE t (v TEXT); INSERT INTO t VALUES ('before')`); err != nil {
+		t.Fatal(err)
+	}
+
+	store := DirSnapshotStore{Dir: "backups"}
+	ctx := context.Background()
+	name, err := takeSnapshot(ctx, store, db, []string{"posts", "missing"}, time.Date(2026, 10, 16, 3, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "snapshot-20261016T030000Z.tar.gz" {
+		t.Errorf("name = %q", name)
+	}
+
+	// Change everything, then put the snapshot back
+	os.WriteFile("posts/hello.md", []byte("# Edited"), 0644)
+	os.WriteFile("posts/new.md", []byte("# New"), 0644)
+	db.Exec(`UPDATE t SET v = 'after'`)
+	db.Close()
+
+	if err := restoreSnapshot(ctx, store, name, "blog.db", []string{"posts"}); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile("posts/hello.md"); string(b) != "# Hello" {
+		t.Errorf("hello.md = %q", b)
+	}
+	if b, _ := os.ReadFile("posts/th/hello.md"); string(b) != "# สวัสดี" {
+		t.Errorf("th/hello.md = %q", b)
+	}
+	if _, err := os.Stat("posts/new.md"); !os.IsNotExist(err) {
+		t.Errorf("new.md survived the restore: %v", err)
+	}
+	db, err = openDB("blog.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var v string
+	if err := db.QueryRow(`SELECT v FROM t`).Scan(&v); err != nil || v != "before" {
+		t.Errorf("database value = %q, %v", v, err)
+	}
+
+	// The state before the restore was saved
+	snapshots, err := listSnapshots(ctx, store)
+	if err != nil || len(snapshots) != 2 {
+		t.Fatalf("snapshots = %v, %v", snapshots, err)
+	}
+	if matches, _ := filepath.Glob(".restore-*"); len(matches) != 0 {
+		t.Errorf("left behind %v", matches)
+	}
+}
+
+func TestRestoreSnapshot_OnlyConfiguredPaths(t *testing.T) {
+	t.Chdir(t.TempDir())
+	os.MkdirAll("static/uploads", 0755)
+	os.WriteFile("static/uploads/a.png", []byte("a"), 0644)
+	os.WriteFile("static/style.css", []byte("body{}"), 0644)
+
+	store := DirSnapshotStore{Dir: "backups"}
+	ctx := context.Background()
+	paths := []string{"static/uploads", "drafts"}
+	name, err := takeSnapshot(ctx, store, nil, paths, time.Date(2026, 10, 16, 3, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.WriteFile("static/uploads/b.png", []byte("b"), 0644)
+	os.WriteFile("static/style.css", []byte("body{color:red}"), 0644)
+	os.MkdirAll("drafts", 0755)
+	os.WriteFile("drafts/wip.md", []byte("# WIP"), 0644)
+
+	if err := restoreSnapshot(ctx, store, name, "blog.db", paths); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile("static/uploads/a.png"); string(b) != "a" {
+		t.Errorf("a.png = %q", b)
+	}
+	if _, err := os.Stat("static/uploads/b.png"); !os.IsNotExist(err) {
+		t.Errorf("b.png survived the restore: %v", err)
+	}
+	// Outside the configured paths nothing is touched
+	if b, _ := os.ReadFile("static/style.css"); string(b) != "body{color:red}" {
+		t.Errorf("style.css = %q", b)
+	}
+	// A configured path missing from the snapshot is cleared
+	if _, err := os.Stat("drafts"); !os.IsNotExist(err) {
+		t.Errorf("drafts survived the restore: %v", err)
+	}
+}
+
+func TestPruneSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{
+		"snapshot-20261013T030000Z.tar.gz",
+		"snapshot-20261014T030000Z.tar.gz",
+		"snapshot-20261015T030000Z.tar.gz",
+		"snapshot-20261016T030000Z.tar.gz",
+		"notes.txt",
+	}
+	for _, n := range names {
+		os.WriteFile(filepath.Join(dir, n), nil, 0644)
+	}
+	store := DirSnapshotStore{Dir: dir}
+	if err := pruneSnapshots(context.Background(), store, 2); err != nil {
+		t.Fatal(err)
+	}
+	left, _ := store.List(context.Background())
+	want := []string{"notes.txt", "snapshot-20261015T030000Z.tar.gz", "snapshot-20261016T030000Z.tar.gz"}
+	if !reflect.DeepEqual(left, want) {
+		t.Errorf("left %v, want %v", left, want)
+	}
+}
+
+func TestNextBackupIn(t *testing.T) {
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		newest string
+		want   time.Duration
+	}{
+		{"", 0},
+		{"snapshot-20261016T030000Z.tar.gz", 18 * time.Hour},
+		{"snapshot-20261014T030000Z.tar.gz", 0},
+	}
+	for _, tt := range tests {
+		if got := nextBackupIn(tt.newest, 24*time.Hour, now); got != tt.want {
+			t.Errorf("nextBackupIn(%q) = %v, want %v", tt.newest, got, tt.want)
+		}
+	}
+}
+
+func TestExtractSnapshotRejectsEscapes(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "evil.tar.gz")
+	f, _ := os.Create(src)
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	tw.WriteHeader(&tar.Header{Name: "../outside.md", Mode: 0644, Size: 1, Typeflag: tar.TypeReg})
+	tw.Write([]byte("x"))
+	tw.Close()
+	gz.Close()
+	f.Close()
+
+	err := extractSnapshot(src, filepath.Join(dir, "out"))
+	if err == nil || !strings.Contains(err.Error(), "not a relative path") {
+		t.Errorf("err = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "outside.md")); !os.IsNotExist(err) {
+		t.Error("entry written outside the target")
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeBenchPosts creates n posts of a few paragraphs each in ./posts
+func writeBenchPosts(b *testing.B, n int) {
+	b.Helper()
+	b.Chdir(b.TempDir())
+	if err := os.MkdirAll("posts", 0755); err != nil {
+		b.Fatal(err)
+	}
+	for i := range n {
+		body := fmt.Sprintf("---\ntitle: Post number %d\ndate: 2025-%02d-%02d\ntags: [go, bench]\n---\n\n", i, i%12+1, i%28+1)
+		for p := range 10 {
+			body += fmt.Sprintf("Paragraph %d with **bold**, `code`, and a [link](https://example.com/%d).\n\n", p, p)
+		}
+		body += "```go\nfunc main() {}\n```\n"
+		if err := os.WriteFile(filepath.Join("posts", fmt.Sprintf("post-%03d.md", i)), []byte(body), 0644); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkHomeHandler(b *testing.B) {
+	writeBenchPosts(b, 300)
+	posts, err := NewPostIndex("posts")
+	if err != nil {
+		b.Fatal(err)
+	}
+	h := HomeHandler(posts, nil)
+	req := httptest.NewRequest(http.MethodGet, "/?lang=en", nil)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		w := httptest.NewRecorder()
+		h(w, req)
+		if w.Code != http.StatusOK {
+			b.Fatalf("status %d", w.Code)
+		}
+	}
+}
+
+func BenchmarkHomeHandlerUncached(b *testing.B) {
+	writeBenchPosts(b, 300)
+	req := httptest.NewRequest(http.MethodGet, "/?lang=en", nil)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		// A new index reads every post again
+		posts, err := NewPostIndex("posts")
+		if err != nil {
+			b.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		HomeHandler(posts, nil)(w, req)
+	}
+}
+
+func BenchmarkPostHandler(b *testing.B) {
+	writeBenchPosts(b, 1)
+	h := PostHandler(&FileReader{}, nil, nil)
+	req := httptest.NewRequest(http.MethodGet, "/posts/post-000", nil)
+	req.SetPathValue("slug", "post-000")
+
+	b.ReportAllocs()
+	for b.Loop() {
+		w := httptest.NewRecorder()
+		h(w, req)
+		if w.Code != http.StatusOK {
+			b.Fatalf("status %d", w.Code)
+		}
+	}
+}
+
+func BenchmarkRenderMarkdown(b *testing.B) {
+	src := ""
+	for p := range 50 {
+		src += fmt.Sprintf("## Section %d\n\nSome *text* with a [link](/posts/%d).\n\n", p, p)
+	}
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := renderMarkdown(src, "bench"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// changesContext is how many unchanged paragraphs are kept around each change
+const changesContext = 1
+
+// maxDiffCells caps the table diffSeqs builds, the product of the lengths of
+// the sequences left once their common start and end are set aside. Longer
+// differences are shown as removed and added whole.
+const maxDiffCells = 1 << 20
+
+// maxWordDiffTokens is the most words and spaces a paragraph may have to be
+// diffed word by word; longer paragraphs are shown as replaced whole
+const maxWordDiffTokens = 2000
+
+// errNoRevision is returned for posts with no earlier version on record
+var errNoRevision = errors.New("no earlier revision")
+
+// diffTokenRegex splits text into words and the spaces between them
+var diffTokenRegex = regexp.MustCompile(`\s+|\S+`)
+
+var changesLabels = map[string]Localized{
+	"title":     {"en": "What changed", "th": "มีอะไรเปลี่ยนไป"},
+	"updated":   {"en": "Updated", "th": "แก้ไขเมื่อ"},
+	"read":      {"en": "Read the post", "th": "อ่านบทความ"},
+	"none":      {"en": "No earlier version of this post is on record.", "th": "ไม่มีบันทึกฉบับก่อนหน้าของบทความนี้"},
+	"unchanged": {"en": "The text is the same as in the previous version.", "th": "เนื้อหาเหมือนกับฉบับก่อนหน้า"},
+}
+
+// gitOutput runs a git command in dir and returns its output
+func gitOutput(ctx context.Context, dir string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return string(out), nil
+}
+
+// gitPreviousRevision returns the last committed version of file that differs
+// from current: its latest commit when current has edits not yet committed,
+// or else the commit before. Files outside a Git work tree have none.
+func gitPreviousRevision(ctx context.Context, file, current string) (string, error) {
+	dir, name := filepath.Split(file)
+	if dir == "" {
+		dir = "."
+	}
+	if _, err := gitOutput(ctx, dir, "rev-parse", "--is-inside-work-tree"); err != nil {
+		return "", errNoRevision
+	}
+	log, err := gitOutput(ctx, dir, "log", "-n", "2", "--format=%H", "--", name)
+	if err != nil {
+		return "", err
+	}
+	for _, hash := range strings.Fields(log) {
+		old, err := gitOutput(ctx, dir, "show", hash+":./"+name)
+		if err != nil {
+			return "", err
+		}
+		if old != current {
+			return old, nil
+		}
+	}
+	return "", errNoRevision
+}
+
+// PreviousRevision returns the version of a post before its latest change,
+// from the Git history of the directory it's stored in
+func (idx *PostIndex) PreviousRevision(ctx context.Context, slug string) (string, error) {
+	d, ok := idx.store.(storeDir)
+	if !ok {
+		return "", errNoRevision
+	}
+	idx.mu.RLock()
+	p, ok := idx.entries[slug]
+	idx.mu.RUnlock()
+	if !ok {
+		return "", errNoRevision
+	}
+	return gitPreviousRevision(ctx, filepath.Join(d.LocalDir(), slug+".md"), p.Content)
+}
+
+// diffOp is one step of a diff: a run of text kept, removed, or added
+type diffOp struct {
+	kind byte // '=', '-', or '+'
+	text string
+}
+
+// diffSeqs diffs two sequences by their longest common subsequence
+func diffSeqs(a, b []string) []diffOp {
+	// The same start and end need no table
+	var head, tail []diffOp
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		head = append(head, diffOp{'=', a[0]})
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		tail = append(tail, diffOp{'=', a[len(a)-1]})
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+	ops := head
+	if len(a)*len(b) > maxDiffCells {
+		for _, s := range a {
+			ops = append(ops, diffOp{'-', s})
+		}
+		for _, s := range b {
+			ops = append(ops, diffOp{'+', s})
+		}
+	} else {
+		ops = append(ops, lcsDiff(a, b)...)
+	}
+	for i := len(tail) - 1; i >= 0; i-- {
+		ops = append(ops, tail[i])
+	}
+	return ops
+}
+
+// lcsDiff diffs two sequences through the table of their common subsequences
+func lcsDiff(a, b []string) []diffOp {
+	// lcs[i][j] is the length of the common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{'=', a[i]})
+			i, j = i+1, j+1
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return ops
+}
+
+// textParagraphs splits plain text into its non-empty lines
+func textParagraphs(text string) []string {
+	var paras []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			paras = append(paras, line)
+		}
+	}
+	return paras
+}
+
+// wordDiffHTML shows the changes between two versions of a paragraph word by
+// word, removed words struck out and added ones marked
+func wordDiffHTML(before, after string) string {
+	oldWords, newWords := diffTokenRegex.FindAllString(before, -1), diffTokenRegex.FindAllString(after, -1)
+	if len(oldWords) > maxWordDiffTokens || len(newWords) > maxWordDiffTokens {
+		return "<del>" + template.HTMLEscapeString(before) + "</del><ins>" + template.HTMLEscapeString(after) + "</ins>"
+	}
+	// Merge runs of the same kind so a changed phrase is marked once
+	var runs []diffOp
+	for _, op := range diffSeqs(oldWords, newWords) {
+		if n := len(runs); n > 0 && runs[n-1].kind == op.kind {
+			runs[n-1].text += op.text
+			continue
+		}
+		runs = append(runs, op)
+	}
+	var b strings.Builder
+	for _, op := range runs {
+		text := template.HTMLEscapeString(op.text)
+		switch op.kind {
+		case '-':
+			b.WriteString("<del>" + text + "</del>")
+		case '+':
+			b.WriteString("<ins>" + text + "</ins>")
+		default:
+			b.WriteString(text)
+		}
+	}
+	return b.String()
+}
+
+// textDiffHTML shows the paragraphs that differ between two versions of a
+// text, with a little unchanged text around each change for context. A
+// paragraph that was edited rather than replaced is diffed word by word. It
+// returns "" when the texts are the same.
+func textDiffHTML(before, after string) string {
+	ops := diffSeqs(textParagraphs(before), textParagraphs(after))
+	changed := make([]bool, len(ops))
+	anyChange := false
+	for i, op := range ops {
+		if op.kind != '=' {
+			anyChange = true
+			for j := max(0, i-changesContext); j <= min(len(ops)-1, i+changesContext); j++ {
+				changed[j] = true
+			}
+		}
+	}
+	if !anyChange {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("<div class=\"text-diff\">\n")
+	skipped := false
+	for i := 0; i < len(ops); i++ {
+		op := ops[i]
+		if !changed[i] {
+			if !skipped {
+				b.WriteString("<p class=\"diff-skip\">…</p>\n")
+				skipped = true
+			}
+			continue
+		}
+		skipped = false
+		switch {
+		case op.kind == '=':
+			b.WriteString("<p class=\"diff-same\">" + template.HTMLEscapeString(op.text) + "</p>\n")
+		case op.kind == '-' && i+1 < len(ops) && ops[i+1].kind == '+':
+			// A paragraph removed and another added in its place is an edit
+			b.WriteString("<p class=\"diff-changed\">" + wordDiffHTML(op.text, ops[i+1].text) + "</p>\n")
+			i++
+		case op.kind == '-':
+			b.WriteString("<p class=\"diff-removed\"><del>" + template.HTMLEscapeString(op.text) + "</del></p>\n")
+		default:
+			b.WriteString("<p class=\"diff-added\"><ins>" + template.HTMLEscapeString(op.text) + "</ins></p>\n")
+		}
+	}
+	b.WriteString("</div>\n")
+	return b.String()
+}
+
+// renderedText renders post markdown and reduces it to its text
+func renderedText(src, slug string) (string, error) {
+	_, body := ParseFrontmatter(src)
+	html, err := renderMarkdown(body, slug)
+	if err != nil {
+		return "", err
+	}
+	return plainText(html), nil
+}
+
+// changesEntry is what the changes page found for one version of a post: the
+// diff against the previous revision, "" when the text is the same, or no
+// previous revision at all
+type changesEntry struct {
+	hash     string
+	diff     string
+	previous bool
+}
+
+// postChanges diffs a post against its previous revision. Both take Git and
+// a diff to find, so the result is kept per post until the post changes.
+func postChanges(ctx context.Context, posts *PostIndex, cache *sync.Map, slug, src string) (changesEntry, error) {
+	hash := contentHash(src)
+	if e, ok := cache.Load(slug); ok && e.(changesEntry).hash == hash {
+		return e.(changesEntry), nil
+	}
+	entry := changesEntry{hash: hash}
+	previous, err := posts.PreviousRevision(ctx, slug)
+	switch {
+	case errors.Is(err, errNoRevision):
+	case err != nil:
+		return entry, fmt.Errorf("read previous revision: %w", err)
+	default:
+		oldText, err := renderedText(previous, slug)
+		if err != nil {
+			return entry, err
+		}
+		newText, err := renderedText(src, slug)
+		if err != nil {
+			return entry, err
+		}
+		entry.diff, entry.previous = textDiffHTML(oldText, newText), true
+	}
+	cache.Store(slug, entry)
+	return entry, nil
+}
+
+// postUpdatedHTML notes when a post was last updated, with a link to what
+// changed, or returns "" for posts without a valid updated date
+func postUpdatedHTML(slug string, fm PostFrontmatter, lang string) string {
+	t, ok := parsePostDate(fm.Updated)
+	if !ok {
+		return ""
+	}
+	return "<span class=\"post-updated\">" + changesLabels["updated"].In(lang) + " " + t.Format("Jan 2, 2006") +
+		" · <a href=\"/posts/" + template.HTMLEscapeString(slug) + "/changes\">" + changesLabels["title"].In(lang) + "</a></span>\n"
+}
+
+// PostChangesHandler shows how the text of an updated post differs from its
+// previous version, so returning readers can see what was revised. Only posts
+// with an updated date have the page.
+func PostChangesHandler(posts *PostIndex) http.HandlerFunc {
+	var cache sync.Map
+	return func(w http.ResponseWriter, r *http.Request) {
+		setSecurityHeaders(w)
+		lang := requestLang(r)
+
+		slug := r.PathValue("slug")
+		if !IsValidSlug(slug) {
+			renderError(w, http.StatusBadRequest, lang)
+			return
+		}
+		src, err := posts.Read(slug)
+		if err != nil {
+			renderError(w, http.StatusNotFound, lang)
+			return
+		}
+		fm, _ := ParseFrontmatter(src)
+		updated, ok := parsePostDate(fm.Updated)
+		if !postVisible(fm) || !ok {
+			renderError(w, http.StatusNotFound, lang)
+			return
+		}
+		title := fm.Title
+		if title == "" {
+			title = toTitleCase(strings.ReplaceAll(slug, "-", " "))
+		}
+		// The post itself is what should be found
+		w.Header().Set("X-Robots-Tag", "noindex, follow")
+
+		var content strings.Builder
+		content.WriteString("<article class=\"post-changes\">\n<div class=\"post-header\">\n")
+		content.WriteString("<h1>" + changesLabels["title"].In(lang) + ": " + template.HTMLEscapeString(title) + "</h1>\n")
+		content.WriteString("<span class=\"post-meta\">" + changesLabels["updated"].In(lang) + " " + updated.Format("Jan 2, 2006") +
+			" · <a href=\"/posts/" + template.HTMLEscapeString(slug) + "\">" + changesLabels["read"].In(lang) + "</a></span>\n</div>\n")
+
+		changes, err := postChanges(r.Context(), posts, &cache, slug, src)
+		if err != nil {
+			slog.Error("Error diffing post versions", "slug", slug, "err", err)
+			renderError(w, http.StatusInternalServerError, lang)
+			return
+		}
+		switch {
+		case !changes.previous:
+			content.WriteString("<p>" + changesLabels["none"].In(lang) + "</p>\n")
+		case changes.diff == "":
+			content.WriteString("<p>" + changesLabels["unchanged"].In(lang) + "</p>\n")
+		default:
+			content.WriteString(changes.diff)
+		}
+		content.WriteString("</article>\n")
+
+		renderPage(w, changesLabels["title"].In(lang)+": "+title, template.HTML(content.String()))
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestTextDiffHTML(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after string
+		want          []string
+		notWant       []string
+	}{
+		{"same", "One.\nTwo.", "One.\n\nTwo.", nil, nil},
+		{"edited word", "Go is slow.", "Go is fast.", []string{`<p class="diff-changed">Go is <del>slow.</del><ins>fast.</ins></p>`}, nil},
+		{"added paragraph", "One.", "One.\nTwo.", []string{`<p class="diff-same">One.</p>`, `<p class="diff-added"><ins>Two.</ins></p>`}, nil},
+		{"removed paragraph", "One.\nTwo.", "One.", []string{`<p class="diff-removed"><del>Two.</del></p>`}, nil},
+		{"escaped", "a < b", "a > b", []string{"<del>&lt;</del><ins>&gt;</ins>"}, nil},
+		{"distant text skipped", "A.\nB.\nC.\nD.", "A.\nB.\nC.\nE.", []string{`<p class="diff-skip">…</p>`, `<p class="diff-same">C.</p>`}, []string{"A.", "B."}},
+		{"long paragraph replaced whole", strings.Repeat("old ", maxWordDiffTokens), strings.Repeat("new ", maxWordDiffTokens),
+			[]string{`<p class="diff-changed"><del>old old`, `old</del><ins>new new`}, []string{"<del>old</del>"}},
+	}
+	for _, tt := range tests {
+		got := textDiffHTML(tt.before, tt.after)
+		if tt.want == nil && got != "" {
+			t.Errorf("%s: expected no diff, got %s", tt.name, got)
+		}
+		for _, s := range tt.want {
+			if !strings.Contains(got, s) {
+				t.Errorf("%s: diff lacks %q: %s", tt.name, s, got)
+			}
+		}
+		for _, s := range tt.notWant {
+			if strings.Contains(got, s) {
+				t.Errorf("%s: diff has %q: %s", tt.name, s, got)
+			}
+		}
+	}
+}
+
+func TestDiffSeqs_Large(t *testing.T) {
+	// Too many differing lines for the table: kept ends, the rest replaced whole
+	n := 2000
+	a, b := make([]string, n), make([]string, n)
+	for i := range n {
+		a[i], b[i] = "a"+strconv.Itoa(i), "b"+strconv.Itoa(i)
+	}
+	a[0], b[0] = "same", "same"
+	ops := diffSeqs(a, b)
+	if len(ops) != 2*n-1 || ops[0] != (diffOp{'=', "same"}) || ops[1].kind != '-' || ops[n].kind != '+' {
+		t.Errorf("got %d ops starting %v", len(ops), ops[:3])
+	}
+}
+
+func TestPostChangesHandler(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Chdir(t.TempDir())
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=Test", "-c", "user.email=test@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join("posts", name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run("init", "--initial-branch=main", ".")
+	os.Mkdir("posts", 0755)
+	write("hello.md", "---\ntitle: Hello\ndate: 2026-01-01\n---\n\nGo is slow.\n\nThe end.")
+	write("plain.md", "---\ntitle: Plain\ndate: 2026-01-01\n---\n\nNever revised.")
+	run("add", ".")
+	run("commit", "-m", "First version")
+	write("hello.md", "---\ntitle: Hello\ndate: 2026-01-01\nupdated: 2026-03-10\n---\n\nGo is fast.\n\nThe end.")
+	run("commit", "-am", "Correct the speed")
+
+	get := func(slug string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/posts/"+slug+"/changes?lang=en", nil)
+		req.SetPathValue("slug", slug)
+		w := httptest.NewRecorder()
+		PostChangesHandler(newTestPostIndex(t, "posts"))(w, req)
+		return w
+	}
+
+	w := get("hello")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+	body := w.Body.String()
+	for _, s := range []string{"What changed: Hello", "Updated Mar 10, 2026", "Go is <del>slow.</del><ins>fast.</ins>", `content="noindex, follow"`} {
+		if !strings.Contains(body, s) {
+			t.Errorf("page lacks %q", s)
+		}
+	}
+
+	// Edits not yet committed are compared with the last commit
+	write("hello.md", "---\ntitle: Hello\ndate: 2026-01-01\nupdated: 2026-03-11\n---\n\nGo is fast.\n\nThe very end.")
+	if body := get("hello").Body.String(); !strings.Contains(body, "<ins>very </ins>") || strings.Contains(body, "<del>slow.</del>") {
+		t.Errorf("uncommitted edit not diffed against the last commit: %s", body)
+	}
+
+	// The diff is kept until the post changes, without asking Git again
+	h := PostChangesHandler(newTestPostIndex(t, "posts"))
+	req := httptest.NewRequest("GET", "/posts/hello/changes?lang=en", nil)
+	req.SetPathValue("slug", "hello")
+	h(httptest.NewRecorder(), req)
+	os.Rename(".git", ".git-moved")
+	w = httptest.NewRecorder()
+	h(w, req)
+	os.Rename(".git-moved", ".git")
+	if !strings.Contains(w.Body.String(), "<ins>very </ins>") {
+		t.Errorf("cached diff not served: %s", w.Body)
+	}
+
+	// Only posts with an updated date have the page
+	if w := get("plain"); w.Code != http.StatusNotFound {
+		t.Errorf("post without updated: status = %d, want 404", w.Code)
+	}
+
+	// The post links to the page
+	req = httptest.NewRequest("GET", "/posts/hello?lang=en", nil)
+	req.SetPathValue("slug", "hello")
+	w = httptest.NewRecorder()
+	PostHandler(newTestPostIndex(t, "posts"), nil, nil)(w, req)
+	if !strings.Contains(w.Body.String(), `<a href="/posts/hello/changes">What changed</a>`) {
+		t.Error("post page lacks the What changed link")
+	}
+}
+
+func TestPostChangesHandler_NoHistory(t *testing.T) {
+	t.Chdir(t.TempDir())
+	os.Mkdir("posts", 0755)
+	os.WriteFile(filepath.Join("posts", "hello.md"), []byte("---\ntitle: Hello\nupdated: 2026-03-10\n---\n\nHi"), 0644)
+
+	req := httptest.NewRequest("GET", "/posts/hello/changes?lang=en", nil)
+	req.SetPathValue("slug", "hello")
+	w := httptest.NewRecorder()
+	PostChangesHandler(newTestPostIndex(t, "posts"))(w, req)
+
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "No earlier version of this post is on record.") {
+		t.Errorf("got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	clapCookieName = "clapper"
+	clapCookieTTL  = 365 * 24 * time.Hour
+)
+
+// ClapTotal is how many claps a post has, and from how many readers
+type ClapTotal struct {
+	Slug    string
+	Claps   int
+	Readers int
+}
+
+// ClapStore keeps each post's clap total, and how many claps each reader gave
+// so they can be capped. Readers are stored only by the ID in their cookie.
+type ClapStore struct {
+	db     *sql.DB
+	secret []byte
+}
+
+const clapsSchema = `CREATE TABLE IF NOT EXISTS clap_totals (
+	slug TEXT PRIMARY KEY,
+	claps INTEGER NOT NULL,
+	readers INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS clap_readers (
+	slug TEXT NOT NULL,
+	reader TEXT NOT NULL,
+	claps INTEGER NOT NULL,
+	PRIMARY KEY (slug, reader)
+);`
+
+// NewClapStore creates the clap tables if needed. secret keys the reader IDs
+// derived from IP addresses.
+func NewClapStore(db *sql.DB, secret []byte) (*ClapStore, error) {
+	if _, err := db.Exec(clapsSchema); err != nil {
+		return nil, fmt.Errorf("create clap tables: %w", err)
+	}
+	return &ClapStore{db: db, secret: secret}, nil
+}
+
+// Add gives slug up to n claps from reader, stopping once the reader has
+// given max, and returns the post's total and the reader's own count
+func (s *ClapStore) Add(slug, reader string, n, max int) (total, yours int, err error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, 0, fmt.Errorf("add claps: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`SELECT claps FROM clap_readers WHERE slug = ? AND reader = ?`, slug, reader).Scan(&yours)
+	newReader := errors.Is(err, sql.ErrNoRows)
+	if err != nil && !newReader {
+		return 0, 0, fmt.Errorf("add claps: %w", err)
+	}
+	n = min(n, max-yours)
+	if n > 0 {
+		_, err = tx.Exec(`INSERT INTO clap_readers (slug, reader, claps) VALUES (?, ?, ?)
+			ON CONFLICT (slug, reader) DO UPDATE SET claps = claps + excluded.claps`, slug, reader, n)
+		if err != nil {
+			return 0, 0, fmt.Errorf("add claps: %w", err)
+		}
+		readers := 0
+		if newReader {
+			readers = 1
+		}
+		_, err = tx.Exec(`INSERT INTO clap_totals (slug, claps, readers) VALUES (?, ?, ?)
+			ON CONFLICT (slug) DO UPDATE SET claps = claps + excluded.claps, readers = readers + excluded.readers`, slug, n, readers)
+		if err != nil {
+			return 0, 0, fmt.Errorf("add claps: %w", err)
+		}
+		yours += n
+	}
+	if err := tx.QueryRow(`SELECT COALESCE((SELECT claps FROM clap_totals WHERE slug = ?), 0)`, slug).Scan(&total); err != nil {
+		return 0, 0, fmt.Errorf("add claps: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("add claps: %w", err)
+	}
+	return total, yours, nil
+}
+
+// Count returns the post's total and the count from the reader making r
+func (s *ClapStore) Count(slug string, r *http.Request) (total, yours int, err error) {
+	reader, _ := s.reader(r)
+	err = s.db.QueryRow(`SELECT
+		COALESCE((SELECT claps FROM clap_totals WHERE slug = ?), 0),
+		COALESCE((SELECT claps FROM clap_readers WHERE slug = ? AND reader = ?), 0)`, slug, slug, reader).Scan(&total, &yours)
+	if err != nil {
+		return 0, 0, fmt.Errorf("count claps: %w", err)
+	}
+	return total, yours, nil
+}
+
+// Top returns the most clapped posts
+func (s *ClapStore) Top(limit int) ([]ClapTotal, error) {
+	rows, err := s.db.Query(`SELECT slug, claps, readers FROM clap_totals ORDER BY claps DESC, readers DESC, slug LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list claps: %w", err)
+	}
+	defer rows.Close()
+
+	var out []ClapTotal
+	for rows.Next() {
+		var ct ClapTotal
+		if err := rows.Scan(&ct.Slug, &ct.Claps, &ct.Readers); err != nil {
+			return nil, fmt.Errorf("list claps: %w", err)
+		}
+		out = append(out, ct)
+	}
+	return out, rows.Err()
+}
+
+// clapReader identifies the reader for the per-reader cap: the ID in their
+// clapper cookie, or one derived from their IP until they have it. The cookie
+// is then set to that same ID, so clearing it doesn't reset the cap, while
+// readers sharing an address keep their own. IPs are keyed with the store's
+// secret so the IDs can't be traced back to an address.
+func (s *ClapStore) reader(r *http.Request) (id string, fromCookie bool) {
+	if c, err := r.Cookie(clapCookieName); err == nil && len(c.Value) == 32 {
+		if _, err := hex.DecodeString(c.Value); err == nil {
+			return c.Value, true
+		}
+	}
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte("clap|" + clientIP(r)))
+	return hex.EncodeToString(mac.Sum(nil))[:32], false
+}
+
+// setClapCookie remembers the reader's ID
+func setClapCookie(w http.ResponseWriter, r *http.Request, id string) {
+	cookie := newCookie(r, clapCookieName, id)
+	cookie.HttpOnly = true
+	cookie.Expires = time.Now().Add(clapCookieTTL)
+	http.SetCookie(w, cookie)
+}
+
+// clapsHTML renders the clap button with the post's total. The button is
+// disabled once the reader has given all their claps.
+func clapsHTML(slug string, total, yours int) string {
+	esc := template.HTMLEscapeString(slug)
+	disabled := ""
+	if yours >= cfg.Claps.MaxPerReader {
+		disabled = " disabled"
+	}
+	label := "claps"
+	if total == 1 {
+		label = "clap"
+	}
+	var b strings.Builder
+	b.WriteString("<form class=\"claps\" id=\"claps\" method=\"POST\" action=\"/posts/" + esc + "/clap\">")
+	b.WriteString("<button type=\"submit\" aria-label=\"Clap for this post\"" + disabled + ">👏</button> ")
+	b.WriteString("<span class=\"clap-count\">" + strconv.Itoa(total) + " " + label + "</span>")
+	if yours > 0 {
+		b.WriteString(" <span class=\"clap-yours\">(" + strconv.Itoa(yours) + " from you)</span>")
+	}
+	b.WriteString("</form>\n")
+	return b.String()
+}
+
+// ClapHandler adds the reader's claps to a post, count of them at a time
+// (one by default), up to claps.max_per_reader per reader. Requests that
+// accept JSON get the new counts; forms go back to the post.
+func ClapHandler(claps *ClapStore, sl SlugReader, limiter *RateLimiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slug := r.PathValue("slug")
+		if !IsValidSlug(slug) {
+			http.Error(w, "Invalid post slug", http.StatusBadRequest)
+			return
+		}
+		md, err := sl.Read(slug)
+		if err != nil {
+			http.Error(w, "Post not found", http.StatusNotFound)
+			return
+		}
+		if fm, _ := ParseFrontmatter(md); !postVisible(fm) {
+			http.Error(w, "Post not found", http.StatusNotFound)
+			return
+		}
+		// The per-reader cap trusts the cookie, so made-up IDs are held back per IP
+		if limiter != nil && !limiter.Allow(clientIP(r)) {
+			http.Error(w, "Too many claps, try again later", http.StatusTooManyRequests)
+			return
+		}
+
+		n := 1
+		if v := r.PostFormValue("count"); v != "" {
+			n, err = strconv.Atoi(v)
+			if err != nil || n < 1 {
+				http.Error(w, "Invalid clap count", http.StatusBadRequest)
+				return
+			}
+		}
+		reader, fromCookie := claps.reader(r)
+		total, yours, err := claps.Add(slug, reader, n, cfg.Claps.MaxPerReader)
+		if err != nil {
+			slog.Error("Error adding claps", "slug", slug, "err", err)
+			http.Error(w, "Could not save claps", http.StatusInternalServerError)
+			return
+		}
+		if !fromCookie {
+			setClapCookie(w, r, reader)
+		}
+
+		if strings.Contains(r.Header.Get("Accept"), "application/json") {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]int{"claps": total, "yours": yours, "max": cfg.Claps.MaxPerReader})
+			return
+		}
+		http.Redirect(w, r, "/posts/"+slug+"#claps", http.StatusSeeOther)
+	}
+}
+
+// clapStatsTable renders the most clapped posts for the admin stats page
+func clapStatsTable(totals []ClapTotal) string {
+	var b strings.Builder
+	b.WriteString("<table class=\"admin-table\">\n<tr><th>Post</th><th>Claps</th><th>Readers</th><th>Claps per reader</th></tr>\n")
+	for _, ct := range totals {
+		perReader := 0.0
+		if ct.Readers > 0 {
+			perReader = float64(ct.Claps) / float64(ct.Readers)
+		}
+		b.WriteString("<tr>")
+		b.WriteString("<td><a href=\"/posts/" + template.HTMLEscapeString(ct.Slug) + "\">" + template.HTMLEscapeString(ct.Slug) + "</a></td>")
+		b.WriteString("<td>" + strconv.Itoa(ct.Claps) + "</td>")
+		b.WriteString("<td>" + strconv.Itoa(ct.Readers) + "</td>")
+		b.WriteString("<td>" + strconv.FormatFloat(perReader, 'f', 1, 64) + "</td>")
+		b.WriteString("</tr>\n")
+	}
+	b.WriteString("</table>\n")
+	return b.String()
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestClapStore(t *testing.T) *ClapStore {
+	t.Helper()
+	store, err := NewClapStore(newTestDB(t), []byte("secret"))
+	if err != nil {
+		t.Fatalf("NewClapStore: %v", err)
+	}
+	return store
+}
+
+func TestClapStoreAdd(t *testing.T) {
+	store := newTestClapStore(t)
+	tests := []struct {
+		reader     string
+		n          int
+		wantTotal  int
+		wantYours  int
+		wantReader int
+	}{
+		{"a", 3, 3, 3, 1},
+		{"a", 5, 5, 5, 1},
+		{"b", 1, 6, 1, 2},
+		// a has given the most allowed
+		{"a", 1, 6, 5, 2},
+	}
+	for _, tt := range tests {
+		total, yours, err := store.Add("hello", tt.reader, tt.n, 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != tt.wantTotal || yours != tt.wantYours {
+			t.Errorf("Add(%s, %d) = %d, %d; want %d, %d", tt.reader, tt.n, total, yours, tt.wantTotal, tt.wantYours)
+		}
+		top, _ := store.Top(10)
+		if len(top) != 1 || top[0].Readers != tt.wantReader {
+			t.Errorf("after Add(%s, %d): Top = %+v", tt.reader, tt.n, top)
+		}
+	}
+	store.Add("other", "a", 1, 5)
+	if top, _ := store.Top(10); len(top) != 2 || top[0].Slug != "hello" || top[1].Slug != "other" {
+		t.Errorf("Top = %+v", top)
+	}
+}
+
+func TestClapHandler(t *testing.T) {
+	defer func(old ClapsConfig) { cfg.Claps = old }(cfg.Claps)
+	cfg.Claps = ClapsConfig{Enabled: true, MaxPerReader: 3}
+
+	store := newTestClapStore(t)
+	posts := &MockSlugReader{content: map[string]string{
+		"hello":  "---\ntitle: Hello\n---\nWorld",
+		"future": "---\ntitle: Soon\ndate: 2099-01-01\n---\nLater",
+	}}
+	h := http.NewServeMux()
+	h.HandleFunc("POST /posts/{slug}/clap", ClapHandler(store, posts, NewRateLimiter(4, time.Hour)))
+
+	clap := func(slug, count string, cookie *http.Cookie, remote string) *httptest.ResponseRecorder {
+		form := url.Values{}
+		if count != "" {
+			form.Set("count", count)
+		}
+		r := httptest.NewRequest("POST", "/posts/"+slug+"/clap", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Set("Accept", "application/json")
+		r.RemoteAddr = remote + ":1234"
+		if cookie != nil {
+			r.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	counts := func(w *httptest.ResponseRecorder) map[string]int {
+		var got map[string]int
+		json.NewDecoder(w.Body).Decode(&got)
+		return got
+	}
+
+	w := clap("hello", "", nil, "192.0.2.1")
+	got := counts(w)
+	if w.Code != http.StatusOK || got["claps"] != 1 || got["yours"] != 1 {
+		t.Fatalf("first clap: %d %v", w.Code, got)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != clapCookieName {
+		t.Fatalf("cookies = %v", cookies)
+	}
+
+	// Clearing the cookie doesn't reset the cap from the same address
+	if got := counts(clap("hello", "10", nil, "192.0.2.1")); got["claps"] != 3 || got["yours"] != 3 {
+		t.Errorf("capped clap without cookie = %v", got)
+	}
+	if got := counts(clap("hello", "", cookies[0], "192.0.2.1")); got["claps"] != 3 || got["yours"] != 3 {
+		t.Errorf("capped clap with cookie = %v", got)
+	}
+	// Another reader at the same address has their own cap
+	other := &http.Cookie{Name: clapCookieName, Value: strings.Repeat("ab", 16)}
+	if got := counts(clap("hello", "", other, "192.0.2.1")); got["claps"] != 4 || got["yours"] != 1 {
+		t.Errorf("other reader = %v", got)
+	}
+	if w := clap("hello", "", nil, "192.0.2.1"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("over the rate limit: status %d", w.Code)
+	}
+
+	for _, tt := range []struct {
+		slug, count string
+		want        int
+	}{
+		{"missing", "", http.StatusNotFound},
+		{"future", "", http.StatusNotFound},
+		{"hello", "0", http.StatusBadRequest},
+		{"hello", "many", http.StatusBadRequest},
+	} {
+		if w := clap(tt.slug, tt.count, nil, "198.51.100.1"); w.Code != tt.want {
+			t.Errorf("clap %s count %q: status %d, want %d", tt.slug, tt.count, w.Code, tt.want)
+		}
+	}
+}
+
+func TestPostHandler_Claps(t *testing.T) {
+	defer func(old ClapsConfig) { cfg.Claps = old }(cfg.Claps)
+	cfg.Claps = ClapsConfig{Enabled: true, MaxPerReader: 2}
+
+	store := newTestClapStore(t)
+	posts := &MockSlugReader{content: map[string]string{"hello": "---\ntitle: Hello\n---\nWorld"}}
+	reader := strings.Repeat("cd", 16)
+	store.Add("hello", reader, 2, 2)
+	store.Add("hello", "someone-else", 1, 2)
+
+	r := httptest.NewRequest("GET", "/posts/hello", nil)
+	r.SetPathValue("slug", "hello")
+	r.AddCookie(&http.Cookie{Name: clapCookieName, Value: reader})
+	w := httptest.NewRecorder()
+	PostHandler(posts, nil, store)(w, r)
+	body := w.Body.String()
+	for _, want := range []string{`action="/posts/hello/clap"`, "3 claps", "(2 from you)", "disabled"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("post page missing %q", want)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	AdminStatsHandler(newTestSearchStats(t), store)(w, httptest.NewRequest("GET", "/admin/stats", nil))
+	if !strings.Contains(w.Body.String(), "Most clapped posts") || !strings.Contains(w.Body.String(), "<td>1.5</td>") {
+		t.Errorf("admin stats = %s", w.Body.String())
+	}
+}
@@ -0,0 +1,77 @@
+// Command loadtest sends requests to a running blog and reports throughput and
+// latency percentiles, for checking handler performance against a real server:
+//
+//	go run ./cmd/loadtest -url http://localhost:3030/ -c 16 -d 10s
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+func main() {
+	target := flag.String("url", "http://localhost:3030/", "URL to request")
+	concurrency := flag.Int("c", 8, "concurrent workers")
+	duration := flag.Duration("d", 10*time.Second, "how long to run")
+	flag.Parse()
+
+	client := &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{MaxIdleConnsPerHost: *concurrency},
+	}
+
+	var (
+		mu        sync.Mutex
+		latencies []time.Duration
+		failures  int
+	)
+	deadline := time.Now().Add(*duration)
+	var wg sync.WaitGroup
+	for range *concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var local []time.Duration
+			var failed int
+			for time.Now().Before(deadline) {
+				start := time.Now()
+				resp, err := client.Get(*target)
+				if err != nil {
+					failed++
+					continue
+				}
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusOK {
+					failed++
+					continue
+				}
+				local = append(local, time.Since(start))
+			}
+			mu.Lock()
+			latencies = append(latencies, local...)
+			failures += failed
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if len(latencies) == 0 {
+		log.Fatalf("no successful requests (%d failed)", failures)
+	}
+	slices.Sort(latencies)
+	pct := func(p float64) time.Duration { return latencies[int(float64(len(latencies)-1)*p)] }
+	fmt.Printf("%s: %d requests in %s (%.0f req/s), %d failed\n",
+		*target, len(latencies), *duration, float64(len(latencies))/duration.Seconds(), failures)
+	fmt.Printf("latency p50 %s  p90 %s  p99 %s  max %s\n", pct(0.50), pct(0.90), pct(0.99), latencies[len(latencies)-1])
+	if failures > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+func init() {
+	shortcodes["code-download"] = codeDownloadShortcode
+}
+
+// codeExtensions maps fenced code languages to file extensions for snippets
+// that don't name their file
+var codeExtensions = map[string]string{
+	"go": ".go", "python": ".py", "py": ".py", "javascript": ".js", "js": ".js",
+	"typescript": ".ts", "ts": ".ts", "sh": ".sh", "bash": ".sh", "shell": ".sh",
+	"html": ".html", "css": ".css", "json": ".json", "yaml": ".yaml", "yml": ".yaml",
+	"toml": ".toml", "sql": ".sql", "rust": ".rs", "c": ".c", "cpp": ".cpp",
+	"java": ".java", "dockerfile": ".dockerfile", "makefile": ".mk",
+}
+
+// codeFile is one file of a post's code download
+type codeFile struct {
+	Name string
+	Body []byte
+}
+
+// codeFileName returns the file name a fenced block's info string gives, as in
+// ```go main.go or ```go file=main.go, or "" when it names none
+func codeFileName(info string) string {
+	fields := strings.Fields(info)
+	for _, f := range fields[min(1, len(fields)):] {
+		name := f
+		if k, v, ok := strings.Cut(f, "="); ok {
+			if k != "file" && k != "title" {
+				continue
+			}
+			name = strings.Trim(v, `"'`)
+		}
+		if strings.Contains(name, ".") && validAttachmentPath(name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// extractCode returns the fenced code blocks of a post's markdown as files.
+// Blocks that name a file are saved under that name, and a later block with
+// the same name replaces an earlier one, so a tutorial that grows a file step
+// by step ends up with the finished version. Others are numbered snippets.
+func extractCode(body string) []codeFile {
+	src := []byte(body)
+	doc := markdown.Parser().Parse(text.NewReader(src))
+
+	var files []codeFile
+	index := make(map[string]int)
+	snippets := 0
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		block, ok := n.(*ast.FencedCodeBlock)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+		var info string
+		if block.Info != nil {
+			info = string(block.Info.Segment.Value(src))
+		}
+		var code bytes.Buffer
+		for i := 0; i < block.Lines().Len(); i++ {
+			line := block.Lines().At(i)
+			code.Write(line.Value(src))
+		}
+		if strings.TrimSpace(code.String()) == "" {
+			return ast.WalkContinue, nil
+		}
+
+		name := codeFileName(info)
+		if name == "" {
+			snippets++
+			lang := ""
+			if fields := strings.Fields(info); len(fields) > 0 {
+				lang = strings.ToLower(fields[0])
+			}
+			ext, ok := codeExtensions[lang]
+			if !ok {
+				ext = ".txt"
+			}
+			name = fmt.Sprintf("snippet-%02d%s", snippets, ext)
+		}
+		if i, ok := index[name]; ok {
+			files[i].Body = code.Bytes()
+		} else {
+			index[name] = len(files)
+			files = append(files, codeFile{Name: name, Body: code.Bytes()})
+		}
+		return ast.WalkContinue, nil
+	})
+	return files
+}
+
+// codeZip packs files into a zip archive under a folder named dir
+func codeZip(dir string, files []codeFile, modTime time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: path.Join(dir, f.Name), Method: zip.Deflate, Modified: modTime})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(f.Body); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// cachedCodeZip is a built archive and the post content it was built from
+type cachedCodeZip struct {
+	sum  [sha256.Size]byte
+	data []byte
+	at   time.Time
+}
+
+// codeZipCache holds the archive of each post until its markdown changes
+var codeZipCache = struct {
+	sync.Mutex
+	entries map[string]cachedCodeZip
+}{entries: make(map[string]cachedCodeZip)}
+
+// postCodeZip returns the code archive for a post, building it unless the
+// post is unchanged since it was last built. Its data is nil when the post
+// has no code.
+func postCodeZip(slug, body string) (cachedCodeZip, error) {
+	sum := sha256.Sum256([]byte(body))
+	codeZipCache.Lock()
+	defer codeZipCache.Unlock()
+	if c, ok := codeZipCache.entries[slug]; ok && c.sum == sum {
+		metrics.Inc("code_zip_cache_hits_total")
+		return c, nil
+	}
+	files := extractCode(body)
+	c := cachedCodeZip{sum: sum, at: time.Now().UTC().Truncate(time.Second)}
+	if len(files) > 0 {
+		var err error
+		if c.data, err = codeZip(slug, files, c.at); err != nil {
+			return c, err
+		}
+	}
+	codeZipCache.entries[slug] = c
+	return c, nil
+}
+
+// codeDownloadShortcode renders {{< code-download [title="..."] >}} as a link
+// to a zip of every code block in the post
+func codeDownloadShortcode(call ShortcodeCall) (string, error) {
+	title := call.Params["title"]
+	if title == "" {
+		title = "Download the code"
+	}
+	href := "/posts/" + call.Slug + "/code.zip"
+	return `<p class="code-download"><a href="` + template.HTMLEscapeString(href) + `" download>` +
+		template.HTMLEscapeString(title) + `</a> <span class="attachment-meta">ZIP</span></p>`, nil
+}
+
+// CodeZipHandler serves /posts/{slug}/code.zip, the post's fenced code blocks
+// as files in a zip archive
+func CodeZipHandler(sl SlugReader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slug := r.PathValue("slug")
+		if !IsValidSlug(slug) {
+			http.Error(w, "Invalid post slug", http.StatusBadRequest)
+			return
+		}
+		src, err := sl.Read(slug)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		fm, body := ParseFrontmatter(src)
+		if !postVisible(fm) {
+			http.NotFound(w, r)
+			return
+		}
+		c, err := postCodeZip(slug, body)
+		if err != nil {
+			slog.Error("Error building code zip", "slug", slug, "err", err)
+			http.Error(w, "Could not build the download", http.StatusInternalServerError)
+			return
+		}
+		if c.data == nil {
+			http.Error(w, "This post has no code", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": slug + "-code.zip"}))
+		w.Header().Set("ETag", strconv.Quote(hex.EncodeToString(c.sum[:8])))
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		http.ServeContent(w, r, slug+"-code.zip", c.at, bytes.NewReader(c.data))
+	}
+}
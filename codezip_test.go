@@ -0,0 +1,115 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const codePost = "---\ntitle: Tutorial\n---\n\n" +
+	"Start with:\n\n```go main.go\npackage main\n```\n\n" +
+	"Then run it:\n\n```sh\ngo run .\n```\n\n" +
+	"Finish main.go:\n\n```go file=main.go\npackage main\n\nfunc main() {}\n```\n\n" +
+	"```\nplain output\n```\n\n" +
+	"{{< code-download >}}\n"
+
+func TestExtractCode(t *testing.T) {
+	_, body := ParseFrontmatter(codePost)
+	files := extractCode(body)
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	if want := []string{"main.go", "snippet-01.sh", "snippet-02.txt"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("files = %v, want %v", names, want)
+	}
+	if got := string(files[0].Body); got != "package main\n\nfunc main() {}\n" {
+		t.Errorf("main.go = %q, want the last version", got)
+	}
+}
+
+func TestCodeFileName(t *testing.T) {
+	tests := []struct {
+		info, want string
+	}{
+		{"go main.go", "main.go"},
+		{`go title="cmd/app/main.go"`, "cmd/app/main.go"},
+		{"go file=../etc/passwd", ""},
+		{"go", ""},
+		{"main.go", ""}, // the first word is the language
+		{"go {linenos=true}", ""},
+	}
+	for _, tt := range tests {
+		if got := codeFileName(tt.info); got != tt.want {
+			t.Errorf("codeFileName(%q) = %q, want %q", tt.info, got, tt.want)
+		}
+	}
+}
+
+func TestCodeZipHandler(t *testing.T) {
+	posts := &MockSlugReader{content: map[string]string{
+		"tutorial": codePost,
+		"prose":    "---\ntitle: Prose\n---\n\nNo code here.",
+		"later":    "---\ntitle: Later\ndate: 2999-01-01\n---\n\n```go\npackage main\n```",
+	}}
+	h := CodeZipHandler(posts)
+	get := func(slug string, header ...string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/posts/"+slug+"/code.zip", nil)
+		r.SetPathValue("slug", slug)
+		if len(header) == 2 {
+			r.Header.Set(header[0], header[1])
+		}
+		w := httptest.NewRecorder()
+		h(w, r)
+		return w
+	}
+
+	w := get("tutorial")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("status %d, Content-Type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if cd := w.Header().Get("Content-Disposition"); cd != "attachment; filename=tutorial-code.zip" {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	if want := []string{"tutorial/main.go", "tutorial/snippet-01.sh", "tutorial/snippet-02.txt"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("zip holds %v, want %v", names, want)
+	}
+	rc, _ := zr.File[1].Open()
+	if b, _ := io.ReadAll(rc); string(b) != "go run .\n" {
+		t.Errorf("snippet = %q", b)
+	}
+
+	// Cached and unchanged: a conditional request gets 304
+	if again := get("tutorial", "If-None-Match", w.Header().Get("ETag")); again.Code != http.StatusNotModified {
+		t.Errorf("conditional request: status %d", again.Code)
+	}
+
+	for slug, status := range map[string]int{"prose": http.StatusNotFound, "later": http.StatusNotFound, "missing": http.StatusNotFound, "bad.slug": http.StatusBadRequest} {
+		if w := get(slug); w.Code != status {
+			t.Errorf("%s: status %d, want %d", slug, w.Code, status)
+		}
+	}
+}
+
+func TestCodeDownloadShortcode(t *testing.T) {
+	html, err := renderMarkdown("Grab it: \n\n{{< code-download title=\"Get the <code>\" >}}\n", "en-tutorial")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(html, `<a href="/posts/en-tutorial/code.zip" download>Get the &lt;code&gt;</a>`) {
+		t.Errorf("html = %s", html)
+	}
+}
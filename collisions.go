@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// SlugCollision is an address more than one content file answers to. The
+// router serves one of them and the others can't be reached there.
+type SlugCollision struct {
+	Path  string
+	Files []string
+}
+
+// String describes the collision for logs and the check command
+func (c SlugCollision) String() string {
+	return c.Path + ": slug collision between " + strings.Join(c.Files, ", ")
+}
+
+// slugCollisions groups files by address, compared case-insensitively since
+// exports land on case-insensitive filesystems, and returns the addresses
+// claimed by more than one file, sorted by address. A clash in case is named
+// by its first spelling in sort order.
+func slugCollisions(addresses map[string][]string) []SlugCollision {
+	byKey := make(map[string]*SlugCollision)
+	for file, paths := range addresses {
+		for _, p := range paths {
+			key := strings.ToLower(p)
+			c, ok := byKey[key]
+			if !ok {
+				c = &SlugCollision{Path: p}
+				byKey[key] = c
+			}
+			c.Path = min(c.Path, p)
+			if !slices.Contains(c.Files, file) {
+				c.Files = append(c.Files, file)
+			}
+		}
+	}
+	var out []SlugCollision
+	for _, c := range byKey {
+		if len(c.Files) > 1 {
+			sort.Strings(c.Files)
+			out = append(out, *c)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return strings.ToLower(out[i].Path) < strings.ToLower(out[j].Path) })
+	return out
+}
+
+// findCollisions returns the addresses more than one post answers to: its
+// own at /posts/{slug}, its name in each language tree it's listed in, where
+// en-hello and a hello written in English are both /en/posts/hello, and its
+// aliases. The caller must hold the lock.
+func (idx *PostIndex) findCollisions() []SlugCollision {
+	addresses := make(map[string][]string, len(idx.entries))
+	for slug, p := range idx.entries {
+		paths := []string{"/posts/" + slug}
+		for _, lang := range pathLanguages {
+			if postInLang(p.Post, lang) {
+				paths = append(paths, "/"+lang+"/posts/"+localSlug(slug, p.Lang))
+			}
+		}
+		for _, alias := range p.FM.Aliases {
+			if ap := aliasPath(alias); ap != "" && !slices.Contains(paths, ap) {
+				paths = append(paths, ap)
+			}
+		}
+		addresses[path.Join(idx.dir, slug+".md")] = paths
+	}
+	return slugCollisions(addresses)
+}
+
+// Collisions returns the addresses more than one post answers to, as of the
+// last scan
+func (idx *PostIndex) Collisions() []SlugCollision {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.collisions
+}
+
+// sectionCollisions returns the addresses more than one entry of a section
+// answers to
+func sectionCollisions() ([]SlugCollision, error) {
+	var out []SlugCollision
+	for _, s := range sections {
+		slugs, err := s.files().List()
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		addresses := make(map[string][]string, len(slugs))
+		for _, slug := range slugs {
+			addresses[path.Join(s.Name, slug+".md")] = []string{"/" + s.Name + "/" + slug}
+		}
+		out = append(out, slugCollisions(addresses)...)
+	}
+	return out, nil
+}
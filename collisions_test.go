@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPostIndex_Collisions(t *testing.T) {
+	t.Chdir(t.TempDir())
+	os.Mkdir("posts", 0755)
+	for name, content := range map[string]string{
+		// Both are /en/posts/hello
+		"en-hello.md": "# Hello",
+		"hello.md":    "---\nlang: en\n---\n\nHello",
+		// Only the case differs
+		"Intro.md": "# Intro",
+		"intro.md": "# intro",
+		// Translations live in different trees
+		"th-walk.md": "# เดิน",
+		"en-walk.md": "# Walk",
+	} {
+		os.WriteFile(filepath.Join("posts", name), []byte(content), 0644)
+	}
+	logs := captureLogs(t)
+
+	idx := newTestPostIndex(t, "posts")
+	got := idx.Collisions()
+	want := []SlugCollision{
+		{Path: "/en/posts/hello", Files: []string{"posts/en-hello.md", "posts/hello.md"}},
+		{Path: "/en/posts/Intro", Files: []string{"posts/Intro.md", "posts/intro.md"}},
+		{Path: "/posts/Intro", Files: []string{"posts/Intro.md", "posts/intro.md"}},
+		{Path: "/th/posts/Intro", Files: []string{"posts/Intro.md", "posts/intro.md"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Collisions() = %+v, want %+v", got, want)
+	}
+	if !strings.Contains(logs.String(), "Slug collision") {
+		t.Errorf("expected the scan to warn about collisions, got %s", logs.String())
+	}
+
+	os.Remove(filepath.Join("posts", "hello.md"))
+	os.Remove(filepath.Join("posts", "Intro.md"))
+	if err := idx.Scan(); err != nil {
+		t.Fatal(err)
+	}
+	if got := idx.Collisions(); len(got) != 0 {
+		t.Errorf("expected no collisions after the rescan, got %+v", got)
+	}
+}
+
+func TestSectionCollisions(t *testing.T) {
+	t.Chdir(t.TempDir())
+	os.Mkdir(notesDir, 0755)
+	for _, name := range []string{"Coffee.md", "coffee.md", "tea.md"} {
+		os.WriteFile(filepath.Join(notesDir, name), []byte("Hi"), 0644)
+	}
+
+	got, err := sectionCollisions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || !reflect.DeepEqual(got[0].Files, []string{"notes/Coffee.md", "notes/coffee.md"}) {
+		t.Errorf("sectionCollisions() = %+v, want the two coffee notes", got)
+	}
+	if s := got[0].String(); !strings.HasSuffix(s, ": slug collision between notes/Coffee.md, notes/coffee.md") {
+		t.Errorf("unexpected description %q", s)
+	}
+}
@@ -0,0 +1,505 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Comment statuses
+const (
+	CommentPending  = "pending"
+	CommentApproved = "approved"
+	CommentSpam     = "spam"
+)
+
+// Comment is a reader comment on a post
+type Comment struct {
+	ID   int64
+	Slug string
+	// ParentID is the comment this one replies to, or 0 for a top-level comment
+	ParentID int64
+	Name     string
+	Email    string
+	Body     string
+	Status   string
+	// IP and UserAgent identify the submitter for spam checks; they are never shown
+	IP        string
+	UserAgent string
+	// IsAuthor marks comments posted by the site author while logged in as admin
+	IsAuthor  bool
+	CreatedAt time.Time
+}
+
+// CommentStore persists post comments
+type CommentStore struct {
+	db *sql.DB
+}
+
+const commentsSchema = `CREATE TABLE IF NOT EXISTS comments (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	slug TEXT NOT NULL,
+	name TEXT NOT NULL,
+	email TEXT NOT NULL,
+	body TEXT NOT NULL,
+	status TEXT NOT NULL DEFAULT 'pending',
+	created_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS comments_slug ON comments (slug, status);`
+
+// NewCommentStore creates the comments table if needed
+func NewCommentStore(db *sql.DB) (*CommentStore, error) {
+	if _, err := db.Exec(commentsSchema); err != nil {
+		return nil, fmt.Errorf("create comments table: %w", err)
+	}
+	columns := []struct{ name, decl string }{
+		{"ip", "TEXT NOT NULL DEFAULT ''"},
+		{"user_agent", "TEXT NOT NULL DEFAULT ''"},
+		{"parent_id", "INTEGER NOT NULL DEFAULT 0"},
+		{"is_author", "INTEGER NOT NULL DEFAULT 0"},
+	}
+	for _, col := range columns {
+		if err := addColumn(db, "comments", col.name, col.decl); err != nil {
+			return nil, err
+		}
+	}
+	return &CommentStore{db: db}, nil
+}
+
+// Save stores a new comment and fills in its ID
+func (s *CommentStore) Save(c *Comment) error {
+	if c.CreatedAt.IsZero() {
+		c.CreatedAt = time.Now()
+	}
+	if c.Status == "" {
+		c.Status = CommentPending
+	}
+	res, err := s.db.Exec(`INSERT INTO comments (slug, parent_id, name, email, body, status, ip, user_agent, is_author, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		c.Slug, c.ParentID, c.Name, c.Email, c.Body, c.Status, c.IP, c.UserAgent, c.IsAuthor, c.CreatedAt.Unix())
+	if err != nil {
+		return fmt.Errorf("save comment: %w", err)
+	}
+	c.ID, _ = res.LastInsertId()
+	return nil
+}
+
+// Approved returns the published comments on a post, oldest first
+func (s *CommentStore) Approved(slug string) ([]Comment, error) {
+	return s.query(`WHERE slug = ? AND status = ? ORDER BY id`, slug, CommentApproved)
+}
+
+// Get returns a single comment
+func (s *CommentStore) Get(id int64) (Comment, error) {
+	comments, err := s.query(`WHERE id = ?`, id)
+	if err != nil {
+		return Comment{}, err
+	}
+	if len(comments) == 0 {
+		return Comment{}, sql.ErrNoRows
+	}
+	return comments[0], nil
+}
+
+// ApprovedCounts returns the number of published comments per post slug
+func (s *CommentStore) ApprovedCounts() (map[string]int, error) {
+	rows, err := s.db.Query(`SELECT slug, COUNT(*) FROM comments WHERE status = ? GROUP BY slug`, CommentApproved)
+	if err != nil {
+		return nil, fmt.Errorf("count comments: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var slug string
+		var n int
+		if err := rows.Scan(&slug, &n); err != nil {
+			return nil, fmt.Errorf("count comments: %w", err)
+		}
+		counts[slug] = n
+	}
+	return counts, rows.Err()
+}
+
+// ByStatus returns all comments with the given status, newest first
+func (s *CommentStore) ByStatus(status string) ([]Comment, error) {
+	return s.query(`WHERE status = ? ORDER BY id DESC`, status)
+}
+
+// query lists comments matching a WHERE clause
+func (s *CommentStore) query(where string, args ...any) ([]Comment, error) {
+	rows, err := s.db.Query(`SELECT id, slug, parent_id, name, email, body, status, ip, user_agent, is_author, created_at
+		FROM comments `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list comments: %w", err)
+	}
+	defer rows.Close()
+
+	var comments []Comment
+	for rows.Next() {
+		var c Comment
+		var createdAt int64
+		if err := rows.Scan(&c.ID, &c.Slug, &c.ParentID, &c.Name, &c.Email, &c.Body, &c.Status, &c.IP, &c.UserAgent, &c.IsAuthor, &createdAt); err != nil {
+			return nil, fmt.Errorf("list comments: %w", err)
+		}
+		c.CreatedAt = time.Unix(createdAt, 0)
+		comments = append(comments, c)
+	}
+	return comments, rows.Err()
+}
+
+// SetStatus moves a comment to approved, pending, or spam
+func (s *CommentStore) SetStatus(id int64, status string) error {
+	res, err := s.db.Exec(`UPDATE comments SET status = ? WHERE id = ?`, status, id)
+	if err != nil {
+		return fmt.Errorf("update comment %d: %w", id, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Delete removes a comment permanently
+func (s *CommentStore) Delete(id int64) error {
+	res, err := s.db.Exec(`DELETE FROM comments WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("delete comment %d: %w", id, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SpamCountByIP returns how many comments from an address were marked as spam
+func (s *CommentStore) SpamCountByIP(ip string) (int, error) {
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM comments WHERE ip = ? AND status = ?`, ip, CommentSpam).Scan(&n)
+	return n, err
+}
+
+// PendingCount returns the number of comments awaiting moderation
+func (s *CommentStore) PendingCount() (int, error) {
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM comments WHERE status = ?`, CommentPending).Scan(&n)
+	return n, err
+}
+
+// validateComment checks the submitted fields, returning a user-facing error
+func validateComment(c Comment) string {
+	if c.Name == "" || c.Body == "" {
+		return "Please enter your name and a comment."
+	}
+	if utf8.RuneCountInString(c.Name) > 100 || utf8.RuneCountInString(c.Body) > 5000 {
+		return "Your comment is too long."
+	}
+	if c.Email != "" {
+		if addr, err := mail.ParseAddress(c.Email); err != nil || addr.Address != c.Email {
+			return "Please enter a valid email address."
+		}
+	}
+	return ""
+}
+
+// CommentSubmitHandler stores a comment on a post, holding it for moderation unless
+// the config says otherwise, and queues an email notification. When a spam filter is
+// given, suspect comments are always held and likely spam goes straight to the spam list.
+// Comments from a logged-in admin are published immediately and marked as the author's.
+// Others are limited per client IP by limiter, when given, and a filled-in honeypot
+// field drops the comment.
+func CommentSubmitHandler(store *CommentStore, sl SlugReader, queue *JobQueue, emails *EmailRenderer, notifyTo string, spam *SpamFilter, limiter *RateLimiter, auth *AdminAuth) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setSecurityHeaders(w)
+
+		slug := r.PathValue("slug")
+		if !IsValidSlug(slug) {
+			http.Error(w, "Invalid post slug", http.StatusBadRequest)
+			return
+		}
+		postMarkdown, err := sl.Read(slug)
+		if err != nil {
+			http.Error(w, "Post not found", http.StatusNotFound)
+			return
+		}
+		fm, _ := ParseFrontmatter(postMarkdown)
+		if !postVisible(fm) {
+			http.Error(w, "Post not found", http.StatusNotFound)
+			return
+		}
+		if fm.CommentsLocked {
+			http.Error(w, "Comments are closed on this post", http.StatusForbidden)
+			return
+		}
+
+		c := Comment{
+			Slug:      slug,
+			Name:      strings.TrimSpace(r.PostFormValue("name")),
+			Email:     strings.TrimSpace(r.PostFormValue("email")),
+			Body:      strings.TrimSpace(r.PostFormValue("body")),
+			Status:    CommentApproved,
+			IP:        clientIP(r),
+			UserAgent: r.UserAgent(),
+		}
+		isAdmin := auth != nil && auth.IsAdmin(r)
+		if !isAdmin {
+			// Only bots fill in the hidden field; they get the same answer as
+			// held comments so they learn nothing
+			if r.PostFormValue(commentHoneypot) != "" {
+				http.Redirect(w, r, "/posts/"+slug+"?comment="+CommentPending+"#comments", http.StatusSeeOther)
+				return
+			}
+			if limiter != nil && !limiter.Allow(c.IP) {
+				http.Error(w, "Too many comments, please try again later", http.StatusTooManyRequests)
+				return
+			}
+		}
+		if cfg.Comments.Moderate {
+			c.Status = CommentPending
+		}
+		if errMsg := validateComment(c); errMsg != "" {
+			http.Error(w, errMsg, http.StatusBadRequest)
+			return
+		}
+
+		if parent := r.PostFormValue("parent_id"); parent != "" {
+			id, err := strconv.ParseInt(parent, 10, 64)
+			if err != nil {
+				http.Error(w, "Invalid reply", http.StatusBadRequest)
+				return
+			}
+			p, err := store.Get(id)
+			if err != nil || p.Slug != slug || p.Status != CommentApproved {
+				http.Error(w, "The comment you replied to no longer exists", http.StatusBadRequest)
+				return
+			}
+			// Replies are one level deep; answering a reply joins its thread
+			c.ParentID = p.ID
+			if p.ParentID != 0 {
+				c.ParentID = p.ParentID
+			}
+		}
+
+		if isAdmin {
+			c.IsAuthor = true
+			c.Status = CommentApproved
+		} else if spam != nil {
+			switch spam.Classify(r.Context(), c) {
+			case SpamLikely:
+				c.Status = CommentSpam
+			case SpamSuspect:
+				c.Status = CommentPending
+			}
+		}
+
+		if err := store.Save(&c); err != nil {
+			slog.Error("Error saving comment", "slug", slug, "err", err)
+			http.Error(w, "Could not save comment", http.StatusInternalServerError)
+			return
+		}
+
+		if notifyTo != "" && c.Status != CommentSpam && !c.IsAuthor {
+			rendered, err := emails.Render("comment_notification", "en", c)
+			if err != nil {
+				slog.Error("Error rendering comment notification", "err", err)
+			} else {
+				email := rendered.Message(notifyTo)
+				email.ReplyTo = c.Email
+				if err := queue.Enqueue("email", email); err != nil {
+					slog.Error("Error queueing comment notification", "err", err)
+				}
+			}
+		}
+
+		// Spam is reported as pending so spammers get no signal about the filter
+		notice := c.Status
+		if notice == CommentSpam {
+			notice = CommentPending
+		}
+		http.Redirect(w, r, "/posts/"+slug+"?comment="+notice+"#comments", http.StatusSeeOther)
+	}
+}
+
+// commentsSectionHTML renders the published comments on a post and, unless comments
+// are locked, the form for adding one. notice is the status of a just-submitted comment.
+func commentsSectionHTML(slug string, comments []Comment, locked bool, notice string) string {
+	var b strings.Builder
+	b.WriteString("<section class=\"comments\" id=\"comments\">\n")
+	b.WriteString("<h2>Comments (" + strconv.Itoa(len(comments)) + ")</h2>\n")
+	b.WriteString("<p class=\"comments-feed\"><a href=\"/posts/" + template.HTMLEscapeString(slug) + "/comments/feed.xml\">Follow comments (RSS)</a></p>\n")
+
+	switch notice {
+	case CommentPending:
+		b.WriteString("<p class=\"form-success\">Thanks! Your comment will appear once it has been approved.</p>\n")
+	case CommentApproved:
+		b.WriteString("<p class=\"form-success\">Thanks for your comment!</p>\n")
+	}
+
+	replies := make(map[int64][]Comment)
+	for _, c := range comments {
+		if c.ParentID != 0 {
+			replies[c.ParentID] = append(replies[c.ParentID], c)
+		}
+	}
+
+	for _, c := range comments {
+		if c.ParentID != 0 {
+			continue
+		}
+		b.WriteString(commentHTML(c))
+		if thread := replies[c.ID]; len(thread) > 0 || !locked {
+			b.WriteString("<div class=\"comment-replies\">\n")
+			for _, reply := range thread {
+				b.WriteString(commentHTML(reply))
+			}
+			if !locked {
+				b.WriteString("<details class=\"comment-reply\"><summary>Reply</summary>\n")
+				b.WriteString(commentFormHTML(slug, c.ID))
+				b.WriteString("</details>\n")
+			}
+			b.WriteString("</div>\n")
+		}
+	}
+
+	if locked {
+		b.WriteString("<p class=\"comments-locked\">Comments are closed.</p>\n")
+	} else {
+		b.WriteString(commentFormHTML(slug, 0))
+	}
+	b.WriteString("</section>\n")
+	return b.String()
+}
+
+// commentHTML renders a single published comment
+func commentHTML(c Comment) string {
+	class := "comment"
+	if c.IsAuthor {
+		class += " comment-author"
+	}
+	var b strings.Builder
+	b.WriteString("<div class=\"" + class + "\" id=\"comment-" + strconv.FormatInt(c.ID, 10) + "\">\n")
+	b.WriteString("<div class=\"comment-meta\"><strong>" + template.HTMLEscapeString(c.Name) + "</strong> ")
+	if c.IsAuthor {
+		b.WriteString("<span class=\"author-badge\">Author</span> ")
+	}
+	b.WriteString("<span class=\"post-date\">" + c.CreatedAt.Format("Jan 2, 2006") + "</span></div>\n")
+	b.WriteString("<p>" + strings.ReplaceAll(template.HTMLEscapeString(c.Body), "\n", "<br>") + "</p>\n")
+	b.WriteString("</div>\n")
+	return b.String()
+}
+
+// commentHoneypot is a comment form field hidden from people. Anything in it
+// was filled in by a bot.
+const commentHoneypot = "website"
+
+// commentFormHTML renders the form for a new comment, or a reply when parentID is set
+func commentFormHTML(slug string, parentID int64) string {
+	var b strings.Builder
+	b.WriteString(`<form method="post" action="/posts/` + template.HTMLEscapeString(slug) + `/comments" class="contact-form comment-form">` + "\n")
+	if parentID != 0 {
+		b.WriteString(`	<input type="hidden" name="parent_id" value="` + strconv.FormatInt(parentID, 10) + `">` + "\n")
+	}
+	b.WriteString(`	<label>Name <input type="text" name="name" maxlength="100" required></label>
+	<label>Email (optional, never shown) <input type="email" name="email"></label>
+	<label>Comment <textarea name="body" rows="5" maxlength="5000" required></textarea></label>
+	<div class="comment-honeypot" aria-hidden="true"><label>Leave this empty <input type="text" name="` + commentHoneypot + `" tabindex="-1" autocomplete="off"></label></div>
+	<button type="submit" class="modal-btn">Post comment</button>
+</form>
+`)
+	return b.String()
+}
+
+// AdminCommentsHandler lists comments awaiting moderation, or those marked as spam
+// with ?status=spam
+func AdminCommentsHandler(store *CommentStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := CommentPending
+		if r.URL.Query().Get("status") == CommentSpam {
+			status = CommentSpam
+		}
+
+		comments, err := store.ByStatus(status)
+		if err != nil {
+			slog.Error("Error listing comments", "err", err)
+			http.Error(w, "Could not list comments", http.StatusInternalServerError)
+			return
+		}
+
+		var content bytes.Buffer
+		content.WriteString("<div class=\"admin-page\">\n")
+		content.WriteString(adminNav())
+		if status == CommentSpam {
+			content.WriteString("<h1>Spam</h1>\n")
+			content.WriteString("<p><a href=\"/admin/comments\">Back to moderation queue</a></p>\n")
+		} else {
+			content.WriteString("<h1>Comments</h1>\n")
+			content.WriteString("<p><a href=\"/admin/comments?status=spam\">View spam</a></p>\n")
+		}
+		if len(comments) == 0 {
+			content.WriteString("<p>Nothing to moderate.</p>\n")
+		}
+		for _, c := range comments {
+			id := strconv.FormatInt(c.ID, 10)
+			content.WriteString("<div class=\"inbox-message\">\n")
+			content.WriteString("<div class=\"inbox-meta\"><strong>" + template.HTMLEscapeString(c.Name) + "</strong> ")
+			if c.Email != "" {
+				content.WriteString("&lt;" + template.HTMLEscapeString(c.Email) + "&gt; ")
+			}
+			content.WriteString("on <a href=\"/posts/" + template.HTMLEscapeString(c.Slug) + "\">" + template.HTMLEscapeString(c.Slug) + "</a> ")
+			content.WriteString("<span class=\"post-date\">" + c.CreatedAt.Format("Jan 2, 2006 15:04") + "</span></div>\n")
+			content.WriteString("<p>" + strings.ReplaceAll(template.HTMLEscapeString(c.Body), "\n", "<br>") + "</p>\n")
+			content.WriteString("<div class=\"admin-actions\">")
+			content.WriteString("<form method=\"post\" action=\"/admin/comments/" + id + "/approve\"><button type=\"submit\">Approve</button></form>")
+			if status != CommentSpam {
+				content.WriteString("<form method=\"post\" action=\"/admin/comments/" + id + "/spam\"><button type=\"submit\">Spam</button></form>")
+			}
+			content.WriteString("<form method=\"post\" action=\"/admin/comments/" + id + "/delete\"><button type=\"submit\">Delete</button></form>")
+			content.WriteString("</div>\n</div>\n")
+		}
+		content.WriteString("</div>")
+
+		renderPage(w, "Comments", template.HTML(content.String()))
+	}
+}
+
+// AdminCommentActionHandler approves, deletes, or marks a comment as spam
+func AdminCommentActionHandler(store *CommentStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid comment ID", http.StatusBadRequest)
+			return
+		}
+
+		switch r.PathValue("action") {
+		case "approve":
+			err = store.SetStatus(id, CommentApproved)
+		case "spam":
+			err = store.SetStatus(id, CommentSpam)
+		case "delete":
+			err = store.Delete(id)
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		if err == sql.ErrNoRows {
+			http.Error(w, "Comment not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.Error("Error updating comment", "id", id, "err", err)
+			http.Error(w, "Could not update comment", http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, "/admin/comments", http.StatusSeeOther)
+	}
+}
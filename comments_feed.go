@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/xml"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// commentFeedSize is how many of the newest comments a feed lists
+const commentFeedSize = 50
+
+// rssFeed is the root of an RSS 2.0 document
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	DC      string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	Language    string    `xml:"language,omitempty"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title   string  `xml:"title"`
+	Link    string  `xml:"link"`
+	GUID    rssGUID `xml:"guid"`
+	PubDate string  `xml:"pubDate,omitempty"`
+	Author  string  `xml:"dc:creator,omitempty"`
+	// Language is the item's own language, for feeds mixing languages
+	Language    string `xml:"dc:language,omitempty"`
+	Description string `xml:"description"`
+	// Enclosures are files attached to the item, such as a post's downloads
+	Enclosures []rssEnclosure `xml:"enclosure"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+type rssGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+// siteURL returns the scheme and host the request was made to, plus the path
+// prefix when a proxy mounts the site under one and the language segment when
+// the page was requested under one
+func siteURL(r *http.Request) string {
+	scheme := "http"
+	if requestIsHTTPS(r) {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + forwardedPrefix(r) + langPathPrefix(r)
+}
+
+// RecentApproved returns the newest published comments, on one post or, with an
+// empty slug, across the site
+func (s *CommentStore) RecentApproved(slug string, limit int) ([]Comment, error) {
+	if slug == "" {
+		return s.query(`WHERE status = ? ORDER BY id DESC LIMIT ?`, CommentApproved, limit)
+	}
+	return s.query(`WHERE slug = ? AND status = ? ORDER BY id DESC LIMIT ?`, slug, CommentApproved, limit)
+}
+
+// CommentsFeedHandler serves an RSS feed of new comments, site-wide or for the post
+// named by the slug path value
+func CommentsFeedHandler(store *CommentStore, sl SlugReader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setSecurityHeaders(w)
+		markNoIndex(w, PageFeed)
+
+		slug := r.PathValue("slug")
+		base := siteURL(r)
+		channel := rssChannel{
+			Title:       cfg.Site.Name + ": Comments",
+			Link:        base + "/",
+			Description: "New comments on " + cfg.Site.Name,
+		}
+
+		titles := make(map[string]string)
+		title := func(slug string) string {
+			if t, ok := titles[slug]; ok {
+				return t
+			}
+			t := toTitleCase(strings.ReplaceAll(slug, "-", " "))
+			if md, err := sl.Read(slug); err == nil {
+				if fm, _ := ParseFrontmatter(md); fm.Title != "" {
+					t = fm.Title
+				}
+			}
+			titles[slug] = t
+			return t
+		}
+
+		if slug != "" {
+			if !IsValidSlug(slug) {
+				http.Error(w, "Invalid post slug", http.StatusBadRequest)
+				return
+			}
+			if _, err := sl.Read(slug); err != nil {
+				http.Error(w, "Post not found", http.StatusNotFound)
+				return
+			}
+			channel.Title = cfg.Site.Name + ": Comments on " + title(slug)
+			channel.Link = base + "/posts/" + slug
+			channel.Description = "New comments on " + title(slug)
+		}
+
+		comments, err := store.RecentApproved(slug, commentFeedSize)
+		if err != nil {
+			slog.Error("Error loading comments feed", "err", err)
+			http.Error(w, "Could not load comments", http.StatusInternalServerError)
+			return
+		}
+
+		for _, c := range comments {
+			link := base + "/posts/" + c.Slug + "#comment-" + strconv.FormatInt(c.ID, 10)
+			channel.Items = append(channel.Items, rssItem{
+				Title:       c.Name + " on " + title(c.Slug),
+				Link:        link,
+				GUID:        rssGUID{Value: link, IsPermaLink: true},
+				PubDate:     c.CreatedAt.UTC().Format(time.RFC1123Z),
+				Author:      c.Name,
+				Description: c.Body,
+			})
+		}
+
+		out, err := xml.MarshalIndent(rssFeed{Version: "2.0", DC: "http://purl.org/dc/elements/1.1/", Channel: channel}, "", "  ")
+		if err != nil {
+			slog.Error("Error encoding comments feed", "err", err)
+			http.Error(w, "Could not render feed", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		w.Write([]byte(xml.Header))
+		w.Write(out)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCommentsFeedHandler(t *testing.T) {
+	store, _ := newTestCommentStore(t)
+	comments := []Comment{
+		{Slug: "hello", Name: "Alice", Body: "First <3", Status: CommentApproved},
+		{Slug: "other", Name: "Bob", Body: "Elsewhere", Status: CommentApproved},
+		{Slug: "hello", Name: "Spammer", Body: "buy now", Status: CommentSpam},
+		{Slug: "hello", Name: "Carol", Body: "Waiting", Status: CommentPending},
+	}
+	for i := range comments {
+		if err := store.Save(&comments[i]); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+	reader := &MockSlugReader{content: map[string]string{
+		"hello": "---\ntitle: Hello World\n---\n\nHi",
+		"other": "Other",
+	}}
+	handler := CommentsFeedHandler(store, reader)
+
+	tests := []struct {
+		name       string
+		slug       string
+		wantStatus int
+		wantTitles []string
+	}{
+		{"site-wide", "", http.StatusOK, []string{"Bob on Other", "Alice on Hello World"}},
+		{"single post", "hello", http.StatusOK, []string{"Alice on Hello World"}},
+		{"missing post", "nope", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://blog.example/comments/feed.xml", nil)
+			req.SetPathValue("slug", tt.slug)
+			w := httptest.NewRecorder()
+			handler(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/rss+xml") {
+				t.Errorf("unexpected Content-Type %q", ct)
+			}
+
+			var feed rssFeed
+			if err := xml.Unmarshal(w.Body.Bytes(), &feed); err != nil {
+				t.Fatalf("invalid feed XML: %v\n%s", err, w.Body.String())
+			}
+			var titles []string
+			for _, item := range feed.Channel.Items {
+				titles = append(titles, item.Title)
+			}
+			if strings.Join(titles, "|") != strings.Join(tt.wantTitles, "|") {
+				t.Errorf("expected items %q, got %q", tt.wantTitles, titles)
+			}
+			if item := feed.Channel.Items[len(feed.Channel.Items)-1]; item.Link != "http://blog.example/posts/hello#comment-1" || item.Description != "First <3" {
+				t.Errorf("unexpected item %+v", item)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestCommentStore(t *testing.T) (*CommentStore, *JobQueue) {
+	t.Helper()
+	db := newTestDB(t)
+	store, err := NewCommentStore(db)
+	if err != nil {
+		t.Fatalf("NewCommentStore: %v", err)
+	}
+	queue, err := NewJobQueue(db)
+	if err != nil {
+		t.Fatalf("NewJobQueue: %v", err)
+	}
+	return store, queue
+}
+
+func TestCommentSubmitHandler_HeldForModeration(t *testing.T) {
+	store, queue := newTestCommentStore(t)
+	var sent EmailMessage
+	queue.Register("email", func(ctx context.Context, payload []byte) error {
+		return json.Unmarshal(payload, &sent)
+	})
+
+	reader := &MockSlugReader{content: map[string]string{"hello": "# Hello"}}
+	handler := CommentSubmitHandler(store, reader, queue, NewEmailRenderer("templates/email"), "owner@example.com", nil, nil, nil)
+
+	req := postForm("/posts/hello/comments", url.Values{"name": {"Reader"}, "body": {"Nice <b>post</b>"}})
+	req.SetPathValue("slug", "hello")
+	w := httptest.NewRecorder()
+	handler(w, req)
+
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("expected status 303, got %d: %s", w.Code, w.Body.String())
+	}
+	if loc := w.Header().Get("Location"); loc != "/posts/hello?comment=pending#comments" {
+		t.Errorf("unexpected redirect %q", loc)
+	}
+
+	approved, _ := store.Approved("hello")
+	if len(approved) != 0 {
+		t.Errorf("expected no published comments before approval, got %+v", approved)
+	}
+	pending, _ := store.ByStatus(CommentPending)
+	if len(pending) != 1 {
+		t.Fatalf("expected one pending comment, got %+v", pending)
+	}
+
+	if _, err := queue.RunOnce(context.Background()); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if !strings.Contains(sent.Subject, "hello") || !strings.Contains(sent.Body, "waiting for approval") {
+		t.Errorf("unexpected notification %+v", sent)
+	}
+
+	// Approving publishes it on the post
+	admin := httptest.NewRequest("POST", "/admin/comments/1/approve", nil)
+	admin.SetPathValue("id", "1")
+	admin.SetPathValue("action", "approve")
+	w = httptest.NewRecorder()
+	AdminCommentActionHandler(store)(w, admin)
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("expected status 303, got %d", w.Code)
+	}
+
+	postReq := httptest.NewRequest("GET", "/posts/hello", nil)
+	postReq.SetPathValue("slug", "hello")
+	w = httptest.NewRecorder()
+	PostHandler(reader, store, nil)(w, postReq)
+	body := w.Body.String()
+	if !strings.Contains(body, "Nice &lt;b&gt;post&lt;/b&gt;") {
+		t.Errorf("expected escaped comment on post page, got %s", body)
+	}
+}
+
+func TestCommentSubmitHandler_Locked(t *testing.T) {
+	store, queue := newTestCommentStore(t)
+	reader := &MockSlugReader{content: map[string]string{
+		"closed": "---\ntitle: Closed\ncomments_locked: true\n---\n\nBody",
+	}}
+
+	req := postForm("/posts/closed/comments", url.Values{"name": {"Reader"}, "body": {"Hi"}})
+	req.SetPathValue("slug", "closed")
+	w := httptest.NewRecorder()
+	CommentSubmitHandler(store, reader, queue, NewEmailRenderer("templates/email"), "", nil, nil, nil)(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403, got %d", w.Code)
+	}
+
+	postReq := httptest.NewRequest("GET", "/posts/closed", nil)
+	postReq.SetPathValue("slug", "closed")
+	w = httptest.NewRecorder()
+	PostHandler(reader, store, nil)(w, postReq)
+	if body := w.Body.String(); strings.Contains(body, "comment-form") || !strings.Contains(body, "Comments are closed") {
+		t.Errorf("expected locked post to hide the comment form, got %s", body)
+	}
+}
+
+func TestAdminCommentActionHandler(t *testing.T) {
+	store, _ := newTestCommentStore(t)
+	c := Comment{Slug: "hello", Name: "Bot", Body: "buy now"}
+	if err := store.Save(&c); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		id         string
+		action     string
+		wantStatus int
+	}{
+		{"spam", "1", "spam", http.StatusSeeOther},
+		{"unknown action", "1", "publish", http.StatusNotFound},
+		{"invalid id", "abc", "approve", http.StatusBadRequest},
+		{"delete", "1", "delete", http.StatusSeeOther},
+		{"missing comment", "1", "approve", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/admin/comments/"+tt.id+"/"+tt.action, nil)
+			req.SetPathValue("id", tt.id)
+			req.SetPathValue("action", tt.action)
+			w := httptest.NewRecorder()
+			AdminCommentActionHandler(store)(w, req)
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestCommentSubmitHandler_Replies(t *testing.T) {
+	store, queue := newTestCommentStore(t)
+	reader := &MockSlugReader{content: map[string]string{"hello": "# Hello", "other": "# Other"}}
+
+	origModerate := cfg.Comments.Moderate
+	cfg.Comments.Moderate = false
+	defer func() { cfg.Comments.Moderate = origModerate }()
+
+	auth := NewAdminAuth("admin", "secret", "key")
+	handler := CommentSubmitHandler(store, reader, queue, NewEmailRenderer("templates/email"), "", nil, nil, auth)
+	submit := func(slug string, form url.Values, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := postForm("/posts/"+slug+"/comments", form)
+		req.SetPathValue("slug", slug)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	submit("hello", url.Values{"name": {"Reader"}, "body": {"Question?"}})
+	submit("hello", url.Values{"name": {"Kenn"}, "body": {"Answer!"}, "parent_id": {"1"}}, loginCookie(t, auth))
+	// A reply to a reply joins the top-level thread
+	submit("hello", url.Values{"name": {"Reader"}, "body": {"Thanks"}, "parent_id": {"2"}})
+
+	if w := submit("other", url.Values{"name": {"Reader"}, "body": {"Hi"}, "parent_id": {"1"}}); w.Code != http.StatusBadRequest {
+		t.Errorf("expected replying across posts to fail with 400, got %d", w.Code)
+	}
+
+	comments, err := store.Approved("hello")
+	if err != nil || len(comments) != 3 {
+		t.Fatalf("expected 3 comments, got %+v (%v)", comments, err)
+	}
+	if comments[1].ParentID != 1 || !comments[1].IsAuthor {
+		t.Errorf("expected author reply to comment 1, got %+v", comments[1])
+	}
+	if comments[2].ParentID != 1 || comments[2].IsAuthor {
+		t.Errorf("expected reader reply flattened to comment 1, got %+v", comments[2])
+	}
+
+	html := commentsSectionHTML("hello", comments, false, "")
+	if !strings.Contains(html, `class="comment comment-author" id="comment-2"`) || !strings.Contains(html, "author-badge") {
+		t.Errorf("expected the author's reply to be highlighted, got %s", html)
+	}
+	if strings.Count(html, `name="parent_id" value="1"`) != 1 {
+		t.Errorf("expected one reply form for the thread, got %s", html)
+	}
+}
+
+func TestCommentSubmitHandler_SpamGuards(t *testing.T) {
+	store, queue := newTestCommentStore(t)
+	reader := &MockSlugReader{content: map[string]string{"hello": "# Hello"}}
+	handler := CommentSubmitHandler(store, reader, queue, NewEmailRenderer("templates/email"), "", nil, NewRateLimiter(2, time.Hour), nil)
+	submit := func(form url.Values) *httptest.ResponseRecorder {
+		req := postForm("/posts/hello/comments", form)
+		req.SetPathValue("slug", "hello")
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	// A filled-in honeypot looks accepted but stores nothing
+	w := submit(url.Values{"name": {"Bot"}, "body": {"Buy now"}, commentHoneypot: {"https://spam.example"}})
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/posts/hello?comment=pending#comments" {
+		t.Errorf("expected the honeypot to answer like a held comment, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	for _, status := range []string{CommentApproved, CommentPending, CommentSpam} {
+		if got, _ := store.ByStatus(status); len(got) != 0 {
+			t.Errorf("expected no %s comments from the honeypot, got %+v", status, got)
+		}
+	}
+
+	for i, want := range []int{http.StatusSeeOther, http.StatusSeeOther, http.StatusTooManyRequests} {
+		if w := submit(url.Values{"name": {"Reader"}, "body": {"Hi"}}); w.Code != want {
+			t.Errorf("comment %d: expected status %d, got %d", i+1, want, w.Code)
+		}
+	}
+}
+
+func TestHomeHandler_CommentCounts(t *testing.T) {
+	store, _ := newTestCommentStore(t)
+	for _, status := range []string{CommentApproved, CommentApproved, CommentPending} {
+		store.Save(&Comment{Slug: "en-hello-world", Name: "Reader", Body: "Hi", Status: status})
+	}
+
+	t.Chdir(t.TempDir())
+	os.Mkdir("posts", 0755)
+	os.WriteFile(filepath.Join("posts", "en-hello-world.md"), []byte("---\ntitle: Hello\n---\n\nHi"), 0644)
+
+	req := httptest.NewRequest("GET", "/?lang=en", nil)
+	w := httptest.NewRecorder()
+	HomeHandler(newTestPostIndex(t, "posts"), store)(w, req)
+
+	if !strings.Contains(w.Body.String(), `href="/posts/en-hello-world#comments">💬 2</a>`) {
+		t.Errorf("expected approved comment count on the homepage, got %s", w.Body.String())
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// compressMinSize is the smallest response worth compressing; below it the
+// encoding overhead outweighs the saving
+const compressMinSize = 1024
+
+// compressibleTypes are the media types worth compressing: pages, styles,
+// scripts, feeds, and other text
+var compressibleTypes = map[string]bool{
+	"text/html":              true,
+	"text/css":               true,
+	"text/javascript":        true,
+	"text/plain":             true,
+	"text/xml":               true,
+	"text/markdown":          true,
+	"application/javascript": true,
+	"application/json":       true,
+	"application/x-ndjson":   true,
+	"application/xml":        true,
+	"application/atom+xml":   true,
+	"application/rss+xml":    true,
+	"application/feed+json":  true,
+	"image/svg+xml":          true,
+}
+
+// compressible reports whether a response of this Content-Type should be compressed
+func compressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	return compressibleTypes[strings.ToLower(strings.TrimSpace(mediaType))]
+}
+
+// acceptedEncodings returns the encodings among br and gzip the client
+// accepts, in order of preference
+func acceptedEncodings(r *http.Request) []string {
+	q := map[string]float64{}
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		weight := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				weight = f
+			}
+		}
+		q[strings.ToLower(name)] = weight
+	}
+	var encodings []string
+	for _, enc := range []string{"br", "gzip"} {
+		w, ok := q[enc]
+		if !ok {
+			w, ok = q["*"]
+		}
+		if ok && w > 0 {
+			encodings = append(encodings, enc)
+		}
+	}
+	// br is preferred when both are equally welcome, gzip when it's ranked higher
+	if len(encodings) == 2 && q["gzip"] > q["br"] {
+		encodings[0], encodings[1] = "gzip", "br"
+	}
+	return encodings
+}
+
+var gzipWriters = sync.Pool{New: func() any {
+	w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+	return w
+}}
+
+var brotliWriters = sync.Pool{New: func() any {
+	// Level 5 compresses better than gzip at a similar speed
+	return brotli.NewWriterLevel(nil, 5)
+}}
+
+// encoder is a pooled compressor
+type encoder interface {
+	io.WriteCloser
+	Reset(io.Writer)
+}
+
+// compressWriter compresses the response body when its type and size call for
+// it, deciding when the header is written
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	head        bool
+	wroteHeader bool
+	enc         encoder
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	h := cw.Header()
+	ct := h.Get("Content-Type")
+	if !compressible(ct) {
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	addVary(h, "Accept-Encoding")
+	size, err := strconv.Atoi(h.Get("Content-Length"))
+	small := err == nil && size < compressMinSize
+	switch {
+	case small, h.Get("Content-Encoding") != "",
+		status == http.StatusNoContent, status == http.StatusNotModified, status == http.StatusPartialContent,
+		status < 200:
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	h.Del("Content-Length")
+	h.Del("Accept-Ranges")
+	h.Set("Content-Encoding", cw.encoding)
+	// The bytes differ from the uncompressed response, so its ETag only still
+	// holds as a weak one
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		h.Set("ETag", "W/"+etag)
+	}
+	cw.ResponseWriter.WriteHeader(status)
+	if cw.head {
+		return
+	}
+	if cw.encoding == "br" {
+		cw.enc = brotliWriters.Get().(*brotli.Writer)
+	} else {
+		cw.enc = gzipWriters.Get().(*gzip.Writer)
+	}
+	cw.enc.Reset(cw.ResponseWriter)
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		if cw.Header().Get("Content-Type") == "" {
+			cw.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.enc != nil {
+		return cw.enc.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (cw *compressWriter) Unwrap() http.ResponseWriter { return cw.ResponseWriter }
+
+// finish flushes the compressed stream and returns the encoder to its pool
+func (cw *compressWriter) finish() {
+	if cw.enc == nil {
+		return
+	}
+	cw.enc.Close()
+	cw.enc.Reset(nil)
+	if cw.encoding == "br" {
+		brotliWriters.Put(cw.enc)
+	} else {
+		gzipWriters.Put(cw.enc)
+	}
+	cw.enc = nil
+}
+
+// Compress compresses HTML, CSS, scripts, feeds, and other text responses with
+// brotli or gzip, whichever the client prefers. Responses that already have a
+// Content-Encoding, such as precompressed static files, pass through.
+func Compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encodings := acceptedEncodings(r)
+		if len(encodings) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, encoding: encodings[0], head: r.Method == http.MethodHead}
+		defer cw.finish()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// addVary adds name to the Vary header unless it is already listed
+func addVary(h http.Header, name string) {
+	for _, v := range h.Values("Vary") {
+		for _, field := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), name) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", name)
+}
+
+// precompressedExt maps encodings to the extension of the files holding them
+var precompressedExt = map[string]string{"br": ".br", "gzip": ".gz"}
+
+// StaticFiles serves dir like http.FileServer, but sends a precompressed
+// name.br or name.gz alongside a file instead when the client accepts it and it
+// is not older than the file
+func StaticFiles(dir string) http.Handler {
+	files := http.FileServer(http.Dir(dir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean("/" + r.URL.Path)
+		orig, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil || orig.IsDir() {
+			files.ServeHTTP(w, r)
+			return
+		}
+		for _, enc := range acceptedEncodings(r) {
+			compressed := filepath.Join(dir, filepath.FromSlash(name)+precompressedExt[enc])
+			if info, err := os.Stat(compressed); err != nil || info.ModTime().Before(orig.ModTime()) {
+				continue
+			}
+			f, err := os.Open(compressed)
+			if err != nil {
+				continue
+			}
+			defer f.Close()
+			ct := mime.TypeByExtension(path.Ext(name))
+			if ct == "" {
+				ct = "application/octet-stream"
+			}
+			w.Header().Set("Content-Type", ct)
+			w.Header().Set("Content-Encoding", enc)
+			addVary(w.Header(), "Accept-Encoding")
+			http.ServeContent(w, r, name, orig.ModTime(), f)
+			return
+		}
+		files.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestAcceptedEncodings(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []string
+	}{
+		{"", nil},
+		{"gzip, deflate, br", []string{"br", "gzip"}},
+		{"gzip", []string{"gzip"}},
+		{"br;q=0.5, gzip", []string{"gzip", "br"}},
+		{"gzip;q=0, br", []string{"br"}},
+		{"*", []string{"br", "gzip"}},
+		{"identity", nil},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Encoding", tt.header)
+		if got := acceptedEncodings(r); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("acceptedEncodings(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestCompress(t *testing.T) {
+	page := strings.Repeat("<p>Hello, compression</p>\n", 100)
+	h := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/small":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("Content-Length", "5")
+			w.Write([]byte("small"))
+		case "/image.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte(page))
+		default:
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("Content-Length", strconv.Itoa(len(page)))
+			w.Header().Set("ETag", `"abc"`)
+			w.Write([]byte(page))
+		}
+	}))
+	get := func(target, accept string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", target, nil)
+		r.Header.Set("Accept-Encoding", accept)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	tests := []struct {
+		accept string
+		decode func(io.Reader) (io.Reader, error)
+	}{
+		{"gzip", func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }},
+		{"br", func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			w := get("/", tt.accept)
+			if w.Header().Get("Content-Encoding") != tt.accept || w.Header().Get("Content-Length") != "" {
+				t.Fatalf("headers = %v", w.Header())
+			}
+			if w.Header().Get("Vary") != "Accept-Encoding" || w.Header().Get("ETag") != `W/"abc"` {
+				t.Errorf("headers = %v", w.Header())
+			}
+			if w.Body.Len() >= len(page) {
+				t.Errorf("body not smaller: %d bytes", w.Body.Len())
+			}
+			r, err := tt.decode(w.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b, _ := io.ReadAll(r); string(b) != page {
+				t.Errorf("decoded body differs: %q", b)
+			}
+		})
+	}
+
+	for _, tt := range []struct{ target, accept string }{{"/", ""}, {"/small", "gzip"}, {"/image.png", "gzip"}} {
+		w := get(tt.target, tt.accept)
+		if w.Header().Get("Content-Encoding") != "" {
+			t.Errorf("%s with %q: compressed", tt.target, tt.accept)
+		}
+	}
+}
+
+func TestStaticFilesPrecompressed(t *testing.T) {
+	dir := t.TempDir()
+	css := "body { color: black }"
+	os.WriteFile(filepath.Join(dir, "style.css"), []byte(css), 0644)
+	os.WriteFile(filepath.Join(dir, "style.css.br"), []byte("brotli bytes"), 0644)
+	os.WriteFile(filepath.Join(dir, "app.js"), []byte("console.log(1)"), 0644)
+	os.WriteFile(filepath.Join(dir, "app.js.gz"), []byte("stale gzip"), 0644)
+	// A copy older than its file is out of date
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(filepath.Join(dir, "app.js.gz"), old, old)
+
+	h := http.StripPrefix("/static/", StaticFiles(dir))
+	tests := []struct {
+		target, accept, wantEncoding, wantBody string
+	}{
+		{"/static/style.css", "gzip, br", "br", "brotli bytes"},
+		{"/static/style.css", "gzip", "", css},
+		{"/static/style.css", "", "", css},
+		{"/static/app.js", "gzip", "", "console.log(1)"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.target, nil)
+		r.Header.Set("Accept-Encoding", tt.accept)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Header().Get("Content-Encoding") != tt.wantEncoding || w.Body.String() != tt.wantBody {
+			t.Errorf("%s with %q: encoding %q, body %q", tt.target, tt.accept, w.Header().Get("Content-Encoding"), w.Body.String())
+		}
+		if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/") {
+			t.Errorf("%s: Content-Type %q", tt.target, w.Header().Get("Content-Type"))
+		}
+	}
+}
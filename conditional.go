@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// bufferedResponse records a response instead of sending it
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header { return b.header }
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(p)
+}
+
+// maxPageVersions bounds the remembered page versions; past it they are
+// forgotten and pages start over with a fresh Last-Modified
+const maxPageVersions = 10000
+
+// pageVersion is the ETag a page was last served with and since when
+type pageVersion struct {
+	etag    string
+	modTime time.Time
+}
+
+// pageVersions remembers the current version of each page by language and URL,
+// so an unchanged page keeps its Last-Modified from one request to the next
+var pageVersions = struct {
+	sync.Mutex
+	entries map[string]pageVersion
+}{entries: make(map[string]pageVersion)}
+
+// pageModTime returns when the page under key last changed: the first time it
+// was served with etag, or now if etag is new
+func pageModTime(key, etag string, now time.Time) time.Time {
+	pageVersions.Lock()
+	defer pageVersions.Unlock()
+	if v, ok := pageVersions.entries[key]; ok && v.etag == etag {
+		return v.modTime
+	}
+	if len(pageVersions.entries) >= maxPageVersions {
+		clear(pageVersions.entries)
+	}
+	// HTTP dates have whole seconds
+	now = now.Truncate(time.Second)
+	pageVersions.entries[key] = pageVersion{etag: etag, modTime: now}
+	return now
+}
+
+// notModified reports whether the client's copy, described by If-None-Match
+// or, without that, If-Modified-Since, is still current
+func notModified(r *http.Request, etag string, modTime time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == etag || tag == "*" {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	return err == nil && !modTime.After(since)
+}
+
+// Conditional gives a page an ETag, a hash of the rendered response, and a
+// Last-Modified of when that response first appeared, and answers requests
+// that already have it with 304 Not Modified. The page is still rendered; what
+// is saved is sending it. Responses marked no-store, and anything but a 200,
+// pass through untouched.
+func Conditional(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next(w, r)
+			return
+		}
+		buf := &bufferedResponse{header: w.Header()}
+		next(buf, r)
+
+		h := w.Header()
+		status := buf.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if status != http.StatusOK || strings.Contains(h.Get("Cache-Control"), "no-store") {
+			w.WriteHeader(status)
+			w.Write(buf.body.Bytes())
+			return
+		}
+
+		sum := sha256.Sum256(buf.body.Bytes())
+		etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+		modTime := pageModTime(pathLang(r)+" "+r.URL.RequestURI(), etag, time.Now())
+		h.Set("ETag", etag)
+		h.Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+		if h.Get("Cache-Control") == "" {
+			// Pages change without notice, so check back every time
+			h.Set("Cache-Control", "no-cache")
+		}
+		if notModified(r, etag, modTime) {
+			h.Del("Content-Length")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.WriteHeader(status)
+		w.Write(buf.body.Bytes())
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestConditional(t *testing.T) {
+	body := "first"
+	h := Conditional(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(body))
+	})
+	get := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/conditional-test", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		w := httptest.NewRecorder()
+		h(w, req)
+		return w
+	}
+
+	first := get("", "")
+	etag, lastMod := first.Header().Get("ETag"), first.Header().Get("Last-Modified")
+	if first.Code != http.StatusOK || first.Body.String() != "first" || etag == "" || lastMod == "" {
+		t.Fatalf("first response: %d %q, headers %v", first.Code, first.Body.String(), first.Header())
+	}
+	if cc := first.Header().Get("Cache-Control"); cc != "no-cache" {
+		t.Errorf("Cache-Control = %q", cc)
+	}
+
+	tests := []struct {
+		name, header, value string
+		want                int
+	}{
+		{"matching etag", "If-None-Match", etag, http.StatusNotModified},
+		{"etag in list", "If-None-Match", `"other", W/` + etag, http.StatusNotModified},
+		{"any etag", "If-None-Match", "*", http.StatusNotModified},
+		{"other etag", "If-None-Match", `"other"`, http.StatusOK},
+		{"not modified since", "If-Modified-Since", lastMod, http.StatusNotModified},
+		{"modified since", "If-Modified-Since", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get(tt.header, tt.value)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+			if tt.want == http.StatusNotModified && w.Body.Len() != 0 {
+				t.Errorf("304 has a body: %q", w.Body.String())
+			}
+		})
+	}
+
+	body = "second"
+	changed := get("If-None-Match", etag)
+	if changed.Code != http.StatusOK || changed.Body.String() != "second" {
+		t.Fatalf("changed page: %d %q", changed.Code, changed.Body.String())
+	}
+	if changed.Header().Get("ETag") == etag {
+		t.Error("ETag did not change with the page")
+	}
+}
+
+func TestConditionalPassThrough(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    int
+	}{
+		{"not found", func(w http.ResponseWriter, r *http.Request) { http.NotFound(w, r) }, http.StatusNotFound},
+		{"no-store", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "private, no-store")
+			w.Write([]byte("preview"))
+		}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/pass-through", nil)
+			req.Header.Set("If-None-Match", "*")
+			w := httptest.NewRecorder()
+			Conditional(tt.handler)(w, req)
+			if w.Code != tt.want || w.Header().Get("ETag") != "" {
+				t.Errorf("status %d, ETag %q", w.Code, w.Header().Get("ETag"))
+			}
+		})
+	}
+}
@@ -0,0 +1,612 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+	_ "time/tzdata"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds server settings. Values come from config.yaml, overlaid by
+// config.<env>.yaml for the active environment, then by environment variables.
+type Config struct {
+	// Env is the active profile, selected with BLOG_ENV
+	Env string `yaml:"-"`
+
+	Port string `yaml:"port"`
+
+	// Site describes the blog and its owner
+	Site SiteConfig `yaml:"site"`
+
+	// NoIndex asks search engines not to index the site (meta robots + X-Robots-Tag)
+	NoIndex bool `yaml:"noindex"`
+	// Debug mounts debugging endpoints such as /debug/pprof
+	Debug bool `yaml:"debug"`
+	// HotReload re-parses templates on every request
+	HotReload bool `yaml:"hot_reload"`
+	// NoIndexPages lists utility page kinds (feed, search, tag, pagination) that are
+	// marked noindex and left out of the sitemap
+	NoIndexPages []string `yaml:"noindex_pages"`
+	// Timezone is the IANA zone post dates are written in, e.g. Asia/Bangkok
+	Timezone string `yaml:"timezone"`
+	location *time.Location
+	// HeadAllowlist holds the URL prefixes posts may load scripts, styles, and
+	// preloads from through frontmatter
+	HeadAllowlist []string `yaml:"head_allowlist"`
+	// LogLevel is "debug", "info", "warn", or "error"
+	LogLevel string `yaml:"log_level"`
+	// LogFormat is "text" for key=value lines or "json" for log collectors;
+	// LOG_FORMAT overrides it
+	LogFormat string `yaml:"log_format"`
+	// AccessLog logs every request with its status, size, and duration
+	AccessLog bool `yaml:"access_log"`
+	// SlowRequestThreshold logs requests taking at least this long, with where the
+	// time went; 0 turns the log off
+	SlowRequestThreshold time.Duration `yaml:"slow_request_threshold"`
+
+	// PostsPerPage is how many posts the homepage lists per page; 0 lists them all
+	PostsPerPage int `yaml:"posts_per_page"`
+	// MaxPostKB caps the size of a markdown file in kilobytes. Larger files are
+	// never read into memory and their pages answer with an error; 0 turns the
+	// cap off.
+	MaxPostKB int `yaml:"max_post_kb"`
+
+	// PostRescanInterval is how often posts/ is rescanned in case file change
+	// notifications were missed, and how often other post stores are checked
+	PostRescanInterval time.Duration `yaml:"post_rescan_interval"`
+
+	PostStore PostStoreConfig `yaml:"post_store"`
+
+	// ImageCacheDir holds resized copies of images
+	ImageCacheDir string `yaml:"image_cache_dir"`
+	// StripImageMetadata serves every JPEG/PNG without EXIF, GPS, or text metadata.
+	// Resized variants are always metadata-free.
+	StripImageMetadata bool `yaml:"strip_image_metadata"`
+	// MaxImageUploadMB caps the size of an image uploaded from the admin area
+	MaxImageUploadMB int `yaml:"max_image_upload_mb"`
+
+	// Compression compresses text responses with brotli or gzip, whichever the
+	// client accepts
+	Compression struct {
+		Enabled bool `yaml:"enabled"`
+	} `yaml:"compression"`
+
+	// TTS generates narrations for posts with audio: auto
+	TTS TTSConfig `yaml:"tts"`
+
+	Comments CommentsConfig `yaml:"comments"`
+
+	Claps ClapsConfig `yaml:"claps"`
+
+	MarkdownSource MarkdownSourceConfig `yaml:"markdown_source"`
+
+	// Markdown configures how posts and notes are rendered
+	Markdown MarkdownConfig `yaml:"markdown"`
+
+	Repo RepoConfig `yaml:"repo"`
+
+	CV CVConfig `yaml:"cv"`
+
+	// SiteURL is the blog's public address, e.g. https://learnarai.com, for links
+	// built outside a request such as cross-posts
+	SiteURL string `yaml:"site_url"`
+
+	// Redirects sends old paths that nothing else answers on for good, to a
+	// path on the site or a URL elsewhere. Renamed posts list their old slugs
+	// under aliases in their frontmatter instead.
+	Redirects map[string]string `yaml:"redirects"`
+
+	CrossPost CrossPostConfig `yaml:"crosspost"`
+
+	PreviewCheck PreviewCheckConfig `yaml:"preview_check"`
+
+	Micropub MicropubConfig `yaml:"micropub"`
+
+	Search SearchConfig `yaml:"search"`
+
+	// Metrics serves request timings and cache counters at /metrics in the
+	// Prometheus format, behind METRICS_TOKEN when that is set
+	Metrics struct {
+		Enabled bool `yaml:"enabled"`
+	} `yaml:"metrics"`
+
+	// LLMsTxt lists the published posts at /llms.txt for language models
+	LLMsTxt LLMsTxtConfig `yaml:"llms_txt"`
+
+	// Corpus serves the full text of every published post as JSON lines at
+	// /export/corpus.jsonl, behind CORPUS_TOKEN when that is set
+	Corpus struct {
+		Enabled bool `yaml:"enabled"`
+	} `yaml:"corpus"`
+
+	Proxy ProxyConfig `yaml:"proxy"`
+
+	Cookies CookieConfig `yaml:"cookies"`
+
+	// Pingback accepts pingbacks at /xmlrpc and trackbacks at /posts/{slug}/trackback
+	Pingback struct {
+		Enabled bool `yaml:"enabled"`
+	} `yaml:"pingback"`
+
+	// ReadLater shows an "email me this post" form under posts, sent through
+	// the SMTP_* settings
+	ReadLater struct {
+		Enabled bool `yaml:"enabled"`
+	} `yaml:"read_later"`
+
+	Playground PlaygroundConfig `yaml:"playground"`
+
+	Repos RepoCardsConfig `yaml:"repos"`
+
+	Backup BackupConfig `yaml:"backup"`
+
+	Health HealthConfig `yaml:"health"`
+}
+
+// PlaygroundConfig controls the snippets the playground shortcode fetches
+type PlaygroundConfig struct {
+	// CacheDir keeps fetched snippets, shown when the playground is unreachable
+	CacheDir string `yaml:"cache_dir"`
+	// Refresh is how old a cached snippet gets before it's fetched again
+	Refresh time.Duration `yaml:"refresh"`
+}
+
+// RepoCardsConfig controls the GitHub repository cards of the repo shortcode
+type RepoCardsConfig struct {
+	// CacheDir keeps each repository's details as last fetched
+	CacheDir string `yaml:"cache_dir"`
+	// Refresh is how often the cached repositories are fetched again
+	Refresh time.Duration `yaml:"refresh"`
+}
+
+// HealthConfig sets the thresholds of the checks behind /readyz
+type HealthConfig struct {
+	// Interval is how often the checks run
+	Interval time.Duration `yaml:"interval"`
+	// MinFreeDisk is the share of the disk, from 0 to 1, that must stay free
+	MinFreeDisk float64 `yaml:"min_free_disk"`
+	// IndexStaleAfter is how long the post index may go without a rescan
+	IndexStaleAfter time.Duration `yaml:"index_stale_after"`
+	// MaxDeadJobs is how many failed jobs to tolerate before warning
+	MaxDeadJobs int `yaml:"max_dead_jobs"`
+	// AlertWebhooks receive a JSON POST when a check starts or stops failing
+	AlertWebhooks []string `yaml:"alert_webhooks"`
+	// SLO warns when a route fails or slows down too often
+	SLO SLOConfig `yaml:"slo"`
+}
+
+// SLOConfig sets the objectives each route is held to, measured over the
+// requests of the last Window
+type SLOConfig struct {
+	Window time.Duration `yaml:"window"`
+	// MinRequests is how many requests a route needs in the window to be
+	// judged, so one failure on a quiet route isn't an alert
+	MinRequests int `yaml:"min_requests"`
+	// MaxErrorRate is the share of requests, from 0 to 1, that may fail with
+	// a 5xx status; 0 turns the check off
+	MaxErrorRate float64 `yaml:"max_error_rate"`
+	// Latency is how fast requests should be answered, at most 1s; 0 turns the
+	// check off
+	Latency time.Duration `yaml:"latency"`
+	// LatencyTarget is the share of requests, from 0 to 1, that must be
+	// answered within Latency
+	LatencyTarget float64 `yaml:"latency_target"`
+}
+
+// BackupConfig takes scheduled snapshots of the content and the database, kept
+// in Dir or, when S3.Bucket is set, in S3 with credentials from
+// AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY
+type BackupConfig struct {
+	// Enabled takes a snapshot every Interval while the server runs. The backup
+	// and restore commands work either way.
+	Enabled  bool          `yaml:"enabled"`
+	Interval time.Duration `yaml:"interval"`
+	// Keep is how many snapshots to keep; older ones are deleted
+	Keep int `yaml:"keep"`
+	// Paths are the content directories to include
+	Paths []string `yaml:"paths"`
+	Dir   string   `yaml:"dir"`
+	S3    struct {
+		Bucket string `yaml:"bucket"`
+		Region string `yaml:"region"`
+		// Endpoint is the service's URL for S3-compatible storage; empty means AWS
+		Endpoint string `yaml:"endpoint"`
+		// Prefix is put before every key, e.g. blog/
+		Prefix string `yaml:"prefix"`
+	} `yaml:"s3"`
+}
+
+// SiteConfig holds what makes the blog someone's own: its name, its owner, the
+// homepage introduction, and the contact links. Texts can be a plain string or
+// a map of languages (th, en). The about text of the contact page lives in
+// pages/contact.{lang}.md.
+type SiteConfig struct {
+	// Name appears in page titles, the header, feeds, and emails
+	Name string `yaml:"name"`
+	// Description is the meta description of pages that have none of their own
+	Description string `yaml:"description"`
+	// Author is the owner's name, on the contact page and in the author meta tag
+	Author Localized `yaml:"author"`
+	// Intro is the welcome text at the top of the homepage
+	Intro Localized `yaml:"intro"`
+	// Contacts are the links listed on the contact page
+	Contacts []ContactLink `yaml:"contacts"`
+	// DefaultLang is the language of pages that don't say otherwise, th or en
+	DefaultLang string `yaml:"default_lang"`
+}
+
+// ContactLink is one way to reach the owner, e.g. GitHub and its URL
+type ContactLink struct {
+	Label string `yaml:"label"`
+	// URL is an http(s) or mailto: address
+	URL string `yaml:"url"`
+}
+
+// LLMsTxtConfig controls /llms.txt
+type LLMsTxtConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+// CookieConfig sets the attributes of the cookies the site sets: the language
+// preference, saved posts, and the admin session
+type CookieConfig struct {
+	// Secure is "auto" (Secure when the request came over HTTPS, directly or per
+	// X-Forwarded-Proto), "always", or "never"
+	Secure string `yaml:"secure"`
+	// SameSite is "lax", "strict", or "none" (which forces Secure). The admin
+	// session is always strict.
+	SameSite string `yaml:"same_site"`
+	// Domain shares cookies with subdomains, e.g. learnarai.com; empty limits
+	// them to the host that set them
+	Domain string `yaml:"domain"`
+	// RememberPreferences keeps the reader's language and display settings in
+	// cookies, so / takes them back to their language, and their theme in local
+	// storage. Turn it off for a
+	// cookie-free site; / then goes by the browser's language.
+	RememberPreferences bool `yaml:"remember_preferences"`
+}
+
+// ProxyConfig describes the reverse proxy in front of the server
+type ProxyConfig struct {
+	// TrustForwardedPrefix honors X-Forwarded-Prefix, for proxies that mount the
+	// site on a subpath such as /blog and strip it before forwarding. Only enable
+	// it when every request comes through such a proxy, since clients could
+	// otherwise send the header themselves.
+	TrustForwardedPrefix bool `yaml:"trust_forwarded_prefix"`
+}
+
+// MicropubConfig enables /micropub for IndieWeb posting clients. Access tokens
+// are checked against TokenEndpoint and must have been issued for Me.
+type MicropubConfig struct {
+	// Me is the site URL you sign in to clients with, e.g. https://learnarai.com/
+	Me string `yaml:"me"`
+	// AuthorizationEndpoint and TokenEndpoint are the IndieAuth server, e.g.
+	// https://indieauth.com/auth and https://tokens.indieauth.com/token
+	AuthorizationEndpoint string `yaml:"authorization_endpoint"`
+	TokenEndpoint         string `yaml:"token_endpoint"`
+}
+
+// CrossPostConfig announces new posts on social platforms. Credentials come from
+// the environment: MASTODON_TOKEN, BLUESKY_APP_PASSWORD, and X_API_KEY,
+// X_API_SECRET, X_ACCESS_TOKEN, X_ACCESS_SECRET.
+type CrossPostConfig struct {
+	// MaxAge limits cross-posting to posts published within this long, so
+	// turning it on does not announce the whole archive
+	MaxAge time.Duration `yaml:"max_age"`
+
+	Mastodon struct {
+		// Instance is the server's base URL, e.g. https://mastodon.social
+		Instance string `yaml:"instance"`
+	} `yaml:"mastodon"`
+	Bluesky struct {
+		Handle string `yaml:"handle"`
+		// PDS is the account's server; empty means https://bsky.social
+		PDS string `yaml:"pds"`
+	} `yaml:"bluesky"`
+	X struct {
+		Enabled bool `yaml:"enabled"`
+	} `yaml:"x"`
+}
+
+// PreviewCheckConfig checks the link previews of new posts by fetching them
+// from site_url like a social platform would
+type PreviewCheckConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// MaxAge limits the check to posts published within this long
+	MaxAge time.Duration `yaml:"max_age"`
+}
+
+// MarkdownConfig selects goldmark's renderer options and extensions. The zero
+// value matches CommonMark with raw HTML left out.
+type MarkdownConfig struct {
+	// Unsafe passes raw HTML in markdown through instead of dropping it
+	Unsafe bool `yaml:"unsafe"`
+	// HardWraps turns every newline inside a paragraph into <br>
+	HardWraps bool `yaml:"hard_wraps"`
+	// XHTML writes self-closing tags such as <br />
+	XHTML bool `yaml:"xhtml"`
+	// AutoHeadingID gives headings an id so they can be linked to
+	AutoHeadingID bool `yaml:"auto_heading_id"`
+	// Attributes allows {#id .class} after headings
+	Attributes bool `yaml:"attributes"`
+	// Highlight colors fenced code blocks that name their language
+	Highlight HighlightConfig `yaml:"highlight"`
+
+	Extensions struct {
+		Table          bool `yaml:"table"`
+		Strikethrough  bool `yaml:"strikethrough"`
+		Linkify        bool `yaml:"linkify"`
+		TaskList       bool `yaml:"task_list"`
+		Footnote       bool `yaml:"footnote"`
+		DefinitionList bool `yaml:"definition_list"`
+		Typographer    bool `yaml:"typographer"`
+	} `yaml:"extensions"`
+}
+
+// HighlightConfig controls syntax highlighting of code blocks
+type HighlightConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Style is a chroma style name, e.g. github, monokai, or dracula
+	Style string `yaml:"style"`
+	// DarkStyle is used instead when the site is in dark mode; empty keeps
+	// Style for both
+	DarkStyle string `yaml:"dark_style"`
+}
+
+// SearchConfig controls /search
+type SearchConfig struct {
+	// ThaiDictionary is a word list, one per line, used to split Thai text
+	// (written without spaces) into words
+	ThaiDictionary string `yaml:"thai_dictionary"`
+}
+
+// CVConfig controls /cv.pdf. The built-in PDF fonts cover Latin text only;
+// set PDFFont to a TrueType file (for example Sarabun) to print the Thai CV.
+type CVConfig struct {
+	PDFFont     string `yaml:"pdf_font"`
+	PDFFontBold string `yaml:"pdf_font_bold"`
+}
+
+// RepoConfig points at the repository holding the posts, for "edit on GitHub" links
+type RepoConfig struct {
+	// URL is the repository's web address, e.g. https://github.com/user/blog
+	URL    string `yaml:"url"`
+	Branch string `yaml:"branch"`
+}
+
+// PostStoreConfig picks where posts are read from: dir (posts/), embed (built
+// into the binary with -tags embedposts), s3, or git. Only dir can be edited
+// from the admin area or Micropub. S3 keys come from AWS_ACCESS_KEY_ID and
+// AWS_SECRET_ACCESS_KEY.
+type PostStoreConfig struct {
+	Type string `yaml:"type"`
+	S3   struct {
+		Bucket string `yaml:"bucket"`
+		Region string `yaml:"region"`
+		// Endpoint is the service's URL for S3-compatible storage; empty means AWS
+		Endpoint string `yaml:"endpoint"`
+		// Prefix is put before every key, e.g. posts/
+		Prefix string `yaml:"prefix"`
+	} `yaml:"s3"`
+	Git struct {
+		URL    string `yaml:"url"`
+		Branch string `yaml:"branch"`
+		// Dir is where the repository is checked out
+		Dir string `yaml:"dir"`
+		// Path is the posts directory inside the repository; empty means its root
+		Path string `yaml:"path"`
+	} `yaml:"git"`
+}
+
+// MarkdownSourceConfig controls the raw markdown served at /posts/{slug}.md
+type MarkdownSourceConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Frontmatter includes the YAML frontmatter in the served source
+	Frontmatter bool `yaml:"frontmatter"`
+}
+
+// CommentsConfig controls reader comments
+type CommentsConfig struct {
+	// Moderate holds new comments for approval in /admin/comments
+	Moderate bool `yaml:"moderate"`
+	// RateLimit is how many comments a client IP may post per hour; 0 turns
+	// the limit off
+	RateLimit int `yaml:"rate_limit"`
+
+	Spam SpamConfig `yaml:"spam"`
+}
+
+// ClapsConfig controls the clap button under posts
+type ClapsConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// MaxPerReader is how many times one reader may clap for a post
+	MaxPerReader int `yaml:"max_per_reader"`
+	// RateLimit is how many clap requests a client IP may make per hour; 0
+	// turns the limit off
+	RateLimit int `yaml:"rate_limit"`
+}
+
+// SpamConfig tunes comment spam filtering. Suspect comments are held for
+// moderation even when moderation is off. The Akismet key comes from the
+// AKISMET_API_KEY environment variable.
+type SpamConfig struct {
+	MaxLinks     int      `yaml:"max_links"`
+	BlockedWords []string `yaml:"blocked_words"`
+	// BlockedIPs are addresses or CIDR ranges
+	BlockedIPs []string `yaml:"blocked_ips"`
+
+	// AkismetEndpoint is an Akismet-compatible comment-check URL; empty disables it
+	AkismetEndpoint string `yaml:"akismet_endpoint"`
+	// SiteURL is sent to Akismet as the blog address
+	SiteURL string `yaml:"site_url"`
+}
+
+// TTSConfig points at an OpenAI-compatible text-to-speech endpoint. The API key
+// comes from the TTS_API_KEY environment variable.
+type TTSConfig struct {
+	Endpoint string `yaml:"endpoint"`
+	Model    string `yaml:"model"`
+	Voice    string `yaml:"voice"`
+}
+
+// cfg is the active configuration
+var cfg = defaultConfig()
+
+// defaultConfig returns the settings used when no config file is present
+func defaultConfig() Config {
+	c := Config{
+		Env:       "production",
+		Port:      "3030",
+		LogLevel:  "info",
+		LogFormat: "text",
+		AccessLog: true,
+		Timezone:  "Asia/Bangkok",
+
+		SlowRequestThreshold: 500 * time.Millisecond,
+
+		Site: defaultSiteConfig(),
+
+		HeadAllowlist: []string{"/static/"},
+
+		NoIndexPages: []string{PageFeed, PageSearch, PageTag, PagePagination},
+
+		PostsPerPage:       10,
+		MaxPostKB:          1024,
+		PostRescanInterval: 5 * time.Minute,
+
+		Cookies: CookieConfig{Secure: "auto", SameSite: "lax", RememberPreferences: true},
+
+		ImageCacheDir:      "cache/images",
+		Playground:         PlaygroundConfig{CacheDir: "cache/playground", Refresh: 24 * time.Hour},
+		Repos:              RepoCardsConfig{CacheDir: "cache/repos", Refresh: 6 * time.Hour},
+		StripImageMetadata: true,
+		MaxImageUploadMB:   25,
+
+		TTS: TTSConfig{Model: "tts-1", Voice: "alloy"},
+
+		Comments: CommentsConfig{Moderate: true, RateLimit: 5, Spam: SpamConfig{MaxLinks: 2}},
+		Claps:    ClapsConfig{MaxPerReader: 50, RateLimit: 200},
+
+		Markdown:       MarkdownConfig{Highlight: HighlightConfig{Enabled: true, Style: "github", DarkStyle: "github-dark"}},
+		MarkdownSource: MarkdownSourceConfig{Enabled: true, Frontmatter: true},
+
+		Repo: RepoConfig{Branch: "main"},
+
+		CrossPost: CrossPostConfig{MaxAge: 48 * time.Hour},
+		PreviewCheck: PreviewCheckConfig{
+			Enabled: true,
+			MaxAge:  48 * time.Hour,
+		},
+
+		Search: SearchConfig{ThaiDictionary: "data/thai-words.txt"},
+
+		LLMsTxt: LLMsTxtConfig{Enabled: true},
+
+		Backup: defaultBackupConfig(),
+
+		Health: HealthConfig{
+			Interval: time.Minute, MinFreeDisk: 0.1, IndexStaleAfter: 15 * time.Minute,
+			SLO: SLOConfig{Window: 15 * time.Minute, MinRequests: 20, MaxErrorRate: 0.05, Latency: 500 * time.Millisecond, LatencyTarget: 0.95},
+		},
+	}
+	c.Compression.Enabled = true
+	c.PostStore.Type = "dir"
+	c.PostStore.S3.Region = "us-east-1"
+	c.PostStore.Git.Branch = "main"
+	c.PostStore.Git.Dir = "data/posts-repo"
+	return c
+}
+
+// defaultSiteConfig describes LearnArai, the blog this server was written for
+func defaultSiteConfig() SiteConfig {
+	return SiteConfig{
+		Name:        "LearnArai",
+		Description: "A learning blog for education and knowledge sharing, written in Thai and English.",
+		Author:      Localized{"th": "ธีรภัทร ยาใจ", "en": "Teerapat Yajai"},
+		Intro: Localized{
+			"th": "สวัสดีครับ!! ผมคือคนที่ชอบสร้างสรรค์และเรียนรู้สิ่งต่างๆ นี่คือพื้นที่ส่วนตัวของผมซึ่งเอาไว้สำหรับแชร์ความคิด สิ่งที่ได้เรียนรู้ หรือโปรเจกต์ที่กำลังทำอยู่",
+			"en": "Hi!! I'm someone who likes to create and learn new things. This is my personal space where I can share ideas or projects I'm currently working on.",
+		},
+		Contacts: []ContactLink{
+			{Label: "Email", URL: "mailto:teerapat.yj@gmail.com"},
+			{Label: "GitHub", URL: "https://github.com/kenn-teera"},
+			{Label: "LinkedIn", URL: "https://linkedin.com/in/teerapat-yajai"},
+		},
+		DefaultLang: "th",
+	}
+}
+
+// defaultBackupConfig keeps a week of daily snapshots in backups/
+func defaultBackupConfig() BackupConfig {
+	c := BackupConfig{
+		Interval: 24 * time.Hour,
+		Keep:     7,
+		Paths:    []string{"posts", notesDir, tilDir, pagesDir, "images", attachmentsDir, "audio", "data"},
+		Dir:      "backups",
+	}
+	c.S3.Region = "us-east-1"
+	return c
+}
+
+// LoadConfig reads config.yaml and the overlay for env from dir. Missing files are
+// not an error; the defaults are production-safe.
+func LoadConfig(dir, env string) (Config, error) {
+	c := defaultConfig()
+	if env != "" {
+		c.Env = env
+	}
+
+	for _, name := range []string{"config.yaml", "config." + c.Env + ".yaml"} {
+		if err := overlayConfigFile(&c, filepath.Join(dir, name)); err != nil {
+			return c, err
+		}
+	}
+
+	if port := os.Getenv("PORT"); port != "" {
+		c.Port = port
+	}
+	if name := os.Getenv("SITE_NAME"); name != "" {
+		c.Site.Name = name
+	}
+	if format := os.Getenv("LOG_FORMAT"); format != "" {
+		c.LogFormat = format
+	}
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return c, fmt.Errorf("invalid timezone %q: %w", c.Timezone, err)
+	}
+	c.location = loc
+	return c, nil
+}
+
+// Location returns the site timezone, falling back to UTC when it is invalid
+func (c Config) Location() *time.Location {
+	if c.location != nil {
+		return c.location
+	}
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// overlayConfigFile unmarshals a YAML file over c; only keys present in the file change
+func overlayConfigFile(c *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read config %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfig_Overlay(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("port: \"8080\"\nlog_level: info\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "config.development.yaml"), []byte("debug: true\nlog_level: debug\n"), 0644)
+
+	tests := []struct {
+		env       string
+		wantDebug bool
+		wantLevel string
+	}{
+		{"development", true, "debug"},
+		{"production", false, "info"},
+		{"", false, "info"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.env, func(t *testing.T) {
+			c, err := LoadConfig(dir, tt.env)
+			if err != nil {
+				t.Fatalf("LoadConfig: %v", err)
+			}
+			if c.Port != "8080" {
+				t.Errorf("expected base port 8080, got %q", c.Port)
+			}
+			if c.Debug != tt.wantDebug || c.LogLevel != tt.wantLevel {
+				t.Errorf("got debug=%v level=%q, want debug=%v level=%q", c.Debug, c.LogLevel, tt.wantDebug, tt.wantLevel)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_Defaults(t *testing.T) {
+	c, err := LoadConfig(t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if c.Env != "production" || c.Port != "3030" || c.NoIndex || c.Debug {
+		t.Errorf("unexpected defaults %+v", c)
+	}
+}
+
+func TestLoadConfig_EnvOverridesPort(t *testing.T) {
+	t.Setenv("PORT", "9999")
+	c, err := LoadConfig(t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if c.Port != "9999" {
+		t.Errorf("expected PORT to override config, got %q", c.Port)
+	}
+}
+
+func TestLoadConfig_Site(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("site:\n  author: Jane Doe\n  intro:\n    en: Hello\n"), 0644)
+	t.Setenv("SITE_NAME", "Jane's Notes")
+	c, err := LoadConfig(dir, "")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if c.Site.Name != "Jane's Notes" || c.Site.Author.In("th") != "Jane Doe" || c.Site.Intro.In("th") != "Hello" {
+		t.Errorf("site = %+v", c.Site)
+	}
+	// Settings left out keep their defaults
+	if c.Site.DefaultLang != "th" || len(c.Site.Contacts) == 0 {
+		t.Errorf("site = %+v", c.Site)
+	}
+}
+
+func TestLoadConfig_InvalidYAML(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("port: [unterminated"), 0644)
+	if _, err := LoadConfig(dir, ""); err == nil {
+		t.Error("expected error for invalid YAML")
+	}
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ContactMessage is a message submitted through the contact form
+type ContactMessage struct {
+	ID      int64
+	Name    string
+	Email   string
+	Message string
+	// PostSlug is set for corrections suggested on a post
+	PostSlug  string
+	Read      bool
+	CreatedAt time.Time
+}
+
+// ContactStore persists contact form submissions so they survive SMTP failures
+type ContactStore struct {
+	db *sql.DB
+}
+
+const contactSchema = `CREATE TABLE IF NOT EXISTS contact_messages (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	email TEXT NOT NULL,
+	message TEXT NOT NULL,
+	read INTEGER NOT NULL DEFAULT 0,
+	created_at INTEGER NOT NULL
+);`
+
+// NewContactStore creates the contact_messages table if needed
+func NewContactStore(db *sql.DB) (*ContactStore, error) {
+	if _, err := db.Exec(contactSchema); err != nil {
+		return nil, fmt.Errorf("create contact_messages table: %w", err)
+	}
+	if err := addColumn(db, "contact_messages", "post_slug", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return nil, err
+	}
+	return &ContactStore{db: db}, nil
+}
+
+// Save stores a new, unread message and fills in its ID
+func (s *ContactStore) Save(m *ContactMessage) error {
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = time.Now()
+	}
+	res, err := s.db.Exec(`INSERT INTO contact_messages (name, email, message, post_slug, created_at) VALUES (?, ?, ?, ?, ?)`,
+		m.Name, m.Email, m.Message, m.PostSlug, m.CreatedAt.Unix())
+	if err != nil {
+		return fmt.Errorf("save contact message: %w", err)
+	}
+	m.ID, _ = res.LastInsertId()
+	return nil
+}
+
+// List returns all messages, newest first
+func (s *ContactStore) List() ([]ContactMessage, error) {
+	rows, err := s.db.Query(`SELECT id, name, email, message, post_slug, read, created_at FROM contact_messages ORDER BY id DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("list contact messages: %w", err)
+	}
+	defer rows.Close()
+
+	var msgs []ContactMessage
+	for rows.Next() {
+		var m ContactMessage
+		var createdAt int64
+		if err := rows.Scan(&m.ID, &m.Name, &m.Email, &m.Message, &m.PostSlug, &m.Read, &createdAt); err != nil {
+			return nil, fmt.Errorf("list contact messages: %w", err)
+		}
+		m.CreatedAt = time.Unix(createdAt, 0)
+		msgs = append(msgs, m)
+	}
+	return msgs, rows.Err()
+}
+
+// SetRead marks a message as read or unread
+func (s *ContactStore) SetRead(id int64, read bool) error {
+	res, err := s.db.Exec(`UPDATE contact_messages SET read = ? WHERE id = ?`, read, id)
+	if err != nil {
+		return fmt.Errorf("update contact message %d: %w", id, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// UnreadCount returns the number of unread messages
+func (s *ContactStore) UnreadCount() (int, error) {
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM contact_messages WHERE read = 0`).Scan(&n)
+	return n, err
+}
+
+// validateContactMessage checks the submitted fields, returning a user-facing error
+func validateContactMessage(m ContactMessage) string {
+	if m.Name == "" || m.Email == "" || m.Message == "" {
+		return "Please fill in all fields."
+	}
+	if utf8.RuneCountInString(m.Name) > 100 || utf8.RuneCountInString(m.Message) > 5000 {
+		return "Your message is too long."
+	}
+	if addr, err := mail.ParseAddress(m.Email); err != nil || addr.Address != m.Email {
+		return "Please enter a valid email address."
+	}
+	return ""
+}
+
+// ContactSubmitHandler stores a contact form submission and queues an email notification
+func ContactSubmitHandler(store *ContactStore, queue *JobQueue, emails *EmailRenderer, notifyTo string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setSecurityHeaders(w)
+
+		lang := r.PostFormValue("lang")
+		if lang != "en" && lang != "th" {
+			lang = defaultLang()
+		}
+
+		msg := ContactMessage{
+			Name:    strings.TrimSpace(r.PostFormValue("name")),
+			Email:   strings.TrimSpace(r.PostFormValue("email")),
+			Message: strings.TrimSpace(r.PostFormValue("message")),
+		}
+		if errMsg := validateContactMessage(msg); errMsg != "" {
+			http.Error(w, errMsg, http.StatusBadRequest)
+			return
+		}
+
+		// The database is the source of truth; email is only a notification
+		if err := store.Save(&msg); err != nil {
+			slog.Error("Error saving contact message", "err", err)
+			http.Error(w, "Could not send message", http.StatusInternalServerError)
+			return
+		}
+
+		if notifyTo != "" {
+			rendered, err := emails.Render("contact_notification", lang, msg)
+			if err != nil {
+				slog.Error("Error rendering contact notification", "err", err)
+			} else {
+				email := rendered.Message(notifyTo)
+				email.ReplyTo = msg.Email
+				if err := queue.Enqueue("email", email); err != nil {
+					slog.Error("Error queueing contact notification", "err", err)
+				}
+			}
+		}
+
+		http.Redirect(w, r, "/contact?lang="+lang+"&sent=1", http.StatusSeeOther)
+	}
+}
+
+// contactFormHTML renders the contact form, or a thank-you note after a successful submission
+func contactFormHTML(lang string, sent bool) string {
+	labels := map[string]string{
+		"heading": "Send a Message",
+		"name":    "Name",
+		"email":   "Email",
+		"message": "Message",
+		"submit":  "Send",
+		"thanks":  "Thank you! Your message has been sent.",
+	}
+	if lang == "th" {
+		labels = map[string]string{
+			"heading": "ส่งข้อความ",
+			"name":    "ชื่อ",
+			"email":   "อีเมล",
+			"message": "ข้อความ",
+			"submit":  "ส่ง",
+			"thanks":  "ขอบคุณครับ! ได้รับข้อความของคุณแล้ว",
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("<section class=\"contact-form-section\">\n")
+	b.WriteString("<h2>" + labels["heading"] + "</h2>\n")
+	if sent {
+		b.WriteString("<p class=\"form-success\">" + labels["thanks"] + "</p>\n")
+	}
+	b.WriteString(`<form method="post" action="/contact" class="contact-form">
+	<input type="hidden" name="lang" value="` + lang + `">
+	<label>` + labels["name"] + ` <input type="text" name="name" maxlength="100" required></label>
+	<label>` + labels["email"] + ` <input type="email" name="email" required></label>
+	<label>` + labels["message"] + ` <textarea name="message" rows="6" maxlength="5000" required></textarea></label>
+	<button type="submit" class="modal-btn">` + labels["submit"] + `</button>
+</form>
+</section>
+`)
+	return b.String()
+}
+
+// AdminInboxHandler lists contact form submissions
+func AdminInboxHandler(store *ContactStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		msgs, err := store.List()
+		if err != nil {
+			slog.Error("Error listing contact messages", "err", err)
+			http.Error(w, "Could not list messages", http.StatusInternalServerError)
+			return
+		}
+
+		var content bytes.Buffer
+		content.WriteString("<div class=\"admin-page\">\n")
+		content.WriteString(adminNav())
+		content.WriteString("<h1>Inbox</h1>\n")
+		content.WriteString("<p><a href=\"/admin/inbox/export.csv\">Export CSV</a></p>\n")
+		if len(msgs) == 0 {
+			content.WriteString("<p>No messages yet.</p>\n")
+		}
+		for _, m := range msgs {
+			id := strconv.FormatInt(m.ID, 10)
+			class := "inbox-message"
+			action, label := "read", "Mark as read"
+			if m.Read {
+				action, label = "unread", "Mark as unread"
+			} else {
+				class += " unread"
+			}
+			content.WriteString("<div class=\"" + class + "\">\n")
+			content.WriteString("<div class=\"inbox-meta\"><strong>" + template.HTMLEscapeString(m.Name) + "</strong> ")
+			if m.Email != "" {
+				content.WriteString("&lt;<a href=\"mailto:" + template.HTMLEscapeString(m.Email) + "\">" + template.HTMLEscapeString(m.Email) + "</a>&gt; ")
+			}
+			if m.PostSlug != "" {
+				content.WriteString("suggested an edit to <a href=\"/posts/" + template.HTMLEscapeString(m.PostSlug) + "\">" + template.HTMLEscapeString(m.PostSlug) + "</a> ")
+			}
+			content.WriteString("<span class=\"post-date\">" + m.CreatedAt.Format("Jan 2, 2006 15:04") + "</span></div>\n")
+			content.WriteString("<p>" + strings.ReplaceAll(template.HTMLEscapeString(m.Message), "\n", "<br>") + "</p>\n")
+			content.WriteString("<form method=\"post\" action=\"/admin/inbox/" + id + "/" + action + "\"><button type=\"submit\">" + label + "</button></form>\n")
+			content.WriteString("</div>\n")
+		}
+		content.WriteString("</div>")
+
+		renderPage(w, "Inbox", template.HTML(content.String()))
+	}
+}
+
+// AdminInboxActionHandler marks a message as read or unread
+func AdminInboxActionHandler(store *ContactStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid message ID", http.StatusBadRequest)
+			return
+		}
+
+		var read bool
+		switch r.PathValue("action") {
+		case "read":
+			read = true
+		case "unread":
+			read = false
+		default:
+			http.NotFound(w, r)
+			return
+		}
+
+		if err := store.SetRead(id, read); err == sql.ErrNoRows {
+			http.Error(w, "Message not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			slog.Error("Error updating contact message", "id", id, "err", err)
+			http.Error(w, "Could not update message", http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, "/admin/inbox", http.StatusSeeOther)
+	}
+}
+
+// AdminInboxExportHandler downloads all contact messages as CSV
+func AdminInboxExportHandler(store *ContactStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		msgs, err := store.List()
+		if err != nil {
+			slog.Error("Error exporting contact messages", "err", err)
+			http.Error(w, "Could not export messages", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="inbox.csv"`)
+
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"id", "date", "name", "email", "message", "read"})
+		for _, m := range msgs {
+			cw.Write([]string{
+				strconv.FormatInt(m.ID, 10),
+				m.CreatedAt.Format(time.RFC3339),
+				csvSafe(m.Name),
+				csvSafe(m.Email),
+				csvSafe(m.Message),
+				strconv.FormatBool(m.Read),
+			})
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			slog.Error("Error writing inbox CSV", "err", err)
+		}
+	}
+}
+
+// csvSafe quotes a visitor's text with a leading ' when a spreadsheet would
+// otherwise run it as a formula
+func csvSafe(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
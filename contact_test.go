@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func postForm(target string, form url.Values) *http.Request {
+	req := httptest.NewRequest("POST", target, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+func TestContactSubmitHandler_StoresAndNotifies(t *testing.T) {
+	db := newTestDB(t)
+	store, err := NewContactStore(db)
+	if err != nil {
+		t.Fatalf("NewContactStore: %v", err)
+	}
+	queue, err := NewJobQueue(db)
+	if err != nil {
+		t.Fatalf("NewJobQueue: %v", err)
+	}
+
+	var sent EmailMessage
+	queue.Register("email", func(ctx context.Context, payload []byte) error {
+		return json.Unmarshal(payload, &sent)
+	})
+
+	handler := ContactSubmitHandler(store, queue, NewEmailRenderer("templates/email"), "owner@example.com")
+	w := httptest.NewRecorder()
+	handler(w, postForm("/contact", url.Values{
+		"lang":    {"en"},
+		"name":    {"Reader"},
+		"email":   {"reader@example.com"},
+		"message": {"Great post!"},
+	}))
+
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("expected status 303, got %d", w.Code)
+	}
+
+	msgs, err := store.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(msgs) != 1 || msgs[0].Message != "Great post!" || msgs[0].Read {
+		t.Fatalf("unexpected stored messages %+v", msgs)
+	}
+
+	if _, err := queue.RunOnce(context.Background()); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if len(sent.To) != 1 || sent.To[0] != "owner@example.com" || sent.ReplyTo != "reader@example.com" {
+		t.Errorf("unexpected notification %+v", sent)
+	}
+	if sent.HTML == "" || !strings.Contains(sent.Body, "Great post!") {
+		t.Errorf("expected HTML and plain-text bodies, got %+v", sent)
+	}
+}
+
+func TestContactSubmitHandler_RejectsInvalidInput(t *testing.T) {
+	db := newTestDB(t)
+	store, _ := NewContactStore(db)
+	queue, _ := NewJobQueue(db)
+	handler := ContactSubmitHandler(store, queue, NewEmailRenderer("templates/email"), "")
+
+	tests := []struct {
+		name string
+		form url.Values
+	}{
+		{"missing message", url.Values{"name": {"A"}, "email": {"a@example.com"}}},
+		{"bad email", url.Values{"name": {"A"}, "email": {"not-an-email"}, "message": {"hi"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler(w, postForm("/contact", tt.form))
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("expected status 400, got %d", w.Code)
+			}
+		})
+	}
+
+	if msgs, _ := store.List(); len(msgs) != 0 {
+		t.Errorf("expected nothing stored, got %d messages", len(msgs))
+	}
+}
+
+func TestAdminInboxExportHandler(t *testing.T) {
+	store, _ := NewContactStore(newTestDB(t))
+	store.Save(&ContactMessage{Name: "Reader", Email: "reader@example.com", Message: "Hello, \"world\""})
+
+	w := httptest.NewRecorder()
+	AdminInboxExportHandler(store)(w, httptest.NewRequest("GET", "/admin/inbox/export.csv", nil))
+
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("expected CSV content type, got %q", ct)
+	}
+	body := w.Body.String()
+	if !strings.HasPrefix(body, "id,date,name,email,message,read\n") {
+		t.Errorf("missing CSV header in %q", body)
+	}
+	if !strings.Contains(body, `"Hello, ""world"""`) {
+		t.Errorf("expected escaped message in %q", body)
+	}
+}
+
+func TestAdminInboxExportHandler_Formulas(t *testing.T) {
+	store, _ := NewContactStore(newTestDB(t))
+	store.Save(&ContactMessage{Name: "@SUM(A1)", Email: "reader@example.com", Message: "=HYPERLINK(\"http://evil.example\")"})
+
+	w := httptest.NewRecorder()
+	AdminInboxExportHandler(store)(w, httptest.NewRequest("GET", "/admin/inbox/export.csv", nil))
+
+	body := w.Body.String()
+	if !strings.Contains(body, `'@SUM(A1),reader@example.com,"'=HYPERLINK(""http://evil.example"")"`) {
+		t.Errorf("formulas not neutralised in %q", body)
+	}
+}
+
+func TestCSVSafe(t *testing.T) {
+	for in, want := range map[string]string{
+		"":      "",
+		"Hello": "Hello",
+		"=1+1":  "'=1+1",
+		"+1":    "'+1",
+		"-1":    "'-1",
+		"@cmd":  "'@cmd",
+		"\tx":   "'\tx",
+		"\rx":   "'\rx",
+		"a=b":   "a=b",
+	} {
+		if got := csvSafe(in); got != want {
+			t.Errorf("csvSafe(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
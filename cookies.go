@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// requestIsHTTPS reports whether the visitor connected over HTTPS, directly or
+// through a proxy that sets X-Forwarded-Proto
+func requestIsHTTPS(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
+
+// sameSiteModes maps the cookies.same_site setting to its mode
+var sameSiteModes = map[string]http.SameSite{
+	"none":   http.SameSiteNoneMode,
+	"lax":    http.SameSiteLaxMode,
+	"strict": http.SameSiteStrictMode,
+}
+
+// cookieDomain returns the configured cookie domain when host belongs to it, so
+// visits on another host (such as localhost) still get working cookies
+func cookieDomain(host string) string {
+	domain := strings.ToLower(strings.Trim(cfg.Cookies.Domain, "."))
+	if domain == "" {
+		return ""
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	if host == domain || strings.HasSuffix(host, "."+domain) {
+		return domain
+	}
+	return ""
+}
+
+// newCookie returns a cookie for the whole site with the Secure, SameSite, and
+// Domain attributes from config
+func newCookie(r *http.Request, name, value string) *http.Cookie {
+	c := &http.Cookie{Name: name, Value: value, Path: "/", SameSite: http.SameSiteLaxMode}
+	if mode, ok := sameSiteModes[cfg.Cookies.SameSite]; ok {
+		c.SameSite = mode
+	}
+	switch cfg.Cookies.Secure {
+	case "always":
+		c.Secure = true
+	case "never":
+	default:
+		c.Secure = requestIsHTTPS(r)
+	}
+	// Browsers drop SameSite=None cookies that aren't Secure
+	if c.SameSite == http.SameSiteNoneMode {
+		c.Secure = true
+	}
+	c.Domain = cookieDomain(r.Host)
+	return c
+}
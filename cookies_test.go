@@ -0,0 +1,61 @@
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewCookie(t *testing.T) {
+	defer func(old CookieConfig) { cfg.Cookies = old }(cfg.Cookies)
+
+	plain := httptest.NewRequest("GET", "http://learnarai.com/", nil)
+	proxied := httptest.NewRequest("GET", "http://learnarai.com/", nil)
+	proxied.Header.Set("X-Forwarded-Proto", "https")
+	direct := httptest.NewRequest("GET", "https://www.learnarai.com:8443/", nil)
+	direct.TLS = &tls.ConnectionState{}
+	local := httptest.NewRequest("GET", "http://localhost:3030/", nil)
+
+	tests := []struct {
+		name       string
+		config     CookieConfig
+		r          *http.Request
+		wantSecure bool
+		wantSite   http.SameSite
+		wantDomain string
+	}{
+		{"auto over http", CookieConfig{Secure: "auto", SameSite: "lax"}, plain, false, http.SameSiteLaxMode, ""},
+		{"auto behind https proxy", CookieConfig{Secure: "auto", SameSite: "lax"}, proxied, true, http.SameSiteLaxMode, ""},
+		{"auto over tls", CookieConfig{Secure: "auto", SameSite: "lax"}, direct, true, http.SameSiteLaxMode, ""},
+		{"always", CookieConfig{Secure: "always", SameSite: "lax"}, plain, true, http.SameSiteLaxMode, ""},
+		{"never", CookieConfig{Secure: "never", SameSite: "lax"}, proxied, false, http.SameSiteLaxMode, ""},
+		{"strict", CookieConfig{Secure: "auto", SameSite: "strict"}, plain, false, http.SameSiteStrictMode, ""},
+		{"none forces secure", CookieConfig{Secure: "never", SameSite: "none"}, plain, true, http.SameSiteNoneMode, ""},
+		{"domain on subdomain", CookieConfig{Secure: "auto", SameSite: "lax", Domain: ".learnarai.com"}, direct, true, http.SameSiteLaxMode, "learnarai.com"},
+		{"domain on apex", CookieConfig{Secure: "auto", SameSite: "lax", Domain: "learnarai.com"}, plain, false, http.SameSiteLaxMode, "learnarai.com"},
+		{"domain elsewhere", CookieConfig{Secure: "auto", SameSite: "lax", Domain: "learnarai.com"}, local, false, http.SameSiteLaxMode, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.Cookies = tt.config
+			c := newCookie(tt.r, "lang", "en")
+			if c.Secure != tt.wantSecure || c.SameSite != tt.wantSite || c.Domain != tt.wantDomain || c.Path != "/" {
+				t.Errorf("got Secure=%v SameSite=%v Domain=%q Path=%q", c.Secure, c.SameSite, c.Domain, c.Path)
+			}
+		})
+	}
+}
+
+func TestAdminLoginCookieSecureBehindProxy(t *testing.T) {
+	auth := NewAdminAuth("admin", "pw", "secret")
+	req := postForm("/admin/login", map[string][]string{"username": {"admin"}, "password": {"pw"}})
+	req.Header.Set("X-Forwarded-Proto", "https")
+	w := httptest.NewRecorder()
+	auth.LoginHandler(w, req)
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || !cookies[0].Secure || !cookies[0].HttpOnly || cookies[0].SameSite != http.SameSiteStrictMode {
+		t.Errorf("session cookie = %+v", cookies)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// corpusPost is one line of /export/corpus.jsonl
+type corpusPost struct {
+	Slug     string   `json:"slug"`
+	Title    string   `json:"title"`
+	URL      string   `json:"url"`
+	Date     string   `json:"date,omitempty"`
+	Lang     string   `json:"lang,omitempty"`
+	Category string   `json:"category,omitempty"`
+	Tags     []string `json:"tags"`
+	// ExternalURL is the page a link post points at
+	ExternalURL string `json:"external_url,omitempty"`
+	// Markdown is the post as written, without frontmatter; Text is the
+	// rendered post reduced to plain text
+	Markdown string `json:"markdown"`
+	Text     string `json:"text"`
+}
+
+// LLMsTxtHandler serves /llms.txt: a markdown index of the published posts for
+// language models, following llmstxt.org. Posts link to their markdown source
+// when that is served.
+func LLMsTxtHandler(posts *PostIndex) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		base := siteURL(r)
+		var b strings.Builder
+		b.WriteString("# " + cfg.Site.Name + "\n\n> " + cfg.Site.Description + "\n\n")
+		if cfg.MarkdownSource.Enabled {
+			b.WriteString("Every post is linked as markdown; drop the .md for the web page.\n")
+		}
+		if cfg.Corpus.Enabled {
+			b.WriteString("The full text of every post is available as JSON lines at " + base + "/export/corpus.jsonl.\n")
+		}
+		b.WriteString("\n## Posts\n\n")
+		for _, p := range posts.Posts() {
+			link := base + postTreePath(p.Slug, p.Lang)
+			if cfg.MarkdownSource.Enabled {
+				link += ".md"
+			}
+			b.WriteString("- [" + markdownLinkText(p.Title) + "](" + link + ")")
+			var details []string
+			if !p.Date.IsZero() {
+				details = append(details, p.Date.Format("2006-01-02"))
+			}
+			if len(p.Tags) > 0 {
+				details = append(details, "tags: "+strings.Join(p.Tags, ", "))
+			}
+			if len(details) > 0 {
+				b.WriteString(": " + strings.Join(details, "; "))
+			}
+			b.WriteString("\n")
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		w.Write([]byte(b.String()))
+	}
+}
+
+// markdownLinkText escapes the characters that would end a markdown link's text
+func markdownLinkText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`).Replace(s)
+}
+
+// CorpusHandler serves every published post, one JSON object per line, for
+// building search tools or assistants over the blog. With a token it requires
+// it as a bearer token, like /metrics.
+func CorpusHandler(posts *PostIndex, token string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token != "" {
+			got, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+
+		base := siteURL(r)
+		w.Header().Set("Content-Type", "application/x-ndjson; charset=utf-8")
+		if token != "" {
+			w.Header().Set("Cache-Control", "private, no-store")
+		} else {
+			w.Header().Set("Cache-Control", "public, max-age=300")
+		}
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		for _, p := range posts.Posts() {
+			entry, ok := posts.Get(p.Slug)
+			if !ok {
+				continue
+			}
+			_, body := ParseFrontmatter(entry.Content)
+			html, err := renderMarkdownCached(body, p.Slug)
+			if err != nil {
+				slog.Error("Error rendering post for the corpus", "slug", p.Slug, "err", err)
+			}
+			line := corpusPost{
+				Slug:        p.Slug,
+				Title:       p.Title,
+				URL:         base + postTreePath(p.Slug, p.Lang),
+				Lang:        p.Lang,
+				Category:    p.Category,
+				Tags:        p.Tags,
+				ExternalURL: p.Link,
+				Markdown:    body,
+				Text:        plainText(html),
+			}
+			if line.Tags == nil {
+				line.Tags = []string{}
+			}
+			if !p.Date.IsZero() {
+				line.Date = p.Date.Format(time.RFC3339)
+			}
+			if err := enc.Encode(line); err != nil {
+				// The client went away
+				return
+			}
+		}
+	}
+}
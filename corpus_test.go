@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newCorpusTestIndex(t *testing.T) *PostIndex {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "en-hello.md"), []byte("---\ntitle: Hello [world]\ndate: 2025-03-01\ntags: [go, web]\n---\nSome **bold** text."), 0644)
+	os.WriteFile(filepath.Join(dir, "intro.md"), []byte("---\ntitle: Intro\ndate: 2025-01-01\n---\nFirst."), 0644)
+	os.WriteFile(filepath.Join(dir, "wip.md"), []byte("---\ntitle: WIP\ndate: 2025-02-01\ndraft: true\n---\nSecret."), 0644)
+	return newTestPostIndex(t, dir)
+}
+
+func TestLLMsTxtHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	LLMsTxtHandler(newCorpusTestIndex(t))(w, httptest.NewRequest("GET", "http://blog.example/llms.txt", nil))
+
+	body := w.Body.String()
+	for _, want := range []string{
+		"# LearnArai\n\n> ",
+		"- [Hello \\[world\\]](http://blog.example/en/posts/hello.md): 2025-03-01; tags: go, web\n",
+		"- [Intro](http://blog.example/posts/intro.md): 2025-01-01\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q in:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "WIP") {
+		t.Error("draft listed")
+	}
+	if strings.Index(body, "Hello") > strings.Index(body, "Intro") {
+		t.Error("posts not newest first")
+	}
+}
+
+func TestCorpusHandler(t *testing.T) {
+	idx := newCorpusTestIndex(t)
+
+	w := httptest.NewRecorder()
+	CorpusHandler(idx, "tok")(w, httptest.NewRequest("GET", "/export/corpus.jsonl", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("without token: status %d", w.Code)
+	}
+
+	req := httptest.NewRequest("GET", "http://blog.example/export/corpus.jsonl", nil)
+	req.Header.Set("Authorization", "Bearer tok")
+	w = httptest.NewRecorder()
+	CorpusHandler(idx, "tok")(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != "private, no-store" {
+		t.Fatalf("status %d, headers %v", w.Code, w.Header())
+	}
+
+	var got []corpusPost
+	sc := bufio.NewScanner(w.Body)
+	for sc.Scan() {
+		var p corpusPost
+		if err := json.Unmarshal(sc.Bytes(), &p); err != nil {
+			t.Fatalf("line %q: %v", sc.Text(), err)
+		}
+		got = append(got, p)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d posts, want 2 (draft left out)", len(got))
+	}
+	hello := got[0]
+	if hello.Slug != "en-hello" || hello.URL != "http://blog.example/en/posts/hello" || hello.Lang != "en" ||
+		hello.Date != "2025-03-01T00:00:00+07:00" || len(hello.Tags) != 2 {
+		t.Errorf("metadata = %+v", hello)
+	}
+	if hello.Markdown != "Some **bold** text." || hello.Text != "Some bold text." {
+		t.Errorf("markdown %q, text %q", hello.Markdown, hello.Text)
+	}
+	if got[1].Tags == nil {
+		t.Error("tags should be an empty list, not null")
+	}
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+	"unicode/utf8"
+)
+
+// Cross-post statuses
+const (
+	CrossPostQueued = "queued"
+	CrossPostSent   = "sent"
+	CrossPostFailed = "failed"
+	// CrossPostSkipped means the post opted out or was unpublished before delivery
+	CrossPostSkipped = "skipped"
+)
+
+// CrossPoster publishes a short status linking to a post on one platform
+type CrossPoster interface {
+	// Name identifies the platform in the store and the admin, e.g. "mastodon"
+	Name() string
+	// Post publishes text, which ends with link, and returns the new status's URL
+	Post(ctx context.Context, text, link string) (string, error)
+}
+
+// CrossPost is the delivery state of one post on one platform
+type CrossPost struct {
+	Slug      string
+	Platform  string
+	Status    string
+	URL       string
+	Error     string
+	UpdatedAt time.Time
+}
+
+// CrossPostJobPayload is the payload of a "crosspost" job
+type CrossPostJobPayload struct {
+	Slug     string `json:"slug"`
+	Platform string `json:"platform"`
+}
+
+// CrossPostStore records which posts were announced where
+type CrossPostStore struct {
+	db *sql.DB
+}
+
+const crossPostsSchema = `CREATE TABLE IF NOT EXISTS crossposts (
+	slug TEXT NOT NULL,
+	platform TEXT NOT NULL,
+	status TEXT NOT NULL,
+	url TEXT NOT NULL DEFAULT '',
+	error TEXT NOT NULL DEFAULT '',
+	updated_at INTEGER NOT NULL,
+	PRIMARY KEY (slug, platform)
+);`
+
+// NewCrossPostStore creates the crossposts table if needed
+func NewCrossPostStore(db *sql.DB) (*CrossPostStore, error) {
+	if _, err := db.Exec(crossPostsSchema); err != nil {
+		return nil, fmt.Errorf("create crossposts table: %w", err)
+	}
+	return &CrossPostStore{db: db}, nil
+}
+
+// Claim marks a post as queued for a platform and reports whether it was new,
+// so each post is announced at most once per platform
+func (s *CrossPostStore) Claim(slug, platform string) (bool, error) {
+	res, err := s.db.Exec(`INSERT OR IGNORE INTO crossposts (slug, platform, status, updated_at) VALUES (?, ?, ?, ?)`,
+		slug, platform, CrossPostQueued, time.Now().Unix())
+	if err != nil {
+		return false, fmt.Errorf("claim cross-post %s/%s: %w", slug, platform, err)
+	}
+	n, _ := res.RowsAffected()
+	return n == 1, nil
+}
+
+// SetResult records the outcome of a delivery attempt
+func (s *CrossPostStore) SetResult(slug, platform, status, url, errMsg string) error {
+	_, err := s.db.Exec(`UPDATE crossposts SET status = ?, url = ?, error = ?, updated_at = ? WHERE slug = ? AND platform = ?`,
+		status, url, errMsg, time.Now().Unix(), slug, platform)
+	if err != nil {
+		return fmt.Errorf("update cross-post %s/%s: %w", slug, platform, err)
+	}
+	return nil
+}
+
+// Get returns the delivery state of a post on a platform
+func (s *CrossPostStore) Get(slug, platform string) (CrossPost, error) {
+	c := CrossPost{Slug: slug, Platform: platform}
+	var updated int64
+	err := s.db.QueryRow(`SELECT status, url, error, updated_at FROM crossposts WHERE slug = ? AND platform = ?`,
+		slug, platform).Scan(&c.Status, &c.URL, &c.Error, &updated)
+	if err != nil {
+		return c, fmt.Errorf("get cross-post %s/%s: %w", slug, platform, err)
+	}
+	c.UpdatedAt = time.Unix(updated, 0)
+	return c, nil
+}
+
+// List returns every cross-post, most recently updated first
+func (s *CrossPostStore) List() ([]CrossPost, error) {
+	rows, err := s.db.Query(`SELECT slug, platform, status, url, error, updated_at FROM crossposts ORDER BY updated_at DESC, slug`)
+	if err != nil {
+		return nil, fmt.Errorf("list cross-posts: %w", err)
+	}
+	defer rows.Close()
+
+	var out []CrossPost
+	for rows.Next() {
+		var c CrossPost
+		var updated int64
+		if err := rows.Scan(&c.Slug, &c.Platform, &c.Status, &c.URL, &c.Error, &updated); err != nil {
+			return nil, fmt.Errorf("list cross-posts: %w", err)
+		}
+		c.UpdatedAt = time.Unix(updated, 0)
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// crossPostOptedOut reports whether a post's frontmatter has crosspost: false
+func crossPostOptedOut(fm PostFrontmatter) bool {
+	return fm.Crosspost != nil && !*fm.Crosspost
+}
+
+// crossPostText is the status published for a post: its title and permalink
+func crossPostText(title, link string) string {
+	// Leave room for the link within the shortest limit (Bluesky's 300 characters)
+	if utf8.RuneCountInString(title) > 200 {
+		title = string([]rune(title)[:199]) + "…"
+	}
+	return title + "\n\n" + link
+}
+
+// queueCrossPosts queues an announcement on every platform for each post that
+// went live within maxAge and has not been cross-posted there yet. Posts without
+// a date are never cross-posted since their publish time is unknown, and drafts
+// and private posts wait until they are published.
+func queueCrossPosts(store *CrossPostStore, queue *JobQueue, posts PostFiles, posters []CrossPoster, maxAge time.Duration, now time.Time) error {
+	slugs, err := posts.List()
+	if err != nil {
+		return err
+	}
+	for _, slug := range slugs {
+		src, err := posts.Read(slug)
+		if err != nil {
+			continue
+		}
+		fm, _ := ParseFrontmatter(src)
+		published, ok := parsePostDate(fm.Date)
+		if !ok || published.After(now) || now.Sub(published) > maxAge || crossPostOptedOut(fm) || !postVisible(fm) {
+			continue
+		}
+		for _, p := range posters {
+			claimed, err := store.Claim(slug, p.Name())
+			if err != nil {
+				return err
+			}
+			if !claimed {
+				continue
+			}
+			if err := queue.Enqueue("crosspost", CrossPostJobPayload{Slug: slug, Platform: p.Name()}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RunCrossPostScanner checks for newly published posts every interval until
+// the context is cancelled. Scheduled posts are picked up once their date passes.
+func RunCrossPostScanner(ctx context.Context, interval time.Duration, store *CrossPostStore, queue *JobQueue, posts PostFiles, posters []CrossPoster, maxAge time.Duration) {
+	for {
+		if err := queueCrossPosts(store, queue, posts, posters, maxAge, time.Now()); err != nil {
+			slog.Error("Error queueing cross-posts", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// CrossPostJob returns a JobHandler that announces a post on one platform and
+// records the result for the admin
+func CrossPostJob(store *CrossPostStore, posts SlugReader, posters []CrossPoster, siteURL string) JobHandler {
+	byName := make(map[string]CrossPoster)
+	for _, p := range posters {
+		byName[p.Name()] = p
+	}
+	return func(ctx context.Context, payload []byte) error {
+		var p CrossPostJobPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return fmt.Errorf("decode crosspost job: %w", err)
+		}
+		poster, ok := byName[p.Platform]
+		if !ok {
+			return fmt.Errorf("cross-posting to %s is not configured", p.Platform)
+		}
+
+		src, err := posts.Read(p.Slug)
+		if err != nil {
+			return store.SetResult(p.Slug, p.Platform, CrossPostSkipped, "", "post no longer exists")
+		}
+		fm, _ := ParseFrontmatter(src)
+		if !postVisible(fm) || crossPostOptedOut(fm) {
+			return store.SetResult(p.Slug, p.Platform, CrossPostSkipped, "", "")
+		}
+		title := fm.Title
+		if title == "" {
+			title = toTitleCase(p.Slug)
+		}
+
+		link := siteURL + "/posts/" + p.Slug
+		url, err := poster.Post(ctx, crossPostText(title, link), link)
+		if err != nil {
+			if serr := store.SetResult(p.Slug, p.Platform, CrossPostFailed, "", err.Error()); serr != nil {
+				slog.Error("Error recording cross-post failure", "err", serr)
+			}
+			return err
+		}
+		return store.SetResult(p.Slug, p.Platform, CrossPostSent, url, "")
+	}
+}
+
+// crossPostersFromConfig returns a poster for each platform that is configured
+// and has its credentials in the environment
+func crossPostersFromConfig(c CrossPostConfig, client *http.Client) []CrossPoster {
+	var posters []CrossPoster
+	if c.Mastodon.Instance != "" && os.Getenv("MASTODON_TOKEN") != "" {
+		posters = append(posters, &MastodonPoster{Instance: c.Mastodon.Instance, Token: os.Getenv("MASTODON_TOKEN"), Client: client})
+	}
+	if c.Bluesky.Handle != "" && os.Getenv("BLUESKY_APP_PASSWORD") != "" {
+		posters = append(posters, &BlueskyPoster{PDS: c.Bluesky.PDS, Handle: c.Bluesky.Handle, AppPassword: os.Getenv("BLUESKY_APP_PASSWORD"), Client: client})
+	}
+	if c.X.Enabled && os.Getenv("X_API_KEY") != "" {
+		posters = append(posters, &XPoster{
+			APIKey:       os.Getenv("X_API_KEY"),
+			APISecret:    os.Getenv("X_API_SECRET"),
+			AccessToken:  os.Getenv("X_ACCESS_TOKEN"),
+			AccessSecret: os.Getenv("X_ACCESS_SECRET"),
+			Client:       client,
+		})
+	}
+	return posters
+}
+
+// postJSON sends body as JSON and decodes a JSON response into out
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, body, out any) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return doJSON(client, req, out)
+}
+
+// doJSON runs a request and decodes its JSON response, turning error statuses into errors
+func doJSON(client *http.Client, req *http.Request, out any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var raw bytes.Buffer
+	raw.ReadFrom(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg := bytes.TrimSpace(raw.Bytes())
+		if len(msg) > 300 {
+			msg = msg[:300]
+		}
+		return fmt.Errorf("%s %s: %s: %s", req.Method, req.URL.Path, resp.Status, msg)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(raw.Bytes(), out); err != nil {
+		return fmt.Errorf("%s %s: decode response: %w", req.Method, req.URL.Path, err)
+	}
+	return nil
+}
+
+// AdminCrossPostsHandler shows where each post was announced
+func AdminCrossPostsHandler(store *CrossPostStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list, err := store.List()
+		if err != nil {
+			slog.Error("Error listing cross-posts", "err", err)
+			http.Error(w, "Could not list cross-posts", http.StatusInternalServerError)
+			return
+		}
+
+		var content bytes.Buffer
+		content.WriteString("<div class=\"admin-page\">\n")
+		content.WriteString(adminNav())
+		content.WriteString("<h1>Cross-posts</h1>\n")
+		if len(list) == 0 {
+			content.WriteString("<p>Nothing cross-posted yet. Newly published posts are announced on the platforms configured under <code>crosspost:</code>.</p>\n")
+		} else {
+			content.WriteString("<table class=\"admin-table\">\n<tr><th>Post</th><th>Platform</th><th>Status</th><th>Details</th><th>Updated</th><th></th></tr>\n")
+			for _, c := range list {
+				slug, platform := template.HTMLEscapeString(c.Slug), template.HTMLEscapeString(c.Platform)
+				content.WriteString("<tr>")
+				content.WriteString("<td><a href=\"/posts/" + slug + "\">" + slug + "</a></td>")
+				content.WriteString("<td>" + platform + "</td>")
+				content.WriteString("<td><span class=\"crosspost-status crosspost-" + c.Status + "\">" + template.HTMLEscapeString(c.Status) + "</span></td>")
+				switch {
+				case c.URL != "":
+					content.WriteString("<td><a href=\"" + template.HTMLEscapeString(c.URL) + "\" rel=\"noopener\">View</a></td>")
+				case c.Error != "":
+					content.WriteString("<td><code>" + template.HTMLEscapeString(c.Error) + "</code></td>")
+				default:
+					content.WriteString("<td></td>")
+				}
+				content.WriteString("<td>" + c.UpdatedAt.Format("Jan 2, 2006 15:04") + "</td>")
+				content.WriteString("<td class=\"admin-actions\">")
+				if c.Status == CrossPostFailed || c.Status == CrossPostSkipped {
+					content.WriteString("<form method=\"post\" action=\"/admin/crossposts/" + slug + "/" + platform + "/retry\"><button type=\"submit\">Retry</button></form>")
+				}
+				content.WriteString("</td></tr>\n")
+			}
+			content.WriteString("</table>\n")
+		}
+		content.WriteString("</div>")
+
+		renderPage(w, "Cross-posts", template.HTML(content.String()))
+	}
+}
+
+// AdminCrossPostRetryHandler queues another delivery of a failed or skipped cross-post
+func AdminCrossPostRetryHandler(store *CrossPostStore, queue *JobQueue) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slug, platform := r.PathValue("slug"), r.PathValue("platform")
+		c, err := store.Get(slug, platform)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Cross-post not found", http.StatusNotFound)
+			return
+		}
+		if err == nil && c.Status != CrossPostSent {
+			if err = store.SetResult(slug, platform, CrossPostQueued, "", ""); err == nil {
+				err = queue.Enqueue("crosspost", CrossPostJobPayload{Slug: slug, Platform: platform})
+			}
+		}
+		if err != nil {
+			slog.Error("Error retrying cross-post", "slug", slug, "platform", platform, "err", err)
+			http.Error(w, "Could not retry cross-post", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/admin/crossposts", http.StatusSeeOther)
+	}
+}
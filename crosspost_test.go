@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakePoster records what it was asked to post
+type fakePoster struct {
+	name  string
+	err   error
+	texts []string
+}
+
+func (f *fakePoster) Name() string { return f.name }
+
+func (f *fakePoster) Post(ctx context.Context, text, link string) (string, error) {
+	if f.err != nil {
+		return "", f.err
+	}
+	f.texts = append(f.texts, text)
+	return "https://social.example/" + f.name + "/1", nil
+}
+
+func newTestCrossPostStore(t *testing.T) (*CrossPostStore, *JobQueue) {
+	t.Helper()
+	db := newTestDB(t)
+	store, err := NewCrossPostStore(db)
+	if err != nil {
+		t.Fatalf("NewCrossPostStore: %v", err)
+	}
+	queue, err := NewJobQueue(db)
+	if err != nil {
+		t.Fatalf("NewJobQueue: %v", err)
+	}
+	queue.Backoff = 0
+	return store, queue
+}
+
+func TestCrossPosting(t *testing.T) {
+	store, queue := newTestCrossPostStore(t)
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	posts := &MockSlugReader{content: map[string]string{
+		"fresh":     "---\ntitle: Fresh post\ndate: 2026-03-10T08:00:00Z\n---\n\nNew",
+		"old":       "---\ntitle: Old\ndate: 2025-01-01\n---\n\nArchive",
+		"scheduled": "---\ntitle: Later\ndate: 2026-04-01\n---\n\nSoon",
+		"opted-out": "---\ntitle: Quiet\ndate: 2026-03-10T08:00:00Z\ncrosspost: false\n---\n\nShh",
+		"undated":   "---\ntitle: Undated\n---\n\nWhen?",
+	}}
+	mastodon, bluesky := &fakePoster{name: "mastodon"}, &fakePoster{name: "bluesky"}
+	posters := []CrossPoster{mastodon, bluesky}
+	queue.Register("crosspost", CrossPostJob(store, posts, posters, "https://blog.example"))
+
+	// Scanning twice must not announce a post twice
+	for range 2 {
+		if err := queueCrossPosts(store, queue, posts, posters, 48*time.Hour, now); err != nil {
+			t.Fatalf("queueCrossPosts: %v", err)
+		}
+	}
+	for {
+		processed, err := queue.RunOnce(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !processed {
+			break
+		}
+	}
+
+	for _, p := range []*fakePoster{mastodon, bluesky} {
+		if len(p.texts) != 1 || p.texts[0] != "Fresh post\n\nhttps://blog.example/posts/fresh" {
+			t.Errorf("%s posted %q, want only the fresh post", p.name, p.texts)
+		}
+	}
+	c, err := store.Get("fresh", "mastodon")
+	if err != nil || c.Status != CrossPostSent || c.URL != "https://social.example/mastodon/1" {
+		t.Errorf("recorded %+v, %v", c, err)
+	}
+}
+
+func TestCrossPosting_DraftPublishedLater(t *testing.T) {
+	store, queue := newTestCrossPostStore(t)
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	posts := &MockSlugReader{content: map[string]string{
+		"wip": "---\ntitle: Work in progress\ndate: 2026-03-10T08:00:00Z\ndraft: true\n---\n\nSoon",
+	}}
+	poster := &fakePoster{name: "mastodon"}
+	posters := []CrossPoster{poster}
+
+	if err := queueCrossPosts(store, queue, posts, posters, 48*time.Hour, now); err != nil {
+		t.Fatalf("queueCrossPosts: %v", err)
+	}
+	if _, err := store.Get("wip", "mastodon"); err == nil {
+		t.Fatal("draft was claimed for cross-posting")
+	}
+
+	posts.content["wip"] = "---\ntitle: Work in progress\ndate: 2026-03-10T08:00:00Z\n---\n\nDone"
+	if err := queueCrossPosts(store, queue, posts, posters, 48*time.Hour, now); err != nil {
+		t.Fatalf("queueCrossPosts: %v", err)
+	}
+	if c, err := store.Get("wip", "mastodon"); err != nil || c.Status != CrossPostQueued {
+		t.Errorf("after publishing: %+v, %v", c, err)
+	}
+}
+
+func TestCrossPostJob_RecordsFailure(t *testing.T) {
+	store, queue := newTestCrossPostStore(t)
+	posts := &MockSlugReader{content: map[string]string{"hello": "---\ntitle: Hello\n---\n\nHi"}}
+	poster := &fakePoster{name: "x", err: errors.New("rate limited")}
+	job := CrossPostJob(store, posts, []CrossPoster{poster}, "https://blog.example")
+
+	store.Claim("hello", "x")
+	if err := job(context.Background(), []byte(`{"slug": "hello", "platform": "x"}`)); err == nil {
+		t.Fatal("expected the delivery error to be returned for a retry")
+	}
+	c, _ := store.Get("hello", "x")
+	if c.Status != CrossPostFailed || c.Error != "rate limited" {
+		t.Errorf("recorded %+v", c)
+	}
+
+	// Retrying from the admin queues it again
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/admin/crossposts/hello/x/retry", nil)
+	req.SetPathValue("slug", "hello")
+	req.SetPathValue("platform", "x")
+	AdminCrossPostRetryHandler(store, queue)(w, req)
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("retry status = %d", w.Code)
+	}
+	if c, _ := store.Get("hello", "x"); c.Status != CrossPostQueued {
+		t.Errorf("status after retry = %s", c.Status)
+	}
+
+	w = httptest.NewRecorder()
+	AdminCrossPostsHandler(store)(w, httptest.NewRequest("GET", "/admin/crossposts", nil))
+	if !strings.Contains(w.Body.String(), `<span class="crosspost-status crosspost-queued">queued</span>`) {
+		t.Errorf("admin page missing status: %s", w.Body.String())
+	}
+}
+
+func TestCrossPostText(t *testing.T) {
+	long := strings.Repeat("ก", 250)
+	got := crossPostText(long, "https://blog.example/posts/x")
+	if n := len([]rune(got)); n > 300 {
+		t.Errorf("text is %d characters, over Bluesky's limit", n)
+	}
+	if !strings.HasSuffix(got, "…\n\nhttps://blog.example/posts/x") {
+		t.Errorf("expected a truncated title and the link, got %q", got)
+	}
+}
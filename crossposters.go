@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MastodonPoster posts statuses to a Mastodon account with an access token
+// that has the write:statuses scope
+type MastodonPoster struct {
+	// Instance is the server's base URL, e.g. https://mastodon.social
+	Instance string
+	Token    string
+	Client   *http.Client
+}
+
+func (m *MastodonPoster) Name() string { return "mastodon" }
+
+func (m *MastodonPoster) Post(ctx context.Context, text, link string) (string, error) {
+	form := url.Values{"status": {text}, "visibility": {"public"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(m.Instance, "/")+"/api/v1/statuses", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+m.Token)
+	// A retried job must not post twice
+	sum := sha256.Sum256([]byte(link))
+	req.Header.Set("Idempotency-Key", hex.EncodeToString(sum[:]))
+
+	var status struct {
+		URL string `json:"url"`
+	}
+	if err := doJSON(m.Client, req, &status); err != nil {
+		return "", fmt.Errorf("mastodon: %w", err)
+	}
+	return status.URL, nil
+}
+
+// BlueskyPoster posts to Bluesky with an app password
+type BlueskyPoster struct {
+	// PDS is the account's server; empty means https://bsky.social
+	PDS         string
+	Handle      string
+	AppPassword string
+	Client      *http.Client
+}
+
+func (b *BlueskyPoster) Name() string { return "bluesky" }
+
+func (b *BlueskyPoster) Post(ctx context.Context, text, link string) (string, error) {
+	pds := strings.TrimSuffix(b.PDS, "/")
+	if pds == "" {
+		pds = "https://bsky.social"
+	}
+
+	var session struct {
+		AccessJwt string `json:"accessJwt"`
+		DID       string `json:"did"`
+	}
+	err := postJSON(ctx, b.Client, pds+"/xrpc/com.atproto.server.createSession", nil,
+		map[string]string{"identifier": b.Handle, "password": b.AppPassword}, &session)
+	if err != nil {
+		return "", fmt.Errorf("bluesky: %w", err)
+	}
+
+	// Bluesky does not detect links in text; a facet marks the byte range
+	record := map[string]any{
+		"$type":     "app.bsky.feed.post",
+		"text":      text,
+		"createdAt": time.Now().UTC().Format(time.RFC3339),
+	}
+	if i := strings.LastIndex(text, link); i >= 0 {
+		record["facets"] = []any{map[string]any{
+			"index":    map[string]int{"byteStart": i, "byteEnd": i + len(link)},
+			"features": []any{map[string]string{"$type": "app.bsky.richtext.facet#link", "uri": link}},
+		}}
+	}
+
+	var created struct {
+		URI string `json:"uri"`
+	}
+	err = postJSON(ctx, b.Client, pds+"/xrpc/com.atproto.repo.createRecord",
+		map[string]string{"Authorization": "Bearer " + session.AccessJwt},
+		map[string]any{"repo": session.DID, "collection": "app.bsky.feed.post", "record": record}, &created)
+	if err != nil {
+		return "", fmt.Errorf("bluesky: %w", err)
+	}
+
+	// at://did/app.bsky.feed.post/rkey -> https://bsky.app/profile/did/post/rkey
+	rkey := created.URI[strings.LastIndex(created.URI, "/")+1:]
+	return "https://bsky.app/profile/" + session.DID + "/post/" + rkey, nil
+}
+
+// XPoster posts to X through the v2 API, signing requests with OAuth 1.0a user
+// credentials from the app's developer portal
+type XPoster struct {
+	APIKey       string
+	APISecret    string
+	AccessToken  string
+	AccessSecret string
+	// Endpoint overrides the create-post URL, for tests
+	Endpoint string
+	Client   *http.Client
+}
+
+func (x *XPoster) Name() string { return "x" }
+
+func (x *XPoster) Post(ctx context.Context, text, link string) (string, error) {
+	endpoint := x.Endpoint
+	if endpoint == "" {
+		endpoint = "https://api.x.com/2/tweets"
+	}
+	var created struct {
+		Data struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	auth := x.authorization(http.MethodPost, endpoint, time.Now())
+	if err := postJSON(ctx, x.Client, endpoint, map[string]string{"Authorization": auth}, map[string]string{"text": text}, &created); err != nil {
+		return "", fmt.Errorf("x: %w", err)
+	}
+	return "https://x.com/i/web/status/" + created.Data.ID, nil
+}
+
+// authorization builds the OAuth 1.0a header. JSON bodies are not part of the
+// signature, so only the oauth_ parameters are signed.
+func (x *XPoster) authorization(method, endpoint string, now time.Time) string {
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	params := map[string]string{
+		"oauth_consumer_key":     x.APIKey,
+		"oauth_nonce":            hex.EncodeToString(nonce),
+		"oauth_signature_method": "HMAC-SHA1",
+		"oauth_timestamp":        strconv.FormatInt(now.Unix(), 10),
+		"oauth_token":            x.AccessToken,
+		"oauth_version":          "1.0",
+	}
+	params["oauth_signature"] = oauthSignature(method, endpoint, params, x.APISecret, x.AccessSecret)
+
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = oauthEscape(k) + `="` + oauthEscape(params[k]) + `"`
+	}
+	return "OAuth " + strings.Join(parts, ", ")
+}
+
+// oauthSignature computes an OAuth 1.0a HMAC-SHA1 signature (RFC 5849 section 3.4)
+func oauthSignature(method, endpoint string, params map[string]string, consumerSecret, tokenSecret string) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = oauthEscape(k) + "=" + oauthEscape(params[k])
+	}
+	base := method + "&" + oauthEscape(endpoint) + "&" + oauthEscape(strings.Join(pairs, "&"))
+	mac := hmac.New(sha1.New, []byte(oauthEscape(consumerSecret)+"&"+oauthEscape(tokenSecret)))
+	mac.Write([]byte(base))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// oauthEscape percent-encodes everything but unreserved characters, as OAuth requires
+func oauthEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOAuthSignature(t *testing.T) {
+	// The worked example from X's "Creating a signature" documentation
+	params := map[string]string{
+		"status":                 "Hello Ladies + Gentlemen, a signed OAuth request!",
+		"include_entities":       "true",
+		"oauth_consumer_key":     "xvz1evFS4wEEPTGEFPHBog",
+		"oauth_nonce":            "kYjzVBB8Y0ZFabxSWbWovY3uYSQ2pTgmZeNu2VS4cg",
+		"oauth_signature_method": "HMAC-SHA1",
+		"oauth_timestamp":        "1318622958",
+		"oauth_token":            "370773112-GmHxMAgYyLbNEtIKZeRNFsMKPR9EyMZeS9weJAEb",
+		"oauth_version":          "1.0",
+	}
+	got := oauthSignature("POST", "https://api.twitter.com/1.1/statuses/update.json", params,
+		"kAcSOqF21Fu85e7zjz7ZN2U4ZRhfV3WpwPAoE3Z7kBw", "LswwdoUaIvS8ltyTt5jkRh4J50vUPVVHtR2YPi5kE")
+	if want := "hCtSmYh+iHYCEqBWrE7C7hYmtUk="; got != want {
+		t.Errorf("oauthSignature = %s, want %s", got, want)
+	}
+}
+
+func TestMastodonPoster(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/statuses" || r.Header.Get("Authorization") != "Bearer tok" || r.Header.Get("Idempotency-Key") == "" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if r.PostFormValue("status") != "Hello\n\nhttps://blog.example/posts/hello" {
+			http.Error(w, "bad status", http.StatusUnprocessableEntity)
+			return
+		}
+		w.Write([]byte(`{"url": "https://mastodon.example/@me/1"}`))
+	}))
+	defer srv.Close()
+
+	m := &MastodonPoster{Instance: srv.URL + "/", Token: "tok", Client: srv.Client()}
+	got, err := m.Post(context.Background(), "Hello\n\nhttps://blog.example/posts/hello", "https://blog.example/posts/hello")
+	if err != nil || got != "https://mastodon.example/@me/1" {
+		t.Errorf("Post = %q, %v", got, err)
+	}
+
+	m.Token = "wrong"
+	if _, err := m.Post(context.Background(), "x", "y"); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("expected a status error, got %v", err)
+	}
+}
+
+func TestBlueskyPoster(t *testing.T) {
+	var record map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/xrpc/com.atproto.server.createSession":
+			w.Write([]byte(`{"accessJwt": "jwt", "did": "did:plc:abc"}`))
+		case "/xrpc/com.atproto.repo.createRecord":
+			if r.Header.Get("Authorization") != "Bearer jwt" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			var body struct {
+				Record map[string]any `json:"record"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			record = body.Record
+			w.Write([]byte(`{"uri": "at://did:plc:abc/app.bsky.feed.post/3kxyz"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	b := &BlueskyPoster{PDS: srv.URL, Handle: "me.example", AppPassword: "pw", Client: srv.Client()}
+	text := "สวัสดี\n\nhttps://blog.example/posts/hi"
+	got, err := b.Post(context.Background(), text, "https://blog.example/posts/hi")
+	if err != nil || got != "https://bsky.app/profile/did:plc:abc/post/3kxyz" {
+		t.Fatalf("Post = %q, %v", got, err)
+	}
+
+	// The link facet is a byte range, so Thai text before it must count in bytes
+	facet := record["facets"].([]any)[0].(map[string]any)["index"].(map[string]any)
+	start, end := int(facet["byteStart"].(float64)), int(facet["byteEnd"].(float64))
+	if text[start:end] != "https://blog.example/posts/hi" {
+		t.Errorf("facet covers %q", text[start:end])
+	}
+}
+
+func TestXPoster(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "OAuth ") || !strings.Contains(auth, `oauth_consumer_key="key"`) || !strings.Contains(auth, "oauth_signature=") {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var req struct{ Text string }
+		json.Unmarshal(body, &req)
+		if req.Text != "Hi" {
+			http.Error(w, "bad text", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"data": {"id": "123", "text": "Hi"}}`))
+	}))
+	defer srv.Close()
+
+	x := &XPoster{APIKey: "key", APISecret: "s", AccessToken: "t", AccessSecret: "ts", Endpoint: srv.URL, Client: srv.Client()}
+	got, err := x.Post(context.Background(), "Hi", "")
+	if err != nil || got != "https://x.com/i/web/status/123" {
+		t.Errorf("Post = %q, %v", got, err)
+	}
+}
+
+func TestOAuthEscape(t *testing.T) {
+	if got := oauthEscape("a b+c~d/é"); got != "a%20b%2Bc~d%2F%C3%A9" {
+		t.Errorf("oauthEscape = %q", got)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// wikiLinkRegex matches [[slug]], [[slug#section]], and [[slug|label]]
+var wikiLinkRegex = regexp.MustCompile(`\[\[([^\[\]|#]+)(#[^\[\]|]*)?(?:\|([^\[\]]+))?\]\]`)
+
+// linkedPosts resolves [[slug]] links while rendering. main points it at the
+// post index; without one every link renders as broken.
+var linkedPosts *PostIndex
+
+// BrokenLink is a [[slug]] link in a post that matches no post
+type BrokenLink struct {
+	Slug   string
+	Target string
+}
+
+// String describes the broken link for logs and the check command
+func (l BrokenLink) String() string {
+	return "/posts/" + l.Slug + ": [[" + l.Target + "]] matches no post"
+}
+
+// lookupLink finds the post a [[target]] link in from means: the post named
+// target, or else target in from's language, or in any language
+func lookupLink(entries map[string]*indexedPost, from, target string) (*indexedPost, bool) {
+	if p, ok := entries[target]; ok {
+		return p, true
+	}
+	langs := postLangs
+	if lang, _ := postLang(from); lang != "" {
+		langs = append([]string{lang}, postLangs...)
+	}
+	for _, lang := range langs {
+		if p, ok := entries[lang+"-"+target]; ok {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// ResolveLink returns the post a [[target]] link in from points to
+func (idx *PostIndex) ResolveLink(from, target string) (*indexedPost, bool) {
+	if idx == nil {
+		return nil, false
+	}
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return lookupLink(idx.entries, from, target)
+}
+
+// eachWikiLink calls fn with every [[slug]] link in markdown outside fenced
+// and inline code, and returns the markdown with each link replaced by what
+// fn returns
+func eachWikiLink(src string, fn func(target, fragment, label string) string) string {
+	var out strings.Builder
+	inFence := false
+	for _, line := range strings.SplitAfter(src, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+		}
+		if inFence || !strings.Contains(line, "[[") {
+			out.WriteString(line)
+			continue
+		}
+		// Odd parts are inside `code`
+		parts := strings.Split(line, "`")
+		for i := 0; i < len(parts); i += 2 {
+			parts[i] = wikiLinkRegex.ReplaceAllStringFunc(parts[i], func(m string) string {
+				sub := wikiLinkRegex.FindStringSubmatch(m)
+				return fn(strings.TrimSpace(sub[1]), sub[2], strings.TrimSpace(sub[3]))
+			})
+		}
+		out.WriteString(strings.Join(parts, "`"))
+	}
+	return out.String()
+}
+
+// wikiLinkTargets returns the slugs a post links to with [[slug]]
+func wikiLinkTargets(body string) []string {
+	var targets []string
+	eachWikiLink(body, func(target, _, _ string) string {
+		if !slices.Contains(targets, target) {
+			targets = append(targets, target)
+		}
+		return ""
+	})
+	return targets
+}
+
+// expandWikiLinks swaps the [[slug]] links in a post for placeholders and
+// returns their HTML: a link titled like the post, a plain label for a post
+// that isn't published yet, or a label marked as broken when no post matches
+func expandWikiLinks(src, slug string) (string, []string) {
+	var links []string
+	out := eachWikiLink(src, func(target, fragment, label string) string {
+		p, ok := linkedPosts.ResolveLink(slug, target)
+		if label == "" {
+			label = target
+			if ok {
+				label = p.Title
+			}
+		}
+		esc := template.HTMLEscapeString(label)
+		html := "<span class=\"xref xref-broken\" title=\"No post named " + template.HTMLEscapeString(target) + "\">" + esc + "</span>"
+		switch {
+		case ok && postVisible(p.FM):
+			html = "<a class=\"xref\" href=\"/posts/" + template.HTMLEscapeString(p.Slug+fragment) + "\">" + esc + "</a>"
+		case ok:
+			html = "<span class=\"xref\">" + esc + "</span>"
+		}
+		links = append(links, html)
+		return wikiLinkPlaceholder(len(links) - 1)
+	})
+	return out, links
+}
+
+// restoreWikiLinks swaps placeholders in rendered HTML for the links. Unlike
+// shortcodes they are inline, so paragraphs around them are kept.
+func restoreWikiLinks(html string, links []string) string {
+	for i, link := range links {
+		html = strings.Replace(html, wikiLinkPlaceholder(i), link, 1)
+	}
+	return html
+}
+
+func wikiLinkPlaceholder(i int) string {
+	return fmt.Sprintf("@@xref-%d@@", i)
+}
+
+// findCrossRefs maps each post to the posts linking to it with [[slug]], and
+// lists the links that match no post. The caller must hold the lock.
+func (idx *PostIndex) findCrossRefs() (map[string][]string, []BrokenLink) {
+	backlinks := make(map[string][]string)
+	var broken []BrokenLink
+	for slug, p := range idx.entries {
+		for _, target := range p.links {
+			linked, ok := lookupLink(idx.entries, slug, target)
+			if !ok {
+				broken = append(broken, BrokenLink{Slug: slug, Target: target})
+				continue
+			}
+			if linked.Slug != slug {
+				backlinks[linked.Slug] = append(backlinks[linked.Slug], slug)
+			}
+		}
+	}
+	sort.Slice(broken, func(i, j int) bool {
+		if broken[i].Slug != broken[j].Slug {
+			return broken[i].Slug < broken[j].Slug
+		}
+		return broken[i].Target < broken[j].Target
+	})
+	return backlinks, broken
+}
+
+// Backlinks returns the published posts that link to slug, newest first
+func (idx *PostIndex) Backlinks(slug string) []Post {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	seen := make(map[string]bool)
+	var posts []Post
+	for _, from := range idx.backlinks[slug] {
+		p, ok := idx.entries[from]
+		if !ok || seen[from] || !postVisible(p.FM) {
+			continue
+		}
+		seen[from] = true
+		posts = append(posts, p.Post)
+	}
+	sort.Slice(posts, func(i, j int) bool {
+		if !posts[i].Date.Equal(posts[j].Date) {
+			return posts[i].Date.After(posts[j].Date)
+		}
+		return posts[i].Slug < posts[j].Slug
+	})
+	return posts
+}
+
+// BrokenLinks returns the [[slug]] links that match no post
+func (idx *PostIndex) BrokenLinks() []BrokenLink {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.brokenLinks
+}
+
+// backlinkFinder knows which posts link to each other, as PostIndex does
+type backlinkFinder interface {
+	Backlinks(slug string) []Post
+}
+
+// backlinksHTML renders the "Posts linking here" section under a post, or
+// nothing when no post links to it
+func backlinksHTML(posts []Post) string {
+	if len(posts) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("<section class=\"backlinks\" aria-labelledby=\"backlinks-title\">\n<h2 id=\"backlinks-title\">Posts linking here</h2>\n<ul>\n")
+	for _, p := range posts {
+		b.WriteString("<li><a href=\"/posts/" + template.HTMLEscapeString(p.Slug) + "\">" + template.HTMLEscapeString(p.Title) + "</a></li>\n")
+	}
+	b.WriteString("</ul>\n</section>\n")
+	return b.String()
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// newCrossRefIndex indexes posts written to a temporary directory and uses
+// the index to resolve [[slug]] links for the rest of the test
+func newCrossRefIndex(t *testing.T, files map[string]string) *PostIndex {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	idx := newTestPostIndex(t, dir)
+	old := linkedPosts
+	t.Cleanup(func() { linkedPosts = old })
+	linkedPosts = idx
+	return idx
+}
+
+func TestWikiLinkTargets(t *testing.T) {
+	body := "See [[intro]] and [[setup#install|the setup]].\n\n" +
+		"Not `[[in-code]]`, again [[intro]].\n\n```\n[[fenced]]\n```\n"
+	got := wikiLinkTargets(body)
+	if want := []string{"intro", "setup"}; !slices.Equal(got, want) {
+		t.Errorf("wikiLinkTargets = %q, want %q", got, want)
+	}
+}
+
+func TestExpandWikiLinks(t *testing.T) {
+	newCrossRefIndex(t, map[string]string{
+		"intro.md":    "---\ntitle: Intro & Overview\n---\nHi\n",
+		"th-hello.md": "---\ntitle: สวัสดี\n---\nHi\n",
+		"en-hello.md": "---\ntitle: Hello\n---\nHi\n",
+		"draft.md":    "---\ntitle: Draft\ndraft: true\n---\nHi\n",
+	})
+
+	tests := []struct {
+		name, slug, src, want string
+	}{
+		{"title", "a", "[[intro]]", `<a class="xref" href="/posts/intro">Intro &amp; Overview</a>`},
+		{"label", "a", "[[intro|start here]]", `<a class="xref" href="/posts/intro">start here</a>`},
+		{"fragment", "a", "[[intro#why]]", `<a class="xref" href="/posts/intro#why">`},
+		{"same language", "th-a", "[[hello]]", `<a class="xref" href="/posts/th-hello">สวัสดี</a>`},
+		{"other language", "en-a", "[[hello]]", `<a class="xref" href="/posts/en-hello">Hello</a>`},
+		{"unpublished", "a", "[[draft]]", `<span class="xref">Draft</span>`},
+		{"broken", "a", "[[missing]]", `<span class="xref xref-broken" title="No post named missing">missing</span>`},
+		{"inline code", "a", "`[[intro]]`", `<code>[[intro]]</code>`},
+	}
+	for _, tt := range tests {
+		got, err := renderMarkdown(tt.src, tt.slug)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(got, tt.want) {
+			t.Errorf("%s: %s renders\n%s\nwant it to contain %s", tt.name, tt.src, got, tt.want)
+		}
+	}
+
+	// An inline link keeps its paragraph
+	got, _ := renderMarkdown("[[intro]]", "a")
+	if !strings.HasPrefix(got, "<p><a class=\"xref\"") {
+		t.Errorf("link on its own line lost its paragraph: %s", got)
+	}
+}
+
+func TestPostIndex_CrossRefs(t *testing.T) {
+	idx := newCrossRefIndex(t, map[string]string{
+		"intro.md":    "---\ntitle: Intro\n---\nStart here.\n",
+		"older.md":    "---\ntitle: Older\ndate: 2024-01-01\n---\nSee [[intro]] and [[intro#more]].\n",
+		"newer.md":    "---\ntitle: Newer\ndate: 2025-01-01\n---\nSee [[intro|the intro]] and [[nowhere]].\n",
+		"draft.md":    "---\ntitle: Draft\ndraft: true\n---\nSee [[intro]].\n",
+		"th-guide.md": "---\ntitle: Guide\n---\nSee [[intro]] and `[[missing-in-code]]`.\n",
+	})
+
+	var got []string
+	for _, p := range idx.Backlinks("intro") {
+		got = append(got, p.Slug)
+	}
+	// Newest first, drafts left out; th-guide is dated by its file
+	if want := []string{"th-guide", "newer", "older"}; !slices.Equal(got, want) {
+		t.Errorf("Backlinks(intro) = %q, want %q", got, want)
+	}
+	if b := idx.Backlinks("newer"); len(b) != 0 {
+		t.Errorf("Backlinks(newer) = %v, want none", b)
+	}
+
+	broken := idx.BrokenLinks()
+	if len(broken) != 1 || broken[0] != (BrokenLink{Slug: "newer", Target: "nowhere"}) {
+		t.Fatalf("BrokenLinks = %v", broken)
+	}
+	if s := broken[0].String(); s != "/posts/newer: [[nowhere]] matches no post" {
+		t.Errorf("String() = %q", s)
+	}
+
+	// Writing the missing post fixes the link
+	if err := idx.Write("nowhere", "---\ntitle: Nowhere\n---\nFound.\n"); err != nil {
+		t.Fatal(err)
+	}
+	if broken := idx.BrokenLinks(); len(broken) != 0 {
+		t.Errorf("BrokenLinks after writing the target = %v", broken)
+	}
+	if b := idx.Backlinks("nowhere"); len(b) != 1 || b[0].Slug != "newer" {
+		t.Errorf("Backlinks(nowhere) = %v", b)
+	}
+}
+
+func TestPostHandler_Backlinks(t *testing.T) {
+	idx := newCrossRefIndex(t, map[string]string{
+		"intro.md": "---\ntitle: Intro\n---\nStart here.\n",
+		"later.md": "---\ntitle: Later <3\n---\nBack to [[intro]].\n",
+	})
+
+	for _, tt := range []struct {
+		slug, want string
+		present    bool
+	}{
+		{"intro", `<li><a href="/posts/later">Later &lt;3</a></li>`, true},
+		{"later", `class="backlinks"`, false},
+	} {
+		r := httptest.NewRequest("GET", "/posts/"+tt.slug, nil)
+		r.SetPathValue("slug", tt.slug)
+		w := httptest.NewRecorder()
+		PostHandler(idx, nil, nil)(w, r)
+		if got := strings.Contains(w.Body.String(), tt.want); got != tt.present {
+			t.Errorf("%s: contains %s = %v, want %v:\n%s", tt.slug, tt.want, got, tt.present, w.Body.String())
+		}
+	}
+}
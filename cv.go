@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// cvFile holds the structured CV shown at /cv and /cv.pdf
+const cvFile = "data/cv.yaml"
+
+// LocalizedList is a list with per-language variants: a plain YAML sequence
+// used for every language, or a map of language to sequence
+type LocalizedList map[string][]string
+
+// UnmarshalYAML accepts a plain sequence as well as a language map
+func (l *LocalizedList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.SequenceNode {
+		var items []string
+		if err := node.Decode(&items); err != nil {
+			return err
+		}
+		*l = LocalizedList{"": items}
+		return nil
+	}
+	var m map[string][]string
+	if err := node.Decode(&m); err != nil {
+		return err
+	}
+	*l = m
+	return nil
+}
+
+// In returns the list for lang, falling back like Localized.In
+func (l LocalizedList) In(lang string) []string {
+	for _, key := range []string{lang, "", "en"} {
+		if items, ok := l[key]; ok {
+			return items
+		}
+	}
+	return nil
+}
+
+// CV is a résumé in one or more languages
+type CV struct {
+	Name       Localized      `yaml:"name"`
+	Headline   Localized      `yaml:"headline"`
+	Location   Localized      `yaml:"location"`
+	Email      string         `yaml:"email"`
+	Website    string         `yaml:"website"`
+	Summary    Localized      `yaml:"summary"`
+	Experience []CVEntry      `yaml:"experience"`
+	Education  []CVEntry      `yaml:"education"`
+	Skills     []CVSkillGroup `yaml:"skills"`
+}
+
+// CVEntry is a job or a degree
+type CVEntry struct {
+	// Title is the role or degree, Org the company or school
+	Title    Localized `yaml:"title"`
+	Org      Localized `yaml:"org"`
+	Location Localized `yaml:"location"`
+	// Start and End are YYYY-MM or YYYY; an empty End means ongoing
+	Start      string        `yaml:"start"`
+	End        string        `yaml:"end"`
+	Highlights LocalizedList `yaml:"highlights"`
+}
+
+// CVSkillGroup is a labelled list of skills
+type CVSkillGroup struct {
+	Name  Localized `yaml:"name"`
+	Items []string  `yaml:"items"`
+}
+
+var cvLabels = map[string]Localized{
+	"title":      {"en": "CV", "th": "ประวัติย่อ"},
+	"summary":    {"en": "Summary", "th": "สรุป"},
+	"experience": {"en": "Experience", "th": "ประสบการณ์ทำงาน"},
+	"education":  {"en": "Education", "th": "การศึกษา"},
+	"skills":     {"en": "Skills", "th": "ทักษะ"},
+	"present":    {"en": "Present", "th": "ปัจจุบัน"},
+	"pdf":        {"en": "Download PDF", "th": "ดาวน์โหลด PDF"},
+}
+
+var thaiMonths = [12]string{"ม.ค.", "ก.พ.", "มี.ค.", "เม.ย.", "พ.ค.", "มิ.ย.", "ก.ค.", "ส.ค.", "ก.ย.", "ต.ค.", "พ.ย.", "ธ.ค."}
+
+// loadCV reads the CV data file; a missing file is reported as fs.ErrNotExist
+func loadCV(path string) (CV, error) {
+	var cv CV
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cv, err
+	}
+	if err := yaml.Unmarshal(data, &cv); err != nil {
+		return cv, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return cv, nil
+}
+
+// cvDate formats a YYYY-MM or YYYY date for lang
+func cvDate(s, lang string) string {
+	t, err := time.Parse("2006-01", s)
+	if err != nil {
+		return s
+	}
+	if lang == "th" {
+		return thaiMonths[t.Month()-1] + " " + t.Format("2006")
+	}
+	return t.Format("Jan 2006")
+}
+
+// cvPeriod formats the span of an entry, e.g. "Jan 2022 – Present"
+func cvPeriod(e CVEntry, lang string) string {
+	if e.Start == "" {
+		return cvDate(e.End, lang)
+	}
+	end := cvLabels["present"].In(lang)
+	if e.End != "" {
+		end = cvDate(e.End, lang)
+	}
+	return cvDate(e.Start, lang) + " – " + end
+}
+
+// cvEntryHeading joins an entry's title, organisation, and location
+func cvEntryHeading(e CVEntry, lang string) (string, string) {
+	sub := e.Org.In(lang)
+	if loc := e.Location.In(lang); loc != "" {
+		sub = strings.Trim(sub+", "+loc, ", ")
+	}
+	return e.Title.In(lang), sub
+}
+
+// cvHTML renders the CV as a page in lang
+func cvHTML(cv CV, lang string) string {
+	var b bytes.Buffer
+	b.WriteString("<div class=\"cv\">\n<header class=\"cv-header\">\n")
+	b.WriteString("<h1>" + template.HTMLEscapeString(cv.Name.In(lang)) + "</h1>\n")
+	if h := cv.Headline.In(lang); h != "" {
+		b.WriteString("<p class=\"cv-headline\">" + template.HTMLEscapeString(h) + "</p>\n")
+	}
+	var contact []string
+	if loc := cv.Location.In(lang); loc != "" {
+		contact = append(contact, template.HTMLEscapeString(loc))
+	}
+	if cv.Email != "" {
+		contact = append(contact, "<a href=\"mailto:"+template.HTMLEscapeString(cv.Email)+"\">"+template.HTMLEscapeString(cv.Email)+"</a>")
+	}
+	if u := linkPostURL(cv.Website); u != "" {
+		contact = append(contact, "<a href=\""+template.HTMLEscapeString(u)+"\">"+template.HTMLEscapeString(linkPostHost(u))+"</a>")
+	}
+	contact = append(contact, "<a href=\"/cv.pdf?lang="+cvPDFLang(lang)+"\">"+template.HTMLEscapeString(cvLabels["pdf"].In(cvPDFLang(lang)))+"</a>")
+	b.WriteString("<p class=\"cv-contact\">" + strings.Join(contact, " · ") + "</p>\n</header>\n")
+
+	if s := cv.Summary.In(lang); s != "" {
+		b.WriteString("<section>\n<h2>" + template.HTMLEscapeString(cvLabels["summary"].In(lang)) + "</h2>\n")
+		b.WriteString("<p>" + template.HTMLEscapeString(s) + "</p>\n</section>\n")
+	}
+
+	for _, section := range []struct {
+		label   string
+		entries []CVEntry
+	}{{"experience", cv.Experience}, {"education", cv.Education}} {
+		if len(section.entries) == 0 {
+			continue
+		}
+		b.WriteString("<section>\n<h2>" + template.HTMLEscapeString(cvLabels[section.label].In(lang)) + "</h2>\n")
+		for _, e := range section.entries {
+			title, sub := cvEntryHeading(e, lang)
+			b.WriteString("<div class=\"cv-entry\">\n<h3>" + template.HTMLEscapeString(title))
+			b.WriteString(" <span class=\"cv-period\">" + template.HTMLEscapeString(cvPeriod(e, lang)) + "</span></h3>\n")
+			if sub != "" {
+				b.WriteString("<p class=\"cv-org\">" + template.HTMLEscapeString(sub) + "</p>\n")
+			}
+			if items := e.Highlights.In(lang); len(items) > 0 {
+				b.WriteString("<ul>\n")
+				for _, item := range items {
+					b.WriteString("<li>" + template.HTMLEscapeString(item) + "</li>\n")
+				}
+				b.WriteString("</ul>\n")
+			}
+			b.WriteString("</div>\n")
+		}
+		b.WriteString("</section>\n")
+	}
+
+	if len(cv.Skills) > 0 {
+		b.WriteString("<section>\n<h2>" + template.HTMLEscapeString(cvLabels["skills"].In(lang)) + "</h2>\n<dl class=\"cv-skills\">\n")
+		for _, g := range cv.Skills {
+			b.WriteString("<dt>" + template.HTMLEscapeString(g.Name.In(lang)) + "</dt><dd>" + template.HTMLEscapeString(strings.Join(g.Items, ", ")) + "</dd>\n")
+		}
+		b.WriteString("</dl>\n</section>\n")
+	}
+	b.WriteString("</div>\n")
+	return b.String()
+}
+
+// CVHandler renders the CV page from the CV data file
+func CVHandler(path string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setSecurityHeaders(w)
+
+		cv, err := loadCV(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			renderError(w, http.StatusNotFound, requestLang(r))
+			return
+		}
+		if err != nil {
+			slog.Error("Error loading CV", "err", err)
+			renderError(w, http.StatusInternalServerError, requestLang(r))
+			return
+		}
+		lang := requestLang(r)
+		renderPage(w, cvLabels["title"].In(lang), template.HTML(cvHTML(cv, lang)))
+	}
+}
+
+// cvPDFLang is the language the PDF can be printed in: the core PDF fonts have
+// no Thai, so a Thai PDF needs cv.pdf_font
+func cvPDFLang(lang string) string {
+	if lang == "th" && cfg.CV.PDFFont == "" {
+		return "en"
+	}
+	return lang
+}
+
+// cvPDFFonts returns the fonts for the PDF: the configured TrueType files, or
+// Helvetica when none is set
+func cvPDFFonts() (pdfFont, pdfFont, error) {
+	if cfg.CV.PDFFont == "" {
+		return helveticaFont{}, helveticaFont{bold: true}, nil
+	}
+	load := func(path string) (pdfFont, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return newTrueTypeFont(data)
+	}
+	regular, err := load(cfg.CV.PDFFont)
+	if err != nil {
+		return nil, nil, err
+	}
+	bold := regular
+	if cfg.CV.PDFFontBold != "" {
+		if bold, err = load(cfg.CV.PDFFontBold); err != nil {
+			return nil, nil, err
+		}
+	}
+	return regular, bold, nil
+}
+
+// cvPDF lays out the CV as a printable PDF in lang
+func cvPDF(cv CV, lang string, regular, bold pdfFont) []byte {
+	d := NewPDFDocument(cv.Name.In(lang)+" – "+cvLabels["title"].In(lang), regular, bold)
+	d.Text(cv.Name.In(lang), 22, true, 0)
+	if h := cv.Headline.In(lang); h != "" {
+		d.Text(h, 12, false, 0)
+	}
+	var contact []string
+	for _, s := range []string{cv.Location.In(lang), cv.Email, cv.Website} {
+		if s != "" {
+			contact = append(contact, s)
+		}
+	}
+	d.Text(strings.Join(contact, "  ·  "), 9.5, false, 0)
+
+	heading := func(label string) {
+		d.Space(10)
+		d.Text(cvLabels[label].In(lang), 13, true, 0)
+		d.Rule()
+	}
+	if s := cv.Summary.In(lang); s != "" {
+		heading("summary")
+		d.Text(s, 10, false, 0)
+	}
+	for _, section := range []struct {
+		label   string
+		entries []CVEntry
+	}{{"experience", cv.Experience}, {"education", cv.Education}} {
+		if len(section.entries) == 0 {
+			continue
+		}
+		heading(section.label)
+		for i, e := range section.entries {
+			if i > 0 {
+				d.Space(6)
+			}
+			title, sub := cvEntryHeading(e, lang)
+			d.Text(title, 11, true, 0)
+			d.Text(strings.Trim(sub+"  ·  "+cvPeriod(e, lang), " ·"), 9.5, false, 0)
+			for _, item := range e.Highlights.In(lang) {
+				d.Text("• "+item, 10, false, 10)
+			}
+		}
+	}
+	if len(cv.Skills) > 0 {
+		heading("skills")
+		for _, g := range cv.Skills {
+			d.Text(g.Name.In(lang)+": "+strings.Join(g.Items, ", "), 10, false, 0)
+		}
+	}
+	return d.Bytes()
+}
+
+// CVPDFHandler serves the CV as a PDF generated from the same data as /cv
+func CVPDFHandler(path string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cv, err := loadCV(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			slog.Error("Error loading CV", "err", err)
+			http.Error(w, "Could not load CV", http.StatusInternalServerError)
+			return
+		}
+		regular, bold, err := cvPDFFonts()
+		if err != nil {
+			slog.Error("Error loading CV font", "err", err)
+			http.Error(w, "Could not generate PDF", http.StatusInternalServerError)
+			return
+		}
+
+		lang := cvPDFLang(requestLang(r))
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", `inline; filename="cv-`+lang+`.pdf"`)
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		w.Write(cvPDF(cv, lang, regular, bold))
+	}
+}
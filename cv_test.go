@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+const testCV = `
+name: Jane Doe
+headline:
+  en: Engineer
+  th: วิศวกร
+email: jane@example.com
+experience:
+  - title: Backend developer
+    org: Acme
+    location: Bangkok
+    start: 2022-03
+    highlights:
+      en: [Shipped the billing service]
+      th: [ทำระบบเรียกเก็บเงิน]
+  - title: Intern
+    org: Initech
+    start: 2020-06
+    end: 2020-09
+education:
+  - title: BSc Computer Science
+    org: Some University
+    end: "2021"
+skills:
+  - name: Languages
+    items: [Go, SQL]
+`
+
+func writeTestCV(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "cv.yaml")
+	if err := os.WriteFile(path, []byte(testCV), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCVHandler(t *testing.T) {
+	path := writeTestCV(t)
+	tests := []struct {
+		target string
+		want   []string
+	}{
+		{"/cv?lang=en", []string{
+			"<h1>Jane Doe</h1>", "Engineer", "Mar 2022 – Present", "Jun 2020 – Sep 2020",
+			"<li>Shipped the billing service</li>", "Acme, Bangkok", "<dt>Languages</dt><dd>Go, SQL</dd>",
+			`href="/cv.pdf?lang=en"`,
+		}},
+		// Without a Thai font the PDF link falls back to English
+		{"/cv?lang=th", []string{"วิศวกร", "มี.ค. 2022 – ปัจจุบัน", "ทำระบบเรียกเก็บเงิน", "ประสบการณ์ทำงาน", `href="/cv.pdf?lang=en"`}},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		CVHandler(path)(w, httptest.NewRequest("GET", tt.target, nil))
+		for _, want := range tt.want {
+			if !strings.Contains(w.Body.String(), want) {
+				t.Errorf("%s: missing %q", tt.target, want)
+			}
+		}
+	}
+
+	w := httptest.NewRecorder()
+	CVHandler(filepath.Join(t.TempDir(), "none.yaml"))(w, httptest.NewRequest("GET", "/cv", nil))
+	if w.Code != 404 {
+		t.Errorf("missing CV: status = %d, want 404", w.Code)
+	}
+}
+
+func TestCVPDFHandler(t *testing.T) {
+	path := writeTestCV(t)
+	old := cfg.CV
+	defer func() { cfg.CV = old }()
+
+	w := httptest.NewRecorder()
+	CVPDFHandler(path)(w, httptest.NewRequest("GET", "/cv.pdf?lang=th", nil))
+	if ct := w.Header().Get("Content-Type"); ct != "application/pdf" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	if !bytes.HasPrefix(w.Body.Bytes(), []byte("%PDF-")) {
+		t.Fatal("response is not a PDF")
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.Contains(cd, "cv-en.pdf") {
+		t.Errorf("Thai PDF without a font should fall back to English, got %q", cd)
+	}
+	if !bytes.Contains(w.Body.Bytes(), []byte("/BaseFont /Helvetica")) {
+		t.Error("expected the built-in Helvetica font")
+	}
+
+	fontPath := filepath.Join(t.TempDir(), "font.ttf")
+	os.WriteFile(fontPath, goregular.TTF, 0644)
+	cfg.CV = CVConfig{PDFFont: fontPath}
+	w = httptest.NewRecorder()
+	CVPDFHandler(path)(w, httptest.NewRequest("GET", "/cv.pdf?lang=th", nil))
+	if cd := w.Header().Get("Content-Disposition"); !strings.Contains(cd, "cv-th.pdf") {
+		t.Errorf("with a font the Thai PDF should be served, got %q", cd)
+	}
+	if !bytes.Contains(w.Body.Bytes(), []byte("/FontFile2")) {
+		t.Error("expected the configured font to be embedded")
+	}
+}
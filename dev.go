@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// liveReloadPath is the server-sent events endpoint pages listen on in dev mode
+const liveReloadPath = "/_dev/reload"
+
+// devReloadDebounce is how long dev mode waits for a burst of file changes,
+// such as an editor's save, to settle before reloading pages
+const devReloadDebounce = 150 * time.Millisecond
+
+// devMode is set by the -dev flag: templates are re-parsed on every request,
+// nothing is cached by the browser, and pages reload when files change
+var devMode bool
+
+// LiveReload tells the pages open in the browser to reload when files change
+type LiveReload struct {
+	mu      sync.Mutex
+	clients map[chan string]bool
+}
+
+// NewLiveReload returns a LiveReload with no pages listening
+func NewLiveReload() *LiveReload {
+	return &LiveReload{clients: make(map[chan string]bool)}
+}
+
+// Notify sends event to every listening page: "css" swaps its stylesheets,
+// "reload" reloads it
+func (lr *LiveReload) Notify(event string) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	for c := range lr.clients {
+		select {
+		case c <- event:
+		default:
+			// The page has an event waiting already; it will reload anyway
+		}
+	}
+}
+
+// ServeHTTP streams reload events to one page until it goes away
+func (lr *LiveReload) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	// The stream outlives the server's write timeout
+	rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	rc.Flush()
+
+	c := make(chan string, 1)
+	lr.mu.Lock()
+	lr.clients[c] = true
+	lr.mu.Unlock()
+	defer func() {
+		lr.mu.Lock()
+		delete(lr.clients, c)
+		lr.mu.Unlock()
+	}()
+
+	keepAlive := time.NewTicker(30 * time.Second)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-c:
+			fmt.Fprintf(w, "event: %s\ndata: \n\n", event)
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// DevHandler serves the live reload endpoint and tells the browser to check
+// back for everything else, so edited styles and scripts are never stale
+func DevHandler(lr *LiveReload, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == liveReloadPath {
+			lr.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-cache")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// WatchDev watches dirs and everything below them until ctx is done. When a
+// burst of changes settles it drops cached renders, rescans posts if a post
+// changed, then has the pages swap their stylesheets if only CSS changed, or
+// reload otherwise.
+func WatchDev(ctx context.Context, lr *LiveReload, posts *PostIndex, dirs ...string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	for _, dir := range dirs {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.IsDir() {
+				return err
+			}
+			return watcher.Add(path)
+		})
+		if err != nil {
+			watcher.Close()
+			return err
+		}
+	}
+
+	go func() {
+		defer watcher.Close()
+		var debounce <-chan time.Time
+		cssOnly, postsChanged := true, false
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if ev.Has(fsnotify.Create) {
+					// Watch new directories too
+					watcher.Add(ev.Name)
+				}
+				name := filepath.Base(ev.Name)
+				if strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") {
+					// Editor swap and backup files
+					continue
+				}
+				cssOnly = cssOnly && filepath.Ext(name) == ".css"
+				postsChanged = postsChanged || (posts != nil && strings.HasPrefix(ev.Name, posts.dir+string(filepath.Separator)))
+				debounce = time.After(devReloadDebounce)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				slog.Error("Error watching files for live reload", "err", err)
+			case <-debounce:
+				debounce = nil
+				// Shortcodes read other files, so any change can stale a render
+				renderCache.Lock()
+				clear(renderCache.entries)
+				renderCache.Unlock()
+				if postsChanged {
+					if err := posts.Scan(); err != nil {
+						slog.Error("Error scanning posts", "err", err)
+					}
+				}
+				event := "reload"
+				if cssOnly {
+					event = "css"
+				}
+				slog.Debug("Live reload", "event", event)
+				lr.Notify(event)
+				cssOnly, postsChanged = true, false
+			}
+		}
+	}()
+	return nil
+}
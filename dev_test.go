@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// nextEvent reads the next event name from a server-sent events stream
+func nextEvent(t *testing.T, r *bufio.Reader) string {
+	t.Helper()
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading events: %v", err)
+		}
+		if event, ok := strings.CutPrefix(line, "event: "); ok {
+			return strings.TrimSpace(event)
+		}
+	}
+}
+
+// listen opens the live reload stream and waits until lr has the page listening
+func listen(t *testing.T, lr *LiveReload) *bufio.Reader {
+	t.Helper()
+	srv := httptest.NewServer(DevHandler(lr, http.NotFoundHandler()))
+	t.Cleanup(srv.Close)
+	resp, err := http.Get(srv.URL + liveReloadPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	for deadline := time.Now().Add(time.Second); ; {
+		lr.mu.Lock()
+		n := len(lr.clients)
+		lr.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("page never started listening")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return bufio.NewReader(resp.Body)
+}
+
+func TestLiveReloadNotify(t *testing.T) {
+	lr := NewLiveReload()
+	events := listen(t, lr)
+	lr.Notify("css")
+	if got := nextEvent(t, events); got != "css" {
+		t.Errorf("event = %q, want css", got)
+	}
+	lr.Notify("reload")
+	if got := nextEvent(t, events); got != "reload" {
+		t.Errorf("event = %q, want reload", got)
+	}
+}
+
+func TestDevHandlerNoCache(t *testing.T) {
+	h := DevHandler(NewLiveReload(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("page"))
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/static/style.css", nil))
+	if w.Body.String() != "page" || w.Header().Get("Cache-Control") != "no-cache" {
+		t.Errorf("body %q, Cache-Control %q", w.Body.String(), w.Header().Get("Cache-Control"))
+	}
+}
+
+func TestWatchDev(t *testing.T) {
+	root := t.TempDir()
+	static, posts := filepath.Join(root, "static"), filepath.Join(root, "posts")
+	os.MkdirAll(filepath.Join(static, "css"), 0755)
+	os.MkdirAll(posts, 0755)
+	idx := newTestPostIndex(t, posts)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lr := NewLiveReload()
+	if err := WatchDev(ctx, lr, idx, static, posts); err != nil {
+		t.Fatal(err)
+	}
+	events := listen(t, lr)
+
+	os.WriteFile(filepath.Join(static, "css", "style.css"), []byte("body{}"), 0644)
+	if got := nextEvent(t, events); got != "css" {
+		t.Errorf("CSS change: event = %q, want css", got)
+	}
+
+	os.WriteFile(filepath.Join(posts, "hello.md"), []byte("---\ntitle: Hello\ndate: 2025-01-01\n---\nHi"), 0644)
+	if got := nextEvent(t, events); got != "reload" {
+		t.Errorf("post change: event = %q, want reload", got)
+	}
+	if _, ok := idx.Get("hello"); !ok {
+		t.Error("new post not indexed before the reload")
+	}
+}
+
+func TestRenderPage_LiveReloadScript(t *testing.T) {
+	defer func(old bool) { devMode = old }(devMode)
+	for _, dev := range []bool{true, false} {
+		devMode = dev
+		w := httptest.NewRecorder()
+		renderPage(w, "Hello", "<p>hi</p>")
+		if got := strings.Contains(w.Body.String(), liveReloadPath); got != dev {
+			t.Errorf("dev mode %v: live reload script = %v", dev, got)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// PostDraft is an autosaved, unpublished version of a post being edited
+type PostDraft struct {
+	// Slug is empty for a post that hasn't been saved yet
+	Slug      string
+	Content   string
+	UpdatedAt time.Time
+}
+
+// DraftStore keeps the editor's autosaved drafts, one per post
+type DraftStore struct {
+	db *sql.DB
+}
+
+const draftsSchema = `CREATE TABLE IF NOT EXISTS post_drafts (
+	slug TEXT PRIMARY KEY,
+	content TEXT NOT NULL,
+	updated_at INTEGER NOT NULL
+);`
+
+// NewDraftStore creates the post_drafts table if needed
+func NewDraftStore(db *sql.DB) (*DraftStore, error) {
+	if _, err := db.Exec(draftsSchema); err != nil {
+		return nil, fmt.Errorf("create post_drafts table: %w", err)
+	}
+	return &DraftStore{db: db}, nil
+}
+
+// Save stores the draft for a post, replacing any earlier one. Drafts of
+// private posts are sealed like the posts themselves, so the database doesn't
+// hold them in the clear.
+func (s *DraftStore) Save(slug, content string) error {
+	content, err := sealPost(content)
+	if err != nil {
+		return fmt.Errorf("seal draft of %q: %w", slug, err)
+	}
+	_, err = s.db.Exec(`INSERT INTO post_drafts (slug, content, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT (slug) DO UPDATE SET content = excluded.content, updated_at = excluded.updated_at`,
+		slug, content, time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("save draft of %q: %w", slug, err)
+	}
+	return nil
+}
+
+// Get returns the draft for a post, or sql.ErrNoRows
+func (s *DraftStore) Get(slug string) (PostDraft, error) {
+	d := PostDraft{Slug: slug}
+	var updatedAt int64
+	err := s.db.QueryRow(`SELECT content, updated_at FROM post_drafts WHERE slug = ?`, slug).Scan(&d.Content, &updatedAt)
+	if err != nil {
+		return d, err
+	}
+	d.UpdatedAt = time.Unix(updatedAt, 0)
+	d.Content, err = openPost(d.Content)
+	return d, err
+}
+
+// Delete drops the draft for a post once it has been saved
+func (s *DraftStore) Delete(slug string) error {
+	if _, err := s.db.Exec(`DELETE FROM post_drafts WHERE slug = ?`, slug); err != nil {
+		return fmt.Errorf("delete draft of %q: %w", slug, err)
+	}
+	return nil
+}
+
+// AdminDraftSaveHandler stores an autosaved draft sent by the editor
+func AdminDraftSaveHandler(drafts *DraftStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slug := r.PathValue("slug")
+		if slug != "" && !IsValidSlug(slug) {
+			http.Error(w, "Invalid post slug", http.StatusBadRequest)
+			return
+		}
+		content := strings.ReplaceAll(r.PostFormValue("content"), "\r\n", "\n")
+		if err := drafts.Save(slug, content); err != nil {
+			slog.Error("Error autosaving draft", "err", err)
+			http.Error(w, "Could not save draft", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+)
+
+// RenderedEmail is an email rendered from templates in both HTML and plain text
+type RenderedEmail struct {
+	Subject string
+	HTML    string
+	Text    string
+}
+
+// Message addresses the rendered email for delivery through the job queue
+func (e RenderedEmail) Message(to ...string) EmailMessage {
+	return EmailMessage{To: to, Subject: e.Subject, Body: e.Text, HTML: e.HTML}
+}
+
+// EmailRenderer renders emails from templates/email.
+//
+// Each email is a pair of files per language: name.lang.html defines a "content"
+// block rendered inside the shared layout.html (which carries the inlined CSS), and
+// name.lang.txt is the plain-text alternative that also defines the "subject".
+type EmailRenderer struct {
+	Dir string
+}
+
+// emailFuncs are available in every email template: {{siteName}}
+var emailFuncs = map[string]any{
+	"siteName": func() string { return cfg.Site.Name },
+}
+
+// NewEmailRenderer returns a renderer for the given template directory
+func NewEmailRenderer(dir string) *EmailRenderer {
+	return &EmailRenderer{Dir: dir}
+}
+
+// Render renders the named email in lang, falling back to English when no
+// translation exists
+func (e *EmailRenderer) Render(name, lang string, data any) (RenderedEmail, error) {
+	var out RenderedEmail
+
+	htmlPath, txtPath := e.files(name, lang)
+
+	ht, err := htmltemplate.New("email").Funcs(htmltemplate.FuncMap(emailFuncs)).ParseFiles(filepath.Join(e.Dir, "layout.html"), htmlPath)
+	if err != nil {
+		return out, fmt.Errorf("parse email %s: %w", name, err)
+	}
+	tt, err := texttemplate.New("email").Funcs(texttemplate.FuncMap(emailFuncs)).ParseFiles(txtPath)
+	if err != nil {
+		return out, fmt.Errorf("parse email %s: %w", name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tt.ExecuteTemplate(&buf, "subject", data); err != nil {
+		return out, fmt.Errorf("render subject of email %s: %w", name, err)
+	}
+	out.Subject = strings.TrimSpace(buf.String())
+
+	buf.Reset()
+	if err := tt.ExecuteTemplate(&buf, filepath.Base(txtPath), data); err != nil {
+		return out, fmt.Errorf("render text of email %s: %w", name, err)
+	}
+	out.Text = strings.TrimSpace(buf.String()) + "\n"
+
+	buf.Reset()
+	if err := ht.ExecuteTemplate(&buf, "layout", data); err != nil {
+		return out, fmt.Errorf("render html of email %s: %w", name, err)
+	}
+	out.HTML = buf.String()
+
+	return out, nil
+}
+
+// files resolves the template pair for an email, preferring the requested language
+func (e *EmailRenderer) files(name, lang string) (string, string) {
+	for _, l := range []string{lang, "en"} {
+		htmlPath := filepath.Join(e.Dir, name+"."+l+".html")
+		if _, err := os.Stat(htmlPath); err == nil {
+			return htmlPath, filepath.Join(e.Dir, name+"."+l+".txt")
+		}
+	}
+	return filepath.Join(e.Dir, name+".en.html"), filepath.Join(e.Dir, name+".en.txt")
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEmailRenderer_Render(t *testing.T) {
+	renderer := NewEmailRenderer("templates/email")
+	data := ContactMessage{Name: "Reader <script>", Email: "reader@example.com", Message: "Hello"}
+
+	tests := []struct {
+		lang        string
+		wantSubject string
+	}{
+		{"en", "New message from Reader <script>"},
+		{"th", "ข้อความใหม่จาก Reader <script>"},
+		{"fr", "New message from Reader <script>"}, // falls back to English
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.lang, func(t *testing.T) {
+			email, err := renderer.Render("contact_notification", tt.lang, data)
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			if email.Subject != tt.wantSubject {
+				t.Errorf("expected subject %q, got %q", tt.wantSubject, email.Subject)
+			}
+			if !strings.Contains(email.Text, "reader@example.com") {
+				t.Errorf("plain-text part missing sender: %q", email.Text)
+			}
+			if !strings.Contains(email.HTML, "Reader &lt;script&gt;") {
+				t.Error("expected HTML part to escape the sender name")
+			}
+			if !strings.Contains(email.HTML, `style="`) {
+				t.Error("expected inlined CSS in HTML part")
+			}
+		})
+	}
+}
+
+func TestMailer_BuildMessageMultipart(t *testing.T) {
+	m := &Mailer{From: "blog@example.com"}
+	raw := string(m.buildMessage(EmailMessage{
+		To:      []string{"owner@example.com"},
+		Subject: "สวัสดี",
+		Body:    "plain",
+		HTML:    "<p>html</p>",
+	}))
+
+	if !strings.Contains(raw, "Content-Type: multipart/alternative; boundary=") {
+		t.Error("expected multipart/alternative message")
+	}
+	if !strings.Contains(raw, "text/plain; charset=UTF-8") || !strings.Contains(raw, "text/html; charset=UTF-8") {
+		t.Error("expected both plain-text and HTML parts")
+	}
+	if !strings.Contains(raw, "Subject: =?utf-8?q?") {
+		t.Error("expected encoded UTF-8 subject")
+	}
+}
@@ -0,0 +1,15 @@
+//go:build embedposts
+
+package main
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed posts
+var embeddedPostFiles embed.FS
+
+func init() {
+	embeddedPosts, _ = fs.Sub(embeddedPostFiles, "posts")
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// errorTitles are the page titles of the error pages
+var errorTitles = map[int]Localized{
+	http.StatusNotFound:            {"en": "Page not found", "th": "ไม่พบหน้านี้"},
+	http.StatusInternalServerError: {"en": "Something went wrong", "th": "เกิดข้อผิดพลาด"},
+}
+
+// errorPageStatus returns the status whose page is shown for status: the 404
+// page for client errors and the 500 page for the rest
+func errorPageStatus(status int) int {
+	if status < http.StatusInternalServerError {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// renderError shows the error page for status in lang through the site
+// template, with a link back home. Error pages are never cached or indexed.
+func renderError(w http.ResponseWriter, status int, lang string) {
+	h := w.Header()
+	// Drop headers meant for the page that failed
+	for _, k := range []string{"ETag", "Last-Modified", "Content-Length"} {
+		h.Del(k)
+	}
+	h.Set("Cache-Control", "no-store")
+	h.Set("X-Robots-Tag", "noindex")
+
+	page := errorPageStatus(status)
+	content, err := errorContent(page, lang)
+	if err != nil {
+		slog.Error("Error rendering error page", "status", status, "err", err)
+		renderErrorPage(w)
+		return
+	}
+	renderPageData(w, PageData{Title: errorTitles[page].In(lang), Content: content, Status: status})
+}
+
+// errorContent renders the error-404 or error-500 template for lang. Template
+// sets without one, as in tests, get the title as a heading and a home link.
+func errorContent(page int, lang string) (template.HTML, error) {
+	t, err := pageTemplate(lang)
+	if err != nil {
+		return "", err
+	}
+	et := t.Lookup("error-" + strconv.Itoa(page))
+	if et == nil {
+		return template.HTML("<div class=\"error-page\">\n<h1>" + template.HTMLEscapeString(errorTitles[page].In(lang)) + "</h1>\n<p><a href=\"/\">Home</a></p>\n</div>"), nil
+	}
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := et.Execute(buf, struct{ Lang string }{lang}); err != nil {
+		return "", err
+	}
+	return template.HTML(buf.String()), nil
+}
+
+// NotFoundHandler serves paths no other route matches: old addresses are
+// redirected with the post aliases and the redirects in the config, and the
+// rest get the 404 page
+func NotFoundHandler(posts *PostIndex) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setSecurityHeaders(w)
+		if redirectMoved(w, r, posts) {
+			return
+		}
+		renderError(w, http.StatusNotFound, requestLang(r))
+	}
+}
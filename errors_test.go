@@ -0,0 +1,120 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderError(t *testing.T) {
+	tests := []struct {
+		status int
+		lang   string
+		want   []string
+	}{
+		{http.StatusNotFound, "en", []string{"<title>Page not found", "Back to the homepage"}},
+		{http.StatusNotFound, "th", []string{"<title>ไม่พบหน้านี้", "กลับหน้าแรก"}},
+		{http.StatusInternalServerError, "en", []string{"<title>Something went wrong", "Please try again"}},
+		{http.StatusInternalServerError, "th", []string{"<title>เกิดข้อผิดพลาด"}},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		w.Header().Set("ETag", `"abc"`)
+		renderError(w, tt.status, tt.lang)
+
+		if w.Code != tt.status {
+			t.Errorf("%d %s: status = %d", tt.status, tt.lang, w.Code)
+		}
+		if got := w.Header().Get("Cache-Control"); got != "no-store" {
+			t.Errorf("%d %s: Cache-Control = %q, want no-store", tt.status, tt.lang, got)
+		}
+		if w.Header().Get("ETag") != "" {
+			t.Errorf("%d %s: ETag kept on the error page", tt.status, tt.lang)
+		}
+		body := w.Body.String()
+		want := append(tt.want, `class="error-page"`, `href="/"`, `content="noindex"`)
+		for _, s := range want {
+			if !strings.Contains(body, s) {
+				t.Errorf("%d %s: page lacks %q", tt.status, tt.lang, s)
+			}
+		}
+	}
+}
+
+func TestRenderErrorWithoutTemplate(t *testing.T) {
+	defer func(orig *template.Template) { tmpl = orig }(tmpl)
+	tmpl = template.Must(template.New("base").Parse(`<title>{{.Title}}</title>{{.Content}}`))
+
+	w := httptest.NewRecorder()
+	renderError(w, http.StatusNotFound, "en")
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", w.Code)
+	}
+	if body := w.Body.String(); !strings.Contains(body, "<h1>Page not found</h1>") || !strings.Contains(body, `href="/"`) {
+		t.Errorf("fallback page = %s", body)
+	}
+}
+
+func TestUnknownPathIsNotFound(t *testing.T) {
+	t.Chdir(t.TempDir())
+	os.Mkdir("posts", 0755)
+	os.WriteFile(filepath.Join("posts", "hello.md"), []byte("---\ntitle: Hello\n---\n\nHi"), 0644)
+	posts := newTestPostIndex(t, "posts")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", HomeHandler(posts, nil))
+	mux.HandleFunc("GET /", NotFoundHandler(posts))
+
+	tests := []struct {
+		path string
+		code int
+		want string
+	}{
+		{"/?lang=en", http.StatusOK, "Hello"},
+		{"/no-such-page?lang=en", http.StatusNotFound, "Page not found"},
+		{"/posts/hello/extra?lang=th", http.StatusNotFound, "ไม่พบหน้านี้"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+		if w.Code != tt.code {
+			t.Errorf("%s: status = %d, want %d", tt.path, w.Code, tt.code)
+		}
+		if !strings.Contains(w.Body.String(), tt.want) {
+			t.Errorf("%s: page lacks %q", tt.path, tt.want)
+		}
+	}
+}
+
+func TestPostHandlerNotFoundPage(t *testing.T) {
+	req := httptest.NewRequest("GET", "/posts/missing?lang=en", nil)
+	req.SetPathValue("slug", "missing")
+	w := httptest.NewRecorder()
+	PostHandler(&MockSlugReader{content: map[string]string{}}, nil, nil)(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "Back to the homepage") {
+		t.Errorf("missing post didn't get the 404 page: %s", w.Body.String())
+	}
+}
+
+func TestPostHandlerInvalidSlugPage(t *testing.T) {
+	req := httptest.NewRequest("GET", "/posts/x?lang=en", nil)
+	req.SetPathValue("slug", "..")
+	w := httptest.NewRecorder()
+	PostHandler(&MockSlugReader{}, nil, nil)(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "Back to the homepage") {
+		t.Errorf("invalid slug didn't get the styled page: %s", w.Body.String())
+	}
+}
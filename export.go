@@ -0,0 +1,284 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// exportSeeds are fetched first; everything else is found by following links
+var exportSeeds = append([]string{"/", "/th/", "/en/", "/feed.xml", "/rss.xml", "/sitemap.xml", "/robots.txt", "/llms.txt"}, sectionFeeds()...)
+
+// exportCopyDirs are copied into an export as they are
+var exportCopyDirs = []string{"static", attachmentsDir}
+
+// exportHandler serves the read-only pages of the site, mounted as main mounts
+// them. Pages that need the server (search, comments, saved posts, the admin
+// area) are left out.
+func exportHandler(posts *PostIndex) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+highlightCSSPath, HighlightCSSHandler(cfg.Markdown.Highlight))
+	mux.HandleFunc("GET /images/{path...}", ImageHandler("images", cfg.ImageCacheDir, cfg.StripImageMetadata))
+	mux.HandleFunc("GET /audio/{file}", AudioHandler(posts, "audio"))
+	mux.HandleFunc("GET /{$}", HomeHandler(posts, nil))
+	pages := &FileReader{Dir: pagesDir}
+	mux.HandleFunc("GET /contact", ContactHandler(pages))
+	mux.HandleFunc("GET /pages/{name}", PageHandler(pages))
+	mux.HandleFunc("GET /posts/{slug}", postFormats(PostHandler(posts, nil, nil), map[string]http.HandlerFunc{
+		".json": PostJSONHandler(posts),
+		".md":   PostSourceHandler(posts),
+	}))
+	mux.HandleFunc("GET /posts/{slug}/code.zip", CodeZipHandler(posts))
+	mux.HandleFunc("GET /projects", ProjectsHandler(projectsFile))
+	mux.HandleFunc("GET /talks", TalksHandler(talksFile))
+	mux.HandleFunc("GET /talks.ics", TalksCalendarHandler(talksFile))
+	mux.HandleFunc("GET /cv", CVHandler(cvFile))
+	mux.HandleFunc("GET /cv.pdf", CVPDFHandler(cvFile))
+	for _, s := range sections {
+		s.handle(mux)
+	}
+	sitemaps := &Sitemaps{
+		Posts:     posts,
+		Sections:  sectionFiles(),
+		DataFiles: map[string]string{"/projects": projectsFile, "/talks": talksFile, "/cv": cvFile},
+	}
+	mux.HandleFunc("GET /robots.txt", RobotsTxtHandler)
+	mux.HandleFunc("GET /sitemap.xml", sitemaps.IndexHandler)
+	mux.HandleFunc("GET /sitemaps/{file}", sitemaps.SectionHandler)
+	mux.HandleFunc("GET /tags/{name}", TagHandler(posts))
+	mux.HandleFunc("GET /categories/{name}", CategoryHandler(posts))
+	mux.HandleFunc("GET /feed.xml", AtomFeedHandler(posts))
+	mux.HandleFunc("GET /rss.xml", RSSFeedHandler(posts))
+	if cfg.LLMsTxt.Enabled {
+		mux.HandleFunc("GET /llms.txt", LLMsTxtHandler(posts))
+	}
+	return ForwardedPrefix(LanguagePaths(posts, mux))
+}
+
+// exportLinkRegexes find the pages, files, and sitemap entries a response links to
+var (
+	exportHrefRegex   = regexp.MustCompile(`\s(?:href|src|poster)="(/[^/"][^"]*|/)"`)
+	exportSrcsetRegex = regexp.MustCompile(`\ssrcset="([^"]*)"`)
+	exportLocRegex    = regexp.MustCompile(`<loc>([^<]+)</loc>`)
+)
+
+// siteExporter crawls the site through its handlers and writes each page to disk
+type siteExporter struct {
+	handler http.Handler
+	out     string
+	// base is the public address of the site, e.g. https://user.github.io/blog,
+	// and prefix its path
+	base   *url.URL
+	prefix string
+
+	seen    map[string]bool
+	queue   []string
+	written int
+}
+
+// exportFilePath maps a URL path to the file that serves it on static hosting:
+// pages become index.html in a directory of their own, files keep their name
+func exportFilePath(urlPath string) string {
+	clean := path.Clean("/" + urlPath)
+	if strings.HasSuffix(urlPath, "/") || path.Ext(clean) == "" {
+		return path.Join(clean, "index.html")[1:]
+	}
+	return clean[1:]
+}
+
+// exportRedirectHTML is a page that forwards to target, standing in for an
+// HTTP redirect. With chooseLang it picks the reader's language tree itself,
+// the way the server redirects /.
+func exportRedirectHTML(target, prefix string, chooseLang bool) []byte {
+	esc := template.HTMLEscapeString(target)
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"><title>Redirecting…</title>\n")
+	b.WriteString("<link rel=\"canonical\" href=\"" + esc + "\">\n")
+	if chooseLang {
+		// The lang cookie is set by the language toggle
+		b.WriteString("<script>var l = (document.cookie.match(/(?:^|; )lang=(th|en)(?:;|$)/) || [])[1] || " +
+			"((navigator.language || '').slice(0, 2) === 'en' ? 'en' : 'th');\n" +
+			"location.replace('" + template.JSEscapeString(prefix) + "/' + l + '/' + location.search);</script>\n")
+	}
+	b.WriteString("<meta http-equiv=\"refresh\" content=\"0; url=" + esc + "\">\n")
+	b.WriteString("</head><body><a href=\"" + esc + "\">" + esc + "</a></body></html>\n")
+	return []byte(b.String())
+}
+
+// add queues a site path to be exported, once
+func (e *siteExporter) add(p string) {
+	// Static hosting ignores the query, and fragments are part of the same page
+	p, _, _ = strings.Cut(p, "#")
+	p, _, _ = strings.Cut(p, "?")
+	if e.prefix != "" {
+		rest, ok := strings.CutPrefix(p, e.prefix)
+		if !ok {
+			return
+		}
+		p = rest
+	}
+	if p == "" {
+		p = "/"
+	}
+	if !strings.HasPrefix(p, "/") || strings.HasPrefix(p, "//") || e.seen[p] {
+		return
+	}
+	e.seen[p] = true
+	e.queue = append(e.queue, p)
+}
+
+// addLinks queues the pages and files an HTML page or a sitemap links to
+func (e *siteExporter) addLinks(body []byte, contentType string) {
+	switch {
+	case strings.HasPrefix(contentType, "text/html"):
+		for _, m := range exportHrefRegex.FindAllSubmatch(body, -1) {
+			e.add(string(m[1]))
+		}
+		for _, m := range exportSrcsetRegex.FindAllSubmatch(body, -1) {
+			for _, c := range strings.Split(string(m[1]), ",") {
+				if fields := strings.Fields(c); len(fields) > 0 {
+					e.add(fields[0])
+				}
+			}
+		}
+	case strings.Contains(contentType, "xml"):
+		root := e.base.Scheme + "://" + e.base.Host
+		for _, m := range exportLocRegex.FindAllSubmatch(body, -1) {
+			if rest, ok := strings.CutPrefix(string(m[1]), root); ok {
+				e.add(rest)
+			}
+		}
+	}
+}
+
+// fetch runs a request for p through the site's handlers
+func (e *siteExporter) fetch(p string) *bufferedResponse {
+	r, _ := http.NewRequest(http.MethodGet, e.base.Scheme+"://"+e.base.Host+p, nil)
+	r.RequestURI = p
+	if e.prefix != "" {
+		r.Header.Set("X-Forwarded-Prefix", e.prefix)
+	}
+	r.Header.Set("X-Forwarded-Proto", e.base.Scheme)
+	w := &bufferedResponse{header: make(http.Header)}
+	e.handler.ServeHTTP(w, r)
+	return w
+}
+
+// exportPage fetches one path, writes it out, and queues what it links to
+func (e *siteExporter) exportPage(p string) error {
+	w := e.fetch(p)
+	body := w.body.Bytes()
+	switch {
+	case w.status >= 300 && w.status < 400:
+		target := w.header.Get("Location")
+		chooseLang := strings.Contains(w.header.Get("Vary"), "Accept-Language") && p == "/"
+		body = exportRedirectHTML(target, e.prefix, chooseLang)
+		e.add(target)
+	case w.status != http.StatusOK:
+		slog.Debug("Export: skipping page", "path", p, "status", w.status)
+		return nil
+	}
+
+	e.addLinks(body, w.header.Get("Content-Type"))
+
+	dst := filepath.Join(e.out, filepath.FromSlash(exportFilePath(p)))
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	e.written++
+	return os.WriteFile(dst, body, 0644)
+}
+
+// copyDir copies the files under src into dst, if src exists
+func copyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if os.IsNotExist(err) && p == src {
+			return fs.SkipAll
+		}
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, 0644)
+	})
+}
+
+// exportSite writes the whole site as static files to out, ready for static
+// hosting such as GitHub Pages. Pages are rendered by the same handlers and
+// templates as the server, at the address in site_url; static/ and
+// attachments/ are copied, images go through the image handler so their
+// metadata is stripped as configured, and only the audio of published posts
+// is included.
+func exportSite(posts *PostIndex, out string) error {
+	siteAddr := cfg.SiteURL
+	if siteAddr == "" {
+		siteAddr = "http://localhost:" + cfg.Port
+	}
+	base, err := url.Parse(strings.TrimSuffix(siteAddr, "/"))
+	if err != nil {
+		return fmt.Errorf("site_url: %w", err)
+	}
+
+	// Static hosting has no query strings and no proxy in front
+	singlePage = true
+	defer func() { singlePage = false }()
+	defer func(old bool) { cfg.Proxy.TrustForwardedPrefix = old }(cfg.Proxy.TrustForwardedPrefix)
+	cfg.Proxy.TrustForwardedPrefix = true
+
+	e := &siteExporter{handler: exportHandler(posts), out: out, base: base, prefix: base.Path, seen: make(map[string]bool)}
+	if e.prefix != "" && !validPrefixRegex.MatchString(e.prefix) {
+		return fmt.Errorf("site_url path %q can't be used as a prefix", e.prefix)
+	}
+	for _, s := range exportSeeds {
+		e.add(e.prefix + s)
+	}
+	for name := range publishedAudio(posts) {
+		e.add(e.prefix + "/audio/" + url.PathEscape(name))
+	}
+	filepath.WalkDir("images", func(p string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			if rel, err := filepath.Rel("images", p); err == nil {
+				e.add(e.prefix + "/images/" + filepath.ToSlash(rel))
+			}
+		}
+		return nil
+	})
+
+	for len(e.queue) > 0 {
+		p := e.queue[0]
+		e.queue = e.queue[1:]
+		if err := e.exportPage(p); err != nil {
+			return fmt.Errorf("export %s: %w", p, err)
+		}
+	}
+	for _, dir := range exportCopyDirs {
+		if err := copyDir(dir, filepath.Join(out, dir)); err != nil {
+			return fmt.Errorf("copy %s: %w", dir, err)
+		}
+	}
+	// Serve directories starting with an underscore on GitHub Pages
+	if err := os.WriteFile(filepath.Join(out, ".nojekyll"), nil, 0644); err != nil {
+		return err
+	}
+	slog.Info("Exported the site", "pages", e.written, "dir", out)
+	return nil
+}
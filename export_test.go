@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportFilePath(t *testing.T) {
+	tests := []struct {
+		path, want string
+	}{
+		{"/", "index.html"},
+		{"/th/", "th/index.html"},
+		{"/th/posts/hello", "th/posts/hello/index.html"},
+		{"/feed.xml", "feed.xml"},
+		{"/images/a/b.png", "images/a/b.png"},
+		{"/../etc/passwd", "etc/passwd/index.html"},
+	}
+	for _, tt := range tests {
+		if got := exportFilePath(tt.path); got != tt.want {
+			t.Errorf("exportFilePath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestExportSite(t *testing.T) {
+	defer func(old string) { cfg.SiteURL = old }(cfg.SiteURL)
+	cfg.SiteURL = "https://example.github.io/blog"
+	defer func(old int) { cfg.PostsPerPage = old }(cfg.PostsPerPage)
+	cfg.PostsPerPage = 1
+
+	t.Chdir(t.TempDir())
+	os.MkdirAll("posts", 0755)
+	os.MkdirAll("static", 0755)
+	os.WriteFile("static/style.css", []byte("body{}"), 0644)
+	os.WriteFile("posts/en-hello.md", []byte("---\ntitle: Hello\ndate: 2025-03-01\ntags: [go]\n---\nHi"), 0644)
+	os.WriteFile("posts/en-second.md", []byte("---\ntitle: Second\ndate: 2025-03-02\n---\nAgain"), 0644)
+
+	out := filepath.Join(t.TempDir(), "public")
+	if err := exportSite(newTestPostIndex(t, "posts"), out); err != nil {
+		t.Fatal(err)
+	}
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(out, name))
+		if err != nil {
+			t.Errorf("missing %s", name)
+		}
+		return string(data)
+	}
+
+	home := read("en/index.html")
+	// Both posts are listed even though posts_per_page is 1
+	for _, want := range []string{`href="/blog/en/posts/hello"`, `href="/blog/en/posts/second"`} {
+		if !strings.Contains(home, want) {
+			t.Errorf("en/index.html missing %s", want)
+		}
+	}
+	if !strings.Contains(read("en/posts/hello/index.html"), "Hi") {
+		t.Error("post page not exported")
+	}
+	read("en/tags/go/index.html")
+	read("feed.xml")
+	read("sitemap.xml")
+	read(".nojekyll")
+	if read("static/style.css") != "body{}" {
+		t.Error("static files not copied")
+	}
+	if root := read("index.html"); !strings.Contains(root, `url=/blog/th/`) || !strings.Contains(root, `location.replace('/blog/'`) {
+		t.Errorf("index.html = %s", root)
+	}
+	if singlePage {
+		t.Error("singlePage left on after export")
+	}
+}
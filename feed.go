@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/xml"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// feedSize is how many of the newest posts the feeds list
+const feedSize = 20
+
+// feedExcerptLength is the longest excerpt, in characters, before it is cut at a word
+const feedExcerptLength = 300
+
+// atomFeed is the root of an Atom 1.0 document
+type atomFeed struct {
+	XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+	// Lang is the site's default language; entries in another one say so
+	Lang    string      `xml:"http://www.w3.org/XML/1998/namespace lang,attr,omitempty"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Author  atomPerson  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Rel    string `xml:"rel,attr,omitempty"`
+	Type   string `xml:"type,attr,omitempty"`
+	Href   string `xml:"href,attr"`
+	Length int64  `xml:"length,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Lang      string     `xml:"http://www.w3.org/XML/1998/namespace lang,attr,omitempty"`
+	Title     string     `xml:"title"`
+	ID        string     `xml:"id"`
+	Links     []atomLink `xml:"link"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+	Summary   string     `xml:"summary"`
+}
+
+// feedItem is a post as both feed formats list it. Its Lang is always set,
+// detected from the text for posts in both trees.
+type feedItem struct {
+	Post
+	// URL is where the entry links to: the post, or the external page of a link post
+	URL string
+	// Permalink is the post's own address, used as its stable ID
+	Permalink string
+	Excerpt   string
+	// Audio is the post's narration or episode, with an absolute URL; it's
+	// listed as the first enclosure so podcast apps pick it up
+	Audio *attachmentFile
+	// Attachments are the post's downloads, listed as enclosures
+	Attachments []attachmentFile
+}
+
+// feedItems returns the newest posts from the index with excerpts rendered from
+// their markdown
+func feedItems(posts *PostIndex, base string) []feedItem {
+	all := posts.Posts()
+	items := make([]feedItem, 0, min(len(all), feedSize))
+	for _, p := range all[:min(len(all), feedSize)] {
+		item := feedItem{Post: p, Permalink: base + "/posts/" + p.Slug}
+		item.URL = item.Permalink
+		if p.Link != "" {
+			item.URL = p.Link
+		}
+		if entry, ok := posts.Get(p.Slug); ok {
+			_, body := ParseFrontmatter(entry.Content)
+			var err error
+			if item.Excerpt, err = postExcerpt(body, p.Slug, p.Lang); err != nil {
+				slog.Error("Error rendering feed excerpt", "slug", p.Slug, "err", err)
+			}
+			item.Lang = contentLang(p.Lang, item.Title+" "+item.Excerpt)
+			item.Attachments = postAttachments(entry.FM.Attachments, attachmentsDir)
+			if audio, ok := postAudioFile(entry.FM.Audio, p.Slug, "audio"); ok {
+				if strings.HasPrefix(audio.URL, "/") {
+					audio.URL = base + audio.URL
+				}
+				item.Audio = &audio
+			}
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// postExcerpt renders a post's markdown and returns the start of its text, cut at
+// a word boundary. lang is the post's language; "" detects it from the text.
+func postExcerpt(body, slug, lang string) (string, error) {
+	html, err := renderMarkdownCached(body, slug)
+	if err != nil {
+		return "", err
+	}
+	return htmlExcerpt(html, lang), nil
+}
+
+// htmlExcerpt returns the start of rendered HTML's text, cut at a word boundary
+func htmlExcerpt(html, lang string) string {
+	text := strings.Join(strings.Fields(plainText(html)), " ")
+	return truncateText(text, feedExcerptLength, lang)
+}
+
+// feedHeadLinks advertises the post feeds to feed readers
+func feedHeadLinks() template.HTML {
+	name := template.HTMLEscapeString(cfg.Site.Name)
+	return template.HTML(`<link rel="alternate" type="application/atom+xml" title="` + name + `" href="/feed.xml">` + "\n" +
+		`<link rel="alternate" type="application/rss+xml" title="` + name + `" href="/rss.xml">` + "\n")
+}
+
+// writeFeed sends an encoded feed document
+func writeFeed(w http.ResponseWriter, contentType string, v any) {
+	out, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		slog.Error("Error encoding feed", "err", err)
+		http.Error(w, "Could not render feed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write([]byte(xml.Header))
+	w.Write(out)
+}
+
+// AtomFeedHandler serves an Atom feed of the newest posts at /feed.xml
+func AtomFeedHandler(posts *PostIndex) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setSecurityHeaders(w)
+		markNoIndex(w, PageFeed)
+
+		base := siteURL(r)
+		items := feedItems(posts, base)
+
+		feed := atomFeed{
+			Lang:   defaultLang(),
+			Title:  cfg.Site.Name,
+			ID:     base + "/",
+			Author: atomPerson{Name: cfg.Site.Name},
+			Links: []atomLink{
+				{Rel: "self", Type: "application/atom+xml", Href: base + "/feed.xml"},
+				{Rel: "alternate", Type: "text/html", Href: base + "/"},
+			},
+		}
+		var updated time.Time
+		for _, it := range items {
+			entry := atomEntry{
+				Title:   it.Title,
+				ID:      it.Permalink,
+				Links:   []atomLink{{Rel: "alternate", Type: "text/html", Href: it.URL}},
+				Summary: it.Excerpt,
+			}
+			if it.Lang != feed.Lang {
+				entry.Lang = it.Lang
+			}
+			if it.URL != it.Permalink {
+				entry.Links = append(entry.Links, atomLink{Rel: "related", Type: "text/html", Href: it.Permalink})
+			}
+			if a := it.Audio; a != nil {
+				entry.Links = append(entry.Links, atomLink{Rel: "enclosure", Type: a.Type, Href: a.URL, Length: a.Size})
+			}
+			for _, a := range it.Attachments {
+				entry.Links = append(entry.Links, atomLink{Rel: "enclosure", Type: a.Type, Href: base + a.URL, Length: a.Size})
+			}
+			// Posts without a date carry their file's modification time
+			entry.Published = it.Date.UTC().Format(time.RFC3339)
+			entry.Updated = entry.Published
+			if it.Date.After(updated) {
+				updated = it.Date
+			}
+			feed.Entries = append(feed.Entries, entry)
+		}
+		feed.Updated = updated.UTC().Format(time.RFC3339)
+
+		writeFeed(w, "application/atom+xml; charset=utf-8", feed)
+	}
+}
+
+// RSSFeedHandler serves an RSS 2.0 feed of the newest posts at /rss.xml
+func RSSFeedHandler(posts *PostIndex) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setSecurityHeaders(w)
+		markNoIndex(w, PageFeed)
+
+		base := siteURL(r)
+		items := feedItems(posts, base)
+
+		channel := rssChannel{
+			Title:       cfg.Site.Name,
+			Link:        base + "/",
+			Description: "Posts from " + cfg.Site.Name,
+			Language:    defaultLang(),
+		}
+		for _, it := range items {
+			item := rssItem{
+				Title:       it.Title,
+				Link:        it.URL,
+				GUID:        rssGUID{Value: it.Permalink, IsPermaLink: true},
+				Description: it.Excerpt,
+				Language:    it.Lang,
+			}
+			item.PubDate = it.Date.UTC().Format(time.RFC1123Z)
+			if a := it.Audio; a != nil {
+				item.Enclosures = append(item.Enclosures, rssEnclosure{URL: a.URL, Length: a.Size, Type: a.Type})
+			}
+			for _, a := range it.Attachments {
+				item.Enclosures = append(item.Enclosures, rssEnclosure{URL: base + a.URL, Length: a.Size, Type: a.Type})
+			}
+			channel.Items = append(channel.Items, item)
+		}
+
+		writeFeed(w, "application/rss+xml; charset=utf-8", rssFeed{Version: "2.0", DC: "http://purl.org/dc/elements/1.1/", Channel: channel})
+	}
+}
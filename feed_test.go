@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFeedPosts(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	posts := map[string]string{
+		"en-first":  "---\ntitle: First\ndate: 2024-01-02\n---\n\n# Heading\n\nSome **bold** text & more.",
+		"en-second": "---\ntitle: Second\ndate: 2024-03-04\nlink: https://go.dev/blog/\n---\n\n" + strings.Repeat("word ", 100),
+		"later":     "---\ntitle: Later\ndate: 2999-01-01\n---\n\nScheduled.",
+	}
+	for slug, md := range posts {
+		os.WriteFile(filepath.Join(dir, slug+".md"), []byte(md), 0644)
+	}
+	return dir
+}
+
+func TestAtomFeedHandler(t *testing.T) {
+	dir := writeFeedPosts(t)
+	w := httptest.NewRecorder()
+	AtomFeedHandler(newTestPostIndex(t, dir))(w, httptest.NewRequest("GET", "http://blog.test/feed.xml", nil))
+
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/atom+xml") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	var feed atomFeed
+	if err := xml.Unmarshal(w.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("invalid Atom: %v\n%s", err, w.Body.String())
+	}
+	if len(feed.Entries) != 2 {
+		t.Fatalf("got %d entries, want 2 (scheduled post left out)", len(feed.Entries))
+	}
+	second, first := feed.Entries[0], feed.Entries[1]
+	if second.Title != "Second" || second.ID != "http://blog.test/posts/en-second" || second.Links[0].Href != "https://go.dev/blog/" {
+		t.Errorf("link post entry = %+v", second)
+	}
+	if !strings.HasSuffix(second.Summary, "…") || len([]rune(second.Summary)) > feedExcerptLength+1 {
+		t.Errorf("long excerpt not cut: %q", second.Summary)
+	}
+	if first.Summary != "Heading Some bold text & more." || first.Links[0].Href != "http://blog.test/posts/en-first" {
+		t.Errorf("entry = %+v", first)
+	}
+	if feed.Updated != second.Published {
+		t.Errorf("feed updated = %q, want newest entry's %q", feed.Updated, second.Published)
+	}
+}
+
+func TestRSSFeedHandler(t *testing.T) {
+	dir := writeFeedPosts(t)
+	w := httptest.NewRecorder()
+	RSSFeedHandler(newTestPostIndex(t, dir))(w, httptest.NewRequest("GET", "http://blog.test/rss.xml", nil))
+
+	var feed rssFeed
+	if err := xml.Unmarshal(w.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("invalid RSS: %v\n%s", err, w.Body.String())
+	}
+	items := feed.Channel.Items
+	if len(items) != 2 {
+		t.Fatalf("got %d items, want 2", len(items))
+	}
+	if items[0].Link != "https://go.dev/blog/" || items[0].GUID.Value != "http://blog.test/posts/en-second" {
+		t.Errorf("link post item = %+v", items[0])
+	}
+	if items[1].Title != "First" || items[1].PubDate == "" || items[1].Description != "Heading Some bold text & more." {
+		t.Errorf("item = %+v", items[1])
+	}
+}
+
+func TestFeedEnclosures(t *testing.T) {
+	t.Chdir(t.TempDir())
+	os.Mkdir("posts", 0755)
+	os.Mkdir(attachmentsDir, 0755)
+	os.WriteFile(filepath.Join(attachmentsDir, "code.zip"), []byte("PK\x03\x04"), 0644)
+	os.WriteFile(filepath.Join("posts", "talk.md"), []byte("---\ntitle: Talk\ndate: 2025-01-02\nattachments: [code.zip, gone.pdf]\n---\n\nNotes."), 0644)
+	posts := newTestPostIndex(t, "posts")
+
+	w := httptest.NewRecorder()
+	AtomFeedHandler(posts)(w, httptest.NewRequest("GET", "http://blog.test/feed.xml", nil))
+	if body := w.Body.String(); !strings.Contains(body, `<link rel="enclosure" type="application/zip" href="http://blog.test/attachments/code.zip" length="4">`) || strings.Contains(body, "gone.pdf") {
+		t.Errorf("Atom enclosures: %s", body)
+	}
+
+	w = httptest.NewRecorder()
+	RSSFeedHandler(posts)(w, httptest.NewRequest("GET", "http://blog.test/rss.xml", nil))
+	if body := w.Body.String(); !strings.Contains(body, `<enclosure url="http://blog.test/attachments/code.zip" length="4" type="application/zip"></enclosure>`) {
+		t.Errorf("RSS enclosures: %s", body)
+	}
+}
+
+func TestFeedAudioEnclosures(t *testing.T) {
+	t.Chdir(t.TempDir())
+	os.Mkdir("posts", 0755)
+	os.Mkdir("audio", 0755)
+	os.WriteFile(filepath.Join("audio", "talk.mp3"), []byte("ID3abc"), 0644)
+	os.WriteFile(filepath.Join("posts", "talk.md"), []byte("---\ntitle: Talk\ndate: 2025-01-02\naudio: auto\n---\n\nNotes."), 0644)
+	os.WriteFile(filepath.Join("posts", "episode.md"), []byte("---\ntitle: Episode\ndate: 2025-01-03\naudio: https://cdn.example.com/ep1.mp3\n---\n\nShow notes."), 0644)
+	os.WriteFile(filepath.Join("posts", "pending.md"), []byte("---\ntitle: Pending\ndate: 2025-01-04\naudio: auto\n---\n\nNot narrated yet."), 0644)
+	posts := newTestPostIndex(t, "posts")
+
+	w := httptest.NewRecorder()
+	RSSFeedHandler(posts)(w, httptest.NewRequest("GET", "http://blog.test/rss.xml", nil))
+	body := w.Body.String()
+	for _, want := range []string{
+		`<enclosure url="http://blog.test/audio/talk.mp3" length="6" type="audio/mpeg"></enclosure>`,
+		`<enclosure url="https://cdn.example.com/ep1.mp3" length="0" type="audio/mpeg"></enclosure>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("RSS: missing %s in %s", want, body)
+		}
+	}
+	if strings.Count(body, "<enclosure") != 2 {
+		t.Errorf("RSS: want an enclosure only for the narrated posts: %s", body)
+	}
+
+	w = httptest.NewRecorder()
+	AtomFeedHandler(posts)(w, httptest.NewRequest("GET", "http://blog.test/feed.xml", nil))
+	if body := w.Body.String(); !strings.Contains(body, `<link rel="enclosure" type="audio/mpeg" href="http://blog.test/audio/talk.mp3" length="6">`) {
+		t.Errorf("Atom enclosures: %s", body)
+	}
+}
+
+func TestFeedLanguages(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "en-hello.md"), []byte("---\ntitle: Hello\ndate: 2024-01-02\n---\n\nHi there"), 0644)
+	os.WriteFile(filepath.Join(dir, "mixed.md"), []byte("---\ntitle: สวัสดี\ndate: 2024-01-03\n---\n\nบันทึกการเรียน Go"), 0644)
+	posts := newTestPostIndex(t, dir)
+
+	w := httptest.NewRecorder()
+	AtomFeedHandler(posts)(w, httptest.NewRequest("GET", "http://blog.test/feed.xml", nil))
+	atom := w.Body.String()
+	for _, want := range []string{`<feed xmlns="http://www.w3.org/2005/Atom" xml:lang="th">`, `<entry xml:lang="en">`, "<entry>"} {
+		if !strings.Contains(atom, want) {
+			t.Errorf("Atom feed missing %s:\n%s", want, atom)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	RSSFeedHandler(posts)(w, httptest.NewRequest("GET", "http://blog.test/rss.xml", nil))
+	rss := w.Body.String()
+	for _, want := range []string{"<language>th</language>", "<dc:language>en</dc:language>", "<dc:language>th</dc:language>"} {
+		if !strings.Contains(rss, want) {
+			t.Errorf("RSS feed missing %s", want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+)
+
+// GraphNode is a published post or a tag in the content graph. IDs are
+// "post:<slug>" and "tag:<tag slug>", so the two never clash.
+type GraphNode struct {
+	ID    string `json:"id"`
+	Type  string `json:"type"`
+	Label string `json:"label"`
+	URL   string `json:"url"`
+	Lang  string `json:"lang,omitempty"`
+	Date  string `json:"date,omitempty"`
+}
+
+// GraphEdge connects two nodes: a post to a post it links to with [[slug]]
+// ("link"), or a post to one of its tags ("tag")
+type GraphEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Type   string `json:"type"`
+}
+
+// ContentGraph is how the published posts connect through links and tags
+type ContentGraph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// buildGraph makes the content graph of the published posts: links to
+// unpublished posts and broken links are left out. The caller must hold the
+// lock, after update has built the published list.
+func (idx *PostIndex) buildGraph() *ContentGraph {
+	g := &ContentGraph{Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+	published := make(map[string]bool, len(idx.published))
+	for _, p := range idx.published {
+		published[p.Slug] = true
+	}
+
+	tags := make(map[string]string)
+	for _, p := range idx.published {
+		node := GraphNode{ID: "post:" + p.Slug, Type: "post", Label: p.Title, URL: postTreePath(p.Slug, p.Lang), Lang: p.Lang}
+		if !p.Date.IsZero() {
+			node.Date = p.Date.Format("2006-01-02")
+		}
+		g.Nodes = append(g.Nodes, node)
+
+		linked := make(map[string]bool)
+		if e, ok := idx.entries[p.Slug]; ok {
+			for _, target := range e.links {
+				to, ok := lookupLink(idx.entries, p.Slug, target)
+				if !ok || !published[to.Slug] || to.Slug == p.Slug || linked[to.Slug] {
+					continue
+				}
+				linked[to.Slug] = true
+				g.Edges = append(g.Edges, GraphEdge{Source: node.ID, Target: "post:" + to.Slug, Type: "link"})
+			}
+		}
+		tagged := make(map[string]bool)
+		for _, tag := range p.Tags {
+			slug := taxonomySlug(tag)
+			if slug == "" || tagged[slug] {
+				continue
+			}
+			tagged[slug] = true
+			// Posts are newest first, so a tag is labelled as last written
+			if _, ok := tags[slug]; !ok {
+				tags[slug] = tag
+			}
+			g.Edges = append(g.Edges, GraphEdge{Source: node.ID, Target: "tag:" + slug, Type: "tag"})
+		}
+	}
+
+	slugs := make([]string, 0, len(tags))
+	for slug := range tags {
+		slugs = append(slugs, slug)
+	}
+	sort.Strings(slugs)
+	for _, slug := range slugs {
+		g.Nodes = append(g.Nodes, GraphNode{ID: "tag:" + slug, Type: "tag", Label: tags[slug], URL: taxonomyURL("tags", slug)})
+	}
+	return g
+}
+
+// Graph returns the content graph as of the last change to the posts. It is
+// shared and must not be modified.
+func (idx *PostIndex) Graph() *ContentGraph {
+	// Scheduled posts that have come due join the graph here
+	idx.Posts()
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.graph
+}
+
+// GraphHandler serves the content graph as JSON at /api/graph, for a graph
+// view of how posts connect
+func GraphHandler(posts *PostIndex) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setSecurityHeaders(w)
+
+		body, err := json.Marshal(posts.Graph())
+		if err != nil {
+			slog.Error("Error encoding graph", "err", err)
+			http.Error(w, "Error rendering graph", http.StatusInternalServerError)
+			return
+		}
+
+		sum := sha256.Sum256(body)
+		etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write(body)
+	}
+}
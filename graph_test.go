@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestPostIndex_Graph(t *testing.T) {
+	idx := newCrossRefIndex(t, map[string]string{
+		"intro.md":    "---\ntitle: Intro\ndate: 2024-01-01\ntags: [Go Tips]\n---\nStart here.\n",
+		"next.md":     "---\ntitle: Next\ndate: 2024-02-01\ntags: [go-tips, web, Web]\n---\nAfter [[intro]] and [[intro#more]], not [[draft]] or [[nowhere]].\n",
+		"draft.md":    "---\ntitle: Draft\ndraft: true\ntags: [secret]\n---\nSee [[intro]].\n",
+		"th-guide.md": "---\ntitle: คู่มือ\ndate: 2024-03-01\n---\nอ่าน [[next|ต่อ]]\n",
+	})
+
+	g := idx.Graph()
+	var nodes []string
+	for _, n := range g.Nodes {
+		nodes = append(nodes, n.ID)
+	}
+	if want := []string{"post:th-guide", "post:next", "post:intro", "tag:go-tips", "tag:web"}; !slices.Equal(nodes, want) {
+		t.Errorf("nodes = %q, want %q", nodes, want)
+	}
+	if n := g.Nodes[0]; n.Type != "post" || n.Label != "คู่มือ" || n.URL != "/th/posts/guide" || n.Lang != "th" || n.Date != "2024-03-01" {
+		t.Errorf("post node = %+v", n)
+	}
+	// Tags are labelled as the newest post spells them
+	if n := g.Nodes[3]; n.Type != "tag" || n.Label != "go-tips" || n.URL != "/tags/go-tips" {
+		t.Errorf("tag node = %+v", n)
+	}
+
+	want := []GraphEdge{
+		{"post:th-guide", "post:next", "link"},
+		{"post:next", "post:intro", "link"},
+		{"post:next", "tag:go-tips", "tag"},
+		{"post:next", "tag:web", "tag"},
+		{"post:intro", "tag:go-tips", "tag"},
+	}
+	if !slices.Equal(g.Edges, want) {
+		t.Errorf("edges = %+v\nwant %+v", g.Edges, want)
+	}
+}
+
+func TestGraphHandler(t *testing.T) {
+	idx := newCrossRefIndex(t, map[string]string{
+		"a.md": "---\ntitle: A\n---\nSee [[b]].\n",
+		"b.md": "---\ntitle: B\n---\nHi\n",
+	})
+
+	w := httptest.NewRecorder()
+	GraphHandler(idx)(w, httptest.NewRequest("GET", "/api/graph", nil))
+	if w.Code != 200 {
+		t.Fatalf("status = %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	var g ContentGraph
+	if err := json.Unmarshal(w.Body.Bytes(), &g); err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Nodes) != 2 || len(g.Edges) != 1 || g.Edges[0] != (GraphEdge{"post:a", "post:b", "link"}) {
+		t.Errorf("graph = %+v", g)
+	}
+
+	r := httptest.NewRequest("GET", "/api/graph", nil)
+	r.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	GraphHandler(idx)(w, r)
+	if w.Code != 304 {
+		t.Errorf("conditional request status = %d, want 304", w.Code)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"html/template"
+	"log/slog"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// headURLAllowed reports whether a frontmatter head URL falls under one of the
+// configured prefixes. Only site-relative paths and https URLs are accepted,
+// and they're compared as the browser will resolve them: by scheme, host, and
+// decoded path, without dot segments.
+func headURLAllowed(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || strings.Contains(raw, "\\") || u.User != nil || u.Opaque != "" {
+		return false
+	}
+	switch {
+	case u.Scheme == "" && u.Host == "" && strings.HasPrefix(u.Path, "/") && !strings.HasPrefix(u.Path, "//"):
+	case u.Scheme == "https" && u.Host != "":
+	default:
+		return false
+	}
+	// u.Path is decoded, so %2e%2e counts as the .. a browser would take it for
+	for _, seg := range strings.Split(u.Path, "/") {
+		if seg == "." || seg == ".." {
+			return false
+		}
+	}
+	for _, prefix := range cfg.HeadAllowlist {
+		if headPrefixMatches(u, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// headPrefixMatches reports whether u is under an allowlist prefix: the same
+// scheme and host, and a path that is the prefix's or below it. A prefix
+// path without a trailing slash still only matches whole path segments.
+func headPrefixMatches(u *url.URL, prefix string) bool {
+	p, err := url.Parse(prefix)
+	if prefix == "" || err != nil || p.Scheme != u.Scheme || !strings.EqualFold(p.Host, u.Host) {
+		return false
+	}
+	switch {
+	case p.Path == "" || strings.HasSuffix(p.Path, "/"):
+		return strings.HasPrefix(u.Path, p.Path)
+	default:
+		return u.Path == p.Path || strings.HasPrefix(u.Path, p.Path+"/")
+	}
+}
+
+// preloadAs returns the preload destination for a URL based on its extension
+func preloadAs(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	switch strings.ToLower(path.Ext(u.Path)) {
+	case ".woff2", ".woff", ".ttf", ".otf":
+		return "font"
+	case ".css":
+		return "style"
+	case ".js", ".mjs":
+		return "script"
+	case ".jpg", ".jpeg", ".png", ".gif", ".webp", ".avif", ".svg":
+		return "image"
+	}
+	return ""
+}
+
+// postHeadTags renders the extra <head> elements a post asks for in its frontmatter
+// (head_scripts, head_styles, preload). URLs outside head_allowlist are dropped.
+func postHeadTags(fm PostFrontmatter, slug string) template.HTML {
+	var b strings.Builder
+	allowed := func(kind, raw string) bool {
+		if headURLAllowed(raw) {
+			return true
+		}
+		slog.Warn("URL is not in head_allowlist", "slug", slug, "kind", kind, "url", raw)
+		return false
+	}
+
+	for _, href := range fm.Preload {
+		as := preloadAs(href)
+		if as == "" || !allowed("preload", href) {
+			continue
+		}
+		b.WriteString(`<link rel="preload" href="` + template.HTMLEscapeString(href) + `" as="` + as + `"`)
+		if as == "font" {
+			b.WriteString(" crossorigin")
+		}
+		b.WriteString(">\n")
+	}
+	for _, href := range fm.HeadStyles {
+		if allowed("head_styles", href) {
+			b.WriteString(`<link rel="stylesheet" href="` + template.HTMLEscapeString(href) + `">` + "\n")
+		}
+	}
+	for _, src := range fm.HeadScripts {
+		if allowed("head_scripts", src) {
+			b.WriteString(`<script src="` + template.HTMLEscapeString(src) + `" defer></script>` + "\n")
+		}
+	}
+	return template.HTML(b.String())
+}
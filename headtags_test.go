@@ -0,0 +1,141 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPostHeadTags(t *testing.T) {
+	orig := cfg.HeadAllowlist
+	cfg.HeadAllowlist = []string{"/static/", "https://cdn.jsdelivr.net/npm/"}
+	defer func() { cfg.HeadAllowlist = orig }()
+
+	fm := PostFrontmatter{
+		HeadScripts: []string{
+			"https://cdn.jsdelivr.net/npm/mermaid@10/dist/mermaid.min.js",
+			"https://evil.example/x.js",
+			"//cdn.jsdelivr.net/npm/x.js",
+			"javascript:alert(1)",
+			"/static/../posts/secret.js",
+		},
+		HeadStyles: []string{"/static/demo.css", "http://cdn.jsdelivr.net/npm/x.css"},
+		Preload:    []string{"/static/fonts/mono.woff2", "/static/hero.webp", "/static/unknown.bin"},
+	}
+	got := string(postHeadTags(fm, "demo"))
+
+	want := []string{
+		`<script src="https://cdn.jsdelivr.net/npm/mermaid@10/dist/mermaid.min.js" defer></script>`,
+		`<link rel="stylesheet" href="/static/demo.css">`,
+		`<link rel="preload" href="/static/fonts/mono.woff2" as="font" crossorigin>`,
+		`<link rel="preload" href="/static/hero.webp" as="image">`,
+	}
+	for _, w := range want {
+		if !strings.Contains(got, w) {
+			t.Errorf("expected %s in %s", w, got)
+		}
+	}
+	for _, nw := range []string{"evil.example", "//cdn.jsdelivr.net/npm/x.js\"", "javascript:", "secret.js", "http://", "unknown.bin"} {
+		if strings.Contains(got, nw) {
+			t.Errorf("did not expect %s in %s", nw, got)
+		}
+	}
+}
+
+func TestHeadURLAllowed(t *testing.T) {
+	orig := cfg.HeadAllowlist
+	cfg.HeadAllowlist = []string{"/static/", "https://cdn.example.com", "https://unpkg.com/htmx.org"}
+	defer func() { cfg.HeadAllowlist = orig }()
+
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"/static/app.js", true},
+		{"/static/%2e%2e/images/x.js", false},
+		{"/static/%2E%2E/posts/x.js", false},
+		{"/static/./app.js", false},
+		{"/staticfile.js", false},
+		{"https://cdn.example.com/lib.js", true},
+		{"https://CDN.example.com/lib.js", true},
+		{"https://cdn.example.com.evil.net/lib.js", false},
+		{"https://cdn.example.com@evil.net/lib.js", false},
+		{"https://user@cdn.example.com/lib.js", false},
+		{"http://cdn.example.com/lib.js", false},
+		{"https://unpkg.com/htmx.org/dist/htmx.js", true},
+		{"https://unpkg.com/htmx.org", true},
+		{"https://unpkg.com/htmx.org.evil/x.js", false},
+		{"//cdn.example.com/lib.js", false},
+	}
+	for _, tt := range tests {
+		if got := headURLAllowed(tt.url); got != tt.want {
+			t.Errorf("headURLAllowed(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestPostHandler_HeadTags(t *testing.T) {
+	reader := &MockSlugReader{content: map[string]string{
+		"demo": "---\ntitle: Demo\nhead_scripts: [/static/demo.js]\n---\n\nBody",
+	}}
+	req := httptest.NewRequest("GET", "/posts/demo", nil)
+	req.SetPathValue("slug", "demo")
+	w := httptest.NewRecorder()
+	PostHandler(reader, nil, nil)(w, req)
+
+	head, _, _ := strings.Cut(w.Body.String(), "</head>")
+	if !strings.Contains(head, `<script src="/static/demo.js" defer></script>`) {
+		t.Errorf("expected the script in <head>, got %s", head)
+	}
+}
+
+func TestPostHandler_PreviewMeta(t *testing.T) {
+	reader := &MockSlugReader{content: map[string]string{
+		"shared":  "---\ntitle: Shared\ndescription: A short summary\nimage: /images/card.png\ncover: /images/cover.jpg\n---\n\nBody text",
+		"covered": "---\ntitle: Covered\ncover: /images/cover.jpg\n---\n\nThe start of the post.",
+		"plain":   "---\ntitle: Plain\n---\n\nNothing to show.",
+	}}
+	tests := []struct {
+		slug   string
+		want   []string
+		absent []string
+	}{
+		{"shared", []string{
+			`<meta name="description" content="A short summary">`,
+			`<meta property="og:description" content="A short summary">`,
+			`<meta property="og:type" content="article">`,
+			`<meta property="og:url" content="http://example.com/posts/shared">`,
+			`<meta property="og:image" content="http://example.com/images/card.png">`,
+			`<meta name="twitter:card" content="summary_large_image">`,
+			`<meta name="twitter:image" content="http://example.com/images/card.png">`,
+		}, []string{"cover.jpg"}},
+		{"covered", []string{
+			`<meta property="og:description" content="The start of the post.">`,
+			`<meta property="og:image" content="http://example.com/images/cover.jpg">`,
+		}, nil},
+		{"plain", []string{
+			`<meta name="twitter:card" content="summary">`,
+			`<meta name="twitter:title" content="Plain | `,
+		}, []string{"og:image", "twitter:image"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.slug, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/posts/"+tt.slug, nil)
+			req.SetPathValue("slug", tt.slug)
+			w := httptest.NewRecorder()
+			PostHandler(reader, nil, nil)(w, req)
+
+			head, _, _ := strings.Cut(w.Body.String(), "</head>")
+			for _, want := range tt.want {
+				if !strings.Contains(head, want) {
+					t.Errorf("expected %s in %s", want, head)
+				}
+			}
+			for _, nw := range tt.absent {
+				if strings.Contains(head, nw) {
+					t.Errorf("did not expect %s in %s", nw, head)
+				}
+			}
+		})
+	}
+}
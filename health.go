@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HealthResult is the outcome of one health check
+type HealthResult struct {
+	Name string
+	OK   bool
+	// Critical failures mean the site can't serve properly and fail /readyz;
+	// the others only warn
+	Critical bool
+	Detail   string
+}
+
+// deadJobCounter reports how many jobs exhausted their retries
+type deadJobCounter interface {
+	DeadCount() (int, error)
+}
+
+// HealthMonitor periodically checks free disk space, how recently the post
+// index was rescanned, failed jobs, and, when objectives are set, the error
+// rate and latency of each route. The latest results back /readyz and the
+// metrics, and a check that starts or stops failing is posted to the alert
+// webhooks.
+type HealthMonitor struct {
+	cfg HealthConfig
+	// dir is on the file system whose free space is checked
+	dir   string
+	index interface{ LastScan() time.Time }
+	jobs  deadJobCounter
+	// alerts queues the webhook deliveries; nil sends none
+	alerts *JobQueue
+	// routes are the request counts the SLO checks judge
+	routes routeStatsSource
+	window sloWindow
+
+	mu      sync.RWMutex
+	results []HealthResult
+}
+
+// NewHealthMonitor returns a monitor that has not run any checks yet
+func NewHealthMonitor(c HealthConfig, dir string, index interface{ LastScan() time.Time }, jobs deadJobCounter, alerts *JobQueue) *HealthMonitor {
+	return &HealthMonitor{cfg: c, dir: dir, index: index, jobs: jobs, alerts: alerts, routes: metrics}
+}
+
+// checkDisk fails when less than the configured share of the disk is free
+func (m *HealthMonitor) checkDisk() HealthResult {
+	res := HealthResult{Name: "disk", Critical: true}
+	free, total, err := diskUsage(m.dir)
+	if err != nil || total == 0 {
+		res.Detail = fmt.Sprintf("could not read disk usage: %v", err)
+		// Not knowing is not a reason to take the site out of rotation
+		res.Critical = false
+		return res
+	}
+	ratio := float64(free) / float64(total)
+	metrics.Set("disk_free_bytes", float64(free))
+	metrics.Set("disk_size_bytes", float64(total))
+	res.OK = ratio >= m.cfg.MinFreeDisk
+	res.Detail = fmt.Sprintf("%s free of %s (%.0f%%)", formatSize(int64(free)), formatSize(int64(total)), ratio*100)
+	return res
+}
+
+// checkIndex fails when the post index has not been rescanned for too long,
+// which means changes to posts are no longer being picked up
+func (m *HealthMonitor) checkIndex(now time.Time) HealthResult {
+	res := HealthResult{Name: "post_index", Critical: true}
+	age := now.Sub(m.index.LastScan())
+	metrics.Set("post_index_age_seconds", age.Seconds())
+	res.OK = age <= m.cfg.IndexStaleAfter
+	res.Detail = "last scanned " + age.Truncate(time.Second).String() + " ago"
+	return res
+}
+
+// checkJobs warns when more jobs than allowed have failed for good
+func (m *HealthMonitor) checkJobs() HealthResult {
+	res := HealthResult{Name: "jobs"}
+	n, err := m.jobs.DeadCount()
+	if err != nil {
+		res.Detail = fmt.Sprintf("could not count failed jobs: %v", err)
+		return res
+	}
+	metrics.Set("jobs_dead", float64(n))
+	res.OK = n <= m.cfg.MaxDeadJobs
+	res.Detail = strconv.Itoa(n) + " failed jobs (see /admin/jobs)"
+	return res
+}
+
+// Check runs every check, records the results, and alerts on changes
+func (m *HealthMonitor) Check(now time.Time) []HealthResult {
+	results := []HealthResult{m.checkDisk(), m.checkIndex(now), m.checkJobs()}
+	results = append(results, m.checkSLO(now)...)
+	failing := 0
+	for _, r := range results {
+		if !r.OK {
+			failing++
+		}
+	}
+	metrics.Set("health_checks_failing", float64(failing))
+
+	m.mu.Lock()
+	previous := m.results
+	m.results = results
+	m.mu.Unlock()
+
+	for i, r := range results {
+		// Only changes are worth a message; the first run reports what is failing
+		wasOK := previous == nil || previous[i].OK
+		if r.OK == wasOK {
+			continue
+		}
+		if wasOK {
+			slog.Warn("Health check failing", "check", r.Name, "detail", r.Detail)
+		} else {
+			slog.Info("Health check recovered", "check", r.Name, "detail", r.Detail)
+		}
+		m.alert(r)
+	}
+	return results
+}
+
+// alert queues a message about r for every alert webhook. The body carries the
+// message in "text", which Slack and Mattermost post as is, and "content" for
+// Discord.
+func (m *HealthMonitor) alert(r HealthResult) {
+	if m.alerts == nil || len(m.cfg.AlertWebhooks) == 0 {
+		return
+	}
+	status := "failing"
+	if r.OK {
+		status = "recovered"
+	}
+	site := strings.TrimSuffix(cfg.SiteURL, "/")
+	if site == "" {
+		site = "blog"
+	}
+	text := fmt.Sprintf("%s: %s check %s: %s", site, r.Name, status, r.Detail)
+	body, err := json.Marshal(map[string]string{
+		"text": text, "content": text, "check": r.Name, "status": status, "detail": r.Detail,
+	})
+	if err != nil {
+		slog.Error("Error encoding health alert", "err", err)
+		return
+	}
+	for _, url := range m.cfg.AlertWebhooks {
+		if err := m.alerts.Enqueue("webhook", WebhookDelivery{URL: url, Body: body}); err != nil {
+			slog.Error("Error queueing health alert", "err", err)
+		}
+	}
+}
+
+// Results returns the latest results, or nil before the first check
+func (m *HealthMonitor) Results() []HealthResult {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.results
+}
+
+// Run checks every interval until ctx is done
+func (m *HealthMonitor) Run(ctx context.Context, interval time.Duration) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+		m.Check(time.Now())
+	}
+}
+
+// ReadyzHandler answers 200 while no critical check fails and 503 otherwise,
+// listing every check either way, for load balancers and uptime monitors
+func ReadyzHandler(m *HealthMonitor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		results := m.Results()
+		if results == nil {
+			http.Error(w, "starting", http.StatusServiceUnavailable)
+			return
+		}
+		status := http.StatusOK
+		var b strings.Builder
+		for _, res := range results {
+			state := "ok"
+			switch {
+			case !res.OK && res.Critical:
+				state = "FAIL"
+				status = http.StatusServiceUnavailable
+			case !res.OK:
+				state = "warn"
+			}
+			fmt.Fprintf(&b, "%-4s %s: %s\n", state, res.Name, res.Detail)
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(b.String()))
+	}
+}
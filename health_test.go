@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeIndex struct{ scanned time.Time }
+
+func (f *fakeIndex) LastScan() time.Time { return f.scanned }
+
+type fakeDeadJobs struct{ n int }
+
+func (f *fakeDeadJobs) DeadCount() (int, error) { return f.n, nil }
+
+func TestHealthMonitor(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	index := &fakeIndex{scanned: now.Add(-time.Minute)}
+	jobs := &fakeDeadJobs{}
+	queue := newTestQueue(t)
+	var alerts []map[string]string
+	queue.Register("webhook", func(ctx context.Context, payload []byte) error {
+		var d WebhookDelivery
+		json.Unmarshal(payload, &d)
+		var body map[string]string
+		json.Unmarshal(d.Body, &body)
+		alerts = append(alerts, body)
+		return nil
+	})
+	runAlerts := func() {
+		for {
+			if processed, _ := queue.RunOnce(context.Background()); !processed {
+				return
+			}
+		}
+	}
+
+	c := HealthConfig{MinFreeDisk: 0, IndexStaleAfter: 15 * time.Minute, AlertWebhooks: []string{"https://hooks.example/x"}}
+	m := NewHealthMonitor(c, t.TempDir(), index, jobs, queue)
+	readyz := func() (int, string) {
+		w := httptest.NewRecorder()
+		ReadyzHandler(m)(w, httptest.NewRequest("GET", "/readyz", nil))
+		return w.Code, w.Body.String()
+	}
+
+	if code, _ := readyz(); code != http.StatusServiceUnavailable {
+		t.Errorf("before the first check: status %d", code)
+	}
+
+	m.Check(now)
+	runAlerts()
+	if code, body := readyz(); code != http.StatusOK || !strings.Contains(body, "ok   post_index: last scanned 1m0s ago") {
+		t.Errorf("healthy: %d %q", code, body)
+	}
+	if len(alerts) != 0 {
+		t.Errorf("alerts while healthy: %v", alerts)
+	}
+
+	// Failed jobs only warn; a stale index fails the check
+	jobs.n = 2
+	index.scanned = now.Add(-time.Hour)
+	m.Check(now)
+	runAlerts()
+	code, body := readyz()
+	if code != http.StatusServiceUnavailable || !strings.Contains(body, "FAIL post_index") || !strings.Contains(body, "warn jobs: 2 failed jobs") {
+		t.Errorf("failing: %d %q", code, body)
+	}
+	if len(alerts) != 2 || alerts[0]["check"] != "post_index" || alerts[0]["status"] != "failing" || alerts[1]["check"] != "jobs" {
+		t.Fatalf("alerts = %v", alerts)
+	}
+
+	// Still failing: no new alerts. Recovered: one each.
+	m.Check(now)
+	runAlerts()
+	if len(alerts) != 2 {
+		t.Errorf("repeated alerts: %v", alerts[2:])
+	}
+	jobs.n = 0
+	index.scanned = now
+	m.Check(now)
+	runAlerts()
+	if len(alerts) != 4 || alerts[2]["status"] != "recovered" || !strings.Contains(alerts[2]["text"], "post_index check recovered") {
+		t.Errorf("alerts = %v", alerts)
+	}
+}
+
+func TestHealthMonitorDisk(t *testing.T) {
+	index := &fakeIndex{scanned: time.Now()}
+	m := NewHealthMonitor(HealthConfig{MinFreeDisk: 0.999999, IndexStaleAfter: time.Hour}, t.TempDir(), index, &fakeDeadJobs{}, nil)
+	results := m.Check(time.Now())
+	disk := results[0]
+	if disk.Name != "disk" {
+		t.Fatalf("results = %+v", results)
+	}
+	// Either the platform can't tell, which only warns, or the disk isn't that empty
+	if disk.OK || disk.Detail == "" {
+		t.Errorf("disk = %+v", disk)
+	}
+}
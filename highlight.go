@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+)
+
+// highlightCSSPath is where the stylesheet for highlighted code is served.
+// Code is marked up with classes rather than inline styles, so the theme lives
+// in one cacheable file.
+const highlightCSSPath = "/static/highlight.css"
+
+// highlightFormat marks up highlighted code with chroma's classes
+var highlightFormat = []chromahtml.Option{chromahtml.WithClasses(true)}
+
+// highlightExtension colors fenced code blocks that name their language.
+// Blocks without one, or in a language chroma doesn't know, stay plain.
+func highlightExtension(c HighlightConfig) goldmark.Extender {
+	return highlighting.NewHighlighting(
+		highlighting.WithStyle(c.Style),
+		highlighting.WithFormatOptions(highlightFormat...),
+	)
+}
+
+// validHighlightStyle reports whether chroma has a style with this name
+func validHighlightStyle(name string) bool {
+	_, ok := styles.Registry[name]
+	return ok
+}
+
+// styleCSS renders the rules for a chroma style
+func styleCSS(style string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := chromahtml.New(highlightFormat...).WriteCSS(&buf, styles.Get(style)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// scopeCSS puts scope in front of every selector in chroma's CSS, which writes
+// one "/* Name */ .sel { ... }" rule per line
+func scopeCSS(css []byte, scope string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(strings.TrimSpace(string(css)), "\n") {
+		if _, rest, ok := strings.Cut(line, "*/"); ok {
+			line = strings.TrimSpace(rest)
+		}
+		sel, decl, ok := strings.Cut(line, "{")
+		if !ok {
+			continue
+		}
+		parts := strings.Split(sel, ",")
+		for i, p := range parts {
+			parts[i] = scope + " " + strings.TrimSpace(p)
+		}
+		b.WriteString(strings.Join(parts, ", ") + " {" + decl + "\n")
+	}
+	return b.String()
+}
+
+// highlightCSS renders the stylesheet for highlighted code. With a dark style
+// the two sets of colors follow the site theme the way style.css does: the
+// data-theme attribute the toggle sets, else the system preference. Each set
+// is scoped to its theme so no color from one leaks into the other.
+func highlightCSS(c HighlightConfig) ([]byte, error) {
+	light, err := styleCSS(c.Style)
+	if err != nil || c.DarkStyle == "" {
+		return light, err
+	}
+	dark, err := styleCSS(c.DarkStyle)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	b.WriteString("/* " + c.Style + " */\n")
+	b.WriteString(scopeCSS(light, `[data-theme="light"]`))
+	b.WriteString("@media (prefers-color-scheme: light) {\n" + scopeCSS(light, ":root:not([data-theme])") + "}\n")
+	b.WriteString("/* " + c.DarkStyle + " */\n")
+	b.WriteString(scopeCSS(dark, `[data-theme="dark"]`))
+	b.WriteString("@media (prefers-color-scheme: dark) {\n" + scopeCSS(dark, ":root:not([data-theme])") + "}\n")
+	return b.Bytes(), nil
+}
+
+// HighlightCSSHandler serves the stylesheet for the configured highlighting themes
+func HighlightCSSHandler(c HighlightConfig) http.HandlerFunc {
+	css, err := highlightCSS(c)
+	modTime := time.Now()
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			slog.Error("Error generating highlight CSS", "err", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/css; charset=utf-8")
+		http.ServeContent(w, r, "highlight.css", modTime, bytes.NewReader(css))
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHighlightCodeBlocks(t *testing.T) {
+	md := newMarkdown(MarkdownConfig{Highlight: HighlightConfig{Enabled: true, Style: "github"}})
+	tests := []struct {
+		name, src string
+		want      []string
+		notWant   []string
+	}{
+		{"known language", "```go\nfunc main() {}\n```", []string{`<pre class="chroma">`, `<span class="kd">func</span>`}, []string{"style="}},
+		{"no language", "```\nfunc main() {}\n```", []string{"<pre><code>func main() {}"}, []string{"chroma"}},
+		{"unknown language", "```klingon\nqapla'\n```", []string{`<code class="language-klingon">`}, []string{"chroma"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := md.Convert([]byte(tt.src), &buf); err != nil {
+				t.Fatal(err)
+			}
+			out := buf.String()
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("missing %q in %s", want, out)
+				}
+			}
+			for _, no := range tt.notWant {
+				if strings.Contains(out, no) {
+					t.Errorf("unexpected %q in %s", no, out)
+				}
+			}
+		})
+	}
+}
+
+func TestHighlightCSSHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	HighlightCSSHandler(HighlightConfig{Enabled: true, Style: "monokai"})(w, httptest.NewRequest("GET", highlightCSSPath, nil))
+	if w.Code != 200 || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/css") {
+		t.Fatalf("status %d, Content-Type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	// monokai's keyword color
+	if !strings.Contains(w.Body.String(), ".chroma .k { color: #66d9ef }") {
+		t.Errorf("body = %s", w.Body.String())
+	}
+}
+
+func TestHighlightCSSDarkStyle(t *testing.T) {
+	css, err := highlightCSS(HighlightConfig{Style: "github", DarkStyle: "monokai"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(css)
+	for _, want := range []string{
+		`[data-theme="light"] .chroma .k { color: #000000; font-weight: bold }`,
+		`[data-theme="dark"] .chroma .k { color: #66d9ef }`,
+		"@media (prefers-color-scheme: dark) {\n:root:not([data-theme]) .bg {",
+		"@media (prefers-color-scheme: light) {\n:root:not([data-theme]) .bg {",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+	// Every rule is scoped to one theme
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, ".") {
+			t.Errorf("unscoped rule %q", line)
+		}
+	}
+}
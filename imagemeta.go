@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// stripImageMetadata removes EXIF (including GPS location), XMP, IPTC, comments,
+// and text chunks from JPEG and PNG data. Pixels are copied verbatim; only the
+// EXIF orientation survives, so phone photos still display the right way up.
+func stripImageMetadata(data []byte, name string) ([]byte, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jpg", ".jpeg":
+		return stripJPEGMetadata(data)
+	case ".png":
+		return stripPNGMetadata(data)
+	}
+	return data, nil
+}
+
+// stripJPEGMetadata drops APP1 (EXIF/XMP), APP13 (IPTC), and COM segments
+func stripJPEGMetadata(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, errors.New("not a JPEG")
+	}
+
+	var out bytes.Buffer
+	out.Write(data[:2])
+
+	orientation := 0
+	pos := 2
+	for pos+2 <= len(data) {
+		if data[pos] != 0xFF {
+			return nil, fmt.Errorf("bad JPEG marker at offset %d", pos)
+		}
+		marker := data[pos+1]
+
+		// Markers may be padded with any number of 0xFF fill bytes
+		if marker == 0xFF {
+			pos++
+			continue
+		}
+		// TEM and RSTn stand alone, without a length
+		if marker == 0x01 || marker >= 0xD0 && marker <= 0xD7 {
+			out.Write(data[pos : pos+2])
+			pos += 2
+			continue
+		}
+
+		// Start of scan: the rest is compressed image data
+		if marker == 0xDA {
+			if orientation > 1 {
+				out.Write(exifOrientationSegment(orientation))
+			}
+			out.Write(data[pos:])
+			return out.Bytes(), nil
+		}
+
+		if pos+4 > len(data) {
+			return nil, fmt.Errorf("truncated JPEG segment at offset %d", pos)
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, fmt.Errorf("truncated JPEG segment at offset %d", pos)
+		}
+		segment := data[pos:end]
+
+		switch marker {
+		case 0xE1:
+			if o := exifOrientation(segment[4:]); o > 0 {
+				orientation = o
+			}
+		case 0xED, 0xFE:
+		default:
+			out.Write(segment)
+		}
+		pos = end
+	}
+	return nil, errors.New("JPEG has no image data")
+}
+
+// exifOrientation reads the orientation tag from an APP1 payload, or 0 if absent
+func exifOrientation(payload []byte) int {
+	if !bytes.HasPrefix(payload, []byte("Exif\x00\x00")) || len(payload) < 14 {
+		return 0
+	}
+	tiff := payload[6:]
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 0
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+	return 0
+}
+
+// exifOrientationSegment builds a minimal APP1 segment holding only the orientation tag
+func exifOrientationSegment(orientation int) []byte {
+	var tiff bytes.Buffer
+	tiff.WriteString("MM")
+	binary.Write(&tiff, binary.BigEndian, uint16(42))
+	binary.Write(&tiff, binary.BigEndian, uint32(8)) // offset of IFD0
+	binary.Write(&tiff, binary.BigEndian, uint16(1)) // one entry
+	binary.Write(&tiff, binary.BigEndian, uint16(0x0112))
+	binary.Write(&tiff, binary.BigEndian, uint16(3)) // SHORT
+	binary.Write(&tiff, binary.BigEndian, uint32(1))
+	binary.Write(&tiff, binary.BigEndian, uint16(orientation))
+	binary.Write(&tiff, binary.BigEndian, uint16(0))
+	binary.Write(&tiff, binary.BigEndian, uint32(0)) // no next IFD
+
+	payload := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	seg := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(seg[2:], uint16(len(payload)+2))
+	return append(seg, payload...)
+}
+
+// stripPNGMetadata drops eXIf, text, and timestamp chunks
+func stripPNGMetadata(data []byte) ([]byte, error) {
+	sig := []byte("\x89PNG\r\n\x1a\n")
+	if !bytes.HasPrefix(data, sig) {
+		return nil, errors.New("not a PNG")
+	}
+
+	var out bytes.Buffer
+	out.Write(sig)
+
+	pos := len(sig)
+	for pos+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		end := pos + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, fmt.Errorf("truncated PNG chunk at offset %d", pos)
+		}
+		chunk := data[pos:end]
+
+		typ := string(chunk[4:8])
+		switch typ {
+		case "eXIf", "tEXt", "zTXt", "iTXt", "tIME":
+		default:
+			if crc32.ChecksumIEEE(chunk[4:8+length]) != binary.BigEndian.Uint32(chunk[8+length:]) {
+				return nil, fmt.Errorf("bad CRC in PNG chunk %s", typ)
+			}
+			out.Write(chunk)
+		}
+		pos = end
+		if typ == "IEND" {
+			return out.Bytes(), nil
+		}
+	}
+	return nil, errors.New("PNG has no IEND chunk")
+}
+
+// writeStrippedImage writes a metadata-free copy of src to dst atomically
+func writeStrippedImage(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	clean, err := stripImageMetadata(data, src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".strip-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(clean); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
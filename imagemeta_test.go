@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// exifWithGPS builds an APP1 segment with an orientation tag and a fake GPS payload
+func exifWithGPS(orientation int) []byte {
+	seg := exifOrientationSegment(orientation)
+	seg = append(seg, []byte("GPS 13.7563N 100.5018E")...)
+	binary.BigEndian.PutUint16(seg[2:], uint16(len(seg)-2))
+	return seg
+}
+
+func TestStripJPEGMetadata(t *testing.T) {
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 8, 8)), nil)
+	orig := buf.Bytes()
+
+	// Insert EXIF and a comment segment after SOI
+	comment := []byte{0xFF, 0xFE, 0, 8, 's', 'e', 'c', 'r', 'e', 't'}
+	tagged := append([]byte{}, orig[:2]...)
+	tagged = append(tagged, exifWithGPS(6)...)
+	tagged = append(tagged, comment...)
+	tagged = append(tagged, orig[2:]...)
+
+	clean, err := stripJPEGMetadata(tagged)
+	if err != nil {
+		t.Fatalf("stripJPEGMetadata: %v", err)
+	}
+	if bytes.Contains(clean, []byte("GPS")) || bytes.Contains(clean, []byte("secret")) {
+		t.Error("metadata was not removed")
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(clean)); err != nil {
+		t.Errorf("stripped JPEG does not decode: %v", err)
+	}
+
+	// Orientation is preserved so the photo is not displayed rotated
+	idx := bytes.Index(clean, []byte("Exif\x00\x00"))
+	if idx < 0 || exifOrientation(clean[idx:]) != 6 {
+		t.Error("expected orientation to be preserved")
+	}
+}
+
+func TestStripJPEGMetadata_FillBytesAndStandaloneMarkers(t *testing.T) {
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 8, 8)), nil)
+	orig := buf.Bytes()
+
+	// Fill bytes before a marker, a stray RST0, and EXIF behind more fill
+	tagged := append([]byte{}, orig[:2]...)
+	tagged = append(tagged, 0xFF, 0xFF, 0xFF, 0xD0)
+	tagged = append(tagged, 0xFF, 0xFF)
+	tagged = append(tagged, exifWithGPS(1)...)
+	tagged = append(tagged, orig[2:]...)
+
+	clean, err := stripJPEGMetadata(tagged)
+	if err != nil {
+		t.Fatalf("stripJPEGMetadata: %v", err)
+	}
+	if bytes.Contains(clean, []byte("GPS")) {
+		t.Error("metadata was not removed")
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(clean)); err != nil {
+		t.Errorf("stripped JPEG does not decode: %v", err)
+	}
+
+	// TEM has no length either and is passed through as is
+	tem := append(append(append([]byte{}, orig[:2]...), 0xFF, 0x01), orig[2:]...)
+	clean, err = stripJPEGMetadata(tem)
+	if err != nil || !bytes.Equal(clean, tem) {
+		t.Errorf("stripJPEGMetadata with TEM: %v", err)
+	}
+}
+
+func TestStripPNGMetadata(t *testing.T) {
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 4)))
+	orig := buf.Bytes()
+
+	// Insert a tEXt chunk after the IHDR chunk (8-byte signature + 25-byte IHDR)
+	text := []byte("Comment\x00Taken at home")
+	chunk := make([]byte, 4)
+	binary.BigEndian.PutUint32(chunk, uint32(len(text)))
+	chunk = append(chunk, "tEXt"...)
+	chunk = append(chunk, text...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(append([]byte("tEXt"), text...)))
+	tagged := append(append(append([]byte{}, orig[:33]...), chunk...), orig[33:]...)
+
+	clean, err := stripPNGMetadata(tagged)
+	if err != nil {
+		t.Fatalf("stripPNGMetadata: %v", err)
+	}
+	if bytes.Contains(clean, []byte("Taken at home")) {
+		t.Error("text chunk was not removed")
+	}
+	if !bytes.Equal(clean, orig) {
+		t.Error("expected the original PNG back after stripping the added chunk")
+	}
+}
+
+func TestImageHandler_StripsServedOriginals(t *testing.T) {
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 8, 8)), nil)
+	data := append(append([]byte{0xFF, 0xD8}, exifWithGPS(1)...), buf.Bytes()[2:]...)
+	os.WriteFile(filepath.Join(dir, "phone.jpg"), data, 0644)
+
+	w := serveImage(ImageHandler(dir, t.TempDir(), true), "phone.jpg", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if bytes.Contains(w.Body.Bytes(), []byte("GPS")) {
+		t.Error("served image still contains GPS metadata")
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	_ "image/gif"
+
+	"github.com/HugoSmits86/nativewebp"
+	"golang.org/x/image/draw"
+)
+
+// imageWidths are the widths the resizing endpoint will generate. Restricting them
+// keeps the cache bounded no matter what query strings visitors send.
+var imageWidths = []int{160, 320, 640, 800, 1200, 1600}
+
+// isResizableImage reports whether the file extension is one the resizer handles
+func isResizableImage(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jpg", ".jpeg", ".png":
+		return true
+	}
+	return false
+}
+
+// variantCacheControl is sent with resized copies. Their URLs only change when
+// the image's name does, so uploads never replace an existing file.
+const variantCacheControl = "public, max-age=31536000, immutable"
+
+// ImageHandler serves files from dir. A ?w=N query returns a copy scaled down to
+// that width, generated on first request and cached under cacheDir, as WebP when
+// the browser takes it and that comes out smaller. Resized copies never carry
+// metadata; with stripMetadata the originals are also served from a cached copy
+// with EXIF/GPS data removed.
+func ImageHandler(dir, cacheDir string, stripMetadata bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("path")
+		if !fs.ValidPath(name) || strings.Contains(name, "\\") {
+			http.Error(w, "Invalid image path", http.StatusBadRequest)
+			return
+		}
+
+		src := filepath.Join(dir, filepath.FromSlash(name))
+		info, err := os.Stat(src)
+		if err != nil || info.IsDir() {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+
+		widthParam := r.URL.Query().Get("w")
+		if widthParam == "" || !isResizableImage(name) {
+			if !stripMetadata || !isResizableImage(name) {
+				http.ServeFile(w, r, src)
+				return
+			}
+
+			clean := filepath.Join(cacheDir, "original", filepath.FromSlash(name))
+			if !isFreshCopy(clean, info) {
+				if err := writeStrippedImage(src, clean); err != nil {
+					// Never fall back to the original: it may leak location data
+					slog.Error("Error stripping image metadata", "image", name, "err", err)
+					http.Error(w, "Could not process image", http.StatusInternalServerError)
+					return
+				}
+			}
+			http.ServeFile(w, r, clean)
+			return
+		}
+
+		width, err := strconv.Atoi(widthParam)
+		if err != nil || !slices.Contains(imageWidths, width) {
+			http.Error(w, "Unsupported image width", http.StatusBadRequest)
+			return
+		}
+
+		cached, err := resizedCopy(src, info, cacheDir, name, width)
+		if err != nil {
+			slog.Error("Error resizing image", "image", name, "width", width, "err", err)
+			http.Error(w, "Could not process image", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Cache-Control", variantCacheControl)
+		w.Header().Set("Vary", "Accept")
+		if strings.Contains(r.Header.Get("Accept"), "image/webp") {
+			if webp, err := webpCopy(cached); err != nil {
+				slog.Error("Error encoding WebP", "image", name, "width", width, "err", err)
+			} else if smallerFile(webp, cached) {
+				cached = webp
+			}
+		}
+		http.ServeFile(w, r, cached)
+	}
+}
+
+// resizedCopy returns the cached copy of src at width, making it if it's
+// missing or older than src
+func resizedCopy(src string, info os.FileInfo, cacheDir, name string, width int) (string, error) {
+	cached := filepath.Join(cacheDir, strconv.Itoa(width), filepath.FromSlash(name))
+	if isFreshCopy(cached, info) {
+		return cached, nil
+	}
+	if err := resizeImage(src, cached, width); err != nil {
+		return "", err
+	}
+	return cached, nil
+}
+
+// webpCopy returns a WebP version of the resized copy at path, kept next to
+// it. The encoder is lossless, so it beats PNG screenshots but often not JPEG
+// photos; callers serve whichever is smaller.
+func webpCopy(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	webp := path + ".webp"
+	if isFreshCopy(webp, info) {
+		return webp, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return "", fmt.Errorf("decode: %w", err)
+	}
+	if err := writeImageFile(webp, img, "webp"); err != nil {
+		return "", err
+	}
+	return webp, nil
+}
+
+// smallerFile reports whether the file at a is smaller than the one at b
+func smallerFile(a, b string) bool {
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bi, err := os.Stat(b)
+	return err == nil && ai.Size() < bi.Size()
+}
+
+// generateImageVariants makes every resized copy of an image, and their WebP
+// versions, so the first readers of a new image don't wait for them
+func generateImageVariants(dir, cacheDir, name string) error {
+	src := filepath.Join(dir, filepath.FromSlash(name))
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	for _, width := range imageWidths {
+		cached, err := resizedCopy(src, info, cacheDir, name, width)
+		if err != nil {
+			return fmt.Errorf("resize %s to %d: %w", name, width, err)
+		}
+		if _, err := webpCopy(cached); err != nil {
+			return fmt.Errorf("encode %s at %d as WebP: %w", name, width, err)
+		}
+	}
+	return nil
+}
+
+// isFreshCopy reports whether the cached file exists and is not older than the source
+func isFreshCopy(cached string, src os.FileInfo) bool {
+	ci, err := os.Stat(cached)
+	return err == nil && !ci.ModTime().Before(src.ModTime())
+}
+
+// resizeImage scales src down to width pixels wide, keeping the aspect ratio, and
+// writes it to dst in the same format. Images already narrower keep their size but
+// are still re-encoded, which drops any embedded metadata.
+func resizeImage(src, dst string, width int) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	img, format, err := image.Decode(f)
+	if err != nil {
+		return fmt.Errorf("decode: %w", err)
+	}
+
+	b := img.Bounds()
+	if b.Dx() > width {
+		height := b.Dy() * width / b.Dx()
+		scaled := image.NewRGBA(image.Rect(0, 0, width, max(height, 1)))
+		draw.CatmullRom.Scale(scaled, scaled.Bounds(), img, b, draw.Over, nil)
+		img = scaled
+	}
+
+	return writeImageFile(dst, img, format)
+}
+
+// writeImageFile encodes img to dst atomically so concurrent requests never see a
+// partially written file
+func writeImageFile(dst string, img image.Image, format string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".resize-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	switch format {
+	case "png":
+		err = png.Encode(tmp, img)
+	case "webp":
+		err = nativewebp.Encode(tmp, img, nil)
+	default:
+		err = jpeg.Encode(tmp, img, &jpeg.Options{Quality: 82})
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+	return os.Rename(tmp.Name(), dst)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// writeTestPNG creates a w×h PNG at path
+func writeTestPNG(t *testing.T, path string, w, h int) {
+	t.Helper()
+	os.MkdirAll(filepath.Dir(path), 0755)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, image.NewRGBA(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func serveImage(handler http.HandlerFunc, name, query string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/images/"+name+query, nil)
+	req.SetPathValue("path", name)
+	w := httptest.NewRecorder()
+	handler(w, req)
+	return w
+}
+
+func TestImageHandler_Resize(t *testing.T) {
+	dir := t.TempDir()
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	writeTestPNG(t, filepath.Join(dir, "photos", "big.png"), 1000, 500)
+	handler := ImageHandler(dir, cacheDir, false)
+
+	w := serveImage(handler, "photos/big.png", "?w=320")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	img, err := png.Decode(w.Body)
+	if err != nil {
+		t.Fatalf("response is not a PNG: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 320 || b.Dy() != 160 {
+		t.Errorf("expected 320x160, got %dx%d", b.Dx(), b.Dy())
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, "320", "photos", "big.png")); err != nil {
+		t.Errorf("expected resized copy to be cached: %v", err)
+	}
+
+	// The original is served when no width is requested
+	w = serveImage(handler, "photos/big.png", "")
+	if img, _ := png.Decode(w.Body); img == nil || img.Bounds().Dx() != 1000 {
+		t.Error("expected original image without ?w=")
+	}
+}
+
+func TestImageHandler_Rejects(t *testing.T) {
+	dir := t.TempDir()
+	writeTestPNG(t, filepath.Join(dir, "a.png"), 10, 10)
+	handler := ImageHandler(dir, t.TempDir(), false)
+
+	tests := []struct {
+		name, query string
+		want        int
+	}{
+		{"a.png", "?w=333", http.StatusBadRequest},
+		{"a.png", "?w=abc", http.StatusBadRequest},
+		{"../a.png", "", http.StatusBadRequest},
+		{"missing.png", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if w := serveImage(handler, tt.name, tt.query); w.Code != tt.want {
+			t.Errorf("%s%s: expected status %d, got %d", tt.name, tt.query, tt.want, w.Code)
+		}
+	}
+}
+
+func TestImageHandler_WebPAndCaching(t *testing.T) {
+	dir := t.TempDir()
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	writeTestPNG(t, filepath.Join(dir, "shot.png"), 1000, 500)
+	handler := ImageHandler(dir, cacheDir, false)
+
+	req := httptest.NewRequest("GET", "/images/shot.png?w=320", nil)
+	req.SetPathValue("path", "shot.png")
+	req.Header.Set("Accept", "image/avif,image/webp,*/*")
+	w := httptest.NewRecorder()
+	handler(w, req)
+	if ct := w.Header().Get("Content-Type"); ct != "image/webp" {
+		t.Fatalf("Content-Type = %q, want image/webp", ct)
+	}
+	img, _, err := image.Decode(w.Body)
+	if err != nil {
+		t.Fatalf("response is not an image: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 320 || b.Dy() != 160 {
+		t.Errorf("expected 320x160, got %dx%d", b.Dx(), b.Dy())
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != variantCacheControl {
+		t.Errorf("Cache-Control = %q, want %q", cc, variantCacheControl)
+	}
+	if v := w.Header().Get("Vary"); v != "Accept" {
+		t.Errorf("Vary = %q, want Accept", v)
+	}
+
+	// Browsers without WebP get the PNG
+	w = serveImage(handler, "shot.png", "?w=320")
+	if ct := w.Header().Get("Content-Type"); ct != "image/png" {
+		t.Errorf("Content-Type without WebP = %q, want image/png", ct)
+	}
+	// Originals may be replaced, so they aren't cached for good
+	w = serveImage(handler, "shot.png", "")
+	if cc := w.Header().Get("Cache-Control"); cc == variantCacheControl {
+		t.Errorf("original served with Cache-Control %q", cc)
+	}
+}
+
+func TestGenerateImageVariants(t *testing.T) {
+	dir := t.TempDir()
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	writeTestPNG(t, filepath.Join(dir, "a.png"), 900, 300)
+
+	if err := generateImageVariants(dir, cacheDir, "a.png"); err != nil {
+		t.Fatal(err)
+	}
+	for _, width := range imageWidths {
+		base := filepath.Join(cacheDir, strconv.Itoa(width), "a.png")
+		for _, path := range []string{base, base + ".webp"} {
+			if _, err := os.Stat(path); err != nil {
+				t.Errorf("variant missing: %v", err)
+			}
+		}
+	}
+}
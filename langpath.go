@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pathLanguages are the languages of the site's trees, e.g. /en/posts/hello
+var pathLanguages = []string{"th", "en"}
+
+// defaultLang returns the language of pages that don't say otherwise
+func defaultLang() string {
+	return cfg.Site.DefaultLang
+}
+
+// langPathKey is the request context key for the language taken from the path
+type langPathKey struct{}
+
+// splitLangPath splits a leading language segment off path, so "/en/posts/x"
+// gives "en" and "/posts/x". Paths without one come back unchanged with no language.
+func splitLangPath(path string) (string, string) {
+	for _, lang := range pathLanguages {
+		if path == "/"+lang {
+			return lang, "/"
+		}
+		if rest, ok := strings.CutPrefix(path, "/"+lang+"/"); ok {
+			return lang, "/" + rest
+		}
+	}
+	return "", path
+}
+
+// pathLang returns the language from the request's URL path, if any
+func pathLang(r *http.Request) string {
+	lang, _ := r.Context().Value(langPathKey{}).(string)
+	return lang
+}
+
+// langPathPrefix returns the path segment for the request's language, e.g. "/en"
+func langPathPrefix(r *http.Request) string {
+	if lang := pathLang(r); lang != "" {
+		return "/" + lang
+	}
+	return ""
+}
+
+// postFileLang returns the language a post is written in: its lang
+// frontmatter, or else the prefix of its file name. Posts with neither belong
+// to both trees.
+func postFileLang(slug string, fm PostFrontmatter) string {
+	if slices.Contains(postLangs, fm.Lang) {
+		return fm.Lang
+	}
+	lang, _ := postLang(slug)
+	return lang
+}
+
+// postInLang reports whether a post is listed in lang's tree
+func postInLang(p Post, lang string) bool {
+	return p.Lang == "" || p.Lang == lang
+}
+
+// localSlug drops the language prefix from a post's slug, giving its name in
+// its language's tree: en-hello is /en/posts/hello
+func localSlug(slug, lang string) string {
+	if lang == "" {
+		return slug
+	}
+	return strings.TrimPrefix(slug, lang+"-")
+}
+
+// postTreePath returns a post's address in its language's tree, or its plain
+// address, which redirects by preference, for posts in both
+func postTreePath(slug, lang string) string {
+	if lang == "" {
+		return "/posts/" + slug
+	}
+	return "/" + lang + "/posts/" + localSlug(slug, lang)
+}
+
+// acceptedLang returns the supported language the reader's browser ranks
+// highest in Accept-Language, or "" when it names none
+func acceptedLang(header string) string {
+	type ranked struct {
+		lang string
+		q    float64
+	}
+	var langs []ranked
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		primary, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if !slices.Contains(pathLanguages, primary) {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			langs = append(langs, ranked{primary, q})
+		}
+	}
+	if len(langs) == 0 {
+		return ""
+	}
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+	return langs[0].lang
+}
+
+// preferredLang picks the tree to send a reader to: the language they last
+// read in (the lang cookie), then their browser's languages, then the default
+func preferredLang(r *http.Request) string {
+	if lang := prefCookie(r, prefLang, pathLanguages); lang != "" {
+		return lang
+	}
+	if lang := acceptedLang(r.Header.Get("Accept-Language")); lang != "" {
+		return lang
+	}
+	return defaultLang()
+}
+
+// langAlternates links every language version of the page at path, plus the
+// language-neutral address as x-default, and records the page language for the
+// page scripts
+func langAlternates(base, path string, query url.Values, lang string) string {
+	query.Del("lang")
+	suffix := ""
+	if len(query) > 0 {
+		suffix = "?" + query.Encode()
+	}
+	var b strings.Builder
+	b.WriteString(`<meta name="lang-path" content="` + lang + `">` + "\n")
+	for _, l := range pathLanguages {
+		href := base + "/" + l + strings.TrimSuffix(path, "/") + suffix
+		if path == "/" {
+			href = base + "/" + l + "/" + suffix
+		}
+		b.WriteString(`<link rel="alternate" hreflang="` + l + `" href="` + template.HTMLEscapeString(href) + `">` + "\n")
+	}
+	b.WriteString(`<link rel="alternate" hreflang="x-default" href="` + template.HTMLEscapeString(base+path+suffix) + `">` + "\n")
+	return b.String()
+}
+
+// postLangAlternates links a post written in one language to its translations.
+// x-default is the version in the default language, or the post itself.
+func postLangAlternates(base string, p Post, translations []PostTranslation) string {
+	versions := append([]PostTranslation{{Lang: p.Lang, Slug: p.Slug, URL: postTreePath(p.Slug, p.Lang)}}, translations...)
+	var b strings.Builder
+	b.WriteString(`<meta name="lang-path" content="` + p.Lang + `">` + "\n")
+	fallback := versions[0].URL
+	for _, v := range versions {
+		b.WriteString(`<link rel="alternate" hreflang="` + v.Lang + `" href="` + template.HTMLEscapeString(base+v.URL) + `">` + "\n")
+		if v.Lang == defaultLang() {
+			fallback = v.URL
+		}
+	}
+	b.WriteString(`<link rel="alternate" hreflang="x-default" href="` + template.HTMLEscapeString(base+fallback) + `">` + "\n")
+	return b.String()
+}
+
+// translationLabels offer a translation in its own language
+var translationLabels = map[string]string{
+	"th": "อ่านบทความนี้เป็นภาษาไทย",
+	"en": "Read this in English",
+}
+
+// translationLinksHTML links a post page to the post's translations
+func translationLinksHTML(root string, translations []PostTranslation) string {
+	if len(translations) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(`<p class="translations">`)
+	for i, t := range translations {
+		if i > 0 {
+			b.WriteString(" · ")
+		}
+		b.WriteString(`<a href="` + template.HTMLEscapeString(root+t.URL) + `" hreflang="` + t.Lang + `" lang="` + t.Lang + `">` +
+			template.HTMLEscapeString(translationLabels[t.Lang]) + `</a>`)
+	}
+	b.WriteString("</p>\n")
+	return b.String()
+}
+
+// localPostLinkRegexes match links to posts named with a language prefix, by language
+var localPostLinkRegexes = func() map[string]*regexp.Regexp {
+	m := make(map[string]*regexp.Regexp)
+	for _, lang := range pathLanguages {
+		m[lang] = regexp.MustCompile(`(\s(?:href|action|formaction)=")/posts/` + lang + `-`)
+	}
+	return m
+}()
+
+// localPostLinks shortens links to lang's posts in a page of lang's tree, so
+// /posts/en-hello is written /posts/hello (and becomes /en/posts/hello)
+func localPostLinks(body []byte, lang string) []byte {
+	re, ok := localPostLinkRegexes[lang]
+	if !ok {
+		return body
+	}
+	return re.ReplaceAll(body, []byte("${1}/posts/"))
+}
+
+// postPathSegment splits "/posts/{name}/rest" into name and rest
+func postPathSegment(path string) (string, string, bool) {
+	after, ok := strings.CutPrefix(path, "/posts/")
+	if !ok || after == "" {
+		return "", "", false
+	}
+	name, rest, found := strings.Cut(after, "/")
+	if found {
+		rest = "/" + rest
+	}
+	return name, rest, true
+}
+
+// resolve finds the post named name in lang's tree. name may end in a format
+// extension such as .md. It returns the post's file slug with the extension,
+// or, for a post in the other language, the path to redirect to instead.
+// Unknown names come back unchanged.
+func (idx *PostIndex) resolve(lang, name string) (slug, redirect string) {
+	stem, ext, _ := strings.Cut(name, ".")
+	if ext != "" {
+		ext = "." + ext
+	}
+	if _, ok := idx.Get(lang + "-" + stem); ok {
+		return lang + "-" + stem + ext, ""
+	}
+	if p, ok := idx.Get(stem); ok {
+		if postInLang(p.Post, lang) {
+			return name, ""
+		}
+		return "", postTreePath(stem, p.Lang) + ext
+	}
+	for _, other := range pathLanguages {
+		if _, ok := idx.Get(other + "-" + stem); ok && other != lang {
+			return "", "/" + other + "/posts/" + stem + ext
+		}
+	}
+	return name, ""
+}
+
+// langRedirect sends an unprefixed request to its address in a language tree,
+// if it has one: / and the tag and category pages go to the reader's
+// preferred language, posts to their own, and ?lang= links to the language
+// they name. It reports whether it redirected.
+func langRedirect(w http.ResponseWriter, r *http.Request, posts *PostIndex) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	query := r.URL.Query()
+	target, status := "", http.StatusFound
+	if lang := query.Get("lang"); slices.Contains(pathLanguages, lang) {
+		target, status = "/"+lang+r.URL.Path, http.StatusMovedPermanently
+		if r.URL.Path == "/" {
+			target = "/" + lang + "/"
+		}
+	} else if r.URL.Path == "/" || strings.HasPrefix(r.URL.Path, "/tags/") || strings.HasPrefix(r.URL.Path, "/categories/") {
+		target = "/" + preferredLang(r) + r.URL.Path
+	} else if name, rest, ok := postPathSegment(r.URL.Path); ok && rest == "" && IsValidSlug(name) {
+		lang := preferredLang(r)
+		if p, found := posts.Get(name); found && p.Lang != "" {
+			// An address from before the trees, with the language in the file name
+			target, status = postTreePath(name, p.Lang), http.StatusMovedPermanently
+		} else if slug, redirect := posts.resolve(lang, name); redirect != "" {
+			target = redirect
+		} else if _, found := posts.Get(slug); found {
+			// Posts in both trees, and tree names, go by preference
+			target = "/" + lang + "/posts/" + name
+		}
+	}
+	if target == "" {
+		return false
+	}
+	query.Del("lang")
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	if status == http.StatusFound {
+		w.Header().Set("Vary", "Accept-Language, Cookie")
+	}
+	http.Redirect(w, r, target, status)
+	return true
+}
+
+// LanguagePaths serves the site as two trees, /th/ and /en/, taking the
+// language from the path. Links in a page keep the language segment, so
+// readers stay in their language, and posts are addressed by their name in the
+// tree: /en/posts/hello serves en-hello.md. Pages outside the trees that have
+// a place in them are redirected there; other pages (admin, feeds, static
+// files) are served as they are.
+func LanguagePaths(posts *PostIndex, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lang, rest := splitLangPath(r.URL.Path)
+		inner := r
+		if lang == "" {
+			if langRedirect(w, r, posts) {
+				return
+			}
+		} else {
+			path := rest
+			if name, after, ok := postPathSegment(rest); ok {
+				slug, redirect := posts.resolve(lang, name)
+				if redirect != "" {
+					redirect += after
+					if r.URL.RawQuery != "" {
+						redirect += "?" + r.URL.RawQuery
+					}
+					http.Redirect(w, r, redirect, http.StatusMovedPermanently)
+					return
+				}
+				path = "/posts/" + slug + after
+			}
+			inner = r.WithContext(context.WithValue(r.Context(), langPathKey{}, lang))
+			u := *r.URL
+			u.Path, u.RawPath = path, ""
+			inner.URL = &u
+		}
+
+		headTags := langAlternates(siteURL(r), rest, r.URL.Query(), requestLang(inner))
+		// A post in one language has its translations as alternates, not the same
+		// name in the other tree
+		if name, after, ok := postPathSegment(inner.URL.Path); ok && after == "" {
+			if p, found := posts.Get(name); found && p.Lang != "" && lang != "" {
+				headTags = postLangAlternates(siteURL(r), p.Post, posts.Translations(name))
+			}
+		}
+		pw := &prefixWriter{
+			ResponseWriter:  w,
+			keepCookiePaths: true,
+			headTags:        headTags,
+			head:            r.Method == http.MethodHead,
+		}
+		if lang != "" {
+			pw.prefix, pw.postLang = "/"+lang, lang
+		}
+		next.ServeHTTP(pw, inner)
+		pw.finish()
+	})
+}
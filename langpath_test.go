@@ -0,0 +1,174 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSplitLangPath(t *testing.T) {
+	tests := []struct {
+		path, lang, rest string
+	}{
+		{"/en/posts/hello", "en", "/posts/hello"},
+		{"/th/", "th", "/"},
+		{"/en", "en", "/"},
+		{"/english/posts", "", "/english/posts"},
+		{"/fr/posts", "", "/fr/posts"},
+		{"/posts/en", "", "/posts/en"},
+		{"/", "", "/"},
+	}
+	for _, tt := range tests {
+		if lang, rest := splitLangPath(tt.path); lang != tt.lang || rest != tt.rest {
+			t.Errorf("splitLangPath(%q) = %q, %q; want %q, %q", tt.path, lang, rest, tt.lang, tt.rest)
+		}
+	}
+}
+
+func TestAcceptedLang(t *testing.T) {
+	tests := []struct {
+		header, want string
+	}{
+		{"en-US,en;q=0.9", "en"},
+		{"th-TH,th;q=0.9,en;q=0.8", "th"},
+		{"fr-FR,en;q=0.5,th;q=0.7", "th"},
+		{"fr,de", ""},
+		{"en;q=0", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := acceptedLang(tt.header); got != tt.want {
+			t.Errorf("acceptedLang(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestLanguagePaths(t *testing.T) {
+	t.Chdir(t.TempDir())
+	os.Mkdir("posts", 0755)
+	os.WriteFile(filepath.Join("posts", "en-hello.md"), []byte("---\ntitle: Hello\ntags: [go]\n---\n\nHi"), 0644)
+	os.WriteFile(filepath.Join("posts", "th-sawasdee.md"), []byte("---\ntitle: สวัสดี\ntags: [go]\n---\n\nHi"), 0644)
+	os.WriteFile(filepath.Join("posts", "both.md"), []byte("---\ntitle: Both\n---\n\nHi"), 0644)
+	posts := newTestPostIndex(t, "posts")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", HomeHandler(posts, nil))
+	mux.HandleFunc("GET /tags/{name}", TagHandler(posts))
+	mux.HandleFunc("GET /posts/{slug}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("post " + r.PathValue("slug")))
+	})
+	mux.HandleFunc("GET /go", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/saved", http.StatusSeeOther)
+	})
+	h := LanguagePaths(posts, mux)
+	get := func(target string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "http://blog.test"+target, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/en/")
+	body := w.Body.String()
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d", w.Code)
+	}
+	for _, want := range []string{
+		`href="/en/posts/hello"`,
+		`href="/en/posts/both"`,
+		`href="/en/projects"`,
+		`<meta name="lang-path" content="en">`,
+		`<link rel="alternate" hreflang="th" href="http://blog.test/th/">`,
+		`<link rel="alternate" hreflang="en" href="http://blog.test/en/">`,
+		`<link rel="alternate" hreflang="x-default" href="http://blog.test/">`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %s", want)
+		}
+	}
+	if strings.Contains(body, "sawasdee") {
+		t.Error("Thai post listed on the English homepage")
+	}
+	if c := w.Header().Get("Set-Cookie"); !strings.HasPrefix(c, "lang=en;") || !strings.Contains(c, "Path=/;") {
+		t.Errorf("Set-Cookie = %q", c)
+	}
+
+	if body := get("/th/tags/go").Body.String(); !strings.Contains(body, `href="/th/posts/sawasdee"`) || strings.Contains(body, "hello") {
+		t.Errorf("Thai tag page = %s", body)
+	}
+
+	tests := []struct {
+		target   string
+		header   []string
+		status   int
+		location string
+	}{
+		{"/", nil, http.StatusFound, "/th/"},
+		{"/", []string{"Accept-Language", "en-GB,en;q=0.9"}, http.StatusFound, "/en/"},
+		{"/", []string{"Accept-Language", "en", "Cookie", "lang=th"}, http.StatusFound, "/th/"},
+		{"/tags/go", []string{"Cookie", "lang=en"}, http.StatusFound, "/en/tags/go"},
+		{"/?lang=en&page=2", nil, http.StatusMovedPermanently, "/en/?page=2"},
+		{"/posts/en-hello", nil, http.StatusMovedPermanently, "/en/posts/hello"},
+		{"/posts/hello", nil, http.StatusFound, "/en/posts/hello"},
+		{"/posts/both", []string{"Accept-Language", "en"}, http.StatusFound, "/en/posts/both"},
+		{"/th/posts/hello", nil, http.StatusMovedPermanently, "/en/posts/hello"},
+		{"/th/go", nil, http.StatusSeeOther, "/th/saved"},
+	}
+	for _, tt := range tests {
+		w := get(tt.target, tt.header...)
+		if w.Code != tt.status || w.Header().Get("Location") != tt.location {
+			t.Errorf("GET %s %v = %d %q, want %d %q", tt.target, tt.header, w.Code, w.Header().Get("Location"), tt.status, tt.location)
+		}
+	}
+
+	for target, want := range map[string]string{
+		"/en/posts/hello":    "post en-hello",
+		"/en/posts/both":     "post both",
+		"/th/posts/sawasdee": "post th-sawasdee",
+		"/en/posts/nope":     "post nope",
+	} {
+		if body := get(target).Body.String(); body != want {
+			t.Errorf("GET %s = %q, want %q", target, body, want)
+		}
+	}
+
+	// A post in one language has its translation as its alternate
+	os.WriteFile(filepath.Join("posts", "learning-go.md"), []byte("---\nlang: en\n---\n\nHi"), 0644)
+	os.WriteFile(filepath.Join("posts", "rian-go.md"), []byte("---\ntranslation_of: learning-go\n---\n\nบันทึกการเรียน"), 0644)
+	posts.Scan()
+	page := LanguagePaths(posts, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<html><head></head><body></body></html>"))
+	}))
+	getPage := func(target string) string {
+		w := httptest.NewRecorder()
+		page.ServeHTTP(w, httptest.NewRequest("GET", "http://blog.test"+target, nil))
+		return w.Body.String()
+	}
+	body = getPage("/en/posts/learning-go")
+	for _, want := range []string{
+		`<link rel="alternate" hreflang="en" href="http://blog.test/en/posts/learning-go">`,
+		`<link rel="alternate" hreflang="th" href="http://blog.test/th/posts/rian-go">`,
+		`<link rel="alternate" hreflang="x-default" href="http://blog.test/th/posts/rian-go">`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %s", want)
+		}
+	}
+	if body := getPage("/en/posts/hello"); strings.Contains(body, `hreflang="th"`) {
+		t.Errorf("untranslated post links a Thai version: %s", body)
+	}
+
+	// Cookie-free: the language stays in the URL only
+	defer func(old bool) { cfg.Cookies.RememberPreferences = old }(cfg.Cookies.RememberPreferences)
+	cfg.Cookies.RememberPreferences = false
+	if c := get("/en/").Header().Get("Set-Cookie"); c != "" {
+		t.Errorf("cookie set in cookie-free mode: %s", c)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"html/template"
+	"net/url"
+	"strings"
+)
+
+// linkPostURL returns the external URL of a link post, or "" when the post is an
+// ordinary article or the link is not an absolute http(s) URL
+func linkPostURL(link string) string {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	return u.String()
+}
+
+// linkPostHost returns the bare host of a link post URL for display
+func linkPostHost(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(u.Hostname(), "www.")
+}
+
+// originallyPublishedHTML renders the note on a syndicated post that links to
+// where it was first published
+func originallyPublishedHTML(original, lang string) string {
+	label := "Originally published at"
+	if lang == "th" {
+		label = "เผยแพร่ครั้งแรกที่"
+	}
+	return "<p class=\"originally-published\">" + label + " <a href=\"" + template.HTMLEscapeString(original) + "\" rel=\"noopener\">" +
+		template.HTMLEscapeString(linkPostHost(original)) + "</a></p>\n"
+}
+
+// linkPostTitleHTML renders a post title pointing at the external URL, with the
+// host shown next to it so readers know they are leaving the site
+func linkPostTitleHTML(title, link string) string {
+	return "<a class=\"link-post-title\" href=\"" + template.HTMLEscapeString(link) + "\" rel=\"noopener\">" +
+		template.HTMLEscapeString(title) + " →</a> <span class=\"link-post-host\">" +
+		template.HTMLEscapeString(linkPostHost(link)) + "</span>"
+}
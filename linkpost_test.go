@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLinkPostURL(t *testing.T) {
+	tests := []struct {
+		link string
+		want string
+	}{
+		{"https://go.dev/blog/", "https://go.dev/blog/"},
+		{"  http://example.com/a?b=c  ", "http://example.com/a?b=c"},
+		{"", ""},
+		{"javascript:alert(1)", ""},
+		{"/posts/local", ""},
+		{"https://", ""},
+	}
+	for _, tt := range tests {
+		if got := linkPostURL(tt.link); got != tt.want {
+			t.Errorf("linkPostURL(%q) = %q, want %q", tt.link, got, tt.want)
+		}
+	}
+}
+
+func TestLinkPost_Listings(t *testing.T) {
+	t.Chdir(t.TempDir())
+	os.Mkdir("posts", 0755)
+	post := "---\ntitle: Go 1.25 is out\nlink: https://www.go.dev/blog/go1.25\n---\n\nMy thoughts."
+	os.WriteFile(filepath.Join("posts", "go-125.md"), []byte(post), 0644)
+
+	w := httptest.NewRecorder()
+	HomeHandler(newTestPostIndex(t, "posts"), nil)(w, httptest.NewRequest("GET", "/?lang=en", nil))
+	body := w.Body.String()
+	for _, want := range []string{
+		`<li class="link-post">`,
+		`href="https://www.go.dev/blog/go1.25" rel="noopener">Go 1.25 is out →</a>`,
+		`<span class="link-post-host">go.dev</span>`,
+		`href="/posts/go-125"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("homepage missing %q in %s", want, body)
+		}
+	}
+
+	posts := &MockSlugReader{content: map[string]string{"go-125": post}}
+	req := httptest.NewRequest("GET", "/posts/go-125", nil)
+	req.SetPathValue("slug", "go-125")
+	w = httptest.NewRecorder()
+	PostHandler(posts, nil, nil)(w, req)
+	if body := w.Body.String(); !strings.Contains(body, `<h1 class="link-post"><a class="link-post-title" href="https://www.go.dev/blog/go1.25"`) ||
+		!strings.Contains(body, "My thoughts.") {
+		t.Errorf("post page should link the title and show the commentary, got %s", body)
+	}
+
+	req = httptest.NewRequest("GET", "/posts/go-125.json", nil)
+	req.SetPathValue("slug", "go-125")
+	w = httptest.NewRecorder()
+	PostJSONHandler(posts)(w, req)
+	var got PostJSON
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.ExternalURL != "https://www.go.dev/blog/go1.25" || got.URL != "/posts/go-125" {
+		t.Errorf("JSON urls = %q, %q", got.ExternalURL, got.URL)
+	}
+}
+
+func TestPostHandler_CanonicalURL(t *testing.T) {
+	posts := &MockSlugReader{content: map[string]string{
+		"syndicated": "---\ntitle: Elsewhere\ncanonical_url: https://www.dev.to/me/elsewhere\n---\nCopy",
+		"own":        "---\ntitle: Mine\n---\nHere first",
+		"bad":        "---\ntitle: Bad\ncanonical_url: javascript:alert(1)\n---\nHmm",
+	}}
+	get := func(slug string) string {
+		r := httptest.NewRequest("GET", "http://example.com/posts/"+slug+"?lang=en", nil)
+		r.SetPathValue("slug", slug)
+		w := httptest.NewRecorder()
+		PostHandler(posts, nil, nil)(w, r)
+		return w.Body.String()
+	}
+
+	body := get("syndicated")
+	for _, want := range []string{
+		`<link rel="canonical" href="https://www.dev.to/me/elsewhere">`,
+		`<meta property="og:url" content="https://www.dev.to/me/elsewhere">`,
+		`<p class="originally-published">Originally published at <a href="https://www.dev.to/me/elsewhere" rel="noopener">dev.to</a></p>`,
+		// Readers share the copy they're reading
+		"url=http%3A%2F%2Fexample.com%2Fposts%2Fsyndicated",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("syndicated post missing %s", want)
+		}
+	}
+
+	for _, slug := range []string{"own", "bad"} {
+		body := get(slug)
+		if !strings.Contains(body, `<link rel="canonical" href="http://example.com/posts/`+slug+`">`) {
+			t.Errorf("%s: not canonical to itself", slug)
+		}
+		if strings.Contains(body, "originally-published") {
+			t.Errorf("%s: shows an originally published note", slug)
+		}
+	}
+}
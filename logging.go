@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+)
+
+// logLevels maps log_level values to slog levels
+var logLevels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// newLogger returns a logger writing to w at the configured level, as JSON
+// lines when format is "json" and as key=value text otherwise
+func newLogger(w io.Writer, format, level string) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: logLevels[level]}
+	if format == "json" {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+// setupLogging sends the server's logs to stderr in the configured format
+func setupLogging(c Config) {
+	slog.SetDefault(newLogger(os.Stderr, c.LogFormat, c.LogLevel))
+}
+
+// fatal logs an error the program can't go on without and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "err", err)
+	os.Exit(1)
+}
+
+// AccessLog logs every request with its status, size, and how long it took
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sr := &statusRecorder{ResponseWriter: w}
+		start := time.Now()
+		next.ServeHTTP(sr, r)
+		slog.Info("Request",
+			"method", r.Method,
+			"path", r.URL.RequestURI(),
+			"status", sr.code(),
+			"duration_ms", float64(time.Since(start).Microseconds())/1000,
+			"bytes", sr.bytes,
+			"remote", clientIP(r),
+		)
+	})
+}
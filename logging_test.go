@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// captureLogs sends slog and log output to the returned buffer, as text at
+// debug level, until the test ends
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	old, oldOut, oldFlags := slog.Default(), log.Writer(), log.Flags()
+	t.Cleanup(func() {
+		slog.SetDefault(old)
+		log.SetOutput(oldOut)
+		log.SetFlags(oldFlags)
+	})
+	slog.SetDefault(newLogger(&buf, "text", "debug"))
+	return &buf
+}
+
+func TestNewLogger(t *testing.T) {
+	tests := []struct {
+		format, level string
+		want          string
+		wantDebug     bool
+	}{
+		{"text", "info", `level=INFO msg=Hello slug=hi`, false},
+		{"text", "debug", `level=INFO msg=Hello slug=hi`, true},
+		{"json", "info", `"level":"INFO","msg":"Hello","slug":"hi"}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format+"/"+tt.level, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := newLogger(&buf, tt.format, tt.level)
+			logger.Debug("Hidden")
+			logger.Info("Hello", "slug", "hi")
+			if !strings.Contains(buf.String(), tt.want) {
+				t.Errorf("log = %q, want %q", buf.String(), tt.want)
+			}
+			if got := strings.Contains(buf.String(), "Hidden"); got != tt.wantDebug {
+				t.Errorf("debug logged = %v, want %v", got, tt.wantDebug)
+			}
+		})
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	logged := captureLogs(t)
+
+	handler := AccessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("short and stout"))
+	}))
+	req := httptest.NewRequest("GET", "/posts/hello?x=1", nil)
+	req.RemoteAddr = "192.0.2.7:5555"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	out := logged.String()
+	for _, want := range []string{
+		`msg=Request method=GET path="/posts/hello?x=1" status=418 duration_ms=`,
+		` bytes=15 remote=192.0.2.7`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log = %q, want %q", out, want)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/image/draw"
+)
+
+// lqipWidth is the width of the tiny preview; the browser blurs it when scaling up
+const lqipWidth = 16
+
+// ImagePlaceholder describes an image's intrinsic size and a tiny preview of it
+type ImagePlaceholder struct {
+	Width   int
+	Height  int
+	DataURI string
+}
+
+type placeholderEntry struct {
+	modTime time.Time
+	ph      ImagePlaceholder
+}
+
+// placeholderCache memoizes placeholders by file path, regenerated when the file changes
+var placeholderCache sync.Map
+
+var (
+	imgTagRegex = regexp.MustCompile(`<img\s[^>]*>`)
+	imgSrcRegex = regexp.MustCompile(`\ssrc="([^"]*)"`)
+	// imageRefRegex finds references to files under images/ in post markdown
+	imageRefRegex = regexp.MustCompile(`/images/([^\s"'()<>?#\]]+)`)
+)
+
+// errNoPlaceholder is returned for images whose placeholder hasn't been
+// generated since the file last changed
+var errNoPlaceholder = errors.New("no placeholder generated")
+
+// imagePlaceholder returns the placeholder generated for an image file under
+// images/. Rendering only looks placeholders up; the index generates them with
+// preparePlaceholder as it reads posts.
+func imagePlaceholder(path string) (ImagePlaceholder, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ImagePlaceholder{}, err
+	}
+	if e, ok := placeholderCache.Load(path); ok {
+		if entry := e.(placeholderEntry); entry.modTime.Equal(info.ModTime()) {
+			return entry.ph, nil
+		}
+	}
+	return ImagePlaceholder{}, errNoPlaceholder
+}
+
+// preparePlaceholder generates the placeholder for an image file unless the
+// one cached is current
+func preparePlaceholder(path string) (ImagePlaceholder, error) {
+	if ph, err := imagePlaceholder(path); !errors.Is(err, errNoPlaceholder) {
+		return ph, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return ImagePlaceholder{}, err
+	}
+	ph, err := generatePlaceholder(path)
+	if err != nil {
+		return ImagePlaceholder{}, err
+	}
+	placeholderCache.Store(path, placeholderEntry{modTime: info.ModTime(), ph: ph})
+	return ph, nil
+}
+
+// preparePostPlaceholders generates the placeholders for the local images a
+// post's markdown refers to, including its cover and its galleries
+func preparePostPlaceholders(slug, content string) {
+	content, err := openPost(content)
+	if err != nil {
+		return
+	}
+	refs := content
+	for _, sub := range shortcodeRegex.FindAllStringSubmatch(content, -1) {
+		if sub[1] != "gallery" {
+			continue
+		}
+		call := ShortcodeCall{Name: sub[1], Slug: slug}
+		for _, a := range shortcodeArgRegex.FindAllStringSubmatch(sub[2], -1) {
+			if a[1] == "" {
+				call.Args = append(call.Args, a[2]+a[3])
+			}
+		}
+		if html, err := galleryShortcode(call); err == nil {
+			refs += html
+		}
+	}
+	for _, m := range imageRefRegex.FindAllStringSubmatch(refs, -1) {
+		rel, err := url.PathUnescape(m[1])
+		if err != nil || strings.Contains(rel, "..") || !isResizableImage(rel) {
+			continue
+		}
+		_, err = preparePlaceholder(filepath.Join("images", filepath.FromSlash(rel)))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("Error generating image placeholder", "slug", slug, "image", rel, "err", err)
+		}
+	}
+}
+
+// generatePlaceholder decodes an image and encodes a tiny copy as a data URI.
+// Images over maxUploadPixels are refused before decoding, as uploads are.
+func generatePlaceholder(path string) (ImagePlaceholder, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return ImagePlaceholder{}, err
+	}
+	defer f.Close()
+
+	ic, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return ImagePlaceholder{}, fmt.Errorf("decode %s: %w", path, err)
+	}
+	if ic.Width*ic.Height > maxUploadPixels {
+		return ImagePlaceholder{}, fmt.Errorf("%s is %dx%d, over %d pixels", path, ic.Width, ic.Height, maxUploadPixels)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return ImagePlaceholder{}, err
+	}
+	img, format, err := image.Decode(f)
+	if err != nil {
+		return ImagePlaceholder{}, fmt.Errorf("decode %s: %w", path, err)
+	}
+
+	b := img.Bounds()
+	height := max(b.Dy()*lqipWidth/max(b.Dx(), 1), 1)
+	small := image.NewRGBA(image.Rect(0, 0, lqipWidth, height))
+	draw.ApproxBiLinear.Scale(small, small.Bounds(), img, b, draw.Over, nil)
+
+	var buf bytes.Buffer
+	mime := "image/jpeg"
+	if format == "png" {
+		mime = "image/png"
+		err = png.Encode(&buf, small)
+	} else {
+		err = jpeg.Encode(&buf, small, &jpeg.Options{Quality: 40})
+	}
+	if err != nil {
+		return ImagePlaceholder{}, fmt.Errorf("encode placeholder for %s: %w", path, err)
+	}
+
+	return ImagePlaceholder{
+		Width:   b.Dx(),
+		Height:  b.Dy(),
+		DataURI: "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(buf.Bytes()),
+	}, nil
+}
+
+// addImagePlaceholders gives local <img> tags their intrinsic width/height, so the
+// layout doesn't shift while loading, and a blurred preview as their background
+func addImagePlaceholders(htmlContent string) string {
+	return imgTagRegex.ReplaceAllStringFunc(htmlContent, func(tag string) string {
+		if strings.Contains(tag, " width=") || strings.Contains(tag, " style=") {
+			return tag
+		}
+		m := imgSrcRegex.FindStringSubmatch(tag)
+		if m == nil {
+			return tag
+		}
+		u, err := url.Parse(html.UnescapeString(m[1]))
+		if err != nil || u.Host != "" || !strings.HasPrefix(u.Path, "/images/") || !isResizableImage(u.Path) {
+			return tag
+		}
+		rel := strings.TrimPrefix(u.Path, "/images/")
+		if strings.Contains(rel, "..") {
+			return tag
+		}
+
+		ph, err := imagePlaceholder(filepath.Join("images", filepath.FromSlash(rel)))
+		if err != nil {
+			return tag
+		}
+
+		attrs := ` width="` + strconv.Itoa(ph.Width) + `" height="` + strconv.Itoa(ph.Height) + `"` +
+			` class="lqip" style="background-image: url(` + ph.DataURI + `)"`
+		return strings.Replace(tag, "<img", "<img"+attrs, 1)
+	})
+}
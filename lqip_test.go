@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAddImagePlaceholders(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeTestPNG(t, filepath.Join("images", "wide.png"), 200, 100)
+	writeTestPNG(t, filepath.Join("images", "unindexed.png"), 200, 100)
+	if _, err := preparePlaceholder(filepath.Join("images", "wide.png")); err != nil {
+		t.Fatalf("preparePlaceholder: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{
+			name: "local image",
+			in:   `<p><img src="/images/wide.png" alt="Wide"></p>`,
+			want: []string{`width="200"`, `height="100"`, `class="lqip"`, `background-image: url(data:image/png;base64,`},
+		},
+		{
+			name: "resized variant",
+			in:   `<img src="/images/wide.png?w=320" alt="">`,
+			want: []string{`width="200"`, `height="100"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := addImagePlaceholders(tt.in)
+			for _, w := range tt.want {
+				if !strings.Contains(got, w) {
+					t.Errorf("expected %q in %s", w, got)
+				}
+			}
+		})
+	}
+
+	// Remote, missing, already-sized, and not yet indexed images are left
+	// untouched; rendering never decodes an image itself
+	for _, in := range []string{
+		`<img src="https://example.com/a.png" alt="">`,
+		`<img src="/images/missing.png" alt="">`,
+		`<img src="/images/unindexed.png" alt="">`,
+		`<img width="10" src="/images/wide.png" alt="">`,
+	} {
+		if got := addImagePlaceholders(in); got != in {
+			t.Errorf("expected %s unchanged, got %s", in, got)
+		}
+	}
+}
+
+func TestImagePlaceholder_RegeneratesWhenFileChanges(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.png")
+	writeTestPNG(t, path, 40, 40)
+
+	first, err := preparePlaceholder(path)
+	if err != nil {
+		t.Fatalf("preparePlaceholder: %v", err)
+	}
+
+	writeTestPNG(t, path, 80, 20)
+	info, _ := os.Stat(path)
+	os.Chtimes(path, info.ModTime(), info.ModTime().Add(1e9))
+
+	if _, err := imagePlaceholder(path); err != errNoPlaceholder {
+		t.Errorf("stale placeholder returned, err %v", err)
+	}
+	second, err := preparePlaceholder(path)
+	if err != nil {
+		t.Fatalf("preparePlaceholder: %v", err)
+	}
+	if first.Width == second.Width || second.Width != 80 || second.Height != 20 {
+		t.Errorf("expected regenerated placeholder 80x20, got %dx%d", second.Width, second.Height)
+	}
+}
+
+func TestPostIndex_PreparesPlaceholders(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeTestPNG(t, filepath.Join("images", "cover.png"), 40, 20)
+	writeTestPNG(t, filepath.Join("images", "inline.png"), 30, 30)
+	writeTestPNG(t, filepath.Join("images", "trip", "beach.png"), 60, 30)
+	os.MkdirAll("posts", 0755)
+	os.WriteFile(filepath.Join("posts", "trip.md"), []byte("---\ntitle: Trip\ncover: /images/cover.png\n---\n\n![Inline](/images/inline.png)\n\n{{< gallery \"trip/*\" >}}\n"), 0644)
+
+	if _, err := NewPostIndex("posts"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"cover.png", "inline.png", "trip/beach.png"} {
+		if _, err := imagePlaceholder(filepath.Join("images", filepath.FromSlash(name))); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestGeneratePlaceholder_PixelCap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "huge.png")
+	writeTestPNG(t, path, 1, 1)
+
+	// Claim a size over the cap in the header, as a decompression bomb would
+	b, _ := os.ReadFile(path)
+	binary.BigEndian.PutUint32(b[16:], 20000)
+	binary.BigEndian.PutUint32(b[20:], 20000)
+	binary.BigEndian.PutUint32(b[29:], crc32.ChecksumIEEE(b[12:29]))
+	os.WriteFile(path, b, 0644)
+
+	if _, err := generatePlaceholder(path); err == nil || !strings.Contains(err.Error(), "over") {
+		t.Errorf("generatePlaceholder = %v, want the pixel cap", err)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strings"
+	"time"
+)
+
+// EmailMessage is the payload of an "email" job
+type EmailMessage struct {
+	To      []string `json:"to"`
+	ReplyTo string   `json:"reply_to,omitempty"`
+	Subject string   `json:"subject"`
+	Body    string   `json:"body"`
+	HTML    string   `json:"html,omitempty"`
+}
+
+// Mailer sends email through an SMTP relay
+type Mailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// NewMailerFromEnv configures a Mailer from SMTP_* environment variables
+func NewMailerFromEnv() *Mailer {
+	m := &Mailer{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     os.Getenv("SMTP_PORT"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+	}
+	if m.Port == "" {
+		m.Port = "587"
+	}
+	return m
+}
+
+// Enabled reports whether enough settings are present to send mail
+func (m *Mailer) Enabled() bool {
+	return m.Host != "" && m.From != ""
+}
+
+// HandleJob sends the EmailMessage carried by a queued job
+func (m *Mailer) HandleJob(ctx context.Context, payload []byte) error {
+	var msg EmailMessage
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return fmt.Errorf("decode email job: %w", err)
+	}
+	return m.Send(msg)
+}
+
+// Send delivers a single message
+func (m *Mailer) Send(msg EmailMessage) error {
+	if !m.Enabled() {
+		return errors.New("mailer is not configured (set SMTP_HOST and SMTP_FROM)")
+	}
+	if len(msg.To) == 0 {
+		return errors.New("email has no recipients")
+	}
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	return smtp.SendMail(net.JoinHostPort(m.Host, m.Port), auth, m.From, msg.To, m.buildMessage(msg))
+}
+
+// buildMessage renders the RFC 5322 message with UTF-8 headers. Messages with an HTML
+// body are sent as multipart/alternative with the plain-text body as the fallback part.
+func (m *Mailer) buildMessage(msg EmailMessage) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("From: " + m.From + "\r\n")
+	buf.WriteString("To: " + strings.Join(msg.To, ", ") + "\r\n")
+	if msg.ReplyTo != "" {
+		buf.WriteString("Reply-To: " + msg.ReplyTo + "\r\n")
+	}
+	buf.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", msg.Subject) + "\r\n")
+	buf.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	if msg.HTML == "" {
+		buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		writeQuotedPrintable(&buf, msg.Body)
+		return buf.Bytes()
+	}
+
+	mw := multipart.NewWriter(&buf)
+	buf.WriteString("Content-Type: multipart/alternative; boundary=" + mw.Boundary() + "\r\n\r\n")
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=UTF-8", msg.Body},
+		{"text/html; charset=UTF-8", msg.HTML},
+	} {
+		pw, _ := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		writeQuotedPrintable(pw, part.body)
+	}
+	mw.Close()
+	return buf.Bytes()
+}
+
+// writeQuotedPrintable writes s to w using quoted-printable encoding
+func writeQuotedPrintable(w io.Writer, s string) {
+	qp := quotedprintable.NewWriter(w)
+	qp.Write([]byte(s))
+	qp.Close()
+}
@@ -1,22 +1,26 @@
 package main
 
 import (
-	"bytes"
 	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"html/template"
 	"io"
-	"log"
+	"io/fs"
+	"log/slog"
 	"net/http"
+	"net/http/pprof"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"regexp"
-	"sort"
+	"slices"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
-	"github.com/yuin/goldmark"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 	"gopkg.in/yaml.v3"
@@ -28,62 +32,578 @@ type Post struct {
 	Title   string
 	Date    time.Time
 	DateStr string
+	// Link is the external URL of a link post
+	Link     string
+	Tags     []string
+	Category string
+	// Lang is the language the post is written in, or "" for both
+	Lang string
+	// Translation is shared by the language versions of a post, "" when it has none
+	Translation string
+	// WordCount and ReadingTime, in minutes, are estimated from the markdown
+	WordCount   int
+	ReadingTime int
 }
 
 // PostFrontmatter represents the YAML frontmatter in posts
 type PostFrontmatter struct {
-	Title string `yaml:"title"`
-	Date  string `yaml:"date"`
+	Title string   `yaml:"title"`
+	Date  string   `yaml:"date"`
+	Tags  []string `yaml:"tags"`
+	// Updated is when the post was last revised; the post links to what changed
+	Updated string `yaml:"updated"`
+	// Lang is "th" or "en"; without it a th- or en- prefix on the file name says
+	Lang string `yaml:"lang"`
+	// TranslationOf names the post this one translates, e.g. en-hello. Posts
+	// named alike apart from their th-/en- prefix are translations without it.
+	TranslationOf string `yaml:"translation_of"`
+	// Category files the post under one /categories/{category} page
+	Category string `yaml:"category"`
+	// Audio is an mp3 narration: a file in audio/, a URL, or "auto" to generate one
+	Audio string `yaml:"audio"`
+	// Attachments are files in attachments/ offered for download below the post
+	// and as enclosures in the feeds
+	Attachments []PostAttachment `yaml:"attachments"`
+	// CommentsLocked closes a post to new comments while keeping existing ones visible
+	CommentsLocked bool `yaml:"comments_locked"`
+	// Draft keeps the post out of listings, feeds, and the sitemap, and off its
+	// URL except for previews
+	Draft bool `yaml:"draft"`
+	// Private keeps the post unlisted for good, readable only through preview
+	// links, with its body encrypted on disk when POST_ENCRYPTION_KEY is set
+	Private bool `yaml:"private"`
+	// Link makes this a link post: listings point at the URL and the post body
+	// is commentary on it
+	Link string `yaml:"link"`
+	// CanonicalURL is where a syndicated post was first published. Search
+	// engines are pointed there, and the post says so above its body.
+	CanonicalURL string `yaml:"canonical_url"`
+	// Aliases are the post's old addresses, redirected to it for good: old
+	// slugs, or paths starting with / such as a previous engine's permalinks
+	Aliases []string `yaml:"aliases"`
+	// Cover is the post's lead image, a path under /images/ or a URL, used for
+	// og:image and the image sitemap
+	Cover string `yaml:"cover"`
+	// Description and Image are what link previews on social platforms show;
+	// without them the start of the post and the cover are used
+	Description string `yaml:"description"`
+	Image       string `yaml:"image"`
+	// Crosspost set to false keeps the post off the configured social platforms
+	Crosspost *bool `yaml:"crosspost"`
+	// TOC lists the post's h2 and h3 headings at the top of the article
+	TOC bool `yaml:"toc"`
+
+	// HeadScripts, HeadStyles, and Preload add elements to <head> for posts with
+	// demos; each URL must match head_allowlist in config
+	HeadScripts []string `yaml:"head_scripts"`
+	HeadStyles  []string `yaml:"head_styles"`
+	Preload     []string `yaml:"preload"`
 }
 
 // PageData holds data for HTML templates
 type PageData struct {
 	Title   string
 	Content template.HTML
+	// Status is the response status, 200 when unset
+	Status int
+	// Robots is the content of the robots meta tag
+	Robots string
+	// Head holds extra tags for <head>, such as canonical and pagination links
+	Head template.HTML
+	// URL is the page's canonical absolute URL, when known
+	URL string
+	// Share holds share links shown under the content
+	Share []ShareLink
+	// Summary, Image, and Type describe the page itself in link previews (Open
+	// Graph and Twitter Cards); without them the site's description is used
+	Summary string
+	Image   string
+	Type    string
+	// CookieDomain and CookieSameSite let page scripts set cookies with the
+	// same attributes as the server
+	CookieDomain   string
+	CookieSameSite string
+	// RememberPreferences lets page scripts store the language and theme
+	RememberPreferences bool
+	// Display is the reader's font size and line width
+	Display DisplayPrefs
+	// HighlightCSS links the stylesheet for highlighted code
+	HighlightCSS bool
+	// LiveReload adds the script that reloads the page on file changes (-dev)
+	LiveReload bool
+	// SiteName, Description, Author, and DefaultLang come from the site config
+	SiteName    string
+	Description string
+	Author      string
+	DefaultLang string
 }
 
-// Cached template for performance
-var tmpl *template.Template
+// Cached templates for performance: the default page template, and one per
+// language that has overrides
+var (
+	tmpl      *template.Template
+	langTmpls map[string]*template.Template
+)
 
 // Slug validation regex - only allow alphanumeric, hyphens, and underscores
 var validSlugRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
 
 func init() {
 	var err error
-	tmpl, err = template.ParseFiles("templates/base.html")
+	tmpl, err = parsePageTemplates(".", "")
+	if err != nil {
+		fatal("Failed to parse template", err)
+	}
+	langTmpls, err = parseLangTemplates(".")
+	if err != nil {
+		fatal("Failed to parse template", err)
+	}
+}
+
+// parsePageTemplates parses templates/base.html and templates/partials/*.html
+// under dir. For a lang, base.{lang}.html and partials/*.{lang}.html take the
+// place of the templates they share a name with, so typography and layout
+// tweaks for one language don't reach the others.
+func parsePageTemplates(dir, lang string) (*template.Template, error) {
+	base, overrides, err := langTemplateFiles(dir, lang)
+	if err != nil {
+		return nil, err
+	}
+	t, err := template.ParseFiles(base)
+	if err != nil {
+		return nil, err
+	}
+	partials, err := filepath.Glob(filepath.Join(dir, "templates/partials/*.html"))
 	if err != nil {
-		log.Fatalf("Failed to parse template: %v", err)
+		return nil, err
+	}
+	// Defaults first, so the definitions in the language's files replace them
+	var defaults []string
+	for _, f := range partials {
+		if templateLang(f) == "" {
+			defaults = append(defaults, f)
+		}
+	}
+	for _, files := range [][]string{defaults, overrides} {
+		if len(files) == 0 {
+			continue
+		}
+		if t, err = t.ParseFiles(files...); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// langTemplateFiles returns the base template to use for lang, and its
+// partial overrides
+func langTemplateFiles(dir, lang string) (base string, overrides []string, err error) {
+	base = filepath.Join(dir, "templates/base.html")
+	if lang == "" {
+		return base, nil, nil
+	}
+	f := filepath.Join(dir, "templates/base."+lang+".html")
+	if _, err := os.Stat(f); err == nil {
+		base = f
+	}
+	overrides, err = filepath.Glob(filepath.Join(dir, "templates/partials/*."+lang+".html"))
+	return base, overrides, err
+}
+
+// templateLang returns the language suffix of a template file name, as in
+// header.th.html, or "" for the default templates
+func templateLang(name string) string {
+	ext := filepath.Ext(strings.TrimSuffix(filepath.Base(name), ".html"))
+	if lang := strings.TrimPrefix(ext, "."); slices.Contains(pathLanguages, lang) {
+		return lang
+	}
+	return ""
+}
+
+// parseLangTemplates parses the page templates for each language with
+// overrides under dir
+func parseLangTemplates(dir string) (map[string]*template.Template, error) {
+	out := make(map[string]*template.Template)
+	for _, lang := range pathLanguages {
+		base, overrides, err := langTemplateFiles(dir, lang)
+		if err != nil {
+			return nil, err
+		}
+		if filepath.Base(base) == "base.html" && len(overrides) == 0 {
+			continue
+		}
+		t, err := parsePageTemplates(dir, lang)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", lang, err)
+		}
+		out[lang] = t
 	}
+	return out, nil
 }
 
 func main() {
-	// Get port from environment or use default
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "3030"
+	exportDir := flag.String("export", "", "write the site as static files to `dir` and exit")
+	flag.BoolVar(&devMode, "dev", false, "reload templates on every request and the browser when templates, static files, or posts change")
+	flag.Parse()
+
+	// Load config.yaml plus the overlay for the active environment
+	var err error
+	cfg, err = LoadConfig(".", os.Getenv("BLOG_ENV"))
+	if err != nil {
+		fatal("Failed to load config", err)
+	}
+	if devMode {
+		cfg.HotReload = true
+	}
+	if err := cfg.Validate(); err != nil {
+		fatal("Invalid config", err)
+	}
+	setupLogging(cfg)
+	// "check", "backup", and "restore" run in place of the server
+	if args := flag.Args(); len(args) > 0 {
+		if args[0] == "check" && len(args) == 1 {
+			err = runCheckCommand()
+		} else {
+			err = runBackupCommand(args)
+		}
+		if err != nil {
+			fatal("Command failed", err)
+		}
+		return
+	}
+	if err := checkStartup("."); err != nil {
+		fatal("Startup check failed", err)
+	}
+	port := cfg.Port
+	slog.Debug("Effective config:\n" + configSummary(cfg))
+	markdown = newMarkdown(cfg.Markdown)
+	playground = newPlaygroundProxy(cfg.Playground)
+	repoCards = newRepoCards(cfg.Repos)
+	if postKey, err = parsePostKey(os.Getenv("POST_ENCRYPTION_KEY")); err != nil {
+		fatal("Invalid config", err)
+	}
+
+	// Every handler reads posts from this index, which follows changes to the
+	// store they're kept in
+	store, err := openPostStore(context.Background(), cfg.PostStore)
+	if err != nil {
+		fatal("Failed to open post store", err)
+	}
+	posts, err := NewStoreIndex(store)
+	if err != nil {
+		fatal("Failed to index posts", err)
+	}
+	linkedPosts = posts
+	if collisions, err := sectionCollisions(); err != nil {
+		slog.Error("Error listing section entries", "err", err)
+	} else {
+		for _, c := range collisions {
+			slog.Warn("Slug collision", "path", c.Path, "files", c.Files)
+		}
+	}
+	previewToken = os.Getenv("PREVIEW_TOKEN")
+	if *exportDir != "" {
+		if err := exportSite(posts, *exportDir); err != nil {
+			fatal("Export failed", err)
+		}
+		return
+	}
+
+	// Open the database backing the job queue
+	db, err := openDB(databasePath())
+	if err != nil {
+		fatal("Failed to open database", err)
+	}
+	defer db.Close()
+
+	// Background job queue for slow work (email, webhooks)
+	queue, err := NewJobQueue(db)
+	if err != nil {
+		fatal("Failed to set up job queue", err)
+	}
+	mailer := NewMailerFromEnv()
+	queue.Register("email", mailer.HandleJob)
+	queue.Register("webhook", WebhookJob(&http.Client{Timeout: 15 * time.Second}))
+	queue.RegisterTimeout("tts", TTSJob(cfg.TTS, os.Getenv("TTS_API_KEY"), posts, "audio", &http.Client{Timeout: 5 * time.Minute}), 5*time.Minute)
+	queue.Register("image-variants", ImageVariantsJob("images", cfg.ImageCacheDir))
+
+	ctx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	go queue.Run(ctx)
+	if cfg.TTS.Endpoint != "" {
+		go RunNarrationScanner(ctx, time.Minute, queue, posts, "audio")
+	}
+	go posts.Watch(ctx, cfg.PostRescanInterval)
+	health := NewHealthMonitor(cfg.Health, ".", posts, queue, queue)
+	health.Check(time.Now())
+	go health.Run(ctx, cfg.Health.Interval)
+	if cfg.Backup.Enabled {
+		go RunBackups(ctx, snapshotStoreFromConfig(cfg.Backup), db, cfg.Backup.Paths, cfg.Backup.Interval, cfg.Backup.Keep)
+	}
+
+	contacts, err := NewContactStore(db)
+	if err != nil {
+		fatal("Failed to set up contact store", err)
+	}
+	comments, err := NewCommentStore(db)
+	if err != nil {
+		fatal("Failed to set up comment store", err)
+	}
+	drafts, err := NewDraftStore(db)
+	if err != nil {
+		fatal("Failed to set up draft store", err)
+	}
+	crossposts, err := NewCrossPostStore(db)
+	if err != nil {
+		fatal("Failed to set up cross-post store", err)
+	}
+	mentions, err := NewMentionStore(db)
+	if err != nil {
+		fatal("Failed to set up mention store", err)
+	}
+	previewChecks, err := NewPreviewCheckStore(db)
+	if err != nil {
+		fatal("Failed to set up preview check store", err)
+	}
+	searchStats, err := NewSearchStats(db)
+	if err != nil {
+		fatal("Failed to set up search stats", err)
+	}
+	posters := crossPostersFromConfig(cfg.CrossPost, &http.Client{Timeout: 30 * time.Second})
+	queue.Register("crosspost", CrossPostJob(crossposts, posts, posters, strings.TrimSuffix(cfg.SiteURL, "/")))
+	if len(posters) > 0 {
+		if cfg.SiteURL == "" {
+			slog.Warn("Cross-posting needs site_url in config; not announcing posts")
+		} else {
+			go RunCrossPostScanner(ctx, 5*time.Minute, crossposts, queue, posts, posters, cfg.CrossPost.MaxAge)
+		}
+	}
+	queue.Register("preview-check", PreviewCheckJob(previewChecks, posts, &http.Client{Timeout: 30 * time.Second}, strings.TrimSuffix(cfg.SiteURL, "/")))
+	if cfg.PreviewCheck.Enabled {
+		if cfg.SiteURL == "" {
+			slog.Warn("Checking link previews needs site_url in config; not checking new posts")
+		} else {
+			go RunPreviewCheckScanner(ctx, 5*time.Minute, previewChecks, queue, posts, cfg.PreviewCheck.MaxAge)
+		}
+	}
+	go RunRepoRefresher(ctx, repoCards, cfg.Repos.Refresh)
+	emails := NewEmailRenderer("templates/email")
+
+	heuristics, err := NewHeuristicSpamChecker(cfg.Comments.Spam, comments)
+	if err != nil {
+		fatal("Invalid spam config", err)
+	}
+	spam := NewSpamFilter(heuristics)
+	if cfg.Comments.Spam.AkismetEndpoint != "" {
+		spam.Checkers = append(spam.Checkers, &AkismetSpamChecker{
+			Endpoint: cfg.Comments.Spam.AkismetEndpoint,
+			APIKey:   os.Getenv("AKISMET_API_KEY"),
+			Blog:     cfg.Comments.Spam.SiteURL,
+			Client:   &http.Client{Timeout: 5 * time.Second},
+		})
+	}
+	var commentLimiter *RateLimiter
+	if cfg.Comments.RateLimit > 0 {
+		commentLimiter = NewRateLimiter(cfg.Comments.RateLimit, time.Hour)
+	}
+
+	auth := NewAdminAuth(os.Getenv("ADMIN_USER"), os.Getenv("ADMIN_PASSWORD"), os.Getenv("SESSION_SECRET"))
+
+	var claps *ClapStore
+	if cfg.Claps.Enabled {
+		claps, err = NewClapStore(db, auth.secret)
+		if err != nil {
+			fatal("Failed to set up clap store", err)
+		}
 	}
 
 	mux := http.NewServeMux()
 
 	// Serve static files (CSS, JS)
-	mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
+	mux.HandleFunc("GET "+highlightCSSPath, HighlightCSSHandler(cfg.Markdown.Highlight))
+	mux.Handle("GET /static/", http.StripPrefix("/static/", StaticFiles("static")))
 
-	// Serve images
-	mux.Handle("GET /images/", http.StripPrefix("/images/", http.FileServer(http.Dir("images"))))
+	// Serve images, resized on demand with ?w=
+	mux.HandleFunc("GET /images/{path...}", ImageHandler("images", cfg.ImageCacheDir, cfg.StripImageMetadata))
+
+	// Serve post narrations
+	mux.HandleFunc("GET /audio/{file}", AudioHandler(posts, "audio"))
+
+	// Post attachments, served as downloads
+	mux.HandleFunc("GET /attachments/{path...}", AttachmentHandler(attachmentsDir))
 
 	// Homepage - list all posts
-	mux.HandleFunc("GET /", HomeHandler)
+	mux.HandleFunc("GET /{$}", Conditional(metrics.Instrument("home", HomeHandler(posts, comments))))
+
+	// Every other path is a 404
+	mux.HandleFunc("GET /", NotFoundHandler(posts))
 
 	// Contact page
-	mux.HandleFunc("GET /contact", ContactHandler)
+	pages := &FileReader{Dir: pagesDir}
+	mux.HandleFunc("GET /contact", ContactHandler(pages))
+	mux.HandleFunc("POST /contact", ContactSubmitHandler(contacts, queue, emails, os.Getenv("CONTACT_EMAIL")))
+
+	// Server status
+	mux.HandleFunc("GET /status", StatusHandler(posts))
+	mux.HandleFunc("GET /status.json", StatusHandler(posts))
+	mux.HandleFunc("GET /readyz", ReadyzHandler(health))
 
 	// Individual post
-	mux.HandleFunc("GET /posts/{slug}", PostHandler(&FileReader{}))
+	mux.HandleFunc("GET /posts/{slug}", postFormats(Conditional(metrics.Instrument("post", PostHandler(posts, comments, claps))), map[string]http.HandlerFunc{
+		".json": PostJSONHandler(posts),
+		".md":   PostSourceHandler(posts),
+	}))
+	mux.HandleFunc("GET /pages/{name}", PageHandler(pages))
+	mux.HandleFunc("GET /oembed", OEmbedHandler(posts))
+	mux.HandleFunc("GET /api/graph", GraphHandler(posts))
+	mux.HandleFunc("GET /projects", ProjectsHandler(projectsFile))
+	mux.HandleFunc("GET /talks", TalksHandler(talksFile))
+	mux.HandleFunc("GET /talks.ics", TalksCalendarHandler(talksFile))
+	mux.HandleFunc("GET /cv", CVHandler(cvFile))
+	mux.HandleFunc("GET /cv.pdf", CVPDFHandler(cvFile))
+	for _, s := range sections {
+		s.handle(mux)
+	}
+	notes := &FileReader{Dir: notesDir}
+	if cfg.Micropub.TokenEndpoint != "" {
+		micropub := MicropubHandler(&IndieAuthVerifier{Endpoint: cfg.Micropub.TokenEndpoint, Me: cfg.Micropub.Me, Client: &http.Client{Timeout: 10 * time.Second}}, posts, notes)
+		mux.HandleFunc("GET /micropub", micropub)
+		mux.HandleFunc("POST /micropub", micropub)
+	}
+	if cfg.Pingback.Enabled {
+		client, limiter := newMentionClient(10*time.Second), NewRateLimiter(10, time.Hour)
+		mux.HandleFunc("POST /xmlrpc", PingbackHandler(mentions, posts, client, limiter))
+		mux.HandleFunc("POST /posts/{slug}/trackback", TrackbackHandler(mentions, posts, client, limiter))
+	}
+	thaiDict, err := loadThaiDictionary(cfg.Search.ThaiDictionary)
+	if err != nil {
+		slog.Warn("No Thai dictionary for search, Thai text is matched as whole phrases", "err", err)
+		thaiDict = NewThaiDictionary(nil)
+	}
+	thaiWords = thaiDict
+	searcher := &Searcher{Posts: posts, Dict: thaiDict}
+	// Build the index in the background so the first search doesn't wait for it
+	go func() {
+		if _, err := searcher.Index(); err != nil {
+			slog.Error("Error building search index", "err", err)
+		}
+	}()
+	mux.HandleFunc("GET /search", SearchHandler(searcher, searchStats))
+	sitemaps := &Sitemaps{
+		Posts:     posts,
+		Sections:  sectionFiles(),
+		DataFiles: map[string]string{"/projects": projectsFile, "/talks": talksFile, "/cv": cvFile},
+	}
+	mux.HandleFunc("GET /robots.txt", RobotsTxtHandler)
+	mux.HandleFunc("GET /sitemap.xml", sitemaps.IndexHandler)
+	mux.HandleFunc("GET /sitemaps/{file}", sitemaps.SectionHandler)
+	mux.HandleFunc("GET /tags/{name}", TagHandler(posts))
+	mux.HandleFunc("GET /categories/{name}", CategoryHandler(posts))
+	// Post feeds
+	mux.HandleFunc("GET /feed.xml", Conditional(AtomFeedHandler(posts)))
+	mux.HandleFunc("GET /rss.xml", Conditional(RSSFeedHandler(posts)))
+	saved := NewSavedPosts(auth.secret)
+	mux.HandleFunc("GET /saved", SavedHandler(saved, posts))
+	mux.HandleFunc("POST /saved", SaveHandler(saved, posts))
+	mux.HandleFunc("GET /posts/{slug}/code.zip", CodeZipHandler(posts))
+	mux.HandleFunc("GET /posts/{slug}/changes", Conditional(PostChangesHandler(posts)))
+	mux.HandleFunc("GET /posts/{slug}/comments/feed.xml", Conditional(CommentsFeedHandler(comments, posts)))
+	mux.HandleFunc("GET /comments/feed.xml", Conditional(CommentsFeedHandler(comments, posts)))
+	if claps != nil {
+		var clapLimiter *RateLimiter
+		if cfg.Claps.RateLimit > 0 {
+			clapLimiter = NewRateLimiter(cfg.Claps.RateLimit, time.Hour)
+		}
+		mux.HandleFunc("POST /posts/{slug}/clap", ClapHandler(claps, posts, clapLimiter))
+	}
+	mux.HandleFunc("POST /posts/{slug}/comments", CommentSubmitHandler(comments, posts, queue, emails, os.Getenv("CONTACT_EMAIL"), spam, commentLimiter, auth))
+	mux.HandleFunc("POST /posts/{slug}/suggest", SuggestEditHandler(contacts, posts, spam, NewRateLimiter(5, time.Hour)))
+	if cfg.ReadLater.Enabled {
+		if !mailer.Enabled() {
+			slog.Warn("read_later is enabled but SMTP_HOST and SMTP_FROM are not set; emails will fail")
+		}
+		mux.HandleFunc("POST /posts/{slug}/email", ReadLaterHandler(posts, queue, emails, NewRateLimiter(5, time.Hour), NewRateLimiter(3, 24*time.Hour)))
+	}
+
+	// Admin area
+	mux.HandleFunc("GET /admin/login", auth.LoginHandler)
+	mux.HandleFunc("POST /admin/login", auth.LoginHandler)
+	mux.HandleFunc("POST /admin/logout", auth.LogoutHandler)
+	mux.HandleFunc("GET /admin", auth.Require(AdminHandler))
+	mux.HandleFunc("GET /admin/posts", auth.Require(AdminPostsHandler(posts)))
+	mux.HandleFunc("GET /admin/posts/new", auth.Require(AdminPostEditHandler(posts, drafts)))
+	mux.HandleFunc("GET /admin/posts/{slug}/edit", auth.Require(AdminPostEditHandler(posts, drafts)))
+	mux.HandleFunc("POST /admin/posts", auth.Require(AdminPostSaveHandler(posts, drafts)))
+	mux.HandleFunc("POST /admin/posts/{slug}", auth.Require(AdminPostSaveHandler(posts, drafts)))
+	mux.HandleFunc("POST /admin/drafts", auth.Require(AdminDraftSaveHandler(drafts)))
+	mux.HandleFunc("POST /admin/drafts/{slug}", auth.Require(AdminDraftSaveHandler(drafts)))
+	mux.HandleFunc("POST /admin/posts/{slug}/duplicate", auth.Require(AdminPostDuplicateHandler(posts)))
+	mux.HandleFunc("POST /admin/posts/{slug}/delete", auth.Require(AdminPostDeleteHandler(posts, drafts)))
+	mux.HandleFunc("POST /admin/preview", auth.Require(AdminPreviewHandler))
+	mux.HandleFunc("GET /admin/images", auth.Require(AdminImagesHandler("images")))
+	mux.HandleFunc("POST /admin/images", auth.Require(AdminImageUploadHandler("images", queue)))
+	mux.HandleFunc("GET /admin/inbox", auth.Require(AdminInboxHandler(contacts)))
+	mux.HandleFunc("GET /admin/inbox/export.csv", auth.Require(AdminInboxExportHandler(contacts)))
+	mux.HandleFunc("POST /admin/inbox/{id}/{action}", auth.Require(AdminInboxActionHandler(contacts)))
+	mux.HandleFunc("GET /admin/comments", auth.Require(AdminCommentsHandler(comments)))
+	mux.HandleFunc("POST /admin/comments/{id}/{action}", auth.Require(AdminCommentActionHandler(comments)))
+	mux.HandleFunc("GET /admin/crossposts", auth.Require(AdminCrossPostsHandler(crossposts)))
+	mux.HandleFunc("POST /admin/crossposts/{slug}/{platform}/retry", auth.Require(AdminCrossPostRetryHandler(crossposts, queue)))
+	mux.HandleFunc("GET /admin/previews", auth.Require(AdminPreviewsHandler(previewChecks)))
+	mux.HandleFunc("POST /admin/previews/{slug}/recheck", auth.Require(AdminPreviewRecheckHandler(previewChecks, queue)))
+	mux.HandleFunc("GET /admin/mentions", auth.Require(AdminMentionsHandler(mentions)))
+	mux.HandleFunc("POST /admin/mentions/{id}/delete", auth.Require(AdminMentionDeleteHandler(mentions)))
+	mux.HandleFunc("GET /admin/stats", auth.Require(AdminStatsHandler(searchStats, claps)))
+	mux.HandleFunc("GET /admin/jobs", auth.Require(AdminJobsHandler(queue)))
+	mux.HandleFunc("POST /admin/jobs/{id}/{action}", auth.Require(AdminJobActionHandler(queue)))
+
+	if cfg.Metrics.Enabled {
+		mux.HandleFunc("GET /metrics", metrics.Handler(os.Getenv("METRICS_TOKEN")))
+	}
+	if cfg.LLMsTxt.Enabled {
+		mux.HandleFunc("GET /llms.txt", LLMsTxtHandler(posts))
+	}
+	if cfg.Corpus.Enabled {
+		mux.HandleFunc("GET /export/corpus.jsonl", CorpusHandler(posts, os.Getenv("CORPUS_TOKEN")))
+	}
+
+	// Debug endpoints (profiling) are only mounted when enabled by config
+	if cfg.Debug {
+		mux.HandleFunc("GET /debug/pprof/", pprof.Index)
+		mux.HandleFunc("GET /debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("GET /debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("GET /debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("GET /debug/pprof/trace", pprof.Trace)
+		mux.HandleFunc("GET /debug/cache", CacheStatsHandler)
+	}
+
+	handler := ForwardedPrefix(LanguagePaths(posts, DisplayPreferences(metrics.Routes(mux))))
+	if cfg.Compression.Enabled {
+		handler = Compress(handler)
+	}
+	if cfg.SlowRequestThreshold > 0 {
+		handler = SlowRequests(cfg.SlowRequestThreshold, handler)
+	}
+	if cfg.AccessLog {
+		handler = AccessLog(handler)
+	}
+	if devMode {
+		lr := NewLiveReload()
+		dirs := []string{"templates", "static"}
+		if _, local := store.(storeDir); local {
+			dirs = append(dirs, posts.dir)
+		}
+		if err := WatchDev(ctx, lr, posts, dirs...); err != nil {
+			fatal("Failed to watch files for live reload", err)
+		}
+		handler = DevHandler(lr, handler)
+		slog.Info("Dev mode: pages reload when files change", "dirs", dirs)
+	}
 
 	// Configure server with timeouts for production
 	server := &http.Server{
 		Addr:         ":" + port,
-		Handler:      mux,
+		Handler:      handler,
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
@@ -95,18 +615,19 @@ func main() {
 		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 		<-sigChan
 
-		log.Println("Shutting down gracefully...")
+		slog.Info("Shutting down gracefully")
+		stopWorkers()
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 
 		if err := server.Shutdown(ctx); err != nil {
-			log.Printf("Error during shutdown: %v", err)
+			slog.Error("Error during shutdown", "err", err)
 		}
 	}()
 
-	log.Printf("Blog running at http://localhost:%s", port)
+	slog.Info("Blog running", "url", "http://localhost:"+port, "env", cfg.Env)
 	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		log.Fatal(err)
+		fatal("Server failed", err)
 	}
 }
 
@@ -114,14 +635,29 @@ type SlugReader interface {
 	Read(slug string) (string, error)
 }
 
-type FileReader struct{}
+// FileReader reads markdown files from a content directory
+type FileReader struct {
+	// Dir is the directory holding the files; empty means posts
+	Dir string
+}
+
+func (fr *FileReader) dir() string {
+	if fr.Dir == "" {
+		return "posts"
+	}
+	return fr.Dir
+}
 
 func (fr *FileReader) Read(slug string) (string, error) {
-	f, err := os.Open(filepath.Join("posts", slug+".md"))
+	path := filepath.Join(fr.dir(), slug+".md")
+	f, err := os.Open(path)
 	if err != nil {
 		return "", err
 	}
 	defer f.Close()
+	if info, err := f.Stat(); err == nil && postTooLarge(info.Size()) {
+		return "", tooLargeError(path, info.Size())
+	}
 	b, err := io.ReadAll(f)
 	if err != nil {
 		return "", err
@@ -129,6 +665,104 @@ func (fr *FileReader) Read(slug string) (string, error) {
 	return string(b), nil
 }
 
+// errPostTooLarge is returned for markdown files over max_post_kb, which are
+// never read
+var errPostTooLarge = errors.New("file is over max_post_kb")
+
+// postTooLarge reports whether a markdown file of size bytes is over the cap
+func postTooLarge(size int64) bool {
+	return cfg.MaxPostKB > 0 && size > int64(cfg.MaxPostKB)<<10
+}
+
+// tooLargeError describes the oversized file at path
+func tooLargeError(path string, size int64) error {
+	return &fs.PathError{Op: "read", Path: path, Err: fmt.Errorf("%w: %d KB, the limit is %d KB", errPostTooLarge, size>>10, cfg.MaxPostKB)}
+}
+
+// SlugWriter stores and removes post markdown by slug
+type SlugWriter interface {
+	Write(slug, content string) error
+	Delete(slug string) error
+}
+
+// SlugLister lists the slugs of all posts
+type SlugLister interface {
+	List() ([]string, error)
+}
+
+// Write saves a post atomically so readers never see a half-written file.
+// Private posts are encrypted first when a key is set; Read returns them as
+// stored and PostIndex decrypts them.
+func (fr *FileReader) Write(slug, content string) error {
+	content, err := sealPost(content)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(fr.dir(), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(fr.dir(), ".edit-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(fr.dir(), slug+".md"))
+}
+
+// Delete removes a post's file
+func (fr *FileReader) Delete(slug string) error {
+	return os.Remove(filepath.Join(fr.dir(), slug+".md"))
+}
+
+// ModTime returns when a file was last modified
+func (fr *FileReader) ModTime(slug string) (time.Time, bool) {
+	info, err := os.Stat(filepath.Join(fr.dir(), slug+".md"))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return info.ModTime(), true
+}
+
+// List returns the slugs of all files, sorted
+func (fr *FileReader) List() ([]string, error) {
+	files, err := os.ReadDir(fr.dir())
+	if err != nil {
+		return nil, err
+	}
+	var slugs []string
+	for _, f := range files {
+		if slug, ok := strings.CutSuffix(f.Name(), ".md"); ok && !f.IsDir() {
+			slugs = append(slugs, slug)
+		}
+	}
+	return slugs, nil
+}
+
+// Stat returns the size and modification time of a post's file
+func (fr *FileReader) Stat(slug string) (PostInfo, error) {
+	info, err := os.Stat(filepath.Join(fr.dir(), slug+".md"))
+	if err != nil {
+		return PostInfo{}, err
+	}
+	return PostInfo{Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// LocalDir returns the directory the files are in, for watching
+func (fr *FileReader) LocalDir() string {
+	return fr.dir()
+}
+
 // ParseFrontmatter extracts YAML frontmatter from markdown content
 func ParseFrontmatter(content string) (PostFrontmatter, string) {
 	var fm PostFrontmatter
@@ -146,7 +780,7 @@ func ParseFrontmatter(content string) (PostFrontmatter, string) {
 
 	// Parse YAML
 	if err := yaml.Unmarshal([]byte(parts[0]), &fm); err != nil {
-		log.Printf("Warning: Failed to parse frontmatter: %v", err)
+		slog.Warn("Failed to parse frontmatter", "err", err)
 	}
 
 	// Return the content after frontmatter
@@ -159,12 +793,22 @@ func setSecurityHeaders(w http.ResponseWriter) {
 	w.Header().Set("X-Frame-Options", "DENY")
 	w.Header().Set("X-XSS-Protection", "1; mode=block")
 	w.Header().Set("Referrer-Policy", "strict-origin-when-cross-origin")
+	if cfg.NoIndex {
+		w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+	}
 }
 
-// toTitleCase converts a string to title case
+// toTitleCase converts a string to title case. Thai has no letter case, so
+// words containing Thai are left as they are.
 func toTitleCase(s string) string {
 	caser := cases.Title(language.English)
-	return caser.String(s)
+	words := strings.Split(s, " ")
+	for i, word := range words {
+		if !strings.ContainsFunc(word, isThai) {
+			words[i] = caser.String(word)
+		}
+	}
+	return strings.Join(words, " ")
 }
 
 // IsValidSlug checks if a slug contains only valid characters
@@ -172,193 +816,116 @@ func IsValidSlug(slug string) bool {
 	return validSlugRegex.MatchString(slug)
 }
 
-// ContactHandler displays the contact/about page
-func ContactHandler(w http.ResponseWriter, r *http.Request) {
-	setSecurityHeaders(w)
-
-	// Get language from query param or cookie, default to "th"
-	lang := r.URL.Query().Get("lang")
-	if lang == "" {
-		if cookie, err := r.Cookie("lang"); err == nil {
-			lang = cookie.Value
-		}
-	}
-	if lang != "en" && lang != "th" {
-		lang = "th"
-	}
-
-	var content bytes.Buffer
-
-	if lang == "th" {
-		content.WriteString(`<div class="contact-page">
-	<h1>Contact &amp; About Me</h1>
-	
-	<section class="about-section">
-		<h2>About Me</h2>
-		<p>ธีรภัทร ยาใจ</p>
-		<p>website นี้จัดทำขึ้นเพื่อการศึกษาและแบ่งปันความรู้เท่านั้น หากมีข้อผิดพลาดหรือต้องการให้เพิ่มเติมอะไร สามารถติดต่อตามที่ติดต่อข้างล่างได้เลย ขอบคุณที่เข้ามาอ่านกันนะครับ 🥰</p>
-	</section>
-
-	<section class="contact-section">
-		<h2>Contact Me</h2>
-		<ul class="contact-list">
-			<li>Email: <a href="mailto:teerapat.yj@gmail.com">teerapat.yj@gmail.com</a></li>
-			<li>GitHub: <a href="https://github.com/kenn-teera" target="_blank" rel="noopener noreferrer">github.com/kenn-teera</a></li>
-			<li>LinkedIn: <a href="https://linkedin.com/in/teerapat-yajai/" target="_blank" rel="noopener noreferrer">linkedin.com/in/teerapat-yajai</a></li>
-		</ul>
-	</section>
-</div>`)
-	} else {
-		content.WriteString(`<div class="contact-page">
-	<h1>Contact &amp; About Me</h1>
-	
-	<section class="about-section">
-		<h2>About Me</h2>
-		<p>Teerapat Yajai</p>
-		<p>This website is built for learning and sharing knowledge. If there are any errors or you want to add more, you can contact me through the contact information below. Thank you for reading! 🥰</p>
-	</section>
-
-	<section class="contact-section">
-		<h2>Contact Me</h2>
-		<ul class="contact-list">
-			<li>Email: <a href="mailto:teerapat.yj@gmail.com">teerapat.yj@gmail.com</a></li>
-			<li>GitHub: <a href="https://github.com/kenn-teera" target="_blank" rel="noopener noreferrer">github.com/kenn-teera</a></li>
-			<li>LinkedIn: <a href="https://linkedin.com/in/teerapat-yajai" target="_blank" rel="noopener noreferrer">linkedin.com/in/teerapat-yajai</a></li>
-		</ul>
-	</section>
-</div>`)
-	}
-
-	renderPage(w, "Contact", template.HTML(content.String()))
-}
-
-// HomeHandler lists all blog posts
-func HomeHandler(w http.ResponseWriter, r *http.Request) {
-	setSecurityHeaders(w)
-
-	// Get language from query param or cookie, default to "th"
-	lang := r.URL.Query().Get("lang")
-	if lang == "" {
-		if cookie, err := r.Cookie("lang"); err == nil {
-			lang = cookie.Value
-		}
-	}
-	if lang != "en" && lang != "th" {
-		lang = "th"
-	}
-
-	// Set language cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "lang",
-		Value:    lang,
-		Path:     "/",
-		MaxAge:   31536000, // 1 year
-		HttpOnly: false,
-		SameSite: http.SameSiteLaxMode,
-	})
+// HomeHandler lists all blog posts, with comment counts when a store is given
+func HomeHandler(posts *PostIndex, comments *CommentStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setSecurityHeaders(w)
 
-	files, err := os.ReadDir("posts")
-	if err != nil {
-		log.Printf("Error reading posts directory: %v", err)
-		http.Error(w, "Could not read posts", http.StatusInternalServerError)
-		return
-	}
+		lang := requestLang(r)
 
-	var posts []Post
-	for _, f := range files {
-		if strings.HasSuffix(f.Name(), ".md") {
-			slug := strings.TrimSuffix(f.Name(), ".md")
-
-			// Filter by language prefix (th- or en-)
-			// Posts without prefix are shown in all languages
-			hasLangPrefix := strings.HasPrefix(slug, "th-") || strings.HasPrefix(slug, "en-")
-			if hasLangPrefix {
-				expectedPrefix := lang + "-"
-				if !strings.HasPrefix(slug, expectedPrefix) {
-					continue // Skip posts for other languages
-				}
-			}
+		// Remember the language for the redirect from /
+		setPrefCookie(w, r, prefLang, lang)
 
-			// Read the post to get frontmatter
-			content, err := os.ReadFile(filepath.Join("posts", f.Name()))
-			if err != nil {
-				log.Printf("Error reading post %s: %v", f.Name(), err)
-				continue
-			}
-
-			fm, _ := ParseFrontmatter(string(content))
-
-			post := Post{
-				Slug: slug,
+		// Posts in no particular language are shown in both
+		all := posts.Posts()
+		listed := make([]Post, 0, len(all))
+		for _, post := range all {
+			if postInLang(post, lang) {
+				listed = append(listed, post)
 			}
+		}
 
-			// Use frontmatter title or generate from slug
-			if fm.Title != "" {
-				post.Title = fm.Title
-			} else {
-				// Remove language prefix for display
-				displaySlug := slug
-				if strings.HasPrefix(slug, "th-") || strings.HasPrefix(slug, "en-") {
-					displaySlug = slug[3:]
-				}
-				post.Title = toTitleCase(strings.ReplaceAll(displaySlug, "-", " "))
-			}
+		// Translated content based on language
+		welcomeText := cfg.Site.Intro.In(lang)
+		var welcomeTitle, postsHeading string
+		if lang == "th" {
+			welcomeTitle = "ยินดีต้อนรับสู่ " + cfg.Site.Name
+			postsHeading = "บทความ"
+		} else {
+			welcomeTitle = "Welcome to " + cfg.Site.Name
+			postsHeading = "Posts"
+		}
 
-			// Parse date from frontmatter or use file modification time
-			if fm.Date != "" {
-				if t, err := time.Parse("2006-01-02", fm.Date); err == nil {
-					post.Date = t
-					post.DateStr = t.Format("Jan 2, 2006")
-				}
-			}
-			if post.DateStr == "" {
-				info, err := f.Info()
-				if err == nil && info != nil {
-					post.Date = info.ModTime()
-					post.DateStr = info.ModTime().Format("Jan 2, 2006")
-				}
+		var counts map[string]int
+		if comments != nil {
+			done := startPhase(r, PhaseStore)
+			var err error
+			if counts, err = comments.ApprovedCounts(); err != nil {
+				slog.Error("Error counting comments", "err", err)
 			}
+			done()
+		}
 
-			posts = append(posts, post)
+		// Build post list HTML
+		content := getBuffer()
+		defer putBuffer(content)
+		content.WriteString("<h1>" + template.HTMLEscapeString(welcomeTitle) + "</h1>\n")
+		if welcomeText != "" {
+			content.WriteString("<p class=\"about-me\">" + template.HTMLEscapeString(welcomeText) + "</p>\n")
+		}
+		content.WriteString("<h2 class=\"posts-heading\">" + template.HTMLEscapeString(postsHeading) + "</h2>\n")
+		pages := NewPagination(r, cfg.PostsPerPage, len(listed))
+		if pages.Page > 1 {
+			markNoIndex(w, PagePagination)
 		}
+		start, end := pages.Bounds()
+		content.WriteString("<ul class=\"post-list\">\n")
+		for _, post := range listed[start:end] {
+			content.WriteString(postListItemHTML(post, counts[post.Slug], lang))
+		}
+		content.WriteString("</ul>\n")
+		content.WriteString(pages.NavHTML())
+
+		defer startPhase(r, PhaseTemplate)()
+		renderPageData(w, PageData{
+			Title:   "Home",
+			Content: template.HTML(content.String()),
+			Head:    pages.HeadLinks(siteURL(r)) + feedHeadLinks() + micropubHeadLinks(),
+		})
 	}
+}
 
-	// Sort posts by date (newest first)
-	sort.Slice(posts, func(i, j int) bool {
-		return posts[i].Date.After(posts[j].Date)
-	})
-
-	// Translated content based on language
-	var welcomeTitle, welcomeText, postsHeading string
+// readingTimeHTML renders a post's word count and reading time in lang, shown
+// next to its date
+func readingTimeHTML(words, minutes int, lang string) string {
+	if words == 0 {
+		return ""
+	}
+	unit := " words"
+	if words == 1 {
+		unit = " word"
+	}
+	label := strconv.Itoa(words) + unit + " · " + strconv.Itoa(minutes) + " min read"
 	if lang == "th" {
-		welcomeTitle = "ยินดีต้อนรับสู่ LearnArai"
-		welcomeText = "สวัสดีครับ!! ผมคือคนที่ชอบสร้างสรรค์และเรียนรู้สิ่งต่างๆ นี่คือพื้นที่ส่วนตัวของผมซึ่งเอาไว้สำหรับแชร์ความคิด สิ่งที่ได้เรียนรู้ หรือโปรเจกต์ที่กำลังทำอยู่"
-		postsHeading = "บทความ"
-	} else {
-		welcomeTitle = "Welcome to LearnArai"
-		welcomeText = "Hi!! I'm someone who likes to create and learn new things. This is my personal space where I can share ideas or projects I'm currently working on."
-		postsHeading = "Posts"
+		label = strconv.Itoa(words) + " คำ · อ่าน " + strconv.Itoa(minutes) + " นาที"
 	}
+	return "<span class=\"reading-time\">" + label + "</span>"
+}
 
-	// Build post list HTML
-	var content bytes.Buffer
-	content.WriteString("<h1>" + template.HTMLEscapeString(welcomeTitle) + "</h1>\n")
-	content.WriteString("<p class=\"about-me\">" + template.HTMLEscapeString(welcomeText) + "</p>\n")
-	content.WriteString("<h2 class=\"posts-heading\">" + template.HTMLEscapeString(postsHeading) + "</h2>\n")
-	content.WriteString("<ul class=\"post-list\">\n")
-	for _, post := range posts {
-		content.WriteString("<li>")
-		content.WriteString("<a href=\"/posts/" + template.HTMLEscapeString(post.Slug) + "\">" + template.HTMLEscapeString(post.Title) + "</a>")
-		content.WriteString("<span class=\"post-date\">" + template.HTMLEscapeString(post.DateStr) + "</span>")
-		content.WriteString("</li>\n")
+// postListItemHTML renders a post as an item of a post list in lang, with its
+// category, tags, and reading time and, when there are any, its comment count
+func postListItemHTML(post Post, comments int, lang string) string {
+	var b strings.Builder
+	if post.Link != "" {
+		b.WriteString("<li class=\"link-post\">")
+		b.WriteString(linkPostTitleHTML(post.Title, post.Link))
+		b.WriteString("<a class=\"link-post-permalink\" href=\"/posts/" + template.HTMLEscapeString(post.Slug) + "\" title=\"Commentary\">∞</a>")
+	} else {
+		b.WriteString("<li>")
+		b.WriteString("<a href=\"/posts/" + template.HTMLEscapeString(post.Slug) + "\">" + template.HTMLEscapeString(post.Title) + "</a>")
 	}
-	content.WriteString("</ul>\n")
-
-	renderPage(w, "Home", template.HTML(content.String()))
+	b.WriteString(postTagsHTML(post.Category, post.Tags))
+	b.WriteString("<span class=\"post-date\">" + template.HTMLEscapeString(post.DateStr) + "</span>")
+	b.WriteString(readingTimeHTML(post.WordCount, post.ReadingTime, lang))
+	if comments > 0 {
+		b.WriteString("<a class=\"comment-count\" href=\"/posts/" + template.HTMLEscapeString(post.Slug) + "#comments\">💬 " + strconv.Itoa(comments) + "</a>")
+	}
+	b.WriteString("</li>\n")
+	return b.String()
 }
 
-// PostHandler handles individual blog posts
-func PostHandler(sl SlugReader) http.HandlerFunc {
+// PostHandler handles individual blog posts. Comments are shown when a store is given.
+func PostHandler(sl SlugReader, comments *CommentStore, claps *ClapStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		setSecurityHeaders(w)
 
@@ -366,24 +933,60 @@ func PostHandler(sl SlugReader) http.HandlerFunc {
 
 		// Validate slug to prevent path traversal
 		if !IsValidSlug(slug) {
-			http.Error(w, "Invalid post slug", http.StatusBadRequest)
+			renderError(w, http.StatusBadRequest, requestLang(r))
 			return
 		}
 
+		done := startPhase(r, PhaseStore)
 		postMarkdown, err := sl.Read(slug)
+		done()
+		if errors.Is(err, errPostTooLarge) {
+			slog.Error("Error reading post", "slug", slug, "err", err)
+			renderError(w, http.StatusInternalServerError, requestLang(r))
+			return
+		}
+		if errors.Is(err, errNoPostKey) {
+			// Only private posts are sealed, so this stays a 404 to readers
+			slog.Error("Error reading post", "slug", slug, "err", err)
+		}
 		if err != nil {
-			http.Error(w, "Post not found", http.StatusNotFound)
+			// A renamed post is found at its old slug
+			if rf, ok := sl.(redirectFinder); ok && redirectMoved(w, r, rf) {
+				return
+			}
+			renderError(w, http.StatusNotFound, requestLang(r))
 			return
 		}
 
 		// Parse frontmatter and get content
 		fm, markdownContent := ParseFrontmatter(postMarkdown)
+		preview := !postVisible(fm)
+		if preview && !previewAllowed(r) {
+			renderError(w, http.StatusNotFound, requestLang(r))
+			return
+		}
+		if preview {
+			// Previews must not be indexed or kept by shared caches
+			w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+			w.Header().Set("Cache-Control", "private, no-store")
+		} else {
+			// The save button shows the reader's own list, so shared caches
+			// must not hand one reader's page to another
+			w.Header().Set("Cache-Control", "private, no-cache")
+		}
+		addVary(w.Header(), "Cookie")
+
+		if cfg.Pingback.Enabled {
+			w.Header().Set("X-Pingback", siteURL(r)+"/xmlrpc")
+		}
 
 		// Convert markdown to HTML
-		var buf bytes.Buffer
-		if err := goldmark.Convert([]byte(markdownContent), &buf); err != nil {
-			log.Printf("Error rendering post %s: %v", slug, err)
-			http.Error(w, "Error rendering post", http.StatusInternalServerError)
+		done = startPhase(r, PhaseMarkdown)
+		html, err := renderMarkdownCached(markdownContent, slug)
+		done()
+		if err != nil {
+			slog.Error("Error rendering post", "slug", slug, "err", err)
+			renderError(w, http.StatusInternalServerError, requestLang(r))
 			return
 		}
 
@@ -394,33 +997,193 @@ func PostHandler(sl SlugReader) http.HandlerFunc {
 		}
 
 		// Build post HTML with date
-		var postHTML bytes.Buffer
-		postHTML.WriteString("<article>\n")
+		postHTML := getBuffer()
+		defer putBuffer(postHTML)
+		// Screen readers and search engines read the post in its own language,
+		// whatever the language of the page around it
+		postHTML.WriteString("<article lang=\"" + contentLang(postFileLang(slug, fm), plainText(html)) + "\">\n")
+		if preview {
+			postHTML.WriteString("<p class=\"preview-banner\">Preview: this post isn't published yet.</p>\n")
+		}
 		postHTML.WriteString("<div class=\"post-header\">\n")
-		postHTML.WriteString("<h1>" + template.HTMLEscapeString(title) + "</h1>\n")
+		if link := linkPostURL(fm.Link); link != "" {
+			postHTML.WriteString("<h1 class=\"link-post\">" + linkPostTitleHTML(title, link) + "</h1>\n")
+		} else {
+			postHTML.WriteString("<h1>" + template.HTMLEscapeString(title) + "</h1>\n")
+		}
 		if fm.Date != "" {
-			if t, err := time.Parse("2006-01-02", fm.Date); err == nil {
+			if t, ok := parsePostDate(fm.Date); ok {
 				postHTML.WriteString("<span class=\"post-meta\">" + t.Format("Jan 2, 2006") + "</span>\n")
 			}
 		}
+		if !preview {
+			postHTML.WriteString(postUpdatedHTML(slug, fm, requestLang(r)))
+		}
+		words := wordCount(markdownText(markdownContent), postFileLang(slug, fm))
+		postHTML.WriteString(readingTimeHTML(words, readingMinutes(words), requestLang(r)))
+		postHTML.WriteString(savePostHTML(slug, savedListed(r, slug)))
+		if !preview {
+			postHTML.WriteString(translationLinksHTML(siteRoot(r), postTranslations(sl, slug)))
+		}
 		postHTML.WriteString("</div>\n")
-		postHTML.WriteString(buf.String())
-		postHTML.WriteString("</article>")
+		original := linkPostURL(fm.CanonicalURL)
+		if original != "" {
+			postHTML.WriteString(originallyPublishedHTML(original, requestLang(r)))
+		}
+		if src := audioSrc(fm.Audio, slug, "audio"); src != "" {
+			postHTML.WriteString(audioPlayerHTML(src))
+		}
+		body, headings := addHeadingAnchors(html)
+		if fm.TOC {
+			postHTML.WriteString(tocHTML(headings))
+		}
+		postHTML.WriteString(body)
+		postHTML.WriteString(attachmentsHTML(postAttachments(fm.Attachments, attachmentsDir)))
+		if cfg.MarkdownSource.Enabled {
+			postHTML.WriteString("<p class=\"post-source\"><a href=\"/posts/" + template.HTMLEscapeString(slug) + ".md\">View source</a></p>\n")
+		}
+		if idx, ok := sl.(backlinkFinder); ok && !preview {
+			postHTML.WriteString(backlinksHTML(idx.Backlinks(slug)))
+		}
+		postHTML.WriteString("</article>\n")
+		if claps != nil && !preview {
+			done = startPhase(r, PhaseStore)
+			total, yours, err := claps.Count(slug, r)
+			done()
+			if err != nil {
+				slog.Error("Error counting claps", "slug", slug, "err", err)
+			}
+			postHTML.WriteString(clapsHTML(slug, total, yours))
+		}
+		if cfg.ReadLater.Enabled && !preview {
+			postHTML.WriteString(readLaterHTML(slug, r.URL.Query().Get("emailed") != ""))
+		}
+		postHTML.WriteString(suggestEditHTML(slug, r.URL.Query().Get("suggested") != ""))
+
+		if comments != nil {
+			done = startPhase(r, PhaseStore)
+			approved, err := comments.Approved(slug)
+			done()
+			if err != nil {
+				slog.Error("Error loading comments", "slug", slug, "err", err)
+			}
+			postHTML.WriteString(commentsSectionHTML(slug, approved, fm.CommentsLocked, r.URL.Query().Get("comment")))
+		}
 
-		renderPage(w, title, template.HTML(postHTML.String()))
+		canonical := siteURL(r) + "/posts/" + localSlug(slug, pathLang(r))
+		summary := fm.Description
+		if summary == "" {
+			summary = htmlExcerpt(html, postFileLang(slug, fm))
+		}
+		image := fm.Image
+		if image == "" {
+			image = fm.Cover
+		}
+		if image != "" {
+			image = absoluteURL(siteRoot(r), image)
+		}
+		// Syndicated posts name the original as canonical; readers still
+		// share the copy here
+		pageURL := canonical
+		if original != "" {
+			pageURL = original
+		}
+		head := postHeadTags(fm, slug) + template.HTML("<link rel=\"canonical\" href=\""+template.HTMLEscapeString(pageURL)+"\">\n")
+		if !preview {
+			head += oembedLinks(siteRoot(r), canonical, title)
+		}
+		defer startPhase(r, PhaseTemplate)()
+		renderPageData(w, PageData{
+			Title:   title,
+			Content: template.HTML(postHTML.String()),
+			Head:    head,
+			URL:     pageURL,
+			Share:   shareLinks(canonical, title),
+			Summary: summary,
+			Image:   image,
+			Type:    "article",
+		})
 	}
 }
 
 // renderPage renders the base template with content
 func renderPage(w http.ResponseWriter, title string, content template.HTML) {
-	data := PageData{
-		Title:   title,
-		Content: content,
+	renderPageData(w, PageData{Title: title, Content: content})
+}
+
+// renderPageData renders the base template for pages that need more than a title
+// and content
+func renderPageData(w http.ResponseWriter, data PageData) {
+	data.Robots = pageRobots(w)
+	data.CookieDomain = strings.Trim(cfg.Cookies.Domain, ".")
+	data.CookieSameSite = cfg.Cookies.SameSite
+	data.RememberPreferences = cfg.Cookies.RememberPreferences
+	data.Display = displayPrefs(w)
+	data.HighlightCSS = cfg.Markdown.Highlight.Enabled
+	data.LiveReload = devMode
+	data.SiteName = cfg.Site.Name
+	data.Description = cfg.Site.Description
+	data.Author = cfg.Site.Author.In("en")
+	data.DefaultLang = cfg.Site.DefaultLang
+
+	t, err := pageTemplate(pageLang(w))
+	if err != nil {
+		slog.Error("Error reloading template", "err", err)
+		renderErrorPage(w)
+		return
 	}
 
+	// Render into a buffer first so a template error mid-page becomes a clean
+	// 500 instead of half a page with an error appended
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := t.Execute(buf, data); err != nil {
+		slog.Error("Error executing template", "err", err)
+		renderErrorPage(w)
+		return
+	}
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if err := tmpl.Execute(w, data); err != nil {
-		log.Printf("Error executing template: %v", err)
-		http.Error(w, "Error rendering page", http.StatusInternalServerError)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	if data.Status != 0 {
+		w.WriteHeader(data.Status)
+	}
+	buf.WriteTo(w)
+}
+
+// pageTemplate returns the page templates for lang: those of a language with
+// its own templates, or the defaults. In hot-reload mode it picks up template
+// edits without restarting.
+func pageTemplate(lang string) (*template.Template, error) {
+	if cfg.HotReload {
+		return parsePageTemplates(".", lang)
+	}
+	if lt, ok := langTmpls[lang]; ok {
+		return lt, nil
+	}
+	return tmpl, nil
+}
+
+// errorPage is served when the page template fails. It is self-contained so it
+// can't fail the same way.
+const errorPage = `<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="UTF-8"><meta name="robots" content="noindex"><title>Error</title></head>
+<body style="font-family: sans-serif; max-width: 40rem; margin: 4rem auto; padding: 0 1rem;">
+<h1>Something went wrong</h1>
+<p>This page could not be displayed. Please try again in a moment, or go back to the <a href="/">homepage</a>.</p>
+</body>
+</html>
+`
+
+// renderErrorPage writes the standalone 500 page
+func renderErrorPage(w http.ResponseWriter) {
+	h := w.Header()
+	// Drop headers meant for the page that failed, such as caching and ETags
+	for _, k := range []string{"Cache-Control", "ETag", "Last-Modified", "Content-Length"} {
+		h.Del(k)
 	}
+	h.Set("Content-Type", "text/html; charset=utf-8")
+	h.Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusInternalServerError)
+	io.WriteString(w, errorPage)
 }
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"html/template"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 )
@@ -129,7 +131,7 @@ This is a test post.`,
 		},
 	}
 
-	handler := PostHandler(mockReader)
+	handler := PostHandler(mockReader, nil, nil)
 
 	req := httptest.NewRequest("GET", "/posts/test-post", nil)
 	req.SetPathValue("slug", "test-post")
@@ -153,7 +155,7 @@ This is a test post.`,
 func TestPostHandler_InvalidSlug(t *testing.T) {
 	mockReader := &MockSlugReader{content: map[string]string{}}
 
-	handler := PostHandler(mockReader)
+	handler := PostHandler(mockReader, nil, nil)
 
 	// Test path traversal attempt
 	req := httptest.NewRequest("GET", "/posts/../etc/passwd", nil)
@@ -170,7 +172,7 @@ func TestPostHandler_InvalidSlug(t *testing.T) {
 func TestPostHandler_NotFound(t *testing.T) {
 	mockReader := &MockSlugReader{content: map[string]string{}}
 
-	handler := PostHandler(mockReader)
+	handler := PostHandler(mockReader, nil, nil)
 
 	req := httptest.NewRequest("GET", "/posts/nonexistent", nil)
 	req.SetPathValue("slug", "nonexistent")
@@ -183,6 +185,22 @@ func TestPostHandler_NotFound(t *testing.T) {
 	}
 }
 
+func TestPostHandler_TooLarge(t *testing.T) {
+	defer func(old int) { cfg.MaxPostKB = old }(cfg.MaxPostKB)
+	cfg.MaxPostKB = 1
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "huge.md"), []byte(strings.Repeat("log line\n", 200)), 0644)
+
+	req := httptest.NewRequest("GET", "/posts/huge?lang=en", nil)
+	req.SetPathValue("slug", "huge")
+	w := httptest.NewRecorder()
+	PostHandler(&FileReader{Dir: dir}, nil, nil)(w, req)
+
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), "Something went wrong") {
+		t.Errorf("expected the 500 page for the oversized post, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
 func TestHomeHandler_SecurityHeaders(t *testing.T) {
 	// Create a temporary posts directory for testing
 	tmpDir := t.TempDir()
@@ -205,7 +223,7 @@ func TestHomeHandler_SecurityHeaders(t *testing.T) {
 	req := httptest.NewRequest("GET", "/", nil)
 	w := httptest.NewRecorder()
 
-	HomeHandler(w, req)
+	HomeHandler(newTestPostIndex(t, "posts"), nil)(w, req)
 
 	// Check security headers are set
 	if w.Header().Get("X-XSS-Protection") != "1; mode=block" {
@@ -213,26 +231,196 @@ func TestHomeHandler_SecurityHeaders(t *testing.T) {
 	}
 }
 
-func TestContactHandler_LanguageSwitch(t *testing.T) {
+func TestRenderPageDataTemplateError(t *testing.T) {
+	defer func(orig *template.Template) { tmpl = orig }(tmpl)
+	// Fails after the start of the page has been produced
+	tmpl = template.Must(template.New("base").Parse(`<html><body><h1>{{.Title}}</h1>{{index .Share 5}}</body></html>`))
+
+	w := httptest.NewRecorder()
+	w.Header().Set("ETag", `"abc"`)
+	renderPageData(w, PageData{Title: "Half a page"})
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", w.Code)
+	}
+	body := w.Body.String()
+	if strings.Contains(body, "Half a page") || !strings.Contains(body, "Something went wrong") {
+		t.Errorf("partial page leaked: %s", body)
+	}
+	if w.Header().Get("ETag") != "" || w.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("headers = %v", w.Header())
+	}
+}
+
+func TestRenderPageDataContentLength(t *testing.T) {
+	w := httptest.NewRecorder()
+	renderPage(w, "Hello", "<p>hi</p>")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Length") != strconv.Itoa(w.Body.Len()) {
+		t.Errorf("status %d, Content-Length %q for %d bytes", w.Code, w.Header().Get("Content-Length"), w.Body.Len())
+	}
+}
+
+func TestHomeHandlerPagination(t *testing.T) {
+	defer func(old int) { cfg.PostsPerPage = old }(cfg.PostsPerPage)
+	cfg.PostsPerPage = 2
+
+	dir := t.TempDir()
+	for i := 1; i <= 5; i++ {
+		content := "---\ntitle: Post " + strconv.Itoa(i) + "\ndate: 2025-01-0" + strconv.Itoa(i) + "\n---\nHi"
+		os.WriteFile(filepath.Join(dir, "post-"+strconv.Itoa(i)+".md"), []byte(content), 0644)
+	}
+	h := HomeHandler(newTestPostIndex(t, dir), nil)
+
 	tests := []struct {
-		lang         string
-		expectedText string
+		target  string
+		want    []string
+		notWant []string
 	}{
-		{"th", "ธีรภัทร ยาใจ"},
-		{"en", "Teerapat Yajai"},
+		{"/", []string{"Post 5", "Post 4", `href="/?page=2" rel="next"`, "Page 1 of 3", "1 คำ · อ่าน 1 นาที"}, []string{"Post 3", `rel="prev"`}},
+		{"/?page=2", []string{"Post 3", "Post 2", `href="/" rel="prev"`, `href="/?page=3" rel="next"`}, []string{"Post 4", "Post 1"}},
+		{"/?page=9", []string{"Post 1", "Page 3 of 3"}, []string{"Post 2", `rel="next"`}},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest("GET", tt.target, nil))
+		body := w.Body.String()
+		for _, want := range tt.want {
+			if !strings.Contains(body, want) {
+				t.Errorf("%s: missing %q", tt.target, want)
+			}
+		}
+		for _, no := range tt.notWant {
+			if strings.Contains(body, no) {
+				t.Errorf("%s: unexpected %q", tt.target, no)
+			}
+		}
 	}
+}
+
+func TestPostHandler_ReadingTime(t *testing.T) {
+	posts := &MockSlugReader{content: map[string]string{
+		"long": "---\ntitle: Long\ndate: 2025-01-01\n---\n" + strings.Repeat("word ", 450) + "\n\n```\n" + strings.Repeat("code ", 500) + "\n```\n",
+	}}
+	for lang, want := range map[string]string{
+		"en": "450 words · 3 min read",
+		"th": "450 คำ · อ่าน 3 นาที",
+	} {
+		r := httptest.NewRequest("GET", "/posts/long?lang="+lang, nil)
+		r.SetPathValue("slug", "long")
+		w := httptest.NewRecorder()
+		PostHandler(posts, nil, nil)(w, r)
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("%s: post page missing %q", lang, want)
+		}
+	}
+}
+
+func TestPostHandler_TranslationLinks(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "en-hello.md"), []byte("---\ntitle: Hello\n---\nHi"), 0644)
+	os.WriteFile(filepath.Join(dir, "th-hello.md"), []byte("---\ntitle: สวัสดี\n---\nสวัสดี"), 0644)
+	os.WriteFile(filepath.Join(dir, "en-alone.md"), []byte("---\ntitle: Alone\n---\nHi"), 0644)
+	handler := PostHandler(newTestPostIndex(t, dir), nil, nil)
+
+	get := func(slug string) string {
+		req := httptest.NewRequest("GET", "http://blog.test/posts/"+slug, nil)
+		req.SetPathValue("slug", slug)
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w.Body.String()
+	}
+	if body := get("en-hello"); !strings.Contains(body, `<a href="http://blog.test/th/posts/hello" hreflang="th" lang="th">อ่านบทความนี้เป็นภาษาไทย</a>`) {
+		t.Errorf("no link to the Thai version: %s", body)
+	}
+	if body := get("th-hello"); !strings.Contains(body, "Read this in English") {
+		t.Error("no link to the English version")
+	}
+	if body := get("en-alone"); strings.Contains(body, `class="translations"`) {
+		t.Error("untranslated post offers a translation")
+	}
+}
+
+func TestPostHandler_ArticleLang(t *testing.T) {
+	handler := PostHandler(&MockSlugReader{content: map[string]string{
+		"en-hello": "---\ntitle: Hello\n---\nHi",
+		"th-hello": "---\ntitle: สวัสดี\nlang: en\n---\nDeclared English",
+		"sawasdee": "---\ntitle: สวัสดี\n---\nสวัสดีครับ ยินดีต้อนรับ",
+	}}, nil, nil)
+	for slug, want := range map[string]string{"en-hello": "en", "th-hello": "en", "sawasdee": "th"} {
+		req := httptest.NewRequest("GET", "/posts/"+slug, nil)
+		req.SetPathValue("slug", slug)
+		w := httptest.NewRecorder()
+		handler(w, req)
+		if !strings.Contains(w.Body.String(), `<article lang="`+want+`">`) {
+			t.Errorf("%s: no <article lang=%q>", slug, want)
+		}
+	}
+}
 
+func TestParsePageTemplates_LangOverrides(t *testing.T) {
+	dir := t.TempDir()
+	for name, body := range map[string]string{
+		"templates/base.html":               `{{template "header" .}}|base`,
+		"templates/base.en.html":            `{{template "header" .}}|base en`,
+		"templates/partials/header.html":    `{{define "header"}}header{{end}}`,
+		"templates/partials/header.th.html": `{{define "header"}}header th{{end}}`,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		lang string
+		want string
+	}{
+		{"", "header|base"},
+		{"th", "header th|base"},
+		{"en", "header|base en"},
+	}
 	for _, tt := range tests {
-		t.Run(tt.lang, func(t *testing.T) {
-			req := httptest.NewRequest("GET", "/contact?lang="+tt.lang, nil)
-			w := httptest.NewRecorder()
+		tm, err := parsePageTemplates(dir, tt.lang)
+		if err != nil {
+			t.Fatalf("parsePageTemplates(%q): %v", tt.lang, err)
+		}
+		var b strings.Builder
+		if err := tm.Execute(&b, nil); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != tt.want {
+			t.Errorf("parsePageTemplates(%q) renders %q, want %q", tt.lang, b.String(), tt.want)
+		}
+	}
 
-			ContactHandler(w, req)
+	langs, err := parseLangTemplates(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(langs) != 2 {
+		t.Errorf("expected templates for th and en, got %d", len(langs))
+	}
+}
 
-			body := w.Body.String()
-			if !strings.Contains(body, tt.expectedText) {
-				t.Errorf("expected body to contain %q for lang=%s", tt.expectedText, tt.lang)
-			}
-		})
+func TestRenderPage_LangTemplates(t *testing.T) {
+	handler := DisplayPreferences(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		renderPage(w, "Hello", template.HTML("<h1>Hello</h1>"))
+	}))
+	for _, tt := range []struct {
+		lang string
+		want bool
+	}{
+		{"th", true},
+		{"en", false},
+	} {
+		r := httptest.NewRequest("GET", "/?lang="+tt.lang, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if got := strings.Contains(w.Body.String(), "line-height: 1.8"); got != tt.want {
+			t.Errorf("lang %s: Thai typography = %v, want %v", tt.lang, got, tt.want)
+		}
 	}
 }
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	gmhtml "github.com/yuin/goldmark/renderer/html"
+)
+
+// markdown converts post markdown to HTML. main rebuilds it from the loaded
+// config; until then it uses the defaults.
+var markdown = newMarkdown(defaultConfig().Markdown)
+
+// newMarkdown builds the goldmark converter described by c
+func newMarkdown(c MarkdownConfig) goldmark.Markdown {
+	var exts []goldmark.Extender
+	for _, e := range []struct {
+		on  bool
+		ext goldmark.Extender
+	}{
+		{c.Extensions.Table, extension.Table},
+		{c.Extensions.Strikethrough, extension.Strikethrough},
+		{c.Extensions.Linkify, extension.Linkify},
+		{c.Extensions.TaskList, extension.TaskList},
+		{c.Extensions.Footnote, extension.Footnote},
+		{c.Extensions.DefinitionList, extension.DefinitionList},
+		{c.Extensions.Typographer, extension.Typographer},
+		{c.Highlight.Enabled, highlightExtension(c.Highlight)},
+	} {
+		if e.on {
+			exts = append(exts, e.ext)
+		}
+	}
+
+	var parserOpts []parser.Option
+	if c.AutoHeadingID {
+		parserOpts = append(parserOpts, parser.WithAutoHeadingID())
+	}
+	if c.Attributes {
+		parserOpts = append(parserOpts, parser.WithAttribute())
+	}
+
+	var htmlOpts []renderer.Option
+	if c.Unsafe {
+		htmlOpts = append(htmlOpts, gmhtml.WithUnsafe())
+	}
+	if c.HardWraps {
+		htmlOpts = append(htmlOpts, gmhtml.WithHardWraps())
+	}
+	if c.XHTML {
+		htmlOpts = append(htmlOpts, gmhtml.WithXHTML())
+	}
+
+	return goldmark.New(
+		goldmark.WithExtensions(exts...),
+		goldmark.WithParserOptions(parserOpts...),
+		goldmark.WithRendererOptions(htmlOpts...),
+	)
+}
+
+var (
+	preBlockRegex = regexp.MustCompile(`(?s)<pre[^>]*>.*?</pre>`)
+	htmlTagRegex  = regexp.MustCompile(`<[^>]+>`)
+	blankRunRegex = regexp.MustCompile(`\n\s*\n\s*`)
+	spaceRunRegex = regexp.MustCompile(`[ \t]+`)
+	// linkTargetRegex matches the (url "title") part of a markdown link or image
+	linkTargetRegex = regexp.MustCompile(`\]\([^)]*\)`)
+)
+
+// renderMarkdown converts post markdown to HTML, expanding shortcodes and
+// [[slug]] links, making images responsive, and adding the low-quality image
+// placeholders the index generated to local images
+func renderMarkdown(src, slug string) (string, error) {
+	expanded, blocks := expandShortcodes(src, slug)
+	expanded, links := expandWikiLinks(expanded, slug)
+
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(expanded), &buf); err != nil {
+		return "", err
+	}
+	out := responsiveImages(buf.String())
+	return addImagePlaceholders(restoreShortcodes(restoreWikiLinks(out, links), blocks)), nil
+}
+
+// markdownText reduces post markdown to its prose for counting words,
+// dropping fenced code, shortcodes, HTML tags, and link targets
+func markdownText(body string) string {
+	var b strings.Builder
+	inFence := false
+	for _, line := range strings.SplitAfter(body, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			continue
+		}
+		if !inFence {
+			b.WriteString(line)
+		}
+	}
+	text := shortcodeRegex.ReplaceAllString(b.String(), " ")
+	text = linkTargetRegex.ReplaceAllString(text, "]")
+	text = htmlTagRegex.ReplaceAllString(text, " ")
+	return html.UnescapeString(text)
+}
+
+// plainText reduces rendered HTML to readable text, dropping code blocks and keeping
+// paragraph breaks
+func plainText(htmlContent string) string {
+	text := preBlockRegex.ReplaceAllString(htmlContent, "\n")
+	text = htmlTagRegex.ReplaceAllString(text, "")
+	text = html.UnescapeString(text)
+	text = spaceRunRegex.ReplaceAllString(text, " ")
+	text = blankRunRegex.ReplaceAllString(text, "\n\n")
+	return strings.TrimSpace(text)
+}
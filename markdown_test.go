@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/yuin/goldmark"
+)
+
+func TestNewMarkdown(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  func(*MarkdownConfig)
+		src     string
+		want    string
+		notWant string
+	}{
+		{"defaults drop raw HTML", func(c *MarkdownConfig) {}, "<b>hi</b>", "<!-- raw HTML omitted -->", "<b>"},
+		{"unsafe", func(c *MarkdownConfig) { c.Unsafe = true }, "<b>hi</b>", "<b>hi</b>", ""},
+		{"hard wraps", func(c *MarkdownConfig) { c.HardWraps = true }, "one\ntwo", "one<br>\ntwo", ""},
+		{"xhtml", func(c *MarkdownConfig) { c.HardWraps, c.XHTML = true, true }, "one\ntwo", "one<br />\ntwo", ""},
+		{"no tables by default", func(c *MarkdownConfig) {}, "| a |\n|---|\n| b |", "", "<table>"},
+		{"table", func(c *MarkdownConfig) { c.Extensions.Table = true }, "| a |\n|---|\n| b |", "<table>", ""},
+		{"strikethrough", func(c *MarkdownConfig) { c.Extensions.Strikethrough = true }, "~~old~~", "<del>old</del>", ""},
+		{"footnote", func(c *MarkdownConfig) { c.Extensions.Footnote = true }, "Hi[^1]\n\n[^1]: Note", `class="footnotes"`, ""},
+		{"heading ids", func(c *MarkdownConfig) { c.AutoHeadingID = true }, "## Getting Started", `<h2 id="getting-started">`, ""},
+		{"attributes", func(c *MarkdownConfig) { c.Attributes = true }, "## Hi {#top}", `<h2 id="top">`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c MarkdownConfig
+			tt.config(&c)
+			var buf bytes.Buffer
+			if err := newMarkdown(c).Convert([]byte(tt.src), &buf); err != nil {
+				t.Fatal(err)
+			}
+			got := buf.String()
+			if tt.want != "" && !strings.Contains(got, tt.want) {
+				t.Errorf("got %q, want it to contain %q", got, tt.want)
+			}
+			if tt.notWant != "" && strings.Contains(got, tt.notWant) {
+				t.Errorf("got %q, want no %q", got, tt.notWant)
+			}
+		})
+	}
+}
+
+func TestMarkdownText(t *testing.T) {
+	src := "Read [the docs](https://example.com/a-long/path) <b>now</b> &amp; then\n\n```go\nfunc main() {}\n```\n\n{{< gallery \"trip/*\" >}}\nDone"
+	got := strings.Fields(markdownText(src))
+	want := []string{"Read", "[the", "docs]", "now", "&", "then", "Done"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("markdownText = %q, want %q", got, want)
+	}
+}
+
+func TestRenderMarkdownUsesConfiguredConverter(t *testing.T) {
+	defer func(m goldmark.Markdown) { markdown = m }(markdown)
+	var c MarkdownConfig
+	c.Extensions.Strikethrough = true
+	markdown = newMarkdown(c)
+
+	html, err := renderMarkdown("~~gone~~", "test")
+	if err != nil || !strings.Contains(html, "<del>gone</del>") {
+		t.Errorf("renderMarkdown = %q, %v", html, err)
+	}
+}
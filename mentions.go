@@ -0,0 +1,513 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Mention kinds. Webmentions are stored in the same table once received.
+const (
+	MentionWebmention = "webmention"
+	MentionPingback   = "pingback"
+	MentionTrackback  = "trackback"
+)
+
+// Mention is a link to one of our posts reported by another site
+type Mention struct {
+	ID        int64
+	Kind      string
+	Source    string
+	Slug      string
+	Title     string
+	Excerpt   string
+	BlogName  string
+	CreatedAt time.Time
+}
+
+// MentionStore keeps received webmentions, pingbacks, and trackbacks
+type MentionStore struct {
+	db *sql.DB
+}
+
+const mentionsSchema = `CREATE TABLE IF NOT EXISTS mentions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	kind TEXT NOT NULL,
+	source TEXT NOT NULL,
+	slug TEXT NOT NULL,
+	title TEXT NOT NULL DEFAULT '',
+	excerpt TEXT NOT NULL DEFAULT '',
+	blog_name TEXT NOT NULL DEFAULT '',
+	created_at INTEGER NOT NULL,
+	UNIQUE (source, slug)
+);`
+
+// errMentionExists is returned when the source already mentioned the post
+var errMentionExists = errors.New("mention already registered")
+
+// NewMentionStore creates the mentions table if needed
+func NewMentionStore(db *sql.DB) (*MentionStore, error) {
+	if _, err := db.Exec(mentionsSchema); err != nil {
+		return nil, fmt.Errorf("create mentions table: %w", err)
+	}
+	return &MentionStore{db: db}, nil
+}
+
+// Add stores a mention, returning errMentionExists if the source already linked the post
+func (s *MentionStore) Add(m Mention) error {
+	res, err := s.db.Exec(`INSERT OR IGNORE INTO mentions (kind, source, slug, title, excerpt, blog_name, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		m.Kind, m.Source, m.Slug, m.Title, m.Excerpt, m.BlogName, time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("add mention: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errMentionExists
+	}
+	return nil
+}
+
+// List returns every mention, newest first
+func (s *MentionStore) List() ([]Mention, error) {
+	rows, err := s.db.Query(`SELECT id, kind, source, slug, title, excerpt, blog_name, created_at FROM mentions ORDER BY created_at DESC, id DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("list mentions: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Mention
+	for rows.Next() {
+		var m Mention
+		var created int64
+		if err := rows.Scan(&m.ID, &m.Kind, &m.Source, &m.Slug, &m.Title, &m.Excerpt, &m.BlogName, &created); err != nil {
+			return nil, fmt.Errorf("list mentions: %w", err)
+		}
+		m.CreatedAt = time.Unix(created, 0)
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+// Delete removes a mention
+func (s *MentionStore) Delete(id int64) error {
+	if _, err := s.db.Exec(`DELETE FROM mentions WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("delete mention %d: %w", id, err)
+	}
+	return nil
+}
+
+// Pingback fault codes from the specification
+const (
+	pingbackSourceMissing  = 0x10
+	pingbackNoLink         = 0x11
+	pingbackTargetMissing  = 0x20
+	pingbackAlreadyPinged  = 0x30
+	pingbackAccessDenied   = 0x31
+	pingbackUpstreamError  = 0x32
+	xmlrpcMethodNotFound   = -32601
+	xmlrpcInvalidArguments = -32602
+)
+
+// mentionError is a validation failure, carrying the pingback fault code
+type mentionError struct {
+	Code    int
+	Message string
+}
+
+func (e *mentionError) Error() string { return e.Message }
+
+// maxMentionSource caps how much of a source page is read when verifying it
+const maxMentionSource = 1 << 20
+
+var titleTag = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// mentionTarget returns the slug of the published post a target URL points at
+func mentionTarget(target string, r *http.Request, posts SlugReader) (string, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", &mentionError{pingbackTargetMissing, "target is not a URL"}
+	}
+	hosts := []string{r.Host}
+	if site, err := url.Parse(cfg.SiteURL); err == nil && site.Host != "" {
+		hosts = append(hosts, site.Host)
+	}
+	lang, path := splitLangPath(strings.TrimPrefix(u.Path, forwardedPrefix(r)))
+	slug, ok := strings.CutPrefix(strings.TrimSuffix(path, "/"), "/posts/")
+	sameHost := func(h string) bool { return strings.EqualFold(h, u.Host) }
+	if !ok || !IsValidSlug(slug) || !slices.ContainsFunc(hosts, sameHost) {
+		return "", &mentionError{pingbackTargetMissing, "target is not a post on this site"}
+	}
+	// In a language tree a post goes by its name without the language prefix
+	if _, err := posts.Read(lang + "-" + slug); lang != "" && err == nil {
+		slug = lang + "-" + slug
+	}
+	src, err := posts.Read(slug)
+	if err != nil {
+		return "", &mentionError{pingbackTargetMissing, "target post does not exist"}
+	}
+	if fm, _ := ParseFrontmatter(src); !postVisible(fm) {
+		return "", &mentionError{pingbackTargetMissing, "target post does not exist"}
+	}
+	return slug, nil
+}
+
+// errInternalAddress is returned when a mention source resolves to an
+// address on our own network
+var errInternalAddress = errors.New("address is not public")
+
+// nonPublicPrefixes are the special-purpose ranges a mention source may not
+// resolve to: this host, private and shared networks, documentation and
+// benchmarking ranges, multicast and reserved space, and the translation
+// prefixes that embed an IPv4 address in an IPv6 one
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("192.88.99.0/24"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("224.0.0.0/4"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("::/128"),
+	netip.MustParsePrefix("::1/128"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("100::/64"),
+	netip.MustParsePrefix("2001::/23"),
+	netip.MustParsePrefix("2001:db8::/32"),
+	netip.MustParsePrefix("2002::/16"),
+	netip.MustParsePrefix("fc00::/7"),
+	netip.MustParsePrefix("fe80::/10"),
+	netip.MustParsePrefix("ff00::/8"),
+}
+
+// refuseInternal is a net.Dialer Control that only lets connections to
+// public addresses through. It runs on the resolved address of every
+// connection, so neither a DNS name nor a redirect can reach the loopback,
+// link-local, private, or carrier-grade NAT networks behind the server.
+func refuseInternal(network, address string, c syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	ip := ap.Addr().Unmap()
+	for _, p := range nonPublicPrefixes {
+		if p.Contains(ip) {
+			return errInternalAddress
+		}
+	}
+	return nil
+}
+
+// newMentionClient returns the client pingbacks and trackbacks fetch their
+// sources with. Anyone can name the source, so it only connects to public
+// addresses, ignores proxy settings that would hide the address, and follows
+// a few redirects to http(s) URLs only.
+func newMentionClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: refuseInternal}
+	transport := &http.Transport{
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: timeout,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     time.Minute,
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("too many redirects")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return errors.New("redirect to a non-http URL")
+			}
+			return nil
+		},
+	}
+}
+
+// verifyMentionSource fetches the source page and checks that it links to
+// target, returning the page title
+func verifyMentionSource(ctx context.Context, client *http.Client, source, target string) (string, error) {
+	if linkPostURL(source) == "" {
+		return "", &mentionError{pingbackSourceMissing, "source is not an http(s) URL"}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return "", &mentionError{pingbackSourceMissing, "source is not a URL"}
+	}
+	// The faults say as little as possible about what the server could reach
+	resp, err := client.Do(req)
+	if err != nil {
+		slog.Debug("Could not fetch mention source", "source", source, "err", err)
+		return "", &mentionError{pingbackUpstreamError, "could not fetch source"}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		slog.Debug("Mention source not found", "source", source, "status", resp.Status)
+		return "", &mentionError{pingbackSourceMissing, "source not found"}
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxMentionSource))
+	if err != nil {
+		return "", &mentionError{pingbackUpstreamError, "could not read source"}
+	}
+	if !bytes.Contains(body, []byte(target)) && !bytes.Contains(body, []byte(html.EscapeString(target))) {
+		return "", &mentionError{pingbackNoLink, "source does not link to target"}
+	}
+	var title string
+	if m := titleTag.FindSubmatch(body); m != nil {
+		title = strings.TrimSpace(html.UnescapeString(string(m[1])))
+	}
+	return title, nil
+}
+
+// xmlrpcCall is an XML-RPC request with string parameters
+type xmlrpcCall struct {
+	MethodName string `xml:"methodName"`
+	Params     []struct {
+		Value struct {
+			String string `xml:"string"`
+			Text   string `xml:",chardata"`
+		} `xml:"value"`
+	} `xml:"params>param"`
+}
+
+// strings returns the call's parameters; untyped values are strings in XML-RPC
+func (c xmlrpcCall) strings() []string {
+	out := make([]string, len(c.Params))
+	for i, p := range c.Params {
+		out[i] = strings.TrimSpace(p.Value.String + p.Value.Text)
+	}
+	return out
+}
+
+// writeXMLRPC writes a methodResponse with a string value, or a fault when code is non-zero
+func writeXMLRPC(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	var b strings.Builder
+	b.WriteString(xml.Header + "<methodResponse>")
+	if code == 0 {
+		b.WriteString("<params><param><value><string>")
+		xml.EscapeText(&b, []byte(message))
+		b.WriteString("</string></value></param></params>")
+	} else {
+		b.WriteString("<fault><value><struct>")
+		b.WriteString("<member><name>faultCode</name><value><int>" + strconv.Itoa(code) + "</int></value></member>")
+		b.WriteString("<member><name>faultString</name><value><string>")
+		xml.EscapeText(&b, []byte(message))
+		b.WriteString("</string></value></member></struct></value></fault>")
+	}
+	b.WriteString("</methodResponse>\n")
+	io.WriteString(w, b.String())
+}
+
+// storeMention saves a verified mention and turns storage errors into pingback faults
+func storeMention(store *MentionStore, m Mention) error {
+	err := store.Add(m)
+	if errors.Is(err, errMentionExists) {
+		return &mentionError{pingbackAlreadyPinged, "this pingback has already been registered"}
+	}
+	if err != nil {
+		slog.Error("Error storing mention", "kind", m.Kind, "source", m.Source, "err", err)
+		return &mentionError{0, "could not store the mention"}
+	}
+	slog.Info("Received mention", "kind", m.Kind, "slug", m.Slug, "source", m.Source)
+	return nil
+}
+
+// faultCode returns the pingback fault code for err
+func faultCode(err error) (int, string) {
+	var me *mentionError
+	if errors.As(err, &me) && me.Code != 0 {
+		return me.Code, me.Message
+	}
+	return pingbackUpstreamError, err.Error()
+}
+
+// PingbackHandler is the XML-RPC endpoint for pingback.ping. The source page
+// must exist and link to a published post before the pingback is stored.
+func PingbackHandler(store *MentionStore, posts SlugReader, client *http.Client, limiter *RateLimiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var call xmlrpcCall
+		if err := xml.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&call); err != nil {
+			http.Error(w, "Invalid XML-RPC request", http.StatusBadRequest)
+			return
+		}
+		if call.MethodName != "pingback.ping" {
+			writeXMLRPC(w, xmlrpcMethodNotFound, "unknown method "+call.MethodName)
+			return
+		}
+		args := call.strings()
+		if len(args) != 2 {
+			writeXMLRPC(w, xmlrpcInvalidArguments, "pingback.ping takes a source and a target URL")
+			return
+		}
+		if !limiter.Allow(clientIP(r)) {
+			writeXMLRPC(w, pingbackAccessDenied, "too many pingbacks, try again later")
+			return
+		}
+
+		source, target := args[0], args[1]
+		slug, err := mentionTarget(target, r, posts)
+		if err == nil {
+			var title string
+			if title, err = verifyMentionSource(r.Context(), client, source, target); err == nil {
+				err = storeMention(store, Mention{Kind: MentionPingback, Source: source, Slug: slug, Title: title})
+			}
+		}
+		if err != nil {
+			code, message := faultCode(err)
+			writeXMLRPC(w, code, message)
+			return
+		}
+		writeXMLRPC(w, 0, "Pingback from "+source+" to "+target+" registered")
+	}
+}
+
+// writeTrackback writes a trackback response; an empty message means success
+func writeTrackback(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	var b strings.Builder
+	b.WriteString(xml.Header + "<response>")
+	if message == "" {
+		b.WriteString("<error>0</error>")
+	} else {
+		b.WriteString("<error>1</error><message>")
+		xml.EscapeText(&b, []byte(message))
+		b.WriteString("</message>")
+	}
+	b.WriteString("</response>\n")
+	io.WriteString(w, b.String())
+}
+
+// TrackbackHandler receives trackback pings at /posts/{slug}/trackback. Like
+// pingbacks, the sending page must link to the post.
+func TrackbackHandler(store *MentionStore, posts SlugReader, client *http.Client, limiter *RateLimiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		source := strings.TrimSpace(r.FormValue("url"))
+		if source == "" {
+			writeTrackback(w, "url is required")
+			return
+		}
+		if !limiter.Allow(clientIP(r)) {
+			writeTrackback(w, "too many trackbacks, try again later")
+			return
+		}
+
+		target := siteURL(r) + "/posts/" + r.PathValue("slug")
+		slug, err := mentionTarget(target, r, posts)
+		if err == nil {
+			var title string
+			if title, err = verifyMentionSource(r.Context(), client, source, target); err == nil {
+				if t := strings.TrimSpace(r.FormValue("title")); t != "" {
+					title = t
+				}
+				err = storeMention(store, Mention{
+					Kind:     MentionTrackback,
+					Source:   source,
+					Slug:     slug,
+					Title:    truncateRunes(title, 200),
+					Excerpt:  truncateRunes(strings.TrimSpace(r.FormValue("excerpt")), 500),
+					BlogName: truncateRunes(strings.TrimSpace(r.FormValue("blog_name")), 200),
+				})
+			}
+		}
+		if err != nil {
+			writeTrackback(w, err.Error())
+			return
+		}
+		writeTrackback(w, "")
+	}
+}
+
+// truncateRunes shortens s to at most n characters, the ellipsis included
+func truncateRunes(s string, n int) string {
+	return truncateText(s, n-1, "")
+}
+
+// AdminMentionsHandler lists received pingbacks and trackbacks
+func AdminMentionsHandler(store *MentionStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list, err := store.List()
+		if err != nil {
+			slog.Error("Error listing mentions", "err", err)
+			http.Error(w, "Could not list mentions", http.StatusInternalServerError)
+			return
+		}
+
+		var content bytes.Buffer
+		content.WriteString("<div class=\"admin-page\">\n")
+		content.WriteString(adminNav())
+		content.WriteString("<h1>Mentions</h1>\n")
+		if len(list) == 0 {
+			content.WriteString("<p>No mentions yet. Pingbacks and trackbacks from other blogs show up here once their pages are checked for a link.</p>\n")
+		} else {
+			content.WriteString("<table class=\"admin-table\">\n<tr><th>Post</th><th>Kind</th><th>From</th><th>Received</th><th></th></tr>\n")
+			for _, m := range list {
+				slug := template.HTMLEscapeString(m.Slug)
+				title := m.Title
+				if title == "" {
+					title = m.Source
+				}
+				content.WriteString("<tr>")
+				content.WriteString("<td><a href=\"/posts/" + slug + "\">" + slug + "</a></td>")
+				content.WriteString("<td>" + template.HTMLEscapeString(m.Kind) + "</td>")
+				content.WriteString("<td><a href=\"" + template.HTMLEscapeString(m.Source) + "\" rel=\"nofollow noopener\">" + template.HTMLEscapeString(title) + "</a>")
+				if m.BlogName != "" {
+					content.WriteString(" (" + template.HTMLEscapeString(m.BlogName) + ")")
+				}
+				if m.Excerpt != "" {
+					content.WriteString("<br><small>" + template.HTMLEscapeString(m.Excerpt) + "</small>")
+				}
+				content.WriteString("</td>")
+				content.WriteString("<td>" + m.CreatedAt.Format("Jan 2, 2006 15:04") + "</td>")
+				content.WriteString("<td class=\"admin-actions\"><form method=\"post\" action=\"/admin/mentions/" + strconv.FormatInt(m.ID, 10) + "/delete\"><button type=\"submit\">Delete</button></form></td>")
+				content.WriteString("</tr>\n")
+			}
+			content.WriteString("</table>\n")
+		}
+		content.WriteString("</div>")
+
+		renderPage(w, "Mentions", template.HTML(content.String()))
+	}
+}
+
+// AdminMentionDeleteHandler removes a mention
+func AdminMentionDeleteHandler(store *MentionStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid mention ID", http.StatusBadRequest)
+			return
+		}
+		if err := store.Delete(id); err != nil {
+			slog.Error("Error deleting mention", "err", err)
+			http.Error(w, "Could not delete mention", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/admin/mentions", http.StatusSeeOther)
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestMentionStore(t *testing.T) *MentionStore {
+	t.Helper()
+	store, err := NewMentionStore(newTestDB(t))
+	if err != nil {
+		t.Fatalf("NewMentionStore: %v", err)
+	}
+	return store
+}
+
+// mentionSources serves pages that do and don't link to the test post
+func mentionSources(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/links":
+			w.Write([]byte(`<html><head><title>A reply &amp; more</title></head><body><a href="http://example.com/posts/hello">this post</a></body></html>`))
+		case "/links-en":
+			w.Write([]byte(`<html><body><a href="http://example.com/en/posts/hi">this post</a></body></html>`))
+		case "/nolink":
+			w.Write([]byte(`<html><body>Nothing here</body></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func pingbackRequest(source, target string) *http.Request {
+	body := `<?xml version="1.0"?><methodCall><methodName>pingback.ping</methodName><params>` +
+		`<param><value><string>` + source + `</string></value></param>` +
+		`<param><value>` + target + `</value></param></params></methodCall>`
+	return httptest.NewRequest(http.MethodPost, "/xmlrpc", strings.NewReader(body))
+}
+
+func TestPingbackHandler(t *testing.T) {
+	srv := mentionSources(t)
+	posts := &MockSlugReader{content: map[string]string{
+		"hello":  "---\ntitle: Hello\n---\nHi",
+		"en-hi":  "---\ntitle: Hi\n---\nHi",
+		"future": "---\ntitle: Later\ndate: 2999-01-01\n---\nSoon",
+	}}
+
+	tests := []struct {
+		name   string
+		source string
+		target string
+		fault  string
+	}{
+		{"valid", srv.URL + "/links", "http://example.com/posts/hello", ""},
+		{"tree address", srv.URL + "/links-en", "http://example.com/en/posts/hi", ""},
+		{"duplicate", srv.URL + "/links", "http://example.com/posts/hello", "<int>48</int>"},
+		{"no link", srv.URL + "/nolink", "http://example.com/posts/hello", "<int>17</int>"},
+		{"missing source", srv.URL + "/gone", "http://example.com/posts/hello", "<int>16</int>"},
+		{"unknown post", srv.URL + "/links", "http://example.com/posts/nope", "<int>32</int>"},
+		{"scheduled post", srv.URL + "/links", "http://example.com/posts/future", "<int>32</int>"},
+		{"other site", srv.URL + "/links", "http://elsewhere.com/posts/hello", "<int>32</int>"},
+	}
+	store := newTestMentionStore(t)
+	h := PingbackHandler(store, posts, srv.Client(), NewRateLimiter(100, time.Hour))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h(w, pingbackRequest(tt.source, tt.target))
+			body := w.Body.String()
+			if tt.fault == "" && strings.Contains(body, "<fault>") {
+				t.Errorf("unexpected fault: %s", body)
+			}
+			if tt.fault != "" && !strings.Contains(body, tt.fault) {
+				t.Errorf("want fault %s, got %s", tt.fault, body)
+			}
+		})
+	}
+
+	list, err := store.List()
+	if err != nil || len(list) != 2 {
+		t.Fatalf("List() = %v, %v", list, err)
+	}
+	if list[0].Slug != "en-hi" {
+		t.Errorf("tree address stored for %q", list[0].Slug)
+	}
+	if m := list[1]; m.Kind != MentionPingback || m.Slug != "hello" || m.Title != "A reply & more" {
+		t.Errorf("stored %+v", m)
+	}
+}
+
+func TestRefuseInternal(t *testing.T) {
+	tests := []struct {
+		address string
+		refused bool
+	}{
+		{"93.184.216.34:80", false},
+		{"[2606:2800:220:1::]:443", false},
+		{"127.0.0.1:80", true},
+		{"[::1]:80", true},
+		{"169.254.169.254:80", true},
+		{"10.0.0.5:8080", true},
+		{"172.16.0.1:80", true},
+		{"192.168.1.1:443", true},
+		{"0.0.0.0:80", true},
+		{"[::ffff:127.0.0.1]:80", true},
+		{"[fd00::1]:80", true},
+		{"100.64.0.1:80", true},
+		{"100.127.255.254:80", true},
+		{"0.1.2.3:80", true},
+		{"192.0.0.170:80", true},
+		{"198.18.0.1:80", true},
+		{"198.19.255.1:80", true},
+		{"240.0.0.1:80", true},
+		{"255.255.255.255:80", true},
+		{"224.0.0.1:80", true},
+		{"[64:ff9b::7f00:1]:80", true},
+		{"[64:ff9b::a9fe:a9fe]:80", true},
+		{"[2002:7f00:1::]:80", true},
+		{"[fe80::1]:80", true},
+		{"100.128.0.1:80", false},
+		{"198.20.0.1:80", false},
+	}
+	for _, tt := range tests {
+		if err := refuseInternal("tcp", tt.address, nil); (err != nil) != tt.refused {
+			t.Errorf("refuseInternal(%s) = %v", tt.address, err)
+		}
+	}
+}
+
+func TestPingbackHandler_InternalSource(t *testing.T) {
+	srv := mentionSources(t)
+	posts := &MockSlugReader{content: map[string]string{"hello": "---\ntitle: Hello\n---\nHi"}}
+	h := PingbackHandler(newTestMentionStore(t), posts, newMentionClient(time.Second), NewRateLimiter(100, time.Hour))
+
+	// The test server listens on loopback, like the services next to the blog
+	w := httptest.NewRecorder()
+	h(w, pingbackRequest(srv.URL+"/links", "http://example.com/posts/hello"))
+	body := w.Body.String()
+	if !strings.Contains(body, "<int>50</int>") || !strings.Contains(body, "could not fetch source") {
+		t.Errorf("want a generic fault, got %s", body)
+	}
+}
+
+func TestPingbackHandlerUnknownMethod(t *testing.T) {
+	h := PingbackHandler(newTestMentionStore(t), &MockSlugReader{}, http.DefaultClient, NewRateLimiter(1, time.Hour))
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodPost, "/xmlrpc", strings.NewReader(`<methodCall><methodName>system.listMethods</methodName></methodCall>`)))
+	if !strings.Contains(w.Body.String(), "<int>-32601</int>") {
+		t.Errorf("body = %s", w.Body)
+	}
+}
+
+func TestTrackbackHandler(t *testing.T) {
+	srv := mentionSources(t)
+	posts := &MockSlugReader{content: map[string]string{"hello": "---\ntitle: Hello\n---\nHi"}}
+	store := newTestMentionStore(t)
+	h := TrackbackHandler(store, posts, srv.Client(), NewRateLimiter(100, time.Hour))
+
+	send := func(slug string, form url.Values) string {
+		r := postForm("/posts/"+slug+"/trackback", form)
+		r.SetPathValue("slug", slug)
+		w := httptest.NewRecorder()
+		h(w, r)
+		return w.Body.String()
+	}
+
+	if body := send("hello", url.Values{"url": {srv.URL + "/links"}, "title": {"My reply"}, "blog_name": {"Old Blog"}, "excerpt": {"Agreed."}}); !strings.Contains(body, "<error>0</error>") {
+		t.Fatalf("trackback rejected: %s", body)
+	}
+	if body := send("hello", url.Values{"url": {srv.URL + "/nolink"}}); !strings.Contains(body, "<error>1</error>") {
+		t.Errorf("trackback without link accepted: %s", body)
+	}
+	if body := send("hello", url.Values{}); !strings.Contains(body, "url is required") {
+		t.Errorf("body = %s", body)
+	}
+
+	list, _ := store.List()
+	if len(list) != 1 || list[0].Title != "My reply" || list[0].BlogName != "Old Blog" || list[0].Kind != MentionTrackback {
+		t.Errorf("stored %+v", list)
+	}
+}
+
+func TestPingbackRateLimit(t *testing.T) {
+	srv := mentionSources(t)
+	h := PingbackHandler(newTestMentionStore(t), &MockSlugReader{}, srv.Client(), NewRateLimiter(1, time.Hour))
+	for i, want := range []string{"<int>32</int>", "<int>49</int>"} {
+		w := httptest.NewRecorder()
+		h(w, pingbackRequest(srv.URL+"/links", "http://example.com/posts/missing"))
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("request %d: want %s, got %s", i+1, want, w.Body)
+		}
+	}
+}
+
+func TestAdminMentionsHandler(t *testing.T) {
+	store := newTestMentionStore(t)
+	if err := store.Add(Mention{Kind: MentionTrackback, Source: "https://old.blog/p/1", Slug: "hello", Title: "<b>Reply</b>"}); err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	AdminMentionsHandler(store)(w, httptest.NewRequest(http.MethodGet, "/admin/mentions", nil))
+	body := w.Body.String()
+	if !strings.Contains(body, "&lt;b&gt;Reply&lt;/b&gt;") || !strings.Contains(body, "/admin/mentions/1/delete") {
+		t.Errorf("body = %s", body)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/admin/mentions/1/delete", nil)
+	r.SetPathValue("id", "1")
+	w = httptest.NewRecorder()
+	AdminMentionDeleteHandler(store)(w, r)
+	if list, _ := store.List(); w.Code != http.StatusSeeOther || len(list) != 0 {
+		t.Errorf("delete: status %d, %d left", w.Code, len(list))
+	}
+}
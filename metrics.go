@@ -0,0 +1,237 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// durationBuckets are the upper bounds, in seconds, of the request duration histogram
+var durationBuckets = []float64{0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+type histogram struct {
+	counts []uint64 // per bucket, plus +Inf
+	sum    float64
+	count  uint64
+}
+
+// newHistogram returns a histogram over durationBuckets
+func newHistogram() *histogram {
+	return &histogram{counts: make([]uint64, len(durationBuckets)+1)}
+}
+
+// observe records a duration of s seconds
+func (h *histogram) observe(s float64) {
+	i, _ := slices.BinarySearch(durationBuckets, s)
+	h.counts[i]++
+	h.sum += s
+	h.count++
+}
+
+// routeMetrics are the requests one route answered: their durations, and
+// their number by status class
+type routeMetrics struct {
+	durations *histogram
+	classes   map[string]uint64
+}
+
+// RouteStats is a copy of the counts for one route, taken for the SLO checks
+type RouteStats struct {
+	Requests uint64
+	// Errors are the requests answered with a 5xx status
+	Errors uint64
+	// Buckets counts the requests by duration, one per durationBuckets and a
+	// last one for the slower ones
+	Buckets []uint64
+}
+
+// Metrics collects counters and request timings and serves them in the
+// Prometheus text format
+type Metrics struct {
+	mu        sync.Mutex
+	counters  map[string]uint64
+	gauges    map[string]float64
+	durations map[string]*histogram
+	routes    map[string]*routeMetrics
+}
+
+// NewMetrics returns an empty registry
+func NewMetrics() *Metrics {
+	return &Metrics{
+		counters:  make(map[string]uint64),
+		gauges:    make(map[string]float64),
+		durations: make(map[string]*histogram),
+		routes:    make(map[string]*routeMetrics),
+	}
+}
+
+// metrics is the process-wide registry
+var metrics = NewMetrics()
+
+// Inc adds one to a counter
+func (m *Metrics) Inc(name string) {
+	m.mu.Lock()
+	m.counters[name]++
+	m.mu.Unlock()
+}
+
+// Counter returns a counter's current value
+func (m *Metrics) Counter(name string) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counters[name]
+}
+
+// Set sets a gauge to its current value
+func (m *Metrics) Set(name string, v float64) {
+	m.mu.Lock()
+	m.gauges[name] = v
+	m.mu.Unlock()
+}
+
+// Observe records how long a request to handler took
+func (m *Metrics) Observe(handler string, d time.Duration) {
+	s := d.Seconds()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h := m.durations[handler]
+	if h == nil {
+		h = newHistogram()
+		m.durations[handler] = h
+	}
+	h.observe(s)
+}
+
+// ObserveRoute records a request to route answered with status after d
+func (m *Metrics) ObserveRoute(route string, status int, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rm := m.routes[route]
+	if rm == nil {
+		rm = &routeMetrics{durations: newHistogram(), classes: make(map[string]uint64)}
+		m.routes[route] = rm
+	}
+	rm.durations.observe(d.Seconds())
+	rm.classes[strconv.Itoa(status/100)+"xx"]++
+}
+
+// RouteStats returns a copy of the counts of every route
+func (m *Metrics) RouteStats() map[string]RouteStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := make(map[string]RouteStats, len(m.routes))
+	for route, rm := range m.routes {
+		stats[route] = RouteStats{
+			Requests: rm.durations.count,
+			Errors:   rm.classes["5xx"],
+			Buckets:  slices.Clone(rm.durations.counts),
+		}
+	}
+	return stats
+}
+
+// Routes records every request next serves under the route pattern it
+// matched, such as "GET /posts/{slug}", with its status and duration. next
+// must be the ServeMux itself, which sets the pattern on the request.
+// Requests no route matched are recorded as "unmatched".
+func (m *Metrics) Routes(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sr := &statusRecorder{ResponseWriter: w}
+		start := time.Now()
+		next.ServeHTTP(sr, r)
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		m.ObserveRoute(route, sr.code(), time.Since(start))
+	})
+}
+
+// Instrument times every request to next under the given handler name
+func (m *Metrics) Instrument(handler string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next(w, r)
+		m.Observe(handler, time.Since(start))
+	}
+}
+
+// WriteTo writes every metric in the Prometheus text exposition format
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(m.counters)) {
+		fmt.Fprintf(&b, "# TYPE blog_%s counter\nblog_%s %d\n", name, name, m.counters[name])
+	}
+	for _, name := range slices.Sorted(maps.Keys(m.gauges)) {
+		fmt.Fprintf(&b, "# TYPE blog_%s gauge\nblog_%s %g\n", name, name, m.gauges[name])
+	}
+	if len(m.durations) > 0 {
+		b.WriteString("# HELP blog_http_request_duration_seconds Time taken to serve requests.\n")
+		b.WriteString("# TYPE blog_http_request_duration_seconds histogram\n")
+	}
+	for _, handler := range slices.Sorted(maps.Keys(m.durations)) {
+		h := m.durations[handler]
+		var cumulative uint64
+		for i, bound := range durationBuckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(&b, "blog_http_request_duration_seconds_bucket{handler=%q,le=%q} %d\n", handler, strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(&b, "blog_http_request_duration_seconds_bucket{handler=%q,le=\"+Inf\"} %d\n", handler, h.count)
+		fmt.Fprintf(&b, "blog_http_request_duration_seconds_sum{handler=%q} %g\n", handler, h.sum)
+		fmt.Fprintf(&b, "blog_http_request_duration_seconds_count{handler=%q} %d\n", handler, h.count)
+	}
+	if len(m.routes) > 0 {
+		b.WriteString("# HELP blog_http_route_requests_total Requests answered per route and status class.\n")
+		b.WriteString("# TYPE blog_http_route_requests_total counter\n")
+	}
+	routes := slices.Sorted(maps.Keys(m.routes))
+	for _, route := range routes {
+		rm := m.routes[route]
+		for _, class := range slices.Sorted(maps.Keys(rm.classes)) {
+			fmt.Fprintf(&b, "blog_http_route_requests_total{route=%q,code=%q} %d\n", route, class, rm.classes[class])
+		}
+	}
+	if len(m.routes) > 0 {
+		b.WriteString("# HELP blog_http_route_duration_seconds Time taken to serve requests per route.\n")
+		b.WriteString("# TYPE blog_http_route_duration_seconds histogram\n")
+	}
+	for _, route := range routes {
+		h := m.routes[route].durations
+		var cumulative uint64
+		for i, bound := range durationBuckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(&b, "blog_http_route_duration_seconds_bucket{route=%q,le=%q} %d\n", route, strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(&b, "blog_http_route_duration_seconds_bucket{route=%q,le=\"+Inf\"} %d\n", route, h.count)
+		fmt.Fprintf(&b, "blog_http_route_duration_seconds_sum{route=%q} %g\n", route, h.sum)
+		fmt.Fprintf(&b, "blog_http_route_duration_seconds_count{route=%q} %d\n", route, h.count)
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// Handler serves /metrics. With a token, scrapers must send it as a bearer token.
+func (m *Metrics) Handler(token string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token != "" {
+			got, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		m.WriteTo(w)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsWriteTo(t *testing.T) {
+	m := NewMetrics()
+	m.Inc("render_cache_hits_total")
+	m.Inc("render_cache_hits_total")
+	m.Set("jobs_dead", 3)
+	m.Observe("home", 300*time.Microsecond)
+	m.Observe("home", 20*time.Millisecond)
+	m.Observe("home", 2*time.Second)
+
+	var b strings.Builder
+	m.WriteTo(&b)
+	out := b.String()
+	for _, want := range []string{
+		"# TYPE blog_render_cache_hits_total counter\nblog_render_cache_hits_total 2\n",
+		"# TYPE blog_jobs_dead gauge\nblog_jobs_dead 3\n",
+		"# TYPE blog_http_request_duration_seconds histogram\n",
+		`blog_http_request_duration_seconds_bucket{handler="home",le="0.00025"} 0`,
+		`blog_http_request_duration_seconds_bucket{handler="home",le="0.0005"} 1`,
+		`blog_http_request_duration_seconds_bucket{handler="home",le="0.025"} 2`,
+		`blog_http_request_duration_seconds_bucket{handler="home",le="1"} 2`,
+		`blog_http_request_duration_seconds_bucket{handler="home",le="+Inf"} 3`,
+		`blog_http_request_duration_seconds_count{handler="home"} 3`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestMetricsInstrumentAndHandler(t *testing.T) {
+	m := NewMetrics()
+	h := m.Instrument("post", func(w http.ResponseWriter, r *http.Request) {})
+	h(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/posts/x", nil))
+
+	w := httptest.NewRecorder()
+	m.Handler("")(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(w.Body.String(), `blog_http_request_duration_seconds_count{handler="post"} 1`) {
+		t.Errorf("body = %s", w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	m.Handler("s3cret")(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("without token: status %d", w.Code)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	r.Header.Set("Authorization", "Bearer s3cret")
+	w = httptest.NewRecorder()
+	m.Handler("s3cret")(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("with token: status %d", w.Code)
+	}
+}
+
+func TestMetricsRoutes(t *testing.T) {
+	m := NewMetrics()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /posts/{slug}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("slug") == "broken" {
+			http.Error(w, "boom", http.StatusInternalServerError)
+		}
+	})
+	h := m.Routes(mux)
+	for _, path := range []string{"/posts/a", "/posts/b", "/posts/broken", "/nowhere"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	stats := m.RouteStats()
+	if s := stats["GET /posts/{slug}"]; s.Requests != 3 || s.Errors != 1 || len(s.Buckets) != len(durationBuckets)+1 {
+		t.Errorf("post route = %+v", s)
+	}
+	if s := stats["unmatched"]; s.Requests != 1 || s.Errors != 0 {
+		t.Errorf("unmatched = %+v", s)
+	}
+
+	var b strings.Builder
+	m.WriteTo(&b)
+	out := b.String()
+	for _, want := range []string{
+		"# TYPE blog_http_route_requests_total counter\n",
+		`blog_http_route_requests_total{route="GET /posts/{slug}",code="2xx"} 2`,
+		`blog_http_route_requests_total{route="GET /posts/{slug}",code="5xx"} 1`,
+		`blog_http_route_requests_total{route="unmatched",code="4xx"} 1`,
+		"# TYPE blog_http_route_duration_seconds histogram\n",
+		`blog_http_route_duration_seconds_bucket{route="GET /posts/{slug}",le="+Inf"} 3`,
+		`blog_http_route_duration_seconds_count{route="unmatched"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// IndieAuthToken is what a token endpoint reports about an access token
+type IndieAuthToken struct {
+	Me       string `json:"me"`
+	ClientID string `json:"client_id"`
+	Scope    string `json:"scope"`
+}
+
+// HasScope reports whether the token grants scope
+func (t IndieAuthToken) HasScope(scope string) bool {
+	return slices.Contains(strings.Fields(t.Scope), scope)
+}
+
+// TokenVerifier checks a bearer token presented to the Micropub endpoint
+type TokenVerifier interface {
+	Verify(ctx context.Context, token string) (IndieAuthToken, error)
+}
+
+// errInvalidToken is returned for tokens the token endpoint does not accept or
+// that belong to someone else
+var errInvalidToken = errors.New("invalid access token")
+
+// IndieAuthVerifier verifies tokens against an IndieAuth token endpoint and
+// caches the answers for a few minutes
+type IndieAuthVerifier struct {
+	// Endpoint is the token endpoint, e.g. https://tokens.indieauth.com/token
+	Endpoint string
+	// Me is the site URL tokens must be issued for
+	Me     string
+	Client *http.Client
+
+	mu    sync.Mutex
+	cache map[string]cachedToken
+}
+
+type cachedToken struct {
+	token IndieAuthToken
+	// invalid tokens are remembered too, so repeating one costs no request
+	invalid bool
+	expires time.Time
+}
+
+const (
+	// indieAuthCacheTTL bounds how long a revoked token keeps working
+	indieAuthCacheTTL = 5 * time.Minute
+	// indieAuthInvalidTTL is how long a rejected token stays rejected
+	indieAuthInvalidTTL = time.Minute
+	// maxIndieAuthCache bounds the token cache; it is emptied when full
+	maxIndieAuthCache = 1000
+	// maxMicropubBody caps create requests in either syntax
+	maxMicropubBody = 1 << 20
+	// maxMicropubFailures is how many invalid tokens a client may present an
+	// hour before it's turned away without asking the token endpoint
+	maxMicropubFailures = 10
+)
+
+// sameMe compares profile URLs, ignoring case and a trailing slash
+func sameMe(a, b string) bool {
+	norm := func(s string) string { return strings.TrimSuffix(strings.ToLower(s), "/") }
+	return norm(a) == norm(b)
+}
+
+func (v *IndieAuthVerifier) Verify(ctx context.Context, token string) (IndieAuthToken, error) {
+	v.mu.Lock()
+	if c, ok := v.cache[token]; ok && time.Now().Before(c.expires) {
+		v.mu.Unlock()
+		if c.invalid {
+			return IndieAuthToken{}, errInvalidToken
+		}
+		return c.token, nil
+	}
+	v.mu.Unlock()
+
+	t, err := v.fetch(ctx, token)
+	switch {
+	case errors.Is(err, errInvalidToken):
+		v.remember(token, cachedToken{invalid: true, expires: time.Now().Add(indieAuthInvalidTTL)})
+	case err == nil:
+		v.remember(token, cachedToken{token: t, expires: time.Now().Add(indieAuthCacheTTL)})
+	}
+	return t, err
+}
+
+// remember caches the answer for a token
+func (v *IndieAuthVerifier) remember(token string, c cachedToken) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.cache == nil || len(v.cache) >= maxIndieAuthCache {
+		v.cache = make(map[string]cachedToken)
+	}
+	v.cache[token] = c
+}
+
+// fetch asks the token endpoint about a token
+func (v *IndieAuthVerifier) fetch(ctx context.Context, token string) (IndieAuthToken, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.Endpoint, nil)
+	if err != nil {
+		return IndieAuthToken{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+	var t IndieAuthToken
+	resp, err := v.Client.Do(req)
+	if err != nil {
+		return t, fmt.Errorf("verify token: %w", err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return t, errInvalidToken
+	case resp.StatusCode != http.StatusOK:
+		return t, fmt.Errorf("verify token: %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&t); err != nil {
+		return t, fmt.Errorf("verify token: decode response: %w", err)
+	}
+	if !sameMe(t.Me, v.Me) {
+		return t, errInvalidToken
+	}
+	return t, nil
+}
+
+// micropubEntry is an h-entry from a Micropub create request, normalized from
+// either the form-encoded or the JSON syntax
+type micropubEntry struct {
+	Name       string
+	Content    string
+	Categories []string
+	Published  string
+	Slug       string
+	BookmarkOf string
+}
+
+// micropubFrontmatter is the frontmatter written for a Micropub post
+type micropubFrontmatter struct {
+	Title string   `yaml:"title,omitempty"`
+	Date  string   `yaml:"date"`
+	Tags  []string `yaml:"tags,flow,omitempty"`
+	Link  string   `yaml:"link,omitempty"`
+}
+
+// parseMicropubRequest reads a create request in either syntax
+func parseMicropubRequest(r *http.Request) (micropubEntry, error) {
+	var e micropubEntry
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if ct == "application/json" {
+		var body struct {
+			Type       []string         `json:"type"`
+			Properties map[string][]any `json:"properties"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			return e, fmt.Errorf("invalid JSON: %w", err)
+		}
+		if len(body.Type) != 1 || body.Type[0] != "h-entry" {
+			return e, errors.New("only h-entry is supported")
+		}
+		first := func(name string) string {
+			for _, v := range body.Properties[name] {
+				switch v := v.(type) {
+				case string:
+					return v
+				case map[string]any:
+					// Rich content arrives as {"html": "..."}; markdown passes HTML through
+					if html, ok := v["html"].(string); ok {
+						return html
+					}
+					if value, ok := v["value"].(string); ok {
+						return value
+					}
+				}
+			}
+			return ""
+		}
+		e.Name, e.Content, e.Published = first("name"), first("content"), first("published")
+		e.Slug, e.BookmarkOf = first("mp-slug"), first("bookmark-of")
+		for _, c := range body.Properties["category"] {
+			if s, ok := c.(string); ok {
+				e.Categories = append(e.Categories, s)
+			}
+		}
+		return e, nil
+	}
+
+	if err := r.ParseMultipartForm(maxMicropubBody); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		return e, fmt.Errorf("invalid form: %w", err)
+	}
+	if h := r.PostForm.Get("h"); h != "entry" {
+		return e, errors.New("only h=entry is supported")
+	}
+	e.Name, e.Content, e.Published = r.PostForm.Get("name"), r.PostForm.Get("content"), r.PostForm.Get("published")
+	e.Slug, e.BookmarkOf = r.PostForm.Get("mp-slug"), r.PostForm.Get("bookmark-of")
+	e.Categories = append(r.PostForm["category"], r.PostForm["category[]"]...)
+	return e, nil
+}
+
+// slugify makes a URL slug from ASCII letters and digits in s; text in other
+// scripts, such as Thai titles, yields an empty slug
+func slugify(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+		if b.Len() >= 60 {
+			break
+		}
+	}
+	return b.String()
+}
+
+// micropubPost turns an entry into a file: a post when it has a name, otherwise a note
+func micropubPost(e micropubEntry, now time.Time) (slug, content string, err error) {
+	published := now.In(cfg.Location())
+	if e.Published != "" {
+		t, ok := parsePostDate(e.Published)
+		if !ok {
+			return "", "", fmt.Errorf("invalid published date %q", e.Published)
+		}
+		published = t
+	}
+
+	fm := micropubFrontmatter{Title: strings.TrimSpace(e.Name), Date: published.Format(time.RFC3339), Tags: e.Categories}
+	if e.BookmarkOf != "" {
+		if fm.Link = linkPostURL(e.BookmarkOf); fm.Link == "" {
+			return "", "", fmt.Errorf("invalid bookmark-of URL %q", e.BookmarkOf)
+		}
+	}
+	if strings.TrimSpace(e.Content) == "" && fm.Link == "" {
+		return "", "", errors.New("content is required")
+	}
+
+	slug = e.Slug
+	if slug == "" {
+		slug = slugify(fm.Title)
+	}
+	if slug == "" || !IsValidSlug(slug) {
+		slug = published.Format("2006-01-02-1504")
+	}
+
+	head, err := yaml.Marshal(fm)
+	if err != nil {
+		return "", "", err
+	}
+	content = "---\n" + string(head) + "---\n\n" + strings.TrimSpace(e.Content) + "\n"
+	return slug, content, nil
+}
+
+// micropubError writes an error response in the form Micropub clients expect
+func micropubError(w http.ResponseWriter, status int, code, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": code, "error_description": description})
+}
+
+// MicropubHandler lets IndieWeb clients such as Quill and Indigenous publish.
+// Entries with a name become posts in posts/, the rest notes in notes/.
+func MicropubHandler(verifier TokenVerifier, posts, notes PostFiles) http.HandlerFunc {
+	failures := NewRateLimiter(maxMicropubFailures, time.Hour)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Header().Set("Content-Type", "application/json")
+			switch r.URL.Query().Get("q") {
+			case "config":
+				w.Write([]byte(`{"syndicate-to": [], "post-types": [{"type": "note", "name": "Note"}, {"type": "article", "name": "Post"}, {"type": "bookmark", "name": "Link post"}]}`))
+			case "syndicate-to":
+				w.Write([]byte(`{"syndicate-to": []}`))
+			default:
+				micropubError(w, http.StatusBadRequest, "invalid_request", "unsupported query")
+			}
+			return
+		}
+
+		// Capped before anything reads the form, which may hold the token
+		r.Body = http.MaxBytesReader(w, r.Body, maxMicropubBody)
+		var tooLarge *http.MaxBytesError
+		err := r.ParseForm()
+		if err == nil {
+			err = r.ParseMultipartForm(maxMicropubBody)
+		}
+		if errors.As(err, &tooLarge) {
+			micropubError(w, http.StatusRequestEntityTooLarge, "invalid_request", "the request is larger than 1 MB")
+			return
+		}
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" {
+			token = r.FormValue("access_token")
+		}
+		if token == "" {
+			micropubError(w, http.StatusUnauthorized, "unauthorized", "missing access token")
+			return
+		}
+		if failures.Blocked(clientIP(r)) {
+			micropubError(w, http.StatusTooManyRequests, "too_many_requests", "too many invalid access tokens, try again later")
+			return
+		}
+		t, err := verifier.Verify(r.Context(), token)
+		if errors.Is(err, errInvalidToken) {
+			failures.Allow(clientIP(r))
+			micropubError(w, http.StatusForbidden, "forbidden", "the access token is not valid for this site")
+			return
+		}
+		if err != nil {
+			slog.Error("Error verifying Micropub token", "err", err)
+			micropubError(w, http.StatusServiceUnavailable, "temporarily_unavailable", "could not verify the access token")
+			return
+		}
+		if !t.HasScope("create") && !t.HasScope("post") {
+			micropubError(w, http.StatusForbidden, "insufficient_scope", "the create scope is required")
+			return
+		}
+
+		if action := r.FormValue("action"); action != "" && action != "create" {
+			micropubError(w, http.StatusBadRequest, "invalid_request", "only create is supported")
+			return
+		}
+		entry, err := parseMicropubRequest(r)
+		if err != nil {
+			micropubError(w, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+		slug, content, err := micropubPost(entry, time.Now())
+		if err != nil {
+			micropubError(w, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+
+		files, section := notes, "notes"
+		if entry.Name != "" {
+			files, section = posts, "posts"
+		}
+		base := slug
+		for i := 2; ; i++ {
+			_, err := files.Read(slug)
+			if errors.Is(err, fs.ErrNotExist) {
+				break
+			}
+			if err != nil {
+				slog.Error("Error checking Micropub slug", "section", section, "slug", slug, "err", err)
+				micropubError(w, http.StatusInternalServerError, "server_error", "could not save the post")
+				return
+			}
+			slug = fmt.Sprintf("%s-%d", base, i)
+		}
+		if err := files.Write(slug, content); err != nil {
+			slog.Error("Error writing Micropub entry", "section", section, "slug", slug, "err", err)
+			micropubError(w, http.StatusInternalServerError, "server_error", "could not save the post")
+			return
+		}
+		slog.Info("Micropub entry created", "client", t.ClientID, "section", section, "slug", slug)
+
+		w.Header().Set("Location", siteURL(r)+"/"+section+"/"+slug)
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+// micropubHeadLinks advertises the Micropub and IndieAuth endpoints so clients can discover them
+func micropubHeadLinks() template.HTML {
+	if cfg.Micropub.TokenEndpoint == "" {
+		return ""
+	}
+	return template.HTML(`<link rel="micropub" href="/micropub">` + "\n" +
+		`<link rel="authorization_endpoint" href="` + template.HTMLEscapeString(cfg.Micropub.AuthorizationEndpoint) + `">` + "\n" +
+		`<link rel="token_endpoint" href="` + template.HTMLEscapeString(cfg.Micropub.TokenEndpoint) + `">` + "\n")
+}
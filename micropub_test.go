@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubVerifier accepts a single token
+type stubVerifier struct {
+	token IndieAuthToken
+}
+
+func (v stubVerifier) Verify(ctx context.Context, token string) (IndieAuthToken, error) {
+	if token != "good" {
+		return IndieAuthToken{}, errInvalidToken
+	}
+	return v.token, nil
+}
+
+func TestIndieAuthVerifier(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch r.Header.Get("Authorization") {
+		case "Bearer good":
+			json.NewEncoder(w).Encode(IndieAuthToken{Me: "https://learnarai.com/", ClientID: "https://quill.p3k.io/", Scope: "create media"})
+		case "Bearer other":
+			json.NewEncoder(w).Encode(IndieAuthToken{Me: "https://someone.else/", Scope: "create"})
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	v := &IndieAuthVerifier{Endpoint: srv.URL, Me: "https://learnarai.com", Client: srv.Client()}
+	tok, err := v.Verify(context.Background(), "good")
+	if err != nil || !tok.HasScope("create") || tok.HasScope("update") {
+		t.Fatalf("Verify(good) = %+v, %v", tok, err)
+	}
+	if _, err := v.Verify(context.Background(), "good"); err != nil || calls != 1 {
+		t.Errorf("second Verify made %d calls, err %v; want cached", calls, err)
+	}
+	for _, token := range []string{"other", "bad"} {
+		if _, err := v.Verify(context.Background(), token); err != errInvalidToken {
+			t.Errorf("Verify(%s) error = %v, want errInvalidToken", token, err)
+		}
+	}
+	// Rejected tokens are remembered as well
+	calls = 0
+	if _, err := v.Verify(context.Background(), "bad"); err != errInvalidToken || calls != 0 {
+		t.Errorf("repeated bad token made %d calls, err %v", calls, err)
+	}
+}
+
+func TestMicropubHandler_Limits(t *testing.T) {
+	h := MicropubHandler(stubVerifier{IndieAuthToken{Me: "https://learnarai.com/", Scope: "create"}}, &MockSlugReader{}, &MockSlugReader{})
+
+	// A body over the cap is refused before the token in it is looked at
+	r := postForm("/micropub", url.Values{"h": {"entry"}, "content": {strings.Repeat("x", maxMicropubBody)}, "access_token": {"good"}})
+	w := httptest.NewRecorder()
+	h(w, r)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("large body: status %d", w.Code)
+	}
+
+	// Clients presenting invalid tokens are turned away after a few
+	for i := 0; i < maxMicropubFailures; i++ {
+		r := postForm("/micropub", url.Values{"h": {"entry"}, "content": {"x"}})
+		r.Header.Set("Authorization", "Bearer bad"+strconv.Itoa(i))
+		h(httptest.NewRecorder(), r)
+	}
+	r = postForm("/micropub", url.Values{"h": {"entry"}, "content": {"x"}})
+	r.Header.Set("Authorization", "Bearer good")
+	w = httptest.NewRecorder()
+	h(w, r)
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("after %d invalid tokens: status %d", maxMicropubFailures, w.Code)
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	tests := map[string]string{
+		"Hello, World!":           "hello-world",
+		"  Go 1.25 -- what's new": "go-1-25-what-s-new",
+		"สวัสดี":                  "",
+		"Go กับ SQLite":           "go-sqlite",
+	}
+	for in, want := range tests {
+		if got := slugify(in); got != want {
+			t.Errorf("slugify(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestMicropubPost(t *testing.T) {
+	now := time.Date(2026, 3, 2, 9, 30, 0, 0, cfg.Location())
+	slug, content, err := micropubPost(micropubEntry{
+		Name:       "Hello: Micropub",
+		Content:    "Posted from Quill.",
+		Categories: []string{"indieweb", "go"},
+	}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slug != "hello-micropub" {
+		t.Errorf("slug = %q", slug)
+	}
+	fm, body := ParseFrontmatter(content)
+	if fm.Title != "Hello: Micropub" || len(fm.Tags) != 2 || body != "Posted from Quill." {
+		t.Errorf("frontmatter = %+v, body %q", fm, body)
+	}
+	if d, ok := parsePostDate(fm.Date); !ok || !d.Equal(now) {
+		t.Errorf("date = %q, want %v", fm.Date, now)
+	}
+
+	slug, _, _ = micropubPost(micropubEntry{Content: "A short note"}, now)
+	if slug != "2026-03-02-0930" {
+		t.Errorf("note slug = %q", slug)
+	}
+
+	for name, e := range map[string]micropubEntry{
+		"no content":   {Name: "Empty"},
+		"bad date":     {Content: "x", Published: "yesterday"},
+		"bad bookmark": {BookmarkOf: "javascript:alert(1)"},
+	} {
+		if _, _, err := micropubPost(e, now); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestMicropubHandler(t *testing.T) {
+	posts := &MockSlugReader{content: map[string]string{"taken": "---\ntitle: Taken\n---\n"}}
+	notes := &MockSlugReader{content: map[string]string{}}
+	h := MicropubHandler(stubVerifier{IndieAuthToken{Me: "https://learnarai.com/", Scope: "create"}}, posts, notes)
+
+	t.Run("form note", func(t *testing.T) {
+		r := postForm("/micropub", url.Values{"h": {"entry"}, "content": {"Just a note"}, "category[]": {"til"}})
+		r.Header.Set("Authorization", "Bearer good")
+		w := httptest.NewRecorder()
+		h(w, r)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("status = %d: %s", w.Code, w.Body)
+		}
+		loc := w.Header().Get("Location")
+		slug := strings.TrimPrefix(loc, "http://example.com/notes/")
+		if slug == loc || !strings.Contains(notes.content[slug], "tags: [til]") {
+			t.Errorf("Location = %q, notes = %v", loc, notes.content)
+		}
+	})
+
+	t.Run("json post with unique slug", func(t *testing.T) {
+		body := `{"type":["h-entry"],"properties":{"name":["Taken"],"content":[{"html":"<p>Hi</p>"}],"mp-slug":["taken"]}}`
+		r := httptest.NewRequest(http.MethodPost, "/micropub", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Authorization", "Bearer good")
+		w := httptest.NewRecorder()
+		h(w, r)
+		if w.Code != http.StatusCreated || w.Header().Get("Location") != "http://example.com/posts/taken-2" {
+			t.Fatalf("status = %d, Location = %q", w.Code, w.Header().Get("Location"))
+		}
+		if !strings.Contains(posts.content["taken-2"], "<p>Hi</p>") {
+			t.Errorf("post = %q", posts.content["taken-2"])
+		}
+	})
+
+	tests := []struct {
+		name   string
+		token  string
+		values url.Values
+		status int
+		code   string
+	}{
+		{"no token", "", url.Values{"h": {"entry"}, "content": {"x"}}, http.StatusUnauthorized, "unauthorized"},
+		{"bad token", "bad", url.Values{"h": {"entry"}, "content": {"x"}}, http.StatusForbidden, "forbidden"},
+		{"token in body", "", url.Values{"h": {"entry"}, "access_token": {"good"}}, http.StatusBadRequest, "invalid_request"},
+		{"unsupported action", "good", url.Values{"action": {"delete"}, "url": {"http://example.com/posts/taken"}}, http.StatusBadRequest, "invalid_request"},
+		{"not an entry", "good", url.Values{"h": {"event"}, "content": {"x"}}, http.StatusBadRequest, "invalid_request"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := postForm("/micropub", tt.values)
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			h(w, r)
+			var resp map[string]string
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			if w.Code != tt.status || resp["error"] != tt.code {
+				t.Errorf("got %d %q, want %d %q", w.Code, resp["error"], tt.status, tt.code)
+			}
+		})
+	}
+}
+
+func TestMicropubHandler_UnreadableSlug(t *testing.T) {
+	// A post that exists but can't be read must not be taken for a free slug
+	posts := &flakyReader{MockSlugReader{content: map[string]string{"taken": "---\ntitle: Taken\n---\n"}}, map[string]bool{"taken": true}}
+	h := MicropubHandler(stubVerifier{IndieAuthToken{Me: "https://learnarai.com/", Scope: "create"}}, posts, &MockSlugReader{content: map[string]string{}})
+
+	r := postForm("/micropub", url.Values{"h": {"entry"}, "name": {"Taken"}, "content": {"New"}, "mp-slug": {"taken"}})
+	r.Header.Set("Authorization", "Bearer good")
+	w := httptest.NewRecorder()
+	h(w, r)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", w.Code)
+	}
+	if posts.content["taken"] != "---\ntitle: Taken\n---\n" {
+		t.Errorf("existing post overwritten: %q", posts.content["taken"])
+	}
+}
+
+func TestMicropubScope(t *testing.T) {
+	h := MicropubHandler(stubVerifier{IndieAuthToken{Me: "https://learnarai.com/", Scope: "media"}}, &MockSlugReader{}, &MockSlugReader{})
+	r := postForm("/micropub", url.Values{"h": {"entry"}, "content": {"x"}})
+	r.Header.Set("Authorization", "Bearer good")
+	w := httptest.NewRecorder()
+	h(w, r)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "insufficient_scope") {
+		t.Errorf("status = %d, body %s", w.Code, w.Body)
+	}
+}
+
+func TestMicropubConfigQuery(t *testing.T) {
+	h := MicropubHandler(stubVerifier{}, &MockSlugReader{}, &MockSlugReader{})
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/micropub?q=config", nil))
+	var resp map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp["syndicate-to"] == nil {
+		t.Errorf("q=config = %s (%v)", w.Body, err)
+	}
+}
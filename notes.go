@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// notesDir holds notes: short posts that need no title
+const notesDir = "notes"
+
+// tilDir holds "today I learned" entries
+const tilDir = "til"
+
+// notesPerPage is how many entries a section's landing page shows per page
+const notesPerPage = 20
+
+// NoteFiles reads and lists note files
+type NoteFiles interface {
+	SlugReader
+	SlugLister
+}
+
+// Note is a micropost, or an entry of another section, rendered through the
+// same markdown pipeline as posts
+type Note struct {
+	Slug  string
+	Title string
+	Date  time.Time
+	HTML  string
+}
+
+// Summary returns the title, or the start of the text for untitled notes
+func (n Note) Summary() string {
+	if n.Title != "" {
+		return n.Title
+	}
+	text := strings.Join(strings.Fields(plainText(n.HTML)), " ")
+	if utf8.RuneCountInString(text) <= 60 {
+		return text
+	}
+	return string([]rune(text)[:60]) + "…"
+}
+
+// loadNote reads and renders one note, reporting false if it is missing or scheduled
+func loadNote(sl SlugReader, slug string) (Note, bool) {
+	md, err := sl.Read(slug)
+	if err != nil {
+		return Note{}, false
+	}
+	fm, body := ParseFrontmatter(md)
+	if !postVisible(fm) {
+		return Note{}, false
+	}
+	html, err := renderMarkdown(body, slug)
+	if err != nil {
+		slog.Error("Error rendering note", "slug", slug, "err", err)
+		return Note{}, false
+	}
+	n := Note{Slug: slug, Title: fm.Title, HTML: html}
+	n.Date, _ = parsePostDate(fm.Date)
+	return n, true
+}
+
+// loadNotes returns every published note, newest first. Notes without a date
+// sort by slug, which is usually date-prefixed.
+func loadNotes(notes NoteFiles) ([]Note, error) {
+	slugs, err := notes.List()
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []Note
+	for _, slug := range slugs {
+		if n, ok := loadNote(notes, slug); ok {
+			out = append(out, n)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if !out[i].Date.Equal(out[j].Date) {
+			return out[i].Date.After(out[j].Date)
+		}
+		return out[i].Slug > out[j].Slug
+	})
+	return out, nil
+}
+
+// noteHTML renders an entry of section as an article with its date as the
+// permalink, titled with the given heading tag so permalink pages get their h1
+func noteHTML(n Note, section, heading string) string {
+	var b strings.Builder
+	b.WriteString("<article class=\"note\" id=\"" + template.HTMLEscapeString(n.Slug) + "\" lang=\"" + detectLang(n.Title+" "+plainText(n.HTML)) + "\">\n")
+	switch {
+	case n.Title != "":
+		b.WriteString("<" + heading + ">" + template.HTMLEscapeString(n.Title) + "</" + heading + ">\n")
+	case heading == "h1":
+		b.WriteString("<h1 class=\"visually-hidden\">" + template.HTMLEscapeString(n.Summary()) + "</h1>\n")
+	}
+	b.WriteString(n.HTML)
+	date := "Permalink"
+	if !n.Date.IsZero() {
+		date = n.Date.Format("Jan 2, 2006")
+	}
+	b.WriteString("<a class=\"note-permalink\" href=\"/" + section + "/" + template.HTMLEscapeString(n.Slug) + "\">" + date + "</a>\n")
+	b.WriteString("</article>\n")
+	return b.String()
+}
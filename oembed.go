@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// oembedWidth is the width of the embed card unless the consumer asks for less
+const oembedWidth = 600
+
+// oembedResponse is a rich oEmbed response (oembed.com, section 2.3.4)
+type oembedResponse struct {
+	XMLName         xml.Name `json:"-" xml:"oembed"`
+	Type            string   `json:"type" xml:"type"`
+	Version         string   `json:"version" xml:"version"`
+	Title           string   `json:"title" xml:"title"`
+	AuthorName      string   `json:"author_name" xml:"author_name"`
+	ProviderName    string   `json:"provider_name" xml:"provider_name"`
+	ProviderURL     string   `json:"provider_url" xml:"provider_url"`
+	CacheAge        int      `json:"cache_age" xml:"cache_age"`
+	HTML            string   `json:"html" xml:"html"`
+	Width           int      `json:"width" xml:"width"`
+	Height          int      `json:"height" xml:"height"`
+	ThumbnailURL    string   `json:"thumbnail_url,omitempty" xml:"thumbnail_url,omitempty"`
+	ThumbnailWidth  int      `json:"thumbnail_width,omitempty" xml:"thumbnail_width,omitempty"`
+	ThumbnailHeight int      `json:"thumbnail_height,omitempty" xml:"thumbnail_height,omitempty"`
+}
+
+// siteRoot returns the site's address without the language tree of the request
+func siteRoot(r *http.Request) string {
+	return strings.TrimSuffix(siteURL(r), langPathPrefix(r))
+}
+
+// oembedLinks returns the oEmbed discovery tags for a post page
+func oembedLinks(root, pageURL, title string) template.HTML {
+	var b strings.Builder
+	for _, format := range []string{"json", "xml"} {
+		href := root + "/oembed?url=" + url.QueryEscape(pageURL) + "&format=" + format
+		b.WriteString(`<link rel="alternate" type="application/` + format + `+oembed" href="` + template.HTMLEscapeString(href) +
+			`" title="` + template.HTMLEscapeString(title) + `">` + "\n")
+	}
+	return template.HTML(b.String())
+}
+
+// oembedPost finds the published post a URL path on this site points at:
+// /posts/{slug} or its address in a language tree
+func oembedPost(posts *PostIndex, p string) (*indexedPost, bool) {
+	lang, rest := splitLangPath(p)
+	name, after, ok := postPathSegment(rest)
+	if !ok || after != "" || strings.Contains(name, ".") {
+		return nil, false
+	}
+	if lang != "" {
+		slug, redirect := posts.resolve(lang, name)
+		if redirect != "" {
+			return oembedPost(posts, redirect)
+		}
+		name = slug
+	}
+	entry, ok := posts.Get(name)
+	if !ok || !postVisible(entry.FM) {
+		return nil, false
+	}
+	return entry, true
+}
+
+// coverThumbnail returns the absolute URL of a post's cover and, for images
+// served from images/, its size
+func coverThumbnail(root, cover string) (string, int, int) {
+	if cover == "" {
+		return "", 0, 0
+	}
+	if rel, ok := strings.CutPrefix(cover, "/images/"); ok && !strings.Contains(rel, "..") {
+		if ph, err := imagePlaceholder(filepath.Join("images", filepath.FromSlash(rel))); err == nil {
+			return absoluteURL(root, cover), ph.Width, ph.Height
+		}
+	}
+	return absoluteURL(root, cover), 0, 0
+}
+
+// oembedCardHTML renders the card consumers embed. It carries its own styles
+// since the embedding page doesn't have the site's stylesheet.
+func oembedCardHTML(pageURL, title, excerpt, thumbnail string, width int) string {
+	href := template.HTMLEscapeString(pageURL)
+	var b strings.Builder
+	b.WriteString(`<blockquote class="learnarai-embed" style="margin:0;max-width:` + strconv.Itoa(width) + `px;padding:16px;border:1px solid #ddd;border-radius:8px;font-family:sans-serif">`)
+	b.WriteString(`<a href="` + href + `" style="color:inherit;text-decoration:none">`)
+	if thumbnail != "" {
+		b.WriteString(`<img src="` + template.HTMLEscapeString(thumbnail) + `" alt="" style="display:block;width:100%;height:auto;border-radius:4px">`)
+	}
+	b.WriteString(`<strong style="display:block;margin:8px 0 4px;font-size:1.1em">` + template.HTMLEscapeString(title) + `</strong></a>`)
+	if excerpt != "" {
+		b.WriteString(`<p style="margin:0 0 8px;color:#555">` + template.HTMLEscapeString(excerpt) + `</p>`)
+	}
+	b.WriteString(`<small><a href="` + href + `">` + template.HTMLEscapeString(cfg.Site.Name) + `</a></small></blockquote>`)
+	return b.String()
+}
+
+// OEmbedHandler answers oEmbed requests for post URLs with a rich card: the
+// title, an excerpt, and the cover image. maxwidth narrows the card; other
+// sites' URLs and unpublished posts are 404, unknown formats 501, per the spec.
+func OEmbedHandler(posts *PostIndex) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		format := q.Get("format")
+		if format == "" {
+			format = "json"
+		}
+		if format != "json" && format != "xml" {
+			http.Error(w, "Unsupported format", http.StatusNotImplemented)
+			return
+		}
+		target, err := url.Parse(q.Get("url"))
+		if err != nil || q.Get("url") == "" {
+			http.Error(w, "Missing or invalid url", http.StatusBadRequest)
+			return
+		}
+		p, ok := strings.CutPrefix(target.Path, forwardedPrefix(r))
+		if !strings.EqualFold(target.Host, r.Host) || !ok {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		entry, ok := oembedPost(posts, p)
+		if !ok {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+
+		root := siteRoot(r)
+		pageURL := root + postTreePath(entry.Slug, entry.Lang)
+		title := entry.Title
+		if title == "" {
+			title = toTitleCase(strings.ReplaceAll(entry.Slug, "-", " "))
+		}
+		_, body := ParseFrontmatter(entry.Content)
+		excerpt, err := postExcerpt(body, entry.Slug, entry.Lang)
+		if err != nil {
+			slog.Error("Error rendering oEmbed excerpt", "slug", entry.Slug, "err", err)
+		}
+
+		width := oembedWidth
+		if n, err := strconv.Atoi(q.Get("maxwidth")); err == nil && n > 0 {
+			width = min(width, n)
+		}
+		thumb, thumbW, thumbH := coverThumbnail(root, entry.FM.Cover)
+		// Room for the title, excerpt, and credit, plus the cover scaled to the card
+		height := 160
+		if thumbW > 0 {
+			height += width * thumbH / thumbW
+		}
+		if n, err := strconv.Atoi(q.Get("maxheight")); err == nil && n > 0 {
+			height = min(height, n)
+		}
+
+		resp := oembedResponse{
+			Type:            "rich",
+			Version:         "1.0",
+			Title:           title,
+			AuthorName:      cfg.Site.Name,
+			ProviderName:    cfg.Site.Name,
+			ProviderURL:     root + "/",
+			CacheAge:        3600,
+			HTML:            oembedCardHTML(pageURL, title, excerpt, thumb, width),
+			Width:           width,
+			Height:          height,
+			ThumbnailURL:    thumb,
+			ThumbnailWidth:  thumbW,
+			ThumbnailHeight: thumbH,
+		}
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		if format == "xml" {
+			w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+			w.Write([]byte(xml.Header))
+			if err := xml.NewEncoder(w).Encode(resp); err != nil {
+				slog.Error("Error writing oEmbed response", "err", err)
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			slog.Error("Error writing oEmbed response", "err", err)
+		}
+	}
+}
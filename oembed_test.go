@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOEmbedHandler(t *testing.T) {
+	t.Chdir(t.TempDir())
+	os.MkdirAll("posts", 0755)
+	os.MkdirAll("images", 0755)
+	f, _ := os.Create("images/cover.png")
+	png.Encode(f, image.NewRGBA(image.Rect(0, 0, 300, 150)))
+	f.Close()
+	os.WriteFile(filepath.Join("posts", "en-hello.md"), []byte("---\ntitle: Hello <World>\ndate: 2025-03-01\ncover: /images/cover.png\n---\nA short **intro**."), 0644)
+	os.WriteFile(filepath.Join("posts", "wip.md"), []byte("---\ntitle: WIP\ndraft: true\n---\nSoon."), 0644)
+	h := OEmbedHandler(newTestPostIndex(t, "posts"))
+
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest("GET", "http://blog.example/oembed?url="+url.QueryEscape(target)+"&maxwidth=400", nil))
+		return w
+	}
+
+	for _, target := range []string{"http://blog.example/en/posts/hello", "http://blog.example/posts/en-hello"} {
+		w := get(target)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status %d", target, w.Code)
+		}
+		var resp oembedResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Type != "rich" || resp.Version != "1.0" || resp.Title != "Hello <World>" || resp.Width != 400 || resp.Height != 360 {
+			t.Errorf("%s: %+v", target, resp)
+		}
+		if resp.ThumbnailURL != "http://blog.example/images/cover.png" || resp.ThumbnailWidth != 300 || resp.ThumbnailHeight != 150 {
+			t.Errorf("thumbnail = %q %dx%d", resp.ThumbnailURL, resp.ThumbnailWidth, resp.ThumbnailHeight)
+		}
+		for _, want := range []string{`href="http://blog.example/en/posts/hello"`, "Hello &lt;World&gt;", "A short intro.", "max-width:400px"} {
+			if !strings.Contains(resp.HTML, want) {
+				t.Errorf("card missing %q: %s", want, resp.HTML)
+			}
+		}
+	}
+
+	for _, target := range []string{"http://other.example/en/posts/hello", "http://blog.example/posts/wip", "http://blog.example/posts/missing", "http://blog.example/tags/go"} {
+		if w := get(target); w.Code != http.StatusNotFound {
+			t.Errorf("%s: status %d, want 404", target, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest("GET", "http://blog.example/oembed?format=xml&url="+url.QueryEscape("http://blog.example/en/posts/hello"), nil))
+	var resp oembedResponse
+	if err := xml.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Title != "Hello <World>" || resp.Width != oembedWidth {
+		t.Errorf("xml: %v %+v", err, resp)
+	}
+
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest("GET", "http://blog.example/oembed?format=yaml&url=x", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("unknown format: status %d", w.Code)
+	}
+}
+
+func TestPostHandlerOEmbedDiscovery(t *testing.T) {
+	reader := &MockSlugReader{content: map[string]string{"hello": "---\ntitle: Hello\n---\nHi"}}
+	req := httptest.NewRequest("GET", "http://blog.example/posts/hello", nil)
+	req.SetPathValue("slug", "hello")
+	w := httptest.NewRecorder()
+	PostHandler(reader, nil, nil)(w, req)
+
+	want := `<link rel="alternate" type="application/json+oembed" href="http://blog.example/oembed?url=http%3A%2F%2Fblog.example%2Fposts%2Fhello&amp;format=json" title="Hello">`
+	if !strings.Contains(w.Body.String(), want) {
+		t.Errorf("missing discovery link in %s", w.Body.String())
+	}
+}
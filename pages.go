@@ -0,0 +1,110 @@
+package main
+
+import (
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// pagesDir holds standalone pages such as the about text of /contact, one
+// markdown file per language (name.th.md, name.en.md) or name.md for both
+const pagesDir = "pages"
+
+// Page is a markdown page rendered for one language
+type Page struct {
+	Title string
+	HTML  string
+}
+
+// loadPage reads and renders the page name in lang, falling back to the default
+// language's version and then to name.md
+func loadPage(pages SlugReader, name, lang string) (Page, error) {
+	var src string
+	var err error
+	for _, file := range []string{name + "." + lang, name + "." + defaultLang(), name} {
+		if src, err = pages.Read(file); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return Page{}, err
+	}
+	fm, content := ParseFrontmatter(src)
+	html, err := renderMarkdown(content, name)
+	if err != nil {
+		return Page{}, err
+	}
+	title := fm.Title
+	if title == "" {
+		title = toTitleCase(strings.ReplaceAll(name, "-", " "))
+	}
+	return Page{Title: title, HTML: html}, nil
+}
+
+// PageHandler serves any markdown page in pages/ at /pages/{name}, in the
+// reader's language
+func PageHandler(pages SlugReader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setSecurityHeaders(w)
+
+		name := r.PathValue("name")
+		if !IsValidSlug(name) {
+			renderError(w, http.StatusBadRequest, requestLang(r))
+			return
+		}
+		page, err := loadPage(pages, name, requestLang(r))
+		if err != nil {
+			renderError(w, http.StatusNotFound, requestLang(r))
+			return
+		}
+		renderPage(w, page.Title, template.HTML("<article class=\"page\">\n"+page.HTML+"</article>\n"))
+	}
+}
+
+// ContactHandler displays the contact page: the about text from
+// pages/contact.{lang}.md, the contact links from the site config, and the
+// message form
+func ContactHandler(pages SlugReader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setSecurityHeaders(w)
+
+		lang := requestLang(r)
+		title := "Contact"
+
+		content := getBuffer()
+		defer putBuffer(content)
+		content.WriteString("<div class=\"contact-page\">\n")
+		if page, err := loadPage(pages, "contact", lang); err == nil {
+			title = page.Title
+			content.WriteString(page.HTML)
+		} else {
+			slog.Error("Error loading contact page", "err", err)
+			content.WriteString("<h1>Contact</h1>\n<p>" + template.HTMLEscapeString(cfg.Site.Author.In(lang)) + "</p>\n")
+		}
+		if len(cfg.Site.Contacts) > 0 {
+			content.WriteString("<section class=\"contact-section\">\n<h2>Contact Me</h2>\n<ul class=\"contact-list\">\n")
+			for _, c := range cfg.Site.Contacts {
+				content.WriteString("<li>" + contactLinkHTML(c) + "</li>\n")
+			}
+			content.WriteString("</ul>\n</section>\n")
+		}
+		content.WriteString(contactFormHTML(lang, r.URL.Query().Get("sent") == "1"))
+		content.WriteString("</div>")
+
+		renderPage(w, title, template.HTML(content.String()))
+	}
+}
+
+// contactLinkHTML renders a contact link as its label and the address without
+// the scheme, e.g. GitHub: github.com/kenn-teera
+func contactLinkHTML(c ContactLink) string {
+	text := strings.TrimPrefix(c.URL, "mailto:")
+	attrs := ""
+	if text == c.URL {
+		text = strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(c.URL, "https://"), "http://"), "/")
+		attrs = ` target="_blank" rel="noopener noreferrer"`
+	}
+	return template.HTMLEscapeString(c.Label) + `: <a href="` + template.HTMLEscapeString(c.URL) + `"` + attrs + `>` +
+		template.HTMLEscapeString(text) + `</a>`
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadPage(t *testing.T) {
+	pages := &MockSlugReader{content: map[string]string{
+		"about.th": "# เกี่ยวกับ",
+		"about.en": "---\ntitle: About me\n---\n# About",
+		"uses":     "# Uses",
+	}}
+	tests := []struct {
+		name, lang string
+		wantTitle  string
+		wantHTML   string
+	}{
+		{"about", "en", "About me", "<h1>About</h1>"},
+		{"about", "th", "About", "<h1>เกี่ยวกับ</h1>"},
+		{"uses", "en", "Uses", "<h1>Uses</h1>"},
+	}
+	for _, tt := range tests {
+		page, err := loadPage(pages, tt.name, tt.lang)
+		if err != nil {
+			t.Fatalf("loadPage(%s, %s): %v", tt.name, tt.lang, err)
+		}
+		if page.Title != tt.wantTitle || !strings.Contains(page.HTML, tt.wantHTML) {
+			t.Errorf("loadPage(%s, %s) = %+v", tt.name, tt.lang, page)
+		}
+	}
+	if _, err := loadPage(pages, "missing", "en"); err == nil {
+		t.Error("missing page loaded")
+	}
+}
+
+func TestLoadPage_DefaultLanguage(t *testing.T) {
+	defer func(old string) { cfg.Site.DefaultLang = old }(cfg.Site.DefaultLang)
+	cfg.Site.DefaultLang = "th"
+
+	pages := &MockSlugReader{content: map[string]string{"about.th": "# เกี่ยวกับ"}}
+	page, err := loadPage(pages, "about", "en")
+	if err != nil || !strings.Contains(page.HTML, "เกี่ยวกับ") {
+		t.Errorf("loadPage = %+v, %v; want the Thai page", page, err)
+	}
+}
+
+func TestPageHandler(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "uses.en.md"), []byte("---\ntitle: Uses\n---\nMy **setup**"), 0644)
+	handler := PageHandler(&FileReader{Dir: dir})
+
+	tests := []struct {
+		name       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"uses", 200, "My <strong>setup</strong>"},
+		{"missing", 404, "Page not found"},
+		{"..", 400, "Page not found"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/pages/"+tt.name+"?lang=en", nil)
+		req.SetPathValue("name", tt.name)
+		w := httptest.NewRecorder()
+		handler(w, req)
+		if w.Code != tt.wantStatus || !strings.Contains(w.Body.String(), tt.wantBody) {
+			t.Errorf("%s: status %d, body %q", tt.name, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestContactHandler_LanguageSwitch(t *testing.T) {
+	tests := []struct {
+		lang         string
+		expectedText string
+	}{
+		{"th", "ธีรภัทร ยาใจ"},
+		{"en", "Teerapat Yajai"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.lang, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/contact?lang="+tt.lang, nil)
+			w := httptest.NewRecorder()
+
+			ContactHandler(&FileReader{Dir: pagesDir})(w, req)
+
+			body := w.Body.String()
+			if !strings.Contains(body, tt.expectedText) {
+				t.Errorf("expected body to contain %q for lang=%s", tt.expectedText, tt.lang)
+			}
+		})
+	}
+}
+
+func TestContactHandler_SiteConfig(t *testing.T) {
+	defer func(old SiteConfig) { cfg.Site = old }(cfg.Site)
+	cfg.Site = SiteConfig{
+		Name:        "Jane's Notes",
+		Author:      Localized{"": "Jane Doe"},
+		Contacts:    []ContactLink{{Label: "Email", URL: "mailto:jane@example.com"}, {Label: "GitHub", URL: "https://github.com/janedoe/"}},
+		DefaultLang: "en",
+	}
+
+	w := httptest.NewRecorder()
+	ContactHandler(&FileReader{Dir: t.TempDir()})(w, httptest.NewRequest("GET", "/contact", nil))
+	body := w.Body.String()
+	for _, want := range []string{
+		"<p>Jane Doe</p>",
+		`Email: <a href="mailto:jane@example.com">jane@example.com</a>`,
+		`GitHub: <a href="https://github.com/janedoe/" target="_blank" rel="noopener noreferrer">github.com/janedoe</a>`,
+		"<title>Contact | Jane&#39;s Notes</title>",
+		`<html lang="en"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+	if strings.Contains(body, "Teerapat") || strings.Contains(body, "LearnArai") {
+		t.Error("page still shows the default owner")
+	}
+}
+
+func TestContactHandler_PageContent(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "contact.en.md"), []byte("---\ntitle: Say hello\n---\n# Hi\n\nI write about *Go*."), 0644)
+
+	w := httptest.NewRecorder()
+	ContactHandler(&FileReader{Dir: dir})(w, httptest.NewRequest("GET", "/contact?lang=en", nil))
+	body := w.Body.String()
+	for _, want := range []string{"<title>Say hello |", "I write about <em>Go</em>.", `class="contact-form"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Pagination splits a listing into pages and renders the links between them.
+// It is shared by every paginated listing so their URLs and head links agree.
+type Pagination struct {
+	// Page is the current page, starting at 1
+	Page int
+	// PerPage is the page size; 0 puts everything on one page
+	PerPage int
+	Total   int
+	// Path is the listing's URL path; Query holds parameters other than page
+	Path  string
+	Query url.Values
+}
+
+// singlePage puts every listing on one page. Static exports set it, since
+// static hosting can't serve ?page=N.
+var singlePage bool
+
+// NewPagination reads ?page=N from the request. Out-of-range pages are clamped
+// to the nearest valid one.
+func NewPagination(r *http.Request, perPage, total int) Pagination {
+	if singlePage {
+		perPage = 0
+	}
+	q := url.Values{}
+	for k, v := range r.URL.Query() {
+		if k != "page" {
+			q[k] = v
+		}
+	}
+	p := Pagination{PerPage: perPage, Total: total, Path: r.URL.Path, Query: q}
+	p.Page, _ = strconv.Atoi(r.URL.Query().Get("page"))
+	p.Page = min(max(p.Page, 1), p.Pages())
+	return p
+}
+
+// Pages returns the number of pages, at least 1
+func (p Pagination) Pages() int {
+	if p.PerPage <= 0 || p.Total == 0 {
+		return 1
+	}
+	return (p.Total + p.PerPage - 1) / p.PerPage
+}
+
+// Bounds returns the slice indexes of the items on the current page
+func (p Pagination) Bounds() (int, int) {
+	if p.PerPage <= 0 {
+		return 0, p.Total
+	}
+	start := min((p.Page-1)*p.PerPage, p.Total)
+	return start, min(start+p.PerPage, p.Total)
+}
+
+// HasPrev reports whether there is a page before the current one
+func (p Pagination) HasPrev() bool { return p.Page > 1 }
+
+// HasNext reports whether there is a page after the current one
+func (p Pagination) HasNext() bool { return p.Page < p.Pages() }
+
+// URL returns the relative URL of a page; page 1 has no page parameter so it
+// shares its URL with the unpaginated listing
+func (p Pagination) URL(page int) string {
+	q := url.Values{}
+	for k, v := range p.Query {
+		q[k] = v
+	}
+	if page > 1 {
+		q.Set("page", strconv.Itoa(page))
+	}
+	if len(q) == 0 {
+		return p.Path
+	}
+	return p.Path + "?" + q.Encode()
+}
+
+// NavHTML renders newer/older links for the bottom of a listing, or "" when
+// everything fits on one page
+func (p Pagination) NavHTML() string {
+	if p.Pages() <= 1 {
+		return ""
+	}
+	nav := `<nav class="pagination">`
+	if p.HasPrev() {
+		nav += `<a href="` + template.HTMLEscapeString(p.URL(p.Page-1)) + `" rel="prev">← Newer</a>`
+	}
+	nav += `<span>Page ` + strconv.Itoa(p.Page) + ` of ` + strconv.Itoa(p.Pages()) + `</span>`
+	if p.HasNext() {
+		nav += `<a href="` + template.HTMLEscapeString(p.URL(p.Page+1)) + `" rel="next">Older →</a>`
+	}
+	return nav + "</nav>\n"
+}
+
+// HeadLinks renders the canonical URL of the current page plus rel="prev" and
+// rel="next" links, all absolute under base
+func (p Pagination) HeadLinks(base string) template.HTML {
+	links := `<link rel="canonical" href="` + template.HTMLEscapeString(base+p.URL(p.Page)) + `">` + "\n"
+	if p.HasPrev() {
+		links += `<link rel="prev" href="` + template.HTMLEscapeString(base+p.URL(p.Page-1)) + `">` + "\n"
+	}
+	if p.HasNext() {
+		links += `<link rel="next" href="` + template.HTMLEscapeString(base+p.URL(p.Page+1)) + `">` + "\n"
+	}
+	return template.HTML(links)
+}
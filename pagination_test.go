@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPagination(t *testing.T) {
+	tests := []struct {
+		name      string
+		target    string
+		perPage   int
+		total     int
+		wantPage  int
+		wantStart int
+		wantEnd   int
+		wantHead  []string
+		notHead   []string
+	}{
+		{
+			name: "first page", target: "/tags/go", perPage: 10, total: 25,
+			wantPage: 1, wantStart: 0, wantEnd: 10,
+			wantHead: []string{`rel="canonical" href="https://blog.example/tags/go"`, `rel="next" href="https://blog.example/tags/go?page=2"`},
+			notHead:  []string{`rel="prev"`},
+		},
+		{
+			name: "middle page keeps other parameters", target: "/?lang=en&page=2", perPage: 10, total: 25,
+			wantPage: 2, wantStart: 10, wantEnd: 20,
+			wantHead: []string{
+				`rel="canonical" href="https://blog.example/?lang=en&amp;page=2"`,
+				`rel="prev" href="https://blog.example/?lang=en"`,
+				`rel="next" href="https://blog.example/?lang=en&amp;page=3"`,
+			},
+		},
+		{
+			name: "last page", target: "/archive?page=3", perPage: 10, total: 25,
+			wantPage: 3, wantStart: 20, wantEnd: 25,
+			wantHead: []string{`rel="prev" href="https://blog.example/archive?page=2"`},
+			notHead:  []string{`rel="next"`},
+		},
+		{
+			name: "out of range is clamped", target: "/archive?page=99", perPage: 10, total: 25,
+			wantPage: 3, wantStart: 20, wantEnd: 25,
+		},
+		{
+			name: "garbage page", target: "/archive?page=abc", perPage: 10, total: 25,
+			wantPage: 1, wantStart: 0, wantEnd: 10,
+		},
+		{
+			name: "unpaginated", target: "/", perPage: 0, total: 25,
+			wantPage: 1, wantStart: 0, wantEnd: 25,
+			wantHead: []string{`rel="canonical" href="https://blog.example/"`},
+			notHead:  []string{`rel="prev"`, `rel="next"`},
+		},
+		{
+			name: "empty listing", target: "/tags/none", perPage: 10, total: 0,
+			wantPage: 1, wantStart: 0, wantEnd: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPagination(httptest.NewRequest("GET", tt.target, nil), tt.perPage, tt.total)
+			if p.Page != tt.wantPage {
+				t.Errorf("expected page %d, got %d", tt.wantPage, p.Page)
+			}
+			if start, end := p.Bounds(); start != tt.wantStart || end != tt.wantEnd {
+				t.Errorf("expected bounds [%d:%d], got [%d:%d]", tt.wantStart, tt.wantEnd, start, end)
+			}
+			head := string(p.HeadLinks("https://blog.example"))
+			for _, want := range tt.wantHead {
+				if !strings.Contains(head, want) {
+					t.Errorf("expected %s in %s", want, head)
+				}
+			}
+			for _, nw := range tt.notHead {
+				if strings.Contains(head, nw) {
+					t.Errorf("did not expect %s in %s", nw, head)
+				}
+			}
+		})
+	}
+}
+
+func TestPagination_NavHTML(t *testing.T) {
+	p := NewPagination(httptest.NewRequest("GET", "/notes?page=2", nil), 10, 25)
+	nav := p.NavHTML()
+	for _, want := range []string{`href="/notes" rel="prev"`, `href="/notes?page=3" rel="next"`, "Page 2 of 3"} {
+		if !strings.Contains(nav, want) {
+			t.Errorf("NavHTML missing %q: %s", want, nav)
+		}
+	}
+
+	if nav := NewPagination(httptest.NewRequest("GET", "/notes", nil), 10, 5).NavHTML(); nav != "" {
+		t.Errorf("single page should have no nav, got %s", nav)
+	}
+}
@@ -0,0 +1,427 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// A4 page size and margins in PDF points
+const (
+	pdfPageWidth  = 595.0
+	pdfPageHeight = 842.0
+	pdfMargin     = 56.0
+)
+
+// pdfFont measures and encodes text in one font of a PDF
+type pdfFont interface {
+	// width returns the width of s at size points
+	width(s string, size float64) float64
+	// encode returns s as a PDF string operand for Tj
+	encode(s string) string
+	// write adds the font's objects to the document and returns the font dictionary's object number
+	write(p *pdfWriter) int
+}
+
+// helveticaWidths are the advance widths of ASCII 32-126 in Helvetica and
+// Helvetica-Bold, in thousandths of an em, from the Adobe core font metrics
+var helveticaWidths = [2][95]int{
+	{278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584},
+	{278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584},
+}
+
+// helveticaFont is a PDF core font. It needs no embedding but only covers
+// Latin-1; other characters are printed as "?".
+type helveticaFont struct {
+	bold bool
+}
+
+func (f helveticaFont) latin1(s string) []byte {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r == '–' || r == '—':
+			b = append(b, '-')
+		case r == '‘' || r == '’':
+			b = append(b, '\'')
+		case r == '“' || r == '”':
+			b = append(b, '"')
+		case r == '•':
+			b = append(b, 0x95) // WinAnsi bullet
+		case r < 256 && (r >= 32 || r == '\t'):
+			b = append(b, byte(r))
+		default:
+			b = append(b, '?')
+		}
+	}
+	return b
+}
+
+func (f helveticaFont) width(s string, size float64) float64 {
+	table := helveticaWidths[0]
+	if f.bold {
+		table = helveticaWidths[1]
+	}
+	total := 0
+	for _, c := range f.latin1(s) {
+		switch {
+		case c >= 32 && c <= 126:
+			total += table[c-32]
+		case c == 0x95:
+			total += 350
+		default:
+			total += 556
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+func (f helveticaFont) encode(s string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, c := range f.latin1(s) {
+		if c == '(' || c == ')' || c == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+func (f helveticaFont) write(p *pdfWriter) int {
+	name := "Helvetica"
+	if f.bold {
+		name = "Helvetica-Bold"
+	}
+	return p.add("<< /Type /Font /Subtype /Type1 /BaseFont /" + name + " /Encoding /WinAnsiEncoding >>")
+}
+
+// trueTypeFont embeds a TrueType font so any script it covers, such as Thai,
+// can be printed. Text is written as glyph IDs; combining marks rely on the
+// font's own zero-width glyphs rather than OpenType positioning.
+type trueTypeFont struct {
+	data []byte
+	f    *sfnt.Font
+	buf  sfnt.Buffer
+	// used maps the glyphs printed so far to the text they stand for
+	used map[sfnt.GlyphIndex]rune
+}
+
+// newTrueTypeFont parses a TrueType (.ttf) font file
+func newTrueTypeFont(data []byte) (*trueTypeFont, error) {
+	f, err := sfnt.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse font: %w", err)
+	}
+	return &trueTypeFont{data: data, f: f, used: make(map[sfnt.GlyphIndex]rune)}, nil
+}
+
+func (f *trueTypeFont) glyph(r rune) sfnt.GlyphIndex {
+	g, err := f.f.GlyphIndex(&f.buf, r)
+	if err != nil {
+		return 0
+	}
+	return g
+}
+
+// advance returns a glyph's width in thousandths of an em
+func (f *trueTypeFont) advance(g sfnt.GlyphIndex) int {
+	upem := f.f.UnitsPerEm()
+	adv, err := f.f.GlyphAdvance(&f.buf, g, fixed.Int26_6(upem)<<6, font.HintingNone)
+	if err != nil {
+		return 0
+	}
+	return int(int64(adv>>6) * 1000 / int64(upem))
+}
+
+func (f *trueTypeFont) width(s string, size float64) float64 {
+	total := 0
+	for _, r := range s {
+		total += f.advance(f.glyph(r))
+	}
+	return float64(total) * size / 1000
+}
+
+func (f *trueTypeFont) encode(s string) string {
+	var b strings.Builder
+	b.WriteByte('<')
+	for _, r := range s {
+		g := f.glyph(r)
+		if _, ok := f.used[g]; !ok {
+			f.used[g] = r
+		}
+		fmt.Fprintf(&b, "%04X", uint16(g))
+	}
+	b.WriteByte('>')
+	return b.String()
+}
+
+func (f *trueTypeFont) write(p *pdfWriter) int {
+	name := "EmbeddedFont"
+	if n, err := f.f.Name(&f.buf, sfnt.NameIDPostScript); err == nil && n != "" {
+		name = strings.Map(func(r rune) rune {
+			if r > ' ' && r < 127 && !strings.ContainsRune("()<>[]{}/%#", r) {
+				return r
+			}
+			return -1
+		}, n)
+	}
+
+	glyphs := make([]sfnt.GlyphIndex, 0, len(f.used))
+	for g := range f.used {
+		glyphs = append(glyphs, g)
+	}
+	sort.Slice(glyphs, func(i, j int) bool { return glyphs[i] < glyphs[j] })
+
+	var widths, unicodes strings.Builder
+	for _, g := range glyphs {
+		fmt.Fprintf(&widths, "%d [%d] ", g, f.advance(g))
+		fmt.Fprintf(&unicodes, "<%04X> <%s>\n", uint16(g), utf16Hex(f.used[g]))
+	}
+
+	upem := int64(f.f.UnitsPerEm())
+	ascent, descent := 800, -200
+	if m, err := f.f.Metrics(&f.buf, fixed.Int26_6(upem)<<6, font.HintingNone); err == nil {
+		ascent = int(int64(m.Ascent>>6) * 1000 / upem)
+		descent = -int(int64(m.Descent>>6) * 1000 / upem)
+	}
+
+	file := p.addStream("/Length1 "+strconv.Itoa(len(f.data)), f.data)
+	descriptor := p.add(fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags 32 /FontBBox [-1000 %d 2000 %d] /ItalicAngle 0 /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+		name, descent, ascent, ascent, descent, ascent, file))
+	cid := p.add(fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /W [%s] /CIDToGIDMap /Identity >>",
+		name, descriptor, widths.String()))
+	cmap := p.addStream("", []byte("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n"+
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n"+
+		"1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n"+
+		strconv.Itoa(len(glyphs))+" beginbfchar\n"+unicodes.String()+"endbfchar\n"+
+		"endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n"))
+	return p.add(fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+		name, cid, cmap))
+}
+
+// utf16Hex returns r in UTF-16BE as hex, for ToUnicode maps
+func utf16Hex(r rune) string {
+	if r >= 0x10000 {
+		r -= 0x10000
+		return fmt.Sprintf("%04X%04X", 0xD800+(r>>10), 0xDC00+(r&0x3FF))
+	}
+	return fmt.Sprintf("%04X", r)
+}
+
+// pdfWriter collects numbered PDF objects
+type pdfWriter struct {
+	objects [][]byte
+}
+
+// add stores an object and returns its number
+func (p *pdfWriter) add(obj string) int {
+	p.objects = append(p.objects, []byte(obj))
+	return len(p.objects)
+}
+
+// reserve allocates an object number to be filled in with set
+func (p *pdfWriter) reserve() int {
+	return p.add("")
+}
+
+func (p *pdfWriter) set(n int, obj string) {
+	p.objects[n-1] = []byte(obj)
+}
+
+// addStream stores a compressed stream object; extra is added to its dictionary
+func (p *pdfWriter) addStream(extra string, data []byte) int {
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	zw.Write(data)
+	zw.Close()
+	var obj bytes.Buffer
+	fmt.Fprintf(&obj, "<< /Length %d /Filter /FlateDecode %s>>\nstream\n", z.Len(), extra)
+	obj.Write(z.Bytes())
+	obj.WriteString("\nendstream")
+	p.objects = append(p.objects, obj.Bytes())
+	return len(p.objects)
+}
+
+// bytes serializes the document with root as the catalog
+func (p *pdfWriter) bytes(root, info int) []byte {
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(p.objects))
+	for i, obj := range p.objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n", i+1)
+		out.Write(obj)
+		out.WriteString("\nendobj\n")
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(p.objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(p.objects)+1, root, info, xref)
+	return out.Bytes()
+}
+
+// PDFDocument lays out text top to bottom on A4 pages, starting a new page
+// when one fills up. It is deliberately small: enough for a CV or a printable
+// page, not a general typesetter.
+type PDFDocument struct {
+	Title string
+	fonts []pdfFont
+	pages []*bytes.Buffer
+	y     float64
+}
+
+// NewPDFDocument returns an empty document using regular and bold for text
+func NewPDFDocument(title string, regular, bold pdfFont) *PDFDocument {
+	d := &PDFDocument{Title: title, fonts: []pdfFont{regular, bold}}
+	d.newPage()
+	return d
+}
+
+func (d *PDFDocument) newPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.y = pdfPageHeight - pdfMargin
+}
+
+// Space moves down by h points
+func (d *PDFDocument) Space(h float64) {
+	d.y -= h
+}
+
+// Text writes a paragraph, wrapped to the page width and indented by indent points
+func (d *PDFDocument) Text(s string, size float64, bold bool, indent float64) {
+	f := d.fonts[0]
+	if bold {
+		f = d.fonts[1]
+	}
+	leading := size * 1.35
+	for _, line := range wrapText(f, s, size, pdfPageWidth-2*pdfMargin-indent) {
+		if d.y-leading < pdfMargin {
+			d.newPage()
+		}
+		d.y -= leading
+		font := "/F1"
+		if bold {
+			font = "/F2"
+		}
+		fmt.Fprintf(d.pages[len(d.pages)-1], "BT %s %.1f Tf %.2f %.2f Td %s Tj ET\n", font, size, pdfMargin+indent, d.y, f.encode(line))
+	}
+}
+
+// Rule draws a thin horizontal line across the page
+func (d *PDFDocument) Rule() {
+	d.y -= 4
+	fmt.Fprintf(d.pages[len(d.pages)-1], "0.75 G 0.5 w %.2f %.2f m %.2f %.2f l S 0 G\n", pdfMargin, d.y, pdfPageWidth-pdfMargin, d.y)
+	d.y -= 4
+}
+
+// Bytes renders the finished PDF
+func (d *PDFDocument) Bytes() []byte {
+	p := &pdfWriter{}
+	pagesObj := p.reserve()
+	f1 := d.fonts[0].write(p)
+	f2 := f1
+	if d.fonts[1] != d.fonts[0] {
+		f2 = d.fonts[1].write(p)
+	}
+	resources := fmt.Sprintf("<< /Font << /F1 %d 0 R /F2 %d 0 R >> >>", f1, f2)
+
+	var kids []string
+	for _, content := range d.pages {
+		c := p.addStream("", content.Bytes())
+		page := p.add(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %g %g] /Resources %s /Contents %d 0 R >>",
+			pagesObj, pdfPageWidth, pdfPageHeight, resources, c))
+		kids = append(kids, strconv.Itoa(page)+" 0 R")
+	}
+	p.set(pagesObj, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids)))
+	catalog := p.add(fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesObj))
+	info := p.add("<< /Title " + pdfTextString(d.Title) + " /Producer " + pdfTextString(cfg.Site.Name) + " >>")
+	return p.bytes(catalog, info)
+}
+
+// pdfTextString encodes s as a UTF-16 PDF text string for metadata
+func pdfTextString(s string) string {
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, r := range s {
+		b.WriteString(utf16Hex(r))
+	}
+	b.WriteByte('>')
+	return b.String()
+}
+
+// wrapText breaks s into lines no wider than width. Lines break at spaces;
+// a run too long for one line, such as Thai written without spaces, is split
+// between characters but never before a combining mark.
+func wrapText(f pdfFont, s string, size, width float64) []string {
+	var lines []string
+	for _, para := range strings.Split(s, "\n") {
+		line := ""
+		for _, word := range strings.Fields(para) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if f.width(candidate, size) <= width {
+				line = candidate
+				continue
+			}
+			if line != "" {
+				lines = append(lines, line)
+				line = ""
+			}
+			for f.width(word, size) > width {
+				cut := splitPoint(f, word, size, width)
+				lines = append(lines, word[:cut])
+				word = word[cut:]
+			}
+			line = word
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// splitPoint returns the byte index of the longest prefix of word that fits in width
+func splitPoint(f pdfFont, word string, size, width float64) int {
+	cut := 0
+	for i, r := range word {
+		if i > 0 && !unicode.Is(unicode.Mn, r) {
+			if f.width(word[:i], size) > width {
+				break
+			}
+			cut = i
+		}
+	}
+	if cut == 0 {
+		// Not even one character fits; take it anyway so layout makes progress
+		for i := range word {
+			if i > 0 {
+				return i
+			}
+		}
+		return len(word)
+	}
+	return cut
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func TestHelveticaFont(t *testing.T) {
+	f := helveticaFont{}
+	if got := f.encode(`a(b)\c – สวัสดี`); got != `(a\(b\)\\c - ??????)` {
+		t.Errorf("encode = %q", got)
+	}
+	// "Hi" is H (722) + i (222) thousandths of an em
+	if got := f.width("Hi", 10); got != 9.44 {
+		t.Errorf("width = %v, want 9.44", got)
+	}
+	if bold := (helveticaFont{bold: true}).width("Hi", 10); bold <= f.width("Hi", 10) {
+		t.Errorf("bold should be wider, got %v", bold)
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	f := helveticaFont{}
+	tests := []struct {
+		name  string
+		text  string
+		width float64
+		want  []string
+	}{
+		{"fits", "short line", 200, []string{"short line"}},
+		{"breaks at spaces", "one two three", 40, []string{"one two", "three"}},
+		{"keeps paragraphs", "a\nb", 200, []string{"a", "b"}},
+		{"splits long words", "abcdefghij", 20, []string{"abc", "defg", "hij"}},
+	}
+	for _, tt := range tests {
+		got := wrapText(f, tt.text, 10, tt.width)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%s: wrapText = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// A Thai vowel mark must stay with the consonant before it
+	for _, line := range wrapText(f, strings.Repeat("กี", 20), 10, 30) {
+		if strings.HasPrefix(line, "ี") {
+			t.Errorf("line starts with a combining mark: %q", line)
+		}
+	}
+}
+
+func TestPDFDocument(t *testing.T) {
+	d := NewPDFDocument("Test", helveticaFont{}, helveticaFont{bold: true})
+	for i := 0; i < 80; i++ {
+		d.Text("Line "+strconv.Itoa(i), 12, i%2 == 0, 0)
+	}
+	out := d.Bytes()
+
+	if !bytes.HasPrefix(out, []byte("%PDF-1.4")) || !bytes.HasSuffix(out, []byte("%%EOF\n")) {
+		t.Fatal("missing PDF header or trailer")
+	}
+	// 45 lines of 12pt text fit on an A4 page
+	if n := bytes.Count(out, []byte("/Type /Page ")); n != 2 {
+		t.Errorf("expected 80 lines to take 2 pages, got %d", n)
+	}
+
+	// Every xref entry must point at the start of its object
+	xref := regexp.MustCompile(`(\d{10}) 00000 n`).FindAllSubmatch(out, -1)
+	for i, m := range xref {
+		off, _ := strconv.Atoi(string(m[1]))
+		if want := strconv.Itoa(i+1) + " 0 obj"; !bytes.HasPrefix(out[off:], []byte(want)) {
+			t.Errorf("xref entry %d points at %q", i+1, out[off:off+10])
+		}
+	}
+}
+
+func TestTrueTypeFont(t *testing.T) {
+	f, err := newTrueTypeFont(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := f.encode("Hi")
+	if !regexp.MustCompile(`^<[0-9A-F]{8}>$`).MatchString(enc) {
+		t.Errorf("encode = %q, want two hex glyph IDs", enc)
+	}
+	if w := f.width("Hi", 10); w <= 0 {
+		t.Errorf("width = %v", w)
+	}
+
+	out := NewPDFDocument("Test", f, f)
+	out.Text("Hi", 12, false, 0)
+	pdf := out.Bytes()
+	for _, want := range []string{"/Subtype /Type0", "/Encoding /Identity-H", "/FontFile2", "/ToUnicode"} {
+		if !bytes.Contains(pdf, []byte(want)) {
+			t.Errorf("PDF missing %s", want)
+		}
+	}
+	if n := bytes.Count(pdf, []byte("/FontFile2")); n != 1 {
+		t.Errorf("font shared by regular and bold should be embedded once, got %d", n)
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	shortcodes["playground"] = playgroundShortcode
+}
+
+// maxSnippetBytes bounds what is read from a playground
+const maxSnippetBytes = 1 << 20
+
+var (
+	goPlayIDRegex   = regexp.MustCompile(`^[A-Za-z0-9_-]{6,40}$`)
+	jsFiddleIDRegex = regexp.MustCompile(`^(?:[A-Za-z0-9_-]+/)?[A-Za-z0-9]+(?:/[0-9]+)?$`)
+)
+
+// Snippet is a playground's code as last fetched
+type Snippet struct {
+	Kind    string    `json:"kind"`
+	ID      string    `json:"id"`
+	Lang    string    `json:"lang"`
+	Code    string    `json:"code"`
+	Fetched time.Time `json:"fetched"`
+}
+
+// PlaygroundProxy fetches snippets from the Go Playground and JSFiddle on the
+// server and keeps them in Dir, so pages show the code without readers
+// contacting the third party, and keep showing it when it is unreachable.
+// Snippets older than Refresh are served as they are and fetched again in
+// the background.
+type PlaygroundProxy struct {
+	Dir     string
+	Refresh time.Duration
+	Client  *http.Client
+	// GoBase and JSFiddleBase are the playgrounds' origins
+	GoBase       string
+	JSFiddleBase string
+
+	mu       sync.Mutex
+	fetching map[string]bool
+}
+
+// playground serves the playground shortcode. main rebuilds it from the loaded
+// config; until then it uses the defaults.
+var playground = newPlaygroundProxy(defaultConfig().Playground)
+
+// newPlaygroundProxy returns a proxy for the real playgrounds described by c
+func newPlaygroundProxy(c PlaygroundConfig) *PlaygroundProxy {
+	return &PlaygroundProxy{
+		Dir:          c.CacheDir,
+		Refresh:      c.Refresh,
+		Client:       &http.Client{Timeout: 5 * time.Second},
+		GoBase:       "https://go.dev",
+		JSFiddleBase: "https://jsfiddle.net",
+	}
+}
+
+// playgroundURL is where readers open a snippet to run or edit it
+func (p *PlaygroundProxy) playgroundURL(kind, id string) string {
+	if kind == "go" {
+		return p.GoBase + "/play/p/" + id
+	}
+	return p.JSFiddleBase + "/" + id + "/"
+}
+
+// sourceURL is where a snippet's code is fetched from: the Go Playground's
+// raw source, or the fiddle's result page, which holds its HTML, CSS, and
+// JavaScript
+func (p *PlaygroundProxy) sourceURL(kind, id string) string {
+	if kind == "go" {
+		return p.GoBase + "/play/p/" + id + ".go"
+	}
+	return p.JSFiddleBase + "/" + id + "/show/light/"
+}
+
+// cachePath returns the file a snippet is kept in
+func (p *PlaygroundProxy) cachePath(kind, id string) string {
+	return filepath.Join(p.Dir, kind, strings.ReplaceAll(id, "/", "_")+".json")
+}
+
+// Snippet returns the snippet, from the cache when it has one. Only a snippet
+// never fetched before waits for the playground; an error means it couldn't
+// be fetched and nothing is cached.
+func (p *PlaygroundProxy) Snippet(ctx context.Context, kind, id string) (Snippet, error) {
+	if cached, err := p.cached(kind, id); err == nil {
+		if time.Since(cached.Fetched) >= p.Refresh {
+			p.refreshInBackground(kind, id)
+		}
+		return cached, nil
+	}
+	return p.fetch(ctx, kind, id)
+}
+
+// cached reads a snippet from the cache
+func (p *PlaygroundProxy) cached(kind, id string) (Snippet, error) {
+	b, err := os.ReadFile(p.cachePath(kind, id))
+	if err != nil {
+		return Snippet{}, err
+	}
+	var s Snippet
+	if err := json.Unmarshal(b, &s); err != nil {
+		return Snippet{}, err
+	}
+	return s, nil
+}
+
+// refreshInBackground fetches a stale snippet again, once at a time; the
+// cached copy stays in place if that fails
+func (p *PlaygroundProxy) refreshInBackground(kind, id string) {
+	key := kind + "/" + id
+	p.mu.Lock()
+	if p.fetching == nil {
+		p.fetching = make(map[string]bool)
+	}
+	if p.fetching[key] {
+		p.mu.Unlock()
+		return
+	}
+	p.fetching[key] = true
+	p.mu.Unlock()
+
+	go func() {
+		defer func() {
+			p.mu.Lock()
+			delete(p.fetching, key)
+			p.mu.Unlock()
+		}()
+		if _, err := p.fetch(context.Background(), kind, id); err != nil {
+			slog.Warn("Could not refresh playground snippet, keeping the cached copy", "kind", kind, "id", id, "err", err)
+		}
+	}()
+}
+
+// fetch gets a snippet from its playground and caches it
+func (p *PlaygroundProxy) fetch(ctx context.Context, kind, id string) (Snippet, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.sourceURL(kind, id), nil)
+	if err != nil {
+		return Snippet{}, err
+	}
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return Snippet{}, fmt.Errorf("fetch %s snippet %s: %w", kind, id, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Snippet{}, fmt.Errorf("fetch %s snippet %s: %s", kind, id, resp.Status)
+	}
+	code, err := io.ReadAll(io.LimitReader(resp.Body, maxSnippetBytes))
+	if err != nil {
+		return Snippet{}, fmt.Errorf("fetch %s snippet %s: %w", kind, id, err)
+	}
+
+	s := Snippet{Kind: kind, ID: id, Lang: "go", Code: strings.TrimSpace(string(code)), Fetched: time.Now().UTC()}
+	if kind == "jsfiddle" {
+		s.Lang = "html"
+	}
+	b, err := json.Marshal(s)
+	if err == nil {
+		path := p.cachePath(kind, id)
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err == nil {
+			err = os.WriteFile(path, b, 0644)
+		}
+	}
+	if err != nil {
+		slog.Error("Error caching playground snippet", "kind", kind, "id", id, "err", err)
+	}
+	return s, nil
+}
+
+// codeBlockHTML renders code as a fenced block through the markdown
+// converter, so it's highlighted like the post's own code
+func codeBlockHTML(lang, code string) (string, error) {
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(fence+lang+"\n"+code+"\n"+fence+"\n"), &buf); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// playgroundShortcode renders {{< playground go ID [title="..."] >}} or
+// {{< playground jsfiddle user/ID [title="..."] >}} as the snippet's code with
+// a link to run it. When the playground can't be reached and the snippet was
+// never cached, a note takes the code's place.
+func playgroundShortcode(call ShortcodeCall) (string, error) {
+	kind, id := call.Arg(0), call.Arg(1)
+	label := ""
+	switch kind {
+	case "go":
+		if !goPlayIDRegex.MatchString(id) {
+			return "", fmt.Errorf("invalid Go Playground ID %q", id)
+		}
+		label = "Run in the Go Playground"
+	case "jsfiddle":
+		if !jsFiddleIDRegex.MatchString(id) {
+			return "", fmt.Errorf("invalid JSFiddle ID %q", id)
+		}
+		label = "Edit on JSFiddle"
+	default:
+		return "", fmt.Errorf("unknown playground %q, want go or jsfiddle", kind)
+	}
+
+	link := "<a href=\"" + template.HTMLEscapeString(playground.playgroundURL(kind, id)) + "\">" + label + "</a>"
+	var b strings.Builder
+	b.WriteString("<figure class=\"playground playground-" + kind + "\">\n")
+	if s, err := playground.Snippet(context.Background(), kind, id); err != nil {
+		slog.Warn("Playground snippet unavailable", "slug", call.Slug, "kind", kind, "id", id, "err", err)
+		b.WriteString("<p class=\"playground-unavailable\">This snippet couldn't be loaded here.</p>\n")
+	} else {
+		code, err := codeBlockHTML(s.Lang, s.Code)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(code)
+	}
+	b.WriteString("<figcaption>")
+	if title := call.Params["title"]; title != "" {
+		b.WriteString(template.HTMLEscapeString(title) + " · ")
+	}
+	b.WriteString(link + "</figcaption>\n</figure>")
+	return b.String(), nil
+}
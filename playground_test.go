@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestPlayground points the playground shortcode at a fake playground
+// serving Go source, and returns how many fetches it answered
+func newTestPlayground(t *testing.T) (*PlaygroundProxy, *httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		switch r.URL.Path {
+		case "/play/p/hello123.go":
+			w.Write([]byte("package main\n\nfunc main() { println(\"<hi>\") }\n"))
+		case "/alice/abc12/show/light/":
+			w.Write([]byte("<button>Click</button>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	p := newPlaygroundProxy(PlaygroundConfig{CacheDir: t.TempDir(), Refresh: time.Hour})
+	p.GoBase, p.JSFiddleBase = srv.URL, srv.URL
+	old := playground
+	playground = p
+	t.Cleanup(func() { playground = old })
+	return p, srv, &fetches
+}
+
+func TestPlaygroundShortcode(t *testing.T) {
+	_, srv, _ := newTestPlayground(t)
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{
+			`{{< playground go hello123 title="Hello" >}}`,
+			[]string{`<figure class="playground playground-go">`, "package", "&lt;hi&gt;", "Hello · ", `href="` + srv.URL + `/play/p/hello123">Run in the Go Playground`},
+		},
+		{
+			`{{< playground jsfiddle alice/abc12 >}}`,
+			[]string{`<span class="nt">button</span>`, `href="` + srv.URL + `/alice/abc12/">Edit on JSFiddle`},
+		},
+		{
+			`{{< playground go missing1 >}}`,
+			[]string{"couldn't be loaded", "/play/p/missing1"},
+		},
+		{`{{< playground go ../etc >}}`, []string{"<!-- shortcode playground failed -->"}},
+		{`{{< playground codepen abc >}}`, []string{"<!-- shortcode playground failed -->"}},
+	}
+	for _, tt := range tests {
+		html, err := renderMarkdown(tt.src, "post")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(html, want) {
+				t.Errorf("%s: output missing %q:\n%s", tt.src, want, html)
+			}
+		}
+	}
+}
+
+func TestPlaygroundProxyCache(t *testing.T) {
+	p, srv, fetches := newTestPlayground(t)
+	ctx := context.Background()
+
+	first, err := p.Snippet(ctx, "go", "hello123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Snippet(ctx, "go", "hello123"); err != nil || fetches.Load() != 1 {
+		t.Fatalf("fresh snippet fetched again: %d fetches, err %v", fetches.Load(), err)
+	}
+
+	// With the playground down, even a stale copy is served
+	srv.Close()
+	p.Refresh = time.Nanosecond
+	got, err := p.Snippet(ctx, "go", "hello123")
+	if err != nil || got.Code != first.Code {
+		t.Errorf("stale snippet = %+v, %v", got, err)
+	}
+	if _, err := p.Snippet(ctx, "go", "never000"); err == nil {
+		t.Error("uncached snippet with the playground down: no error")
+	}
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		p.mu.Lock()
+		n := len(p.fetching)
+		p.mu.Unlock()
+		if n == 0 || time.Now().After(deadline) {
+			break
+		}
+	}
+}
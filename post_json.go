@@ -0,0 +1,232 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+// PostJSON is the structured form of a post served at /posts/{slug}.json
+type PostJSON struct {
+	Slug               string   `json:"slug"`
+	Title              string   `json:"title"`
+	Date               string   `json:"date,omitempty"`
+	Lang               string   `json:"lang,omitempty"`
+	Tags               []string `json:"tags"`
+	Category           string   `json:"category,omitempty"`
+	Audio              string   `json:"audio,omitempty"`
+	ReadingTimeMinutes int      `json:"reading_time_minutes"`
+	URL                string   `json:"url"`
+	// ExternalURL is the page a link post points at
+	ExternalURL  string            `json:"external_url,omitempty"`
+	HTML         string            `json:"html"`
+	Translations []PostTranslation `json:"translations"`
+}
+
+// PostTranslation points at the same post in another language
+type PostTranslation struct {
+	Lang string `json:"lang"`
+	Slug string `json:"slug"`
+	URL  string `json:"url"`
+}
+
+// postLangs are the languages posts can be written in, identified by slug prefix
+var postLangs = []string{"th", "en"}
+
+// postLang returns the language prefix of a slug and the slug without it
+func postLang(slug string) (string, string) {
+	for _, lang := range postLangs {
+		if rest, ok := strings.CutPrefix(slug, lang+"-"); ok {
+			return lang, rest
+		}
+	}
+	return "", slug
+}
+
+// translationFinder knows which posts translate each other, as PostIndex does
+type translationFinder interface {
+	Translations(slug string) []PostTranslation
+}
+
+// postTranslations finds a post's published translations: those the index
+// linked to it, or, without an index, posts with the same slug under another
+// language prefix
+func postTranslations(sl SlugReader, slug string) []PostTranslation {
+	if idx, ok := sl.(translationFinder); ok {
+		return idx.Translations(slug)
+	}
+	lang, base := postLang(slug)
+	translations := []PostTranslation{}
+	if lang == "" {
+		return translations
+	}
+	for _, other := range postLangs {
+		if other == lang {
+			continue
+		}
+		src, err := sl.Read(other + "-" + base)
+		if err != nil {
+			continue
+		}
+		// A translation that is scheduled or a draft isn't out yet
+		if fm, _ := ParseFrontmatter(src); postVisible(fm) {
+			translations = append(translations, PostTranslation{Lang: other, Slug: other + "-" + base, URL: postTreePath(other+"-"+base, other)})
+		}
+	}
+	return translations
+}
+
+// readingTime estimates minutes to read text
+func readingTime(text, lang string) int {
+	return readingMinutes(wordCount(text, lang))
+}
+
+// readingMinutes is how long words take to read at 200 words per minute,
+// rounded up
+func readingMinutes(words int) int {
+	return max(1, (words+199)/200)
+}
+
+// wordCount counts the words in text. Thai is written without spaces: in
+// Thai text (lang th, or detected when lang is empty) it is split into
+// dictionary words, and otherwise, as with stretches the dictionary doesn't
+// know, counted as a word every six characters.
+func wordCount(text, lang string) int {
+	if lang == "" {
+		lang = detectLang(text)
+	}
+	words, thai := 0, 0
+	for _, field := range strings.Fields(text) {
+		latin := false
+		var run []rune
+		for _, r := range field {
+			if isThai(r) {
+				run = append(run, r)
+				continue
+			}
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				latin = true
+			}
+			words, thai = countThaiWords(run, lang, words, thai)
+			run = run[:0]
+		}
+		words, thai = countThaiWords(run, lang, words, thai)
+		if latin {
+			words++
+		}
+	}
+	return words + thai/6
+}
+
+// countThaiWords adds a run of Thai to wordCount's counts: dictionary words
+// to words, and the characters left over to thai
+func countThaiWords(run []rune, lang string, words, thai int) (int, int) {
+	if len(run) == 0 {
+		return words, thai
+	}
+	if lang != "th" {
+		return words, thai + len(run)
+	}
+	for _, word := range thaiWords.Segment(string(run)) {
+		if thaiWords.words[word] {
+			words++
+		} else {
+			thai += len([]rune(word))
+		}
+	}
+	return words, thai
+}
+
+// PostJSONHandler serves a post as JSON for lightweight clients
+func PostJSONHandler(sl SlugReader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setSecurityHeaders(w)
+
+		slug := r.PathValue("slug")
+		if !IsValidSlug(slug) {
+			http.Error(w, "Invalid post slug", http.StatusBadRequest)
+			return
+		}
+
+		postMarkdown, err := sl.Read(slug)
+		if err != nil {
+			http.Error(w, "Post not found", http.StatusNotFound)
+			return
+		}
+
+		fm, markdownContent := ParseFrontmatter(postMarkdown)
+		if !postVisible(fm) {
+			http.Error(w, "Post not found", http.StatusNotFound)
+			return
+		}
+		html, err := renderMarkdown(markdownContent, slug)
+		if err != nil {
+			slog.Error("Error rendering post", "slug", slug, "err", err)
+			http.Error(w, "Error rendering post", http.StatusInternalServerError)
+			return
+		}
+
+		post := PostJSON{
+			Slug:               slug,
+			Title:              fm.Title,
+			Tags:               fm.Tags,
+			Category:           strings.TrimSpace(fm.Category),
+			Audio:              audioSrc(fm.Audio, slug, "audio"),
+			ReadingTimeMinutes: readingTime(plainText(html), postFileLang(slug, fm)),
+			Lang:               postFileLang(slug, fm),
+			ExternalURL:        linkPostURL(fm.Link),
+			HTML:               html,
+			Translations:       postTranslations(sl, slug),
+		}
+		post.URL = postTreePath(slug, post.Lang)
+		if post.Title == "" {
+			post.Title = toTitleCase(strings.ReplaceAll(slug, "-", " "))
+		}
+		if post.Tags == nil {
+			post.Tags = []string{}
+		}
+		if t, ok := parsePostDate(fm.Date); ok {
+			post.Date = t.Format("2006-01-02")
+		}
+
+		body, err := json.Marshal(post)
+		if err != nil {
+			slog.Error("Error encoding post", "slug", slug, "err", err)
+			http.Error(w, "Error rendering post", http.StatusInternalServerError)
+			return
+		}
+
+		sum := sha256.Sum256(body)
+		etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write(body)
+	}
+}
+
+// postFormats routes /posts/{slug}.ext to the handler for that extension, with the
+// extension removed from the slug path value, and everything else to page
+func postFormats(page http.HandlerFunc, formats map[string]http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slug := r.PathValue("slug")
+		for ext, h := range formats {
+			if base, ok := strings.CutSuffix(slug, ext); ok {
+				r.SetPathValue("slug", base)
+				h(w, r)
+				return
+			}
+		}
+		page(w, r)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPostTranslationsPublishedOnly(t *testing.T) {
+	soon := time.Now().Add(time.Hour).Format(time.RFC3339)
+	reader := &MockSlugReader{content: map[string]string{
+		"en-a": "A", "th-a": "---\ndate: " + soon + "\n---\nเร็วๆ นี้",
+		"en-b": "B", "th-b": "---\ndraft: true\n---\nร่าง",
+	}}
+	for _, slug := range []string{"en-a", "en-b"} {
+		if got := postTranslations(reader, slug); len(got) != 0 {
+			t.Errorf("%s: translations = %+v, want the unpublished one left out", slug, got)
+		}
+	}
+}
+
+func TestPostJSONHandler(t *testing.T) {
+	reader := &MockSlugReader{content: map[string]string{
+		"en-hello": "---\ntitle: Hello\ndate: 2026-01-15\ntags: [go, web]\n---\n\nSome **bold** words.",
+		"th-hello": "สวัสดี",
+	}}
+	handler := postFormats(PostHandler(reader, nil, nil), map[string]http.HandlerFunc{".json": PostJSONHandler(reader)})
+
+	req := httptest.NewRequest("GET", "/posts/en-hello.json", nil)
+	req.SetPathValue("slug", "en-hello.json")
+	w := httptest.NewRecorder()
+	handler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("unexpected Content-Type %q", ct)
+	}
+	if w.Header().Get("Cache-Control") == "" || w.Header().Get("ETag") == "" {
+		t.Errorf("expected caching headers, got %v", w.Header())
+	}
+
+	var post PostJSON
+	if err := json.Unmarshal(w.Body.Bytes(), &post); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if post.Title != "Hello" || post.Date != "2026-01-15" || post.Lang != "en" || post.ReadingTimeMinutes != 1 {
+		t.Errorf("unexpected post %+v", post)
+	}
+	if strings.Join(post.Tags, ",") != "go,web" || !strings.Contains(post.HTML, "<strong>bold</strong>") {
+		t.Errorf("unexpected tags or HTML in %+v", post)
+	}
+	if len(post.Translations) != 1 || post.Translations[0].Slug != "th-hello" {
+		t.Errorf("expected the Thai translation, got %+v", post.Translations)
+	}
+
+	// A matching ETag gets an empty 304
+	again := httptest.NewRequest("GET", "/posts/en-hello.json", nil)
+	again.SetPathValue("slug", "en-hello.json")
+	again.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	handler(w, again)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("expected empty 304, got %d with %d bytes", w.Code, w.Body.Len())
+	}
+
+	missing := httptest.NewRequest("GET", "/posts/nope.json", nil)
+	missing.SetPathValue("slug", "nope.json")
+	w = httptest.NewRecorder()
+	handler(w, missing)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+}
+
+func TestReadingTime(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want int
+	}{
+		{"empty", "", 1},
+		{"short", "a few words", 1},
+		{"400 words", strings.Repeat("word ", 400), 2},
+		{"401 words", strings.Repeat("word ", 401), 3},
+		{"thai", strings.Repeat("ภาษาไทย", 300), 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := readingTime(tt.text, ""); got != tt.want {
+				t.Errorf("expected %d minutes, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestReadingTimeHTML(t *testing.T) {
+	tests := []struct {
+		words, minutes int
+		lang           string
+		want           string
+	}{
+		{1200, 6, "en", `<span class="reading-time">1200 words · 6 min read</span>`},
+		{1200, 6, "th", `<span class="reading-time">1200 คำ · อ่าน 6 นาที</span>`},
+		{1, 1, "en", `<span class="reading-time">1 word · 1 min read</span>`},
+		{0, 1, "en", ""},
+	}
+	for _, tt := range tests {
+		if got := readingTimeHTML(tt.words, tt.minutes, tt.lang); got != tt.want {
+			t.Errorf("readingTimeHTML(%d, %d, %s) = %q, want %q", tt.words, tt.minutes, tt.lang, got, tt.want)
+		}
+	}
+}
+
+func TestReadingTime_ThaiDictionary(t *testing.T) {
+	defer func(old *ThaiDictionary) { thaiWords = old }(thaiWords)
+	thaiWords = NewThaiDictionary([]string{"ภาษา", "ไทย"})
+
+	// 600 dictionary words, where six characters a word would make 350
+	text := strings.Repeat("ภาษาไทย", 300)
+	if got := readingTime(text, "th"); got != 3 {
+		t.Errorf("th: got %d minutes, want 3", got)
+	}
+	if got := readingTime(text, "en"); got != 2 {
+		t.Errorf("en: got %d minutes, want 2", got)
+	}
+}
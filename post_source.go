@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+)
+
+// PostSourceHandler serves the raw markdown of a post at /posts/{slug}.md, with
+// or without its frontmatter depending on config
+func PostSourceHandler(sl SlugReader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setSecurityHeaders(w)
+
+		if !cfg.MarkdownSource.Enabled {
+			http.NotFound(w, r)
+			return
+		}
+
+		slug := r.PathValue("slug")
+		if !IsValidSlug(slug) {
+			http.Error(w, "Invalid post slug", http.StatusBadRequest)
+			return
+		}
+
+		source, err := sl.Read(slug)
+		if err != nil {
+			http.Error(w, "Post not found", http.StatusNotFound)
+			return
+		}
+		fm, body := ParseFrontmatter(source)
+		if !postVisible(fm) {
+			http.Error(w, "Post not found", http.StatusNotFound)
+			return
+		}
+		if !cfg.MarkdownSource.Frontmatter {
+			source = body + "\n"
+		}
+
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		w.Write([]byte(source))
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPostSourceHandler(t *testing.T) {
+	reader := &MockSlugReader{content: map[string]string{
+		"hello": "---\ntitle: Hello\n---\n\n# Hello\n",
+	}}
+	orig := cfg.MarkdownSource
+	defer func() { cfg.MarkdownSource = orig }()
+
+	tests := []struct {
+		name       string
+		config     MarkdownSourceConfig
+		slug       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"with frontmatter", MarkdownSourceConfig{Enabled: true, Frontmatter: true}, "hello", http.StatusOK, "---\ntitle: Hello\n---\n\n# Hello\n"},
+		{"without frontmatter", MarkdownSourceConfig{Enabled: true}, "hello", http.StatusOK, "# Hello\n"},
+		{"disabled", MarkdownSourceConfig{}, "hello", http.StatusNotFound, ""},
+		{"missing post", MarkdownSourceConfig{Enabled: true}, "nope", http.StatusNotFound, ""},
+		{"invalid slug", MarkdownSourceConfig{Enabled: true}, "../main", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.MarkdownSource = tt.config
+			req := httptest.NewRequest("GET", "/posts/"+tt.slug+".md", nil)
+			req.SetPathValue("slug", tt.slug)
+			w := httptest.NewRecorder()
+			PostSourceHandler(reader)(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/markdown") {
+				t.Errorf("unexpected Content-Type %q", ct)
+			}
+			if w.Body.String() != tt.wantBody {
+				t.Errorf("expected body %q, got %q", tt.wantBody, w.Body.String())
+			}
+		})
+	}
+}
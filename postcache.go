@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// renderCacheTTL bounds how long rendered HTML is reused, so shortcodes that look
+// at other files (such as gallery) pick up changes
+const renderCacheTTL = 5 * time.Minute
+
+// maxRenderCacheEntries bounds the render cache; it is emptied when full
+const maxRenderCacheEntries = 1000
+
+type renderedMarkdown struct {
+	src      string
+	html     string
+	rendered time.Time
+}
+
+// renderCache remembers the HTML of recently rendered posts, keyed by slug and
+// checked against the markdown so edits are picked up immediately
+var renderCache = struct {
+	sync.Mutex
+	entries map[string]renderedMarkdown
+}{entries: make(map[string]renderedMarkdown)}
+
+// renderMarkdownCached is renderMarkdown, reusing the last result for unchanged posts
+func renderMarkdownCached(src, slug string) (string, error) {
+	renderCache.Lock()
+	e, ok := renderCache.entries[slug]
+	renderCache.Unlock()
+	if ok && e.src == src && time.Since(e.rendered) < renderCacheTTL {
+		metrics.Inc("render_cache_hits_total")
+		return e.html, nil
+	}
+
+	metrics.Inc("render_cache_misses_total")
+	html, err := renderMarkdown(src, slug)
+	if err != nil {
+		return "", err
+	}
+	renderCache.Lock()
+	if len(renderCache.entries) >= maxRenderCacheEntries {
+		clear(renderCache.entries)
+	}
+	renderCache.entries[slug] = renderedMarkdown{src: src, html: html, rendered: time.Now()}
+	renderCache.Unlock()
+	return html, nil
+}
+
+// CacheStats describes the in-memory caches
+type CacheStats struct {
+	RenderEntries    int    `json:"render_entries"`
+	RenderMaxEntries int    `json:"render_max_entries"`
+	RenderHits       uint64 `json:"render_hits"`
+	RenderMisses     uint64 `json:"render_misses"`
+	// PageVersions counts the pages Conditional remembers ETags for
+	PageVersions int `json:"page_versions"`
+}
+
+// collectCacheStats reads the current cache sizes and hit counts
+func collectCacheStats() CacheStats {
+	st := CacheStats{
+		RenderMaxEntries: maxRenderCacheEntries,
+		RenderHits:       metrics.Counter("render_cache_hits_total"),
+		RenderMisses:     metrics.Counter("render_cache_misses_total"),
+	}
+	renderCache.Lock()
+	st.RenderEntries = len(renderCache.entries)
+	renderCache.Unlock()
+	pageVersions.Lock()
+	st.PageVersions = len(pageVersions.entries)
+	pageVersions.Unlock()
+	return st
+}
+
+// CacheStatsHandler serves /debug/cache: how full the caches are and how often
+// rendered posts were reused since the server started
+func CacheStatsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(collectCacheStats()); err != nil {
+		slog.Error("Error encoding cache stats", "err", err)
+	}
+}
+
+// bufferPool recycles the buffers pages are assembled in
+var bufferPool = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	// Don't hold on to the occasional huge page
+	if buf.Cap() <= 1<<20 {
+		bufferPool.Put(buf)
+	}
+}
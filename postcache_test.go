@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRenderMarkdownCached(t *testing.T) {
+	html, err := renderMarkdownCached("**one**", "cache-test")
+	if err != nil || !strings.Contains(html, "<strong>one</strong>") {
+		t.Fatalf("got %q, %v", html, err)
+	}
+	// Same slug with edited markdown renders again
+	html, _ = renderMarkdownCached("**two**", "cache-test")
+	if !strings.Contains(html, "<strong>two</strong>") {
+		t.Errorf("stale render: %q", html)
+	}
+
+	hits := metrics.counters["render_cache_hits_total"]
+	renderMarkdownCached("**two**", "cache-test")
+	if metrics.counters["render_cache_hits_total"] != hits+1 {
+		t.Error("unchanged markdown was rendered again")
+	}
+}
+
+func TestCacheStatsHandler(t *testing.T) {
+	renderMarkdownCached("**stats**", "cache-stats-test")
+	renderMarkdownCached("**stats**", "cache-stats-test")
+
+	w := httptest.NewRecorder()
+	CacheStatsHandler(w, httptest.NewRequest("GET", "/debug/cache", nil))
+	var st CacheStats
+	if err := json.Unmarshal(w.Body.Bytes(), &st); err != nil {
+		t.Fatalf("decode %s: %v", w.Body, err)
+	}
+	if st.RenderEntries == 0 || st.RenderHits == 0 || st.RenderMisses == 0 || st.RenderMaxEntries != maxRenderCacheEntries {
+		t.Errorf("stats = %+v", st)
+	}
+}
@@ -0,0 +1,474 @@
+package main
+
+import (
+	"context"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// postIndexDebounce is how long the index waits after a change in the posts
+// directory before rescanning, so a burst of writes costs one scan
+const postIndexDebounce = 100 * time.Millisecond
+
+// indexedPost is a post file as the index holds it
+type indexedPost struct {
+	Post
+	FM      PostFrontmatter
+	Content string
+	// fileLang is the language the frontmatter or file name gives, before
+	// translations are taken into account
+	fileLang string
+	// links are the slugs the post links to with [[slug]]
+	links   []string
+	size    int64
+	modTime time.Time
+}
+
+// PostIndex holds every post in memory: the raw markdown, the parsed
+// frontmatter, and the list of published posts. It scans its store once when
+// created and again when Watch sees files change, so handlers never read posts
+// from the store. It implements PostFiles and can stand in for a FileReader.
+type PostIndex struct {
+	store PostStore
+	// dir names the posts in messages, and is the directory watched for
+	// stores kept on disk
+	dir string
+
+	// scanMu serializes scans and writes so neither loses the other's changes
+	scanMu sync.Mutex
+
+	mu          sync.RWMutex
+	entries     map[string]*indexedPost
+	slugs       []string
+	published   []Post
+	nextPublish time.Time
+	// version counts changes to the posts, for caches built from them
+	version uint64
+	// lastScan is when the directory was last read successfully
+	lastScan time.Time
+	// collisions are the addresses more than one post answers to
+	collisions []SlugCollision
+	// oversized are the files over max_post_kb, left unread
+	oversized map[string]*indexedPost
+	// backlinks maps each post to the posts linking to it with [[slug]]
+	backlinks map[string][]string
+	// brokenLinks are the [[slug]] links that match no post
+	brokenLinks []BrokenLink
+	// graph is how the published posts connect through links and tags
+	graph *ContentGraph
+	// aliases maps the old addresses listed in posts' aliases to the posts
+	aliases map[string]string
+}
+
+// NewPostIndex scans dir and returns its index
+func NewPostIndex(dir string) (*PostIndex, error) {
+	return NewStoreIndex(&FileReader{Dir: dir})
+}
+
+// NewStoreIndex scans store and returns its index
+func NewStoreIndex(store PostStore) (*PostIndex, error) {
+	idx := &PostIndex{store: store, dir: "posts", entries: make(map[string]*indexedPost)}
+	if d, ok := store.(storeDir); ok {
+		idx.dir = d.LocalDir()
+	}
+	if err := idx.Scan(); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// newIndexedPost parses a post file. Posts without a date are dated by the file's
+// modification time, and untitled posts are titled from their slug.
+func newIndexedPost(slug, content string, info PostInfo) *indexedPost {
+	fm, body := ParseFrontmatter(content)
+	p := &indexedPost{
+		Post:     Post{Slug: slug, Link: linkPostURL(fm.Link), Tags: fm.Tags, Category: strings.TrimSpace(fm.Category), Lang: postFileLang(slug, fm)},
+		FM:       fm,
+		Content:  content,
+		fileLang: postFileLang(slug, fm),
+		size:     info.Size,
+		modTime:  info.ModTime,
+	}
+
+	// Use frontmatter title or generate from slug
+	if fm.Title != "" {
+		p.Title = fm.Title
+	} else {
+		// Remove language prefix for display
+		p.Title = toTitleCase(strings.ReplaceAll(localSlug(slug, p.Lang), "-", " "))
+	}
+
+	if t, ok := parsePostDate(fm.Date); ok {
+		p.Date = t
+	} else {
+		p.Date = info.ModTime
+	}
+	p.DateStr = p.Date.Format("Jan 2, 2006")
+	if !postSealed(content) {
+		p.WordCount = wordCount(markdownText(body), p.fileLang)
+		p.ReadingTime = readingMinutes(p.WordCount)
+		p.links = wikiLinkTargets(body)
+	}
+	return p
+}
+
+// Scan rereads the store. Files whose size and modification time are
+// unchanged since the last scan are not read again.
+func (idx *PostIndex) Scan() error {
+	idx.scanMu.Lock()
+	defer idx.scanMu.Unlock()
+
+	slugs, err := idx.store.List()
+	if err != nil {
+		return err
+	}
+	metrics.Inc("post_index_scans_total")
+	idx.mu.Lock()
+	idx.lastScan = time.Now()
+	idx.mu.Unlock()
+
+	// Only scans and writes change the entries, so they can be read unlocked here
+	old := idx.entries
+
+	entries := make(map[string]*indexedPost, len(slugs))
+	oversized := make(map[string]*indexedPost)
+	changed := idx.published == nil
+	for _, slug := range slugs {
+		name := slug + ".md"
+		info, err := idx.store.Stat(slug)
+		if err != nil {
+			// Removed since the store was listed
+			continue
+		}
+		if postTooLarge(info.Size) {
+			p := &indexedPost{Post: Post{Slug: slug}, size: info.Size, modTime: info.ModTime}
+			if o, ok := idx.oversized[slug]; !ok || o.size != p.size || !o.modTime.Equal(p.modTime) {
+				slog.Warn("Post too large, not serving it", "file", name, "kb", info.Size>>10, "max_kb", cfg.MaxPostKB)
+			}
+			oversized[slug] = p
+			continue
+		}
+		if p, ok := old[slug]; ok && p.size == info.Size && p.modTime.Equal(info.ModTime) {
+			entries[slug] = p
+			continue
+		}
+		content, err := idx.store.Read(slug)
+		if err != nil {
+			slog.Error("Error reading post", "file", name, "err", err)
+			continue
+		}
+		p := newIndexedPost(slug, content, info)
+		preparePostPlaceholders(slug, content)
+		if p.FM.Private && postKey != nil && !postSealed(p.Content) {
+			slog.Warn("Private post is stored unencrypted; save it in the editor to encrypt it", "file", name)
+		}
+		entries[slug] = p
+		changed = true
+	}
+	idx.mu.Lock()
+	idx.oversized = oversized
+	idx.mu.Unlock()
+	if !changed && len(entries) == len(old) {
+		return nil
+	}
+
+	idx.mu.Lock()
+	idx.entries = entries
+	idx.update(time.Now())
+	collisions, broken := idx.collisions, idx.brokenLinks
+	idx.mu.Unlock()
+	for _, c := range collisions {
+		slog.Warn("Slug collision", "path", c.Path, "files", c.Files)
+	}
+	for _, l := range broken {
+		slog.Warn("Broken post link", "slug", l.Slug, "target", l.Target)
+	}
+	return nil
+}
+
+// update rebuilds the slug list and the published list from the entries. The
+// caller must hold the write lock.
+func (idx *PostIndex) update(now time.Time) {
+	idx.linkTranslations()
+	idx.slugs = idx.slugs[:0]
+	published := []Post{}
+	var next time.Time
+	for slug, p := range idx.entries {
+		idx.slugs = append(idx.slugs, slug)
+		if p.FM.Draft || p.FM.Private {
+			continue
+		}
+		if isScheduled(p.FM, now) {
+			if next.IsZero() || p.Date.Before(next) {
+				next = p.Date
+			}
+			continue
+		}
+		published = append(published, p.Post)
+	}
+	sort.Strings(idx.slugs)
+	// Sort posts by date (newest first)
+	sort.Slice(published, func(i, j int) bool {
+		if !published[i].Date.Equal(published[j].Date) {
+			return published[i].Date.After(published[j].Date)
+		}
+		return published[i].Slug < published[j].Slug
+	})
+	idx.published, idx.nextPublish = published, next
+	idx.aliases = idx.findAliases()
+	idx.collisions = idx.findCollisions()
+	idx.backlinks, idx.brokenLinks = idx.findCrossRefs()
+	idx.graph = idx.buildGraph()
+	idx.version++
+}
+
+// linkTranslations groups posts with their translations. A post in a group is in
+// one language even without lang frontmatter or a prefix: it's detected from
+// the text. The caller must hold the write lock.
+func (idx *PostIndex) linkTranslations() {
+	targets := make(map[string]bool)
+	for _, p := range idx.entries {
+		if p.FM.TranslationOf != "" {
+			targets[p.FM.TranslationOf] = true
+		}
+	}
+	for slug, p := range idx.entries {
+		lang, translation := p.fileLang, ""
+		if lang == "" && (p.FM.TranslationOf != "" || targets[slug]) {
+			_, body := ParseFrontmatter(p.Content)
+			lang = detectLang(body)
+		}
+		if lang != "" {
+			// Translations share the name of the original without its language
+			// prefix, so th-hello and en-hello pair up on their own
+			_, translation = postLang(slug)
+			if of := p.FM.TranslationOf; of != "" {
+				_, translation = postLang(of)
+			}
+		}
+		if lang != p.Lang || translation != p.Translation {
+			// Handlers may still hold the entry, so it's replaced, not changed
+			changed := *p
+			changed.Lang, changed.Translation = lang, translation
+			idx.entries[slug] = &changed
+		}
+	}
+}
+
+// Translations returns the published versions of a post in other languages
+func (idx *PostIndex) Translations(slug string) []PostTranslation {
+	translations := []PostTranslation{}
+	p, ok := idx.Get(slug)
+	if !ok || p.Translation == "" {
+		return translations
+	}
+	for _, other := range idx.Posts() {
+		if other.Translation == p.Translation && other.Lang != p.Lang {
+			translations = append(translations, PostTranslation{Lang: other.Lang, Slug: other.Slug, URL: postTreePath(other.Slug, other.Lang)})
+		}
+	}
+	sort.Slice(translations, func(i, j int) bool {
+		return slices.Index(postLangs, translations[i].Lang) < slices.Index(postLangs, translations[j].Lang)
+	})
+	return translations
+}
+
+// LastScan returns when the posts directory was last read successfully
+func (idx *PostIndex) LastScan() time.Time {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.lastScan
+}
+
+// Posts returns every published post, newest first, in all languages. Scheduled
+// posts join the list once their date passes. The returned slice is shared and
+// must not be modified.
+func (idx *PostIndex) Posts() []Post {
+	now := time.Now()
+	idx.mu.RLock()
+	if idx.nextPublish.IsZero() || now.Before(idx.nextPublish) {
+		defer idx.mu.RUnlock()
+		return idx.published
+	}
+	idx.mu.RUnlock()
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if !idx.nextPublish.IsZero() && !now.Before(idx.nextPublish) {
+		idx.update(now)
+	}
+	return idx.published
+}
+
+// Version returns a number that changes whenever a post is added, edited,
+// removed, or published
+func (idx *PostIndex) Version() uint64 {
+	idx.Posts()
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.version
+}
+
+// Get returns a post's parsed entry, scheduled or not
+func (idx *PostIndex) Get(slug string) (*indexedPost, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	p, ok := idx.entries[slug]
+	return p, ok
+}
+
+// ModTime returns when a post's file was last modified
+func (idx *PostIndex) ModTime(slug string) (time.Time, bool) {
+	p, ok := idx.Get(slug)
+	if !ok {
+		return time.Time{}, false
+	}
+	return p.modTime, true
+}
+
+// Read returns a post's markdown, like FileReader.Read. Private posts are
+// decrypted on every read, so only their sealed form stays in memory.
+func (idx *PostIndex) Read(slug string) (string, error) {
+	p, ok := idx.Get(slug)
+	if !ok {
+		path := filepath.Join(idx.dir, slug+".md")
+		idx.mu.RLock()
+		o, oversized := idx.oversized[slug]
+		idx.mu.RUnlock()
+		if oversized {
+			return "", tooLargeError(path, o.size)
+		}
+		return "", &fs.PathError{Op: "open", Path: path, Err: fs.ErrNotExist}
+	}
+	return openPost(p.Content)
+}
+
+// List returns the slugs of all posts, scheduled ones included, sorted
+func (idx *PostIndex) List() ([]string, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return append([]string(nil), idx.slugs...), nil
+}
+
+// Write saves a post to the store and updates the index straight away, without
+// waiting for the watcher. Stores that can't be edited return errReadOnlyStore.
+func (idx *PostIndex) Write(slug, content string) error {
+	idx.scanMu.Lock()
+	defer idx.scanMu.Unlock()
+
+	w, ok := idx.store.(SlugWriter)
+	if !ok {
+		return errReadOnlyStore
+	}
+	// The index holds private posts sealed, as they are on disk
+	content, err := sealPost(content)
+	if err != nil {
+		return err
+	}
+	if err := w.Write(slug, content); err != nil {
+		return err
+	}
+	info, err := idx.store.Stat(slug)
+	if err != nil {
+		return err
+	}
+	preparePostPlaceholders(slug, content)
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.entries[slug] = newIndexedPost(slug, content, info)
+	idx.update(time.Now())
+	return nil
+}
+
+// Delete removes a post's file and drops it from the index straight away.
+// Stores that can't be edited return errReadOnlyStore.
+func (idx *PostIndex) Delete(slug string) error {
+	idx.scanMu.Lock()
+	defer idx.scanMu.Unlock()
+
+	w, ok := idx.store.(SlugWriter)
+	if !ok {
+		return errReadOnlyStore
+	}
+	if err := w.Delete(slug); err != nil {
+		return err
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	delete(idx.entries, slug)
+	idx.update(time.Now())
+	return nil
+}
+
+// Watch keeps the index current until ctx is done. Changes to a store on disk
+// are picked up through file system notifications, and the store is also
+// rescanned every interval in case notifications are missed or unavailable
+// (some network and container file systems don't deliver them). Stores that
+// fetch their posts, like a Git checkout, are refreshed before those rescans.
+func (idx *PostIndex) Watch(ctx context.Context, interval time.Duration) {
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	if _, local := idx.store.(storeDir); local {
+		watcher, err := fsnotify.NewWatcher()
+		if err == nil {
+			if err = watcher.Add(idx.dir); err != nil {
+				watcher.Close()
+			}
+		}
+		if err != nil {
+			slog.Warn("Not watching for changes, rescanning instead", "dir", idx.dir, "interval", interval, "err", err)
+		} else {
+			defer watcher.Close()
+			events, errs = watcher.Events, watcher.Errors
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var debounce <-chan time.Time
+	rescan := func() {
+		if err := idx.Scan(); err != nil {
+			slog.Error("Error scanning posts", "dir", idx.dir, "err", err)
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			if strings.HasSuffix(ev.Name, ".md") {
+				debounce = time.After(postIndexDebounce)
+			}
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			// Events may have been dropped, so scan to be sure
+			slog.Error("Error watching posts", "dir", idx.dir, "err", err)
+			debounce = time.After(postIndexDebounce)
+		case <-debounce:
+			debounce = nil
+			rescan()
+		case <-ticker.C:
+			if r, ok := idx.store.(storeRefresher); ok {
+				if err := r.Refresh(ctx); err != nil {
+					slog.Error("Error refreshing posts", "err", err)
+				}
+			}
+			rescan()
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestPostIndex(t *testing.T, dir string) *PostIndex {
+	t.Helper()
+	idx, err := NewPostIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return idx
+}
+
+func TestPostIndex(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("old.md", "---\ntitle: Old\ndate: 2025-01-01\n---\n")
+	write("en-new-post.md", "---\ndate: 2025-06-01\n---\n\nBody")
+	soon := time.Now().Add(time.Hour).Format(time.RFC3339)
+	write("soon.md", "---\ntitle: Soon\ndate: "+soon+"\n---\n")
+	write("notes.txt", "not a post")
+
+	idx := newTestPostIndex(t, dir)
+	posts := idx.Posts()
+	if len(posts) != 2 || posts[0].Title != "New Post" || posts[1].Title != "Old" {
+		t.Fatalf("posts = %+v", posts)
+	}
+	if slugs, _ := idx.List(); len(slugs) != 3 {
+		t.Errorf("List() = %v, want scheduled posts included", slugs)
+	}
+	if p := posts[0]; p.WordCount != 1 || p.ReadingTime != 1 {
+		t.Errorf("New Post: %d words, %d minutes, want 1 and 1", p.WordCount, p.ReadingTime)
+	}
+	if md, err := idx.Read("en-new-post"); err != nil || md != "---\ndate: 2025-06-01\n---\n\nBody" {
+		t.Errorf("Read = %q, %v", md, err)
+	}
+	if _, err := idx.Read("missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Read(missing) error = %v, want fs.ErrNotExist", err)
+	}
+
+	// Files changed behind the index's back show up after a scan
+	write("extra.md", "---\ntitle: Extra\ndate: 2025-07-01\n---\n")
+	if len(idx.Posts()) != 2 {
+		t.Error("index read the disk without a scan")
+	}
+	if err := idx.Scan(); err != nil {
+		t.Fatal(err)
+	}
+	if posts := idx.Posts(); len(posts) != 3 || posts[0].Title != "Extra" {
+		t.Errorf("after scan: %+v", posts)
+	}
+
+	// A scheduled post is listed once its date passes, without a scan
+	idx.mu.Lock()
+	idx.entries["soon"].FM.Date = "2025-08-01"
+	idx.entries["soon"].Date, _ = parsePostDate("2025-08-01")
+	idx.nextPublish = time.Now().Add(-time.Second)
+	idx.mu.Unlock()
+	if posts := idx.Posts(); len(posts) != 4 {
+		t.Errorf("scheduled post not published: %d posts", len(posts))
+	}
+
+	// Writes through the index are visible immediately
+	if err := idx.Write("written", "---\ntitle: Written\ndate: 2025-09-01\n---\n"); err != nil {
+		t.Fatal(err)
+	}
+	if posts := idx.Posts(); posts[0].Title != "Written" {
+		t.Errorf("written post not indexed: %+v", posts[0])
+	}
+	if _, err := os.Stat(filepath.Join(dir, "written.md")); err != nil {
+		t.Errorf("written post not on disk: %v", err)
+	}
+
+	// So are deletes
+	if err := idx.Delete("written"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := idx.Get("written"); ok {
+		t.Error("deleted post still indexed")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "written.md")); !os.IsNotExist(err) {
+		t.Errorf("deleted post still on disk: %v", err)
+	}
+}
+
+func TestPostIndexMissingDir(t *testing.T) {
+	if _, err := NewPostIndex(filepath.Join(t.TempDir(), "nope")); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+}
+
+func TestPostIndexWatch(t *testing.T) {
+	dir := t.TempDir()
+	idx := newTestPostIndex(t, dir)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go idx.Watch(ctx, time.Hour)
+	// Give the watcher a moment to start
+	time.Sleep(50 * time.Millisecond)
+
+	os.WriteFile(filepath.Join(dir, "hello.md"), []byte("---\ntitle: Hello\n---\n"), 0644)
+	deadline := time.Now().Add(5 * time.Second)
+	for len(idx.Posts()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("new post not picked up by the watcher")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	os.Remove(filepath.Join(dir, "hello.md"))
+	for len(idx.Posts()) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("removed post still indexed")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestPostIndexTranslations(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"en-hello.md":    "---\ntitle: Hello\n---\nHi",
+		"th-hello.md":    "---\ntitle: สวัสดี\n---\nสวัสดีครับ",
+		"learning-go.md": "---\ntitle: Learning Go\n---\nNotes on learning Go.",
+		"rian-go.md":     "---\ntitle: เรียน Go\ntranslation_of: learning-go\n---\nบันทึกการเรียนภาษา Go",
+		"th-draft.md":    "---\ntitle: ร่าง\ndraft: true\ntranslation_of: en-hello\n---\nร่าง",
+		"both.md":        "---\ntitle: Both\n---\nHi",
+	} {
+		os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+	}
+	idx := newTestPostIndex(t, dir)
+
+	tests := []struct {
+		slug     string
+		wantLang string
+		want     []string
+	}{
+		{"en-hello", "en", []string{"th-hello"}},
+		{"th-hello", "th", []string{"en-hello"}},
+		{"learning-go", "en", []string{"rian-go"}},
+		{"rian-go", "th", []string{"learning-go"}},
+		{"both", "", nil},
+	}
+	for _, tt := range tests {
+		p, _ := idx.Get(tt.slug)
+		if p.Lang != tt.wantLang {
+			t.Errorf("%s: lang = %q, want %q", tt.slug, p.Lang, tt.wantLang)
+		}
+		var got []string
+		for _, tr := range idx.Translations(tt.slug) {
+			got = append(got, tr.Slug)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: translations = %v, want %v", tt.slug, got, tt.want)
+		}
+	}
+	if tr := idx.Translations("learning-go"); tr[0].URL != "/th/posts/rian-go" {
+		t.Errorf("URL = %q", tr[0].URL)
+	}
+}
+
+func TestPostIndexOversized(t *testing.T) {
+	defer func(old int) { cfg.MaxPostKB = old }(cfg.MaxPostKB)
+	cfg.MaxPostKB = 1
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "small.md"), []byte("# Small"), 0644)
+	os.WriteFile(filepath.Join(dir, "build-log.md"), make([]byte, 2<<10), 0644)
+	logs := captureLogs(t)
+	idx := newTestPostIndex(t, dir)
+
+	if _, ok := idx.Get("build-log"); ok {
+		t.Error("expected the oversized file to stay out of the index")
+	}
+	if len(idx.Posts()) != 1 {
+		t.Errorf("expected only the small post to be listed, got %+v", idx.Posts())
+	}
+	if _, err := idx.Read("build-log"); !errors.Is(err, errPostTooLarge) {
+		t.Errorf("expected errPostTooLarge, got %v", err)
+	}
+	if _, err := idx.Read("missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist for a missing post, got %v", err)
+	}
+	if _, err := (&FileReader{Dir: dir}).Read("build-log"); !errors.Is(err, errPostTooLarge) {
+		t.Errorf("expected FileReader to refuse the oversized file, got %v", err)
+	}
+
+	// Rescans don't warn again about the same file
+	idx.Scan()
+	if n := strings.Count(logs.String(), "Post too large"); n != 1 {
+		t.Errorf("expected one warning, got %d: %s", n, logs.String())
+	}
+
+	// Once trimmed it is served again
+	os.WriteFile(filepath.Join(dir, "build-log.md"), []byte("# Build log"), 0644)
+	idx.Scan()
+	if _, err := idx.Read("build-log"); err != nil {
+		t.Errorf("expected the trimmed post to be readable, got %v", err)
+	}
+}
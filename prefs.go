@@ -0,0 +1,135 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+)
+
+// Reader preferences. Each can be chosen with a query parameter of the same
+// name, e.g. ?font_size=large, and is then remembered in a cookie.
+const (
+	prefLang      = "lang"
+	prefFontSize  = "font_size"
+	prefLineWidth = "line_width"
+)
+
+// Values of the display preferences; the first of each is the default
+var (
+	fontSizes  = []string{"medium", "small", "large"}
+	lineWidths = []string{"normal", "narrow", "wide"}
+)
+
+// prefCookie returns the reader's saved value of a preference, or "" when it
+// is unset or not one of values
+func prefCookie(r *http.Request, name string, values []string) string {
+	c, err := r.Cookie(name)
+	if err != nil || !slices.Contains(values, c.Value) {
+		return ""
+	}
+	return c.Value
+}
+
+// setPrefCookie remembers a preference for a year, unless
+// cookies.remember_preferences is off. Not HttpOnly: page scripts change the
+// language from the toggle.
+func setPrefCookie(w http.ResponseWriter, r *http.Request, name, value string) {
+	if !cfg.Cookies.RememberPreferences {
+		return
+	}
+	cookie := newCookie(r, name, value)
+	cookie.MaxAge = 31536000 // 1 year
+	http.SetCookie(w, cookie)
+}
+
+// readerPref returns a preference from the query, remembering it, else from
+// its cookie, else the default
+func readerPref(w http.ResponseWriter, r *http.Request, name string, values []string) string {
+	if v := r.URL.Query().Get(name); slices.Contains(values, v) {
+		if v != prefCookie(r, name, values) {
+			setPrefCookie(w, r, name, v)
+		}
+		return v
+	}
+	if v := prefCookie(r, name, values); v != "" {
+		return v
+	}
+	return values[0]
+}
+
+// DisplayPrefs are how the reader likes text shown
+type DisplayPrefs struct {
+	FontSize  string
+	LineWidth string
+}
+
+// Class returns the classes for <html> that apply the preferences, empty for
+// the defaults
+func (d DisplayPrefs) Class() string {
+	var class string
+	if d.FontSize != "" && d.FontSize != fontSizes[0] {
+		class = "font-" + d.FontSize
+	}
+	if d.LineWidth != "" && d.LineWidth != lineWidths[0] {
+		if class != "" {
+			class += " "
+		}
+		class += "line-" + d.LineWidth
+	}
+	return class
+}
+
+// displayWriter carries a request's display preferences and page language to
+// renderPageData
+type displayWriter struct {
+	http.ResponseWriter
+	prefs DisplayPrefs
+	lang  string
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (dw *displayWriter) Unwrap() http.ResponseWriter { return dw.ResponseWriter }
+
+// DisplayPreferences reads the reader's font size and line width, and the
+// language of the page, for the pages rendered by next
+func DisplayPreferences(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefs := DisplayPrefs{
+			FontSize:  readerPref(w, r, prefFontSize, fontSizes),
+			LineWidth: readerPref(w, r, prefLineWidth, lineWidths),
+		}
+		next.ServeHTTP(&displayWriter{ResponseWriter: w, prefs: prefs, lang: requestLang(r)}, r)
+	})
+}
+
+// findDisplayWriter returns the displayWriter w wraps, if any
+func findDisplayWriter(w http.ResponseWriter) *displayWriter {
+	for w != nil {
+		if dw, ok := w.(*displayWriter); ok {
+			return dw
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			break
+		}
+		w = u.Unwrap()
+	}
+	return nil
+}
+
+// displayPrefs returns the preferences DisplayPreferences found for the
+// response w belongs to, the defaults outside it
+func displayPrefs(w http.ResponseWriter) DisplayPrefs {
+	if dw := findDisplayWriter(w); dw != nil {
+		return dw.prefs
+	}
+	return DisplayPrefs{FontSize: fontSizes[0], LineWidth: lineWidths[0]}
+}
+
+// pageLang returns the language of the page w renders, or "" outside
+// DisplayPreferences
+func pageLang(w http.ResponseWriter) string {
+	if dw := findDisplayWriter(w); dw != nil {
+		return dw.lang
+	}
+	return ""
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReaderPref(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		cookie     string
+		remember   bool
+		want       string
+		wantCookie string
+	}{
+		{"default", "", "", true, "medium", ""},
+		{"query is remembered", "?font_size=large", "", true, "large", "large"},
+		{"query over cookie", "?font_size=small", "large", true, "small", "small"},
+		{"same as cookie", "?font_size=large", "large", true, "large", ""},
+		{"cookie", "", "large", true, "large", ""},
+		{"unknown query", "?font_size=huge", "small", true, "small", ""},
+		{"unknown cookie", "", "huge", true, "medium", ""},
+		{"not remembered", "?font_size=large", "", false, "large", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(old bool) { cfg.Cookies.RememberPreferences = old }(cfg.Cookies.RememberPreferences)
+			cfg.Cookies.RememberPreferences = tt.remember
+
+			r := httptest.NewRequest("GET", "/posts/hello"+tt.query, nil)
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: prefFontSize, Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+			if got := readerPref(w, r, prefFontSize, fontSizes); got != tt.want {
+				t.Errorf("readerPref() = %q, want %q", got, tt.want)
+			}
+
+			var set string
+			for _, c := range w.Result().Cookies() {
+				if c.Name == prefFontSize {
+					set = c.Value
+					if c.MaxAge != 31536000 || c.Path != "/" {
+						t.Errorf("cookie = %+v, want a year-long cookie for the whole site", c)
+					}
+				}
+			}
+			if set != tt.wantCookie {
+				t.Errorf("cookie set to %q, want %q", set, tt.wantCookie)
+			}
+		})
+	}
+}
+
+func TestDisplayPrefsClass(t *testing.T) {
+	tests := []struct {
+		prefs DisplayPrefs
+		want  string
+	}{
+		{DisplayPrefs{"medium", "normal"}, ""},
+		{DisplayPrefs{"large", "normal"}, "font-large"},
+		{DisplayPrefs{"medium", "narrow"}, "line-narrow"},
+		{DisplayPrefs{"small", "wide"}, "font-small line-wide"},
+		{DisplayPrefs{}, ""},
+	}
+	for _, tt := range tests {
+		if got := tt.prefs.Class(); got != tt.want {
+			t.Errorf("%+v.Class() = %q, want %q", tt.prefs, got, tt.want)
+		}
+	}
+}
+
+func TestDisplayPreferences(t *testing.T) {
+	handler := DisplayPreferences(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		renderPage(w, "Hello", template.HTML("<h1>Hello</h1>"))
+	}))
+	r := httptest.NewRequest("GET", "/posts/hello?line_width=wide", nil)
+	r.AddCookie(&http.Cookie{Name: prefFontSize, Value: "large"})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	body := w.Body.String()
+	for _, want := range []string{
+		`<html lang="th" class="font-large line-wide"`,
+		`<a href="?font_size=large" aria-label="Large" aria-current="true">`,
+		`<a href="?line_width=wide" data-i18n="wide" aria-current="true">`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %s in %s", want, body)
+		}
+	}
+
+	// Outside the middleware pages render with the defaults
+	w = httptest.NewRecorder()
+	renderPage(w, "Hello", template.HTML("<h1>Hello</h1>"))
+	if strings.Contains(w.Body.String(), `class="font-`) {
+		t.Error("expected no display classes outside DisplayPreferences")
+	}
+}
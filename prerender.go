@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"runtime"
+	"sync"
+)
+
+// renderedPost is a published post with its markdown rendered
+type renderedPost struct {
+	Slug string
+	FM   PostFrontmatter
+	HTML string
+}
+
+// renderWorkers bounds how many posts are rendered at once
+var renderWorkers = runtime.GOMAXPROCS(0)
+
+// renderPosts reads and renders the published posts among slugs with a pool
+// of renderWorkers goroutines, keeping the order of slugs. Posts that can't be
+// read or rendered are left out and their errors returned together, alongside
+// the rest.
+func renderPosts(files SlugReader, slugs []string) ([]renderedPost, error) {
+	results := make([]*renderedPost, len(slugs))
+	errs := make([]error, len(slugs))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(renderWorkers, len(slugs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				slug := slugs[i]
+				src, err := files.Read(slug)
+				if errors.Is(err, fs.ErrNotExist) {
+					// Deleted since it was listed
+					continue
+				}
+				if err != nil {
+					errs[i] = fmt.Errorf("read %s: %w", slug, err)
+					continue
+				}
+				fm, body := ParseFrontmatter(src)
+				if !postVisible(fm) {
+					continue
+				}
+				html, err := renderMarkdown(body, slug)
+				if err != nil {
+					errs[i] = fmt.Errorf("render %s: %w", slug, err)
+					continue
+				}
+				results[i] = &renderedPost{Slug: slug, FM: fm, HTML: html}
+			}
+		}()
+	}
+	for i := range slugs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	var out []renderedPost
+	for _, p := range results {
+		if p != nil {
+			out = append(out, *p)
+		}
+	}
+	return out, errors.Join(errs...)
+}
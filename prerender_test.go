@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// flakyReader fails to read some slugs
+type flakyReader struct {
+	MockSlugReader
+	broken map[string]bool
+}
+
+func (f *flakyReader) Read(slug string) (string, error) {
+	if f.broken[slug] {
+		return "", errors.New("disk on fire")
+	}
+	return f.MockSlugReader.Read(slug)
+}
+
+func TestRenderPosts(t *testing.T) {
+	defer func(n int) { renderWorkers = n }(renderWorkers)
+	renderWorkers = 4
+
+	content := map[string]string{"scheduled": "---\ndate: 2999-01-01\n---\nLater"}
+	var slugs []string
+	for i := range 50 {
+		slug := fmt.Sprintf("post-%02d", i)
+		slugs = append(slugs, slug)
+		content[slug] = fmt.Sprintf("---\ntitle: Post %d\n---\n**%d**", i, i)
+	}
+	slugs = append(slugs, "scheduled", "deleted", "broken-a", "broken-b")
+	files := &flakyReader{MockSlugReader{content: content}, map[string]bool{"broken-a": true, "broken-b": true}}
+
+	posts, err := renderPosts(files, slugs)
+	if len(posts) != 50 {
+		t.Fatalf("rendered %d posts, want 50", len(posts))
+	}
+	for i, p := range posts {
+		if p.Slug != slugs[i] || p.FM.Title != fmt.Sprintf("Post %d", i) || !strings.Contains(p.HTML, fmt.Sprintf("<strong>%d</strong>", i)) {
+			t.Errorf("posts[%d] = %+v", i, p)
+		}
+	}
+	if err == nil || !strings.Contains(err.Error(), "read broken-a") || !strings.Contains(err.Error(), "read broken-b") {
+		t.Errorf("err = %v, want both read failures", err)
+	}
+}
+
+func TestRenderPostsEmpty(t *testing.T) {
+	posts, err := renderPosts(&MockSlugReader{}, nil)
+	if len(posts) != 0 || err != nil {
+		t.Errorf("renderPosts(nil) = %v, %v", posts, err)
+	}
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"image"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "golang.org/x/image/webp"
+)
+
+// Preview check statuses
+const (
+	PreviewQueued = "queued"
+	PreviewPassed = "passed"
+	PreviewFailed = "failed"
+	// PreviewSkipped means the post was unpublished or removed before the check
+	PreviewSkipped = "skipped"
+)
+
+const (
+	// previewMinWidth and previewMinHeight are the smallest og:image Facebook
+	// shows; anything smaller is dropped from the preview
+	previewMinWidth  = 200
+	previewMinHeight = 200
+	// previewLargeMinWidth and previewLargeMinHeight are the smallest image X
+	// shows as a summary_large_image card
+	previewLargeMinWidth  = 300
+	previewLargeMinHeight = 157
+	// previewMaxImageBytes is the largest og:image X accepts
+	previewMaxImageBytes = 5 << 20
+)
+
+// previewRequiredTags are the meta tags a post needs for a complete link
+// preview on the usual platforms
+var previewRequiredTags = []string{"og:title", "og:description", "og:type", "og:url", "og:image", "twitter:card"}
+
+// previewMetaRegex matches the Open Graph and Twitter meta tags as the base
+// template writes them
+var previewMetaRegex = regexp.MustCompile(`<meta\s+(?:property|name)="((?:og|twitter):[^"]+)"\s+content="([^"]*)"`)
+
+// PreviewCheck is the result of checking one post's link preview
+type PreviewCheck struct {
+	Slug      string
+	Status    string
+	Problems  []string
+	CheckedAt time.Time
+}
+
+// PreviewCheckJobPayload is the payload of a "preview-check" job
+type PreviewCheckJobPayload struct {
+	Slug string `json:"slug"`
+}
+
+// PreviewCheckStore records the link preview checks of new posts
+type PreviewCheckStore struct {
+	db *sql.DB
+}
+
+const previewChecksSchema = `CREATE TABLE IF NOT EXISTS preview_checks (
+	slug TEXT PRIMARY KEY,
+	status TEXT NOT NULL,
+	problems TEXT NOT NULL DEFAULT '',
+	checked_at INTEGER NOT NULL
+);`
+
+// NewPreviewCheckStore creates the preview_checks table if needed
+func NewPreviewCheckStore(db *sql.DB) (*PreviewCheckStore, error) {
+	if _, err := db.Exec(previewChecksSchema); err != nil {
+		return nil, fmt.Errorf("create preview_checks table: %w", err)
+	}
+	return &PreviewCheckStore{db: db}, nil
+}
+
+// Claim marks a post as queued for a check and reports whether it was new, so
+// each post is checked once unless the admin asks again
+func (s *PreviewCheckStore) Claim(slug string) (bool, error) {
+	res, err := s.db.Exec(`INSERT OR IGNORE INTO preview_checks (slug, status, checked_at) VALUES (?, ?, ?)`,
+		slug, PreviewQueued, time.Now().Unix())
+	if err != nil {
+		return false, fmt.Errorf("claim preview check %s: %w", slug, err)
+	}
+	n, _ := res.RowsAffected()
+	return n == 1, nil
+}
+
+// SetResult records the outcome of a check
+func (s *PreviewCheckStore) SetResult(slug, status string, problems []string) error {
+	_, err := s.db.Exec(`UPDATE preview_checks SET status = ?, problems = ?, checked_at = ? WHERE slug = ?`,
+		status, strings.Join(problems, "\n"), time.Now().Unix(), slug)
+	if err != nil {
+		return fmt.Errorf("update preview check %s: %w", slug, err)
+	}
+	return nil
+}
+
+// Get returns the latest check of a post
+func (s *PreviewCheckStore) Get(slug string) (PreviewCheck, error) {
+	c := PreviewCheck{Slug: slug}
+	var problems string
+	var checked int64
+	err := s.db.QueryRow(`SELECT status, problems, checked_at FROM preview_checks WHERE slug = ?`, slug).Scan(&c.Status, &problems, &checked)
+	if err != nil {
+		return c, fmt.Errorf("get preview check %s: %w", slug, err)
+	}
+	if problems != "" {
+		c.Problems = strings.Split(problems, "\n")
+	}
+	c.CheckedAt = time.Unix(checked, 0)
+	return c, nil
+}
+
+// List returns every check, failures first, then most recent first
+func (s *PreviewCheckStore) List() ([]PreviewCheck, error) {
+	rows, err := s.db.Query(`SELECT slug, status, problems, checked_at FROM preview_checks
+		ORDER BY status = ? DESC, checked_at DESC, slug`, PreviewFailed)
+	if err != nil {
+		return nil, fmt.Errorf("list preview checks: %w", err)
+	}
+	defer rows.Close()
+
+	var out []PreviewCheck
+	for rows.Next() {
+		var c PreviewCheck
+		var problems string
+		var checked int64
+		if err := rows.Scan(&c.Slug, &c.Status, &problems, &checked); err != nil {
+			return nil, fmt.Errorf("list preview checks: %w", err)
+		}
+		if problems != "" {
+			c.Problems = strings.Split(problems, "\n")
+		}
+		c.CheckedAt = time.Unix(checked, 0)
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// queuePreviewChecks queues a check for each post that went live within maxAge
+// and has not been checked yet. Like cross-posts, posts without a date are
+// left alone.
+func queuePreviewChecks(store *PreviewCheckStore, queue *JobQueue, posts PostFiles, maxAge time.Duration, now time.Time) error {
+	slugs, err := posts.List()
+	if err != nil {
+		return err
+	}
+	for _, slug := range slugs {
+		src, err := posts.Read(slug)
+		if err != nil {
+			continue
+		}
+		fm, _ := ParseFrontmatter(src)
+		published, ok := parsePostDate(fm.Date)
+		if !ok || published.After(now) || now.Sub(published) > maxAge || !postVisible(fm) {
+			continue
+		}
+		claimed, err := store.Claim(slug)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			continue
+		}
+		if err := queue.Enqueue("preview-check", PreviewCheckJobPayload{Slug: slug}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunPreviewCheckScanner checks for newly published posts every interval until
+// the context is cancelled
+func RunPreviewCheckScanner(ctx context.Context, interval time.Duration, store *PreviewCheckStore, queue *JobQueue, posts PostFiles, maxAge time.Duration) {
+	for {
+		if err := queuePreviewChecks(store, queue, posts, maxAge, time.Now()); err != nil {
+			slog.Error("Error queueing preview checks", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// previewMeta returns the Open Graph and Twitter meta tags of a page
+func previewMeta(page []byte) map[string]string {
+	meta := make(map[string]string)
+	for _, m := range previewMetaRegex.FindAllSubmatch(page, -1) {
+		name := string(m[1])
+		if _, seen := meta[name]; !seen {
+			meta[name] = html.UnescapeString(string(m[2]))
+		}
+	}
+	return meta
+}
+
+// previewProblems lists what is missing or wrong in a page's preview tags
+func previewProblems(meta map[string]string) []string {
+	var problems []string
+	for _, name := range previewRequiredTags {
+		if strings.TrimSpace(meta[name]) == "" {
+			problems = append(problems, "missing "+name)
+		}
+	}
+	return problems
+}
+
+// previewImageProblems fetches a preview image and lists what keeps it from
+// being shown: an error, a format platforms don't read, or a size out of bounds
+func previewImageProblems(ctx context.Context, client *http.Client, url, card string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return []string{"og:image " + url + " is not a valid URL"}, nil
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch og:image: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return []string{"og:image " + url + " returned " + resp.Status}, nil
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, previewMaxImageBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("fetch og:image: %w", err)
+	}
+	if len(data) > previewMaxImageBytes {
+		return []string{"og:image is larger than " + strconv.Itoa(previewMaxImageBytes>>20) + " MB"}, nil
+	}
+	ic, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return []string{"og:image is not a JPEG, PNG, GIF, or WebP image"}, nil
+	}
+
+	var problems []string
+	size := strconv.Itoa(ic.Width) + "×" + strconv.Itoa(ic.Height)
+	if ic.Width < previewMinWidth || ic.Height < previewMinHeight {
+		problems = append(problems, "og:image is "+size+", smaller than "+strconv.Itoa(previewMinWidth)+"×"+strconv.Itoa(previewMinHeight))
+	} else if card == "summary_large_image" && (ic.Width < previewLargeMinWidth || ic.Height < previewLargeMinHeight) {
+		problems = append(problems, "og:image is "+size+", too small for a large card ("+strconv.Itoa(previewLargeMinWidth)+"×"+strconv.Itoa(previewLargeMinHeight)+")")
+	}
+	return problems, nil
+}
+
+// checkPreview fetches a post the way a social platform would and lists the
+// problems with its link preview. Errors mean the site couldn't be reached.
+func checkPreview(ctx context.Context, client *http.Client, url string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return []string{url + " returned " + resp.Status}, nil
+	}
+	page, err := io.ReadAll(io.LimitReader(resp.Body, 2<<20))
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s: %w", url, err)
+	}
+
+	meta := previewMeta(page)
+	problems := previewProblems(meta)
+	if img := meta["og:image"]; img != "" {
+		imgProblems, err := previewImageProblems(ctx, client, img, meta["twitter:card"])
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, imgProblems...)
+	}
+	return problems, nil
+}
+
+// PreviewCheckJob returns a JobHandler that checks a post's link preview
+// through the live site and records the result for the admin
+func PreviewCheckJob(store *PreviewCheckStore, posts SlugReader, client *http.Client, siteURL string) JobHandler {
+	return func(ctx context.Context, payload []byte) error {
+		var p PreviewCheckJobPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return fmt.Errorf("decode preview-check job: %w", err)
+		}
+		src, err := posts.Read(p.Slug)
+		if err != nil {
+			return store.SetResult(p.Slug, PreviewSkipped, []string{"post no longer exists"})
+		}
+		if fm, _ := ParseFrontmatter(src); !postVisible(fm) {
+			return store.SetResult(p.Slug, PreviewSkipped, nil)
+		}
+
+		problems, err := checkPreview(ctx, client, siteURL+"/posts/"+p.Slug)
+		if err != nil {
+			if serr := store.SetResult(p.Slug, PreviewFailed, []string{err.Error()}); serr != nil {
+				slog.Error("Error recording preview check failure", "err", serr)
+			}
+			return err
+		}
+		if len(problems) > 0 {
+			slog.Warn("Link preview has problems", "slug", p.Slug, "problems", strings.Join(problems, "; "))
+			return store.SetResult(p.Slug, PreviewFailed, problems)
+		}
+		return store.SetResult(p.Slug, PreviewPassed, nil)
+	}
+}
+
+// AdminPreviewsHandler shows the link preview checks of new posts, failures first
+func AdminPreviewsHandler(store *PreviewCheckStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list, err := store.List()
+		if err != nil {
+			slog.Error("Error listing preview checks", "err", err)
+			http.Error(w, "Could not list preview checks", http.StatusInternalServerError)
+			return
+		}
+
+		var content bytes.Buffer
+		content.WriteString("<div class=\"admin-page\">\n")
+		content.WriteString(adminNav())
+		content.WriteString("<h1>Link previews</h1>\n")
+		if len(list) == 0 {
+			content.WriteString("<p>No posts checked yet. Newly published posts are fetched like a social platform would, and their preview tags and image checked.</p>\n")
+		} else {
+			content.WriteString("<table class=\"admin-table\">\n<tr><th>Post</th><th>Status</th><th>Problems</th><th>Checked</th><th></th></tr>\n")
+			for _, c := range list {
+				slug := template.HTMLEscapeString(c.Slug)
+				content.WriteString("<tr>")
+				content.WriteString("<td><a href=\"/posts/" + slug + "\">" + slug + "</a></td>")
+				content.WriteString("<td><span class=\"crosspost-status preview-" + c.Status + "\">" + template.HTMLEscapeString(c.Status) + "</span></td>")
+				content.WriteString("<td>")
+				if len(c.Problems) > 0 {
+					content.WriteString("<ul class=\"preview-problems\">")
+					for _, p := range c.Problems {
+						content.WriteString("<li>" + template.HTMLEscapeString(p) + "</li>")
+					}
+					content.WriteString("</ul>")
+				}
+				content.WriteString("</td>")
+				content.WriteString("<td>" + c.CheckedAt.Format("Jan 2, 2006 15:04") + "</td>")
+				content.WriteString("<td class=\"admin-actions\">")
+				if c.Status != PreviewQueued {
+					content.WriteString("<form method=\"post\" action=\"/admin/previews/" + slug + "/recheck\"><button type=\"submit\">Check again</button></form>")
+				}
+				content.WriteString("</td></tr>\n")
+			}
+			content.WriteString("</table>\n")
+		}
+		content.WriteString("</div>")
+
+		renderPage(w, "Link previews", template.HTML(content.String()))
+	}
+}
+
+// AdminPreviewRecheckHandler queues another check of a post's link preview,
+// after its image or description was fixed
+func AdminPreviewRecheckHandler(store *PreviewCheckStore, queue *JobQueue) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slug := r.PathValue("slug")
+		_, err := store.Get(slug)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Preview check not found", http.StatusNotFound)
+			return
+		}
+		if err == nil {
+			if err = store.SetResult(slug, PreviewQueued, nil); err == nil {
+				err = queue.Enqueue("preview-check", PreviewCheckJobPayload{Slug: slug})
+			}
+		}
+		if err != nil {
+			slog.Error("Error rechecking link preview", "slug", slug, "err", err)
+			http.Error(w, "Could not recheck link preview", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/admin/previews", http.StatusSeeOther)
+	}
+}
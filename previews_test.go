@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestPreviewCheckStore(t *testing.T) (*PreviewCheckStore, *JobQueue) {
+	t.Helper()
+	db := newTestDB(t)
+	store, err := NewPreviewCheckStore(db)
+	if err != nil {
+		t.Fatalf("NewPreviewCheckStore: %v", err)
+	}
+	queue, err := NewJobQueue(db)
+	if err != nil {
+		t.Fatalf("NewJobQueue: %v", err)
+	}
+	queue.Backoff = 0
+	return store, queue
+}
+
+// previewSite serves posts with the given head tags, and blank PNGs of the
+// sizes in images
+func previewSite(t *testing.T, heads map[string]string, images map[string]image.Point) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /posts/{slug}", func(w http.ResponseWriter, r *http.Request) {
+		head, ok := heads[r.PathValue("slug")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("<!DOCTYPE html><html><head>\n" + head + "\n</head><body></body></html>"))
+	})
+	mux.HandleFunc("GET /images/{name}", func(w http.ResponseWriter, r *http.Request) {
+		size, ok := images[r.PathValue("name")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		png.Encode(w, image.NewGray(image.Rect(0, 0, size.X, size.Y)))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// previewHead is the head the base template writes for a post with an image
+func previewHead(base, image string) string {
+	return `<meta property="og:title" content="Hello | Blog">
+<meta property="og:description" content="A post &amp; more">
+<meta property="og:type" content="article">
+<meta property="og:url" content="` + base + `/posts/hello">
+<meta property="og:image" content="` + base + `/images/` + image + `">
+<meta name="twitter:card" content="summary_large_image">`
+}
+
+func TestCheckPreview(t *testing.T) {
+	heads := map[string]string{}
+	srv := previewSite(t, heads, map[string]image.Point{
+		"large.png": {1200, 630},
+		"tiny.png":  {100, 100},
+		"short.png": {250, 120},
+		"card.png":  {280, 280},
+	})
+	heads["ok"] = previewHead(srv.URL, "large.png")
+	heads["tiny"] = previewHead(srv.URL, "tiny.png")
+	heads["short"] = previewHead(srv.URL, "short.png")
+	heads["narrow-card"] = previewHead(srv.URL, "card.png")
+	heads["missing-image"] = previewHead(srv.URL, "gone.png")
+	heads["no-image"] = `<meta property="og:title" content="Hi">
+<meta property="og:type" content="article">
+<meta name="twitter:card" content="summary">`
+
+	tests := []struct {
+		slug string
+		want []string
+	}{
+		{"ok", nil},
+		{"tiny", []string{"og:image is 100×100, smaller than 200×200"}},
+		{"short", []string{"og:image is 250×120, smaller than 200×200"}},
+		{"narrow-card", []string{"og:image is 280×280, too small for a large card (300×157)"}},
+		{"missing-image", []string{"og:image " + srv.URL + "/images/gone.png returned 404 Not Found"}},
+		{"no-image", []string{"missing og:description", "missing og:url", "missing og:image"}},
+		{"unknown", []string{srv.URL + "/posts/unknown returned 404 Not Found"}},
+	}
+	for _, tt := range tests {
+		got, err := checkPreview(context.Background(), srv.Client(), srv.URL+"/posts/"+tt.slug)
+		if err != nil {
+			t.Errorf("%s: %v", tt.slug, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: problems = %q, want %q", tt.slug, got, tt.want)
+		}
+	}
+}
+
+func TestPreviewChecks(t *testing.T) {
+	store, queue := newTestPreviewCheckStore(t)
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	posts := &MockSlugReader{content: map[string]string{
+		"fresh":     "---\ntitle: Fresh\ndate: 2026-03-10T08:00:00Z\n---\n\nNew",
+		"bare":      "---\ntitle: Bare\ndate: 2026-03-10T09:00:00Z\n---\n\nNew",
+		"old":       "---\ntitle: Old\ndate: 2025-01-01\n---\n\nArchive",
+		"scheduled": "---\ntitle: Later\ndate: 2026-04-01\n---\n\nSoon",
+		"undated":   "---\ntitle: Undated\n---\n\nWhen?",
+	}}
+	heads := map[string]string{}
+	srv := previewSite(t, heads, map[string]image.Point{"large.png": {1200, 630}})
+	heads["fresh"] = previewHead(srv.URL, "large.png")
+	heads["bare"] = `<meta property="og:title" content="Bare">`
+	queue.Register("preview-check", PreviewCheckJob(store, posts, srv.Client(), srv.URL))
+
+	// Scanning twice must not check a post twice
+	for range 2 {
+		if err := queuePreviewChecks(store, queue, posts, 48*time.Hour, now); err != nil {
+			t.Fatalf("queuePreviewChecks: %v", err)
+		}
+	}
+	ran := 0
+	for {
+		processed, err := queue.RunOnce(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !processed {
+			break
+		}
+		ran++
+	}
+	if ran != 2 {
+		t.Errorf("ran %d checks, want 2", ran)
+	}
+
+	if c, err := store.Get("fresh"); err != nil || c.Status != PreviewPassed || len(c.Problems) != 0 {
+		t.Errorf("fresh: recorded %+v, %v", c, err)
+	}
+	c, err := store.Get("bare")
+	if err != nil || c.Status != PreviewFailed || !reflect.DeepEqual(c.Problems[:2], []string{"missing og:description", "missing og:type"}) {
+		t.Errorf("bare: recorded %+v, %v", c, err)
+	}
+	if _, err := store.Get("old"); err == nil {
+		t.Error("old post was checked")
+	}
+
+	// Failures are listed first in the admin
+	w := httptest.NewRecorder()
+	AdminPreviewsHandler(store)(w, httptest.NewRequest("GET", "/admin/previews", nil))
+	body := w.Body.String()
+	if !strings.Contains(body, "<li>missing og:description</li>") {
+		t.Errorf("admin page lacks the problems: %s", body)
+	}
+	if strings.Index(body, "/posts/bare") > strings.Index(body, "/posts/fresh") {
+		t.Error("failed check not listed first")
+	}
+
+	// Checking again from the admin queues it
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/admin/previews/bare/recheck", nil)
+	req.SetPathValue("slug", "bare")
+	AdminPreviewRecheckHandler(store, queue)(w, req)
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("recheck status = %d", w.Code)
+	}
+	if c, _ := store.Get("bare"); c.Status != PreviewQueued {
+		t.Errorf("status after recheck = %s", c.Status)
+	}
+	if processed, err := queue.RunOnce(context.Background()); err != nil || !processed {
+		t.Errorf("recheck not queued: %v", err)
+	}
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/admin/previews/nope/recheck", nil)
+	req.SetPathValue("slug", "nope")
+	AdminPreviewRecheckHandler(store, queue)(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("recheck of unknown post = %d, want 404", w.Code)
+	}
+}
+
+func TestPreviewCheckJob_Unreachable(t *testing.T) {
+	store, _ := newTestPreviewCheckStore(t)
+	posts := &MockSlugReader{content: map[string]string{"hello": "---\ntitle: Hello\n---\n\nHi"}}
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	job := PreviewCheckJob(store, posts, &http.Client{Timeout: time.Second}, srv.URL)
+
+	store.Claim("hello")
+	if err := job(context.Background(), []byte(`{"slug": "hello"}`)); err == nil {
+		t.Fatal("expected the fetch error to be returned for a retry")
+	}
+	if c, _ := store.Get("hello"); c.Status != PreviewFailed || len(c.Problems) != 1 {
+		t.Errorf("recorded %+v", c)
+	}
+
+	// A post gone by the time of the check is skipped
+	store.Claim("gone")
+	if err := job(context.Background(), []byte(`{"slug": "gone"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := store.Get("gone"); c.Status != PreviewSkipped {
+		t.Errorf("gone: status = %s", c.Status)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Sealed post bodies sit between these lines, base64-encoded
+const (
+	sealedBegin = "-----BEGIN ENCRYPTED POST-----"
+	sealedEnd   = "-----END ENCRYPTED POST-----"
+)
+
+// postKey encrypts the bodies of private posts on disk. main sets it from
+// POST_ENCRYPTION_KEY; nil stores them as written.
+var postKey []byte
+
+// errNoPostKey is returned when reading an encrypted post without a key
+var errNoPostKey = errors.New("post is encrypted and POST_ENCRYPTION_KEY is not set")
+
+// parsePostKey decodes a base64 AES-256 key, as made by `openssl rand -base64 32`.
+// An empty value turns encryption off.
+func parsePostKey(s string) ([]byte, error) {
+	if s == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("POST_ENCRYPTION_KEY must be 32 bytes, base64-encoded")
+	}
+	return key, nil
+}
+
+// splitFrontmatter cuts a post into its frontmatter block, fences included,
+// and the rest, where ParseFrontmatter would
+func splitFrontmatter(content string) (head, body string) {
+	if !strings.HasPrefix(content, "---") {
+		return "", content
+	}
+	end := strings.Index(content[3:], "---")
+	if end < 0 {
+		return "", content
+	}
+	cut := 3 + end + 3
+	return content[:cut], content[cut:]
+}
+
+// postSealed reports whether a post's body is encrypted
+func postSealed(content string) bool {
+	_, body := splitFrontmatter(content)
+	return strings.HasPrefix(strings.TrimSpace(body), sealedBegin)
+}
+
+// postGCM returns the AEAD for postKey
+func postGCM() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(postKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealPost encrypts the body of a private post when a key is set. The
+// frontmatter stays readable so the index can keep the post out of listings.
+// Other posts, and posts already sealed, come back unchanged.
+func sealPost(content string) (string, error) {
+	fm, _ := ParseFrontmatter(content)
+	if !fm.Private || postKey == nil || postSealed(content) {
+		return content, nil
+	}
+	gcm, err := postGCM()
+	if err != nil {
+		return "", err
+	}
+	head, body := splitFrontmatter(content)
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	encoded := base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(body), nil))
+
+	var b strings.Builder
+	b.WriteString(head + "\n" + sealedBegin + "\n")
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76] + "\n")
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded + "\n" + sealedEnd + "\n")
+	return b.String(), nil
+}
+
+// openPost decrypts a sealed post body, returning the post as it was written.
+// Posts that aren't sealed come back unchanged.
+func openPost(content string) (string, error) {
+	if !postSealed(content) {
+		return content, nil
+	}
+	if postKey == nil {
+		return "", errNoPostKey
+	}
+	head, body := splitFrontmatter(content)
+	encoded := strings.TrimSpace(body)
+	encoded = strings.TrimPrefix(encoded, sealedBegin)
+	encoded = strings.TrimSuffix(encoded, sealedEnd)
+	data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(encoded), ""))
+	if err != nil {
+		return "", fmt.Errorf("decoding encrypted post: %w", err)
+	}
+	gcm, err := postGCM()
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", errors.New("decrypting post: too short")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypting post: %w", err)
+	}
+	return head + string(plain), nil
+}
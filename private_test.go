@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testPostKey(t *testing.T) {
+	t.Helper()
+	key, err := parsePostKey("MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := postKey
+	postKey = key
+	t.Cleanup(func() { postKey = old })
+}
+
+func TestParsePostKey(t *testing.T) {
+	tests := []struct {
+		value   string
+		wantKey bool
+		wantErr bool
+	}{
+		{"", false, false},
+		{"MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=", true, false},
+		{"c2hvcnQ=", false, true},
+		{"not base64!", false, true},
+	}
+	for _, tt := range tests {
+		key, err := parsePostKey(tt.value)
+		if (err != nil) != tt.wantErr || (key != nil) != tt.wantKey {
+			t.Errorf("parsePostKey(%q) = %v, %v", tt.value, key, err)
+		}
+	}
+}
+
+func TestSealPost(t *testing.T) {
+	testPostKey(t)
+	private := "---\ntitle: Diary\nprivate: true\n---\n\nDear diary, the secret is 42.\n"
+
+	sealed, err := sealPost(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sealed, "secret") || !strings.HasPrefix(sealed, "---\ntitle: Diary\nprivate: true\n---\n"+sealedBegin) {
+		t.Errorf("expected the body sealed under readable frontmatter, got %s", sealed)
+	}
+	if again, _ := sealPost(sealed); again != sealed {
+		t.Error("expected sealing to leave a sealed post alone")
+	}
+	opened, err := openPost(sealed)
+	if err != nil || opened != private {
+		t.Errorf("openPost = %q, %v; want the post as written", opened, err)
+	}
+
+	// Public posts are never sealed
+	public := "---\ntitle: Hello\n---\n\nHi"
+	if got, _ := sealPost(public); got != public {
+		t.Errorf("expected a public post unchanged, got %s", got)
+	}
+
+	// Without the key the post can't be read, and a wrong key is caught
+	postKey = nil
+	if _, err := openPost(sealed); !errors.Is(err, errNoPostKey) {
+		t.Errorf("expected errNoPostKey, got %v", err)
+	}
+	postKey = []byte("0123456789abcdef0123456789abcdeX")
+	if _, err := openPost(sealed); err == nil {
+		t.Error("expected a wrong key to fail")
+	}
+}
+
+func TestDraftStore_PrivateDrafts(t *testing.T) {
+	testPostKey(t)
+	db := newTestDB(t)
+	drafts, err := NewDraftStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	private := "---\ntitle: Diary\nprivate: true\n---\n\nDear diary, the secret is 42.\n"
+	if err := drafts.Save("diary", private); err != nil {
+		t.Fatal(err)
+	}
+
+	var stored string
+	db.QueryRow(`SELECT content FROM post_drafts WHERE slug = 'diary'`).Scan(&stored)
+	if strings.Contains(stored, "secret") {
+		t.Errorf("private draft stored in the clear: %s", stored)
+	}
+	if d, err := drafts.Get("diary"); err != nil || d.Content != private {
+		t.Errorf("Get = %q, %v; want the draft as written", d.Content, err)
+	}
+}
+
+func TestPostIndex_PrivatePosts(t *testing.T) {
+	testPostKey(t)
+	defer func(old string) { previewToken = old }(previewToken)
+	previewToken = "let-me-see"
+
+	dir := t.TempDir()
+	idx := newTestPostIndex(t, dir)
+	if err := idx.Write("diary", "---\ntitle: Diary\nprivate: true\n---\n\nThe secret is 42."); err != nil {
+		t.Fatal(err)
+	}
+
+	onDisk, _ := os.ReadFile(filepath.Join(dir, "diary.md"))
+	if strings.Contains(string(onDisk), "secret") {
+		t.Errorf("expected the body encrypted on disk, got %s", onDisk)
+	}
+	if p, _ := idx.Get("diary"); strings.Contains(p.Content, "secret") {
+		t.Error("expected the index to hold the sealed post")
+	}
+	if len(idx.Posts()) != 0 {
+		t.Errorf("expected private posts to stay unlisted, got %+v", idx.Posts())
+	}
+
+	for _, tt := range []struct {
+		url        string
+		wantStatus int
+	}{
+		{"/posts/diary", http.StatusNotFound},
+		{"/posts/diary?preview=let-me-see", http.StatusOK},
+	} {
+		req := httptest.NewRequest("GET", tt.url, nil)
+		req.SetPathValue("slug", "diary")
+		w := httptest.NewRecorder()
+		PostHandler(idx, nil, nil)(w, req)
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: expected status %d, got %d", tt.url, tt.wantStatus, w.Code)
+		}
+		if tt.wantStatus == http.StatusOK && !strings.Contains(w.Body.String(), "The secret is 42.") {
+			t.Errorf("%s: expected the decrypted post, got %s", tt.url, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// projectsFile lists the projects shown on /projects
+const projectsFile = "data/projects.yaml"
+
+// Localized is text with per-language variants. In YAML it is either a plain
+// string used for every language or a map such as {en: ..., th: ...}.
+type Localized map[string]string
+
+// UnmarshalYAML accepts a plain string as well as a language map
+func (l *Localized) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*l = Localized{"": node.Value}
+		return nil
+	}
+	var m map[string]string
+	if err := node.Decode(&m); err != nil {
+		return err
+	}
+	*l = m
+	return nil
+}
+
+// In returns the text for lang, falling back to English, then to any variant
+func (l Localized) In(lang string) string {
+	for _, key := range []string{lang, "", "en"} {
+		if s, ok := l[key]; ok {
+			return s
+		}
+	}
+	for _, s := range l {
+		return s
+	}
+	return ""
+}
+
+// Project is one entry on the projects page
+type Project struct {
+	Name        Localized `yaml:"name"`
+	Description Localized `yaml:"description"`
+	// Repo and URL are the source code and a live site or demo
+	Repo string `yaml:"repo"`
+	URL  string `yaml:"url"`
+	// Status is active, maintained, paused, or archived
+	Status     string `yaml:"status"`
+	Screenshot string `yaml:"screenshot"`
+}
+
+// projectStatusLabels names the known project statuses per language
+var projectStatusLabels = map[string]Localized{
+	"active":     {"en": "Active", "th": "กำลังพัฒนา"},
+	"maintained": {"en": "Maintained", "th": "ดูแลอยู่"},
+	"paused":     {"en": "Paused", "th": "พักไว้"},
+	"archived":   {"en": "Archived", "th": "เก็บถาวร"},
+}
+
+var (
+	projectWebsiteLabel = Localized{"en": "Website", "th": "เว็บไซต์"}
+	projectSourceLabel  = Localized{"en": "Source code", "th": "ซอร์สโค้ด"}
+)
+
+// loadProjects reads the projects data file; a missing file means no projects
+func loadProjects(path string) ([]Project, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var projects []Project
+	if err := yaml.Unmarshal(data, &projects); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return projects, nil
+}
+
+// requestLang returns the reader's language from the /th/ or /en/ tree the
+// page is in or, outside the trees, from ?lang= or the lang cookie, defaulting
+// to Thai
+func requestLang(r *http.Request) string {
+	if lang := pathLang(r); lang != "" {
+		return lang
+	}
+	if lang := r.URL.Query().Get(prefLang); slices.Contains(pathLanguages, lang) {
+		return lang
+	}
+	if lang := prefCookie(r, prefLang, pathLanguages); lang != "" {
+		return lang
+	}
+	return defaultLang()
+}
+
+// projectHTML renders one project card in lang
+func projectHTML(p Project, lang string) string {
+	var b strings.Builder
+	b.WriteString("<article class=\"project\">\n")
+	if p.Screenshot != "" && (strings.HasPrefix(p.Screenshot, "/") || linkPostURL(p.Screenshot) != "") {
+		b.WriteString("<img class=\"project-screenshot\" src=\"" + template.HTMLEscapeString(p.Screenshot) + "\" alt=\"\" loading=\"lazy\">\n")
+	}
+	b.WriteString("<h2>" + template.HTMLEscapeString(p.Name.In(lang)))
+	if label, ok := projectStatusLabels[p.Status]; ok {
+		b.WriteString(" <span class=\"project-status project-status-" + p.Status + "\">" + template.HTMLEscapeString(label.In(lang)) + "</span>")
+	}
+	b.WriteString("</h2>\n")
+	if desc := p.Description.In(lang); desc != "" {
+		html, err := renderMarkdown(desc, "projects")
+		if err != nil {
+			slog.Error("Error rendering project", "project", p.Name.In("en"), "err", err)
+			html = "<p>" + template.HTMLEscapeString(desc) + "</p>"
+		}
+		b.WriteString(html)
+	}
+
+	var links []string
+	if u := linkPostURL(p.URL); u != "" {
+		links = append(links, "<a href=\""+template.HTMLEscapeString(u)+"\" rel=\"noopener\">"+projectWebsiteLabel.In(lang)+"</a>")
+	}
+	if u := linkPostURL(p.Repo); u != "" {
+		links = append(links, "<a href=\""+template.HTMLEscapeString(u)+"\" rel=\"noopener\">"+projectSourceLabel.In(lang)+"</a>")
+	}
+	if len(links) > 0 {
+		b.WriteString("<p class=\"project-links\">" + strings.Join(links, " · ") + "</p>\n")
+	}
+	b.WriteString("</article>\n")
+	return b.String()
+}
+
+// ProjectsHandler renders the portfolio from the projects data file
+func ProjectsHandler(path string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setSecurityHeaders(w)
+
+		projects, err := loadProjects(path)
+		if err != nil {
+			slog.Error("Error loading projects", "err", err)
+			renderError(w, http.StatusInternalServerError, requestLang(r))
+			return
+		}
+
+		lang := requestLang(r)
+		heading := "Projects"
+		if lang == "th" {
+			heading = "โปรเจกต์"
+		}
+
+		var content bytes.Buffer
+		content.WriteString("<h1>" + heading + "</h1>\n")
+		content.WriteString("<div class=\"projects\">\n")
+		for _, p := range projects {
+			content.WriteString(projectHTML(p, lang))
+		}
+		content.WriteString("</div>\n")
+		renderPage(w, heading, template.HTML(content.String()))
+	}
+}
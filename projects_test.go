@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalized(t *testing.T) {
+	tests := []struct {
+		name string
+		l    Localized
+		lang string
+		want string
+	}{
+		{"exact", Localized{"en": "Hi", "th": "สวัสดี"}, "th", "สวัสดี"},
+		{"plain string", Localized{"": "Same"}, "th", "Same"},
+		{"falls back to English", Localized{"en": "Hi"}, "th", "Hi"},
+		{"empty", nil, "en", ""},
+	}
+	for _, tt := range tests {
+		if got := tt.l.In(tt.lang); got != tt.want {
+			t.Errorf("%s: In(%q) = %q, want %q", tt.name, tt.lang, got, tt.want)
+		}
+	}
+}
+
+func TestProjectsHandler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "projects.yaml")
+	os.WriteFile(path, []byte(`
+- name: Blog
+  description:
+    en: A *blog*.
+    th: บล็อก
+  repo: https://github.com/me/blog
+  status: active
+  screenshot: /images/blog.png
+- name: Old thing
+  description: Retired.
+  repo: javascript:alert(1)
+  status: archived
+`), 0644)
+
+	tests := []struct {
+		target string
+		want   []string
+		not    []string
+	}{
+		{
+			target: "/projects?lang=en",
+			want: []string{
+				"<h1>Projects</h1>",
+				"<em>blog</em>",
+				`<span class="project-status project-status-active">Active</span>`,
+				`<a href="https://github.com/me/blog" rel="noopener">Source code</a>`,
+				`src="/images/blog.png"`,
+				"Retired.",
+			},
+			not: []string{"javascript:"},
+		},
+		{
+			target: "/projects?lang=th",
+			want:   []string{"โปรเจกต์", "บล็อก", "เก็บถาวร", "Retired."},
+		},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		ProjectsHandler(path)(w, httptest.NewRequest("GET", tt.target, nil))
+		body := w.Body.String()
+		for _, want := range tt.want {
+			if !strings.Contains(body, want) {
+				t.Errorf("%s: missing %q in %s", tt.target, want, body)
+			}
+		}
+		for _, not := range tt.not {
+			if strings.Contains(body, not) {
+				t.Errorf("%s: unexpected %q", tt.target, not)
+			}
+		}
+	}
+}
+
+func TestProjectsHandler_MissingFile(t *testing.T) {
+	w := httptest.NewRecorder()
+	ProjectsHandler(filepath.Join(t.TempDir(), "none.yaml"))(w, httptest.NewRequest("GET", "/projects", nil))
+	if w.Code != 200 {
+		t.Errorf("status = %d, want 200", w.Code)
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// validPrefixRegex matches a mount path such as /blog or /sites/blog
+var validPrefixRegex = regexp.MustCompile(`^(/[A-Za-z0-9._~-]+)+$`)
+
+// forwardedPrefix returns the path a trusted proxy mounted the site under, taken
+// from X-Forwarded-Prefix, or "" when there is none or proxies aren't trusted
+func forwardedPrefix(r *http.Request) string {
+	if !cfg.Proxy.TrustForwardedPrefix {
+		return ""
+	}
+	prefix := strings.TrimRight(r.Header.Get("X-Forwarded-Prefix"), "/")
+	if !validPrefixRegex.MatchString(prefix) {
+		return ""
+	}
+	return prefix
+}
+
+// rootRelativeAttrRegex matches URL attributes that start at the site root
+// ("/posts/x" but not "//cdn.example/x")
+var rootRelativeAttrRegex = regexp.MustCompile(`(\s(?:href|src|action|poster|formaction)=")(/(?:[^/"][^"]*)?")`)
+
+// srcsetAttrRegex matches srcset attributes, whose value is a list of URLs
+var srcsetAttrRegex = regexp.MustCompile(`(\ssrcset=")([^"]*)(")`)
+
+// cssURLRegex matches root-relative url() references in stylesheets
+var cssURLRegex = regexp.MustCompile(`url\((['"]?)(/[^/'")][^'")]*)(['"]?)\)`)
+
+// prefixLinks rewrites root-relative links in an HTML or CSS document to sit under prefix
+func prefixLinks(body []byte, contentType, prefix string) []byte {
+	if strings.HasPrefix(contentType, "text/css") {
+		return cssURLRegex.ReplaceAll(body, []byte("url(${1}"+prefix+"${2}${3})"))
+	}
+	body = rootRelativeAttrRegex.ReplaceAll(body, []byte("${1}"+prefix+"${2}"))
+	return srcsetAttrRegex.ReplaceAllFunc(body, func(m []byte) []byte {
+		parts := srcsetAttrRegex.FindSubmatch(m)
+		candidates := strings.Split(string(parts[2]), ",")
+		for i, c := range candidates {
+			trimmed := strings.TrimLeft(c, " ")
+			if strings.HasPrefix(trimmed, "/") && !strings.HasPrefix(trimmed, "//") {
+				candidates[i] = c[:len(c)-len(trimmed)] + prefix + trimmed
+			}
+		}
+		return []byte(string(parts[1]) + strings.Join(candidates, ",") + string(parts[3]))
+	})
+}
+
+// prefixLocation puts a root-relative redirect target under prefix
+func prefixLocation(loc, prefix string) string {
+	if strings.HasPrefix(loc, "/") && !strings.HasPrefix(loc, "//") {
+		return prefix + loc
+	}
+	return loc
+}
+
+// prefixCookies scopes every cookie being set to the prefix
+func prefixCookies(h http.Header, prefix string) {
+	lines := h.Values("Set-Cookie")
+	if len(lines) == 0 {
+		return
+	}
+	h.Del("Set-Cookie")
+	for _, line := range lines {
+		c, err := http.ParseSetCookie(line)
+		if err != nil {
+			h.Add("Set-Cookie", line)
+			continue
+		}
+		// An empty path already defaults to the prefixed URL the browser asked for
+		switch {
+		case c.Path == "/":
+			c.Path = prefix
+		case strings.HasPrefix(c.Path, "/"):
+			c.Path = prefix + c.Path
+		}
+		h.Add("Set-Cookie", c.String())
+	}
+}
+
+// prefixWriter applies a path prefix to a response: redirects and cookie paths
+// always, and links in HTML and CSS bodies, which it buffers to rewrite
+type prefixWriter struct {
+	http.ResponseWriter
+	prefix string
+	// keepCookiePaths leaves cookies scoped to the whole site
+	keepCookiePaths bool
+	// headTags are added to the start of <head> in HTML pages
+	headTags string
+	// postLang shortens links to posts in this language, see localPostLinks
+	postLang    string
+	head        bool
+	status      int
+	wroteHeader bool
+	buffering   bool
+	buf         bytes.Buffer
+}
+
+func (pw *prefixWriter) WriteHeader(status int) {
+	if pw.wroteHeader {
+		return
+	}
+	pw.wroteHeader, pw.status = true, status
+	h := pw.Header()
+	if loc := h.Get("Location"); loc != "" {
+		if pw.postLang != "" {
+			if rest, ok := strings.CutPrefix(loc, "/posts/"+pw.postLang+"-"); ok {
+				loc = "/posts/" + rest
+			}
+		}
+		h.Set("Location", prefixLocation(loc, pw.prefix))
+	}
+	if !pw.keepCookiePaths {
+		prefixCookies(h, pw.prefix)
+	}
+
+	ct := h.Get("Content-Type")
+	rewritable := strings.HasPrefix(ct, "text/html") || strings.HasPrefix(ct, "text/css")
+	// Partial and unchanged responses must go through byte for byte
+	if !rewritable || status == http.StatusPartialContent || status == http.StatusNotModified {
+		pw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	h.Del("Content-Length")
+	if pw.head {
+		pw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	pw.buffering = true
+}
+
+func (pw *prefixWriter) Write(p []byte) (int, error) {
+	if !pw.wroteHeader {
+		if pw.Header().Get("Content-Type") == "" {
+			pw.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		pw.WriteHeader(http.StatusOK)
+	}
+	if pw.buffering {
+		return pw.buf.Write(p)
+	}
+	return pw.ResponseWriter.Write(p)
+}
+
+// finish sends a buffered body with its links rewritten
+func (pw *prefixWriter) finish() {
+	if !pw.wroteHeader {
+		// Nothing was written; still fix up the headers of the empty response
+		pw.WriteHeader(http.StatusOK)
+	}
+	if !pw.buffering {
+		return
+	}
+	ct := pw.Header().Get("Content-Type")
+	body := pw.buf.Bytes()
+	if pw.postLang != "" && strings.HasPrefix(ct, "text/html") {
+		body = localPostLinks(body, pw.postLang)
+	}
+	if pw.prefix != "" {
+		body = prefixLinks(body, ct, pw.prefix)
+	}
+	if pw.headTags != "" && strings.HasPrefix(ct, "text/html") {
+		if i := bytes.Index(body, []byte("<head>")); i >= 0 {
+			i += len("<head>")
+			body = append(body[:i:i], append([]byte("\n"+pw.headTags), body[i:]...)...)
+		}
+	}
+	pw.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	pw.ResponseWriter.WriteHeader(pw.status)
+	pw.ResponseWriter.Write(body)
+}
+
+// ForwardedPrefix lets the site run unmodified under a subpath: when a trusted
+// proxy strips a prefix such as /blog and reports it in X-Forwarded-Prefix, the
+// prefix is put back on links, redirects, and cookie paths
+func ForwardedPrefix(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix := forwardedPrefix(r)
+		if prefix == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		pw := &prefixWriter{ResponseWriter: w, prefix: prefix, head: r.Method == http.MethodHead}
+		next.ServeHTTP(pw, r)
+		pw.finish()
+	})
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestForwardedPrefix(t *testing.T) {
+	defer func(old ProxyConfig) { cfg.Proxy = old }(cfg.Proxy)
+	cfg.Proxy.TrustForwardedPrefix = true
+
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"/blog", "/blog"},
+		{"/blog/", "/blog"},
+		{"/sites/blog", "/sites/blog"},
+		{"", ""},
+		{"blog", ""},
+		{"//evil.example", ""},
+		{"/a b", ""},
+		{`/"><script>`, ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-Forwarded-Prefix", tt.header)
+		if got := forwardedPrefix(r); got != tt.want {
+			t.Errorf("forwardedPrefix(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+
+	cfg.Proxy.TrustForwardedPrefix = false
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Forwarded-Prefix", "/blog")
+	if got := forwardedPrefix(r); got != "" {
+		t.Errorf("untrusted prefix used: %q", got)
+	}
+}
+
+func TestPrefixLinks(t *testing.T) {
+	html := `<a href="/posts/x">x</a> <a href="//cdn.example/y">y</a> <a href="https://go.dev/">go</a> <a href="/">home</a>` +
+		`<form action="/saved"><img src="/images/a.jpg" srcset="/images/a.jpg?w=400 400w, https://x.example/b.jpg 800w">`
+	got := string(prefixLinks([]byte(html), "text/html; charset=utf-8", "/blog"))
+	for _, want := range []string{
+		`href="/blog/posts/x"`, `href="//cdn.example/y"`, `href="https://go.dev/"`, `href="/blog/"`,
+		`action="/blog/saved"`, `src="/blog/images/a.jpg"`, `srcset="/blog/images/a.jpg?w=400 400w, https://x.example/b.jpg 800w"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %s in %s", want, got)
+		}
+	}
+
+	css := `body { background: url(/static/bg.png) } .a { background: url("//cdn.example/x.png") }`
+	if got := string(prefixLinks([]byte(css), "text/css; charset=utf-8", "/blog")); !strings.Contains(got, "url(/blog/static/bg.png)") || !strings.Contains(got, `url("//cdn.example/x.png")`) {
+		t.Errorf("css = %s", got)
+	}
+}
+
+func TestForwardedPrefixMiddleware(t *testing.T) {
+	defer func(old ProxyConfig) { cfg.Proxy = old }(cfg.Proxy)
+	cfg.Proxy.TrustForwardedPrefix = true
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /page", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "lang", Value: "en", Path: "/"})
+		renderPage(w, "Page", `<a href="/posts/hello">Hello</a>`)
+	})
+	mux.HandleFunc("POST /go", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/saved", http.StatusSeeOther)
+	})
+	mux.HandleFunc("GET /data", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"url":"/posts/hello","base":"`+siteURL(r)+`"}`)
+	})
+	h := ForwardedPrefix(mux)
+
+	serve := func(method, path, prefix string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "http://example.com"+path, nil)
+		if prefix != "" {
+			r.Header.Set("X-Forwarded-Prefix", prefix)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve("GET", "/page", "/blog")
+	body := w.Body.String()
+	if !strings.Contains(body, `href="/blog/posts/hello"`) || !strings.Contains(body, `href="/blog/static/style.css"`) {
+		t.Errorf("links not prefixed: %s", body)
+	}
+	if w.Header().Get("Content-Length") != strconv.Itoa(len(body)) {
+		t.Errorf("Content-Length %s for %d bytes", w.Header().Get("Content-Length"), len(body))
+	}
+	if c := w.Header().Get("Set-Cookie"); !strings.Contains(c, "Path=/blog") || strings.Contains(c, "Path=/blog/") {
+		t.Errorf("cookie = %q", c)
+	}
+
+	if w := serve("POST", "/go", "/blog"); w.Header().Get("Location") != "/blog/saved" {
+		t.Errorf("Location = %q", w.Header().Get("Location"))
+	}
+
+	w = serve("GET", "/data", "/blog")
+	if body := w.Body.String(); body != `{"url":"/posts/hello","base":"http://example.com/blog"}` {
+		t.Errorf("json = %s", body)
+	}
+
+	// Without the header the response is untouched
+	w = serve("GET", "/page", "")
+	if !strings.Contains(w.Body.String(), `href="/posts/hello"`) || strings.Contains(w.Header().Get("Set-Cookie"), "/blog") {
+		t.Errorf("unprefixed response changed: %v %s", w.Header(), w.Body.String())
+	}
+}
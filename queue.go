@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Job statuses
+const (
+	JobPending = "pending"
+	JobDead    = "dead"
+)
+
+// Job is a unit of deferred work stored in the queue
+type Job struct {
+	ID        int64
+	Kind      string
+	Payload   string
+	Status    string
+	Attempts  int
+	LastError string
+	RunAt     time.Time
+	CreatedAt time.Time
+}
+
+// JobHandler processes the JSON payload of a queued job
+type JobHandler func(ctx context.Context, payload []byte) error
+
+// JobQueue is a persistent, SQLite-backed queue for work that should not block request handlers
+type JobQueue struct {
+	db       *sql.DB
+	mu       sync.RWMutex
+	handlers map[string]JobHandler
+	timeouts map[string]time.Duration
+
+	// MaxAttempts is how many times a job runs before it is moved to the dead-letter list
+	MaxAttempts int
+	// Backoff is the delay before the first retry; it doubles on every further attempt
+	Backoff time.Duration
+	// PollInterval is how long the worker sleeps when no job is due
+	PollInterval time.Duration
+	// Timeout bounds a single job run, unless its kind was registered with its own
+	Timeout time.Duration
+}
+
+// ErrJobNotFound is returned when a job ID does not exist
+var ErrJobNotFound = errors.New("job not found")
+
+const jobsSchema = `CREATE TABLE IF NOT EXISTS jobs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	kind TEXT NOT NULL,
+	payload TEXT NOT NULL,
+	status TEXT NOT NULL DEFAULT 'pending',
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	run_at INTEGER NOT NULL,
+	created_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS jobs_due ON jobs (status, run_at);`
+
+// NewJobQueue creates the jobs table if needed and returns a queue with default settings
+func NewJobQueue(db *sql.DB) (*JobQueue, error) {
+	if _, err := db.Exec(jobsSchema); err != nil {
+		return nil, fmt.Errorf("create jobs table: %w", err)
+	}
+	return &JobQueue{
+		db:           db,
+		handlers:     make(map[string]JobHandler),
+		timeouts:     make(map[string]time.Duration),
+		MaxAttempts:  5,
+		Backoff:      30 * time.Second,
+		PollInterval: 2 * time.Second,
+		Timeout:      time.Minute,
+	}, nil
+}
+
+// Register sets the handler for a job kind
+func (q *JobQueue) Register(kind string, h JobHandler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[kind] = h
+}
+
+// RegisterTimeout sets the handler for a job kind whose runs may take longer
+// or shorter than the queue's Timeout
+func (q *JobQueue) RegisterTimeout(kind string, h JobHandler, timeout time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[kind] = h
+	q.timeouts[kind] = timeout
+}
+
+// timeout returns how long a job of kind may run
+func (q *JobQueue) timeout(kind string) time.Duration {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if t, ok := q.timeouts[kind]; ok {
+		return t
+	}
+	return q.Timeout
+}
+
+// Enqueue stores a job to be run as soon as a worker is free
+func (q *JobQueue) Enqueue(kind string, payload any) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("encode %s job: %w", kind, err)
+	}
+	now := time.Now().Unix()
+	_, err = q.db.Exec(`INSERT INTO jobs (kind, payload, run_at, created_at) VALUES (?, ?, ?, ?)`,
+		kind, string(b), now, now)
+	if err != nil {
+		return fmt.Errorf("enqueue %s job: %w", kind, err)
+	}
+	return nil
+}
+
+// EnqueueUnique stores a job unless an identical one is already waiting or dead
+func (q *JobQueue) EnqueueUnique(kind string, payload any) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("encode %s job: %w", kind, err)
+	}
+	var exists bool
+	err = q.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM jobs WHERE kind = ? AND payload = ?)`,
+		kind, string(b)).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("enqueue %s job: %w", kind, err)
+	}
+	if exists {
+		return nil
+	}
+	return q.Enqueue(kind, payload)
+}
+
+// Run processes due jobs until the context is cancelled
+func (q *JobQueue) Run(ctx context.Context) {
+	for {
+		processed, err := q.RunOnce(ctx)
+		if err != nil {
+			slog.Error("Job queue error", "err", err)
+		}
+		if processed {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(q.PollInterval):
+		}
+	}
+}
+
+// RunOnce runs the oldest due job, if any, and reports whether one was processed
+func (q *JobQueue) RunOnce(ctx context.Context) (bool, error) {
+	now := time.Now()
+
+	var job Job
+	err := q.db.QueryRow(`SELECT id, kind, payload, attempts FROM jobs
+		WHERE status = ? AND run_at <= ? ORDER BY run_at, id LIMIT 1`,
+		JobPending, now.Unix()).Scan(&job.ID, &job.Kind, &job.Payload, &job.Attempts)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("fetch job: %w", err)
+	}
+
+	// Lease the job so a crash mid-run leads to a retry rather than a lost job
+	job.Attempts++
+	lease := now.Add(q.timeout(job.Kind) + q.Backoff)
+	if _, err := q.db.Exec(`UPDATE jobs SET attempts = ?, run_at = ? WHERE id = ?`,
+		job.Attempts, lease.Unix(), job.ID); err != nil {
+		return false, fmt.Errorf("lease job %d: %w", job.ID, err)
+	}
+
+	runErr := q.run(ctx, job)
+	if runErr == nil {
+		if _, err := q.db.Exec(`DELETE FROM jobs WHERE id = ?`, job.ID); err != nil {
+			return true, fmt.Errorf("complete job %d: %w", job.ID, err)
+		}
+		return true, nil
+	}
+
+	slog.Warn("Job attempt failed", "id", job.ID, "kind", job.Kind, "attempt", job.Attempts, "err", runErr)
+
+	if job.Attempts >= q.MaxAttempts {
+		_, err = q.db.Exec(`UPDATE jobs SET status = ?, last_error = ? WHERE id = ?`,
+			JobDead, runErr.Error(), job.ID)
+	} else {
+		delay := q.Backoff << (job.Attempts - 1)
+		_, err = q.db.Exec(`UPDATE jobs SET last_error = ?, run_at = ? WHERE id = ?`,
+			runErr.Error(), now.Add(delay).Unix(), job.ID)
+	}
+	if err != nil {
+		return true, fmt.Errorf("record failure of job %d: %w", job.ID, err)
+	}
+	return true, nil
+}
+
+// run dispatches a job to its registered handler
+func (q *JobQueue) run(ctx context.Context, job Job) error {
+	q.mu.RLock()
+	h, ok := q.handlers[job.Kind]
+	q.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("no handler registered for job kind %q", job.Kind)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, q.timeout(job.Kind))
+	defer cancel()
+	return h(ctx, []byte(job.Payload))
+}
+
+// DeadJobs lists jobs that exhausted their retries, newest first
+func (q *JobQueue) DeadJobs() ([]Job, error) {
+	rows, err := q.db.Query(`SELECT id, kind, payload, status, attempts, last_error, run_at, created_at
+		FROM jobs WHERE status = ? ORDER BY id DESC`, JobDead)
+	if err != nil {
+		return nil, fmt.Errorf("list dead jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []Job
+	for rows.Next() {
+		var j Job
+		var runAt, createdAt int64
+		if err := rows.Scan(&j.ID, &j.Kind, &j.Payload, &j.Status, &j.Attempts, &j.LastError, &runAt, &createdAt); err != nil {
+			return nil, fmt.Errorf("list dead jobs: %w", err)
+		}
+		j.RunAt = time.Unix(runAt, 0)
+		j.CreatedAt = time.Unix(createdAt, 0)
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+// DeadCount returns how many jobs exhausted their retries
+func (q *JobQueue) DeadCount() (int, error) {
+	var n int
+	if err := q.db.QueryRow(`SELECT COUNT(*) FROM jobs WHERE status = ?`, JobDead).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count dead jobs: %w", err)
+	}
+	return n, nil
+}
+
+// Retry moves a dead job back to the pending list with a fresh set of attempts
+func (q *JobQueue) Retry(id int64) error {
+	res, err := q.db.Exec(`UPDATE jobs SET status = ?, attempts = 0, run_at = ? WHERE id = ? AND status = ?`,
+		JobPending, time.Now().Unix(), id, JobDead)
+	if err != nil {
+		return fmt.Errorf("retry job %d: %w", id, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrJobNotFound
+	}
+	return nil
+}
+
+// Delete removes a job permanently
+func (q *JobQueue) Delete(id int64) error {
+	res, err := q.db.Exec(`DELETE FROM jobs WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("delete job %d: %w", id, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrJobNotFound
+	}
+	return nil
+}
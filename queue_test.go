@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestDB opens a fresh SQLite database in a temp directory
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := openDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("openDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func newTestQueue(t *testing.T) *JobQueue {
+	t.Helper()
+	q, err := NewJobQueue(newTestDB(t))
+	if err != nil {
+		t.Fatalf("NewJobQueue: %v", err)
+	}
+	q.Backoff = 0
+	q.MaxAttempts = 2
+	return q
+}
+
+func TestJobQueue_RunsRegisteredHandler(t *testing.T) {
+	q := newTestQueue(t)
+
+	var got string
+	q.Register("greet", func(ctx context.Context, payload []byte) error {
+		got = string(payload)
+		return nil
+	})
+
+	if err := q.Enqueue("greet", map[string]string{"name": "world"}); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	processed, err := q.RunOnce(context.Background())
+	if err != nil || !processed {
+		t.Fatalf("RunOnce = %v, %v; want true, nil", processed, err)
+	}
+	if got != `{"name":"world"}` {
+		t.Errorf("handler got payload %q", got)
+	}
+
+	// Completed jobs are removed from the queue
+	processed, _ = q.RunOnce(context.Background())
+	if processed {
+		t.Error("expected queue to be empty after successful job")
+	}
+}
+
+func TestJobQueue_TimeoutPerKind(t *testing.T) {
+	q := newTestQueue(t)
+	deadlines := map[string]time.Duration{}
+	record := func(kind string) JobHandler {
+		return func(ctx context.Context, payload []byte) error {
+			d, _ := ctx.Deadline()
+			deadlines[kind] = time.Until(d)
+			return nil
+		}
+	}
+	q.Register("email", record("email"))
+	q.RegisterTimeout("tts", record("tts"), 5*time.Minute)
+	q.Enqueue("email", nil)
+	q.Enqueue("tts", nil)
+	for range 2 {
+		q.RunOnce(context.Background())
+	}
+
+	if d := deadlines["email"]; d > q.Timeout || d < q.Timeout-time.Second {
+		t.Errorf("email deadline in %v, want the queue's %v", d, q.Timeout)
+	}
+	if d := deadlines["tts"]; d < 4*time.Minute {
+		t.Errorf("tts deadline in %v, want its own 5m", d)
+	}
+}
+
+func TestJobQueue_DeadLetterAndRetry(t *testing.T) {
+	q := newTestQueue(t)
+
+	fail := true
+	q.Register("flaky", func(ctx context.Context, payload []byte) error {
+		if fail {
+			return errors.New("smtp unavailable")
+		}
+		return nil
+	})
+
+	if err := q.Enqueue("flaky", nil); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	for i := 0; i < q.MaxAttempts; i++ {
+		if _, err := q.RunOnce(context.Background()); err != nil {
+			t.Fatalf("RunOnce: %v", err)
+		}
+	}
+
+	dead, err := q.DeadJobs()
+	if err != nil {
+		t.Fatalf("DeadJobs: %v", err)
+	}
+	if len(dead) != 1 {
+		t.Fatalf("expected 1 dead job, got %d", len(dead))
+	}
+	if dead[0].LastError != "smtp unavailable" || dead[0].Attempts != 2 {
+		t.Errorf("unexpected dead job %+v", dead[0])
+	}
+	if n, err := q.DeadCount(); n != 1 || err != nil {
+		t.Errorf("DeadCount = %d, %v", n, err)
+	}
+
+	// Dead jobs are not picked up again until retried
+	if processed, _ := q.RunOnce(context.Background()); processed {
+		t.Error("dead job should not be processed")
+	}
+
+	fail = false
+	if err := q.Retry(dead[0].ID); err != nil {
+		t.Fatalf("Retry: %v", err)
+	}
+	if processed, err := q.RunOnce(context.Background()); err != nil || !processed {
+		t.Fatalf("RunOnce after retry = %v, %v", processed, err)
+	}
+	if dead, _ := q.DeadJobs(); len(dead) != 0 {
+		t.Errorf("expected no dead jobs after successful retry, got %d", len(dead))
+	}
+}
+
+func TestJobQueue_RetryUnknownJob(t *testing.T) {
+	q := newTestQueue(t)
+	if err := q.Retry(42); err != ErrJobNotFound {
+		t.Errorf("Retry(42) = %v, want ErrJobNotFound", err)
+	}
+}
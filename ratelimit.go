@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RateLimiter allows each key (usually a client IP) a number of events per window.
+// State is in memory, so limits reset on restart.
+type RateLimiter struct {
+	Limit  int
+	Window time.Duration
+
+	mu     sync.Mutex
+	events map[string][]time.Time
+}
+
+// NewRateLimiter returns a limiter allowing limit events per window
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{Limit: limit, Window: window, events: make(map[string][]time.Time)}
+}
+
+// Allow records an event for key and reports whether it is within the limit
+func (l *RateLimiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	recent := l.events[key][:0]
+	for _, t := range l.events[key] {
+		if now.Sub(t) < l.Window {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= l.Limit {
+		l.events[key] = recent
+		return false
+	}
+	l.events[key] = append(recent, now)
+
+	// Drop idle keys now and then so the map doesn't grow forever
+	if len(l.events) > 10000 {
+		for k, ts := range l.events {
+			if len(ts) == 0 || now.Sub(ts[len(ts)-1]) >= l.Window {
+				delete(l.events, k)
+			}
+		}
+	}
+	return true
+}
+
+// Blocked reports whether key has used up its events in the current window,
+// without recording one
+func (l *RateLimiter) Blocked(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	n := 0
+	for _, t := range l.events[key] {
+		if now.Sub(t) < l.Window {
+			n++
+		}
+	}
+	return n >= l.Limit
+}
+
+// clientIP returns the address the request came from
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
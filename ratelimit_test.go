@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := NewRateLimiter(2, time.Hour)
+	for i, want := range []bool{true, true, false} {
+		if got := l.Allow("1.2.3.4"); got != want {
+			t.Errorf("event %d: Allow = %v, want %v", i, got, want)
+		}
+	}
+	if !l.Allow("5.6.7.8") {
+		t.Error("a different key should have its own limit")
+	}
+
+	l.Window = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	if !l.Allow("1.2.3.4") {
+		t.Error("events outside the window should not count")
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"strings"
+)
+
+// readLaterEmail is the data for the read_later email template
+type readLaterEmail struct {
+	Title string
+	URL   string
+	// HTML is the rendered post with its links made absolute; Text is the
+	// plain-text alternative
+	HTML template.HTML
+	Text string
+}
+
+// ReadLaterHandler emails a post to the address a reader enters, so a long
+// tutorial can be read later. Sends are limited per client and per address;
+// the email holds only the post, so the form can't be used to send anything else.
+func ReadLaterHandler(sl SlugReader, queue *JobQueue, emails *EmailRenderer, perClient, perAddress *RateLimiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setSecurityHeaders(w)
+
+		slug := r.PathValue("slug")
+		if !IsValidSlug(slug) {
+			http.Error(w, "Invalid post slug", http.StatusBadRequest)
+			return
+		}
+		postMarkdown, err := sl.Read(slug)
+		if err != nil {
+			http.Error(w, "Post not found", http.StatusNotFound)
+			return
+		}
+		fm, body := ParseFrontmatter(postMarkdown)
+		if !postVisible(fm) {
+			http.Error(w, "Post not found", http.StatusNotFound)
+			return
+		}
+
+		to := strings.TrimSpace(r.PostFormValue("email"))
+		if addr, err := mail.ParseAddress(to); err != nil || addr.Address != to {
+			http.Error(w, "Please enter a valid email address", http.StatusBadRequest)
+			return
+		}
+		if !perClient.Allow(clientIP(r)) || !perAddress.Allow(strings.ToLower(to)) {
+			http.Error(w, "Too many emails, please try again later", http.StatusTooManyRequests)
+			return
+		}
+
+		html, err := renderMarkdownCached(body, slug)
+		if err != nil {
+			slog.Error("Error rendering post", "slug", slug, "err", err)
+			http.Error(w, "Error rendering post", http.StatusInternalServerError)
+			return
+		}
+		root := siteRoot(r)
+		data := readLaterEmail{
+			Title: fm.Title,
+			URL:   root + postTreePath(slug, postFileLang(slug, fm)),
+			HTML:  template.HTML(prefixLinks([]byte(html), "text/html", root)),
+			Text:  plainText(html),
+		}
+		if data.Title == "" {
+			data.Title = toTitleCase(strings.ReplaceAll(slug, "-", " "))
+		}
+		lang := postFileLang(slug, fm)
+		if lang == "" {
+			lang = requestLang(r)
+		}
+		rendered, err := emails.Render("read_later", lang, data)
+		if err != nil {
+			slog.Error("Error rendering read-later email", "slug", slug, "err", err)
+			http.Error(w, "Could not send email", http.StatusInternalServerError)
+			return
+		}
+		if err := queue.Enqueue("email", rendered.Message(to)); err != nil {
+			slog.Error("Error queueing read-later email", "slug", slug, "err", err)
+			http.Error(w, "Could not send email", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/posts/"+slug+"?emailed=1", http.StatusSeeOther)
+	}
+}
+
+// readLaterHTML renders the collapsed "email me this post" form shown under a
+// post, with a confirmation once it has been sent
+func readLaterHTML(slug string, sent bool) string {
+	var b strings.Builder
+	b.WriteString("<section class=\"read-later\">\n")
+	if sent {
+		b.WriteString("<p class=\"read-later-notice\">Sent! The post is on its way to your inbox.</p>\n")
+	}
+	b.WriteString("<details>\n<summary>Email me this post</summary>\n")
+	b.WriteString("<form method=\"POST\" action=\"/posts/" + template.HTMLEscapeString(slug) + "/email\">\n")
+	b.WriteString("<label>Your email<input type=\"email\" name=\"email\" required></label>\n")
+	b.WriteString("<button type=\"submit\">Send</button>\n")
+	b.WriteString("</form>\n")
+	b.WriteString("<p>Your address is only used to send this one email.</p>\n")
+	b.WriteString("</details>\n</section>\n")
+	return b.String()
+}
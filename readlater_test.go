@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadLaterHandler(t *testing.T) {
+	db := newTestDB(t)
+	queue, err := NewJobQueue(db)
+	if err != nil {
+		t.Fatalf("NewJobQueue: %v", err)
+	}
+	var sent EmailMessage
+	queue.Register("email", func(ctx context.Context, payload []byte) error {
+		return json.Unmarshal(payload, &sent)
+	})
+
+	reader := &MockSlugReader{content: map[string]string{
+		"en-tutorial": "---\ntitle: Long Tutorial\ndate: 2025-05-01\n---\nStep one. See [the intro](/posts/intro) and ![diagram](/images/d.png).",
+		"wip":         "---\ntitle: WIP\ndraft: true\n---\nSoon.",
+	}}
+	handler := ReadLaterHandler(reader, queue, NewEmailRenderer("templates/email"), NewRateLimiter(10, time.Hour), NewRateLimiter(1, time.Hour))
+	send := func(slug, email string) *httptest.ResponseRecorder {
+		req := postForm("http://blog.example/posts/"+slug+"/email", url.Values{"email": {email}})
+		req.SetPathValue("slug", slug)
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	w := send("en-tutorial", "reader@example.com")
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/posts/en-tutorial?emailed=1" {
+		t.Fatalf("status %d, Location %q", w.Code, w.Header().Get("Location"))
+	}
+	if _, err := queue.RunOnce(context.Background()); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if len(sent.To) != 1 || sent.To[0] != "reader@example.com" || sent.Subject != "Long Tutorial" {
+		t.Errorf("unexpected email %+v", sent)
+	}
+	for _, want := range []string{`href="http://blog.example/en/posts/tutorial"`, `href="http://blog.example/posts/intro"`, `src="http://blog.example/images/d.png"`} {
+		if !strings.Contains(sent.HTML, want) {
+			t.Errorf("HTML missing %s", want)
+		}
+	}
+	if !strings.Contains(sent.Body, "Step one.") {
+		t.Errorf("plain text = %q", sent.Body)
+	}
+
+	tests := []struct {
+		name, slug, email string
+		status            int
+	}{
+		{"same address again", "en-tutorial", "Reader@example.com", http.StatusTooManyRequests},
+		{"bad address", "en-tutorial", "not an address", http.StatusBadRequest},
+		{"address with a name", "en-tutorial", "Eve <eve@example.com>", http.StatusBadRequest},
+		{"draft", "wip", "other@example.com", http.StatusNotFound},
+		{"missing post", "nope", "other@example.com", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if w := send(tt.slug, tt.email); w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.status)
+		}
+	}
+}
+
+func TestPostHandlerReadLaterForm(t *testing.T) {
+	defer func(old bool) { cfg.ReadLater.Enabled = old }(cfg.ReadLater.Enabled)
+	reader := &MockSlugReader{content: map[string]string{"hello": "---\ntitle: Hello\n---\nHi"}}
+	get := func(target string) string {
+		req := httptest.NewRequest("GET", target, nil)
+		req.SetPathValue("slug", "hello")
+		w := httptest.NewRecorder()
+		PostHandler(reader, nil, nil)(w, req)
+		return w.Body.String()
+	}
+
+	cfg.ReadLater.Enabled = false
+	if strings.Contains(get("/posts/hello"), "read-later") {
+		t.Error("form shown while disabled")
+	}
+	cfg.ReadLater.Enabled = true
+	if body := get("/posts/hello"); !strings.Contains(body, `action="/posts/hello/email"`) || strings.Contains(body, "read-later-notice") {
+		t.Error("form missing, or confirmation shown before sending")
+	}
+	if !strings.Contains(get("/posts/hello?emailed=1"), "read-later-notice") {
+		t.Error("no confirmation after sending")
+	}
+}
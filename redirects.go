@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// aliasPath returns the address a post alias stands for: an old slug is
+// /posts/{slug} and anything starting with / is a path kept as it is, e.g. a
+// permalink from a previous blog engine. It returns "" for aliases that are
+// neither.
+func aliasPath(alias string) string {
+	alias = strings.TrimSpace(alias)
+	if strings.HasPrefix(alias, "/") {
+		if strings.Contains(alias, "..") || strings.HasPrefix(alias, "//") {
+			return ""
+		}
+		if p := path.Clean(alias); p != "/" {
+			return p
+		}
+		return ""
+	}
+	if !IsValidSlug(alias) {
+		return ""
+	}
+	return "/posts/" + alias
+}
+
+// findAliases maps each address listed under a post's aliases to the post.
+// An address claimed twice goes to the first post in slug order; the clash is
+// reported as a collision. The caller must hold the lock.
+func (idx *PostIndex) findAliases() map[string]string {
+	aliases := make(map[string]string)
+	for _, slug := range idx.slugs {
+		for _, alias := range idx.entries[slug].FM.Aliases {
+			if p := aliasPath(alias); p != "" {
+				if _, taken := aliases[p]; !taken {
+					aliases[p] = slug
+				}
+			}
+		}
+	}
+	return aliases
+}
+
+// Redirect returns where a request for urlPath that no page answers should go:
+// the target under redirects in the config, or the published post that lists
+// the path among its aliases. lang is the language tree the request is in, if
+// any, so the post's address is given within it.
+func (idx *PostIndex) Redirect(urlPath, lang string) (string, bool) {
+	if target, ok := cfg.Redirects[urlPath]; ok {
+		return target, true
+	}
+	if idx == nil {
+		return "", false
+	}
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	slug, ok := idx.aliases[urlPath]
+	if !ok {
+		return "", false
+	}
+	p, ok := idx.entries[slug]
+	if !ok || !postVisible(p.FM) {
+		return "", false
+	}
+	if lang != "" {
+		return "/posts/" + localSlug(slug, lang), true
+	}
+	return postTreePath(slug, p.Lang), true
+}
+
+// redirectFinder knows the old addresses of posts, as PostIndex does
+type redirectFinder interface {
+	Redirect(urlPath, lang string) (string, bool)
+}
+
+// redirectMoved sends a request for an old address on to the new one for good,
+// keeping its query, and reports whether it did
+func redirectMoved(w http.ResponseWriter, r *http.Request, rf redirectFinder) bool {
+	if rf == nil {
+		return false
+	}
+	target, ok := rf.Redirect(r.URL.Path, pathLang(r))
+	if !ok {
+		return false
+	}
+	if r.URL.RawQuery != "" && !strings.Contains(target, "?") {
+		target += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, target, http.StatusMovedPermanently)
+	return true
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAliasPath(t *testing.T) {
+	tests := []struct {
+		alias, want string
+	}{
+		{"old-name", "/posts/old-name"},
+		{" old-name ", "/posts/old-name"},
+		{"/2019/05/old.html", "/2019/05/old.html"},
+		{"/blog//old/", "/blog/old"},
+		{"/", ""},
+		{"//evil.example/x", ""},
+		{"/a/../admin", ""},
+		{"Old Name", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := aliasPath(tt.alias); got != tt.want {
+			t.Errorf("aliasPath(%q) = %q, want %q", tt.alias, got, tt.want)
+		}
+	}
+}
+
+func TestRedirects(t *testing.T) {
+	defer func(old map[string]string) { cfg.Redirects = old }(cfg.Redirects)
+	cfg.Redirects = map[string]string{"/about-me": "/pages/about", "/talks/2019": "https://talks.example/2019"}
+	t.Chdir(t.TempDir())
+	os.Mkdir("posts", 0755)
+	for name, content := range map[string]string{
+		"go-tips.md":   "---\ntitle: Go tips\naliases: [golang-tips, /2019/05/go-tips.html]\n---\n\nTips",
+		"en-hello.md":  "---\ntitle: Hello\naliases: [hi]\n---\n\nHi",
+		"upcoming.md":  "---\ntitle: Later\ndraft: true\naliases: [soon]\n---\n\nSoon",
+		"existing.md":  "---\ntitle: Existing\n---\n\nHere",
+		"shadowing.md": "---\ntitle: Shadowing\naliases: [existing]\n---\n\nNot here",
+	} {
+		os.WriteFile(filepath.Join("posts", name), []byte(content), 0644)
+	}
+	posts := newTestPostIndex(t, "posts")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /posts/{slug}", PostHandler(posts, nil, nil))
+	mux.HandleFunc("GET /", NotFoundHandler(posts))
+
+	tests := []struct {
+		path     string
+		code     int
+		location string
+	}{
+		{"/posts/golang-tips", http.StatusMovedPermanently, "/posts/go-tips"},
+		{"/posts/golang-tips?utm_source=x", http.StatusMovedPermanently, "/posts/go-tips?utm_source=x"},
+		{"/2019/05/go-tips.html", http.StatusMovedPermanently, "/posts/go-tips"},
+		{"/posts/hi", http.StatusMovedPermanently, "/en/posts/hello"},
+		{"/about-me", http.StatusMovedPermanently, "/pages/about"},
+		{"/talks/2019", http.StatusMovedPermanently, "https://talks.example/2019"},
+		// Drafts keep their aliases to themselves, and real posts win
+		{"/posts/soon", http.StatusNotFound, ""},
+		{"/posts/existing", http.StatusOK, ""},
+		{"/posts/never-was", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+		if w.Code != tt.code || w.Header().Get("Location") != tt.location {
+			t.Errorf("%s: %d %q, want %d %q", tt.path, w.Code, w.Header().Get("Location"), tt.code, tt.location)
+		}
+	}
+
+	// Within a language tree the post's address is given in the tree
+	if target, ok := posts.Redirect("/posts/hi", "en"); !ok || target != "/posts/hello" {
+		t.Errorf("Redirect in the en tree = %q, %v", target, ok)
+	}
+
+	want := []SlugCollision{{Path: "/posts/existing", Files: []string{"posts/existing.md", "posts/shadowing.md"}}}
+	if got := posts.Collisions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("collisions = %+v, want %+v", got, want)
+	}
+}
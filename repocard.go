@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	shortcodes["repo"] = repoShortcode
+}
+
+// repoNameRegex matches a GitHub owner/name
+var repoNameRegex = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9-]{0,38})/[A-Za-z0-9._-]{1,100}$`)
+
+// RepoInfo is what a repository card shows, as last fetched from GitHub
+type RepoInfo struct {
+	FullName    string    `json:"full_name"`
+	Description string    `json:"description"`
+	HTMLURL     string    `json:"html_url"`
+	Language    string    `json:"language"`
+	Stars       int       `json:"stargazers_count"`
+	Forks       int       `json:"forks_count"`
+	ETag        string    `json:"etag"`
+	Fetched     time.Time `json:"fetched"`
+}
+
+// RepoCards fetches repository metadata from the GitHub API and keeps it in
+// Dir. Cards are rendered from the cache; RunRepoRefresher updates it on a
+// schedule, so page views never wait on GitHub except for a repository seen
+// for the first time.
+type RepoCards struct {
+	Dir string
+	// API is the GitHub API origin
+	API string
+	// Token, from GITHUB_TOKEN, raises the API's rate limit
+	Token  string
+	Client *http.Client
+}
+
+// repoCards serves the repo shortcode. main rebuilds it from the loaded
+// config; until then it uses the defaults.
+var repoCards = newRepoCards(defaultConfig().Repos)
+
+// newRepoCards returns the cards for GitHub described by c
+func newRepoCards(c RepoCardsConfig) *RepoCards {
+	return &RepoCards{
+		Dir:    c.CacheDir,
+		API:    "https://api.github.com",
+		Token:  os.Getenv("GITHUB_TOKEN"),
+		Client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// cachePath returns the file a repository is kept in
+func (c *RepoCards) cachePath(name string) string {
+	return filepath.Join(c.Dir, filepath.FromSlash(strings.ToLower(name))+".json")
+}
+
+// cached reads a repository from the cache
+func (c *RepoCards) cached(name string) (RepoInfo, error) {
+	b, err := os.ReadFile(c.cachePath(name))
+	if err != nil {
+		return RepoInfo{}, err
+	}
+	var info RepoInfo
+	if err := json.Unmarshal(b, &info); err != nil {
+		return RepoInfo{}, err
+	}
+	return info, nil
+}
+
+// Repo returns a repository from the cache, fetching it if it isn't there yet
+func (c *RepoCards) Repo(ctx context.Context, name string) (RepoInfo, error) {
+	if info, err := c.cached(name); err == nil {
+		return info, nil
+	}
+	return c.fetch(ctx, name, RepoInfo{})
+}
+
+// fetch gets a repository from the API and caches it. With a cached copy the
+// request is conditional, so an unchanged repository costs no rate limit.
+func (c *RepoCards) fetch(ctx context.Context, name string, old RepoInfo) (RepoInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.API+"/repos/"+name, nil)
+	if err != nil {
+		return RepoInfo{}, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", "blog-web")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	if old.ETag != "" {
+		req.Header.Set("If-None-Match", old.ETag)
+	}
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return RepoInfo{}, fmt.Errorf("fetch repo %s: %w", name, err)
+	}
+	defer resp.Body.Close()
+
+	info := old
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+	case http.StatusOK:
+		info = RepoInfo{}
+		if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&info); err != nil {
+			return RepoInfo{}, fmt.Errorf("fetch repo %s: %w", name, err)
+		}
+		info.ETag = resp.Header.Get("ETag")
+	default:
+		return RepoInfo{}, fmt.Errorf("fetch repo %s: %s", name, resp.Status)
+	}
+	info.Fetched = time.Now().UTC()
+
+	b, err := json.Marshal(info)
+	if err == nil {
+		path := c.cachePath(name)
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err == nil {
+			err = os.WriteFile(path, b, 0644)
+		}
+	}
+	if err != nil {
+		slog.Error("Error caching repo", "repo", name, "err", err)
+	}
+	return info, nil
+}
+
+// Refresh fetches every cached repository again. A repository that can't be
+// fetched keeps its cached copy.
+func (c *RepoCards) Refresh(ctx context.Context) error {
+	return filepath.WalkDir(c.Dir, func(path string, d fs.DirEntry, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".json" {
+			return err
+		}
+		rel, err := filepath.Rel(c.Dir, strings.TrimSuffix(path, ".json"))
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		old, err := c.cached(name)
+		if err != nil {
+			slog.Warn("Skipping unreadable cached repo", "path", path, "err", err)
+			return nil
+		}
+		if _, err := c.fetch(ctx, name, old); err != nil {
+			slog.Warn("Could not refresh repo, keeping the cached copy", "repo", name, "err", err)
+		}
+		return ctx.Err()
+	})
+}
+
+// RunRepoRefresher refreshes the cached repositories every interval until ctx is done
+func RunRepoRefresher(ctx context.Context, cards *RepoCards, interval time.Duration) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+		if err := cards.Refresh(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("Error refreshing repos", "err", err)
+		}
+	}
+}
+
+// repoShortcode renders {{< repo owner/name >}} as a card linking to the
+// repository with its description, language, stars, and forks. Until GitHub
+// has answered once for the repository, the card only has its name.
+func repoShortcode(call ShortcodeCall) (string, error) {
+	name := call.Arg(0)
+	if !repoNameRegex.MatchString(name) || strings.Contains(name, "..") {
+		return "", fmt.Errorf("invalid repository %q, want owner/name", name)
+	}
+
+	info, err := repoCards.Repo(context.Background(), name)
+	if err != nil {
+		slog.Warn("Repo details unavailable", "slug", call.Slug, "repo", name, "err", err)
+	}
+	href := "https://github.com/" + name
+	if strings.HasPrefix(info.HTMLURL, "https://github.com/") {
+		href = info.HTMLURL
+	}
+	if info.FullName != "" {
+		name = info.FullName
+	}
+
+	var b strings.Builder
+	b.WriteString("<a class=\"repo-card\" href=\"" + template.HTMLEscapeString(href) + "\">\n")
+	b.WriteString("<span class=\"repo-name\">" + template.HTMLEscapeString(name) + "</span>\n")
+	if info.Description != "" {
+		b.WriteString("<span class=\"repo-description\">" + template.HTMLEscapeString(info.Description) + "</span>\n")
+	}
+	if !info.Fetched.IsZero() {
+		b.WriteString("<span class=\"repo-stats\">")
+		if info.Language != "" {
+			b.WriteString("<span class=\"repo-language\">" + template.HTMLEscapeString(info.Language) + "</span> ")
+		}
+		b.WriteString("<span title=\"Stars\">★ " + strconv.Itoa(info.Stars) + "</span> ")
+		b.WriteString("<span title=\"Forks\">⑂ " + strconv.Itoa(info.Forks) + "</span>")
+		b.WriteString("</span>\n")
+	}
+	b.WriteString("</a>")
+	return b.String(), nil
+}
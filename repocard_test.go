@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// newTestRepoCards points the repo shortcode at a fake GitHub API whose star
+// count the test can change
+func newTestRepoCards(t *testing.T) (*RepoCards, *atomic.Int32, *atomic.Int32) {
+	t.Helper()
+	var stars, notModified atomic.Int32
+	stars.Store(42)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/kenn/blog-web" || r.Header.Get("User-Agent") == "" {
+			http.NotFound(w, r)
+			return
+		}
+		n := strconv.Itoa(int(stars.Load()))
+		etag := `"` + n + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(`{"full_name": "Kenn/blog-web", "description": "A <small> blog", "html_url": "https://github.com/Kenn/blog-web",
+			"language": "Go", "stargazers_count": ` + n + `, "forks_count": 3}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	cards := newRepoCards(RepoCardsConfig{CacheDir: t.TempDir()})
+	cards.API = srv.URL
+	old := repoCards
+	repoCards = cards
+	t.Cleanup(func() { repoCards = old })
+	return cards, &stars, &notModified
+}
+
+func TestRepoShortcode(t *testing.T) {
+	newTestRepoCards(t)
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{
+			"{{< repo kenn/blog-web >}}",
+			[]string{`<a class="repo-card" href="https://github.com/Kenn/blog-web">`, "Kenn/blog-web", "A &lt;small&gt; blog", "Go", "★ 42", "⑂ 3"},
+		},
+		{
+			"{{< repo kenn/missing >}}",
+			[]string{`<a class="repo-card" href="https://github.com/kenn/missing">`, `<span class="repo-name">kenn/missing</span>`},
+		},
+		{"{{< repo kenn >}}", []string{"<!-- shortcode repo failed -->"}},
+		{"{{< repo kenn/.. >}}", []string{"<!-- shortcode repo failed -->"}},
+	}
+	for _, tt := range tests {
+		html, err := renderMarkdown(tt.src, "post")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(html, want) {
+				t.Errorf("%s: output missing %q:\n%s", tt.src, want, html)
+			}
+		}
+	}
+}
+
+func TestRepoCardsRefresh(t *testing.T) {
+	cards, stars, notModified := newTestRepoCards(t)
+	ctx := context.Background()
+	if _, err := cards.Repo(ctx, "kenn/blog-web"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unchanged repositories are answered with 304 and keep their details
+	if err := cards.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := cards.Repo(ctx, "kenn/blog-web"); notModified.Load() != 1 || info.Stars != 42 {
+		t.Errorf("after an unchanged refresh: %d not modified, %d stars", notModified.Load(), info.Stars)
+	}
+
+	stars.Store(57)
+	if err := cards.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := cards.Repo(ctx, "kenn/blog-web"); info.Stars != 57 {
+		t.Errorf("after a refresh: %d stars, want 57", info.Stars)
+	}
+
+	// Rendering reads the cache, never the API
+	cards.API = "http://127.0.0.1:0"
+	if info, err := cards.Repo(ctx, "kenn/blog-web"); err != nil || info.Stars != 57 {
+		t.Errorf("cached repo = %+v, %v", info, err)
+	}
+}
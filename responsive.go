@@ -0,0 +1,85 @@
+package main
+
+import (
+	"html"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// postImageSizes tells the browser how wide post images are shown: the body
+// column, 720px less its padding
+const postImageSizes = "(max-width: 720px) calc(100vw - 2rem), 688px"
+
+var (
+	// soloImageRegex matches an image that is a paragraph on its own, as
+	// goldmark renders ![alt](src) on a line by itself
+	soloImageRegex  = regexp.MustCompile(`<p>(<img\s[^>]*>)</p>`)
+	imgAltAttrRegex = regexp.MustCompile(`\salt="([^"]*)"`)
+)
+
+// responsiveImages makes the images in rendered markdown cheaper to load:
+// every image loads lazily, local ones get a srcset of their resized copies,
+// and an image standing alone in a paragraph becomes a figure captioned with
+// its alt text. Shortcodes lay out their own images, so it runs before they
+// are restored.
+func responsiveImages(htmlContent string) string {
+	out := imgTagRegex.ReplaceAllStringFunc(htmlContent, func(tag string) string {
+		var attrs string
+		if !strings.Contains(tag, " loading=") {
+			attrs += ` loading="lazy"`
+		}
+		if srcset := imageSrcset(tag); srcset != "" && !strings.Contains(tag, " srcset=") {
+			attrs += ` srcset="` + srcset + `" sizes="` + postImageSizes + `"`
+		}
+		return strings.Replace(tag, "<img", "<img"+attrs, 1)
+	})
+	return soloImageRegex.ReplaceAllStringFunc(out, func(p string) string {
+		tag := soloImageRegex.FindStringSubmatch(p)[1]
+		caption := ""
+		if m := imgAltAttrRegex.FindStringSubmatch(tag); m != nil && strings.TrimSpace(m[1]) != "" {
+			// The alt text is already escaped
+			caption = "<figcaption>" + m[1] + "</figcaption>"
+		}
+		return "<figure class=\"post-image\">" + tag + caption + "</figure>"
+	})
+}
+
+// imageSrcset lists the resized copies of a local image that are narrower
+// than the original, and no wider than the ?w= its src asks for, if any. It
+// returns "" for other images.
+func imageSrcset(tag string) string {
+	m := imgSrcRegex.FindStringSubmatch(tag)
+	if m == nil {
+		return ""
+	}
+	u, err := url.Parse(html.UnescapeString(m[1]))
+	if err != nil || u.Host != "" || !strings.HasPrefix(u.Path, "/images/") || !isResizableImage(u.Path) {
+		return ""
+	}
+	rel := strings.TrimPrefix(u.Path, "/images/")
+	if strings.Contains(rel, "..") {
+		return ""
+	}
+	ph, err := imagePlaceholder(filepath.Join("images", filepath.FromSlash(rel)))
+	if err != nil {
+		return ""
+	}
+
+	limit := ph.Width - 1
+	if w, err := strconv.Atoi(u.Query().Get("w")); err == nil {
+		limit = min(limit, w)
+	}
+	var candidates []string
+	for _, width := range imageWidths {
+		if width <= limit {
+			candidates = append(candidates, u.EscapedPath()+"?w="+strconv.Itoa(width)+" "+strconv.Itoa(width)+"w")
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	return html.EscapeString(strings.Join(candidates, ", "))
+}
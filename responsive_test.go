@@ -0,0 +1,74 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResponsiveImages(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeTestPNG(t, filepath.Join("images", "photo.png"), 1000, 750)
+	writeTestPNG(t, filepath.Join("images", "icon.png"), 100, 100)
+	preparePostPlaceholders("post", "![](/images/photo.png) ![](/images/icon.png)")
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			"local image in a paragraph of its own",
+			`<p><img src="/images/photo.png" alt="The bay at dusk"></p>`,
+			`<figure class="post-image"><img loading="lazy" srcset="/images/photo.png?w=160 160w, /images/photo.png?w=320 320w, /images/photo.png?w=640 640w, /images/photo.png?w=800 800w" sizes="` + postImageSizes + `" src="/images/photo.png" alt="The bay at dusk"><figcaption>The bay at dusk</figcaption></figure>`,
+		},
+		{
+			"width asked for",
+			`<p><img src="/images/photo.png?w=320" alt=""></p>`,
+			`<figure class="post-image"><img loading="lazy" srcset="/images/photo.png?w=160 160w, /images/photo.png?w=320 320w" sizes="` + postImageSizes + `" src="/images/photo.png?w=320" alt=""></figure>`,
+		},
+		{
+			"smaller than every width",
+			`<p>An <img src="/images/icon.png" alt="icon"> inline</p>`,
+			`<p>An <img loading="lazy" src="/images/icon.png" alt="icon"> inline</p>`,
+		},
+		{
+			"remote image",
+			`<p><img src="https://example.com/a.jpg" alt="A &amp; B"></p>`,
+			`<figure class="post-image"><img loading="lazy" src="https://example.com/a.jpg" alt="A &amp; B"><figcaption>A &amp; B</figcaption></figure>`,
+		},
+		{
+			"already lazy, with a srcset",
+			`<img loading="eager" srcset="/x.png 2x" src="/images/photo.png" alt="">`,
+			`<img loading="eager" srcset="/x.png 2x" src="/images/photo.png" alt="">`,
+		},
+	}
+	for _, tt := range tests {
+		if got := responsiveImages(tt.in); got != tt.want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRenderMarkdown_ResponsiveImages(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeTestPNG(t, filepath.Join("images", "photo.png"), 1000, 750)
+	preparePostPlaceholders("post", "![A photo](/images/photo.png)")
+
+	got, err := renderMarkdown("Intro\n\n![A photo](/images/photo.png)\n\n{{< gallery \"*.png\" >}}\n", "post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<figure class="post-image"><img width="1000" height="750" class="lqip"`,
+		`<figcaption>A photo</figcaption></figure>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %s in:\n%s", want, got)
+		}
+	}
+	// Gallery thumbnails keep their own markup
+	if strings.Count(got, "srcset=") != 1 || strings.Count(got, "<figure") != 1 {
+		t.Errorf("gallery images changed:\n%s", got)
+	}
+}
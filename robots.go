@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Utility page kinds that can be kept out of search results with noindex_pages
+const (
+	PageFeed       = "feed"
+	PageSearch     = "search"
+	PageTag        = "tag"
+	PagePagination = "pagination"
+)
+
+// noIndexPage reports whether pages of the given kind are configured as noindex.
+// Such pages are also left out of the sitemap.
+func noIndexPage(kind string) bool {
+	return slices.Contains(cfg.NoIndexPages, kind)
+}
+
+// markNoIndex asks search engines not to index a utility page while still
+// following its links. renderPage mirrors the header into the robots meta tag.
+func markNoIndex(w http.ResponseWriter, kind string) {
+	if noIndexPage(kind) && w.Header().Get("X-Robots-Tag") == "" {
+		w.Header().Set("X-Robots-Tag", "noindex, follow")
+	}
+}
+
+// pageRobots returns the robots meta content for a page, honouring the site-wide
+// noindex switch and any X-Robots-Tag the handler already set
+func pageRobots(w http.ResponseWriter) string {
+	if cfg.NoIndex {
+		return "noindex, nofollow"
+	}
+	if tag := w.Header().Get("X-Robots-Tag"); tag != "" {
+		return tag
+	}
+	return "index, follow"
+}
+
+// robotsDisallowed are paths crawlers have no business in: the admin area,
+// readers' own pages, and endpoints for tools rather than people
+var robotsDisallowed = []string{"/admin", "/saved", "/debug/", "/metrics", "/export/", "/micropub"}
+
+// RobotsTxtHandler serves /robots.txt, pointing crawlers at the sitemap. With
+// the site-wide noindex switch on, it asks them to stay away entirely.
+func RobotsTxtHandler(w http.ResponseWriter, r *http.Request) {
+	var b strings.Builder
+	b.WriteString("User-agent: *\n")
+	if cfg.NoIndex {
+		b.WriteString("Disallow: /\n")
+	} else {
+		for _, p := range robotsDisallowed {
+			b.WriteString("Disallow: " + p + "\n")
+		}
+	}
+	b.WriteString("\nSitemap: " + siteRoot(r) + "/sitemap.xml\n")
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.Write([]byte(b.String()))
+}
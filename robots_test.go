@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMarkNoIndex(t *testing.T) {
+	origNoIndex, origPages := cfg.NoIndex, cfg.NoIndexPages
+	defer func() { cfg.NoIndex, cfg.NoIndexPages = origNoIndex, origPages }()
+
+	tests := []struct {
+		name       string
+		siteNoIdx  bool
+		pages      []string
+		kind       string
+		wantHeader string
+		wantMeta   string
+	}{
+		{"configured kind", false, []string{PageFeed, PageTag}, PageTag, "noindex, follow", `content="noindex, follow"`},
+		{"other kind", false, []string{PageFeed}, PageSearch, "", `content="index, follow"`},
+		{"site-wide noindex wins", true, []string{PageTag}, PageTag, "noindex, nofollow", `content="noindex, nofollow"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.NoIndex, cfg.NoIndexPages = tt.siteNoIdx, tt.pages
+
+			w := httptest.NewRecorder()
+			setSecurityHeaders(w)
+			markNoIndex(w, tt.kind)
+			renderPage(w, "Tag", "")
+
+			if got := w.Header().Get("X-Robots-Tag"); got != tt.wantHeader {
+				t.Errorf("expected X-Robots-Tag %q, got %q", tt.wantHeader, got)
+			}
+			if !strings.Contains(w.Body.String(), `<meta name="robots" `+tt.wantMeta) {
+				t.Errorf("expected robots meta %s in page", tt.wantMeta)
+			}
+		})
+	}
+}
+
+func TestRobotsTxtHandler(t *testing.T) {
+	defer func(old bool) { cfg.NoIndex = old }(cfg.NoIndex)
+
+	get := func() string {
+		w := httptest.NewRecorder()
+		RobotsTxtHandler(w, httptest.NewRequest("GET", "http://blog.example/robots.txt", nil))
+		if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		return w.Body.String()
+	}
+
+	cfg.NoIndex = false
+	body := get()
+	for _, want := range []string{"User-agent: *\n", "Disallow: /admin\n", "Sitemap: http://blog.example/sitemap.xml\n"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q in:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "Disallow: /\n") {
+		t.Error("whole site disallowed")
+	}
+
+	cfg.NoIndex = true
+	if body := get(); !strings.Contains(body, "Disallow: /\n") || strings.Contains(body, "Disallow: /admin") {
+		t.Errorf("noindex site:\n%s", body)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeS3 stores objects in memory and answers the calls S3SnapshotStore and
+// S3PostStore make
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+		http.Error(w, "unsigned", http.StatusForbidden)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/bucket":
+		var keys []string
+		for k := range f.objects {
+			if strings.HasPrefix(k, r.URL.Query().Get("prefix")) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		fmt.Fprint(w, `<ListBucketResult>`)
+		for _, k := range keys {
+			fmt.Fprintf(w, `<Contents><Key>%s</Key><Size>%d</Size></Contents>`, k, len(f.objects[k]))
+		}
+		fmt.Fprint(w, `<IsTruncated>false</IsTruncated></ListBucketResult>`)
+	case r.Method == http.MethodPut:
+		f.objects[key], _ = io.ReadAll(r.Body)
+	case r.Method == http.MethodGet:
+		b, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Write(b)
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestS3SnapshotStore(t *testing.T) {
+	fake := &fakeS3{objects: map[string][]byte{"other/file": nil}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	store := &S3SnapshotStore{
+		Endpoint: srv.URL, Region: "us-east-1", Bucket: "bucket", Prefix: "blog/",
+		AccessKey: "AKID", SecretKey: "secret", Client: srv.Client(),
+	}
+	ctx := context.Background()
+	dir := t.TempDir()
+	src := filepath.Join(dir, "snap.tar.gz")
+	os.WriteFile(src, []byte("archive"), 0644)
+
+	if err := store.Put(ctx, "snapshot-20261016T030000Z.tar.gz", src); err != nil {
+		t.Fatal(err)
+	}
+	if string(fake.objects["blog/snapshot-20261016T030000Z.tar.gz"]) != "archive" {
+		t.Errorf("objects = %v", fake.objects)
+	}
+	names, err := store.List(ctx)
+	if err != nil || len(names) != 1 || names[0] != "snapshot-20261016T030000Z.tar.gz" {
+		t.Fatalf("List = %v, %v", names, err)
+	}
+	dst := filepath.Join(dir, "back.tar.gz")
+	if err := store.Get(ctx, names[0], dst); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(dst); string(b) != "archive" {
+		t.Errorf("downloaded %q", b)
+	}
+	if err := store.Delete(ctx, names[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Get(ctx, names[0], dst); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Get after Delete: %v", err)
+	}
+}
+
+func TestAWSURIEncode(t *testing.T) {
+	tests := []struct {
+		in          string
+		encodeSlash bool
+		want        string
+	}{
+		{"/bucket/blog/snap-1.tar.gz", false, "/bucket/blog/snap-1.tar.gz"},
+		{"blog/", true, "blog%2F"},
+		{"a b+c~", true, "a%20b%2Bc~"},
+	}
+	for _, tt := range tests {
+		if got := awsURIEncode(tt.in, tt.encodeSlash); got != tt.want {
+			t.Errorf("awsURIEncode(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
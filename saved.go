@@ -0,0 +1,173 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"html/template"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	savedCookieName = "saved"
+	savedCookieTTL  = 365 * 24 * time.Hour
+	// maxSavedPosts keeps the cookie well under the 4 KB browsers allow
+	maxSavedPosts = 50
+)
+
+// SavedPosts keeps a reader's "save for later" list in a signed cookie, so
+// nothing is stored on the server and no account is needed
+type SavedPosts struct {
+	secret []byte
+}
+
+// NewSavedPosts signs saved lists with secret
+func NewSavedPosts(secret []byte) *SavedPosts {
+	return &SavedPosts{secret: secret}
+}
+
+// sign returns the MAC for a comma-separated slug list
+func (s *SavedPosts) sign(list string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte("saved|" + list))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Slugs returns the reader's saved slugs, newest first. A missing or tampered
+// cookie is an empty list.
+func (s *SavedPosts) Slugs(r *http.Request) []string {
+	cookie, err := r.Cookie(savedCookieName)
+	if err != nil {
+		return nil
+	}
+	list, mac, ok := strings.Cut(cookie.Value, ".")
+	if !ok || list == "" || !hmac.Equal([]byte(mac), []byte(s.sign(list))) {
+		return nil
+	}
+	return strings.Split(list, "~")
+}
+
+// setSlugs stores the list in the cookie, or clears it when empty
+func (s *SavedPosts) setSlugs(w http.ResponseWriter, r *http.Request, slugs []string) {
+	cookie := newCookie(r, savedCookieName, "")
+	cookie.HttpOnly = true
+	if len(slugs) == 0 {
+		cookie.MaxAge = -1
+	} else {
+		list := strings.Join(slugs, "~")
+		cookie.Value = list + "." + s.sign(list)
+		cookie.Expires = time.Now().Add(savedCookieTTL)
+	}
+	http.SetCookie(w, cookie)
+}
+
+// savedListed reports whether the request's cookie lists slug. It only labels
+// the button on post pages; the signature is checked wherever the list is used.
+func savedListed(r *http.Request, slug string) bool {
+	cookie, err := r.Cookie(savedCookieName)
+	if err != nil {
+		return false
+	}
+	list, _, _ := strings.Cut(cookie.Value, ".")
+	return slices.Contains(strings.Split(list, "~"), slug)
+}
+
+// savePostHTML renders the button that adds a post to, or removes it from, the saved list
+func savePostHTML(slug string, saved bool) string {
+	action, label := "save", "Save for later"
+	if saved {
+		action, label = "remove", "Saved ✓ Remove"
+	}
+	return "<form class=\"save-post\" method=\"POST\" action=\"/saved\">" +
+		"<input type=\"hidden\" name=\"slug\" value=\"" + template.HTMLEscapeString(slug) + "\">" +
+		"<input type=\"hidden\" name=\"action\" value=\"" + action + "\">" +
+		"<button type=\"submit\">" + label + "</button></form>\n"
+}
+
+// localRedirect returns target if it is a path on this site, otherwise fallback
+func localRedirect(target, fallback string) string {
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") || strings.HasPrefix(target, "/\\") {
+		return fallback
+	}
+	return target
+}
+
+// SaveHandler adds a post to, or removes it from, the reader's saved list and
+// sends them back to the page they came from
+func SaveHandler(saved *SavedPosts, sl SlugReader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slug := r.PostFormValue("slug")
+		if !IsValidSlug(slug) {
+			http.Error(w, "Invalid post slug", http.StatusBadRequest)
+			return
+		}
+
+		slugs := slices.DeleteFunc(saved.Slugs(r), func(s string) bool { return s == slug })
+		if r.PostFormValue("action") != "remove" {
+			if _, err := sl.Read(slug); err != nil {
+				http.Error(w, "Post not found", http.StatusNotFound)
+				return
+			}
+			slugs = append([]string{slug}, slugs...)
+			if len(slugs) > maxSavedPosts {
+				slugs = slugs[:maxSavedPosts]
+			}
+		}
+		saved.setSlugs(w, r, slugs)
+
+		http.Redirect(w, r, localRedirect(r.PostFormValue("return"), "/posts/"+slug), http.StatusSeeOther)
+	}
+}
+
+// SavedHandler renders the reader's saved posts
+func SavedHandler(saved *SavedPosts, sl SlugReader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setSecurityHeaders(w)
+		// The page differs per reader; keep it out of shared caches and search results
+		w.Header().Set("Cache-Control", "private, no-store")
+		w.Header().Set("X-Robots-Tag", "noindex, follow")
+
+		var items strings.Builder
+		for _, slug := range saved.Slugs(r) {
+			if !IsValidSlug(slug) {
+				continue
+			}
+			src, err := sl.Read(slug)
+			if err != nil {
+				continue
+			}
+			fm, _ := ParseFrontmatter(src)
+			if !postVisible(fm) {
+				continue
+			}
+			title := fm.Title
+			if title == "" {
+				title = toTitleCase(strings.ReplaceAll(slug, "-", " "))
+			}
+			esc := template.HTMLEscapeString(slug)
+			items.WriteString("<li><a href=\"/posts/" + esc + "\">" + template.HTMLEscapeString(title) + "</a>")
+			if t, ok := parsePostDate(fm.Date); ok {
+				items.WriteString("<span class=\"post-date\">" + t.Format("Jan 2, 2006") + "</span>")
+			}
+			items.WriteString("<form class=\"save-post\" method=\"POST\" action=\"/saved\">" +
+				"<input type=\"hidden\" name=\"slug\" value=\"" + esc + "\">" +
+				"<input type=\"hidden\" name=\"action\" value=\"remove\">" +
+				"<input type=\"hidden\" name=\"return\" value=\"/saved\">" +
+				"<button type=\"submit\">Remove</button></form>")
+			items.WriteString("</li>\n")
+		}
+
+		var content strings.Builder
+		content.WriteString("<h1>Saved for later</h1>\n")
+		if items.Len() > 0 {
+			content.WriteString("<ul class=\"post-list saved-list\">\n" + items.String() + "</ul>\n")
+		} else {
+			content.WriteString("<p>Nothing saved yet. Use \"Save for later\" on a post to keep it here. The list lives in a cookie in this browser only.</p>\n")
+		}
+
+		renderPage(w, "Saved", template.HTML(content.String()))
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSavedPostsCookie(t *testing.T) {
+	saved := NewSavedPosts([]byte("secret"))
+	w := httptest.NewRecorder()
+	saved.setSlugs(w, httptest.NewRequest(http.MethodPost, "/saved", nil), []string{"b", "a"})
+	cookie := w.Result().Cookies()[0]
+
+	r := httptest.NewRequest(http.MethodGet, "/saved", nil)
+	r.AddCookie(cookie)
+	if got := saved.Slugs(r); strings.Join(got, ",") != "b,a" {
+		t.Errorf("Slugs() = %v", got)
+	}
+
+	tampered := httptest.NewRequest(http.MethodGet, "/saved", nil)
+	tampered.AddCookie(&http.Cookie{Name: savedCookieName, Value: "b~a~c" + cookie.Value[3:]})
+	if got := saved.Slugs(tampered); got != nil {
+		t.Errorf("tampered cookie gave %v", got)
+	}
+	if got := NewSavedPosts([]byte("other")).Slugs(r); got != nil {
+		t.Errorf("cookie signed with another key gave %v", got)
+	}
+}
+
+func TestSaveHandler(t *testing.T) {
+	posts := &MockSlugReader{content: map[string]string{
+		"first":  "---\ntitle: First\n---\nOne",
+		"second": "---\ntitle: Second\ndate: 2026-01-02\n---\nTwo",
+	}}
+	saved := NewSavedPosts([]byte("secret"))
+	h := SaveHandler(saved, posts)
+
+	var cookies []*http.Cookie
+	send := func(form url.Values) *httptest.ResponseRecorder {
+		r := postForm("/saved", form)
+		for _, c := range cookies {
+			r.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		h(w, r)
+		if c := w.Result().Cookies(); len(c) > 0 {
+			cookies = c
+		}
+		return w
+	}
+
+	if w := send(url.Values{"slug": {"first"}}); w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/posts/first" {
+		t.Fatalf("save: %d %q", w.Code, w.Header().Get("Location"))
+	}
+	send(url.Values{"slug": {"second"}, "return": {"//evil.example"}})
+	if w := send(url.Values{"slug": {"missing"}}); w.Code != http.StatusNotFound {
+		t.Errorf("saving a missing post: %d", w.Code)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/saved", nil)
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	if got := saved.Slugs(r); strings.Join(got, ",") != "second,first" {
+		t.Errorf("Slugs() = %v", got)
+	}
+	if !savedListed(r, "first") || savedListed(r, "third") {
+		t.Error("savedListed disagrees with the cookie")
+	}
+
+	w := httptest.NewRecorder()
+	SavedHandler(saved, posts)(w, r)
+	body := w.Body.String()
+	if !strings.Contains(body, `href="/posts/second">Second</a>`) || strings.Index(body, "Second") > strings.Index(body, "First") {
+		t.Errorf("saved page = %s", body)
+	}
+	if w.Header().Get("Cache-Control") != "private, no-store" {
+		t.Errorf("Cache-Control = %q", w.Header().Get("Cache-Control"))
+	}
+
+	if w := send(url.Values{"slug": {"second"}, "action": {"remove"}, "return": {"/saved"}}); w.Header().Get("Location") != "/saved" {
+		t.Errorf("remove redirect = %q", w.Header().Get("Location"))
+	}
+	send(url.Values{"slug": {"first"}, "action": {"remove"}})
+	if len(cookies) != 1 || cookies[0].MaxAge >= 0 {
+		t.Errorf("emptying the list should clear the cookie, got %+v", cookies)
+	}
+}
+
+func TestPostHandler_SavedStateNotShared(t *testing.T) {
+	reader := &MockSlugReader{content: map[string]string{"first": "---\ntitle: First\n---\n\nHi"}}
+	req := httptest.NewRequest("GET", "/posts/first", nil)
+	req.SetPathValue("slug", "first")
+	req.AddCookie(&http.Cookie{Name: savedCookieName, Value: "first"})
+	w := httptest.NewRecorder()
+	Conditional(PostHandler(reader, nil, nil))(w, req)
+
+	if !strings.Contains(w.Body.String(), "Saved ✓") {
+		t.Fatal("expected the post shown as saved")
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "private, no-cache" {
+		t.Errorf("Cache-Control = %q", cc)
+	}
+	if !slices.Contains(w.Header().Values("Vary"), "Cookie") {
+		t.Errorf("Vary = %q", w.Header().Values("Vary"))
+	}
+}
+
+func TestSavedHandlerEmpty(t *testing.T) {
+	w := httptest.NewRecorder()
+	SavedHandler(NewSavedPosts([]byte("secret")), &MockSlugReader{})(w, httptest.NewRequest(http.MethodGet, "/saved", nil))
+	if !strings.Contains(w.Body.String(), "Nothing saved yet") {
+		t.Errorf("body = %s", w.Body)
+	}
+}
+
+func TestLocalRedirect(t *testing.T) {
+	tests := map[string]string{
+		"/saved":               "/saved",
+		"":                     "/fallback",
+		"https://evil.com":     "/fallback",
+		"//evil.com":           "/fallback",
+		"/\\evil.com":          "/fallback",
+		"/posts/a?lang=en#top": "/posts/a?lang=en#top",
+	}
+	for in, want := range tests {
+		if got := localRedirect(in, "/fallback"); got != want {
+			t.Errorf("localRedirect(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// postDateLayouts are the accepted formats of the frontmatter date, most precise first
+var postDateLayouts = []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02"}
+
+// parsePostDate parses a frontmatter date. Dates without a zone are in the site timezone.
+func parsePostDate(s string) (time.Time, bool) {
+	for _, layout := range postDateLayouts {
+		if t, err := time.ParseInLocation(layout, strings.TrimSpace(s), cfg.Location()); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// isScheduled reports whether a post's date is still in the future
+func isScheduled(fm PostFrontmatter, now time.Time) bool {
+	t, ok := parsePostDate(fm.Date)
+	return ok && t.After(now)
+}
+
+// postVisible reports whether a post may be listed and served to readers
+func postVisible(fm PostFrontmatter) bool {
+	return !fm.Draft && !fm.Private && !isScheduled(fm, time.Now())
+}
+
+// previewToken lets drafts and scheduled posts be read at
+// /posts/{slug}?preview=<token> before they are published. main sets it from
+// PREVIEW_TOKEN; empty turns previews off.
+var previewToken string
+
+// previewAllowed reports whether the request carries the preview token
+func previewAllowed(r *http.Request) bool {
+	got := r.URL.Query().Get("preview")
+	return previewToken != "" && subtle.ConstantTimeCompare([]byte(got), []byte(previewToken)) == 1
+}
+
+// setFrontmatterField sets key to value in the post's YAML frontmatter, adding the
+// field, or the whole frontmatter block, when missing. Other lines are kept as written.
+func setFrontmatterField(content, key, value string) string {
+	line := key + ": " + value
+	if !strings.HasPrefix(content, "---") {
+		return "---\n" + line + "\n---\n\n" + content
+	}
+
+	rest := content[3:]
+	end := strings.Index(rest, "\n---")
+	if end < 0 {
+		return "---\n" + line + "\n---\n\n" + content
+	}
+
+	lines := strings.Split(rest[:end], "\n")
+	replaced := false
+	for i, l := range lines {
+		if strings.HasPrefix(l, key+":") {
+			lines[i] = line
+			replaced = true
+		}
+	}
+	if !replaced {
+		lines = append(lines, line)
+	}
+	return "---" + strings.Join(lines, "\n") + rest[end:]
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePostDate(t *testing.T) {
+	bangkok, _ := time.LoadLocation("Asia/Bangkok")
+	tests := []struct {
+		in   string
+		want time.Time
+		ok   bool
+	}{
+		{"2026-01-15", time.Date(2026, 1, 15, 0, 0, 0, 0, bangkok), true},
+		{"2026-01-15 09:30", time.Date(2026, 1, 15, 9, 30, 0, 0, bangkok), true},
+		{"2026-01-15T09:30:00Z", time.Date(2026, 1, 15, 9, 30, 0, 0, time.UTC), true},
+		{"January 15", time.Time{}, false},
+		{"", time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, ok := parsePostDate(tt.in)
+			if ok != tt.ok || !got.Equal(tt.want) {
+				t.Errorf("expected %v (%v), got %v (%v)", tt.want, tt.ok, got, ok)
+			}
+		})
+	}
+}
+
+func TestPostVisible(t *testing.T) {
+	future := time.Now().Add(time.Hour).Format(time.RFC3339)
+	past := time.Now().Add(-time.Hour).Format(time.RFC3339)
+
+	if !postVisible(PostFrontmatter{Date: past}) || !postVisible(PostFrontmatter{}) {
+		t.Error("expected past and undated posts to be visible")
+	}
+	if postVisible(PostFrontmatter{Date: future}) {
+		t.Error("expected a future-dated post to be hidden")
+	}
+	if postVisible(PostFrontmatter{Date: past, Draft: true}) {
+		t.Error("expected a draft to be hidden")
+	}
+}
+
+func TestDraftPreview(t *testing.T) {
+	defer func(old string) { previewToken = old }(previewToken)
+	previewToken = "s3cret"
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "live.md"), []byte("---\ntitle: Live\ndate: 2025-01-01\n---\nHi"), 0644)
+	os.WriteFile(filepath.Join(dir, "wip.md"), []byte("---\ntitle: Work in progress\ndate: 2025-02-01\ndraft: true\n---\nSoon"), 0644)
+	idx := newTestPostIndex(t, dir)
+	if posts := idx.Posts(); len(posts) != 1 || posts[0].Slug != "live" {
+		t.Errorf("Posts() = %+v, want the draft left out", posts)
+	}
+
+	tests := []struct {
+		target string
+		status int
+	}{
+		{"/posts/wip", http.StatusNotFound},
+		{"/posts/wip?preview=wrong", http.StatusNotFound},
+		{"/posts/wip?preview=", http.StatusNotFound},
+		{"/posts/wip?preview=s3cret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.target, nil)
+		req.SetPathValue("slug", "wip")
+		w := httptest.NewRecorder()
+		PostHandler(idx, nil, nil)(w, req)
+		if w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.target, w.Code, tt.status)
+			continue
+		}
+		if w.Code == http.StatusOK {
+			if !strings.Contains(w.Body.String(), `class="preview-banner"`) || w.Header().Get("Cache-Control") != "private, no-store" {
+				t.Errorf("preview missing banner or caching headers: %v", w.Header())
+			}
+			if !strings.HasPrefix(w.Header().Get("X-Robots-Tag"), "noindex") {
+				t.Error("preview not marked noindex")
+			}
+		}
+	}
+
+	// Without a token configured there is no preview
+	previewToken = ""
+	req := httptest.NewRequest("GET", "/posts/wip?preview=", nil)
+	req.SetPathValue("slug", "wip")
+	w := httptest.NewRecorder()
+	PostHandler(idx, nil, nil)(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("empty token: status %d", w.Code)
+	}
+}
+
+func TestSetFrontmatterField(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "replace",
+			content: "---\ntitle: Hi\ndate: 2026-01-01\n---\n\nBody",
+			want:    "---\ntitle: Hi\ndate: 2026-02-01\n---\n\nBody",
+		},
+		{
+			name:    "append",
+			content: "---\ntitle: Hi\n---\n\nBody",
+			want:    "---\ntitle: Hi\ndate: 2026-02-01\n---\n\nBody",
+		},
+		{
+			name:    "no frontmatter",
+			content: "Body",
+			want:    "---\ndate: 2026-02-01\n---\n\nBody",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := setFrontmatterField(tt.content, "date", "2026-02-01")
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+			if fm, _ := ParseFrontmatter(got); fm.Date != "2026-02-01" {
+				t.Errorf("expected the date to parse back, got %+v", fm)
+			}
+		})
+	}
+}
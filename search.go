@@ -0,0 +1,449 @@
+package main
+
+import (
+	"html/template"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// searchIndexTTL is how long a built index is reused before posts are read
+// again, when the posts can't say whether they changed
+const searchIndexTTL = time.Minute
+
+// searchSnippetLength is the length in characters of a result's snippet
+const searchSnippetLength = 200
+
+// Score weights for where and how a query term matched
+const (
+	searchTitleWeight = 3.0
+	searchBodyWeight  = 1.0
+	searchExact       = 1.0
+	searchPrefix      = 0.7
+	searchFuzzy       = 0.5
+)
+
+// tokenize lowercases text and splits it into words. Latin text splits on
+// anything that isn't a letter or digit; runs of Thai are segmented with dict.
+func tokenize(text string, dict *ThaiDictionary) []string {
+	var tokens []string
+	var run []rune
+	thai := false
+	flush := func() {
+		if len(run) == 0 {
+			return
+		}
+		if thai && dict != nil {
+			tokens = append(tokens, dict.Segment(string(run))...)
+		} else {
+			tokens = append(tokens, string(run))
+		}
+		run = run[:0]
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case isThai(r):
+			if !thai {
+				flush()
+			}
+			thai = true
+			run = append(run, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r):
+			if thai {
+				flush()
+			}
+			thai = false
+			run = append(run, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return tokens
+}
+
+// editDistance is the optimal string alignment distance between a and b:
+// insertions, deletions, substitutions, and swaps of neighbouring characters
+// each cost one. It stops early and returns max+1 once the distance exceeds max.
+func editDistance(a, b string, max int) int {
+	ra, rb := []rune(a), []rune(b)
+	if d := len(ra) - len(rb); d > max || -d > max {
+		return max + 1
+	}
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+			rowMin = min(rowMin, cur[j])
+		}
+		if rowMin > max {
+			return max + 1
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(rb)]
+}
+
+// maxTypos is how many edits a query term of this length may be away from an
+// indexed word: none for short words, where a typo usually makes another word
+func maxTypos(term string) int {
+	switch n := utf8.RuneCountInString(term); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// searchDoc is an indexed post
+type searchDoc struct {
+	Slug  string
+	Title string
+	Date  time.Time
+	Text  string
+}
+
+// SearchIndex is an inverted index over post titles and bodies
+type SearchIndex struct {
+	docs  []searchDoc
+	terms map[string]map[int]float64 // term -> doc -> weighted frequency
+	dict  *ThaiDictionary
+}
+
+// SearchResult is a matching post with its relevance score
+type SearchResult struct {
+	Slug  string
+	Title string
+	Date  time.Time
+	Text  string
+	Score float64
+	// Words are the indexed words in the post that the query matched
+	Words []string
+}
+
+// NewSearchIndex indexes every published post in posts
+func NewSearchIndex(posts PostFiles, dict *ThaiDictionary) (*SearchIndex, error) {
+	idx := &SearchIndex{terms: make(map[string]map[int]float64), dict: dict}
+	slugs, err := posts.List()
+	if err != nil {
+		return nil, err
+	}
+	rendered, err := renderPosts(posts, slugs)
+	if err != nil {
+		// Index what rendered rather than failing every search over one bad post
+		slog.Error("Error rendering posts for search", "err", err)
+	}
+	for _, p := range rendered {
+		doc := searchDoc{Slug: p.Slug, Title: p.FM.Title, Text: strings.Join(strings.Fields(plainText(p.HTML)), " ")}
+		if doc.Title == "" {
+			doc.Title = toTitleCase(strings.ReplaceAll(p.Slug, "-", " "))
+		}
+		doc.Date, _ = parsePostDate(p.FM.Date)
+		idx.add(doc)
+	}
+	return idx, nil
+}
+
+func (idx *SearchIndex) add(doc searchDoc) {
+	id := len(idx.docs)
+	idx.docs = append(idx.docs, doc)
+	for _, field := range []struct {
+		text   string
+		weight float64
+	}{{doc.Title, searchTitleWeight}, {doc.Text, searchBodyWeight}} {
+		for _, t := range tokenize(field.text, idx.dict) {
+			if idx.terms[t] == nil {
+				idx.terms[t] = make(map[int]float64)
+			}
+			idx.terms[t][id] += field.weight
+		}
+	}
+}
+
+// expand returns the indexed words a query term matches and how well: the
+// word itself, words it starts, and words within a few typos
+func (idx *SearchIndex) expand(term string) map[string]float64 {
+	out := make(map[string]float64)
+	if _, ok := idx.terms[term]; ok {
+		out[term] = searchExact
+	}
+	typos := maxTypos(term)
+	prefixOK := utf8.RuneCountInString(term) >= 3
+	for word := range idx.terms {
+		if word == term {
+			continue
+		}
+		if prefixOK && strings.HasPrefix(word, term) {
+			out[word] = max(out[word], searchPrefix)
+			continue
+		}
+		if typos > 0 {
+			if d := editDistance(term, word, typos); d <= typos {
+				out[word] = max(out[word], searchFuzzy/float64(d))
+			}
+		}
+	}
+	return out
+}
+
+// Search returns posts matching every term of the query, best first
+func (idx *SearchIndex) Search(query string) []SearchResult {
+	terms := tokenize(query, idx.dict)
+	if len(terms) == 0 {
+		return nil
+	}
+	scores := make(map[int]float64)
+	words := make(map[int][]string)
+	for i, term := range terms {
+		termScores := make(map[int]float64)
+		for word, quality := range idx.expand(term) {
+			for doc, freq := range idx.terms[word] {
+				termScores[doc] = max(termScores[doc], quality*freq)
+				words[doc] = append(words[doc], word)
+			}
+		}
+		// Every term must match somewhere in the post
+		for doc := range scores {
+			if _, ok := termScores[doc]; !ok {
+				delete(scores, doc)
+			}
+		}
+		for doc, s := range termScores {
+			if _, ok := scores[doc]; ok || i == 0 {
+				scores[doc] += s
+			}
+		}
+	}
+
+	results := make([]SearchResult, 0, len(scores))
+	for id, score := range scores {
+		d := idx.docs[id]
+		results = append(results, SearchResult{Slug: d.Slug, Title: d.Title, Date: d.Date, Text: d.Text, Score: score, Words: words[id]})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Date.After(results[j].Date)
+	})
+	return results
+}
+
+// versionedPosts is implemented by post stores that can report changes, such
+// as PostIndex
+type versionedPosts interface {
+	Version() uint64
+}
+
+// Searcher keeps a search index over the posts. It is rebuilt whenever a
+// PostIndex reports a change, or for other stores once it is older than
+// searchIndexTTL, so new and edited posts show up within a minute.
+type Searcher struct {
+	Posts PostFiles
+	Dict  *ThaiDictionary
+
+	mu      sync.Mutex
+	index   *SearchIndex
+	built   time.Time
+	version uint64
+}
+
+// Index returns a current index, building it if needed
+func (s *Searcher) Index() (*SearchIndex, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	vp, versioned := s.Posts.(versionedPosts)
+	var version uint64
+	if versioned {
+		version = vp.Version()
+	}
+	if s.index != nil {
+		if versioned && version == s.version {
+			return s.index, nil
+		}
+		if !versioned && time.Since(s.built) < searchIndexTTL {
+			return s.index, nil
+		}
+	}
+	idx, err := NewSearchIndex(s.Posts, s.Dict)
+	if err != nil {
+		return nil, err
+	}
+	s.index, s.built, s.version = idx, time.Now(), version
+	return idx, nil
+}
+
+// searchSummary returns the first words of a result's text
+func searchSummary(text string) string {
+	if utf8.RuneCountInString(text) <= searchSnippetLength {
+		return text
+	}
+	return string([]rune(text)[:searchSnippetLength]) + "…"
+}
+
+// isWordRune reports whether r continues a Latin word; Thai is written without
+// spaces, so Thai words can't be told apart from their neighbours this way
+func isWordRune(r rune) bool {
+	return !isThai(r) && (unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r))
+}
+
+// matchSpans finds where words occur in text, ignoring case, as sorted,
+// non-overlapping rune ranges. Latin words only match whole words.
+func matchSpans(text []rune, words []string) [][2]int {
+	lower := make([]rune, len(text))
+	for i, r := range text {
+		lower[i] = unicode.ToLower(r)
+	}
+	var spans [][2]int
+	for _, w := range words {
+		word := []rune(w)
+		if len(word) == 0 {
+			continue
+		}
+		for i := 0; i+len(word) <= len(lower); i++ {
+			if !slices.Equal(lower[i:i+len(word)], word) {
+				continue
+			}
+			end := i + len(word)
+			if isWordRune(word[0]) && i > 0 && isWordRune(lower[i-1]) {
+				continue
+			}
+			if isWordRune(word[len(word)-1]) && end < len(lower) && isWordRune(lower[end]) {
+				continue
+			}
+			spans = append(spans, [2]int{i, end})
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+	merged := spans[:0]
+	for _, sp := range spans {
+		if n := len(merged); n > 0 && sp[0] <= merged[n-1][1] {
+			merged[n-1][1] = max(merged[n-1][1], sp[1])
+			continue
+		}
+		merged = append(merged, sp)
+	}
+	return merged
+}
+
+// highlight escapes text[from:to] and wraps the spans inside it in <mark>
+func highlight(text []rune, spans [][2]int, from, to int) string {
+	var b strings.Builder
+	pos := from
+	for _, sp := range spans {
+		if sp[1] <= from || sp[0] >= to {
+			continue
+		}
+		start, end := max(sp[0], from), min(sp[1], to)
+		b.WriteString(template.HTMLEscapeString(string(text[pos:start])))
+		b.WriteString("<mark>" + template.HTMLEscapeString(string(text[start:end])) + "</mark>")
+		pos = end
+	}
+	b.WriteString(template.HTMLEscapeString(string(text[pos:to])))
+	return b.String()
+}
+
+// highlightTitle returns a result's title as HTML with the matched words marked
+func highlightTitle(title string, words []string) template.HTML {
+	text := []rune(title)
+	return template.HTML(highlight(text, matchSpans(text, words), 0, len(text)))
+}
+
+// searchSnippet returns HTML for the part of a result's text around the first
+// match, with matched words marked. Posts that only matched in the title get
+// their opening words.
+func searchSnippet(text string, words []string) template.HTML {
+	runes := []rune(text)
+	spans := matchSpans(runes, words)
+	if len(spans) == 0 {
+		return template.HTML(template.HTMLEscapeString(searchSummary(text)))
+	}
+	// Start a little before the first match, at the beginning of a word
+	from := max(0, spans[0][0]-searchSnippetLength/4)
+	if from > 0 {
+		if i := slices.Index(runes[from:spans[0][0]], ' '); i >= 0 {
+			from += i + 1
+		}
+	}
+	to := min(len(runes), from+searchSnippetLength)
+	if to < spans[0][1] {
+		to = spans[0][1]
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	b.WriteString(highlight(runes, spans, from, to))
+	if to < len(runes) {
+		b.WriteString("…")
+	}
+	return template.HTML(b.String())
+}
+
+// SearchHandler renders /search?q=... and counts the query in stats, if given
+func SearchHandler(s *Searcher, stats *SearchStats) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setSecurityHeaders(w)
+		markNoIndex(w, PageSearch)
+
+		query := strings.TrimSpace(r.URL.Query().Get("q"))
+		var content strings.Builder
+		content.WriteString("<h1>Search</h1>\n")
+		content.WriteString("<form class=\"search-form\" method=\"GET\" action=\"/search\" role=\"search\">")
+		content.WriteString("<input type=\"search\" name=\"q\" value=\"" + template.HTMLEscapeString(query) + "\" aria-label=\"Search posts\" required>")
+		content.WriteString("<button type=\"submit\">Search</button></form>\n")
+
+		if query != "" {
+			idx, err := s.Index()
+			if err != nil {
+				slog.Error("Error building search index", "err", err)
+				renderError(w, http.StatusInternalServerError, requestLang(r))
+				return
+			}
+			results := idx.Search(query)
+			if stats != nil {
+				if err := stats.Record(query, len(results)); err != nil {
+					slog.Error("Error recording search", "err", err)
+				}
+			}
+			if len(results) == 0 {
+				content.WriteString("<p class=\"search-empty\">No posts match <strong>" + template.HTMLEscapeString(query) + "</strong>.</p>\n")
+			} else {
+				content.WriteString("<ul class=\"search-results\">\n")
+				for _, res := range results {
+					content.WriteString("<li><a href=\"/posts/" + template.HTMLEscapeString(res.Slug) + "\">" + string(highlightTitle(res.Title, res.Words)) + "</a>")
+					if !res.Date.IsZero() {
+						content.WriteString("<span class=\"post-date\">" + res.Date.Format("Jan 2, 2006") + "</span>")
+					}
+					content.WriteString("<p>" + string(searchSnippet(res.Text, res.Words)) + "</p></li>\n")
+				}
+				content.WriteString("</ul>\n")
+			}
+		}
+
+		renderPage(w, "Search", template.HTML(content.String()))
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// SearchQueryStat is how often a query was searched and how many posts it found last time
+type SearchQueryStat struct {
+	Query    string
+	Count    int
+	Results  int
+	LastSeen time.Time
+}
+
+// SearchStats counts search queries. Only the normalized query text is kept,
+// never who searched or exactly when.
+type SearchStats struct {
+	db *sql.DB
+}
+
+const searchStatsSchema = `CREATE TABLE IF NOT EXISTS search_queries (
+	query TEXT PRIMARY KEY,
+	count INTEGER NOT NULL,
+	results INTEGER NOT NULL,
+	last_day INTEGER NOT NULL
+);`
+
+// NewSearchStats creates the search_queries table if needed
+func NewSearchStats(db *sql.DB) (*SearchStats, error) {
+	if _, err := db.Exec(searchStatsSchema); err != nil {
+		return nil, fmt.Errorf("create search_queries table: %w", err)
+	}
+	return &SearchStats{db: db}, nil
+}
+
+var (
+	emailPattern  = regexp.MustCompile(`\S+@\S+`)
+	numberPattern = regexp.MustCompile(`\d[\d\s-]{5,}\d`)
+)
+
+// maxStatQueryLength caps stored queries; longer ones are almost always pasted text
+const maxStatQueryLength = 100
+
+// anonymizeQuery normalizes a query for counting and masks anything that looks
+// like an email address or a phone or ID number
+func anonymizeQuery(q string) string {
+	q = strings.Join(strings.Fields(strings.ToLower(q)), " ")
+	q = emailPattern.ReplaceAllString(q, "[email]")
+	q = numberPattern.ReplaceAllString(q, "[number]")
+	if utf8.RuneCountInString(q) > maxStatQueryLength {
+		q = string([]rune(q)[:maxStatQueryLength])
+	}
+	return q
+}
+
+// Record counts a search and remembers how many posts it found
+func (s *SearchStats) Record(query string, results int) error {
+	q := anonymizeQuery(query)
+	if q == "" {
+		return nil
+	}
+	day := time.Now().UTC().Truncate(24 * time.Hour).Unix()
+	_, err := s.db.Exec(`INSERT INTO search_queries (query, count, results, last_day) VALUES (?, 1, ?, ?)
+		ON CONFLICT (query) DO UPDATE SET count = count + 1, results = excluded.results, last_day = excluded.last_day`,
+		q, results, day)
+	if err != nil {
+		return fmt.Errorf("record search: %w", err)
+	}
+	return nil
+}
+
+// Top returns the most searched queries. With zeroOnly, only queries that found nothing the last time.
+func (s *SearchStats) Top(limit int, zeroOnly bool) ([]SearchQueryStat, error) {
+	where := ""
+	if zeroOnly {
+		where = "WHERE results = 0"
+	}
+	rows, err := s.db.Query(`SELECT query, count, results, last_day FROM search_queries `+where+` ORDER BY count DESC, last_day DESC, query LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list searches: %w", err)
+	}
+	defer rows.Close()
+
+	var out []SearchQueryStat
+	for rows.Next() {
+		var st SearchQueryStat
+		var day int64
+		if err := rows.Scan(&st.Query, &st.Count, &st.Results, &day); err != nil {
+			return nil, fmt.Errorf("list searches: %w", err)
+		}
+		st.LastSeen = time.Unix(day, 0).UTC()
+		out = append(out, st)
+	}
+	return out, rows.Err()
+}
+
+// searchStatsTable renders one report table
+func searchStatsTable(stats []SearchQueryStat) string {
+	var b strings.Builder
+	b.WriteString("<table class=\"admin-table\">\n<tr><th>Query</th><th>Searches</th><th>Results</th><th>Last searched</th></tr>\n")
+	for _, st := range stats {
+		b.WriteString("<tr>")
+		b.WriteString("<td><a href=\"/search?q=" + template.URLQueryEscaper(st.Query) + "\">" + template.HTMLEscapeString(st.Query) + "</a></td>")
+		b.WriteString("<td>" + strconv.Itoa(st.Count) + "</td>")
+		b.WriteString("<td>" + strconv.Itoa(st.Results) + "</td>")
+		b.WriteString("<td>" + st.LastSeen.Format("Jan 2, 2006") + "</td>")
+		b.WriteString("</tr>\n")
+	}
+	b.WriteString("</table>\n")
+	return b.String()
+}
+
+// AdminStatsHandler reports what readers search for, and which searches find
+// nothing, as a guide to what to write next, and which posts got the most
+// claps when claps are on
+func AdminStatsHandler(stats *SearchStats, claps *ClapStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		top, err := stats.Top(25, false)
+		var empty []SearchQueryStat
+		if err == nil {
+			empty, err = stats.Top(25, true)
+		}
+		var clapped []ClapTotal
+		if err == nil && claps != nil {
+			clapped, err = claps.Top(25)
+		}
+		if err != nil {
+			slog.Error("Error loading search stats", "err", err)
+			http.Error(w, "Could not load stats", http.StatusInternalServerError)
+			return
+		}
+
+		var content bytes.Buffer
+		content.WriteString("<div class=\"admin-page\">\n")
+		content.WriteString(adminNav())
+		content.WriteString("<h1>Stats</h1>\n")
+		content.WriteString("<h2>Top searches</h2>\n")
+		if len(top) == 0 {
+			content.WriteString("<p>No searches yet.</p>\n")
+		} else {
+			content.WriteString(searchStatsTable(top))
+		}
+		content.WriteString("<h2>Searches with no results</h2>\n")
+		if len(empty) == 0 {
+			content.WriteString("<p>Every search found something.</p>\n")
+		} else {
+			content.WriteString("<p>Readers looked for these and found nothing; they may be worth writing about.</p>\n")
+			content.WriteString(searchStatsTable(empty))
+		}
+		if claps != nil {
+			content.WriteString("<h2>Most clapped posts</h2>\n")
+			if len(clapped) == 0 {
+				content.WriteString("<p>No claps yet.</p>\n")
+			} else {
+				content.WriteString(clapStatsTable(clapped))
+			}
+		}
+		content.WriteString("</div>")
+
+		renderPage(w, "Stats", template.HTML(content.String()))
+	}
+}
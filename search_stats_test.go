@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestSearchStats(t *testing.T) *SearchStats {
+	t.Helper()
+	stats, err := NewSearchStats(newTestDB(t))
+	if err != nil {
+		t.Fatalf("NewSearchStats: %v", err)
+	}
+	return stats
+}
+
+func TestAnonymizeQuery(t *testing.T) {
+	tests := map[string]string{
+		"  Go   Generics ":         "go generics",
+		"contact me@example.com":   "contact [email]",
+		"call 081-234-5678 please": "call [number] please",
+		"go 1.25":                  "go 1.25",
+		strings.Repeat("ก", 150):   strings.Repeat("ก", 100),
+	}
+	for in, want := range tests {
+		if got := anonymizeQuery(in); got != want {
+			t.Errorf("anonymizeQuery(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSearchStats(t *testing.T) {
+	stats := newTestSearchStats(t)
+	for _, s := range []struct {
+		q string
+		n int
+	}{{"Go", 3}, {"go ", 2}, {"rust", 0}, {"go", 2}, {"  ", 0}, {"zig", 0}, {"zig", 0}} {
+		if err := stats.Record(s.q, s.n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	top, err := stats.Top(10, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(top) != 3 || top[0].Query != "go" || top[0].Count != 3 || top[0].Results != 2 || top[1].Query != "zig" {
+		t.Errorf("Top = %+v", top)
+	}
+	empty, _ := stats.Top(10, true)
+	if len(empty) != 2 || empty[0].Query != "zig" || empty[1].Query != "rust" {
+		t.Errorf("zero-result searches = %+v", empty)
+	}
+}
+
+func TestSearchHandlerRecordsStats(t *testing.T) {
+	stats := newTestSearchStats(t)
+	posts := &MockSlugReader{content: map[string]string{"hello": "---\ntitle: Hello\n---\nWorld"}}
+	h := SearchHandler(&Searcher{Posts: posts, Dict: NewThaiDictionary(nil)}, stats)
+	for _, q := range []string{"hello", "missing", ""} {
+		h(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/search?q="+q, nil))
+	}
+
+	w := httptest.NewRecorder()
+	AdminStatsHandler(stats, nil)(w, httptest.NewRequest(http.MethodGet, "/admin/stats", nil))
+	body := w.Body.String()
+	_, noResults, _ := strings.Cut(body, "Searches with no results")
+	if !strings.Contains(body, `<a href="/search?q=hello">hello</a>`) || !strings.Contains(noResults, ">missing</a>") || strings.Contains(noResults, ">hello</a>") {
+		t.Errorf("body = %s", body)
+	}
+}
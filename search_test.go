@@ -0,0 +1,172 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	dict := NewThaiDictionary([]string{"เรียน", "ภาษา", "โก"})
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Hello, World!", []string{"hello", "world"}},
+		{"Go 1.25's vet", []string{"go", "1", "25", "s", "vet"}},
+		{"เรียนภาษาGo ง่ายๆ", []string{"เรียน", "ภาษา", "go", "ง่ายๆ"}},
+	}
+	for _, tt := range tests {
+		if got := tokenize(tt.text, dict); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("tokenize(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"golang", "golang", 0},
+		{"golang", "goalng", 1}, // swapped letters
+		{"golang", "golan", 1},
+		{"golang", "gulang", 1},
+		{"kitten", "sitting", 3},
+		{"ภาษา", "ภาษ", 1},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b, 5); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+	if got := editDistance("kitten", "sitting", 1); got != 2 {
+		t.Errorf("capped distance = %d, want 2", got)
+	}
+}
+
+func newTestSearchIndex(t *testing.T) *SearchIndex {
+	t.Helper()
+	posts := &MockSlugReader{content: map[string]string{
+		"goroutines":  "---\ntitle: Understanding Goroutines\ndate: 2026-01-10\n---\nConcurrency in Go with channels.",
+		"sqlite":      "---\ntitle: SQLite in Go\ndate: 2026-01-12\n---\nA database without a server. Goroutines are not needed.",
+		"th-learn-go": "---\ntitle: เรียนภาษาโก\ndate: 2026-01-15\n---\nการเขียนโปรแกรมภาษาโกสำหรับผู้เริ่มต้น",
+		"scheduled":   "---\ntitle: Goroutines Again\ndate: 2999-01-01\n---\nLater.",
+	}}
+	idx, err := NewSearchIndex(posts, NewThaiDictionary([]string{"เรียน", "ภาษา", "โก", "การ", "เขียน", "โปรแกรม", "สำหรับ", "ผู้", "เริ่มต้น"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return idx
+}
+
+func TestSearchIndex(t *testing.T) {
+	idx := newTestSearchIndex(t)
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"goroutines", []string{"goroutines", "sqlite"}}, // title match ranks first
+		{"gorutines", []string{"goroutines", "sqlite"}},  // one typo
+		{"goroutnies", []string{"goroutines", "sqlite"}}, // swapped letters
+		{"concurr", []string{"goroutines"}},              // prefix
+		{"database server", []string{"sqlite"}},
+		{"database channels", nil}, // every term must match
+		{"ภาษาโก", []string{"th-learn-go"}},
+		{"เขียนโปรแกรม", []string{"th-learn-go"}},
+		{"later", nil}, // scheduled posts aren't indexed
+		{"go", []string{"sqlite", "goroutines"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, r := range idx.Search(tt.query) {
+			got = append(got, r.Slug)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSearchHandler(t *testing.T) {
+	posts := &MockSlugReader{content: map[string]string{"hello": "---\ntitle: Hello <World>\n---\nGreetings from the blog."}}
+	h := SearchHandler(&Searcher{Posts: posts, Dict: NewThaiDictionary(nil)}, nil)
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/search?q=greetngs", nil))
+	body := w.Body.String()
+	if !strings.Contains(body, `<a href="/posts/hello">Hello &lt;World&gt;</a>`) || !strings.Contains(body, "<mark>Greetings</mark> from the blog.") {
+		t.Errorf("body = %s", body)
+	}
+	if got := w.Header().Get("X-Robots-Tag"); got != "noindex, follow" {
+		t.Errorf("X-Robots-Tag = %q", got)
+	}
+
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/search?q=%3Cscript%3E", nil))
+	if body := w.Body.String(); !strings.Contains(body, "No posts match <strong>&lt;script&gt;</strong>") {
+		t.Errorf("body = %s", body)
+	}
+}
+
+func TestSearchSnippet(t *testing.T) {
+	long := strings.Repeat("filler words here ", 20) + "the Goroutines <b>run</b> concurrently " + strings.Repeat("more text ", 30)
+	tests := []struct {
+		name  string
+		text  string
+		words []string
+		want  string
+	}{
+		{"whole words only", "Go, going, gone: go!", []string{"go"}, "<mark>Go</mark>, going, gone: <mark>go</mark>!"},
+		{"several words", "SQLite in Go", []string{"sqlite", "go"}, "<mark>SQLite</mark> in <mark>Go</mark>"},
+		{"thai", "การเขียนโปรแกรมภาษาโก", []string{"โปรแกรม"}, "การเขียน<mark>โปรแกรม</mark>ภาษาโก"},
+		{"no match", "Matched in the title only.", []string{"title"}, "Matched in the <mark>title</mark> only."},
+		{"no words", "a <b>bold</b> claim", nil, "a &lt;b&gt;bold&lt;/b&gt; claim"},
+	}
+	for _, tt := range tests {
+		if got := string(searchSnippet(tt.text, tt.words)); got != tt.want {
+			t.Errorf("%s: searchSnippet = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	got := string(searchSnippet(long, []string{"goroutines"}))
+	if !strings.HasPrefix(got, "…") || !strings.HasSuffix(got, "…") || !strings.Contains(got, "the <mark>Goroutines</mark> &lt;b&gt;run&lt;/b&gt;") {
+		t.Errorf("long snippet = %q", got)
+	}
+	if strings.HasPrefix(got, "…ords") || strings.HasPrefix(got, "…ere") {
+		t.Errorf("snippet starts mid-word: %q", got)
+	}
+}
+
+func TestSearcherRebuildsOnChange(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "first.md"), []byte("---\ntitle: First\ndate: 2025-01-01\n---\nAbout gophers."), 0644)
+	posts := newTestPostIndex(t, dir)
+	s := &Searcher{Posts: posts, Dict: NewThaiDictionary(nil)}
+
+	idx, err := s.Index()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := posts.Scan(); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := s.Index(); again != idx {
+		t.Error("index rebuilt without changes")
+	}
+	if err := posts.Write("second", "---\ntitle: Second\ndate: 2025-02-01\n---\nMore gophers."); err != nil {
+		t.Fatal(err)
+	}
+	idx, err = s.Index()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := idx.Search("gophers"); len(got) != 2 {
+		t.Errorf("results after write = %v", got)
+	}
+}
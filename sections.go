@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Section is a directory of short entries, like notes, with a landing page
+// listing them in full at /{name}, a permalink per entry at /{name}/{slug}, an
+// RSS feed at /{name}/feed.xml, and a sitemap of its own
+type Section struct {
+	// Name is both the directory and the URL path segment
+	Name string
+	// Title heads the landing page and names the feed
+	Title string
+	// Label is the entries in a sentence, e.g. "Follow notes (RSS)"
+	Label string
+	// Description is the feed description, said to be from the site
+	Description string
+}
+
+// sections are the content sections besides posts. Adding one here gives it
+// its pages, feed, and sitemap.
+var sections = []Section{
+	{Name: notesDir, Title: "Notes", Label: "notes", Description: "Short notes"},
+	{Name: tilDir, Title: "Today I Learned", Label: "TILs", Description: "Things learned day to day"},
+}
+
+// files returns the reader for the section's directory
+func (s Section) files() *FileReader {
+	return &FileReader{Dir: s.Name}
+}
+
+// handle mounts the section's landing page, feed, and entries on mux
+func (s Section) handle(mux *http.ServeMux) {
+	files := s.files()
+	mux.HandleFunc("GET /"+s.Name, SectionHandler(s, files))
+	mux.HandleFunc("GET /"+s.Name+"/feed.xml", Conditional(SectionFeedHandler(s, files)))
+	mux.HandleFunc("GET /"+s.Name+"/{slug}", SectionEntryHandler(s, files))
+}
+
+// sectionFeeds returns the feed path of every section
+func sectionFeeds() []string {
+	var paths []string
+	for _, s := range sections {
+		paths = append(paths, "/"+s.Name+"/feed.xml")
+	}
+	return paths
+}
+
+// sectionFiles returns the reader of every section by name, for the sitemap
+func sectionFiles() map[string]PostFiles {
+	files := make(map[string]PostFiles, len(sections))
+	for _, s := range sections {
+		files[s.Name] = s.files()
+	}
+	return files
+}
+
+// SectionHandler lists a section's entries in full, newest first
+func SectionHandler(s Section, files NoteFiles) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setSecurityHeaders(w)
+
+		all, err := loadNotes(files)
+		if err != nil {
+			slog.Error("Error loading section", "section", s.Name, "err", err)
+			renderError(w, http.StatusInternalServerError, requestLang(r))
+			return
+		}
+
+		pages := NewPagination(r, notesPerPage, len(all))
+		if pages.Page > 1 {
+			markNoIndex(w, PagePagination)
+		}
+		start, end := pages.Bounds()
+
+		var content bytes.Buffer
+		content.WriteString("<h1>" + template.HTMLEscapeString(s.Title) + "</h1>\n")
+		content.WriteString("<p class=\"notes-feed\"><a href=\"/" + s.Name + "/feed.xml\">Follow " + template.HTMLEscapeString(s.Label) + " (RSS)</a></p>\n")
+		if len(all) == 0 {
+			content.WriteString("<p>No " + template.HTMLEscapeString(s.Label) + " yet.</p>\n")
+		}
+		for _, n := range all[start:end] {
+			content.WriteString(noteHTML(n, s.Name, "h2"))
+		}
+		content.WriteString(pages.NavHTML())
+
+		renderPageData(w, PageData{
+			Title:   s.Title,
+			Content: template.HTML(content.String()),
+			Head:    pages.HeadLinks(siteURL(r)),
+		})
+	}
+}
+
+// SectionEntryHandler shows a single entry of a section
+func SectionEntryHandler(s Section, files SlugReader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setSecurityHeaders(w)
+
+		slug := r.PathValue("slug")
+		if !IsValidSlug(slug) {
+			renderError(w, http.StatusBadRequest, requestLang(r))
+			return
+		}
+		n, ok := loadNote(files, slug)
+		if !ok {
+			renderError(w, http.StatusNotFound, requestLang(r))
+			return
+		}
+		back := "<p><a href=\"/" + s.Name + "\">← All " + template.HTMLEscapeString(s.Label) + "</a></p>\n"
+		renderPage(w, n.Summary(), template.HTML(noteHTML(n, s.Name, "h1")+back))
+	}
+}
+
+// SectionFeedHandler serves an RSS feed of a section's newest entries
+func SectionFeedHandler(s Section, files NoteFiles) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setSecurityHeaders(w)
+		markNoIndex(w, PageFeed)
+
+		all, err := loadNotes(files)
+		if err != nil {
+			slog.Error("Error loading section feed", "section", s.Name, "err", err)
+			http.Error(w, "Could not read "+s.Label, http.StatusInternalServerError)
+			return
+		}
+
+		base := siteURL(r)
+		channel := rssChannel{
+			Title:       cfg.Site.Name + ": " + s.Title,
+			Link:        base + "/" + s.Name,
+			Description: s.Description + " from " + cfg.Site.Name,
+		}
+		for _, n := range all[:min(len(all), notesPerPage)] {
+			link := base + "/" + s.Name + "/" + n.Slug
+			item := rssItem{
+				Title:       n.Summary(),
+				Link:        link,
+				GUID:        rssGUID{Value: link, IsPermaLink: true},
+				Description: n.HTML,
+			}
+			if !n.Date.IsZero() {
+				item.PubDate = n.Date.UTC().Format(time.RFC1123Z)
+			}
+			channel.Items = append(channel.Items, item)
+		}
+
+		out, err := xml.MarshalIndent(rssFeed{Version: "2.0", DC: "http://purl.org/dc/elements/1.1/", Channel: channel}, "", "  ")
+		if err != nil {
+			slog.Error("Error encoding section feed", "section", s.Name, "err", err)
+			http.Error(w, "Could not render feed", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		w.Write([]byte(xml.Header))
+		w.Write(out)
+	}
+}
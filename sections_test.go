@@ -0,0 +1,132 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func testNotes() *MockSlugReader {
+	return &MockSlugReader{content: map[string]string{
+		"2026-01-01-old":    "---\ndate: 2026-01-01\n---\n\nAn **older** note.",
+		"2026-02-01-new":    "---\ndate: 2026-02-01\ntitle: Titled note\n---\n\nNewer.",
+		"2099-01-01-future": "---\ndate: 2099-01-01\n---\n\nNot yet.",
+	}}
+}
+
+func TestSectionHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	SectionHandler(sections[0], testNotes())(w, httptest.NewRequest("GET", "/notes", nil))
+
+	body := w.Body.String()
+	newer, older := strings.Index(body, "Titled note"), strings.Index(body, "<strong>older</strong>")
+	if newer < 0 || older < 0 || newer > older {
+		t.Errorf("expected both notes, newest first, got %s", body)
+	}
+	if strings.Contains(body, "Not yet") {
+		t.Error("scheduled note should be hidden")
+	}
+	if !strings.Contains(body, `href="/notes/2026-01-01-old">Jan 1, 2026</a>`) {
+		t.Errorf("expected a dated permalink, got %s", body)
+	}
+}
+
+func TestSectionEntryHandler(t *testing.T) {
+	tests := []struct {
+		slug      string
+		wantCode  int
+		wantTitle string
+	}{
+		{"2026-01-01-old", http.StatusOK, "<title>An older note."},
+		{"2026-02-01-new", http.StatusOK, "<title>Titled note"},
+		{"2099-01-01-future", http.StatusNotFound, ""},
+		{"missing", http.StatusNotFound, ""},
+		{"../secret", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.slug, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/notes/x", nil)
+			req.SetPathValue("slug", tt.slug)
+			w := httptest.NewRecorder()
+			SectionEntryHandler(sections[0], testNotes())(w, req)
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantCode)
+			}
+			if tt.wantTitle != "" && !strings.Contains(w.Body.String(), tt.wantTitle) {
+				t.Errorf("expected %q in %s", tt.wantTitle, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestSectionFeedHandler(t *testing.T) {
+	req := httptest.NewRequest("GET", "http://blog.example/notes/feed.xml", nil)
+	w := httptest.NewRecorder()
+	SectionFeedHandler(sections[0], testNotes())(w, req)
+
+	body := w.Body.String()
+	for _, want := range []string{
+		"<title>Titled note</title>",
+		"<title>An older note.</title>",
+		"<link>http://blog.example/notes/2026-01-01-old</link>",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("feed missing %q: %s", want, body)
+		}
+	}
+	if strings.Contains(body, "Not yet") {
+		t.Error("scheduled note should not be in the feed")
+	}
+}
+
+func TestSectionHandle(t *testing.T) {
+	t.Chdir(t.TempDir())
+	os.MkdirAll("til", 0755)
+	os.WriteFile("til/2026-10-01-defer.md", []byte("---\ntitle: Deferred calls run in reverse\ndate: 2026-10-01\n---\n\nLast in, first out."), 0644)
+
+	til := Section{Name: "til", Title: "Today I Learned", Label: "TILs", Description: "Things learned"}
+	mux := http.NewServeMux()
+	til.handle(mux)
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", "http://blog.example"+path, nil))
+		return w
+	}
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"/til", []string{"<h1>Today I Learned</h1>", `href="/til/feed.xml">Follow TILs (RSS)`, `href="/til/2026-10-01-defer">Oct 1, 2026</a>`}},
+		{"/til/2026-10-01-defer", []string{"<h1>Deferred calls run in reverse</h1>", `<a href="/til">← All TILs</a>`}},
+		{"/til/feed.xml", []string{"<title>LearnArai: Today I Learned</title>", "<description>Things learned from LearnArai</description>", "<link>http://blog.example/til/2026-10-01-defer</link>"}},
+	}
+	for _, tt := range tests {
+		w := get(tt.path)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: status %d", tt.path, w.Code)
+			continue
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(w.Body.String(), want) {
+				t.Errorf("%s: expected %q in %s", tt.path, want, w.Body.String())
+			}
+		}
+	}
+
+	// Every section gets its landing page and sitemap
+	s := &Sitemaps{Posts: &MockSlugReader{content: map[string]string{}}, Sections: map[string]PostFiles{"til": til.files()}}
+	items, err := s.sections()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items["til"]) != 1 || items["til"][0].Path != "/til/2026-10-01-defer" {
+		t.Errorf("til sitemap = %+v", items["til"])
+	}
+	if !slices.ContainsFunc(items["pages"], func(i sitemapItem) bool { return i.Path == "/til" }) {
+		t.Errorf("pages sitemap = %+v, want /til", items["pages"])
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import "net/url"
+
+// ShareLink is a prebuilt "share on" URL for one platform. Links are plain
+// hrefs, so sharing needs no third-party scripts on the page.
+type ShareLink struct {
+	// Name is the platform, e.g. "LINE"
+	Name string
+	URL  string
+}
+
+// shareLinks builds share URLs for a page from its canonical URL and title
+func shareLinks(canonical, title string) []ShareLink {
+	return []ShareLink{
+		{Name: "X", URL: "https://x.com/intent/post?" + url.Values{"text": {title}, "url": {canonical}}.Encode()},
+		{Name: "Facebook", URL: "https://www.facebook.com/sharer/sharer.php?" + url.Values{"u": {canonical}}.Encode()},
+		{Name: "LINE", URL: "https://social-plugins.line.me/lineit/share?" + url.Values{"url": {canonical}}.Encode()},
+		{Name: "Hacker News", URL: "https://news.ycombinator.com/submitlink?" + url.Values{"u": {canonical}, "t": {title}}.Encode()},
+	}
+}
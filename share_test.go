@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestShareLinks(t *testing.T) {
+	links := shareLinks("https://blog.example/posts/th-go", "เริ่มต้น Go & more")
+	want := map[string]string{
+		"X":           "https://x.com/intent/post?text=%E0%B9%80%E0%B8%A3%E0%B8%B4%E0%B9%88%E0%B8%A1%E0%B8%95%E0%B9%89%E0%B8%99+Go+%26+more&url=https%3A%2F%2Fblog.example%2Fposts%2Fth-go",
+		"Facebook":    "https://www.facebook.com/sharer/sharer.php?u=https%3A%2F%2Fblog.example%2Fposts%2Fth-go",
+		"LINE":        "https://social-plugins.line.me/lineit/share?url=https%3A%2F%2Fblog.example%2Fposts%2Fth-go",
+		"Hacker News": "https://news.ycombinator.com/submitlink?t=%E0%B9%80%E0%B8%A3%E0%B8%B4%E0%B9%88%E0%B8%A1%E0%B8%95%E0%B9%89%E0%B8%99+Go+%26+more&u=https%3A%2F%2Fblog.example%2Fposts%2Fth-go",
+	}
+	if len(links) != len(want) {
+		t.Fatalf("got %d links, want %d", len(links), len(want))
+	}
+	for _, l := range links {
+		if l.URL != want[l.Name] {
+			t.Errorf("%s = %s, want %s", l.Name, l.URL, want[l.Name])
+		}
+	}
+}
+
+func TestPostHandler_ShareLinks(t *testing.T) {
+	posts := &MockSlugReader{content: map[string]string{"hello": "---\ntitle: Hello\n---\n\nHi"}}
+	req := httptest.NewRequest("GET", "http://blog.example/posts/hello", nil)
+	req.SetPathValue("slug", "hello")
+	w := httptest.NewRecorder()
+	PostHandler(posts, nil, nil)(w, req)
+
+	body := w.Body.String()
+	for _, want := range []string{
+		`<meta property="og:url" content="http://blog.example/posts/hello">`,
+		`href="https://social-plugins.line.me/lineit/share?url=http%3A%2F%2Fblog.example%2Fposts%2Fhello"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %s", want)
+		}
+	}
+}
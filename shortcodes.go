@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"log/slog"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ShortcodeCall is a single {{< name args >}} invocation in post markdown
+type ShortcodeCall struct {
+	Name string
+	// Args are the positional arguments, with quotes removed
+	Args []string
+	// Params are the key=value arguments
+	Params map[string]string
+	// Slug is the post being rendered
+	Slug string
+}
+
+// Arg returns the positional argument at i, or "" if absent
+func (c ShortcodeCall) Arg(i int) string {
+	if i < len(c.Args) {
+		return c.Args[i]
+	}
+	return ""
+}
+
+// Shortcode renders a shortcode call to HTML
+type Shortcode func(call ShortcodeCall) (string, error)
+
+// shortcodes is the registry of available shortcodes
+var shortcodes = map[string]Shortcode{
+	"gallery": galleryShortcode,
+}
+
+var (
+	shortcodeRegex    = regexp.MustCompile(`\{\{<\s*([a-zA-Z][\w-]*)((?:\s+(?:[\w-]+=)?(?:"[^"]*"|[^\s">]+))*)\s*>\}\}`)
+	shortcodeArgRegex = regexp.MustCompile(`(?:([\w-]+)=)?(?:"([^"]*)"|([^\s"]+))`)
+)
+
+// expandShortcodes replaces shortcode calls with placeholders and returns the
+// rendered HTML for each. Markdown would escape raw HTML, so the HTML is swapped
+// back in after conversion by restoreShortcodes. Calls inside fenced code blocks
+// are left alone so posts can document the syntax.
+func expandShortcodes(src, slug string) (string, []string) {
+	var blocks []string
+	var out strings.Builder
+	inFence := false
+
+	for _, line := range strings.SplitAfter(src, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+		}
+		if inFence {
+			out.WriteString(line)
+			continue
+		}
+
+		out.WriteString(shortcodeRegex.ReplaceAllStringFunc(line, func(m string) string {
+			sub := shortcodeRegex.FindStringSubmatch(m)
+			sc, ok := shortcodes[sub[1]]
+			if !ok {
+				return m
+			}
+
+			call := ShortcodeCall{Name: sub[1], Params: map[string]string{}, Slug: slug}
+			for _, a := range shortcodeArgRegex.FindAllStringSubmatch(sub[2], -1) {
+				value := a[2] + a[3]
+				if a[1] != "" {
+					call.Params[a[1]] = value
+				} else {
+					call.Args = append(call.Args, value)
+				}
+			}
+
+			html, err := sc(call)
+			if err != nil {
+				slog.Error("Error rendering shortcode", "shortcode", call.Name, "slug", slug, "err", err)
+				html = "<!-- shortcode " + template.HTMLEscapeString(call.Name) + " failed -->"
+			}
+			blocks = append(blocks, html)
+			return shortcodePlaceholder(len(blocks) - 1)
+		}))
+	}
+	return out.String(), blocks
+}
+
+// restoreShortcodes swaps placeholders in rendered HTML for the shortcode output
+func restoreShortcodes(html string, blocks []string) string {
+	for i, block := range blocks {
+		ph := shortcodePlaceholder(i)
+		// Block-level shortcodes end up wrapped in a paragraph of their own
+		html = strings.Replace(html, "<p>"+ph+"</p>", block, 1)
+		html = strings.Replace(html, ph, block, 1)
+	}
+	return html
+}
+
+func shortcodePlaceholder(i int) string {
+	return fmt.Sprintf("@@shortcode-%d@@", i)
+}
+
+// galleryShortcode renders {{< gallery "vacation/*" >}} as a grid of thumbnails,
+// served through the image resizing endpoint, linking to the full-size images
+func galleryShortcode(call ShortcodeCall) (string, error) {
+	pattern := call.Arg(0)
+	if pattern == "" || strings.Contains(pattern, "..") || path.IsAbs(pattern) {
+		return "", fmt.Errorf("invalid gallery pattern %q", pattern)
+	}
+
+	matches, err := filepath.Glob(filepath.Join("images", filepath.FromSlash(pattern)))
+	if err != nil {
+		return "", fmt.Errorf("invalid gallery pattern %q: %w", pattern, err)
+	}
+	sort.Strings(matches)
+
+	var b strings.Builder
+	b.WriteString("<div class=\"gallery\">\n")
+	for _, m := range matches {
+		rel, err := filepath.Rel("images", m)
+		if err != nil || !isResizableImage(m) {
+			continue
+		}
+		src := "/images/" + filepath.ToSlash(rel)
+		alt := strings.TrimSuffix(filepath.Base(m), filepath.Ext(m))
+		b.WriteString("<a href=\"" + template.HTMLEscapeString(src) + "\">")
+		b.WriteString("<img src=\"" + template.HTMLEscapeString(src) + "?w=320\" alt=\"" + template.HTMLEscapeString(alt) + "\" loading=\"lazy\">")
+		b.WriteString("</a>\n")
+	}
+	b.WriteString("</div>")
+	return b.String(), nil
+}
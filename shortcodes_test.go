@@ -0,0 +1,86 @@
+package main
+
+import (
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandShortcodes(t *testing.T) {
+	orig := shortcodes["test"]
+	defer func() { shortcodes["test"] = orig }()
+	shortcodes["test"] = func(call ShortcodeCall) (string, error) {
+		return "<b>" + call.Arg(0) + "|" + call.Params["size"] + "|" + call.Slug + "</b>", nil
+	}
+
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "block shortcode",
+			src:  `{{< test "hello world" size=large >}}`,
+			want: "<b>hello world|large|my-post</b>",
+		},
+		{
+			name: "inline shortcode",
+			src:  `Before {{< test x >}} after`,
+			want: "<p>Before <b>x||my-post</b> after</p>",
+		},
+		{
+			name: "unknown shortcode left alone",
+			src:  `{{< nope >}}`,
+			want: "<p>{{&lt; nope &gt;}}</p>",
+		},
+		{
+			name: "fenced code is not expanded",
+			src:  "```\n{{< test x >}}\n```",
+			want: "<pre><code>{{&lt; test x &gt;}}\n</code></pre>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html, err := renderMarkdown(tt.src, "my-post")
+			if err != nil {
+				t.Fatalf("renderMarkdown: %v", err)
+			}
+			if strings.TrimSpace(html) != tt.want {
+				t.Errorf("got %q, want %q", strings.TrimSpace(html), tt.want)
+			}
+		})
+	}
+}
+
+func TestGalleryShortcode(t *testing.T) {
+	t.Chdir(t.TempDir())
+	os.MkdirAll(filepath.Join("images", "vacation"), 0755)
+	for _, name := range []string{"b.png", "a.png"} {
+		f, _ := os.Create(filepath.Join("images", "vacation", name))
+		png.Encode(f, image.NewRGBA(image.Rect(0, 0, 4, 4)))
+		f.Close()
+	}
+	os.WriteFile(filepath.Join("images", "vacation", "notes.txt"), []byte("skip"), 0644)
+
+	html, err := galleryShortcode(ShortcodeCall{Args: []string{"vacation/*"}})
+	if err != nil {
+		t.Fatalf("galleryShortcode: %v", err)
+	}
+
+	a := strings.Index(html, `<a href="/images/vacation/a.png"><img src="/images/vacation/a.png?w=320"`)
+	b := strings.Index(html, `<a href="/images/vacation/b.png">`)
+	if a < 0 || b < 0 || a > b {
+		t.Errorf("expected sorted thumbnails linking to full-size images, got %s", html)
+	}
+	if strings.Contains(html, "notes.txt") {
+		t.Error("non-image files should be skipped")
+	}
+
+	if _, err := galleryShortcode(ShortcodeCall{Args: []string{"../secret/*"}}); err == nil {
+		t.Error("expected error for pattern escaping the images directory")
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSitemapURLs is the most URLs one sitemap file may list; larger sections
+// are split into numbered files
+const maxSitemapURLs = 50000
+
+// sitemapNewsAge is how long a post stays in the news sitemap
+const sitemapNewsAge = 48 * time.Hour
+
+type sitemapIndex struct {
+	XMLName  xml.Name       `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+	Sitemaps []sitemapEntry `xml:"sitemap"`
+}
+
+type sitemapEntry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type urlSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	Image   string       `xml:"xmlns:image,attr,omitempty"`
+	News    string       `xml:"xmlns:news,attr,omitempty"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string         `xml:"loc"`
+	LastMod string         `xml:"lastmod,omitempty"`
+	Images  []sitemapImage `xml:"image:image"`
+	News    *sitemapNews   `xml:"news:news"`
+}
+
+type sitemapImage struct {
+	Loc string `xml:"image:loc"`
+}
+
+type sitemapNews struct {
+	Name            string `xml:"news:publication>news:name"`
+	Language        string `xml:"news:publication>news:language"`
+	PublicationDate string `xml:"news:publication_date"`
+	Title           string `xml:"news:title"`
+}
+
+// sitemapItem is one published page, the data every sitemap is generated from
+type sitemapItem struct {
+	Path  string
+	Title string
+	Date  time.Time
+	// Modified is when the page last changed: its date, or for undated pages
+	// the modification time of its file
+	Modified time.Time
+	Cover    string
+	Lang     string
+}
+
+// modTimer is implemented by content sources that know when a file last changed
+type modTimer interface {
+	ModTime(slug string) (time.Time, bool)
+}
+
+// sitemapSection lists the published files of a content directory under prefix, newest first
+func sitemapSection(files PostFiles, prefix string) ([]sitemapItem, error) {
+	slugs, err := files.List()
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var items []sitemapItem
+	for _, slug := range slugs {
+		src, err := files.Read(slug)
+		if err != nil {
+			continue
+		}
+		fm, _ := ParseFrontmatter(src)
+		// Syndicated posts are indexed where they were first published
+		if !postVisible(fm) || linkPostURL(fm.CanonicalURL) != "" {
+			continue
+		}
+		item := sitemapItem{Path: prefix + slug, Title: fm.Title, Cover: fm.Cover, Lang: "th"}
+		if item.Title == "" {
+			item.Title = toTitleCase(strings.ReplaceAll(slug, "-", " "))
+		}
+		if strings.HasPrefix(slug, "en-") {
+			item.Lang = "en"
+		}
+		item.Date, _ = parsePostDate(fm.Date)
+		item.Modified = item.Date
+		if mt, ok := files.(modTimer); ok && item.Modified.IsZero() {
+			item.Modified, _ = mt.ModTime(slug)
+		}
+		items = append(items, item)
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Date.After(items[j].Date) })
+	return items, nil
+}
+
+// Sitemaps generates the sitemap index and the per-section sitemaps it points at
+type Sitemaps struct {
+	Posts PostFiles
+	// Sections maps each content section's name to its files
+	Sections map[string]PostFiles
+	// DataFiles maps a page path to the file it is rendered from; pages whose
+	// file is missing are left out
+	DataFiles map[string]string
+}
+
+// sections returns every sitemap section by name
+func (s *Sitemaps) sections() (map[string][]sitemapItem, error) {
+	pages := []sitemapItem{{Path: "/"}, {Path: "/contact"}}
+	names := slices.Sorted(maps.Keys(s.Sections))
+	for _, name := range names {
+		pages = append(pages, sitemapItem{Path: "/" + name})
+	}
+	for _, path := range slices.Sorted(maps.Keys(s.DataFiles)) {
+		if _, err := os.Stat(s.DataFiles[path]); err == nil {
+			pages = append(pages, sitemapItem{Path: path})
+		}
+	}
+
+	posts, err := sitemapSection(s.Posts, "/posts/")
+	if err != nil {
+		return nil, err
+	}
+	out := map[string][]sitemapItem{"pages": pages, "posts": posts}
+	for _, name := range names {
+		if out[name], err = sitemapSection(s.Sections[name], "/"+name+"/"); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// newsItems returns the posts published within sitemapNewsAge
+func newsItems(posts []sitemapItem, now time.Time) []sitemapItem {
+	var out []sitemapItem
+	for _, p := range posts {
+		if !p.Date.IsZero() && now.Sub(p.Date) <= sitemapNewsAge {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// sitemapFiles splits a section into files of at most maxSitemapURLs URLs,
+// named section.xml, section-2.xml, ...
+func sitemapFiles(section string, items []sitemapItem) map[string][]sitemapItem {
+	files := make(map[string][]sitemapItem)
+	for i := 0; i < len(items) || i == 0; i += maxSitemapURLs {
+		name := section
+		if i > 0 {
+			name += "-" + strconv.Itoa(i/maxSitemapURLs+1)
+		}
+		files[name] = items[i:min(i+maxSitemapURLs, len(items))]
+	}
+	return files
+}
+
+// lastMod returns the newest modification among items, formatted for a sitemap
+func lastMod(items []sitemapItem) string {
+	var newest time.Time
+	for _, it := range items {
+		if it.Modified.After(newest) {
+			newest = it.Modified
+		}
+	}
+	if newest.IsZero() {
+		return ""
+	}
+	return newest.Format(time.RFC3339)
+}
+
+// absoluteURL makes a site path or URL absolute
+func absoluteURL(base, ref string) string {
+	if strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://") {
+		return ref
+	}
+	return base + "/" + strings.TrimPrefix(ref, "/")
+}
+
+// files returns every sitemap file by name, news included when there is recent news
+func (s *Sitemaps) files(now time.Time) (map[string][]sitemapItem, error) {
+	sections, err := s.sections()
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string][]sitemapItem)
+	for section, items := range sections {
+		if len(items) == 0 {
+			continue
+		}
+		for name, chunk := range sitemapFiles(section, items) {
+			files[name] = chunk
+		}
+	}
+	if news := newsItems(sections["posts"], now); len(news) > 0 {
+		files["news"] = news
+	}
+	return files, nil
+}
+
+func writeXML(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		slog.Error("Error encoding sitemap", "err", err)
+	}
+}
+
+// IndexHandler serves /sitemap.xml, a sitemap index of the section sitemaps
+func (s *Sitemaps) IndexHandler(w http.ResponseWriter, r *http.Request) {
+	files, err := s.files(time.Now())
+	if err != nil {
+		slog.Error("Error building sitemap", "err", err)
+		http.Error(w, "Could not build sitemap", http.StatusInternalServerError)
+		return
+	}
+	base := siteURL(r)
+	index := sitemapIndex{}
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		index.Sitemaps = append(index.Sitemaps, sitemapEntry{Loc: base + "/sitemaps/" + name + ".xml", LastMod: lastMod(files[name])})
+	}
+	writeXML(w, index)
+}
+
+// SectionHandler serves /sitemaps/{name}.xml
+func (s *Sitemaps) SectionHandler(w http.ResponseWriter, r *http.Request) {
+	name, ok := strings.CutSuffix(r.PathValue("file"), ".xml")
+	files, err := s.files(time.Now())
+	if err != nil {
+		slog.Error("Error building sitemap", "err", err)
+		http.Error(w, "Could not build sitemap", http.StatusInternalServerError)
+		return
+	}
+	items, found := files[name]
+	if !ok || !found {
+		http.NotFound(w, r)
+		return
+	}
+
+	base := siteURL(r)
+	set := urlSet{Image: "http://www.google.com/schemas/sitemap-image/1.1"}
+	if name == "news" {
+		set = urlSet{News: "http://www.google.com/schemas/sitemap-news/0.9"}
+	}
+	for _, it := range items {
+		u := sitemapURL{Loc: base + it.Path}
+		if !it.Modified.IsZero() {
+			u.LastMod = it.Modified.Format(time.RFC3339)
+		}
+		if name == "news" {
+			u.News = &sitemapNews{Name: cfg.Site.Name, Language: it.Lang, PublicationDate: it.Date.Format(time.RFC3339), Title: it.Title}
+		} else if it.Cover != "" {
+			u.Images = []sitemapImage{{Loc: absoluteURL(base, it.Cover)}}
+		}
+		set.URLs = append(set.URLs, u)
+	}
+	writeXML(w, set)
+}
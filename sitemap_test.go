@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestSitemaps(t *testing.T) *Sitemaps {
+	t.Helper()
+	recent := time.Now().Add(-2 * time.Hour).Format(time.RFC3339)
+	dir := t.TempDir()
+	projects := filepath.Join(dir, "projects.yaml")
+	os.WriteFile(projects, []byte("[]"), 0644)
+	return &Sitemaps{
+		Posts: &MockSlugReader{content: map[string]string{
+			"old":        "---\ntitle: Old Post\ndate: 2025-05-01\ncover: /images/old.jpg\n---\nOld",
+			"en-fresh":   "---\ntitle: Fresh & New\ndate: " + recent + "\n---\nNew",
+			"scheduled":  "---\ntitle: Later\ndate: 2999-01-01\n---\nSoon",
+			"syndicated": "---\ntitle: Elsewhere\ndate: 2025-04-01\ncanonical_url: https://dev.to/me/elsewhere\n---\nCopy",
+		}},
+		Sections:  map[string]PostFiles{"notes": &MockSlugReader{content: map[string]string{}}},
+		DataFiles: map[string]string{"/projects": projects, "/talks": filepath.Join(dir, "missing.yaml")},
+	}
+}
+
+func TestSitemapIndex(t *testing.T) {
+	s := newTestSitemaps(t)
+	w := httptest.NewRecorder()
+	s.IndexHandler(w, httptest.NewRequest(http.MethodGet, "/sitemap.xml", nil))
+
+	var index sitemapIndex
+	if err := xml.Unmarshal(w.Body.Bytes(), &index); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, w.Body)
+	}
+	var locs []string
+	for _, sm := range index.Sitemaps {
+		locs = append(locs, sm.Loc)
+	}
+	want := "http://example.com/sitemaps/news.xml http://example.com/sitemaps/pages.xml http://example.com/sitemaps/posts.xml"
+	if strings.Join(locs, " ") != want {
+		t.Errorf("sitemaps = %v, want %s (empty notes left out)", locs, want)
+	}
+}
+
+func TestSitemapSections(t *testing.T) {
+	s := newTestSitemaps(t)
+	get := func(file string) string {
+		r := httptest.NewRequest(http.MethodGet, "/sitemaps/"+file, nil)
+		r.SetPathValue("file", file)
+		w := httptest.NewRecorder()
+		s.SectionHandler(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status %d", file, w.Code)
+		}
+		return w.Body.String()
+	}
+
+	posts := get("posts.xml")
+	for _, want := range []string{
+		`xmlns:image="http://www.google.com/schemas/sitemap-image/1.1"`,
+		"<loc>http://example.com/posts/en-fresh</loc>",
+		"<image:image>\n      <image:loc>http://example.com/images/old.jpg</image:loc>",
+	} {
+		if !strings.Contains(posts, want) {
+			t.Errorf("posts sitemap missing %q:\n%s", want, posts)
+		}
+	}
+	if strings.Contains(posts, "scheduled") {
+		t.Error("scheduled post listed")
+	}
+	if strings.Contains(posts, "syndicated") {
+		t.Error("post with an external canonical URL listed")
+	}
+
+	pages := get("pages.xml")
+	if !strings.Contains(pages, "/projects</loc>") || strings.Contains(pages, "/talks</loc>") {
+		t.Errorf("pages sitemap:\n%s", pages)
+	}
+
+	news := get("news.xml")
+	if !strings.Contains(news, "<news:title>Fresh &amp; New</news:title>") || !strings.Contains(news, "<news:language>en</news:language>") || strings.Contains(news, "Old Post") {
+		t.Errorf("news sitemap:\n%s", news)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/sitemaps/notes.xml", nil)
+	r.SetPathValue("file", "notes.xml")
+	w := httptest.NewRecorder()
+	s.SectionHandler(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("empty section: status %d", w.Code)
+	}
+}
+
+func TestSitemapLastModFromFile(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "undated.md"), []byte("---\ntitle: Undated\n---\nHi"), 0644)
+	os.WriteFile(filepath.Join(dir, "dated.md"), []byte("---\ntitle: Dated\ndate: 2025-05-01\n---\nHi"), 0644)
+	mtime := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	for _, name := range []string{"undated.md", "dated.md"} {
+		os.Chtimes(filepath.Join(dir, name), mtime, mtime)
+	}
+
+	items, err := sitemapSection(newTestPostIndex(t, dir), "/posts/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]time.Time{}
+	for _, it := range items {
+		got[it.Path] = it.Modified
+	}
+	if !got["/posts/undated"].Equal(mtime) {
+		t.Errorf("undated: Modified = %v, want the file time", got["/posts/undated"])
+	}
+	if want, _ := parsePostDate("2025-05-01"); !got["/posts/dated"].Equal(want) {
+		t.Errorf("dated: Modified = %v, want the frontmatter date", got["/posts/dated"])
+	}
+}
+
+func TestSitemapFiles(t *testing.T) {
+	items := make([]sitemapItem, maxSitemapURLs*2+1)
+	files := sitemapFiles("posts", items)
+	if len(files) != 3 || len(files["posts"]) != maxSitemapURLs || len(files["posts-3"]) != 1 {
+		t.Errorf("got %d files", len(files))
+	}
+}
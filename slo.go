@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// routeStatsSource is where the SLO checks read the counts per route, as
+// Metrics keeps them
+type routeStatsSource interface {
+	RouteStats() map[string]RouteStats
+}
+
+// routeSnapshot is the counts per route at one check
+type routeSnapshot struct {
+	at    time.Time
+	stats map[string]RouteStats
+}
+
+// sloWindow keeps the snapshots the SLO checks need to tell what happened in
+// the last window, as the counts only ever grow
+type sloWindow struct {
+	snapshots []routeSnapshot
+}
+
+// add records the counts taken at now and returns what each route served
+// since the newest snapshot at least window old. Until the checks have run
+// for a whole window that's everything since the server started.
+func (sw *sloWindow) add(now time.Time, stats map[string]RouteStats, window time.Duration) map[string]RouteStats {
+	var base map[string]RouteStats
+	keep := 0
+	for i, s := range sw.snapshots {
+		if now.Sub(s.at) >= window {
+			base = s.stats
+			keep = i
+		}
+	}
+	sw.snapshots = append(sw.snapshots[keep:], routeSnapshot{at: now, stats: stats})
+
+	deltas := make(map[string]RouteStats, len(stats))
+	for route, cur := range stats {
+		old := base[route]
+		d := RouteStats{Requests: cur.Requests - old.Requests, Errors: cur.Errors - old.Errors, Buckets: slices.Clone(cur.Buckets)}
+		for i := range min(len(d.Buckets), len(old.Buckets)) {
+			d.Buckets[i] -= old.Buckets[i]
+		}
+		if d.Requests > 0 {
+			deltas[route] = d
+		}
+	}
+	return deltas
+}
+
+// fastRequests counts the requests answered within limit. Durations are only
+// known to their bucket, so a limit between bounds counts up to the bound below.
+func (s RouteStats) fastRequests(limit time.Duration) uint64 {
+	var n uint64
+	for i, bound := range durationBuckets {
+		if i >= len(s.Buckets) || bound > limit.Seconds() {
+			break
+		}
+		n += s.Buckets[i]
+	}
+	return n
+}
+
+// checkErrorRate warns when a route failed more than its share of requests
+// within the window
+func (m *HealthMonitor) checkErrorRate(deltas map[string]RouteStats) HealthResult {
+	slo := m.cfg.SLO
+	res := HealthResult{Name: "error_rate", OK: true}
+	var failing []string
+	for _, route := range slices.Sorted(maps.Keys(deltas)) {
+		d := deltas[route]
+		if d.Requests < uint64(slo.MinRequests) {
+			continue
+		}
+		if rate := float64(d.Errors) / float64(d.Requests); rate > slo.MaxErrorRate {
+			failing = append(failing, fmt.Sprintf("%s: %.0f%% of %d requests failed", route, rate*100, d.Requests))
+		}
+	}
+	if len(failing) > 0 {
+		res.OK = false
+		res.Detail = strings.Join(failing, "; ")
+		return res
+	}
+	res.Detail = fmt.Sprintf("no route above %.0f%% errors in the last %v", slo.MaxErrorRate*100, slo.Window)
+	return res
+}
+
+// checkLatency warns when a route answered too few requests within the
+// latency objective during the window
+func (m *HealthMonitor) checkLatency(deltas map[string]RouteStats) HealthResult {
+	slo := m.cfg.SLO
+	res := HealthResult{Name: "latency", OK: true}
+	var slow []string
+	for _, route := range slices.Sorted(maps.Keys(deltas)) {
+		d := deltas[route]
+		if d.Requests < uint64(slo.MinRequests) {
+			continue
+		}
+		if share := float64(d.fastRequests(slo.Latency)) / float64(d.Requests); share < slo.LatencyTarget {
+			slow = append(slow, fmt.Sprintf("%s: %.0f%% of %d requests within %v", route, share*100, d.Requests, slo.Latency))
+		}
+	}
+	if len(slow) > 0 {
+		res.OK = false
+		res.Detail = strings.Join(slow, "; ")
+		return res
+	}
+	res.Detail = fmt.Sprintf("%.0f%% of requests to every route within %v in the last %v", slo.LatencyTarget*100, slo.Latency, slo.Window)
+	return res
+}
+
+// checkSLO runs the checks of the objectives that are set, or none
+func (m *HealthMonitor) checkSLO(now time.Time) []HealthResult {
+	slo := m.cfg.SLO
+	if slo.MaxErrorRate <= 0 && slo.Latency <= 0 {
+		return nil
+	}
+	deltas := m.window.add(now, m.routes.RouteStats(), slo.Window)
+	var results []HealthResult
+	if slo.MaxErrorRate > 0 {
+		results = append(results, m.checkErrorRate(deltas))
+	}
+	if slo.Latency > 0 {
+		results = append(results, m.checkLatency(deltas))
+	}
+	return results
+}
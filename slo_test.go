@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRouteStats hands out the counts a test sets
+type fakeRouteStats struct{ stats map[string]RouteStats }
+
+func (f *fakeRouteStats) RouteStats() map[string]RouteStats { return f.stats }
+
+// routeStats builds the counts of a route whose requests took fast or slow,
+// 10ms and 2s
+func routeStats(fast, slow, errors uint64) RouteStats {
+	buckets := make([]uint64, len(durationBuckets)+1)
+	buckets[7] = fast // le 0.025
+	buckets[len(durationBuckets)] = slow
+	return RouteStats{Requests: fast + slow, Errors: errors, Buckets: buckets}
+}
+
+func TestSLOWindow(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	var sw sloWindow
+	d := sw.add(now, map[string]RouteStats{"GET /": routeStats(10, 0, 1)}, 10*time.Minute)
+	if d["GET /"].Requests != 10 {
+		t.Errorf("first = %+v", d)
+	}
+	d = sw.add(now.Add(5*time.Minute), map[string]RouteStats{"GET /": routeStats(30, 0, 1)}, 10*time.Minute)
+	if d["GET /"].Requests != 30 {
+		t.Errorf("within the first window = %+v", d)
+	}
+	// A window on, the counts are those since the snapshot ten minutes ago
+	d = sw.add(now.Add(10*time.Minute), map[string]RouteStats{"GET /": routeStats(35, 5, 3)}, 10*time.Minute)
+	if s := d["GET /"]; s.Requests != 30 || s.Errors != 2 || s.Buckets[7] != 25 || s.Buckets[len(durationBuckets)] != 5 {
+		t.Errorf("a window on = %+v", s)
+	}
+	// Routes with nothing new are left out, and snapshots older than the
+	// window's start are dropped
+	d = sw.add(now.Add(20*time.Minute), map[string]RouteStats{"GET /": routeStats(35, 5, 3)}, 10*time.Minute)
+	if len(d) != 0 {
+		t.Errorf("idle = %+v", d)
+	}
+	if len(sw.snapshots) != 2 {
+		t.Errorf("kept %d snapshots", len(sw.snapshots))
+	}
+}
+
+func TestHealthMonitorSLO(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	queue := newTestQueue(t)
+	var alerts []map[string]string
+	queue.Register("webhook", func(ctx context.Context, payload []byte) error {
+		var d WebhookDelivery
+		json.Unmarshal(payload, &d)
+		var body map[string]string
+		json.Unmarshal(d.Body, &body)
+		alerts = append(alerts, body)
+		return nil
+	})
+	runAlerts := func() {
+		for {
+			if processed, _ := queue.RunOnce(context.Background()); !processed {
+				return
+			}
+		}
+	}
+
+	c := HealthConfig{
+		IndexStaleAfter: time.Hour,
+		AlertWebhooks:   []string{"https://hooks.example/x"},
+		SLO:             SLOConfig{Window: 10 * time.Minute, MinRequests: 20, MaxErrorRate: 0.05, Latency: 500 * time.Millisecond, LatencyTarget: 0.9},
+	}
+	source := &fakeRouteStats{stats: map[string]RouteStats{
+		"GET /posts/{slug}": routeStats(100, 0, 0),
+		// Too few requests to judge
+		"GET /search": routeStats(0, 5, 5),
+	}}
+	m := NewHealthMonitor(c, t.TempDir(), &fakeIndex{scanned: now}, &fakeDeadJobs{}, queue)
+	m.routes = source
+	findResult := func(results []HealthResult, name string) HealthResult {
+		for _, r := range results {
+			if r.Name == name {
+				return r
+			}
+		}
+		t.Fatalf("no %s check in %+v", name, results)
+		return HealthResult{}
+	}
+
+	results := m.Check(now)
+	runAlerts()
+	if r := findResult(results, "error_rate"); !r.OK || r.Critical {
+		t.Errorf("error_rate = %+v", r)
+	}
+	if r := findResult(results, "latency"); !r.OK {
+		t.Errorf("latency = %+v", r)
+	}
+	if len(alerts) != 0 {
+		t.Errorf("alerts while healthy: %v", alerts)
+	}
+
+	// 20 of the next 50 requests fail, and slowly
+	source.stats = map[string]RouteStats{"GET /posts/{slug}": routeStats(130, 20, 20), "GET /search": routeStats(0, 5, 5)}
+	results = m.Check(now.Add(10 * time.Minute))
+	runAlerts()
+	if r := findResult(results, "error_rate"); r.OK || r.Detail != "GET /posts/{slug}: 40% of 50 requests failed" {
+		t.Errorf("error_rate = %+v", r)
+	}
+	if r := findResult(results, "latency"); r.OK || r.Detail != "GET /posts/{slug}: 60% of 50 requests within 500ms" {
+		t.Errorf("latency = %+v", r)
+	}
+	if len(alerts) != 2 || alerts[0]["check"] != "error_rate" || alerts[0]["status"] != "failing" || alerts[1]["check"] != "latency" {
+		t.Fatalf("alerts = %v", alerts)
+	}
+
+	// A window later with only good requests both recover
+	source.stats = map[string]RouteStats{"GET /posts/{slug}": routeStats(230, 20, 20), "GET /search": routeStats(0, 5, 5)}
+	m.Check(now.Add(20 * time.Minute))
+	runAlerts()
+	if len(alerts) != 4 || alerts[2]["status"] != "recovered" || !strings.Contains(alerts[3]["text"], "latency check recovered") {
+		t.Errorf("alerts = %v", alerts)
+	}
+}
+
+func TestHealthMonitorSLOOff(t *testing.T) {
+	m := NewHealthMonitor(HealthConfig{IndexStaleAfter: time.Hour}, t.TempDir(), &fakeIndex{scanned: time.Now()}, &fakeDeadJobs{}, nil)
+	m.routes = &fakeRouteStats{}
+	if results := m.Check(time.Now()); len(results) != 3 {
+		t.Errorf("results = %+v", results)
+	}
+}
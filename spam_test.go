@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+type fixedSpamChecker struct {
+	score float64
+	err   error
+}
+
+func (f fixedSpamChecker) SpamScore(ctx context.Context, c Comment) (float64, error) {
+	return f.score, f.err
+}
+
+func TestSpamFilter_Classify(t *testing.T) {
+	tests := []struct {
+		name     string
+		checkers []SpamChecker
+		want     int
+	}{
+		{"no checkers", nil, SpamClean},
+		{"clean", []SpamChecker{fixedSpamChecker{score: 0.1}}, SpamClean},
+		{"highest score wins", []SpamChecker{fixedSpamChecker{score: 0.1}, fixedSpamChecker{score: 0.95}}, SpamLikely},
+		{"suspect", []SpamChecker{fixedSpamChecker{score: 0.6}}, SpamSuspect},
+		{"checker error holds for review", []SpamChecker{fixedSpamChecker{err: errors.New("timeout")}}, SpamSuspect},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewSpamFilter(tt.checkers...).Classify(context.Background(), Comment{}); got != tt.want {
+				t.Errorf("expected verdict %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHeuristicSpamChecker(t *testing.T) {
+	store, _ := newTestCommentStore(t)
+	for range 3 {
+		if err := store.Save(&Comment{Slug: "a", Name: "x", Body: "x", Status: CommentSpam, IP: "203.0.113.9"}); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+	store.Save(&Comment{Slug: "a", Name: "x", Body: "x", Status: CommentSpam, IP: "203.0.113.10"})
+
+	h, err := NewHeuristicSpamChecker(SpamConfig{
+		MaxLinks:     2,
+		BlockedWords: []string{"Casino"},
+		BlockedIPs:   []string{"198.51.100.0/24", "2001:db8::1"},
+	}, store)
+	if err != nil {
+		t.Fatalf("NewHeuristicSpamChecker: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		c    Comment
+		want float64
+	}{
+		{"clean", Comment{Body: "Thanks, see https://example.com", IP: "192.0.2.1"}, 0},
+		{"too many links", Comment{Body: "https://a.example www.b.example http://c.example", IP: "192.0.2.1"}, 0.6},
+		{"blocked word", Comment{Body: "best casino bonus", IP: "192.0.2.1"}, 1},
+		{"blocked range", Comment{Body: "hi", IP: "198.51.100.7"}, 1},
+		{"blocked IPv6", Comment{Body: "hi", IP: "2001:db8::1"}, 1},
+		{"earlier spam from IP", Comment{Body: "hi", IP: "203.0.113.10"}, 0.6},
+		{"repeat spammer", Comment{Body: "hi", IP: "203.0.113.9"}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := h.SpamScore(context.Background(), tt.c)
+			if err != nil {
+				t.Fatalf("SpamScore: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected score %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	if _, err := NewHeuristicSpamChecker(SpamConfig{BlockedIPs: []string{"not-an-ip"}}, nil); err == nil {
+		t.Error("expected an error for an invalid blocked IP")
+	}
+}
+
+func TestAkismetSpamChecker(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		proTip  string
+		want    float64
+		wantErr bool
+	}{
+		{"ham", "false", "", 0, false},
+		{"spam", "true", "", 0.6, false},
+		{"blatant spam", "true", "discard", 1, false},
+		{"invalid key", "invalid", "", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var form url.Values
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				r.ParseForm()
+				form = r.PostForm
+				if tt.proTip != "" {
+					w.Header().Set("X-akismet-pro-tip", tt.proTip)
+				}
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			a := &AkismetSpamChecker{Endpoint: srv.URL, APIKey: "key", Blog: "https://blog.example/", Client: srv.Client()}
+			got, err := a.SpamScore(context.Background(), Comment{Slug: "hello", Name: "Reader", Body: "Hi", IP: "192.0.2.1"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected score %v, got %v", tt.want, got)
+			}
+			if form.Get("api_key") != "key" || form.Get("user_ip") != "192.0.2.1" || form.Get("permalink") != "https://blog.example/posts/hello" {
+				t.Errorf("unexpected request form %v", form)
+			}
+		})
+	}
+}
+
+func TestCommentSubmitHandler_SpamRouting(t *testing.T) {
+	store, queue := newTestCommentStore(t)
+	reader := &MockSlugReader{content: map[string]string{"hello": "# Hello"}}
+
+	origModerate := cfg.Comments.Moderate
+	cfg.Comments.Moderate = false
+	defer func() { cfg.Comments.Moderate = origModerate }()
+
+	h, _ := NewHeuristicSpamChecker(SpamConfig{MaxLinks: 0, BlockedWords: []string{"viagra"}}, store)
+	handler := CommentSubmitHandler(store, reader, queue, NewEmailRenderer("templates/email"), "", NewSpamFilter(h), nil, nil)
+
+	tests := []struct {
+		body       string
+		wantStatus string
+	}{
+		{"Lovely write-up", CommentApproved},
+		{"See https://example.com", CommentPending},
+		{"cheap viagra", CommentSpam},
+	}
+	for _, tt := range tests {
+		req := postForm("/posts/hello/comments", url.Values{"name": {"Reader"}, "body": {tt.body}})
+		req.SetPathValue("slug", "hello")
+		w := httptest.NewRecorder()
+		handler(w, req)
+		if w.Code != http.StatusSeeOther {
+			t.Fatalf("expected status 303, got %d", w.Code)
+		}
+		comments, _ := store.ByStatus(tt.wantStatus)
+		if len(comments) == 0 || comments[0].Body != tt.body {
+			t.Errorf("expected %q to be %s, got %+v", tt.body, tt.wantStatus, comments)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// contentDirs are the directories the server reads from on every request
+var contentDirs = []string{"posts", notesDir, "static", "templates"}
+
+// secretEnv lists the environment variables holding credentials. The config
+// summary says whether each is set but never prints the value.
+var secretEnv = []string{
+	"ADMIN_PASSWORD", "SESSION_SECRET", "SMTP_PASSWORD", "AKISMET_API_KEY", "TTS_API_KEY", "METRICS_TOKEN", "PREVIEW_TOKEN", "POST_ENCRYPTION_KEY", "CORPUS_TOKEN", "AWS_SECRET_ACCESS_KEY", "GITHUB_TOKEN",
+	"MASTODON_TOKEN", "BLUESKY_APP_PASSWORD", "X_API_KEY", "X_API_SECRET", "X_ACCESS_TOKEN", "X_ACCESS_SECRET",
+}
+
+// Validate checks settings that would otherwise only fail once a request or
+// background job touched them. Every problem is reported, not just the first.
+func (c Config) Validate() error {
+	var errs []error
+	if n, err := strconv.Atoi(c.Port); err != nil || n < 1 || n > 65535 {
+		errs = append(errs, fmt.Errorf("port %q: must be a number from 1 to 65535", c.Port))
+	}
+	if strings.TrimSpace(c.Site.Name) == "" {
+		errs = append(errs, errors.New("site.name: required"))
+	}
+	if !slices.Contains(pathLanguages, c.Site.DefaultLang) {
+		errs = append(errs, fmt.Errorf("site.default_lang %q: must be one of %s", c.Site.DefaultLang, strings.Join(pathLanguages, ", ")))
+	}
+	for _, l := range c.Site.Contacts {
+		if l.Label == "" {
+			errs = append(errs, fmt.Errorf("site.contacts: %q needs a label", l.URL))
+		}
+		if _, ok := strings.CutPrefix(l.URL, "mailto:"); !ok {
+			if err := checkAbsoluteURL(l.URL); err != nil || l.URL == "" {
+				errs = append(errs, fmt.Errorf("site.contacts: %s: %q must be an http(s) or mailto: address", l.Label, l.URL))
+			}
+		}
+	}
+	if _, ok := logLevels[c.LogLevel]; !ok {
+		errs = append(errs, fmt.Errorf("log_level %q: must be debug, info, warn, or error", c.LogLevel))
+	}
+	switch c.LogFormat {
+	case "text", "json":
+	default:
+		errs = append(errs, fmt.Errorf("log_format %q: must be text or json", c.LogFormat))
+	}
+	if c.SlowRequestThreshold < 0 {
+		errs = append(errs, fmt.Errorf("slow_request_threshold %v: must not be negative", c.SlowRequestThreshold))
+	}
+	for _, kind := range c.NoIndexPages {
+		switch kind {
+		case PageFeed, PageSearch, PageTag, PagePagination:
+		default:
+			errs = append(errs, fmt.Errorf("noindex_pages: unknown page kind %q (want feed, search, tag, or pagination)", kind))
+		}
+	}
+	for _, u := range []struct{ key, value string }{
+		{"site_url", c.SiteURL},
+		{"comments.spam.akismet_endpoint", c.Comments.Spam.AkismetEndpoint},
+		{"comments.spam.site_url", c.Comments.Spam.SiteURL},
+		{"tts.endpoint", c.TTS.Endpoint},
+		{"repo.url", c.Repo.URL},
+		{"crosspost.mastodon.instance", c.CrossPost.Mastodon.Instance},
+		{"crosspost.bluesky.pds", c.CrossPost.Bluesky.PDS},
+		{"micropub.me", c.Micropub.Me},
+		{"micropub.authorization_endpoint", c.Micropub.AuthorizationEndpoint},
+		{"micropub.token_endpoint", c.Micropub.TokenEndpoint},
+		{"backup.s3.endpoint", c.Backup.S3.Endpoint},
+	} {
+		if err := checkAbsoluteURL(u.value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", u.key, err))
+		}
+	}
+	froms := make([]string, 0, len(c.Redirects))
+	for from := range c.Redirects {
+		froms = append(froms, from)
+	}
+	sort.Strings(froms)
+	for _, from := range froms {
+		to := c.Redirects[from]
+		if !strings.HasPrefix(from, "/") || strings.HasPrefix(from, "//") || from == "/" {
+			errs = append(errs, fmt.Errorf("redirects: %q must be a path starting with / other than the homepage", from))
+		}
+		if strings.HasPrefix(to, "/") && !strings.HasPrefix(to, "//") {
+			continue
+		}
+		if to == "" {
+			errs = append(errs, fmt.Errorf("redirects: %q has no target", from))
+		} else if err := checkAbsoluteURL(to); err != nil {
+			errs = append(errs, fmt.Errorf("redirects: %q: %w", from, err))
+		}
+	}
+	if c.Micropub.TokenEndpoint != "" && c.Micropub.Me == "" {
+		errs = append(errs, errors.New("micropub.me: required when micropub.token_endpoint is set"))
+	}
+	switch c.Cookies.Secure {
+	case "auto", "always", "never":
+	default:
+		errs = append(errs, fmt.Errorf("cookies.secure %q: must be auto, always, or never", c.Cookies.Secure))
+	}
+	if _, ok := sameSiteModes[c.Cookies.SameSite]; !ok {
+		errs = append(errs, fmt.Errorf("cookies.same_site %q: must be lax, strict, or none", c.Cookies.SameSite))
+	}
+	if c.PostRescanInterval <= 0 {
+		errs = append(errs, fmt.Errorf("post_rescan_interval %v: must be positive", c.PostRescanInterval))
+	}
+	if c.Playground.Refresh <= 0 {
+		errs = append(errs, fmt.Errorf("playground.refresh %v: must be positive", c.Playground.Refresh))
+	}
+	if c.Repos.Refresh <= 0 {
+		errs = append(errs, fmt.Errorf("repos.refresh %v: must be positive", c.Repos.Refresh))
+	}
+	if c.CrossPost.MaxAge < 0 {
+		errs = append(errs, fmt.Errorf("crosspost.max_age %v: must not be negative", c.CrossPost.MaxAge))
+	}
+	if c.PreviewCheck.MaxAge < 0 {
+		errs = append(errs, fmt.Errorf("preview_check.max_age %v: must not be negative", c.PreviewCheck.MaxAge))
+	}
+	if c.PostsPerPage < 0 {
+		errs = append(errs, fmt.Errorf("posts_per_page %d: must not be negative", c.PostsPerPage))
+	}
+	switch c.PostStore.Type {
+	case "s3":
+		if c.PostStore.S3.Bucket == "" {
+			errs = append(errs, errors.New("post_store.s3.bucket: required for the s3 store"))
+		}
+	case "git":
+		if c.PostStore.Git.URL == "" || c.PostStore.Git.Dir == "" || c.PostStore.Git.Branch == "" {
+			errs = append(errs, errors.New("post_store.git: url, branch, and dir are required for the git store"))
+		}
+	}
+	if !slices.Contains(postStoreTypes, c.PostStore.Type) {
+		errs = append(errs, fmt.Errorf("post_store.type %q: must be one of %s", c.PostStore.Type, strings.Join(postStoreTypes, ", ")))
+	}
+	if c.MaxPostKB < 0 {
+		errs = append(errs, fmt.Errorf("max_post_kb %d: must not be negative", c.MaxPostKB))
+	}
+	if c.MaxImageUploadMB <= 0 {
+		errs = append(errs, fmt.Errorf("max_image_upload_mb %d: must be positive", c.MaxImageUploadMB))
+	}
+	if h := c.Markdown.Highlight; h.Enabled {
+		if !validHighlightStyle(h.Style) {
+			errs = append(errs, fmt.Errorf("markdown.highlight.style %q: not a chroma style", h.Style))
+		}
+		if h.DarkStyle != "" && !validHighlightStyle(h.DarkStyle) {
+			errs = append(errs, fmt.Errorf("markdown.highlight.dark_style %q: not a chroma style", h.DarkStyle))
+		}
+	}
+	if c.Backup.Enabled && c.Backup.Interval <= 0 {
+		errs = append(errs, fmt.Errorf("backup.interval %v: must be positive", c.Backup.Interval))
+	}
+	if c.Backup.Keep < 1 {
+		errs = append(errs, fmt.Errorf("backup.keep %d: must be at least 1", c.Backup.Keep))
+	}
+	if c.Backup.S3.Bucket == "" && c.Backup.Dir == "" {
+		errs = append(errs, errors.New("backup.dir: required unless backup.s3.bucket is set"))
+	}
+	if c.Backup.S3.Bucket != "" && c.Backup.S3.Region == "" {
+		errs = append(errs, errors.New("backup.s3.region: required when backup.s3.bucket is set"))
+	}
+	for _, p := range c.Backup.Paths {
+		if !fs.ValidPath(p) || p == "." || strings.Contains(p, "/") {
+			errs = append(errs, fmt.Errorf("backup.paths: %q must be a directory in the blog's root", p))
+		}
+	}
+	if c.Health.Interval <= 0 {
+		errs = append(errs, fmt.Errorf("health.interval %v: must be positive", c.Health.Interval))
+	}
+	if c.Health.MinFreeDisk < 0 || c.Health.MinFreeDisk >= 1 {
+		errs = append(errs, fmt.Errorf("health.min_free_disk %v: must be from 0 to below 1", c.Health.MinFreeDisk))
+	}
+	if c.Health.IndexStaleAfter <= c.PostRescanInterval {
+		errs = append(errs, fmt.Errorf("health.index_stale_after %v: must be longer than post_rescan_interval", c.Health.IndexStaleAfter))
+	}
+	if c.Health.MaxDeadJobs < 0 {
+		errs = append(errs, fmt.Errorf("health.max_dead_jobs %d: must not be negative", c.Health.MaxDeadJobs))
+	}
+	for _, u := range c.Health.AlertWebhooks {
+		if err := checkAbsoluteURL(u); err != nil || u == "" {
+			errs = append(errs, fmt.Errorf("health.alert_webhooks: %q must be an absolute http:// or https:// URL", u))
+		}
+	}
+	if slo := c.Health.SLO; slo.MaxErrorRate > 0 || slo.Latency > 0 {
+		if slo.Window < c.Health.Interval {
+			errs = append(errs, fmt.Errorf("health.slo.window %v: must be at least health.interval", slo.Window))
+		}
+		if slo.MinRequests < 1 {
+			errs = append(errs, fmt.Errorf("health.slo.min_requests %d: must be at least 1", slo.MinRequests))
+		}
+	}
+	if rate := c.Health.SLO.MaxErrorRate; rate < 0 || rate >= 1 {
+		errs = append(errs, fmt.Errorf("health.slo.max_error_rate %v: must be from 0 to below 1", rate))
+	}
+	if l := c.Health.SLO.Latency; l < 0 || l.Seconds() > durationBuckets[len(durationBuckets)-1] {
+		errs = append(errs, fmt.Errorf("health.slo.latency %v: must be from 0 to 1s", l))
+	}
+	if target := c.Health.SLO.LatencyTarget; c.Health.SLO.Latency > 0 && (target <= 0 || target > 1) {
+		errs = append(errs, fmt.Errorf("health.slo.latency_target %v: must be above 0 and at most 1", target))
+	}
+	if c.Comments.RateLimit < 0 {
+		errs = append(errs, fmt.Errorf("comments.rate_limit %d: must not be negative", c.Comments.RateLimit))
+	}
+	if c.Claps.Enabled && c.Claps.MaxPerReader < 1 {
+		errs = append(errs, fmt.Errorf("claps.max_per_reader %d: must be at least 1", c.Claps.MaxPerReader))
+	}
+	if c.Claps.RateLimit < 0 {
+		errs = append(errs, fmt.Errorf("claps.rate_limit %d: must not be negative", c.Claps.RateLimit))
+	}
+	if c.Comments.Spam.MaxLinks < 0 {
+		errs = append(errs, fmt.Errorf("comments.spam.max_links %d: must not be negative", c.Comments.Spam.MaxLinks))
+	}
+	return errors.Join(errs...)
+}
+
+// checkAbsoluteURL accepts an empty string or an absolute http(s) URL
+func checkAbsoluteURL(s string) error {
+	if s == "" {
+		return nil
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return fmt.Errorf("%q is not a valid URL", s)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q must be an absolute http:// or https:// URL", s)
+	}
+	return nil
+}
+
+// checkStartup verifies, relative to dir, that the content directories exist and
+// every page and email template parses, so a broken deploy stops at startup
+// instead of on the first request
+func checkStartup(dir string) error {
+	var errs []error
+	for _, d := range contentDirs {
+		if d == "posts" && cfg.PostStore.Type != "dir" {
+			continue
+		}
+		info, err := os.Stat(filepath.Join(dir, d))
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("content directory %s: %w (run the server from the blog's root directory)", d, err))
+		case !info.IsDir():
+			errs = append(errs, fmt.Errorf("content directory %s: not a directory", d))
+		}
+	}
+
+	if _, err := parsePageTemplates(dir, ""); err != nil {
+		errs = append(errs, fmt.Errorf("page template: %w", err))
+	}
+	if _, err := parseLangTemplates(dir); err != nil {
+		errs = append(errs, fmt.Errorf("page template: %w", err))
+	}
+	emailDir := filepath.Join(dir, "templates/email")
+	layout := filepath.Join(emailDir, "layout.html")
+	htmlFiles, _ := filepath.Glob(filepath.Join(emailDir, "*.html"))
+	for _, f := range htmlFiles {
+		if f == layout {
+			continue
+		}
+		if _, err := htmltemplate.New("email").Funcs(htmltemplate.FuncMap(emailFuncs)).ParseFiles(layout, f); err != nil {
+			errs = append(errs, fmt.Errorf("email template: %w", err))
+		}
+	}
+	txtFiles, _ := filepath.Glob(filepath.Join(emailDir, "*.txt"))
+	for _, f := range txtFiles {
+		if _, err := texttemplate.New("email").Funcs(texttemplate.FuncMap(emailFuncs)).ParseFiles(f); err != nil {
+			errs = append(errs, fmt.Errorf("email template: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// configSummary renders the effective configuration as YAML followed by which
+// secret environment variables are set. Passwords in URLs are masked.
+func configSummary(c Config) string {
+	c.SiteURL = redactURL(c.SiteURL)
+	c.Comments.Spam.AkismetEndpoint = redactURL(c.Comments.Spam.AkismetEndpoint)
+	c.Comments.Spam.SiteURL = redactURL(c.Comments.Spam.SiteURL)
+	c.TTS.Endpoint = redactURL(c.TTS.Endpoint)
+	c.Repo.URL = redactURL(c.Repo.URL)
+	c.Micropub.TokenEndpoint = redactURL(c.Micropub.TokenEndpoint)
+
+	var b strings.Builder
+	b.WriteString("env: " + c.Env + "\n")
+	out, err := yaml.Marshal(c)
+	if err != nil {
+		fmt.Fprintf(&b, "# could not encode config: %v\n", err)
+	} else {
+		b.Write(out)
+	}
+
+	vars := append([]string(nil), secretEnv...)
+	sort.Strings(vars)
+	b.WriteString("secrets:\n")
+	for _, name := range vars {
+		state := "unset"
+		if os.Getenv(name) != "" {
+			state = "set"
+		}
+		b.WriteString("  " + name + ": " + state + "\n")
+	}
+	return b.String()
+}
+
+// redactURL masks the password in a URL's userinfo
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// version is the deployed build, injected at build time with
+// go build -ldflags "-X main.version=$(git describe --tags --always)"
+var version = "dev"
+
+// startTime is when the server process started
+var startTime = time.Now()
+
+// SiteStatus is a quick health summary of the running server
+type SiteStatus struct {
+	Version        string    `json:"version"`
+	StartedAt      time.Time `json:"started_at"`
+	UptimeSeconds  int64     `json:"uptime_seconds"`
+	PostCount      int       `json:"post_count"`
+	ContentUpdated time.Time `json:"content_updated"`
+}
+
+// collectStatus gathers the current status. Only published posts are counted,
+// whatever store they come from, so drafts and private posts don't show.
+func collectStatus(posts *PostIndex) SiteStatus {
+	st := SiteStatus{
+		Version:       version,
+		StartedAt:     startTime,
+		UptimeSeconds: int64(time.Since(startTime).Seconds()),
+	}
+	for _, p := range posts.Posts() {
+		st.PostCount++
+		if mod, ok := posts.ModTime(p.Slug); ok && mod.After(st.ContentUpdated) {
+			st.ContentUpdated = mod
+		}
+	}
+	return st
+}
+
+// StatusHandler shows uptime, content freshness, and the deployed version.
+// Requests for /status.json get the same data as JSON.
+func StatusHandler(posts *PostIndex) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setSecurityHeaders(w)
+		w.Header().Set("Cache-Control", "no-store")
+
+		st := collectStatus(posts)
+
+		if strings.HasSuffix(r.URL.Path, ".json") {
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(st); err != nil {
+				slog.Error("Error encoding status", "err", err)
+			}
+			return
+		}
+
+		updated := "–"
+		if !st.ContentUpdated.IsZero() {
+			updated = st.ContentUpdated.Format("Jan 2, 2006 15:04")
+		}
+
+		var content bytes.Buffer
+		content.WriteString("<div class=\"status-page\">\n<h1>Status</h1>\n<dl class=\"status-list\">\n")
+		for _, row := range [][2]string{
+			{"Version", st.Version},
+			{"Uptime", formatUptime(time.Duration(st.UptimeSeconds) * time.Second)},
+			{"Started", st.StartedAt.Format("Jan 2, 2006 15:04")},
+			{"Posts", strconv.Itoa(st.PostCount)},
+			{"Content updated", updated},
+		} {
+			content.WriteString("<dt>" + row[0] + "</dt><dd>" + template.HTMLEscapeString(row[1]) + "</dd>\n")
+		}
+		content.WriteString("</dl>\n<p><a href=\"/status.json\">JSON</a></p>\n</div>")
+
+		renderPage(w, "Status", template.HTML(content.String()))
+	}
+}
+
+// formatUptime renders a duration as days, hours, and minutes
+func formatUptime(d time.Duration) string {
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	minutes := int(d.Minutes()) % 60
+	if days > 0 {
+		return strconv.Itoa(days) + "d " + strconv.Itoa(hours) + "h " + strconv.Itoa(minutes) + "m"
+	}
+	if hours > 0 {
+		return strconv.Itoa(hours) + "h " + strconv.Itoa(minutes) + "m"
+	}
+	return strconv.Itoa(minutes) + "m"
+}
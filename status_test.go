@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatusHandler_JSON(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "en-a.md"), []byte("a"), 0644)
+	os.WriteFile(filepath.Join(dir, "th-a.md"), []byte("a"), 0644)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0644)
+	os.WriteFile(filepath.Join(dir, "draft.md"), []byte("---\ndraft: true\n---\nd"), 0644)
+	os.WriteFile(filepath.Join(dir, "diary.md"), []byte("---\nprivate: true\n---\nd"), 0644)
+	os.WriteFile(filepath.Join(dir, "later.md"), []byte("---\ndate: 2999-01-01\n---\nd"), 0644)
+
+	w := httptest.NewRecorder()
+	StatusHandler(newTestPostIndex(t, dir))(w, httptest.NewRequest("GET", "/status.json", nil))
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("expected JSON content type, got %q", ct)
+	}
+
+	var st SiteStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &st); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if st.PostCount != 2 {
+		t.Errorf("expected 2 posts, got %d", st.PostCount)
+	}
+	if st.Version != version {
+		t.Errorf("expected version %q, got %q", version, st.Version)
+	}
+	if st.ContentUpdated.IsZero() {
+		t.Error("expected content updated time")
+	}
+}
+
+func TestStatusHandler_HTML(t *testing.T) {
+	w := httptest.NewRecorder()
+	StatusHandler(newTestPostIndex(t, t.TempDir()))(w, httptest.NewRequest("GET", "/status", nil))
+
+	if !strings.Contains(w.Body.String(), "<dt>Version</dt>") {
+		t.Error("expected status list in HTML page")
+	}
+}
+
+func TestFormatUptime(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{30 * time.Second, "0m"},
+		{90 * time.Minute, "1h 30m"},
+		{50 * time.Hour, "2d 2h 0m"},
+	}
+	for _, tt := range tests {
+		if got := formatUptime(tt.d); got != tt.want {
+			t.Errorf("formatUptime(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PostInfo is what a store knows about a post file without reading it
+type PostInfo struct {
+	Size    int64
+	ModTime time.Time
+}
+
+// PostStore is where post markdown comes from. The index lists, stats, and
+// reads posts through it, so they can live in a local directory, in the
+// binary, in an S3 bucket, or in a Git repository. Read returns a file as
+// stored. Stores that can be edited also implement SlugWriter.
+type PostStore interface {
+	SlugReader
+	SlugLister
+	Stat(slug string) (PostInfo, error)
+}
+
+// storeRefresher is implemented by stores that fetch their posts from
+// elsewhere, which the index refreshes before every periodic rescan
+type storeRefresher interface {
+	Refresh(ctx context.Context) error
+}
+
+// storeDir is implemented by stores kept in a local directory, which the
+// index watches for changes
+type storeDir interface {
+	LocalDir() string
+}
+
+// errReadOnlyStore is returned when writing to a store that can't be edited
+var errReadOnlyStore = errors.New("posts are read-only with this post_store")
+
+// postStoreTypes are the supported post_store.type values
+var postStoreTypes = []string{"dir", "embed", "s3", "git"}
+
+// embeddedPosts holds posts/ when the binary is built with -tags embedposts
+var embeddedPosts fs.FS
+
+// openPostStore returns the store the config selects. A Git store is cloned
+// or updated before it's returned.
+func openPostStore(ctx context.Context, c PostStoreConfig) (PostStore, error) {
+	switch c.Type {
+	case "embed":
+		if embeddedPosts == nil {
+			return nil, errors.New("post_store embed: the binary was built without -tags embedposts")
+		}
+		return FSStore{FS: embeddedPosts}, nil
+	case "s3":
+		return &S3PostStore{Bucket: &S3SnapshotStore{
+			Endpoint:  c.S3.Endpoint,
+			Region:    c.S3.Region,
+			Bucket:    c.S3.Bucket,
+			Prefix:    c.S3.Prefix,
+			AccessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			Client:    &http.Client{Timeout: 30 * time.Second},
+		}}, nil
+	case "git":
+		g := &GitPostStore{URL: c.Git.URL, Branch: c.Git.Branch, Dir: c.Git.Dir, Path: c.Git.Path}
+		if err := g.Refresh(ctx); err != nil {
+			// A checkout from an earlier run can still be served
+			if _, statErr := os.Stat(g.files().dir()); statErr != nil {
+				return nil, err
+			}
+			slog.Warn("Could not update posts, serving the existing checkout", "url", g.URL, "err", err)
+		}
+		return g, nil
+	default:
+		return &FileReader{Dir: "posts"}, nil
+	}
+}
+
+// FSStore reads posts from the top of a file system, such as the posts
+// embedded in the binary. It can't be edited.
+type FSStore struct {
+	FS fs.FS
+}
+
+func (s FSStore) Read(slug string) (string, error) {
+	b, err := fs.ReadFile(s.FS, slug+".md")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (s FSStore) List() ([]string, error) {
+	files, err := fs.ReadDir(s.FS, ".")
+	if err != nil {
+		return nil, err
+	}
+	var slugs []string
+	for _, f := range files {
+		if slug, ok := strings.CutSuffix(f.Name(), ".md"); ok && !f.IsDir() {
+			slugs = append(slugs, slug)
+		}
+	}
+	return slugs, nil
+}
+
+func (s FSStore) Stat(slug string) (PostInfo, error) {
+	info, err := fs.Stat(s.FS, slug+".md")
+	if err != nil {
+		return PostInfo{}, err
+	}
+	return PostInfo{Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// S3PostStore reads posts from the .md objects under a bucket's prefix. Stat
+// answers from the last listing, so the index lists before it stats. It can't
+// be edited.
+type S3PostStore struct {
+	Bucket *S3SnapshotStore
+
+	mu      sync.Mutex
+	objects map[string]PostInfo
+}
+
+func (s *S3PostStore) List() ([]string, error) {
+	objects, err := s.Bucket.objects(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	infos := make(map[string]PostInfo, len(objects))
+	var slugs []string
+	for _, o := range objects {
+		slug, ok := strings.CutSuffix(o.Name, ".md")
+		if !ok || strings.Contains(slug, "/") {
+			continue
+		}
+		infos[slug] = PostInfo{Size: o.Size, ModTime: o.LastModified}
+		slugs = append(slugs, slug)
+	}
+	sort.Strings(slugs)
+	s.mu.Lock()
+	s.objects = infos
+	s.mu.Unlock()
+	return slugs, nil
+}
+
+func (s *S3PostStore) Stat(slug string) (PostInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info, ok := s.objects[slug]
+	if !ok {
+		return PostInfo{}, &fs.PathError{Op: "stat", Path: slug + ".md", Err: fs.ErrNotExist}
+	}
+	return info, nil
+}
+
+func (s *S3PostStore) Read(slug string) (string, error) {
+	req, err := s.Bucket.request(context.Background(), http.MethodGet, s.Bucket.Prefix+slug+".md", nil, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := s.Bucket.do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// gitHistoryDepth is how many commits a Git post store fetches: enough for the
+// previous version of recently updated posts, without the whole history
+const gitHistoryDepth = "50"
+
+// GitPostStore serves posts from a checkout of a Git repository, kept current
+// by Refresh, so publishing is a git push. Local edits would be lost on the
+// next refresh, so it can't be edited.
+type GitPostStore struct {
+	URL    string
+	Branch string
+	// Dir is where the repository is checked out
+	Dir string
+	// Path is the directory holding the posts inside the repository; empty
+	// means its root
+	Path string
+
+	mu sync.Mutex
+}
+
+// files returns the reader for the posts in the checkout
+func (g *GitPostStore) files() *FileReader {
+	return &FileReader{Dir: filepath.Join(g.Dir, g.Path)}
+}
+
+func (g *GitPostStore) Read(slug string) (string, error)   { return g.files().Read(slug) }
+func (g *GitPostStore) List() ([]string, error)            { return g.files().List() }
+func (g *GitPostStore) Stat(slug string) (PostInfo, error) { return g.files().Stat(slug) }
+func (g *GitPostStore) LocalDir() string                   { return g.files().dir() }
+
+// Refresh clones the repository, or fetches the branch and moves the
+// checkout to it
+func (g *GitPostStore) Refresh(ctx context.Context) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, err := os.Stat(filepath.Join(g.Dir, ".git")); err != nil {
+		return g.git(ctx, "", "clone", "--depth", gitHistoryDepth, "--branch", g.Branch, g.URL, g.Dir)
+	}
+	if err := g.git(ctx, g.Dir, "fetch", "--depth", gitHistoryDepth, "origin", g.Branch); err != nil {
+		return err
+	}
+	return g.git(ctx, g.Dir, "reset", "--hard", "FETCH_HEAD")
+}
+
+// git runs a git command in dir, returning its output in the error
+func (g *GitPostStore) git(ctx context.Context, dir string, args ...string) error {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFSStore(t *testing.T) {
+	store := FSStore{FS: fstest.MapFS{
+		"hello.md":      {Data: []byte("---\ntitle: Hello\ndate: 2026-10-01\n---\n\nHi")},
+		"notes.txt":     {Data: []byte("not a post")},
+		"drafts/old.md": {Data: []byte("# Nested")},
+	}}
+	idx, err := NewStoreIndex(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if posts := idx.Posts(); len(posts) != 1 || posts[0].Title != "Hello" {
+		t.Errorf("Posts() = %+v, want just Hello", posts)
+	}
+	if err := idx.Write("new", "# New"); !errors.Is(err, errReadOnlyStore) {
+		t.Errorf("expected embedded posts to be read-only, got %v", err)
+	}
+}
+
+func TestOpenPostStore_EmbedNeedsBuildTag(t *testing.T) {
+	defer func(old fs.FS) { embeddedPosts = old }(embeddedPosts)
+	embeddedPosts = nil
+	if _, err := openPostStore(context.Background(), PostStoreConfig{Type: "embed"}); err == nil {
+		t.Error("expected an error without embedded posts")
+	}
+}
+
+func TestS3PostStore(t *testing.T) {
+	fake := &fakeS3{objects: map[string][]byte{
+		"posts/hello.md":       []byte("---\ntitle: Hello\n---\n\nHi"),
+		"posts/images/a.md":    []byte("# Nested"),
+		"posts/cover.png":      []byte("png"),
+		"elsewhere/outside.md": []byte("# Outside"),
+	}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	store := &S3PostStore{Bucket: &S3SnapshotStore{
+		Endpoint: srv.URL, Region: "us-east-1", Bucket: "bucket", Prefix: "posts/",
+		AccessKey: "AKID", SecretKey: "secret", Client: srv.Client(),
+	}}
+
+	idx, err := NewStoreIndex(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slugs, _ := idx.List(); len(slugs) != 1 || slugs[0] != "hello" {
+		t.Errorf("List() = %v, want [hello]", slugs)
+	}
+	if md, err := idx.Read("hello"); err != nil || md != "---\ntitle: Hello\n---\n\nHi" {
+		t.Errorf("Read = %q, %v", md, err)
+	}
+
+	// A new object shows up on the next scan
+	fake.mu.Lock()
+	fake.objects["posts/second.md"] = []byte("# Second")
+	fake.mu.Unlock()
+	if err := idx.Scan(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := idx.Get("second"); !ok {
+		t.Error("expected the new object to be indexed")
+	}
+}
+
+func TestGitPostStore(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	root := t.TempDir()
+	run := func(dir string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=Test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	// The author's working copy, pushing to a bare repository the server pulls from
+	origin, work := filepath.Join(root, "origin.git"), filepath.Join(root, "work")
+	run(root, "init", "--bare", "--initial-branch=main", origin)
+	run(root, "clone", origin, work)
+	os.MkdirAll(filepath.Join(work, "posts"), 0755)
+	os.WriteFile(filepath.Join(work, "posts", "hello.md"), []byte("# Hello"), 0644)
+	run(work, "add", ".")
+	run(work, "commit", "-m", "First post")
+	run(work, "push", "origin", "HEAD:main")
+
+	var c PostStoreConfig
+	c.Type = "git"
+	c.Git.URL, c.Git.Branch, c.Git.Dir, c.Git.Path = origin, "main", filepath.Join(root, "checkout"), "posts"
+	store, err := openPostStore(context.Background(), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := NewStoreIndex(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := idx.Get("hello"); !ok {
+		t.Fatal("expected the cloned post to be indexed")
+	}
+	if err := idx.Write("local", "# Local"); !errors.Is(err, errReadOnlyStore) {
+		t.Errorf("expected the checkout to be read-only, got %v", err)
+	}
+
+	// Publishing is a push
+	os.WriteFile(filepath.Join(work, "posts", "second.md"), []byte("# Second"), 0644)
+	run(work, "add", ".")
+	run(work, "commit", "-m", "Second post")
+	run(work, "push", "origin", "HEAD:main")
+	if err := store.(storeRefresher).Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	idx.Scan()
+	if _, ok := idx.Get("second"); !ok {
+		t.Error("expected the pushed post after a refresh")
+	}
+}
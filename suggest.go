@@ -0,0 +1,114 @@
+package main
+
+import (
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxSuggestionLength keeps corrections short; longer discussions belong in comments
+const maxSuggestionLength = 2000
+
+// SuggestEditHandler accepts a reader's correction to a post and files it in the
+// admin inbox. Likely spam is dropped without telling the sender.
+func SuggestEditHandler(store *ContactStore, sl SlugReader, spam *SpamFilter, limiter *RateLimiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setSecurityHeaders(w)
+
+		slug := r.PathValue("slug")
+		if !IsValidSlug(slug) {
+			http.Error(w, "Invalid post slug", http.StatusBadRequest)
+			return
+		}
+		postMarkdown, err := sl.Read(slug)
+		if err != nil {
+			http.Error(w, "Post not found", http.StatusNotFound)
+			return
+		}
+		if fm, _ := ParseFrontmatter(postMarkdown); !postVisible(fm) {
+			http.Error(w, "Post not found", http.StatusNotFound)
+			return
+		}
+
+		ip := clientIP(r)
+		if limiter != nil && !limiter.Allow(ip) {
+			http.Error(w, "Too many suggestions, please try again later", http.StatusTooManyRequests)
+			return
+		}
+
+		m := ContactMessage{
+			Name:      strings.TrimSpace(r.PostFormValue("name")),
+			Email:     strings.TrimSpace(r.PostFormValue("email")),
+			Message:   strings.TrimSpace(r.PostFormValue("message")),
+			PostSlug:  slug,
+			CreatedAt: time.Now(),
+		}
+		if m.Message == "" {
+			http.Error(w, "Please describe the correction", http.StatusBadRequest)
+			return
+		}
+		if utf8.RuneCountInString(m.Message) > maxSuggestionLength {
+			http.Error(w, "Suggestion is too long", http.StatusBadRequest)
+			return
+		}
+		if m.Email != "" && !strings.Contains(m.Email, "@") {
+			http.Error(w, "Invalid email address", http.StatusBadRequest)
+			return
+		}
+		if m.Name == "" {
+			m.Name = "Anonymous reader"
+		}
+
+		if spam != nil {
+			c := Comment{Slug: slug, Name: m.Name, Email: m.Email, Body: m.Message, IP: ip, UserAgent: r.UserAgent()}
+			if spam.Classify(r.Context(), c) == SpamLikely {
+				http.Redirect(w, r, "/posts/"+slug+"?suggested=1", http.StatusSeeOther)
+				return
+			}
+		}
+
+		if err := store.Save(&m); err != nil {
+			slog.Error("Error saving suggestion", "slug", slug, "err", err)
+			http.Error(w, "Could not save suggestion", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/posts/"+slug+"?suggested=1", http.StatusSeeOther)
+	}
+}
+
+// repoEditURL links to the post's markdown file in the configured repository, or
+// returns "" when no repository is set
+func repoEditURL(slug string) string {
+	if cfg.Repo.URL == "" {
+		return ""
+	}
+	branch := cfg.Repo.Branch
+	if branch == "" {
+		branch = "main"
+	}
+	return strings.TrimSuffix(cfg.Repo.URL, "/") + "/edit/" + branch + "/posts/" + slug + ".md"
+}
+
+// suggestEditHTML renders the collapsed correction form shown under a post
+func suggestEditHTML(slug string, sent bool) string {
+	var b strings.Builder
+	b.WriteString("<section class=\"suggest-edit\">\n")
+	if sent {
+		b.WriteString("<p class=\"suggest-edit-notice\">Thanks for the correction! It has been sent to the author.</p>\n")
+	}
+	b.WriteString("<details>\n<summary>Suggest an edit</summary>\n")
+	b.WriteString("<form method=\"POST\" action=\"/posts/" + template.HTMLEscapeString(slug) + "/suggest\">\n")
+	b.WriteString("<label>What should change?<textarea name=\"message\" rows=\"4\" maxlength=\"2000\" required></textarea></label>\n")
+	b.WriteString("<label>Name (optional)<input type=\"text\" name=\"name\"></label>\n")
+	b.WriteString("<label>Email (optional, if you'd like a reply)<input type=\"email\" name=\"email\"></label>\n")
+	b.WriteString("<button type=\"submit\">Send suggestion</button>\n")
+	b.WriteString("</form>\n")
+	if u := repoEditURL(slug); u != "" {
+		b.WriteString("<p>Or <a href=\"" + template.HTMLEscapeString(u) + "\" rel=\"noopener\">edit this post on GitHub</a>.</p>\n")
+	}
+	b.WriteString("</details>\n</section>\n")
+	return b.String()
+}
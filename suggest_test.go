@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestContactStore(t *testing.T) *ContactStore {
+	t.Helper()
+	store, err := NewContactStore(newTestDB(t))
+	if err != nil {
+		t.Fatalf("NewContactStore: %v", err)
+	}
+	return store
+}
+
+func TestSuggestEditHandler(t *testing.T) {
+	store := newTestContactStore(t)
+	posts := &MockSlugReader{content: map[string]string{"hello": "# Hello"}}
+	h, err := NewHeuristicSpamChecker(SpamConfig{MaxLinks: 2, BlockedWords: []string{"casino"}}, nil)
+	if err != nil {
+		t.Fatalf("NewHeuristicSpamChecker: %v", err)
+	}
+	spam := NewSpamFilter(h)
+	handler := SuggestEditHandler(store, posts, spam, NewRateLimiter(10, time.Hour))
+
+	tests := []struct {
+		name     string
+		slug     string
+		form     url.Values
+		wantCode int
+		wantSave bool
+	}{
+		{"valid", "hello", url.Values{"message": {"Typo in the second paragraph"}}, http.StatusSeeOther, true},
+		{"missing message", "hello", url.Values{"message": {"  "}}, http.StatusBadRequest, false},
+		{"too long", "hello", url.Values{"message": {strings.Repeat("x", maxSuggestionLength+1)}}, http.StatusBadRequest, false},
+		{"bad email", "hello", url.Values{"message": {"Fix"}, "email": {"nope"}}, http.StatusBadRequest, false},
+		{"unknown post", "missing", url.Values{"message": {"Fix"}}, http.StatusNotFound, false},
+		{"spam dropped", "hello", url.Values{"message": {"Visit my casino"}}, http.StatusSeeOther, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, _ := store.List()
+			req := postForm("/posts/"+tt.slug+"/suggest", tt.form)
+			req.SetPathValue("slug", tt.slug)
+			w := httptest.NewRecorder()
+			handler(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body.String())
+			}
+			after, _ := store.List()
+			if saved := len(after) > len(before); saved != tt.wantSave {
+				t.Errorf("saved = %v, want %v", saved, tt.wantSave)
+			}
+			if tt.wantSave && after[0].PostSlug != tt.slug {
+				t.Errorf("PostSlug = %q, want %q", after[0].PostSlug, tt.slug)
+			}
+		})
+	}
+}
+
+func TestSuggestEditHandler_RateLimited(t *testing.T) {
+	store := newTestContactStore(t)
+	posts := &MockSlugReader{content: map[string]string{"hello": "# Hello"}}
+	handler := SuggestEditHandler(store, posts, nil, NewRateLimiter(1, time.Hour))
+
+	for i, want := range []int{http.StatusSeeOther, http.StatusTooManyRequests} {
+		req := postForm("/posts/hello/suggest", url.Values{"message": {"Fix"}})
+		req.SetPathValue("slug", "hello")
+		w := httptest.NewRecorder()
+		handler(w, req)
+		if w.Code != want {
+			t.Errorf("request %d: status = %d, want %d", i, w.Code, want)
+		}
+	}
+}
+
+func TestSuggestEditHTML_RepoLink(t *testing.T) {
+	old := cfg.Repo
+	defer func() { cfg.Repo = old }()
+
+	cfg.Repo = RepoConfig{}
+	if strings.Contains(suggestEditHTML("hello", false), "GitHub") {
+		t.Error("no repo configured, but an edit link was rendered")
+	}
+
+	cfg.Repo = RepoConfig{URL: "https://github.com/me/blog/", Branch: "posts"}
+	want := `href="https://github.com/me/blog/edit/posts/posts/hello.md"`
+	if got := suggestEditHTML("hello", true); !strings.Contains(got, want) || !strings.Contains(got, "Thanks") {
+		t.Errorf("suggestEditHTML = %s, want link %s and a notice", got, want)
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// talksFile lists the talks and publications shown on /talks
+const talksFile = "data/talks.yaml"
+
+// Talk is a conference talk, meetup, podcast, or, with kind: publication, an
+// article published elsewhere
+type Talk struct {
+	Title    Localized `yaml:"title"`
+	Abstract Localized `yaml:"abstract"`
+	Kind     string    `yaml:"kind"`
+	// Date is a day or a day and start time, in the site timezone unless it has a zone
+	Date     string `yaml:"date"`
+	Duration string `yaml:"duration"`
+	Venue    string `yaml:"venue"`
+	Location string `yaml:"location"`
+	// URL is the event or publication page
+	URL    string `yaml:"url"`
+	Slides string `yaml:"slides"`
+	Video  string `yaml:"video"`
+	// YouTube and Vimeo are video IDs to embed a click-to-load player
+	YouTube string `yaml:"youtube"`
+	Vimeo   string `yaml:"vimeo"`
+
+	start  time.Time
+	allDay bool
+}
+
+// defaultTalkDuration is used for the calendar when a talk has a time but no duration
+const defaultTalkDuration = time.Hour
+
+// End returns when the talk finishes: the end of the day for all-day talks
+func (t Talk) End() time.Time {
+	if t.allDay {
+		return t.start.AddDate(0, 0, 1)
+	}
+	if d, err := time.ParseDuration(t.Duration); err == nil && d > 0 {
+		return t.start.Add(d)
+	}
+	return t.start.Add(defaultTalkDuration)
+}
+
+// loadTalks reads the talks data file, dropping entries without a valid date.
+// A missing file means no talks.
+func loadTalks(path string) ([]Talk, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var all []Talk
+	if err := yaml.Unmarshal(data, &all); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	talks := all[:0]
+	for _, t := range all {
+		start, ok := parsePostDate(t.Date)
+		if !ok {
+			slog.Warn("Talk has no valid date", "talk", t.Title.In("en"))
+			continue
+		}
+		t.start = start
+		t.allDay = len(strings.TrimSpace(t.Date)) == len("2006-01-02")
+		talks = append(talks, t)
+	}
+	sort.SliceStable(talks, func(i, j int) bool { return talks[i].start.After(talks[j].start) })
+	return talks, nil
+}
+
+// splitTalks separates upcoming talks, soonest first, from past talks and
+// publications, newest first
+func splitTalks(talks []Talk, now time.Time) (upcoming, past, publications []Talk) {
+	for _, t := range talks {
+		switch {
+		case t.Kind == "publication":
+			publications = append(publications, t)
+		case t.End().After(now):
+			upcoming = append([]Talk{t}, upcoming...)
+		default:
+			past = append(past, t)
+		}
+	}
+	return upcoming, past, publications
+}
+
+var talkLabels = map[string]Localized{
+	"heading":      {"en": "Talks & Publications", "th": "งานพูดและบทความ"},
+	"upcoming":     {"en": "Upcoming", "th": "เร็วๆ นี้"},
+	"past":         {"en": "Past talks", "th": "งานพูดที่ผ่านมา"},
+	"publications": {"en": "Publications", "th": "บทความ"},
+	"slides":       {"en": "Slides", "th": "สไลด์"},
+	"video":        {"en": "Video", "th": "วิดีโอ"},
+	"event":        {"en": "Event", "th": "งาน"},
+	"calendar":     {"en": "Add upcoming talks to your calendar (iCal)", "th": "เพิ่มงานพูดที่จะถึงลงในปฏิทิน (iCal)"},
+}
+
+// talkHTML renders one talk in lang
+func talkHTML(t Talk, lang string) string {
+	var b strings.Builder
+	b.WriteString("<article class=\"talk\">\n")
+	b.WriteString("<h3>" + template.HTMLEscapeString(t.Title.In(lang)) + "</h3>\n")
+
+	meta := []string{t.start.Format("Jan 2, 2006")}
+	for _, s := range []string{t.Venue, t.Location} {
+		if s != "" {
+			meta = append(meta, template.HTMLEscapeString(s))
+		}
+	}
+	b.WriteString("<p class=\"talk-meta\">" + strings.Join(meta, " · ") + "</p>\n")
+
+	if abstract := t.Abstract.In(lang); abstract != "" {
+		html, err := renderMarkdown(abstract, "talks")
+		if err != nil {
+			slog.Error("Error rendering talk", "talk", t.Title.In("en"), "err", err)
+			html = "<p>" + template.HTMLEscapeString(abstract) + "</p>"
+		}
+		b.WriteString(html)
+	}
+
+	call := ShortcodeCall{Args: []string{t.YouTube}, Params: map[string]string{"title": t.Title.In(lang), "load": "click"}}
+	var player string
+	var err error
+	switch {
+	case t.YouTube != "":
+		player, err = youtubeShortcode(call)
+	case t.Vimeo != "":
+		call.Args = []string{t.Vimeo}
+		player, err = vimeoShortcode(call)
+	}
+	if err != nil {
+		slog.Error("Error embedding video for talk", "talk", t.Title.In("en"), "err", err)
+	}
+	b.WriteString(player)
+
+	var links []string
+	for _, l := range []struct{ url, label string }{{t.Slides, "slides"}, {t.Video, "video"}, {t.URL, "event"}} {
+		if u := linkPostURL(l.url); u != "" {
+			links = append(links, "<a href=\""+template.HTMLEscapeString(u)+"\" rel=\"noopener\">"+talkLabels[l.label].In(lang)+"</a>")
+		}
+	}
+	if len(links) > 0 {
+		b.WriteString("<p class=\"talk-links\">" + strings.Join(links, " · ") + "</p>\n")
+	}
+	b.WriteString("</article>\n")
+	return b.String()
+}
+
+// TalksHandler renders upcoming and past talks and publications from the talks data file
+func TalksHandler(path string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setSecurityHeaders(w)
+
+		talks, err := loadTalks(path)
+		if err != nil {
+			slog.Error("Error loading talks", "err", err)
+			renderError(w, http.StatusInternalServerError, requestLang(r))
+			return
+		}
+		lang := requestLang(r)
+		upcoming, past, publications := splitTalks(talks, time.Now())
+
+		var content bytes.Buffer
+		heading := talkLabels["heading"].In(lang)
+		content.WriteString("<h1>" + template.HTMLEscapeString(heading) + "</h1>\n")
+		for _, section := range []struct {
+			label string
+			talks []Talk
+		}{{"upcoming", upcoming}, {"past", past}, {"publications", publications}} {
+			if len(section.talks) == 0 {
+				continue
+			}
+			content.WriteString("<section class=\"talks\">\n<h2>" + template.HTMLEscapeString(talkLabels[section.label].In(lang)) + "</h2>\n")
+			if section.label == "upcoming" {
+				content.WriteString("<p class=\"talks-calendar\"><a href=\"/talks.ics\">" + template.HTMLEscapeString(talkLabels["calendar"].In(lang)) + "</a></p>\n")
+			}
+			for _, t := range section.talks {
+				content.WriteString(talkHTML(t, lang))
+			}
+			content.WriteString("</section>\n")
+		}
+		renderPage(w, heading, template.HTML(content.String()))
+	}
+}
+
+// TalksCalendarHandler serves upcoming talks as an iCalendar feed
+func TalksCalendarHandler(path string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		talks, err := loadTalks(path)
+		if err != nil {
+			slog.Error("Error loading talks", "err", err)
+			http.Error(w, "Could not load talks", http.StatusInternalServerError)
+			return
+		}
+		upcoming, _, _ := splitTalks(talks, time.Now())
+
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.Header().Set("Content-Disposition", `inline; filename="talks.ics"`)
+		w.Write([]byte(talksICal(upcoming, r.Host, time.Now())))
+	}
+}
+
+// talksICal renders talks as an iCalendar (RFC 5545) document
+func talksICal(talks []Talk, host string, now time.Time) string {
+	var b strings.Builder
+	line := func(s string) { b.WriteString(icalFold(s) + "\r\n") }
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//" + cfg.Site.Name + "//Talks//EN")
+	line("CALSCALE:GREGORIAN")
+	line("X-WR-CALNAME:" + icalEscape(cfg.Site.Name+" talks"))
+	for _, t := range talks {
+		line("BEGIN:VEVENT")
+		// The UID must not change between fetches or calendars show duplicates
+		sum := sha256.Sum256([]byte(t.Date + "\x00" + t.Title.In("en")))
+		line("UID:" + hex.EncodeToString(sum[:8]) + "@" + host)
+		line("DTSTAMP:" + now.UTC().Format("20060102T150405Z"))
+		if t.allDay {
+			line("DTSTART;VALUE=DATE:" + t.start.Format("20060102"))
+			line("DTEND;VALUE=DATE:" + t.End().Format("20060102"))
+		} else {
+			line("DTSTART:" + t.start.UTC().Format("20060102T150405Z"))
+			line("DTEND:" + t.End().UTC().Format("20060102T150405Z"))
+		}
+		line("SUMMARY:" + icalEscape(t.Title.In("en")))
+		if where := strings.Trim(t.Venue+", "+t.Location, ", "); where != "" {
+			line("LOCATION:" + icalEscape(where))
+		}
+		if abstract := t.Abstract.In("en"); abstract != "" {
+			line("DESCRIPTION:" + icalEscape(abstract))
+		}
+		if u := linkPostURL(t.URL); u != "" {
+			line("URL:" + u)
+		}
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return b.String()
+}
+
+// icalEscape escapes text property values
+func icalEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// icalFold splits content lines longer than 75 octets, without breaking UTF-8
+// sequences, as RFC 5545 requires
+func icalFold(s string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range s {
+		size := utf8.RuneLen(r)
+		if n+size > 75 {
+			b.WriteString("\r\n ")
+			n = 1
+		}
+		b.WriteRune(r)
+		n += size
+	}
+	return b.String()
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+const testTalks = `
+- title: Go in 2099
+  date: 2099-05-01 14:00
+  duration: 30m
+  venue: GopherCon
+  location: Bangkok, Thailand
+  abstract: What's next; probably generics.
+  url: https://gophercon.example
+- title:
+    en: Writing a blog in Go
+    th: เขียนบล็อกด้วย Go
+  date: 2025-03-01
+  venue: Go Meetup
+  slides: https://slides.example/blog
+  youtube: dQw4w9WgXcQ
+- title: Notes on SQLite
+  kind: publication
+  date: 2025-06-01
+  venue: Some Magazine
+  url: https://magazine.example/sqlite
+- title: No date
+  date: someday
+`
+
+func writeTestTalks(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "talks.yaml")
+	if err := os.WriteFile(path, []byte(testTalks), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestTalksHandler(t *testing.T) {
+	path := writeTestTalks(t)
+	w := httptest.NewRecorder()
+	TalksHandler(path)(w, httptest.NewRequest("GET", "/talks?lang=th", nil))
+
+	body := w.Body.String()
+	upcoming, past, pubs := strings.Index(body, "Go in 2099"), strings.Index(body, "เขียนบล็อกด้วย Go"), strings.Index(body, "Notes on SQLite")
+	if upcoming < 0 || past < 0 || pubs < 0 || !(upcoming < past && past < pubs) {
+		t.Errorf("expected upcoming, past, then publications, got %s", body)
+	}
+	for _, want := range []string{
+		`href="/talks.ics"`,
+		`data-embed-src="https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ`,
+		`<a href="https://slides.example/blog" rel="noopener">สไลด์</a>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q in %s", want, body)
+		}
+	}
+	if strings.Contains(body, "No date") {
+		t.Error("talk without a valid date should be skipped")
+	}
+}
+
+func TestTalksICal(t *testing.T) {
+	talks, err := loadTalks(writeTestTalks(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	upcoming, _, _ := splitTalks(talks, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	if len(upcoming) != 2 {
+		t.Fatalf("expected 2 upcoming talks in 2025, got %d", len(upcoming))
+	}
+
+	ics := talksICal(upcoming, "blog.example", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"DTSTART;VALUE=DATE:20250301\r\nDTEND;VALUE=DATE:20250302\r\n",
+		"DTSTART:20990501T070000Z\r\nDTEND:20990501T073000Z\r\n",
+		`LOCATION:GopherCon\, Bangkok\, Thailand`,
+		`DESCRIPTION:What's next\; probably generics.`,
+		"@blog.example\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(ics, want) {
+			t.Errorf("missing %q in\n%s", want, ics)
+		}
+	}
+	for _, line := range strings.Split(ics, "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line longer than 75 octets: %q", line)
+		}
+	}
+}
+
+func TestICalFold(t *testing.T) {
+	long := "SUMMARY:" + strings.Repeat("ก", 40)
+	folded := icalFold(long)
+	if strings.ReplaceAll(folded, "\r\n ", "") != long {
+		t.Errorf("unfolding did not restore the line: %q", folded)
+	}
+	for _, line := range strings.Split(folded, "\r\n") {
+		if len(line) > 75 || !utf8.ValidString(line) {
+			t.Errorf("bad folded line: %q", line)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// taxonomySlug normalizes a tag or category for URLs and matching: "Go Tips"
+// and "go-tips" are the same tag. Letters outside ASCII, such as Thai, are kept.
+func taxonomySlug(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), "-")
+}
+
+// taxonomyURL is the listing page of a tag or category; section is "tags" or "categories"
+func taxonomyURL(section, name string) string {
+	return "/" + section + "/" + url.PathEscape(taxonomySlug(name))
+}
+
+// postTagsHTML renders a post's category and tags as chips linking to their
+// listing pages, or nothing when it has neither
+func postTagsHTML(category string, tags []string) string {
+	if category == "" && len(tags) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("<span class=\"post-tags\">")
+	if category != "" {
+		b.WriteString("<a class=\"category-chip\" href=\"" + template.HTMLEscapeString(taxonomyURL("categories", category)) + "\">" + template.HTMLEscapeString(category) + "</a>")
+	}
+	for _, tag := range tags {
+		if taxonomySlug(tag) == "" {
+			continue
+		}
+		b.WriteString("<a class=\"tag-chip\" href=\"" + template.HTMLEscapeString(taxonomyURL("tags", tag)) + "\">#" + template.HTMLEscapeString(tag) + "</a>")
+	}
+	b.WriteString("</span>")
+	return b.String()
+}
+
+// taxonomyHandler lists the posts that names returns a match for, titled
+// with the matching name as the post spells it. Unknown names are a 404.
+func taxonomyHandler(posts *PostIndex, heading string, names func(Post) []string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setSecurityHeaders(w)
+		// Category pages are thin listings just like tag pages
+		markNoIndex(w, PageTag)
+
+		want := taxonomySlug(r.PathValue("name"))
+		lang := pathLang(r)
+		var name string
+		var matched []Post
+		for _, post := range posts.Posts() {
+			if lang != "" && !postInLang(post, lang) {
+				continue
+			}
+			for _, n := range names(post) {
+				if taxonomySlug(n) == want {
+					if name == "" {
+						name = n
+					}
+					matched = append(matched, post)
+					break
+				}
+			}
+		}
+		if want == "" || len(matched) == 0 {
+			renderError(w, http.StatusNotFound, requestLang(r))
+			return
+		}
+
+		var content strings.Builder
+		content.WriteString("<h1>" + template.HTMLEscapeString(heading) + ": " + template.HTMLEscapeString(name) + "</h1>\n")
+		content.WriteString("<ul class=\"post-list\">\n")
+		for _, post := range matched {
+			content.WriteString(postListItemHTML(post, 0, requestLang(r)))
+		}
+		content.WriteString("</ul>\n")
+
+		renderPage(w, heading+": "+name, template.HTML(content.String()))
+	}
+}
+
+// TagHandler lists the posts with the tag at /tags/{name}
+func TagHandler(posts *PostIndex) http.HandlerFunc {
+	return taxonomyHandler(posts, "Tag", func(p Post) []string { return p.Tags })
+}
+
+// CategoryHandler lists the posts in the category at /categories/{name}
+func CategoryHandler(posts *PostIndex) http.HandlerFunc {
+	return taxonomyHandler(posts, "Category", func(p Post) []string {
+		if p.Category == "" {
+			return nil
+		}
+		return []string{p.Category}
+	})
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTaxonomySlug(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Go", "go"},
+		{"  Go   Tips ", "go-tips"},
+		{"go-tips", "go-tips"},
+		{"ภาษาไทย", "ภาษาไทย"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := taxonomySlug(tt.in); got != tt.want {
+			t.Errorf("taxonomySlug(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPostTagsHTML(t *testing.T) {
+	if got := postTagsHTML("", nil); got != "" {
+		t.Errorf("empty = %q", got)
+	}
+	got := postTagsHTML("Tutorials", []string{"Go Tips", "<b>", "ภาษาไทย", " "})
+	for _, want := range []string{
+		`<a class="category-chip" href="/categories/tutorials">Tutorials</a>`,
+		`<a class="tag-chip" href="/tags/go-tips">#Go Tips</a>`,
+		`#&lt;b&gt;</a>`,
+		`href="/tags/%E0%B8%A0%E0%B8%B2%E0%B8%A9%E0%B8%B2%E0%B9%84%E0%B8%97%E0%B8%A2"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %s in %s", want, got)
+		}
+	}
+	if strings.Count(got, "tag-chip") != 3 {
+		t.Errorf("blank tag rendered: %s", got)
+	}
+}
+
+func TestTagAndCategoryHandlers(t *testing.T) {
+	dir := t.TempDir()
+	posts := map[string]string{
+		"one":   "---\ntitle: One\ndate: 2025-01-01\ntags: [Go, web]\ncategory: Tutorials\n---\n",
+		"two":   "---\ntitle: Two\ndate: 2025-02-01\ntags: [go]\n---\n",
+		"three": "---\ntitle: Three\ndate: 2025-03-01\ntags: [rust]\ncategory: Notes\n---\n",
+	}
+	for slug, md := range posts {
+		os.WriteFile(filepath.Join(dir, slug+".md"), []byte(md), 0644)
+	}
+	idx := newTestPostIndex(t, dir)
+
+	serve := func(h http.HandlerFunc, name string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.SetPathValue("name", name)
+		w := httptest.NewRecorder()
+		h(w, req)
+		return w
+	}
+
+	w := serve(TagHandler(idx), "go")
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.Contains(body, "<h1>Tag: go</h1>") {
+		t.Fatalf("status %d, body %s", w.Code, body)
+	}
+	if !strings.Contains(body, `href="/posts/one"`) || !strings.Contains(body, `href="/posts/two"`) || strings.Contains(body, `href="/posts/three"`) {
+		t.Errorf("wrong posts for tag go: %s", body)
+	}
+	if strings.Index(body, `/posts/two"`) > strings.Index(body, `/posts/one"`) {
+		t.Error("posts not newest first")
+	}
+	if !strings.Contains(w.Header().Get("X-Robots-Tag"), "noindex") {
+		t.Error("tag page not marked noindex")
+	}
+
+	w = serve(CategoryHandler(idx), "tutorials")
+	if body := w.Body.String(); w.Code != http.StatusOK || !strings.Contains(body, "Category: Tutorials") || strings.Contains(body, `href="/posts/two"`) {
+		t.Errorf("category page: %d %s", w.Code, body)
+	}
+
+	if w := serve(TagHandler(idx), "python"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown tag status = %d", w.Code)
+	}
+	if w := serve(CategoryHandler(idx), ""); w.Code != http.StatusNotFound {
+		t.Errorf("empty category status = %d", w.Code)
+	}
+}
+
+func TestHomeHandler_TagChips(t *testing.T) {
+	t.Chdir(t.TempDir())
+	os.Mkdir("posts", 0755)
+	os.WriteFile(filepath.Join("posts", "hello.md"), []byte("---\ntitle: Hello\ntags: [go]\ncategory: Notes\n---\n"), 0644)
+
+	w := httptest.NewRecorder()
+	HomeHandler(newTestPostIndex(t, "posts"), nil)(w, httptest.NewRequest("GET", "/", nil))
+	body := w.Body.String()
+	if !strings.Contains(body, `<a class="tag-chip" href="/tags/go">#go</a>`) || !strings.Contains(body, `href="/categories/notes"`) {
+		t.Errorf("homepage missing chips: %s", body)
+	}
+}
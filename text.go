@@ -0,0 +1,103 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// thaiWords splits Thai text into words outside search too: for excerpt breaks
+// and reading times. main loads it from search.thai_dictionary.
+var thaiWords = NewThaiDictionary(nil)
+
+// detectLang guesses the language of text: th when at least a fifth of its
+// letters are Thai, en otherwise
+func detectLang(text string) string {
+	letters, thai := 0, 0
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			letters++
+			if isThai(r) {
+				thai++
+			}
+		}
+	}
+	if letters > 0 && thai*5 >= letters {
+		return "th"
+	}
+	return "en"
+}
+
+// contentLang returns lang, or when it's empty the language detected in text
+func contentLang(lang, text string) string {
+	if lang != "" {
+		return lang
+	}
+	return detectLang(text)
+}
+
+// isLeadingVowel reports whether r is a Thai vowel written before the consonant
+// it follows in speech (เ แ โ ใ ไ)
+func isLeadingVowel(r rune) bool {
+	return r >= 'เ' && r <= 'ไ'
+}
+
+// clusterBoundary moves i back until cutting rs there keeps every character
+// cluster whole: a letter with its vowel and tone marks, and a Thai leading
+// vowel with the consonant after it
+func clusterBoundary(rs []rune, i int) int {
+	for i > 0 && i < len(rs) && (unicode.Is(unicode.Mn, rs[i]) || rs[i] == 'ำ' || isLeadingVowel(rs[i-1])) {
+		i--
+	}
+	return i
+}
+
+// wordBreakBefore returns the last word break at or before n: a space or, in
+// Thai text, a boundary between dictionary words. It returns 0 when there is none.
+func wordBreakBefore(rs []rune, n int, lang string) int {
+	best := 0
+	for i := n; i > 0; i-- {
+		if unicode.IsSpace(rs[i]) {
+			best = i
+			break
+		}
+	}
+	if lang != "th" || !isThai(rs[n-1]) || !isThai(rs[n]) {
+		return best
+	}
+	// n falls inside a run of Thai; break it into words
+	start, end := n, n
+	for start > 0 && isThai(rs[start-1]) {
+		start--
+	}
+	for end < len(rs) && isThai(rs[end]) {
+		end++
+	}
+	at := start
+	for _, word := range thaiWords.Segment(string(rs[start:end])) {
+		at += len([]rune(word))
+		if at > n {
+			break
+		}
+		best = max(best, at)
+	}
+	return best
+}
+
+// truncateText shortens text to at most n characters and an ellipsis, cut at a
+// word break when there is one. Thai (lang th, or detected when lang is empty)
+// is broken between dictionary words, since it has no spaces between them.
+// Character clusters are never split.
+func truncateText(text string, n int, lang string) string {
+	rs := []rune(text)
+	if len(rs) <= n {
+		return text
+	}
+	if lang == "" {
+		lang = detectLang(text)
+	}
+	cut := wordBreakBefore(rs, n, lang)
+	if cut == 0 {
+		cut = clusterBoundary(rs, n)
+	}
+	return strings.TrimRight(string(rs[:cut]), " ,.;:") + "…"
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+)
+
+func TestDetectLang(t *testing.T) {
+	tests := []struct {
+		text, want string
+	}{
+		{"Learning Go", "en"},
+		{"เรียนรู้ภาษา Go", "th"},
+		{"A long English sentence with one word ไทย", "en"},
+		{"", "en"},
+	}
+	for _, tt := range tests {
+		if got := detectLang(tt.text); got != tt.want {
+			t.Errorf("detectLang(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	defer func(old *ThaiDictionary) { thaiWords = old }(thaiWords)
+	thaiWords = NewThaiDictionary([]string{"เรียน", "เขียน", "โปรแกรม", "ภาษา"})
+
+	tests := []struct {
+		name, text string
+		n          int
+		lang       string
+		want       string
+	}{
+		{"short", "Hello world", 20, "", "Hello world"},
+		{"at a space", "Hello wonderful world", 12, "en", "Hello…"},
+		{"punctuation trimmed", "Hello, wonderful world", 10, "en", "Hello…"},
+		{"thai word break", "เรียนเขียนโปรแกรม", 12, "th", "เรียนเขียน…"},
+		{"thai without dictionary words", "กกกกกกกกกก", 5, "th", "กกกกก…"},
+		{"tone mark kept", "ที่ที่ที่", 5, "th", "ที่…"},
+		{"leading vowel kept", "กกเก", 3, "th", "กก…"},
+	}
+	for _, tt := range tests {
+		if got := truncateText(tt.text, tt.n, tt.lang); got != tt.want {
+			t.Errorf("%s: truncateText(%q, %d) = %q, want %q", tt.name, tt.text, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestTruncateText_NeverSplitsClusters(t *testing.T) {
+	text := strings.Repeat("น้ำเก่า", 20)
+	rs := []rune(text)
+	for n := 1; n < 40; n++ {
+		kept := utf8.RuneCountInString(strings.TrimSuffix(truncateText(text, n, "th"), "…"))
+		if next := rs[kept]; unicode.Is(unicode.Mn, next) || next == 'ำ' || kept > 0 && isLeadingVowel(rs[kept-1]) {
+			t.Errorf("n=%d: cut after %q splits a cluster", n, string(rs[:kept]))
+		}
+	}
+}
+
+func TestToTitleCase(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"hello world", "Hello World"},
+		{"เรียน go ภาษาไทย", "เรียน Go ภาษาไทย"},
+		{"ที่ 1", "ที่ 1"},
+	}
+	for _, tt := range tests {
+		if got := toTitleCase(tt.in); got != tt.want {
+			t.Errorf("toTitleCase(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// ThaiDictionary holds the words Thai text is split into. Thai is written
+// without spaces between words, so search needs a word list to find them.
+type ThaiDictionary struct {
+	words  map[string]bool
+	maxLen int // longest word in runes
+}
+
+// NewThaiDictionary builds a dictionary from a list of words
+func NewThaiDictionary(words []string) *ThaiDictionary {
+	d := &ThaiDictionary{words: make(map[string]bool, len(words))}
+	for _, w := range words {
+		if w = strings.TrimSpace(w); w == "" {
+			continue
+		}
+		d.words[w] = true
+		if n := len([]rune(w)); n > d.maxLen {
+			d.maxLen = n
+		}
+	}
+	return d
+}
+
+// loadThaiDictionary reads one word per line; blank lines and lines starting with # are skipped
+func loadThaiDictionary(path string) (*ThaiDictionary, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var words []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			words = append(words, line)
+		}
+	}
+	return NewThaiDictionary(words), sc.Err()
+}
+
+// isThai reports whether r is in the Thai block
+func isThai(r rune) bool {
+	return unicode.Is(unicode.Thai, r)
+}
+
+// Segment splits a run of Thai text into words by maximal matching: of all the
+// ways to cover the text with dictionary words, it picks the one leaving the
+// fewest characters unmatched, then the one with the fewest words. Unmatched
+// characters are kept together as a single token, never split from their
+// vowel and tone marks.
+func (d *ThaiDictionary) Segment(text string) []string {
+	rs := []rune(text)
+	n := len(rs)
+	if n == 0 {
+		return nil
+	}
+
+	type step struct {
+		unknown, words int
+		from           int
+		known          bool
+	}
+	const inf = 1 << 30
+	best := make([]step, n+1)
+	for i := 1; i <= n; i++ {
+		best[i] = step{unknown: inf}
+	}
+	better := func(a, b step) bool {
+		return a.unknown < b.unknown || a.unknown == b.unknown && a.words < b.words
+	}
+
+	for i := 0; i < n; i++ {
+		if best[i].unknown == inf {
+			continue
+		}
+		for l := 1; l <= d.maxLen && i+l <= n; l++ {
+			if d.words[string(rs[i:i+l])] {
+				cand := step{unknown: best[i].unknown, words: best[i].words + 1, from: i, known: true}
+				if better(cand, best[i+l]) {
+					best[i+l] = cand
+				}
+			}
+		}
+		// Skip one character cluster: a base letter and any marks attached to it
+		j := i + 1
+		for j < n && unicode.Is(unicode.Mn, rs[j]) {
+			j++
+		}
+		cand := step{unknown: best[i].unknown + (j - i), words: best[i].words + 1, from: i}
+		if better(cand, best[j]) {
+			best[j] = cand
+		}
+	}
+
+	var parts []string
+	for end := n; end > 0; {
+		s := best[end]
+		start := s.from
+		if !s.known {
+			// Merge a stretch of unmatched clusters into one token
+			for start > 0 && !best[start].known && best[start].from < start {
+				start = best[start].from
+			}
+		}
+		parts = append(parts, string(rs[start:end]))
+		end = start
+	}
+	for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+		parts[i], parts[j] = parts[j], parts[i]
+	}
+	return parts
+}
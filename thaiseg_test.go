@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestThaiDictionarySegment(t *testing.T) {
+	dict := NewThaiDictionary([]string{"เรียน", "เรียนรู้", "รู้", "ภาษา", "โปรแกรม", "การ", "เขียน", "เขียนโปรแกรม"})
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"เรียนรู้ภาษา", []string{"เรียนรู้", "ภาษา"}},
+		{"การเขียนโปรแกรม", []string{"การ", "เขียนโปรแกรม"}},
+		// Unknown text stays together, with its vowels and tone marks
+		{"สวัสดีภาษา", []string{"สวัสดี", "ภาษา"}},
+		{"ภาษาไทยเรียน", []string{"ภาษา", "ไทย", "เรียน"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := dict.Segment(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Segment(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestLoadThaiDictionary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "words.txt")
+	os.WriteFile(path, []byte("# comment\nภาษา\n\n  ไทย  \n"), 0644)
+	dict, err := loadThaiDictionary(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := dict.Segment("ภาษาไทย"); !reflect.DeepEqual(got, []string{"ภาษา", "ไทย"}) {
+		t.Errorf("Segment = %q", got)
+	}
+	if dict.words["# comment"] {
+		t.Error("comment line loaded as a word")
+	}
+}
+
+func TestBundledThaiDictionary(t *testing.T) {
+	dict, err := loadThaiDictionary("data/thai-words.txt")
+	if err != nil {
+		t.Fatalf("bundled dictionary: %v", err)
+	}
+	if got := dict.Segment("การเขียนโปรแกรมภาษาไทย"); !reflect.DeepEqual(got, []string{"การ", "เขียนโปรแกรม", "ภาษาไทย"}) {
+		t.Errorf("Segment = %q", got)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"html"
+	"html/template"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	// sectionHeadingRegex matches the h2 and h3 headings of rendered markdown
+	sectionHeadingRegex = regexp.MustCompile(`(?s)<h([23])((?:\s[^>]*)?)>(.*?)</h([23])>`)
+	idAttrRegex         = regexp.MustCompile(`\sid="([^"]*)"`)
+)
+
+// tocHeading is one h2 or h3 of a post, as listed in its table of contents
+type tocHeading struct {
+	Level int
+	ID    string
+	Text  string
+}
+
+// headingID makes a fragment ID from heading text, keeping letters and digits
+// in any script so Thai headings get readable anchors too
+func headingID(text string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r):
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		default:
+			dash = true
+		}
+	}
+	if b.Len() == 0 {
+		return "section"
+	}
+	return b.String()
+}
+
+// addHeadingAnchors gives every h2 and h3 in a post an id, unless markdown
+// already gave it one, and a link to itself, and returns the headings in order
+func addHeadingAnchors(content string) (string, []tocHeading) {
+	used := make(map[string]bool)
+	for _, m := range idAttrRegex.FindAllStringSubmatch(content, -1) {
+		used[m[1]] = true
+	}
+
+	var headings []tocHeading
+	out := sectionHeadingRegex.ReplaceAllStringFunc(content, func(tag string) string {
+		m := sectionHeadingRegex.FindStringSubmatch(tag)
+		if m[1] != m[4] {
+			return tag
+		}
+		level, _ := strconv.Atoi(m[1])
+		attrs, inner := m[2], m[3]
+		text := strings.Join(strings.Fields(html.UnescapeString(htmlTagRegex.ReplaceAllString(inner, ""))), " ")
+
+		var id string
+		if idm := idAttrRegex.FindStringSubmatch(attrs); idm != nil {
+			id = html.UnescapeString(idm[1])
+		} else {
+			base := headingID(text)
+			id = base
+			for n := 1; used[id]; n++ {
+				id = base + "-" + strconv.Itoa(n)
+			}
+			used[id] = true
+			attrs = " id=\"" + template.HTMLEscapeString(id) + "\"" + attrs
+		}
+		headings = append(headings, tocHeading{Level: level, ID: id, Text: text})
+
+		anchor := "<a class=\"heading-anchor\" href=\"#" + template.HTMLEscapeString(id) + "\" aria-label=\"Link to this section\">#</a>"
+		return "<h" + m[1] + attrs + ">" + inner + " " + anchor + "</h" + m[1] + ">"
+	})
+	return out, headings
+}
+
+// tocHTML renders a nested table of contents: h3s are listed under the h2
+// before them, and h3s before the first h2 at the top level
+func tocHTML(headings []tocHeading) string {
+	if len(headings) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("<nav class=\"toc\" aria-label=\"Table of contents\">\n<p class=\"toc-title\">Contents</p>\n<ol>\n")
+	open := false // a list of h3s is open inside the last h2's item
+	for i, h := range headings {
+		sub := h.Level == 3 && i > 0 && (open || headings[i-1].Level == 2)
+		switch {
+		case i == 0:
+		case sub && !open:
+			b.WriteString("\n<ol>\n")
+			open = true
+		case !sub && open:
+			b.WriteString("</li>\n</ol>\n</li>\n")
+			open = false
+		default:
+			b.WriteString("</li>\n")
+		}
+		b.WriteString("<li><a href=\"#" + template.HTMLEscapeString(h.ID) + "\">" + template.HTMLEscapeString(h.Text) + "</a>")
+	}
+	b.WriteString("</li>\n")
+	if open {
+		b.WriteString("</ol>\n</li>\n")
+	}
+	b.WriteString("</ol>\n</nav>\n")
+	return b.String()
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHeadingID(t *testing.T) {
+	tests := map[string]string{
+		"Getting Started":      "getting-started",
+		"  What's new in 2.0?": "what-s-new-in-2-0",
+		"ติดตั้ง Go":           "ติดตั้ง-go",
+		"!!!":                  "section",
+	}
+	for in, want := range tests {
+		if got := headingID(in); got != want {
+			t.Errorf("headingID(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestAddHeadingAnchors(t *testing.T) {
+	in := `<h2>Setup</h2>
+<p>x</p>
+<h3>Install <code>go</code></h3>
+<h2 id="custom">Usage</h2>
+<h2>Setup</h2>
+<h4>Deep</h4>`
+	out, headings := addHeadingAnchors(in)
+
+	want := []tocHeading{
+		{2, "setup", "Setup"},
+		{3, "install-go", "Install go"},
+		{2, "custom", "Usage"},
+		{2, "setup-1", "Setup"},
+	}
+	if len(headings) != len(want) {
+		t.Fatalf("headings = %+v", headings)
+	}
+	for i := range want {
+		if headings[i] != want[i] {
+			t.Errorf("heading %d = %+v, want %+v", i, headings[i], want[i])
+		}
+	}
+	for _, s := range []string{
+		`<h2 id="setup">Setup <a class="heading-anchor" href="#setup"`,
+		`<h3 id="install-go">Install <code>go</code> <a class="heading-anchor" href="#install-go"`,
+		`<h2 id="custom">Usage <a class="heading-anchor" href="#custom"`,
+		`<h2 id="setup-1">`,
+		`<h4>Deep</h4>`,
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("output missing %q:\n%s", s, out)
+		}
+	}
+}
+
+func TestTOCHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		headings []tocHeading
+		want     string
+	}{
+		{"none", nil, ""},
+		{
+			"nested",
+			[]tocHeading{{3, "intro", "Intro"}, {2, "a", "A"}, {3, "a1", "A1"}, {3, "a2", "A2"}, {2, "b", "B"}},
+			`<ol>
+<li><a href="#intro">Intro</a></li>
+<li><a href="#a">A</a>
+<ol>
+<li><a href="#a1">A1</a></li>
+<li><a href="#a2">A2</a></li>
+</ol>
+</li>
+<li><a href="#b">B</a></li>
+</ol>`,
+		},
+		{
+			"ends nested",
+			[]tocHeading{{2, "a", "A"}, {3, "a1", "A1"}},
+			`<ol>
+<li><a href="#a">A</a>
+<ol>
+<li><a href="#a1">A1</a></li>
+</ol>
+</li>
+</ol>`,
+		},
+	}
+	for _, tt := range tests {
+		got := tocHTML(tt.headings)
+		if !strings.Contains(got, tt.want) {
+			t.Errorf("%s: tocHTML =\n%s\nwant it to contain\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPostHandler_TOC(t *testing.T) {
+	posts := &MockSlugReader{content: map[string]string{
+		"guide": "---\ntitle: Guide\ntoc: true\n---\n## One\n\ntext\n\n### Two\n",
+		"plain": "---\ntitle: Plain\n---\n## One\n",
+	}}
+	for _, tt := range []struct {
+		slug    string
+		wantTOC bool
+	}{
+		{"guide", true},
+		{"plain", false},
+	} {
+		r := httptest.NewRequest("GET", "/posts/"+tt.slug, nil)
+		r.SetPathValue("slug", tt.slug)
+		w := httptest.NewRecorder()
+		PostHandler(posts, nil, nil)(w, r)
+		body := w.Body.String()
+		if got := strings.Contains(body, `<nav class="toc"`); got != tt.wantTOC {
+			t.Errorf("%s: table of contents = %v, want %v", tt.slug, got, tt.wantTOC)
+		}
+		if !strings.Contains(body, `<h2 id="one">One <a class="heading-anchor" href="#one"`) {
+			t.Errorf("%s: heading has no anchor:\n%s", tt.slug, body)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Request phases timed for the slow request log
+const (
+	PhaseStore    = "store"
+	PhaseMarkdown = "markdown"
+	PhaseTemplate = "template"
+)
+
+type traceKey struct{}
+
+// requestTrace adds up how long each phase of a request took, in the order the
+// phases first ran
+type requestTrace struct {
+	mu     sync.Mutex
+	names  []string
+	phases map[string]time.Duration
+}
+
+func (t *requestTrace) add(name string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.phases[name]; !ok {
+		t.names = append(t.names, name)
+	}
+	t.phases[name] += d
+}
+
+// startPhase starts timing a phase of r; call the returned func when it ends.
+// Outside SlowRequests it does nothing.
+func startPhase(r *http.Request, name string) func() {
+	t, _ := r.Context().Value(traceKey{}).(*requestTrace)
+	if t == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() { t.add(name, time.Since(start)) }
+}
+
+// format describes a request that took total: its phases, and the time
+// spent outside them as other
+func (t *requestTrace) format(total time.Duration) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	parts := make([]string, 0, len(t.names)+1)
+	other := total
+	for _, name := range t.names {
+		parts = append(parts, name+" "+formatMillis(t.phases[name]))
+		other -= t.phases[name]
+	}
+	if other > 0 {
+		parts = append(parts, "other "+formatMillis(other))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatMillis formats d in milliseconds, to a tenth
+func formatMillis(d time.Duration) string {
+	return fmt.Sprintf("%.1fms", float64(d)/float64(time.Millisecond))
+}
+
+// statusRecorder remembers the status a handler responded with and how many
+// body bytes it wrote
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	if sr.status == 0 {
+		sr.status = status
+	}
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+func (sr *statusRecorder) Write(p []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	n, err := sr.ResponseWriter.Write(p)
+	sr.bytes += n
+	return n, err
+}
+
+// code returns the status sent, 200 when the handler never set one
+func (sr *statusRecorder) code() int {
+	if sr.status == 0 {
+		return http.StatusOK
+	}
+	return sr.status
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (sr *statusRecorder) Unwrap() http.ResponseWriter { return sr.ResponseWriter }
+
+// SlowRequests logs every request that takes threshold or longer, with how long
+// was spent reading posts, rendering markdown, and executing templates
+func SlowRequests(threshold time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		trace := &requestTrace{phases: make(map[string]time.Duration)}
+		sr := &statusRecorder{ResponseWriter: w}
+		start := time.Now()
+		next.ServeHTTP(sr, r.WithContext(context.WithValue(r.Context(), traceKey{}, trace)))
+		total := time.Since(start)
+		if total < threshold {
+			return
+		}
+		metrics.Inc("slow_requests_total")
+		slog.Warn("Slow request",
+			"method", r.Method,
+			"path", r.URL.RequestURI(),
+			"status", sr.code(),
+			"duration", formatMillis(total),
+			"phases", trace.format(total),
+		)
+	})
+}
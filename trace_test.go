@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestTraceFormat(t *testing.T) {
+	trace := &requestTrace{phases: make(map[string]time.Duration)}
+	trace.add(PhaseStore, 2*time.Millisecond)
+	trace.add(PhaseMarkdown, 30*time.Millisecond)
+	trace.add(PhaseStore, time.Millisecond)
+
+	got := trace.format(40 * time.Millisecond)
+	want := "store 3.0ms, markdown 30.0ms, other 7.0ms"
+	if got != want {
+		t.Errorf("format = %q, want %q", got, want)
+	}
+}
+
+func TestStartPhase_OutsideSlowRequests(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	startPhase(r, PhaseStore)() // must not panic without a trace
+}
+
+func TestSlowRequests(t *testing.T) {
+	logged := captureLogs(t)
+
+	slow := SlowRequests(10*time.Millisecond, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		done := startPhase(r, PhaseMarkdown)
+		time.Sleep(15 * time.Millisecond)
+		done()
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+		}
+	}))
+
+	slow.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/missing?x=1", nil))
+	out := logged.String()
+	if !strings.Contains(out, `msg="Slow request" method=GET path="/missing?x=1" status=404 duration=`) || !strings.Contains(out, `phases="markdown `) {
+		t.Errorf("log = %q, want the request, status, and markdown phase", out)
+	}
+
+	logged.Reset()
+	fast := SlowRequests(time.Hour, slow)
+	fast.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if strings.Count(logged.String(), "Slow request") != 1 {
+		t.Errorf("log = %q, want only the inner 10ms threshold to log", logged.String())
+	}
+}
+
+func TestPostHandler_Phases(t *testing.T) {
+	logged := captureLogs(t)
+
+	handler := SlowRequests(0, PostHandler(&MockSlugReader{content: map[string]string{"hello": "# Hello\n\nWorld"}}, nil, nil))
+	req := httptest.NewRequest("GET", "/posts/hello", nil)
+	req.SetPathValue("slug", "hello")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	out := logged.String()
+	for _, phase := range []string{"store ", "markdown ", "template "} {
+		if !strings.Contains(out, phase) {
+			t.Errorf("log = %q, missing phase %q", out, phase)
+		}
+	}
+}
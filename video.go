@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net/url"
+	"regexp"
+	"strconv"
+)
+
+var (
+	youtubeIDRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+	vimeoIDRegex   = regexp.MustCompile(`^[0-9]+$`)
+)
+
+func init() {
+	shortcodes["youtube"] = youtubeShortcode
+	shortcodes["vimeo"] = vimeoShortcode
+}
+
+// youtubeShortcode renders {{< youtube ID [title="..."] [start=30] [poster="/images/x.jpg"] [load=click] >}}
+// through youtube-nocookie.com, which sets no tracking cookies until playback
+func youtubeShortcode(call ShortcodeCall) (string, error) {
+	id := call.Arg(0)
+	if !youtubeIDRegex.MatchString(id) {
+		return "", fmt.Errorf("invalid YouTube video ID %q", id)
+	}
+	q := url.Values{}
+	if start, err := strconv.Atoi(call.Params["start"]); err == nil && start > 0 {
+		q.Set("start", strconv.Itoa(start))
+	}
+	return videoEmbedHTML("https://www.youtube-nocookie.com/embed/"+id, q, call), nil
+}
+
+// vimeoShortcode renders {{< vimeo ID [title="..."] [poster="/images/x.jpg"] [load=click] >}}
+// with Vimeo's do-not-track flag
+func vimeoShortcode(call ShortcodeCall) (string, error) {
+	id := call.Arg(0)
+	if !vimeoIDRegex.MatchString(id) {
+		return "", fmt.Errorf("invalid Vimeo video ID %q", id)
+	}
+	q := url.Values{"dnt": {"1"}}
+	return videoEmbedHTML("https://player.vimeo.com/video/"+id, q, call), nil
+}
+
+// videoEmbedHTML renders a lazy-loaded iframe, or with a poster or load=click a
+// local placeholder button that swaps in the iframe only when clicked, so the
+// third party is not contacted on page load
+func videoEmbedHTML(src string, q url.Values, call ShortcodeCall) string {
+	title := call.Params["title"]
+	if title == "" {
+		title = "Video"
+	}
+	poster := call.Params["poster"]
+
+	if poster == "" && call.Params["load"] != "click" {
+		if len(q) > 0 {
+			src += "?" + q.Encode()
+		}
+		return `<div class="video-embed"><iframe src="` + template.HTMLEscapeString(src) + `" title="` +
+			template.HTMLEscapeString(title) + `" loading="lazy" allow="fullscreen; picture-in-picture" allowfullscreen></iframe></div>`
+	}
+
+	q.Set("autoplay", "1")
+	// Only local posters are allowed; a remote one would defeat the purpose
+	style := ""
+	if u, err := url.Parse(poster); poster != "" && err == nil && u.Host == "" && u.Scheme == "" {
+		style = ` style="background-image: url('` + template.HTMLEscapeString(u.EscapedPath()) + `')"`
+	}
+	return `<div class="video-embed video-click-to-load" data-embed-src="` + template.HTMLEscapeString(src+"?"+q.Encode()) +
+		`" data-embed-title="` + template.HTMLEscapeString(title) + `"><button type="button" class="video-poster"` + style +
+		`><span class="video-play" aria-hidden="true">▶</span><span class="video-title">` + template.HTMLEscapeString(title) + `</span></button></div>`
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVideoShortcodes(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		want    []string
+		notWant []string
+	}{
+		{
+			name: "youtube uses nocookie domain and lazy loading",
+			src:  `{{< youtube dQw4w9WgXcQ start=42 title="Demo" >}}`,
+			want: []string{`src="https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ?start=42"`, `loading="lazy"`, `title="Demo"`},
+		},
+		{
+			name: "vimeo sets do-not-track",
+			src:  `{{< vimeo 76979871 >}}`,
+			want: []string{`src="https://player.vimeo.com/video/76979871?dnt=1"`},
+		},
+		{
+			name:    "poster defers the iframe until clicked",
+			src:     `{{< youtube dQw4w9WgXcQ poster="/images/cover.jpg" >}}`,
+			want:    []string{`class="video-embed video-click-to-load"`, `data-embed-src="https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ?autoplay=1"`, `url('/images/cover.jpg')`},
+			notWant: []string{"<iframe"},
+		},
+		{
+			name:    "remote posters are ignored",
+			src:     `{{< vimeo 1 poster="https://i.vimeocdn.com/x.jpg" >}}`,
+			want:    []string{"video-click-to-load"},
+			notWant: []string{"vimeocdn", "<iframe"},
+		},
+		{
+			name:    "invalid ID renders nothing",
+			src:     `{{< youtube "x onload=alert(1)" >}}`,
+			want:    []string{"<!-- shortcode youtube failed -->"},
+			notWant: []string{"iframe", "alert"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html, err := renderMarkdown(tt.src, "post")
+			if err != nil {
+				t.Fatalf("renderMarkdown: %v", err)
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(html, w) {
+					t.Errorf("expected %q in %s", w, html)
+				}
+			}
+			for _, nw := range tt.notWant {
+				if strings.Contains(html, nw) {
+					t.Errorf("did not expect %q in %s", nw, html)
+				}
+			}
+		})
+	}
+}
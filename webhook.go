@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// WebhookDelivery is the payload of a "webhook" job
+type WebhookDelivery struct {
+	URL  string          `json:"url"`
+	Body json.RawMessage `json:"body"`
+}
+
+// WebhookJob returns a JobHandler that POSTs the delivery body as JSON
+func WebhookJob(client *http.Client) JobHandler {
+	return func(ctx context.Context, payload []byte) error {
+		var d WebhookDelivery
+		if err := json.Unmarshal(payload, &d); err != nil {
+			return fmt.Errorf("decode webhook job: %w", err)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Body))
+		if err != nil {
+			return fmt.Errorf("build webhook request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("deliver webhook to %s: %w", d.URL, err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("deliver webhook to %s: unexpected status %s", d.URL, resp.Status)
+		}
+		return nil
+	}
+}